@@ -19,7 +19,11 @@ fi
 
 # 构建 Docker 镜像
 echo "正在构建 Docker 镜像..."
-docker build -t ${IMAGE_NAME} .
+docker build \
+  --build-arg VERSION="$(git describe --tags --always --dirty 2>/dev/null || echo dev)" \
+  --build-arg GIT_COMMIT="$(git rev-parse HEAD 2>/dev/null)" \
+  --build-arg BUILD_TIME="$(date -u +%Y-%m-%dT%H:%M:%SZ)" \
+  -t ${IMAGE_NAME} .
 
 # 给镜像打标签
 echo "正在给镜像打标签..."
@@ -0,0 +1,209 @@
+// Package auth Web 界面和 API 的登录认证：用户和角色来自配置，会话保存在内存中，进程重启后需要重新登录
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"pikachun/internal/config"
+)
+
+// Role 用户角色
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"    // 所有操作，含维护暂停和源库账号轮换
+	RoleOperator Role = "operator" // 管理任务和重新投递
+	RoleViewer   Role = "viewer"   // 只能查看
+)
+
+// CanMutate 是否可以修改任务等数据
+func (r Role) CanMutate() bool {
+	return r == RoleAdmin || r == RoleOperator
+}
+
+// maxActivities 每个用户保留的最近操作数
+const maxActivities = 50
+
+// ErrInvalidCredentials 用户名或密码错误
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// User 登录用户
+type User struct {
+	Username string `json:"username"`
+	Role     Role   `json:"role"`
+}
+
+// Session 登录会话
+type Session struct {
+	ID        string    `json:"-"`
+	User      User      `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Activity 用户的一次操作
+type Activity struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"` // 如 login、POST /api/v1/tasks
+	Status int       `json:"status,omitempty"`
+}
+
+type account struct {
+	user User
+	hash []byte
+}
+
+// Authenticator 校验用户名密码并管理会话，未配置用户时不启用
+type Authenticator struct {
+	accounts map[string]account
+	ttl      time.Duration
+
+	mu         sync.Mutex
+	sessions   map[string]*Session
+	activities map[string][]Activity
+}
+
+// New 根据配置的用户创建认证器，ttl 为会话的空闲过期时间
+func New(users []config.UserConfig, ttl time.Duration) (*Authenticator, error) {
+	accounts := make(map[string]account, len(users))
+	for _, u := range users {
+		if u.Username == "" || u.PasswordHash == "" {
+			return nil, fmt.Errorf("user %q requires username and password_hash", u.Username)
+		}
+		role := Role(u.Role)
+		if role != RoleAdmin && role != RoleOperator && role != RoleViewer {
+			return nil, fmt.Errorf("invalid role %q for user %s, supported: admin, operator, viewer", u.Role, u.Username)
+		}
+		if _, err := bcrypt.Cost([]byte(u.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("invalid password_hash for user %s: %v", u.Username, err)
+		}
+		accounts[u.Username] = account{user: User{Username: u.Username, Role: role}, hash: []byte(u.PasswordHash)}
+	}
+	if ttl <= 0 {
+		ttl = 12 * time.Hour
+	}
+
+	return &Authenticator{
+		accounts:   accounts,
+		ttl:        ttl,
+		sessions:   make(map[string]*Session),
+		activities: make(map[string][]Activity),
+	}, nil
+}
+
+// HashPassword 生成用于配置文件的 bcrypt 哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Enabled 是否配置了用户
+func (a *Authenticator) Enabled() bool {
+	return a != nil && len(a.accounts) > 0
+}
+
+// Login 校验用户名密码并创建会话
+func (a *Authenticator) Login(username, password string) (*Session, error) {
+	acc, ok := a.accounts[username]
+	if !ok || bcrypt.CompareHashAndPassword(acc.hash, []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %v", err)
+	}
+	now := time.Now()
+	session := &Session{
+		ID:        hex.EncodeToString(buf),
+		User:      acc.user,
+		CreatedAt: now,
+		ExpiresAt: now.Add(a.ttl),
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.removeExpired(now)
+	a.sessions[session.ID] = session
+	a.record(username, Activity{Time: now, Action: "login"})
+	copied := *session
+	return &copied, nil
+}
+
+// Session 查找未过期的会话并延长过期时间
+func (a *Authenticator) Session(id string) (*Session, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	session, ok := a.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	if now.After(session.ExpiresAt) {
+		delete(a.sessions, id)
+		return nil, false
+	}
+	session.ExpiresAt = now.Add(a.ttl)
+	copied := *session
+	return &copied, true
+}
+
+// Logout 删除会话
+func (a *Authenticator) Logout(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if session, ok := a.sessions[id]; ok {
+		delete(a.sessions, id)
+		a.record(session.User.Username, Activity{Time: time.Now(), Action: "logout"})
+	}
+}
+
+// Record 记录用户的一次操作
+func (a *Authenticator) Record(username, action string, status int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.record(username, Activity{Time: time.Now(), Action: action, Status: status})
+}
+
+// Activities 用户最近的操作，最新的在前
+func (a *Authenticator) Activities(username string) []Activity {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	activities := a.activities[username]
+	result := make([]Activity, len(activities))
+	for i, activity := range activities {
+		result[len(activities)-1-i] = activity
+	}
+	return result
+}
+
+// record 调用方需持有 a.mu
+func (a *Authenticator) record(username string, activity Activity) {
+	activities := append(a.activities[username], activity)
+	if len(activities) > maxActivities {
+		activities = activities[len(activities)-maxActivities:]
+	}
+	a.activities[username] = activities
+}
+
+// removeExpired 清理过期会话，调用方需持有 a.mu
+func (a *Authenticator) removeExpired(now time.Time) {
+	for id, session := range a.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(a.sessions, id)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"pikachun/internal/config"
+)
+
+func testUsers(t *testing.T) []config.UserConfig {
+	// 测试中使用最低成本，避免拖慢测试
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []config.UserConfig{
+		{Username: "alice", PasswordHash: string(hash), Role: "admin"},
+		{Username: "bob", PasswordHash: string(hash), Role: "viewer"},
+	}
+}
+
+// TestNewValidatesUsers 测试用户配置校验
+func TestNewValidatesUsers(t *testing.T) {
+	authn, err := New(nil, 0)
+	if err != nil || authn.Enabled() {
+		t.Fatalf("expected disabled authenticator without users, got %v", err)
+	}
+
+	users := testUsers(t)
+	users[1].Role = "guest"
+	if _, err := New(users, time.Hour); err == nil {
+		t.Error("expected error for unknown role")
+	}
+	users = testUsers(t)
+	users[0].PasswordHash = "secret"
+	if _, err := New(users, time.Hour); err == nil {
+		t.Error("expected error for a plain text password")
+	}
+}
+
+// TestLoginAndSessions 测试登录、会话过期和退出
+func TestLoginAndSessions(t *testing.T) {
+	authn, err := New(testUsers(t), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := authn.Login("alice", "wrong"); err != ErrInvalidCredentials {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+	if _, err := authn.Login("carol", "secret"); err != ErrInvalidCredentials {
+		t.Errorf("expected invalid credentials for unknown user, got %v", err)
+	}
+
+	session, err := authn.Login("bob", "secret")
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	found, ok := authn.Session(session.ID)
+	if !ok || found.User.Username != "bob" || found.User.Role != RoleViewer || found.User.Role.CanMutate() {
+		t.Fatalf("unexpected session %+v", found)
+	}
+
+	// 过期的会话不可用
+	authn.mu.Lock()
+	authn.sessions[session.ID].ExpiresAt = time.Now().Add(-time.Second)
+	authn.mu.Unlock()
+	if _, ok := authn.Session(session.ID); ok {
+		t.Error("expired session should be rejected")
+	}
+
+	session, _ = authn.Login("alice", "secret")
+	authn.Logout(session.ID)
+	if _, ok := authn.Session(session.ID); ok {
+		t.Error("session should be removed after logout")
+	}
+}
+
+// TestActivities 测试最近操作按时间倒序且有数量上限
+func TestActivities(t *testing.T) {
+	authn, err := New(testUsers(t), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxActivities+5; i++ {
+		authn.Record("alice", "POST /api/v1/tasks", 201)
+	}
+	authn.Record("alice", "DELETE /api/v1/tasks/1", 200)
+
+	activities := authn.Activities("alice")
+	if len(activities) != maxActivities {
+		t.Fatalf("expected %d activities, got %d", maxActivities, len(activities))
+	}
+	if activities[0].Action != "DELETE /api/v1/tasks/1" {
+		t.Errorf("expected latest activity first, got %+v", activities[0])
+	}
+	if len(authn.Activities("bob")) != 0 {
+		t.Error("activities should be per user")
+	}
+}
@@ -0,0 +1,478 @@
+package canal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pikachun/internal/config"
+	"pikachun/internal/objectstore"
+	"pikachun/internal/parquet"
+)
+
+// 归档文件格式
+const (
+	ArchiveFormatNDJSON  = "ndjson"
+	ArchiveFormatParquet = "parquet"
+)
+
+// archiveColumns Parquet 归档文件的列，行镜像以 JSON 字符串存储
+var archiveColumns = []parquet.Column{
+	{Name: "id", Type: parquet.String},
+	{Name: "schema", Type: parquet.String},
+	{Name: "table", Type: parquet.String},
+	{Name: "event_type", Type: parquet.String},
+	{Name: "timestamp", Type: parquet.TimestampMillis},
+	{Name: "binlog_file", Type: parquet.String},
+	{Name: "binlog_pos", Type: parquet.Int64},
+	{Name: "before_data", Type: parquet.String},
+	{Name: "after_data", Type: parquet.String},
+}
+
+// archiveFile 一个分区正在缓冲的文件
+type archiveFile struct {
+	partition string
+	createdAt time.Time
+	size      int      // 按 JSON 编码估算的大小
+	events    []*Event // 写出前缓冲的事件
+
+	// 轮转后确定的对象 key 和编码结果，重试上传时沿用，不重新编码
+	key  string
+	data []byte
+}
+
+// 上传失败后重试的退避时间
+const (
+	archiveMinBackoff = time.Second
+	archiveMaxBackoff = time.Minute
+)
+
+// ArchiveHandler 对象存储归档处理器
+// 事件按 dt=日期/schema=库/table=表 分区写成 NDJSON 或 Parquet 文件，文件达到大小上限或缓冲时间后轮转，
+// 由后台协程上传，作为数据湖的低成本数据源。上传失败时按退避时间重试，
+// 缓冲（含等待上传的文件）达到上限后 Handle 等待，不会无限占用内存
+type ArchiveHandler struct {
+	name   string
+	cfg    config.ArchiveConfig
+	store  objectstore.Store
+	logger *log.Logger
+
+	maxFileSize   int
+	maxFileAge    time.Duration
+	maxBufferSize int64
+
+	filesMu sync.Mutex
+	files   map[string]*archiveFile // 分区 -> 缓冲文件
+	sealed  []*archiveFile          // 已轮转、等待上传的文件，按轮转顺序
+	seq     int64
+	space   chan struct{} // 上传成功释放缓冲时关闭并替换，唤醒等待的 Handle
+
+	bufferedBytes int64 // 所有缓冲和等待上传的文件大小之和，原子访问
+
+	uploadMu   sync.Mutex    // 同一时间只有一个上传，后台协程和 Flush 共用
+	uploadCh   chan struct{} // 有文件轮转时通知后台协程
+	minBackoff time.Duration
+	backoff    time.Duration // 当前的重试退避时间，只在后台协程中访问
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	// 性能统计
+	mu           sync.RWMutex
+	eventCount   int64
+	uploaded     int64 // 已上传的事件数
+	fileCount    int64
+	byteCount    int64
+	errorCount   int64
+	lastError    string
+	lastErrorAt  time.Time
+	lastUploadAt time.Time
+}
+
+// NewArchiveHandler 创建归档处理器
+func NewArchiveHandler(name string, cfg config.ArchiveConfig, store objectstore.Store, logger *log.Logger) *ArchiveHandler {
+	logger.Printf("🔧 Creating Archive Handler (Name: %s, Format: %s)", name, cfg.Format)
+
+	if cfg.Format == "" {
+		cfg.Format = ArchiveFormatNDJSON
+	}
+	maxFileSize := cfg.MaxFileSize
+	if maxFileSize <= 0 {
+		maxFileSize = 64 << 20
+	}
+	maxFileAge, err := time.ParseDuration(cfg.MaxFileAge)
+	if err != nil || maxFileAge <= 0 {
+		maxFileAge = 5 * time.Minute
+	}
+	// 缓冲上限至少能容纳一个完整的文件
+	maxBufferSize := cfg.MaxBufferSize
+	if maxBufferSize < int64(maxFileSize) {
+		maxBufferSize = 4 * int64(maxFileSize)
+	}
+
+	handler := &ArchiveHandler{
+		name:          name,
+		cfg:           cfg,
+		store:         store,
+		logger:        logger,
+		maxFileSize:   maxFileSize,
+		maxFileAge:    maxFileAge,
+		maxBufferSize: maxBufferSize,
+		files:         make(map[string]*archiveFile),
+		space:         make(chan struct{}),
+		uploadCh:      make(chan struct{}, 1),
+		minBackoff:    archiveMinBackoff,
+		stopCh:        make(chan struct{}),
+	}
+
+	handler.wg.Add(1)
+	go handler.rotateLoop()
+
+	logger.Printf("✅ Archive Handler created successfully (Name: %s)", name)
+	return handler
+}
+
+// ValidateArchiveConfig 校验归档配置
+func ValidateArchiveConfig(cfg config.ArchiveConfig) error {
+	switch cfg.Format {
+	case "", ArchiveFormatNDJSON, ArchiveFormatParquet:
+	default:
+		return fmt.Errorf("unknown archive format %q, expected %s or %s", cfg.Format, ArchiveFormatNDJSON, ArchiveFormatParquet)
+	}
+	if cfg.MaxFileAge != "" {
+		if _, err := time.ParseDuration(cfg.MaxFileAge); err != nil {
+			return fmt.Errorf("invalid archive max_file_age: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetName 获取处理器名称
+func (h *ArchiveHandler) GetName() string {
+	return h.name
+}
+
+// Handle 处理事件，写入所在分区的缓冲文件，文件达到大小上限时轮转，由后台协程上传。
+// 缓冲达到上限时等待上传释放空间，ctx 结束时返回错误
+func (h *ArchiveHandler) Handle(ctx context.Context, event *Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	partition := archivePartition(event)
+
+	h.filesMu.Lock()
+	defer h.filesMu.Unlock()
+
+	for atomic.LoadInt64(&h.bufferedBytes) > 0 && atomic.LoadInt64(&h.bufferedBytes)+int64(len(line)+1) > h.maxBufferSize {
+		space := h.space
+		h.filesMu.Unlock()
+		select {
+		case <-space:
+			h.filesMu.Lock()
+		case <-ctx.Done():
+			h.filesMu.Lock()
+			return fmt.Errorf("archive buffer full (%d bytes waiting for upload): %v", atomic.LoadInt64(&h.bufferedBytes), ctx.Err())
+		}
+	}
+
+	file := h.files[partition]
+	if file == nil {
+		file = &archiveFile{partition: partition, createdAt: time.Now()}
+		h.files[partition] = file
+	}
+	file.events = append(file.events, event)
+	file.size += len(line) + 1
+	atomic.AddInt64(&h.bufferedBytes, int64(len(line)+1))
+
+	h.mu.Lock()
+	h.eventCount++
+	h.mu.Unlock()
+
+	if file.size >= h.maxFileSize {
+		h.logger.Printf("📦 Archive file for %s reached %d bytes, rotating", partition, file.size)
+		h.sealLocked(file)
+		signal(h.uploadCh)
+	}
+	return nil
+}
+
+// Flush 轮转所有缓冲文件并立即上传，不等待重试的退避时间
+func (h *ArchiveHandler) Flush(ctx context.Context) error {
+	h.filesMu.Lock()
+	for _, file := range h.files {
+		h.sealLocked(file)
+	}
+	h.filesMu.Unlock()
+
+	h.uploadMu.Lock()
+	defer h.uploadMu.Unlock()
+	return h.uploadSealed(ctx)
+}
+
+// Close 停止定时轮转并上传剩余的缓冲文件
+func (h *ArchiveHandler) Close() error {
+	h.stopOnce.Do(func() { close(h.stopCh) })
+	h.wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	return h.Flush(ctx)
+}
+
+// rotateLoop 后台上传协程：定时轮转缓冲时间超过上限的文件，上传已轮转的文件，失败后按退避时间重试
+func (h *ArchiveHandler) rotateLoop() {
+	defer h.wg.Done()
+
+	// 检查间隔取缓冲时间的一部分，使文件实际缓冲时间接近上限
+	interval := h.maxFileAge / 10
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-h.stopCh:
+			return
+		case <-ticker.C:
+			h.rotateExpired()
+		case <-h.uploadCh:
+		case <-retry:
+			retry = nil
+		}
+		if retry == nil && !h.uploadPending() {
+			retry = time.After(h.backoff)
+		}
+	}
+}
+
+// rotateExpired 轮转缓冲时间超过上限的文件
+func (h *ArchiveHandler) rotateExpired() {
+	h.filesMu.Lock()
+	defer h.filesMu.Unlock()
+
+	for _, file := range h.files {
+		if time.Since(file.createdAt) >= h.maxFileAge {
+			h.sealLocked(file)
+		}
+	}
+}
+
+// uploadPending 上传已轮转的文件，失败时增加退避时间并返回 false。只在后台协程中调用
+func (h *ArchiveHandler) uploadPending() bool {
+	h.uploadMu.Lock()
+	defer h.uploadMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	if err := h.uploadSealed(ctx); err != nil {
+		if h.backoff < h.minBackoff {
+			h.backoff = h.minBackoff
+		} else if h.backoff *= 2; h.backoff > archiveMaxBackoff {
+			h.backoff = archiveMaxBackoff
+		}
+		h.logger.Printf("⏳ Retrying archive upload in %v", h.backoff)
+		return false
+	}
+	h.backoff = 0
+	return true
+}
+
+// sealLocked 轮转文件：从分区的缓冲中移出，等待上传。调用方需持有 filesMu
+func (h *ArchiveHandler) sealLocked(file *archiveFile) {
+	delete(h.files, file.partition)
+	if len(file.events) == 0 {
+		return
+	}
+	h.seq++
+	key := fmt.Sprintf("%s/%s-%s-%06d.%s", file.partition, h.name,
+		file.createdAt.UTC().Format("20060102T150405Z"), h.seq, h.cfg.Format)
+	if prefix := strings.Trim(h.cfg.Prefix, "/"); prefix != "" {
+		key = prefix + "/" + key
+	}
+	file.key = key
+	h.sealed = append(h.sealed, file)
+}
+
+// uploadSealed 按轮转顺序上传等待上传的文件，遇到失败时停止，文件保留到下次重试。调用方需持有 uploadMu
+func (h *ArchiveHandler) uploadSealed(ctx context.Context) error {
+	for {
+		h.filesMu.Lock()
+		if len(h.sealed) == 0 {
+			h.filesMu.Unlock()
+			return nil
+		}
+		file := h.sealed[0]
+		h.filesMu.Unlock()
+
+		if err := h.upload(ctx, file); err != nil {
+			return err
+		}
+
+		h.filesMu.Lock()
+		h.sealed = h.sealed[1:]
+		atomic.AddInt64(&h.bufferedBytes, -int64(file.size))
+		close(h.space)
+		h.space = make(chan struct{})
+		h.filesMu.Unlock()
+	}
+}
+
+// upload 编码并上传一个已轮转的文件，编码结果保留用于重试
+func (h *ArchiveHandler) upload(ctx context.Context, file *archiveFile) error {
+	if file.data == nil {
+		data, err := h.encode(file.events)
+		if err != nil {
+			h.recordError(err)
+			return err
+		}
+		file.data = data
+	}
+
+	contentType := "application/x-ndjson"
+	if h.cfg.Format == ArchiveFormatParquet {
+		contentType = "application/vnd.apache.parquet"
+	}
+
+	if _, err := h.store.Put(ctx, file.key, file.data, contentType); err != nil {
+		h.logger.Printf("❌ Failed to upload archive file %s (%d events): %v", file.key, len(file.events), err)
+		h.recordError(err)
+		return fmt.Errorf("failed to upload archive file %s: %v", file.key, err)
+	}
+
+	h.logger.Printf("✅ Uploaded archive file %s (%d events, %d bytes)", file.key, len(file.events), len(file.data))
+	h.mu.Lock()
+	h.fileCount++
+	h.uploaded += int64(len(file.events))
+	h.byteCount += int64(len(file.data))
+	h.lastUploadAt = time.Now()
+	h.mu.Unlock()
+	return nil
+}
+
+// BufferedBytes 尚未上传的缓冲文件占用的字节数
+func (h *ArchiveHandler) BufferedBytes() int64 {
+	return atomic.LoadInt64(&h.bufferedBytes)
+}
+
+// encode 按配置的格式编码事件
+func (h *ArchiveHandler) encode(events []*Event) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if h.cfg.Format != ArchiveFormatParquet {
+		encoder := json.NewEncoder(&buf)
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return nil, fmt.Errorf("failed to encode event: %v", err)
+			}
+		}
+		return buf.Bytes(), nil
+	}
+
+	writer := parquet.NewWriter(&buf, archiveColumns)
+	for _, event := range events {
+		before, err := rowDataJSON(event.BeforeData)
+		if err != nil {
+			return nil, err
+		}
+		after, err := rowDataJSON(event.AfterData)
+		if err != nil {
+			return nil, err
+		}
+		row := []interface{}{
+			event.ID, event.Schema, event.Table, string(event.EventType), event.Timestamp,
+			event.Position.Name, int64(event.Position.Pos), before, after,
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, fmt.Errorf("failed to encode event: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rowDataJSON 行镜像编码为 JSON 字符串，nil 表示没有该镜像
+func rowDataJSON(data *RowData) (interface{}, error) {
+	if data == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode row data: %v", err)
+	}
+	return string(encoded), nil
+}
+
+// archivePartition 事件所在分区，按事件时间的 UTC 日期划分
+func archivePartition(event *Event) string {
+	return fmt.Sprintf("dt=%s/schema=%s/table=%s",
+		event.Timestamp.UTC().Format("2006-01-02"), objectKeySegment(event.Schema), objectKeySegment(event.Table))
+}
+
+// recordError 记录最近一次错误
+func (h *ArchiveHandler) recordError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errorCount++
+	h.lastError, h.lastErrorAt = err.Error(), time.Now()
+}
+
+// DeliveryStatus 上传归档文件的结果，已缓冲、尚未上传的事件计为待投递
+func (h *ArchiveHandler) DeliveryStatus() DeliveryStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return DeliveryStatus{
+		Delivered:     h.uploaded,
+		Pending:       h.eventCount - h.uploaded,
+		Failed:        h.errorCount,
+		LastError:     h.lastError,
+		LastErrorAt:   h.lastErrorAt,
+		LastSuccessAt: h.lastUploadAt,
+	}
+}
+
+// GetStats 获取处理器统计信息
+func (h *ArchiveHandler) GetStats() map[string]interface{} {
+	h.filesMu.Lock()
+	buffered, pending := 0, 0
+	for _, file := range h.files {
+		buffered += len(file.events)
+		pending++
+	}
+	sealed := len(h.sealed)
+	for _, file := range h.sealed {
+		buffered += len(file.events)
+	}
+	h.filesMu.Unlock()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return map[string]interface{}{
+		"name":            h.name,
+		"format":          h.cfg.Format,
+		"event_count":     h.eventCount,
+		"file_count":      h.fileCount,
+		"byte_count":      h.byteCount,
+		"error_count":     h.errorCount,
+		"last_error":      h.lastError,
+		"last_upload_at":  h.lastUploadAt,
+		"buffered_events": buffered,
+		"open_files":      pending,
+		"sealed_files":    sealed,
+		"buffered_bytes":  atomic.LoadInt64(&h.bufferedBytes),
+	}
+}
@@ -0,0 +1,165 @@
+package canal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// newArchiveEvent 创建归档测试事件
+func newArchiveEvent(id, table string, ts time.Time) *Event {
+	return &Event{ID: id, Schema: "testdb", Table: table, EventType: EventTypeInsert, Timestamp: ts,
+		Position:  Position{Name: "mysql-bin.000001", Pos: 4},
+		AfterData: &RowData{Columns: []Column{{Name: "id", Type: "int", Value: int64(1)}}}}
+}
+
+// TestArchiveHandlerRotation 测试按分区写文件和按大小轮转
+func TestArchiveHandlerRotation(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 23, 30, 0, 0, time.FixedZone("UTC+8", 8*3600))
+	// 第三个事件写入后达到大小上限
+	line, _ := json.Marshal(newArchiveEvent("xx", "users", ts))
+	store := &fakeObjectStore{objects: make(map[string][]byte)}
+	handler := NewArchiveHandler("archive-1", config.ArchiveConfig{
+		Format: ArchiveFormatNDJSON, Prefix: "/events/", MaxFileSize: 3 * len(line), MaxFileAge: "1h",
+	}, store, log.New(io.Discard, "", 0))
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if err := handler.Handle(ctx, newArchiveEvent(strings.Repeat("x", i+1), "users", ts)); err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+	}
+	handler.Handle(ctx, newArchiveEvent("o1", "orders", ts))
+
+	// users 分区超过大小上限已轮转并在后台上传，orders 分区仍在缓冲
+	waitArchiveFiles(t, store, 1)
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if len(store.objects) != 2 {
+		t.Fatalf("expected 2 files after close, got %d", len(store.objects))
+	}
+
+	var keys []string
+	for key := range store.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	// 分区日期使用 UTC
+	if !strings.HasPrefix(keys[0], "events/dt=2024-05-01/schema=testdb/table=orders/archive-1-") ||
+		!strings.HasPrefix(keys[1], "events/dt=2024-05-01/schema=testdb/table=users/archive-1-") ||
+		!strings.HasSuffix(keys[1], ".ndjson") {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(store.objects[keys[1]]))
+	lines := 0
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid ndjson line %q: %v", scanner.Text(), err)
+		}
+		lines++
+	}
+	if lines != 3 {
+		t.Errorf("expected 3 events in users file, got %d", lines)
+	}
+	if stats := handler.GetStats(); stats["file_count"] != int64(2) || stats["buffered_events"] != 0 {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
+
+// waitArchiveFiles 等待后台上传完成
+func waitArchiveFiles(t *testing.T, store *fakeObjectStore, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for store.count() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d uploaded files, got %d", n, store.count())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestArchiveHandlerBackpressure 测试上传失败时 Handle 不等待上传，缓冲达到上限后才等待，恢复后按退避重试上传
+func TestArchiveHandlerBackpressure(t *testing.T) {
+	ts := time.Now()
+	line, _ := json.Marshal(newArchiveEvent("e0", "users", ts))
+	store := &fakeObjectStore{objects: make(map[string][]byte), err: io.ErrUnexpectedEOF}
+	handler := NewArchiveHandler("archive-3", config.ArchiveConfig{
+		Format: ArchiveFormatNDJSON, MaxFileSize: len(line), MaxBufferSize: int64(3 * (len(line) + 1)), MaxFileAge: "1h",
+	}, store, log.New(io.Discard, "", 0))
+	handler.minBackoff = 20 * time.Millisecond
+	defer handler.Close()
+
+	// 每个事件轮转一个文件，上传失败不阻塞 Handle
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := handler.Handle(ctx, newArchiveEvent(fmt.Sprintf("e%d", i), "users", ts))
+		cancel()
+		if err != nil {
+			t.Fatalf("Handle %d failed: %v", i, err)
+		}
+	}
+
+	// 缓冲已满，Handle 等待到超时
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	err := handler.Handle(ctx, newArchiveEvent("e3", "users", ts))
+	cancel()
+	if err == nil || !strings.Contains(err.Error(), "archive buffer full") {
+		t.Fatalf("expected buffer full error, got %v", err)
+	}
+	if stats := handler.GetStats(); stats["sealed_files"] != 3 || stats["error_count"].(int64) == 0 {
+		t.Errorf("unexpected stats %v", stats)
+	}
+
+	// 对象存储恢复后后台重试上传，释放缓冲
+	store.setErr(nil)
+	waitArchiveFiles(t, store, 3)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := handler.Handle(ctx, newArchiveEvent("e3", "users", ts)); err != nil {
+		t.Fatalf("Handle after recovery failed: %v", err)
+	}
+	waitArchiveFiles(t, store, 4)
+}
+
+// TestArchiveHandlerParquet 测试 Parquet 格式和上传失败后重试
+func TestArchiveHandlerParquet(t *testing.T) {
+	store := &fakeObjectStore{objects: make(map[string][]byte), err: io.ErrUnexpectedEOF}
+	handler := NewArchiveHandler("archive-2", config.ArchiveConfig{Format: ArchiveFormatParquet},
+		store, log.New(io.Discard, "", 0))
+	defer handler.Close()
+
+	handler.Handle(context.Background(), newArchiveEvent("e1", "users", time.Now()))
+	if err := handler.Flush(context.Background()); err == nil {
+		t.Fatalf("expected upload error")
+	}
+	// 失败的事件保留在缓冲区中
+	store.setErr(nil)
+	if err := handler.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	for key, data := range store.objects {
+		if !strings.HasSuffix(key, ".parquet") || string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+			t.Errorf("unexpected parquet object %s", key)
+		}
+	}
+	if len(store.objects) != 1 {
+		t.Errorf("expected 1 file, got %d", len(store.objects))
+	}
+
+	if err := ValidateArchiveConfig(config.ArchiveConfig{Format: "avro"}); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
@@ -0,0 +1,316 @@
+package canal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	"pikachun/internal/config"
+)
+
+// 校验和算法，见 BinlogChecksum.Algorithm
+const (
+	ChecksumAlgorithmAuto  = "auto"  // 按源库的 binlog_checksum，事件带 CRC32 时校验
+	ChecksumAlgorithmCRC32 = "crc32" // 要求源库使用 CRC32，源库关闭校验和时拒绝读取
+)
+
+// 校验和不匹配时的处理方式，见 BinlogChecksum.OnMismatch
+const (
+	ChecksumMismatchFail = "fail" // 停在损坏事件之前，记录错误并按流错误重连
+	ChecksumMismatchSkip = "skip" // 跳过损坏事件继续读取，记录审计
+)
+
+// AlertBinlogChecksum 实例告警：binlog 事件校验和不匹配，或源库未使用要求的校验和算法
+const AlertBinlogChecksum = "binlog_checksum"
+
+// MaxSkippedEvents 保留的跳过记录数
+const MaxSkippedEvents = 50
+
+// BinlogChecksum 源库 binlog 事件校验和的处理设置
+type BinlogChecksum struct {
+	Verify     bool   `json:"verify"`      // 校验每个事件的 CRC32
+	Algorithm  string `json:"algorithm"`   // auto 或 crc32
+	OnMismatch string `json:"on_mismatch"` // fail 或 skip
+}
+
+// DefaultBinlogChecksum 默认按源库的设置校验，不匹配时停止读取
+func DefaultBinlogChecksum() BinlogChecksum {
+	return BinlogChecksum{Verify: true, Algorithm: ChecksumAlgorithmAuto, OnMismatch: ChecksumMismatchFail}
+}
+
+// NewBinlogChecksum 根据 canal.binlog.checksum 创建校验和设置，algorithm 和 on_mismatch 未配置时使用默认值
+func NewBinlogChecksum(cfg config.BinlogChecksumConfig) (BinlogChecksum, error) {
+	checksum := BinlogChecksum{
+		Verify:     cfg.Verify,
+		Algorithm:  strings.ToLower(strings.TrimSpace(cfg.Algorithm)),
+		OnMismatch: strings.ToLower(strings.TrimSpace(cfg.OnMismatch)),
+	}
+	if checksum.Algorithm == "" {
+		checksum.Algorithm = ChecksumAlgorithmAuto
+	}
+	if checksum.OnMismatch == "" {
+		checksum.OnMismatch = ChecksumMismatchFail
+	}
+	if checksum.Algorithm != ChecksumAlgorithmAuto && checksum.Algorithm != ChecksumAlgorithmCRC32 {
+		return BinlogChecksum{}, fmt.Errorf("invalid algorithm %q, expected auto or crc32", cfg.Algorithm)
+	}
+	if checksum.OnMismatch != ChecksumMismatchFail && checksum.OnMismatch != ChecksumMismatchSkip {
+		return BinlogChecksum{}, fmt.Errorf("invalid on_mismatch %q, expected fail or skip", cfg.OnMismatch)
+	}
+	// 不校验时发现不了损坏的事件，skip 不会生效，多半是配置错误
+	if checksum.OnMismatch == ChecksumMismatchSkip && !checksum.Verify {
+		return BinlogChecksum{}, fmt.Errorf("on_mismatch skip requires verify")
+	}
+	return checksum, nil
+}
+
+// withDefaults 零值使用默认设置
+func (c BinlogChecksum) withDefaults() BinlogChecksum {
+	if c.Algorithm == "" {
+		return DefaultBinlogChecksum()
+	}
+	return c
+}
+
+// ChecksumMismatchError 事件的 CRC32 校验失败，Position 为损坏事件的起始位置
+type ChecksumMismatchError struct {
+	Position Position
+	Err      error
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("binlog checksum mismatch at %s:%d: %v", e.Position.Name, e.Position.Pos, e.Err)
+}
+
+func (e *ChecksumMismatchError) Unwrap() error {
+	return e.Err
+}
+
+// IsChecksumMismatchError 判断错误是否由事件校验和不匹配导致
+func IsChecksumMismatchError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var mismatch *ChecksumMismatchError
+	if errors.As(err, &mismatch) || errors.Is(err, replication.ErrChecksumMismatch) {
+		return true
+	}
+	// 上层错误可能只保留了错误文本
+	return strings.Contains(err.Error(), replication.ErrChecksumMismatch.Error())
+}
+
+// SkippedEvent 一个因校验和不匹配被跳过的事件
+type SkippedEvent struct {
+	At        time.Time `json:"at" format:"date-time"`
+	Position  Position  `json:"position"`   // 损坏事件的起始位置
+	EndPos    uint32    `json:"end_pos"`    // 跳过后继续读取的位置
+	EventType string    `json:"event_type"` // 源库 SHOW BINLOG EVENTS 中的事件类型
+	Error     string    `json:"error"`
+}
+
+// ChecksumStatus 校验和设置、源库实际使用的算法和校验失败的审计记录
+type ChecksumStatus struct {
+	BinlogChecksum
+	SourceAlgorithm string         `json:"source_algorithm,omitempty"` // 最近一个 FORMAT_DESCRIPTION 事件声明的算法: crc32 或 none
+	Failing         bool           `json:"failing"`                    // 停在损坏事件之前或源库未使用要求的算法
+	Mismatches      int            `json:"mismatches"`                 // 实例启动以来校验失败的次数
+	LastError       string         `json:"last_error,omitempty"`       // 最近一次校验失败，包含损坏事件的位置
+	LastErrorAt     time.Time      `json:"last_error_at,omitempty" format:"date-time"`
+	SkippedTotal    int            `json:"skipped_total"`
+	Skipped         []SkippedEvent `json:"skipped"` // 最近跳过的事件，按时间先后排列
+}
+
+// checksumState 校验失败的计数和跳过事件的审计记录，受 MySQLBinlogSlave.mu 保护
+type checksumState struct {
+	sourceAlgorithm string
+	failing         bool
+	mismatches      int
+	lastError       string
+	lastErrorAt     time.Time
+	skipped         []SkippedEvent
+	skippedTotal    int
+
+	// 查询损坏事件的结束位置和类型，测试中可替换
+	eventEnd func(pos mysql.Position) (uint32, string, error)
+	// 跳过事件后调用，用于持久化审计记录和告警，在 binlog 读取协程中调用
+	onSkip func(SkippedEvent)
+}
+
+// checksumAlgorithmName FORMAT_DESCRIPTION 事件中的算法编号对应的名称
+func checksumAlgorithmName(alg byte) string {
+	switch alg {
+	case replication.BINLOG_CHECKSUM_ALG_OFF:
+		return "none"
+	case replication.BINLOG_CHECKSUM_ALG_CRC32:
+		return "crc32"
+	default:
+		return ""
+	}
+}
+
+// checkChecksumAlgorithm 记录 FORMAT_DESCRIPTION 事件声明的校验和算法，
+// 要求 CRC32 而源库写入的 binlog 不带校验和时返回错误，停止读取
+func (m *MySQLBinlogSlave) checkChecksumAlgorithm(e *replication.FormatDescriptionEvent) error {
+	algorithm := checksumAlgorithmName(e.ChecksumAlgorithm)
+	if algorithm == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if algorithm != m.checksum.sourceAlgorithm {
+		m.logger.Printf("🔐 Binlog %s checksum algorithm: %s (verify=%v)", m.binlogPos.Name, algorithm, m.config.Checksum.Verify)
+	}
+	m.checksum.sourceAlgorithm = algorithm
+	if m.config.Checksum.Algorithm == ChecksumAlgorithmCRC32 && algorithm != "crc32" {
+		m.checksum.failing = true
+		return fmt.Errorf("binlog %s has no checksum but canal.binlog.checksum.algorithm requires crc32: set binlog_checksum=CRC32 on the source", m.binlogPos.Name)
+	}
+	return nil
+}
+
+// handleChecksumMismatch 处理 binlog 流的校验和错误，返回带损坏事件位置的错误。
+// on_mismatch 为 skip 且查到了损坏事件的结束位置时跳过该事件，返回 true 表示可以立即继续读取
+func (m *MySQLBinlogSlave) handleChecksumMismatch(err error) (bool, error) {
+	m.mu.Lock()
+	pos := m.binlogPos
+	mismatch := &ChecksumMismatchError{Position: Position{Name: pos.Name, Pos: pos.Pos}, Err: err}
+	m.checksum.mismatches++
+	m.checksum.lastError = mismatch.Error()
+	m.checksum.lastErrorAt = time.Now()
+	policy := m.config.Checksum.OnMismatch
+	eventEnd := m.checksum.eventEnd
+	m.mu.Unlock()
+
+	m.logger.Printf("🚨 Corrupt binlog event at %s:%d: %v", pos.Name, pos.Pos, err)
+	if policy != ChecksumMismatchSkip {
+		m.setChecksumFailing()
+		return false, mismatch
+	}
+
+	if eventEnd == nil {
+		eventEnd = m.queryEventEnd
+	}
+	endPos, eventType, lookupErr := eventEnd(pos)
+	if lookupErr != nil {
+		m.logger.Printf("❌ Cannot skip corrupt binlog event at %s:%d: %v", pos.Name, pos.Pos, lookupErr)
+		m.setChecksumFailing()
+		return false, mismatch
+	}
+
+	skipped := SkippedEvent{
+		At:        time.Now(),
+		Position:  mismatch.Position,
+		EndPos:    endPos,
+		EventType: eventType,
+		Error:     err.Error(),
+	}
+	m.mu.Lock()
+	m.checksum.skipped = append(m.checksum.skipped, skipped)
+	if len(m.checksum.skipped) > MaxSkippedEvents {
+		m.checksum.skipped = m.checksum.skipped[len(m.checksum.skipped)-MaxSkippedEvents:]
+	}
+	m.checksum.skippedTotal++
+	onSkip := m.checksum.onSkip
+	m.binlogPos.Pos = endPos
+	m.lastError = fmt.Sprintf("skipped corrupt %s event at %s:%d (next %d): %v", eventType, pos.Name, pos.Pos, endPos, err)
+	m.lastErrorAt = skipped.At
+	if m.syncer != nil {
+		m.syncer.Close()
+	}
+	initErr := m.initBinlogSyncer()
+	m.mu.Unlock()
+
+	m.logger.Printf("🚨 AUDIT: skipped corrupt %s event at %s:%d, continuing from %s:%d (on_mismatch=skip)",
+		eventType, pos.Name, pos.Pos, pos.Name, endPos)
+	if onSkip != nil {
+		onSkip(skipped)
+	}
+	if initErr != nil {
+		return false, fmt.Errorf("failed to reinitialize binlog syncer after skipping %s:%d: %v", pos.Name, pos.Pos, initErr)
+	}
+	return true, nil
+}
+
+// SetChecksumAuditor 设置跳过损坏事件后的回调，用于持久化审计记录和告警，需在启动前设置
+func (m *MySQLBinlogSlave) SetChecksumAuditor(onSkip func(SkippedEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checksum.onSkip = onSkip
+}
+
+// setChecksumFailing 标记实例停在损坏事件之前，读取到后续事件后清除
+func (m *MySQLBinlogSlave) setChecksumFailing() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checksum.failing = true
+}
+
+// queryEventEnd 通过 SHOW BINLOG EVENTS 查询 pos 处事件的结束位置和类型
+func (m *MySQLBinlogSlave) queryEventEnd(pos mysql.Position) (uint32, string, error) {
+	if pos.Name == "" {
+		return 0, "", fmt.Errorf("binlog file of the corrupt event is unknown")
+	}
+	db, err := m.openSourceDB()
+	if err != nil {
+		return 0, "", err
+	}
+	defer db.Close()
+
+	query := fmt.Sprintf("SHOW BINLOG EVENTS IN '%s' FROM %d LIMIT 1", strings.ReplaceAll(pos.Name, "'", "''"), pos.Pos)
+	row, err := queryFirstRow(db, query)
+	if err != nil {
+		return 0, "", err
+	}
+	return parseEventEnd(row, pos.Pos)
+}
+
+// parseEventEnd 从 SHOW BINLOG EVENTS 的一行中取出事件的结束位置和类型，pos 必须是该事件的起始位置
+func parseEventEnd(row map[string]string, pos uint32) (uint32, string, error) {
+	start, err := strconv.ParseUint(row["Pos"], 10, 32)
+	if err != nil || uint32(start) != pos {
+		return 0, "", fmt.Errorf("no binlog event starts at %d: %v", pos, row)
+	}
+	end, err := strconv.ParseUint(row["End_log_pos"], 10, 32)
+	if err != nil || uint32(end) <= pos {
+		return 0, "", fmt.Errorf("invalid end position of the event at %d: %v", pos, row)
+	}
+	return uint32(end), row["Event_type"], nil
+}
+
+// ChecksumStatus 校验和设置和校验失败的审计记录
+func (m *MySQLBinlogSlave) ChecksumStatus() ChecksumStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return ChecksumStatus{
+		BinlogChecksum:  m.config.Checksum,
+		SourceAlgorithm: m.checksum.sourceAlgorithm,
+		Failing:         m.checksum.failing,
+		Mismatches:      m.checksum.mismatches,
+		LastError:       m.checksum.lastError,
+		LastErrorAt:     m.checksum.lastErrorAt,
+		SkippedTotal:    m.checksum.skippedTotal,
+		Skipped:         append([]SkippedEvent{}, m.checksum.skipped...),
+	}
+}
+
+// checkChecksum 将校验和设置的检查追加到 report：要求 CRC32 而源库未使用时无法读取；
+// 开启校验而源库的 binlog 不带校验和时给出警告。查询不到 binlog_checksum（MySQL 5.6 之前）时不检查
+func (s *BinlogSettings) checkChecksum(report *PreflightReport, checksum BinlogChecksum) {
+	if s.Checksum == "" || !s.LogBin {
+		return
+	}
+	crc32 := strings.EqualFold(s.Checksum, "CRC32")
+	if checksum.Algorithm == ChecksumAlgorithmCRC32 {
+		report.add("binlog_checksum", PreflightError, crc32,
+			fmt.Sprintf("binlog_checksum 为 %s，canal.binlog.checksum.algorithm 要求 CRC32，请执行 SET GLOBAL binlog_checksum = 'CRC32'，从新的 binlog 文件开始生效", s.Checksum))
+		return
+	}
+	report.add("binlog_checksum", PreflightWarning, crc32 || !checksum.Verify,
+		fmt.Sprintf("binlog_checksum 为 %s，事件不带校验和，无法发现损坏的事件，建议执行 SET GLOBAL binlog_checksum = 'CRC32'", s.Checksum))
+}
@@ -0,0 +1,215 @@
+package canal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	"pikachun/internal/config"
+)
+
+// TestNewBinlogChecksum 测试默认值和无效配置
+func TestNewBinlogChecksum(t *testing.T) {
+	checksum, err := NewBinlogChecksum(config.BinlogChecksumConfig{Verify: true})
+	if err != nil || checksum != DefaultBinlogChecksum() {
+		t.Fatalf("expected defaults, got %+v, %v", checksum, err)
+	}
+	checksum, err = NewBinlogChecksum(config.BinlogChecksumConfig{Verify: true, Algorithm: " CRC32 ", OnMismatch: "Skip"})
+	if err != nil || checksum.Algorithm != ChecksumAlgorithmCRC32 || checksum.OnMismatch != ChecksumMismatchSkip {
+		t.Fatalf("unexpected checksum settings %+v, %v", checksum, err)
+	}
+
+	for _, cfg := range []config.BinlogChecksumConfig{
+		{Verify: true, Algorithm: "md5"},
+		{Verify: true, OnMismatch: "ignore"},
+		{Verify: false, OnMismatch: "skip"},
+	} {
+		if _, err := NewBinlogChecksum(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+
+	if (BinlogChecksum{}).withDefaults() != DefaultBinlogChecksum() {
+		t.Error("zero value should use the default settings")
+	}
+}
+
+// TestIsChecksumMismatchError 测试识别包装后和只保留文本的校验和错误
+func TestIsChecksumMismatchError(t *testing.T) {
+	tests := map[error]bool{
+		replication.ErrChecksumMismatch:                                                  true,
+		fmt.Errorf("failed to get binlog event: %w", replication.ErrChecksumMismatch):    true,
+		fmt.Errorf("failed to get binlog event: %v", replication.ErrChecksumMismatch):    true,
+		&ChecksumMismatchError{Position: Position{Name: "mysql-bin.000001", Pos: 4}}:     true,
+		errors.New("failed to get binlog event: connection reset"):                       false,
+		fmt.Errorf("ERROR 1236: could not find first log file name in binary log index"): false,
+	}
+	for err, want := range tests {
+		if got := IsChecksumMismatchError(err); got != want {
+			t.Errorf("IsChecksumMismatchError(%v) = %v, want %v", err, got, want)
+		}
+	}
+	if IsChecksumMismatchError(nil) {
+		t.Error("nil is not a checksum error")
+	}
+}
+
+// TestParseEventEnd 测试从 SHOW BINLOG EVENTS 的结果中取出损坏事件的结束位置
+func TestParseEventEnd(t *testing.T) {
+	row := map[string]string{"Log_name": "mysql-bin.000003", "Pos": "1200", "Event_type": "Write_rows", "End_log_pos": "1350"}
+	end, eventType, err := parseEventEnd(row, 1200)
+	if err != nil || end != 1350 || eventType != "Write_rows" {
+		t.Fatalf("unexpected result %d %q %v", end, eventType, err)
+	}
+
+	// 位置不是事件的起始位置
+	if _, _, err := parseEventEnd(row, 1100); err == nil {
+		t.Error("expected error when no event starts at the position")
+	}
+	row["End_log_pos"] = "1200"
+	if _, _, err := parseEventEnd(row, 1200); err == nil {
+		t.Error("expected error for an end position not after the start")
+	}
+}
+
+// TestHandleChecksumMismatch 测试 fail 停在损坏事件前并告警，skip 跳到事件结束位置并记录审计
+func TestHandleChecksumMismatch(t *testing.T) {
+	newSlave := func(onMismatch string) *MySQLBinlogSlave {
+		logger := log.New(io.Discard, "", 0)
+		checksum := BinlogChecksum{Verify: true, Algorithm: ChecksumAlgorithmAuto, OnMismatch: onMismatch}
+		slave, err := NewMySQLBinlogSlave(MySQLConfig{Host: "127.0.0.1", Port: 1, ServerID: 1001, Checksum: checksum}, NewDefaultEventSink(logger), logger)
+		if err != nil {
+			t.Fatalf("Failed to create binlog slave: %v", err)
+		}
+		slave.binlogPos = mysql.Position{Name: "mysql-bin.000003", Pos: 1200}
+		return slave
+	}
+	streamErr := fmt.Errorf("failed to get binlog event: %w", replication.ErrChecksumMismatch)
+
+	t.Run("fail", func(t *testing.T) {
+		slave := newSlave(ChecksumMismatchFail)
+		skipped, err := slave.handleChecksumMismatch(streamErr)
+		var mismatch *ChecksumMismatchError
+		if skipped || !errors.As(err, &mismatch) || mismatch.Position != (Position{Name: "mysql-bin.000003", Pos: 1200}) {
+			t.Fatalf("expected structured mismatch error, got %v, %v", skipped, err)
+		}
+		if !strings.Contains(err.Error(), "mysql-bin.000003:1200") {
+			t.Errorf("error should contain the event position: %v", err)
+		}
+		if slave.binlogPos.Pos != 1200 {
+			t.Errorf("fail policy should not move the position, got %d", slave.binlogPos.Pos)
+		}
+		status := slave.ChecksumStatus()
+		if !status.Failing || status.Mismatches != 1 || len(status.Skipped) != 0 {
+			t.Errorf("unexpected status %+v", status)
+		}
+		if failing, _ := slave.GetStats()["checksum_failing"].(bool); !failing {
+			t.Error("stats should report the checksum alert")
+		}
+
+		// 越过损坏事件后告警解除，伪造的事件不算
+		slave.updatePosition(&replication.BinlogEvent{Header: &replication.EventHeader{EventType: replication.ROTATE_EVENT}})
+		if !slave.ChecksumStatus().Failing {
+			t.Error("fake events should not clear the alert")
+		}
+		slave.updatePosition(&replication.BinlogEvent{Header: &replication.EventHeader{EventType: replication.XID_EVENT, LogPos: 1300}})
+		if slave.ChecksumStatus().Failing {
+			t.Error("alert should clear after reading past the corrupt event")
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		slave := newSlave(ChecksumMismatchSkip)
+		slave.checksum.eventEnd = func(pos mysql.Position) (uint32, string, error) {
+			return pos.Pos + 150, "Write_rows", nil
+		}
+		var audited []SkippedEvent
+		slave.SetChecksumAuditor(func(event SkippedEvent) { audited = append(audited, event) })
+		skipped, err := slave.handleChecksumMismatch(streamErr)
+		if !skipped || err != nil {
+			t.Fatalf("expected the event to be skipped, got %v, %v", skipped, err)
+		}
+		if len(audited) != 1 || audited[0].Position.Pos != 1200 || audited[0].EndPos != 1350 {
+			t.Errorf("expected the skipped event passed to the auditor, got %+v", audited)
+		}
+		if slave.binlogPos.Pos != 1350 {
+			t.Errorf("expected position 1350 after skipping, got %d", slave.binlogPos.Pos)
+		}
+		status := slave.ChecksumStatus()
+		if status.Failing || status.SkippedTotal != 1 || len(status.Skipped) != 1 {
+			t.Fatalf("unexpected status %+v", status)
+		}
+		if audit := status.Skipped[0]; audit.Position.Pos != 1200 || audit.EndPos != 1350 || audit.EventType != "Write_rows" {
+			t.Errorf("unexpected audit record %+v", audit)
+		}
+	})
+
+	t.Run("skip lookup failed", func(t *testing.T) {
+		slave := newSlave(ChecksumMismatchSkip)
+		slave.checksum.eventEnd = func(pos mysql.Position) (uint32, string, error) {
+			return 0, "", errors.New("connection refused")
+		}
+		skipped, err := slave.handleChecksumMismatch(streamErr)
+		if skipped || !IsChecksumMismatchError(err) || slave.binlogPos.Pos != 1200 || !slave.ChecksumStatus().Failing {
+			t.Fatalf("expected to stay before the corrupt event, got %v, %v", skipped, err)
+		}
+	})
+}
+
+// TestCheckChecksumAlgorithm 测试要求 CRC32 时拒绝不带校验和的 binlog
+func TestCheckChecksumAlgorithm(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	checksum := BinlogChecksum{Verify: true, Algorithm: ChecksumAlgorithmCRC32, OnMismatch: ChecksumMismatchFail}
+	slave, err := NewMySQLBinlogSlave(MySQLConfig{Host: "127.0.0.1", Port: 1, ServerID: 1001, Checksum: checksum}, NewDefaultEventSink(logger), logger)
+	if err != nil {
+		t.Fatalf("Failed to create binlog slave: %v", err)
+	}
+
+	if err := slave.checkChecksumAlgorithm(&replication.FormatDescriptionEvent{ChecksumAlgorithm: replication.BINLOG_CHECKSUM_ALG_CRC32}); err != nil {
+		t.Fatalf("crc32 binlog should be accepted: %v", err)
+	}
+	if err := slave.checkChecksumAlgorithm(&replication.FormatDescriptionEvent{ChecksumAlgorithm: replication.BINLOG_CHECKSUM_ALG_OFF}); err == nil {
+		t.Fatal("binlog without checksum should be rejected")
+	}
+	if status := slave.ChecksumStatus(); status.SourceAlgorithm != "none" || !status.Failing {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+// TestBinlogSettingsCheckChecksum 测试 binlog_checksum 的检查级别
+func TestBinlogSettingsCheckChecksum(t *testing.T) {
+	crc32 := BinlogChecksum{Verify: true, Algorithm: ChecksumAlgorithmCRC32, OnMismatch: ChecksumMismatchFail}
+	noVerify := BinlogChecksum{Algorithm: ChecksumAlgorithmAuto, OnMismatch: ChecksumMismatchFail}
+	tests := []struct {
+		name     string
+		checksum string
+		settings BinlogChecksum
+		failed   []string
+		passed   bool
+	}{
+		{"crc32", "CRC32", DefaultBinlogChecksum(), nil, true},
+		{"unknown", "", crc32, nil, true},
+		{"none with verify", "NONE", DefaultBinlogChecksum(), []string{"binlog_checksum"}, true},
+		{"none without verify", "NONE", noVerify, nil, true},
+		{"crc32 required", "NONE", crc32, []string{"binlog_checksum"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := BinlogSettings{LogBin: true, Format: "ROW", RowImage: "FULL", Checksum: tt.checksum}
+			report := &PreflightReport{Passed: true}
+			settings.checkChecksum(report, tt.settings)
+			if got := failedChecks(report); strings.Join(got, ",") != strings.Join(tt.failed, ",") {
+				t.Errorf("expected failed checks %v, got %v", tt.failed, got)
+			}
+			if report.Passed != tt.passed {
+				t.Errorf("expected passed=%v, got %v", tt.passed, report.Passed)
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package canal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/shopspring/decimal"
+)
+
+// RecoveryAction binlog 被清除后的恢复方式
+type RecoveryAction string
+
+const (
+	RecoverFromEarliest RecoveryAction = "earliest" // 跳到最早可用的 binlog 位置
+	RecoverFromLatest   RecoveryAction = "latest"   // 跳到最新的 binlog 位置
+	RecoverWithSnapshot RecoveryAction = "snapshot" // 全量快照后从最新位置继续
+)
+
+// AlertBinlogPurged 实例告警：保存的 binlog 文件已被清除
+const AlertBinlogPurged = "binlog_purged"
+
+// IsBinlogPurgedError 判断错误是否由 binlog 文件被清除导致
+// MySQL 返回 ERROR 1236 (ER_MASTER_FATAL_ERROR_READING_BINLOG)
+func IsBinlogPurgedError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var myErr *mysql.MyError
+	if errors.As(err, &myErr) && myErr.Code == mysql.ER_MASTER_FATAL_ERROR_READING_BINLOG {
+		return true
+	}
+
+	// 上层错误可能只保留了错误文本
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "error 1236") ||
+		strings.Contains(msg, "could not find first log file name") ||
+		strings.Contains(msg, "purged binary logs")
+}
+
+// markPurged 标记 binlog 已被清除，等待人工恢复
+func (m *MySQLBinlogSlave) markPurged(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.purged {
+		m.purgedAt = time.Now()
+	}
+	m.purged = true
+	m.purgeError = err.Error()
+	m.logger.Printf("🚨 Binlog %s:%d is no longer available, waiting for recovery: %v",
+		m.binlogPos.Name, m.binlogPos.Pos, err)
+}
+
+// 恢复的状态
+const (
+	RecoveryRunning   = "running"
+	RecoveryCompleted = "completed"
+	RecoveryFailed    = "failed"
+)
+
+// RecoveryProgress 最近一次恢复的进度，snapshot 方式的快照在后台进行
+type RecoveryProgress struct {
+	Action     RecoveryAction `json:"action"`
+	State      string         `json:"state"` // running、completed 或 failed
+	Position   Position       `json:"position"`
+	Table      string         `json:"table,omitempty"` // 正在快照的表
+	Tables     int            `json:"tables"`          // 需要快照的表数
+	TablesDone int            `json:"tables_done"`
+	Rows       int64          `json:"rows"` // 已发送的快照行数
+	Error      string         `json:"error,omitempty"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+}
+
+// Recover 从 binlog 被清除的状态中恢复。earliest 和 latest 直接切换位置；snapshot 先确定最新位置，
+// 在后台快照监听的表后从该位置继续读取，进度见 GetStats 的 recovery。快照失败时仍处于告警状态，可以重新恢复
+func (m *MySQLBinlogSlave) Recover(action RecoveryAction) error {
+	switch action {
+	case RecoverFromEarliest, RecoverFromLatest, RecoverWithSnapshot:
+	default:
+		return fmt.Errorf("unknown recovery action: %s", action)
+	}
+
+	m.mu.Lock()
+	if !m.purged {
+		m.mu.Unlock()
+		return fmt.Errorf("binlog slave is not waiting for recovery")
+	}
+	if m.recovery != nil && m.recovery.State == RecoveryRunning {
+		m.mu.Unlock()
+		return fmt.Errorf("recovery with action %s is already running", m.recovery.Action)
+	}
+	progress := &RecoveryProgress{Action: action, State: RecoveryRunning, StartedAt: time.Now()}
+	m.recovery = progress
+	ctx := m.ctx
+	m.mu.Unlock()
+
+	m.logger.Printf("🔧 Recovering binlog slave with action: %s", action)
+
+	var pos mysql.Position
+	var err error
+	if action == RecoverFromEarliest {
+		pos, err = m.queryEarliestPosition()
+	} else {
+		pos, err = m.queryLatestPosition()
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to resolve recovery position: %v", err)
+		m.finishRecovery(progress, err)
+		return err
+	}
+	m.mu.Lock()
+	progress.Position = Position{Name: pos.Name, Pos: pos.Pos}
+	m.mu.Unlock()
+
+	if action != RecoverWithSnapshot {
+		err := m.resumeFrom(pos)
+		m.finishRecovery(progress, err)
+		return err
+	}
+
+	// 先确定位置再做快照，快照期间的变更会在之后的 binlog 中重放
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go func() {
+		err := m.snapshotWatchedTables(ctx, pos, progress)
+		if err != nil {
+			err = fmt.Errorf("failed to snapshot watched tables: %v", err)
+		} else {
+			err = m.resumeFrom(pos)
+		}
+		m.finishRecovery(progress, err)
+	}()
+	return nil
+}
+
+// finishRecovery 记录恢复的结果
+func (m *MySQLBinlogSlave) finishRecovery(progress *RecoveryProgress, err error) {
+	now := time.Now()
+	m.mu.Lock()
+	progress.Table = ""
+	progress.FinishedAt = &now
+	progress.State = RecoveryCompleted
+	if err != nil {
+		progress.State = RecoveryFailed
+		progress.Error = err.Error()
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		m.logger.Printf("❌ Recovery with action %s failed: %v", progress.Action, err)
+	}
+}
+
+// resumeFrom 切换到 pos 并保存，唤醒等待恢复的 binlog 流
+func (m *MySQLBinlogSlave) resumeFrom(pos mysql.Position) error {
+	m.mu.Lock()
+	m.binlogPos = pos
+	m.boundaries = nil
+	// 跳过或重放了部分 binlog，原有 GTID 集合不再对应新位置，从新位置重新累计
+	m.restoreGTIDSet(Position{})
+	m.purged = false
+	m.purgeError = ""
+	m.purgedAt = time.Time{}
+	if m.syncer != nil {
+		m.syncer.Close()
+	}
+	m.mu.Unlock()
+	m.eventSink.resetAck()
+
+	if err := m.initBinlogSyncer(); err != nil {
+		return fmt.Errorf("failed to reinitialize binlog syncer: %v", err)
+	}
+
+	if m.metaManager != nil {
+		if err := m.metaManager.SavePosition(m.instanceID, Position{Name: pos.Name, Pos: pos.Pos}); err != nil {
+			m.logger.Printf("❌ Failed to save recovered binlog position: %v", err)
+		}
+	}
+
+	// 唤醒等待中的 binlog 流
+	select {
+	case m.recoverCh <- struct{}{}:
+	default:
+	}
+
+	m.logger.Printf("✅ Binlog slave recovered to %s:%d", pos.Name, pos.Pos)
+	return nil
+}
+
+// openSourceDB 打开到源库的普通连接
+func (m *MySQLBinlogSlave) openSourceDB() (*sql.DB, error) {
+	dsn := SourceDSN(m.config.Username, m.config.Password, m.config.Host, m.config.Port,
+		fmt.Sprintf("charset=utf8mb4&timeout=%s", m.config.Connection.withDefaults().DialTimeout))
+	return sql.Open("mysql", dsn)
+}
+
+// queryEarliestPosition 查询最早可用的 binlog 位置
+func (m *MySQLBinlogSlave) queryEarliestPosition() (mysql.Position, error) {
+	db, err := m.openSourceDB()
+	if err != nil {
+		return mysql.Position{}, err
+	}
+	defer db.Close()
+
+	row, err := queryFirstRow(db, "SHOW BINARY LOGS")
+	if err != nil {
+		return mysql.Position{}, err
+	}
+
+	name := row["Log_name"]
+	if name == "" {
+		return mysql.Position{}, fmt.Errorf("no binary logs available")
+	}
+	return mysql.Position{Name: name, Pos: 4}, nil
+}
+
+// queryLatestPosition 查询当前最新的 binlog 位置
+func (m *MySQLBinlogSlave) queryLatestPosition() (mysql.Position, error) {
+	db, err := m.openSourceDB()
+	if err != nil {
+		return mysql.Position{}, err
+	}
+	defer db.Close()
+
+	row, err := queryMasterStatus(db)
+	if err != nil {
+		return mysql.Position{}, err
+	}
+
+	pos, err := strconv.ParseUint(row["Position"], 10, 32)
+	if err != nil || row["File"] == "" {
+		return mysql.Position{}, fmt.Errorf("invalid master status: %v", row)
+	}
+	return mysql.Position{Name: row["File"], Pos: uint32(pos)}, nil
+}
+
+// queryFirstRow 执行查询并以 列名 -> 值 的形式返回第一行
+func queryFirstRow(db *sql.DB, query string) (map[string]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s returned no rows", query)
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(columns))
+	for i, col := range columns {
+		result[col] = values[i].String
+	}
+	return result, nil
+}
+
+// snapshotWatchedTables 对监听的表做全量快照，以 INSERT 事件发送，进度记录在 progress 中
+func (m *MySQLBinlogSlave) snapshotWatchedTables(ctx context.Context, pos mysql.Position, progress *RecoveryProgress) error {
+	m.mu.Lock()
+	tables := make([]string, 0, len(m.watchTables))
+	for key := range m.watchTables {
+		tables = append(tables, key)
+	}
+	sort.Strings(tables)
+	progress.Tables = len(tables)
+	m.mu.Unlock()
+
+	if len(tables) == 0 {
+		m.logger.Printf("⚠️ No watched tables configured, skipping snapshot")
+		return nil
+	}
+
+	db, err := m.openSourceDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, key := range tables {
+		parts := strings.SplitN(key, ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		m.mu.Lock()
+		progress.Table = key
+		m.mu.Unlock()
+		count, err := m.snapshotTable(ctx, db, parts[0], parts[1], pos, progress)
+		if err != nil {
+			return fmt.Errorf("snapshot %s: %v", key, err)
+		}
+		m.mu.Lock()
+		progress.TablesDone++
+		m.mu.Unlock()
+		m.logger.Printf("📸 Snapshot of %s completed: %d rows", key, count)
+	}
+	return nil
+}
+
+// snapshotTable 快照单张表，列值按列类型转换，与 binlog 事件中的类型一致
+func (m *MySQLBinlogSlave) snapshotTable(ctx context.Context, db *sql.DB, schema, table string, pos mysql.Position, progress *RecoveryProgress) (int, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s.%s", quoteIdentifier(schema), quoteIdentifier(table)))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for rows.Next() {
+		values := make([]interface{}, len(columnTypes))
+		dest := make([]interface{}, len(columnTypes))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return count, err
+		}
+
+		data := &RowData{Columns: make([]Column, len(columnTypes))}
+		for i, columnType := range columnTypes {
+			value := snapshotValue(values[i], columnType.DatabaseTypeName())
+			data.Columns[i] = Column{Name: columnType.Name(), Value: value, IsNull: value == nil}
+		}
+
+		event := &Event{
+			ID: m.idGenerator.NextID(EventKey{
+				Source:   fmt.Sprintf("snapshot:%s.%s", schema, table),
+				Position: Position{Name: pos.Name, Pos: pos.Pos},
+				Row:      count,
+			}),
+			Schema:    schema,
+			Table:     table,
+			EventType: EventTypeInsert,
+			Timestamp: time.Now(),
+			Position:  Position{Name: pos.Name, Pos: pos.Pos},
+			AfterData: data,
+		}
+		if err := m.eventSink.SendEvent(event); err != nil {
+			return count, err
+		}
+		count++
+		m.mu.Lock()
+		progress.Rows++
+		m.mu.Unlock()
+	}
+
+	return count, rows.Err()
+}
+
+// snapshotValue 快照查询以文本返回列值，按列类型还原：整数为 int64（无符号为 uint64）、
+// 浮点数为 float64、定点小数为 decimal.Decimal，与 binlog 事件中的数值类型一致，其他类型为字符串
+func snapshotValue(value interface{}, typeName string) interface{} {
+	b, ok := value.([]byte)
+	if !ok {
+		return value
+	}
+	text := string(b)
+	switch typeName {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+	case "UNSIGNED TINYINT", "UNSIGNED SMALLINT", "UNSIGNED INT", "UNSIGNED BIGINT":
+		if n, err := strconv.ParseUint(text, 10, 64); err == nil {
+			return n
+		}
+	case "FLOAT", "DOUBLE":
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	case "DECIMAL":
+		if d, err := decimal.NewFromString(text); err == nil {
+			return d
+		}
+	}
+	return text
+}
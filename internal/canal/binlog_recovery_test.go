@@ -0,0 +1,82 @@
+package canal
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/shopspring/decimal"
+)
+
+// TestIsBinlogPurgedError 测试 binlog 被清除错误的识别
+func TestIsBinlogPurgedError(t *testing.T) {
+	myErr := &mysql.MyError{
+		Code:    mysql.ER_MASTER_FATAL_ERROR_READING_BINLOG,
+		Message: "Could not find first log file name in binary log index file",
+	}
+
+	cases := []struct {
+		err    error
+		purged bool
+	}{
+		{nil, false},
+		{myErr, true},
+		{fmt.Errorf("failed to get binlog event: %w", myErr), true},
+		{fmt.Errorf("failed to get binlog event: %v", myErr), true},
+		{errors.New("connection refused"), false},
+	}
+
+	for _, c := range cases {
+		if got := IsBinlogPurgedError(c.err); got != c.purged {
+			t.Errorf("IsBinlogPurgedError(%v) = %v, want %v", c.err, got, c.purged)
+		}
+	}
+}
+
+// TestRecoverRequiresPurgedState 测试未处于告警状态时拒绝恢复
+func TestRecoverRequiresPurgedState(t *testing.T) {
+	slave := &MySQLBinlogSlave{}
+	if err := slave.Recover(RecoverFromLatest); err == nil {
+		t.Errorf("Expected error when slave is not waiting for recovery")
+	}
+}
+
+// TestRecoverRejectsConcurrentRecovery 测试恢复进行中时拒绝再次恢复
+func TestRecoverRejectsConcurrentRecovery(t *testing.T) {
+	slave := &MySQLBinlogSlave{purged: true, recovery: &RecoveryProgress{Action: RecoverWithSnapshot, State: RecoveryRunning}}
+	if err := slave.Recover(RecoverFromLatest); err == nil {
+		t.Errorf("Expected error while a snapshot recovery is running")
+	}
+	if err := slave.Recover("unknown"); err == nil {
+		t.Errorf("Expected error for unknown recovery action")
+	}
+}
+
+// TestSnapshotValue 测试快照的文本列值按列类型还原为数值
+func TestSnapshotValue(t *testing.T) {
+	cases := []struct {
+		value    interface{}
+		typeName string
+		want     interface{}
+	}{
+		{[]byte("42"), "INT", int64(42)},
+		{[]byte("-7"), "BIGINT", int64(-7)},
+		{[]byte("18446744073709551615"), "UNSIGNED BIGINT", uint64(18446744073709551615)},
+		{[]byte("2024"), "YEAR", int64(2024)},
+		{[]byte("1.5"), "DOUBLE", 1.5},
+		{[]byte("abc"), "VARCHAR", "abc"},
+		{[]byte("2024-06-01 00:00:00"), "DATETIME", "2024-06-01 00:00:00"},
+		{nil, "INT", nil},
+	}
+	for _, c := range cases {
+		if got := snapshotValue(c.value, c.typeName); got != c.want {
+			t.Errorf("snapshotValue(%s, %s) = %#v, want %#v", c.value, c.typeName, got, c.want)
+		}
+	}
+
+	d, ok := snapshotValue([]byte("9.90"), "DECIMAL").(decimal.Decimal)
+	if !ok || d.String() != "9.9" {
+		t.Errorf("expected decimal 9.9, got %#v", d)
+	}
+}
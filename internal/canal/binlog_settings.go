@@ -0,0 +1,132 @@
+package canal
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AlertBinlogSettings 实例告警：源库 binlog 配置不满足要求，未开始读取
+const AlertBinlogSettings = "binlog_settings"
+
+// BinlogSettings 源库的 binlog 配置
+type BinlogSettings struct {
+	LogBin    bool   `json:"log_bin"`
+	Format    string `json:"binlog_format"`
+	RowImage  string `json:"binlog_row_image"`
+	Retention int64  `json:"retention_seconds"` // 自动清除 binlog 的期限，0 表示不自动清除
+	Checksum  string `json:"binlog_checksum"`   // 事件校验和算法，CRC32 或 NONE，查询失败时为空
+}
+
+// QueryBinlogSettings 查询源库的 binlog 配置
+func QueryBinlogSettings(db *sql.DB) (*BinlogSettings, error) {
+	var logBin int
+	settings := &BinlogSettings{}
+	row := db.QueryRow("SELECT @@GLOBAL.log_bin, @@GLOBAL.binlog_format, @@GLOBAL.binlog_row_image")
+	if err := row.Scan(&logBin, &settings.Format, &settings.RowImage); err != nil {
+		return nil, fmt.Errorf("failed to query binlog variables: %v", err)
+	}
+	settings.LogBin = logBin == 1
+
+	// MySQL 8.0 和 MariaDB 10.6 起为 binlog_expire_logs_seconds，为 0 时旧的 expire_logs_days 仍然生效
+	var seconds, days sql.NullFloat64
+	db.QueryRow("SELECT @@GLOBAL.binlog_expire_logs_seconds").Scan(&seconds)
+	db.QueryRow("SELECT @@GLOBAL.expire_logs_days").Scan(&days)
+	settings.Retention = int64(seconds.Float64)
+	if settings.Retention == 0 {
+		settings.Retention = int64(days.Float64 * 86400)
+	}
+
+	// MySQL 5.6.2 起才有 binlog_checksum
+	var checksum sql.NullString
+	db.QueryRow("SELECT @@GLOBAL.binlog_checksum").Scan(&checksum)
+	settings.Checksum = checksum.String
+	return settings, nil
+}
+
+// check 将 binlog 配置的检查追加到 report：未开启 binlog 或不是 ROW 格式时无法读取行变更；
+// 行镜像不完整或保留时间短于 minRetention 时可以运行，但可能丢失信息
+func (s *BinlogSettings) check(report *PreflightReport, minRetention time.Duration) {
+	report.add("log_bin", PreflightError, s.LogBin,
+		"源库未开启 binlog，请在 MySQL 配置中设置 log_bin 和 server_id 后重启")
+	report.add("binlog_format", PreflightError, !s.LogBin || strings.EqualFold(s.Format, "ROW"),
+		fmt.Sprintf("binlog_format 为 %s，只有 ROW 格式记录行数据，请执行 SET GLOBAL binlog_format = 'ROW' 并写入配置文件，已连接的会话需重连后生效", s.Format))
+	report.add("binlog_row_image", PreflightWarning, strings.EqualFold(s.RowImage, "FULL"),
+		fmt.Sprintf("binlog_row_image 为 %s，UPDATE/DELETE 事件中缺少未修改的列，建议执行 SET GLOBAL binlog_row_image = 'FULL'", s.RowImage))
+
+	retention := time.Duration(s.Retention) * time.Second
+	report.add("binlog_retention", PreflightWarning, s.Retention == 0 || retention >= minRetention,
+		fmt.Sprintf("binlog 只保留 %v，短于 canal.min_binlog_retention (%v)，服务停止较久后 binlog 可能已被清除，建议调大 binlog_expire_logs_seconds", retention, minRetention))
+}
+
+// BinlogSettingsError 源库 binlog 配置不满足要求，Report 中有未通过的检查和处理方法
+type BinlogSettingsError struct {
+	Settings *BinlogSettings
+	Report   *PreflightReport
+}
+
+func (e *BinlogSettingsError) Error() string {
+	return "binlog settings check failed: " + e.Report.Err().Error()
+}
+
+// checkBinlogSettings 启动时检查源库的 binlog 配置，不满足要求时返回 *BinlogSettingsError，查询失败时只记录日志
+func (m *MySQLBinlogSlave) checkBinlogSettings() error {
+	db, err := m.openSourceDB()
+	if err != nil {
+		return nil
+	}
+	defer db.Close()
+
+	settings, err := QueryBinlogSettings(db)
+	if err != nil {
+		m.logger.Printf("⚠️ Failed to check binlog settings of the source: %v", err)
+		return nil
+	}
+
+	report := &PreflightReport{Passed: true}
+	settings.check(report, m.config.MinBinlogRetention)
+	settings.checkChecksum(report, m.config.Checksum)
+	for _, check := range report.Checks {
+		if !check.Passed && check.Level == PreflightWarning {
+			m.logger.Printf("⚠️ %s", check.Message)
+		}
+	}
+	if !report.Passed {
+		return &BinlogSettingsError{Settings: settings, Report: report}
+	}
+	return nil
+}
+
+// reportStatementBinlog 首次收到按语句记录的数据修改时告警，这些修改没有行事件，不会投递
+func (m *MySQLBinlogSlave) reportStatementBinlog(schema string) {
+	m.mu.Lock()
+	reported := m.statementBinlog
+	m.statementBinlog = true
+	m.mu.Unlock()
+	if reported {
+		return
+	}
+
+	m.logger.Printf("🚨 Statement-based binlog event detected in %s, row changes are not captured; set binlog_format=ROW on the source", schema)
+	m.recordError(fmt.Errorf("statement-based binlog detected in %s, row changes are not captured: set binlog_format=ROW on the source", schema))
+}
+
+// isStatementDML 判断 QUERY 事件是否为按语句记录的数据修改，ROW 格式下行变更不会出现在 QUERY 事件中
+func isStatementDML(query string) bool {
+	query = strings.TrimSpace(query)
+	// 跳过语句前的注释，如 /* app */ UPDATE ...
+	for strings.HasPrefix(query, "/*") {
+		end := strings.Index(query, "*/")
+		if end < 0 {
+			return false
+		}
+		query = strings.TrimSpace(query[end+2:])
+	}
+	verb, _, _ := strings.Cut(query, " ")
+	switch strings.ToUpper(verb) {
+	case "INSERT", "UPDATE", "DELETE", "REPLACE":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package canal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBinlogSettingsCheck 测试 binlog 配置检查的级别和处理方法
+func TestBinlogSettingsCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings BinlogSettings
+		failed   []string
+		passed   bool
+	}{
+		{"row format", BinlogSettings{LogBin: true, Format: "ROW", RowImage: "FULL", Retention: 7 * 86400}, nil, true},
+		{"no auto purge", BinlogSettings{LogBin: true, Format: "ROW", RowImage: "FULL"}, nil, true},
+		// 未开启 binlog 时只报 log_bin，不重复报格式
+		{"binlog disabled", BinlogSettings{Format: "STATEMENT", RowImage: "FULL"}, []string{"log_bin"}, false},
+		{"statement format", BinlogSettings{LogBin: true, Format: "STATEMENT", RowImage: "FULL"}, []string{"binlog_format"}, false},
+		{"short retention", BinlogSettings{LogBin: true, Format: "ROW", RowImage: "FULL", Retention: 3600}, []string{"binlog_retention"}, true},
+		{"minimal row image", BinlogSettings{LogBin: true, Format: "ROW", RowImage: "MINIMAL"}, []string{"binlog_row_image"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &PreflightReport{Passed: true}
+			tt.settings.check(report, 24*time.Hour)
+			if got := failedChecks(report); strings.Join(got, ",") != strings.Join(tt.failed, ",") {
+				t.Errorf("expected failed checks %v, got %v", tt.failed, got)
+			}
+			if report.Passed != tt.passed {
+				t.Errorf("expected passed=%v, got %v", tt.passed, report.Passed)
+			}
+		})
+	}
+}
+
+// TestBinlogSettingsError 测试错误信息包含处理方法且可以用 errors.As 识别
+func TestBinlogSettingsError(t *testing.T) {
+	settings := &BinlogSettings{LogBin: true, Format: "STATEMENT", RowImage: "FULL"}
+	report := &PreflightReport{Passed: true}
+	settings.check(report, 0)
+
+	var err error = &BinlogSettingsError{Settings: settings, Report: report}
+	if !strings.Contains(err.Error(), "SET GLOBAL binlog_format = 'ROW'") {
+		t.Errorf("error should explain how to fix the format: %v", err)
+	}
+	var settingsErr *BinlogSettingsError
+	if !errors.As(err, &settingsErr) || settingsErr.Settings.Format != "STATEMENT" {
+		t.Error("errors.As should find the settings error")
+	}
+}
+
+// TestIsStatementDML 测试识别按语句记录的数据修改
+func TestIsStatementDML(t *testing.T) {
+	tests := map[string]bool{
+		"BEGIN":  false,
+		"COMMIT": false,
+		"ALTER TABLE orders ADD COLUMN note TEXT": false,
+		"update orders set status = 1":            true,
+		"INSERT INTO orders VALUES (1)":           true,
+		"/* app:checkout */ DELETE FROM carts":    true,
+		"REPLACE INTO kv VALUES ('a', 1)":         true,
+		"/* unterminated":                         false,
+	}
+	for query, want := range tests {
+		if got := isStatementDML(query); got != want {
+			t.Errorf("isStatementDML(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package canal
+
+import (
+	"log"
+	"strings"
+)
+
+// 持久化位置的保存时机
+const (
+	CheckpointEvent       = "event"       // 每个事件之后保存已投递完成的位置，可能位于事务中间
+	CheckpointTransaction = "transaction" // 只在事务提交（XID、DDL）之后保存，重启总是从事务边界开始
+)
+
+// maxCheckpointBoundaries 等待投递完成的事务边界数上限，超出时丢弃最早的
+const maxCheckpointBoundaries = 1024
+
+// parseCheckpointMode 解析位置保存时机，未配置或无效时为 event
+func parseCheckpointMode(value string, logger *log.Logger) string {
+	switch mode := strings.ToLower(strings.TrimSpace(value)); mode {
+	case "", CheckpointEvent:
+		return CheckpointEvent
+	case CheckpointTransaction:
+		return mode
+	default:
+		logger.Printf("⚠️ Invalid checkpoint mode %q, using %s", value, CheckpointEvent)
+		return CheckpointEvent
+	}
+}
+
+// noteBoundaryLocked 读取到事务提交或切换文件后记录当前位置为事务边界，调用方需持有锁
+func (m *MySQLBinlogSlave) noteBoundaryLocked(read Position) {
+	if m.config.Checkpoint != CheckpointTransaction {
+		return
+	}
+	if n := len(m.boundaries); n > 0 && ComparePositions(m.boundaries[n-1], read) >= 0 {
+		return
+	}
+	if len(m.boundaries) >= maxCheckpointBoundaries {
+		m.boundaries = m.boundaries[1:]
+	}
+	m.boundaries = append(m.boundaries, read)
+}
+
+// boundaryBeforeLocked 不超过 pos 的最近一个事务边界，之前的边界不会再用到，一并丢弃。
+// 没有这样的边界时 Name 为空，调用方需持有锁
+func (m *MySQLBinlogSlave) boundaryBeforeLocked(pos Position) Position {
+	i := 0
+	for i < len(m.boundaries) && ComparePositions(m.boundaries[i], pos) <= 0 {
+		i++
+	}
+	if i == 0 {
+		return Position{}
+	}
+	m.boundaries = m.boundaries[i-1:]
+	return m.boundaries[0]
+}
@@ -0,0 +1,88 @@
+package canal
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// pendingTracker 报告固定投递水位的处理器
+type pendingTracker struct {
+	collectingHandler
+	pos Position
+}
+
+func (h *pendingTracker) DeliveredPosition() (Position, bool) {
+	return h.pos, h.pos.Name != ""
+}
+
+// TestCheckpointTransaction 测试 transaction 模式只在事务提交之后保存位置，且不超过已投递完成的位置
+func TestCheckpointTransaction(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, mode := range []string{CheckpointEvent, CheckpointTransaction} {
+		meta := &memoryMetaManager{positions: make(map[string]Position)}
+		sink := NewDefaultEventSink(logger)
+		tracker := &pendingTracker{}
+		sink.Subscribe("shop", "orders", tracker)
+		config := MySQLConfig{Host: "127.0.0.1", Port: 1, ServerID: 1001, Checkpoint: mode}
+		slave, err := NewMySQLBinlogSlaveWithMeta(config, sink, logger, meta)
+		if err != nil {
+			t.Fatalf("Failed to create binlog slave: %v", err)
+		}
+		slave.binlogPos.Name = "mysql-bin.000001"
+
+		handle := func(logPos uint32, e replication.Event) uint32 {
+			ev := &replication.BinlogEvent{Header: &replication.EventHeader{LogPos: logPos}, Event: e}
+			if err := slave.handleBinlogEvent(ev); err != nil {
+				t.Fatalf("handleBinlogEvent failed: %v", err)
+			}
+			slave.updatePosition(ev)
+			waitSaves(t, slave)
+			pos, _ := meta.LoadPosition(slave.instanceID)
+			return pos.Pos
+		}
+
+		handle(150, &replication.QueryEvent{Query: []byte("BEGIN")})
+		mid := handle(200, &replication.RowsQueryEvent{Query: []byte("INSERT INTO orders VALUES (1)")})
+		if got := handle(250, &replication.XIDEvent{}); got != 250 {
+			t.Errorf("%s: expected position saved after commit, got %d", mode, got)
+		}
+		handle(300, &replication.QueryEvent{Query: []byte("BEGIN")})
+		if got := handle(350, &replication.RowsQueryEvent{Query: []byte("INSERT INTO orders VALUES (2)")}); mode == CheckpointEvent {
+			if got != 350 || mid != 200 {
+				t.Errorf("event mode: expected positions saved inside transactions, got %d and %d", mid, got)
+			}
+			continue
+		} else if got != 250 || mid != 0 {
+			t.Errorf("transaction mode: expected no position inside transactions, got %d and %d", mid, got)
+		}
+
+		// DDL 没有 XID 事件，语句本身即提交
+		if got := handle(400, &replication.QueryEvent{Query: []byte("ALTER TABLE orders ADD COLUMN note TEXT")}); got != 400 {
+			t.Errorf("expected position saved after ddl, got %d", got)
+		}
+
+		// 投递落后时保存不超过投递水位的最近一个事务边界
+		tracker.pos = Position{Name: "mysql-bin.000001", Pos: 450}
+		handle(450, &replication.QueryEvent{Query: []byte("BEGIN")})
+		if got := handle(500, &replication.XIDEvent{}); got != 400 {
+			t.Errorf("expected boundary before delivered position, got %d", got)
+		}
+		tracker.pos = Position{}
+		if pos, err := slave.savePositionSync(); err != nil || pos.Pos != 500 {
+			t.Errorf("expected boundary saved once delivered, got %+v, %v", pos, err)
+		}
+		if stats := slave.GetStats(); stats["checkpoint"] != CheckpointTransaction {
+			t.Errorf("expected checkpoint mode in stats, got %v", stats["checkpoint"])
+		}
+	}
+
+	if mode := parseCheckpointMode(" Transaction ", logger); mode != CheckpointTransaction {
+		t.Errorf("expected transaction mode, got %q", mode)
+	}
+	if mode := parseCheckpointMode("statement", logger); mode != CheckpointEvent {
+		t.Errorf("expected invalid mode to fall back to event, got %q", mode)
+	}
+}
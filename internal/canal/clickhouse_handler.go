@@ -0,0 +1,537 @@
+package canal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// ClickHouse 目标表的版本列和删除标记列
+const (
+	clickHouseVersionColumn = "_version"
+	clickHouseDeletedColumn = "_is_deleted"
+)
+
+// ClickHouseHandler ClickHouse 同步处理器
+// 所有变更都以追加写入 ReplacingMergeTree 表，UPDATE 写入新行，DELETE 写入带删除标记的旧行，
+// 由 ClickHouse 按版本列合并
+type ClickHouseHandler struct {
+	name        string
+	cfg         config.ClickHouseConfig
+	metaManager MetaManager
+	client      *http.Client
+	logger      *log.Logger
+
+	// 批处理
+	batchSize     int
+	flushInterval time.Duration
+	maxBuffer     int                                 // 缓冲行数上限
+	buffer        map[string][]map[string]interface{} // 目标表 -> 待写入行
+	bufferCount   int
+	targetBytes   map[string]int64 // 目标表 -> 待写入行的估算字节数
+	bufferedBytes int64            // 所有待写入行的估算字节数，原子访问，刷新期间也可无锁读取
+	bufferMu      sync.Mutex
+	flushTimer    *time.Timer
+
+	// 已建表的目标表
+	tablesMu sync.Mutex
+	tables   map[string]bool
+
+	// 性能统计
+	mu           sync.RWMutex
+	rowCount     int64
+	errorCount   int64
+	lastError    string
+	lastErrorAt  time.Time
+	lastFlushAt  time.Time
+	createdCount int64
+	bufferWaits  int64 // 缓冲区满时等待写入的次数
+	pendingRows  int64 // 缓冲区中待写入的行数，与 bufferCount 相同，原子访问，读取时不必等待写入完成
+}
+
+// NewClickHouseHandler 创建 ClickHouse 处理器，metaManager 用于读取和缓存源表结构
+func NewClickHouseHandler(name string, cfg config.ClickHouseConfig, metaManager MetaManager, logger *log.Logger) *ClickHouseHandler {
+	logger.Printf("🔧 Creating ClickHouse Handler (Name: %s, URL: %s)", name, cfg.URL)
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	flushInterval, err := time.ParseDuration(cfg.FlushInterval)
+	if err != nil || flushInterval <= 0 {
+		flushInterval = 5 * time.Second
+	}
+	maxBuffer := cfg.MaxBufferRows
+	if maxBuffer < batchSize {
+		maxBuffer = 100 * batchSize
+	}
+
+	handler := &ClickHouseHandler{
+		name:          name,
+		cfg:           cfg,
+		metaManager:   metaManager,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		logger:        logger,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		maxBuffer:     maxBuffer,
+		buffer:        make(map[string][]map[string]interface{}),
+		targetBytes:   make(map[string]int64),
+		tables:        make(map[string]bool),
+	}
+
+	logger.Printf("✅ ClickHouse Handler created successfully (Name: %s)", name)
+	return handler
+}
+
+// GetName 获取处理器名称
+func (h *ClickHouseHandler) GetName() string {
+	return h.name
+}
+
+// Handle 处理事件（支持批处理）。缓冲区达到行数上限时先写入，ClickHouse 不可用时每隔 flushInterval 重试，
+// 期间事件处理等待，形成背压，ctx 结束时返回错误
+func (h *ClickHouseHandler) Handle(ctx context.Context, event *Event) error {
+	data := event.AfterData
+	deleted := 0
+	if event.EventType == EventTypeDelete {
+		data = event.BeforeData
+		deleted = 1
+	}
+	if data == nil || len(data.Columns) == 0 {
+		return nil
+	}
+
+	meta, err := h.tableMeta(event, data)
+	if err != nil {
+		return err
+	}
+
+	target := h.targetTable(event.Schema, event.Table)
+	if err := h.ensureTable(ctx, target, meta); err != nil {
+		h.recordError(err)
+		return err
+	}
+
+	row := make(map[string]interface{}, len(data.Columns)+2)
+	for _, col := range data.Columns {
+		if col.IsNull {
+			row[col.Name] = nil
+			continue
+		}
+		row[col.Name] = col.Value
+	}
+	row[clickHouseVersionColumn] = eventVersion(event)
+	row[clickHouseDeletedColumn] = deleted
+
+	h.bufferMu.Lock()
+	defer h.bufferMu.Unlock()
+
+	if h.bufferCount >= h.maxBuffer {
+		h.mu.Lock()
+		h.bufferWaits++
+		h.mu.Unlock()
+	}
+	for h.bufferCount >= h.maxBuffer {
+		if err := h.flushLocked(ctx); err == nil {
+			break
+		}
+		h.bufferMu.Unlock()
+		select {
+		case <-time.After(h.flushInterval):
+			h.bufferMu.Lock()
+		case <-ctx.Done():
+			h.bufferMu.Lock()
+			return fmt.Errorf("clickhouse buffer full (%d rows waiting to be written): %v", h.bufferCount, ctx.Err())
+		}
+	}
+
+	size := EventSize(event)
+	h.buffer[target] = append(h.buffer[target], row)
+	h.bufferCount++
+	atomic.AddInt64(&h.pendingRows, 1)
+	h.targetBytes[target] += size
+	atomic.AddInt64(&h.bufferedBytes, size)
+
+	if h.bufferCount >= h.batchSize {
+		h.logger.Printf("📊 ClickHouse buffer reached batch size %d, flushing", h.batchSize)
+		return h.flushLocked(ctx)
+	}
+
+	if h.flushTimer == nil {
+		h.flushTimer = time.AfterFunc(h.flushInterval, func() {
+			h.bufferMu.Lock()
+			defer h.bufferMu.Unlock()
+			timeoutCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			h.flushLocked(timeoutCtx)
+		})
+	}
+
+	return nil
+}
+
+// Flush 立即写入缓冲区中的数据
+func (h *ClickHouseHandler) Flush(ctx context.Context) error {
+	h.bufferMu.Lock()
+	defer h.bufferMu.Unlock()
+	return h.flushLocked(ctx)
+}
+
+// Close 写入缓冲区中剩余的数据并停止重试定时器
+func (h *ClickHouseHandler) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	h.bufferMu.Lock()
+	defer h.bufferMu.Unlock()
+	err := h.flushLocked(ctx)
+	// 写入失败时 flushLocked 会设置重试定时器，关闭后不再重试
+	if h.flushTimer != nil {
+		h.flushTimer.Stop()
+		h.flushTimer = nil
+	}
+	if err != nil && h.bufferCount > 0 {
+		return fmt.Errorf("dropped %d rows: %v", h.bufferCount, err)
+	}
+	return err
+}
+
+// flushLocked 写入缓冲区，调用方需持有 bufferMu
+// 写入失败的行保留在缓冲区中，下次刷新时重试
+func (h *ClickHouseHandler) flushLocked(ctx context.Context) error {
+	if h.flushTimer != nil {
+		h.flushTimer.Stop()
+		h.flushTimer = nil
+	}
+	if h.bufferCount == 0 {
+		return nil
+	}
+
+	var lastErr error
+	for target, rows := range h.buffer {
+		if err := h.insertRows(ctx, target, rows); err != nil {
+			h.logger.Printf("❌ Failed to write %d rows to ClickHouse table %s: %v", len(rows), target, err)
+			h.recordError(err)
+			lastErr = err
+			continue
+		}
+
+		h.logger.Printf("✅ Wrote %d rows to ClickHouse table %s", len(rows), target)
+		h.mu.Lock()
+		h.rowCount += int64(len(rows))
+		h.lastFlushAt = time.Now()
+		h.mu.Unlock()
+
+		h.bufferCount -= len(rows)
+		atomic.AddInt64(&h.pendingRows, -int64(len(rows)))
+		atomic.AddInt64(&h.bufferedBytes, -h.targetBytes[target])
+		delete(h.buffer, target)
+		delete(h.targetBytes, target)
+	}
+
+	// 有失败的行时稍后重试
+	if lastErr != nil && h.bufferCount > 0 {
+		h.flushTimer = time.AfterFunc(h.flushInterval, func() {
+			h.bufferMu.Lock()
+			defer h.bufferMu.Unlock()
+			timeoutCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			h.flushLocked(timeoutCtx)
+		})
+	}
+
+	return lastErr
+}
+
+// BufferedBytes 缓冲区中待写入行占用的字节数
+func (h *ClickHouseHandler) BufferedBytes() int64 {
+	return atomic.LoadInt64(&h.bufferedBytes)
+}
+
+// insertRows 以 JSONEachRow 格式批量写入
+func (h *ClickHouseHandler) insertRows(ctx context.Context, target string, rows []map[string]interface{}) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("failed to encode row: %v", err)
+		}
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", target)
+	return h.exec(ctx, query, &body)
+}
+
+// ensureTable 目标表不存在时根据源表结构自动建表
+func (h *ClickHouseHandler) ensureTable(ctx context.Context, target string, meta *TableMeta) error {
+	h.tablesMu.Lock()
+	defer h.tablesMu.Unlock()
+
+	if h.tables[target] {
+		return nil
+	}
+
+	if database := h.targetDatabase(meta.Schema); database != "" {
+		if err := h.exec(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", quoteIdentifier(database)), nil); err != nil {
+			return fmt.Errorf("failed to create ClickHouse database %s: %v", database, err)
+		}
+	}
+
+	ddl := BuildClickHouseDDL(target, meta)
+	h.logger.Printf("🏗️ Ensuring ClickHouse table %s", target)
+	if err := h.exec(ctx, ddl, nil); err != nil {
+		return fmt.Errorf("failed to create ClickHouse table %s: %v", target, err)
+	}
+
+	h.tables[target] = true
+	h.mu.Lock()
+	h.createdCount++
+	h.mu.Unlock()
+	return nil
+}
+
+// tableMeta 获取源表结构：优先使用元数据缓存，缓存缺失时根据事件列和主键推导并写回缓存
+func (h *ClickHouseHandler) tableMeta(event *Event, data *RowData) (*TableMeta, error) {
+	schema, table := event.Schema, event.Table
+	if h.metaManager != nil {
+		meta, err := h.metaManager.LoadTableMeta(schema, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load table meta for %s.%s: %v", schema, table, err)
+		}
+		if meta != nil && len(meta.Columns) > 0 {
+			// 升级前缓存的表结构没有主键，使用事件携带的主键
+			if len(meta.PrimaryKey) == 0 && len(event.PrimaryKey) > 0 {
+				withKey := *meta
+				withKey.PrimaryKey = event.PrimaryKey
+				return &withKey, nil
+			}
+			return meta, nil
+		}
+	}
+
+	meta := &TableMeta{
+		Schema:     schema,
+		Table:      table,
+		Columns:    make([]string, len(data.Columns)),
+		Types:      make([]string, len(data.Columns)),
+		PrimaryKey: event.PrimaryKey,
+	}
+	for i, col := range data.Columns {
+		meta.Columns[i] = col.Name
+		meta.Types[i] = col.Type
+	}
+
+	if h.metaManager != nil {
+		if err := h.metaManager.SaveTableMeta(schema, table, meta); err != nil {
+			h.logger.Printf("⚠️ Failed to cache table meta for %s.%s: %v", schema, table, err)
+		}
+	}
+	return meta, nil
+}
+
+// exec 通过 HTTP 接口执行语句，body 非空时作为数据发送
+func (h *ClickHouseHandler) exec(ctx context.Context, query string, body io.Reader) error {
+	endpoint := strings.TrimRight(h.cfg.URL, "/") + "/"
+
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = http.NewRequestWithContext(ctx, "POST", endpoint+"?query="+url.QueryEscape(query), body)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(query))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("User-Agent", "Canal-Pikachun/1.0")
+	if h.cfg.Username != "" {
+		req.Header.Set("X-ClickHouse-User", h.cfg.Username)
+		req.Header.Set("X-ClickHouse-Key", h.cfg.Password)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to ClickHouse: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ClickHouse returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// targetDatabase 获取目标库名
+func (h *ClickHouseHandler) targetDatabase(schema string) string {
+	if h.cfg.Database != "" {
+		return h.cfg.Database
+	}
+	return schema
+}
+
+// targetTable 获取目标表全名，配置了统一目标库时以 源库__表名 避免重名
+func (h *ClickHouseHandler) targetTable(schema, table string) string {
+	if h.cfg.Database != "" {
+		return quoteIdentifier(h.cfg.Database) + "." + quoteIdentifier(schema+"__"+table)
+	}
+	return quoteIdentifier(schema) + "." + quoteIdentifier(table)
+}
+
+// recordError 记录最近一次错误
+func (h *ClickHouseHandler) recordError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errorCount++
+	h.lastError, h.lastErrorAt = err.Error(), time.Now()
+}
+
+// DeliveryStatus 写入 ClickHouse 的结果
+func (h *ClickHouseHandler) DeliveryStatus() DeliveryStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return DeliveryStatus{
+		Delivered:     h.rowCount,
+		Pending:       atomic.LoadInt64(&h.pendingRows),
+		Failed:        h.errorCount,
+		LastError:     h.lastError,
+		LastErrorAt:   h.lastErrorAt,
+		LastSuccessAt: h.lastFlushAt,
+	}
+}
+
+// GetStats 获取处理器统计信息
+func (h *ClickHouseHandler) GetStats() map[string]interface{} {
+	h.bufferMu.Lock()
+	buffered := h.bufferCount
+	h.bufferMu.Unlock()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return map[string]interface{}{
+		"name":           h.name,
+		"url":            h.cfg.URL,
+		"row_count":      h.rowCount,
+		"error_count":    h.errorCount,
+		"last_error":     h.lastError,
+		"last_flush_at":  h.lastFlushAt,
+		"tables_created": h.createdCount,
+		"buffer_size":    buffered,
+		"max_buffer":     h.maxBuffer,
+		"buffer_waits":   h.bufferWaits,
+	}
+}
+
+// BuildClickHouseDDL 根据源表结构生成 ReplacingMergeTree 建表语句
+// 以主键列作为排序键，同一主键的行按版本合并；主键未知时退化为第一列。排序键以外的列均允许为 NULL
+func BuildClickHouseDDL(target string, meta *TableMeta) string {
+	known := make(map[string]bool, len(meta.Columns))
+	for _, name := range meta.Columns {
+		known[name] = true
+	}
+	orderKey := make([]string, 0, len(meta.PrimaryKey))
+	isKey := make(map[string]bool)
+	for _, name := range meta.PrimaryKey {
+		if known[name] {
+			orderKey = append(orderKey, name)
+			isKey[name] = true
+		}
+	}
+	if len(orderKey) == 0 && len(meta.Columns) > 0 {
+		orderKey = append(orderKey, meta.Columns[0])
+		isKey[meta.Columns[0]] = true
+	}
+
+	columns := make([]string, 0, len(meta.Columns)+2)
+	for i, name := range meta.Columns {
+		colType := ""
+		if i < len(meta.Types) {
+			colType = meta.Types[i]
+		}
+		chType := clickHouseType(colType)
+		if !isKey[name] {
+			chType = "Nullable(" + chType + ")"
+		}
+		columns = append(columns, fmt.Sprintf("    %s %s", quoteIdentifier(name), chType))
+	}
+	columns = append(columns,
+		fmt.Sprintf("    %s UInt64", quoteIdentifier(clickHouseVersionColumn)),
+		fmt.Sprintf("    %s UInt8", quoteIdentifier(clickHouseDeletedColumn)),
+	)
+
+	orderBy := "tuple()"
+	switch len(orderKey) {
+	case 0:
+	case 1:
+		orderBy = quoteIdentifier(orderKey[0])
+	default:
+		quoted := make([]string, len(orderKey))
+		for i, name := range orderKey {
+			quoted[i] = quoteIdentifier(name)
+		}
+		orderBy = "(" + strings.Join(quoted, ", ") + ")"
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n) ENGINE = ReplacingMergeTree(%s, %s)\nORDER BY %s",
+		target, strings.Join(columns, ",\n"),
+		quoteIdentifier(clickHouseVersionColumn), quoteIdentifier(clickHouseDeletedColumn), orderBy)
+}
+
+// clickHouseType MySQL 类型映射为 ClickHouse 类型
+func clickHouseType(mysqlType string) string {
+	switch strings.ToLower(mysqlType) {
+	case "tinyint":
+		return "Int8"
+	case "smallint", "year":
+		return "Int16"
+	case "mediumint", "int":
+		return "Int32"
+	case "bigint":
+		return "Int64"
+	case "float":
+		return "Float32"
+	case "double":
+		return "Float64"
+	case "decimal":
+		return "Decimal(38, 10)"
+	case "date":
+		return "Date32"
+	case "datetime", "timestamp":
+		return "DateTime64(6)"
+	default:
+		// varchar, text, blob, json, time, enum 等统一按字符串存储
+		return "String"
+	}
+}
+
+// eventVersion 计算行版本：binlog 文件序号与偏移量组合，重放时保持一致
+// 无法解析文件名时退化为事件时间
+func eventVersion(event *Event) uint64 {
+	name := event.Position.Name
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		if seq, err := strconv.ParseUint(name[idx+1:], 10, 32); err == nil {
+			return seq<<32 | uint64(event.Position.Pos)
+		}
+	}
+	return uint64(event.Timestamp.UnixNano())
+}
+
+// quoteIdentifier 使用反引号引用标识符
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -0,0 +1,184 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// TestBuildClickHouseDDL 测试建表语句生成
+func TestBuildClickHouseDDL(t *testing.T) {
+	ddl := BuildClickHouseDDL("`testdb`.`users`", &TableMeta{
+		Schema:  "testdb",
+		Table:   "users",
+		Columns: []string{"id", "name", "created_at"},
+		Types:   []string{"bigint", "varchar", "datetime"},
+	})
+
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS `testdb`.`users`",
+		"`id` Int64,",
+		"`name` Nullable(String)",
+		"`created_at` Nullable(DateTime64(6))",
+		"`_version` UInt64",
+		"`_is_deleted` UInt8",
+		"ENGINE = ReplacingMergeTree(`_version`, `_is_deleted`)",
+		"ORDER BY `id`",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("DDL missing %q:\n%s", want, ddl)
+		}
+	}
+}
+
+// TestEventVersion 测试版本号随 binlog 位置递增
+func TestEventVersion(t *testing.T) {
+	v1 := eventVersion(&Event{Position: Position{Name: "mysql-bin.000001", Pos: 9000}})
+	v2 := eventVersion(&Event{Position: Position{Name: "mysql-bin.000002", Pos: 4}})
+	if v1 >= v2 {
+		t.Errorf("expected version to grow across binlog files: %d >= %d", v1, v2)
+	}
+}
+
+// TestClickHouseHandlerFlush 测试批量写入与自动建表
+func TestClickHouseHandlerFlush(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	var inserted string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if query := r.URL.Query().Get("query"); query != "" {
+			queries = append(queries, query)
+			inserted += string(body)
+		} else {
+			queries = append(queries, string(body))
+		}
+	}))
+	defer server.Close()
+
+	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
+	handler := NewClickHouseHandler("clickhouse-1", config.ClickHouseConfig{URL: server.URL, BatchSize: 10}, nil, logger)
+
+	row := func(id int, name string) *RowData {
+		return &RowData{Columns: []Column{
+			{Name: "id", Type: "int", Value: id},
+			{Name: "name", Type: "varchar", Value: name},
+		}}
+	}
+	events := []*Event{
+		{Schema: "testdb", Table: "users", EventType: EventTypeInsert, AfterData: row(1, "a"), Position: Position{Name: "mysql-bin.000001", Pos: 100}},
+		{Schema: "testdb", Table: "users", EventType: EventTypeDelete, BeforeData: row(1, "a"), Position: Position{Name: "mysql-bin.000001", Pos: 200}},
+	}
+	for _, event := range events {
+		if err := handler.Handle(context.Background(), event); err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+	}
+	if err := handler.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(queries) != 3 {
+		t.Fatalf("expected create database, create table and insert, got %d queries: %v", len(queries), queries)
+	}
+	if !strings.HasPrefix(queries[1], "CREATE TABLE IF NOT EXISTS `testdb`.`users`") {
+		t.Errorf("unexpected DDL: %s", queries[1])
+	}
+	if queries[2] != "INSERT INTO `testdb`.`users` FORMAT JSONEachRow" {
+		t.Errorf("unexpected insert query: %s", queries[2])
+	}
+
+	lines := strings.Split(strings.TrimSpace(inserted), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 rows, got %d: %s", len(lines), inserted)
+	}
+	if !strings.Contains(lines[0], `"_is_deleted":0`) || !strings.Contains(lines[1], `"_is_deleted":1`) {
+		t.Errorf("unexpected delete markers: %v", lines)
+	}
+
+	if stats := handler.GetStats(); stats["row_count"].(int64) != 2 {
+		t.Errorf("unexpected row_count: %v", stats["row_count"])
+	}
+}
+
+// TestBuildClickHouseDDLPrimaryKey 测试以缓存的主键列作为排序键，主键列不允许为 NULL
+func TestBuildClickHouseDDLPrimaryKey(t *testing.T) {
+	ddl := BuildClickHouseDDL("`testdb`.`order_items`", &TableMeta{
+		Schema:     "testdb",
+		Table:      "order_items",
+		Columns:    []string{"note", "order_id", "line_no"},
+		Types:      []string{"varchar", "bigint", "int"},
+		PrimaryKey: []string{"order_id", "line_no"},
+	})
+
+	for _, want := range []string{
+		"`note` Nullable(String)",
+		"`order_id` Int64,",
+		"`line_no` Int32,",
+		"ORDER BY (`order_id`, `line_no`)",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("DDL missing %q:\n%s", want, ddl)
+		}
+	}
+}
+
+// TestClickHouseHandlerBackpressure 测试 ClickHouse 不可用时缓冲区达到上限后处理等待，恢复后继续
+func TestClickHouseHandlerBackpressure(t *testing.T) {
+	var mu sync.Mutex
+	down := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if down && r.URL.Query().Get("query") != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	logger := log.New(io.Discard, "", 0)
+	handler := NewClickHouseHandler("clickhouse-1", config.ClickHouseConfig{URL: server.URL, BatchSize: 2, MaxBufferRows: 2, FlushInterval: "20ms"}, nil, logger)
+	defer handler.Close()
+
+	event := func(id int) *Event {
+		return &Event{Schema: "testdb", Table: "users", EventType: EventTypeInsert, PrimaryKey: []string{"id"},
+			AfterData: &RowData{Columns: []Column{{Name: "id", Type: "int", Value: id}}}, Position: Position{Name: "mysql-bin.000001", Pos: uint32(id)}}
+	}
+	handler.Handle(context.Background(), event(1))
+	handler.Handle(context.Background(), event(2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := handler.Handle(ctx, event(3)); err == nil {
+		t.Fatal("expected Handle to wait while the buffer is full and ClickHouse is down")
+	}
+	if pending := handler.DeliveryStatus().Pending; pending != 2 {
+		t.Errorf("expected buffer capped at 2 rows, got %d", pending)
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	if err := handler.Handle(context.Background(), event(3)); err != nil {
+		t.Fatalf("expected Handle to proceed after ClickHouse recovered: %v", err)
+	}
+	if stats := handler.GetStats(); stats["buffer_waits"].(int64) != 2 {
+		t.Errorf("unexpected buffer_waits: %v", stats["buffer_waits"])
+	}
+}
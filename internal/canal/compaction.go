@@ -0,0 +1,131 @@
+package canal
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxCompactWindow 合并窗口上限，窗口内的事件都保存在内存中
+const MaxCompactWindow = 3600
+
+// maxCompactRows 合并窗口内缓冲的最多行数，超出后提前投递
+const maxCompactRows = 10000
+
+// EventCompactor 窗口合并：同一行在窗口内的多次变更合并为最新状态后再投递（后写覆盖），
+// 用于计数器等频繁更新的热点行，大幅减少 Webhook 请求量。
+// 合并规则（前一个事件 + 新事件）：
+//   - INSERT + UPDATE：INSERT，值为最新状态
+//   - INSERT + DELETE：两者都不投递
+//   - UPDATE + UPDATE：UPDATE，变更前为第一次变更前的值，变更后为最新状态
+//   - DELETE + INSERT：UPDATE，变更前为删除前的值
+//   - 其他：保留新事件
+//
+// 没有主键的表不合并。合并后的事件位于该行最后一次变更的位置，compacted 字段为合并的事件数
+type EventCompactor struct {
+	Window time.Duration
+
+	index map[string]int // 行 -> 在缓冲区中的下标，调用方负责加锁
+	live  int            // 缓冲区中未被合并掉的事件数
+}
+
+// NewEventCompactor 根据任务配置创建合并器，window 为合并窗口（秒），为 0 时返回 nil，表示不合并
+func NewEventCompactor(window int) (*EventCompactor, error) {
+	if window < 0 || window > MaxCompactWindow {
+		return nil, fmt.Errorf("compact window must be between 0 and %d seconds, got %d", MaxCompactWindow, window)
+	}
+	if window == 0 {
+		return nil, nil
+	}
+	return &EventCompactor{
+		Window: time.Duration(window) * time.Second,
+		index:  make(map[string]int),
+	}, nil
+}
+
+// add 把事件合并进缓冲区，返回新的缓冲区、缓冲区字节数的变化和被合并掉的事件数。
+// 被合并的旧事件在缓冲区中置为 nil，刷新时由 drain 清除
+func (c *EventCompactor) add(buffer []*Event, event *Event) ([]*Event, int64, int) {
+	key, ok := rowValues(event, event.PrimaryKey)
+	if !ok {
+		c.live++
+		return append(buffer, event), EventSize(event), 0
+	}
+	key = event.Schema + "." + event.Table + ":" + key
+
+	idx, exists := c.index[key]
+	if !exists {
+		c.index[key] = len(buffer)
+		c.live++
+		return append(buffer, event), EventSize(event), 0
+	}
+
+	prev := buffer[idx]
+	buffer[idx] = nil
+	delta := -EventSize(prev)
+	merged := mergeEvents(prev, event)
+	if merged == nil {
+		delete(c.index, key)
+		c.live--
+		return buffer, delta, 2
+	}
+	c.index[key] = len(buffer)
+	return c.pack(append(buffer, merged)), delta + EventSize(merged), 1
+}
+
+// pack 热点行反复合并会在缓冲区中留下大量空位，空位过多时整理缓冲区并重建索引
+func (c *EventCompactor) pack(buffer []*Event) []*Event {
+	if len(buffer) < 2*c.live+64 {
+		return buffer
+	}
+	packed := buffer[:0]
+	for _, event := range buffer {
+		if event == nil {
+			continue
+		}
+		if key, ok := rowValues(event, event.PrimaryKey); ok {
+			c.index[event.Schema+"."+event.Table+":"+key] = len(packed)
+		}
+		packed = append(packed, event)
+	}
+	clear(buffer[len(packed):])
+	return packed
+}
+
+// rows 缓冲区中待投递的事件数
+func (c *EventCompactor) rows() int {
+	return c.live
+}
+
+// drain 取出合并后的事件并清空索引
+func (c *EventCompactor) drain(buffer []*Event) []*Event {
+	c.index = make(map[string]int)
+	c.live = 0
+	events := make([]*Event, 0, len(buffer))
+	for _, event := range buffer {
+		if event != nil {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// mergeEvents 合并同一行的两个事件，返回 nil 表示两者相互抵消。
+// 事件可能同时被其他处理器使用，不修改传入的事件
+func mergeEvents(prev, next *Event) *Event {
+	merged := *next
+	merged.Compacted = max(prev.Compacted, 1) + max(next.Compacted, 1)
+
+	switch {
+	case prev.EventType == EventTypeInsert && next.EventType == EventTypeUpdate:
+		merged.EventType = EventTypeInsert
+		merged.BeforeData = nil
+	case prev.EventType == EventTypeInsert && next.EventType == EventTypeDelete:
+		return nil
+	case prev.EventType == EventTypeUpdate && next.EventType == EventTypeUpdate:
+		merged.BeforeData = prev.BeforeData
+	case prev.EventType == EventTypeDelete && next.EventType == EventTypeInsert:
+		merged.EventType = EventTypeUpdate
+		merged.BeforeData = prev.BeforeData
+	}
+	return &merged
+}
@@ -0,0 +1,191 @@
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// compactTestEvent 构造 shop.counters 表上的事件，value 为计数列的值
+func compactTestEvent(eventType EventType, id, before, after int) *Event {
+	row := func(value int) *RowData {
+		return &RowData{Columns: []Column{{Name: "id", Value: id}, {Name: "value", Value: value}}}
+	}
+	event := &Event{
+		ID:         "e",
+		Schema:     "shop",
+		Table:      "counters",
+		EventType:  eventType,
+		PrimaryKey: []string{"id"},
+	}
+	if eventType != EventTypeInsert {
+		event.BeforeData = row(before)
+	}
+	if eventType != EventTypeDelete {
+		event.AfterData = row(after)
+	}
+	return event
+}
+
+// TestNewEventCompactor 测试合并窗口校验
+func TestNewEventCompactor(t *testing.T) {
+	if compactor, err := NewEventCompactor(0); err != nil || compactor != nil {
+		t.Errorf("expected nil compactor for window 0, got %v, %v", compactor, err)
+	}
+	for _, window := range []int{-1, MaxCompactWindow + 1} {
+		if _, err := NewEventCompactor(window); err == nil {
+			t.Errorf("expected error for window %d", window)
+		}
+	}
+}
+
+// TestMergeEvents 测试同一行两个事件的合并规则
+func TestMergeEvents(t *testing.T) {
+	value := func(row *RowData) interface{} {
+		if row == nil {
+			return nil
+		}
+		return row.Columns[1].Value
+	}
+
+	cases := []struct {
+		name       string
+		prev, next *Event
+		wantType   EventType
+		wantBefore interface{}
+		wantAfter  interface{}
+	}{
+		{"insert+update", compactTestEvent(EventTypeInsert, 1, 0, 1), compactTestEvent(EventTypeUpdate, 1, 1, 2), EventTypeInsert, nil, 2},
+		{"update+update", compactTestEvent(EventTypeUpdate, 1, 1, 2), compactTestEvent(EventTypeUpdate, 1, 2, 3), EventTypeUpdate, 1, 3},
+		{"delete+insert", compactTestEvent(EventTypeDelete, 1, 5, 0), compactTestEvent(EventTypeInsert, 1, 0, 7), EventTypeUpdate, 5, 7},
+		{"update+delete", compactTestEvent(EventTypeUpdate, 1, 1, 2), compactTestEvent(EventTypeDelete, 1, 2, 0), EventTypeDelete, 2, nil},
+	}
+	for _, c := range cases {
+		next := *c.next
+		merged := mergeEvents(c.prev, c.next)
+		if merged == nil {
+			t.Fatalf("%s: unexpected nil", c.name)
+		}
+		if merged.EventType != c.wantType || value(merged.BeforeData) != c.wantBefore || value(merged.AfterData) != c.wantAfter || merged.Compacted != 2 {
+			t.Errorf("%s: unexpected merged event %+v", c.name, merged)
+		}
+		if c.next.EventType != next.EventType || c.next.BeforeData != next.BeforeData || c.next.Compacted != 0 {
+			t.Errorf("%s: the incoming event must not be modified", c.name)
+		}
+	}
+
+	if merged := mergeEvents(compactTestEvent(EventTypeInsert, 1, 0, 1), compactTestEvent(EventTypeDelete, 1, 1, 0)); merged != nil {
+		t.Errorf("insert+delete should cancel out, got %+v", merged)
+	}
+}
+
+// TestEventCompactorBuffer 测试缓冲区按行合并，合并后的事件位于该行最后一次变更的位置，没有主键的事件不合并
+func TestEventCompactorBuffer(t *testing.T) {
+	compactor, err := NewEventCompactor(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noPK := &Event{ID: "nopk", Schema: "shop", Table: "logs", EventType: EventTypeInsert}
+	var buffer []*Event
+	var bytes int64
+	compacted := 0
+	for _, event := range []*Event{
+		compactTestEvent(EventTypeInsert, 1, 0, 1),
+		compactTestEvent(EventTypeInsert, 2, 0, 1),
+		noPK,
+		noPK,
+		compactTestEvent(EventTypeUpdate, 1, 1, 2),
+		compactTestEvent(EventTypeDelete, 2, 1, 0),
+	} {
+		var delta int64
+		var n int
+		buffer, delta, n = compactor.add(buffer, event)
+		bytes += delta
+		compacted += n
+	}
+
+	events := compactor.drain(buffer)
+	if len(events) != 3 || events[0] != noPK || events[1] != noPK || events[2].AfterData.Columns[1].Value != 2 {
+		t.Fatalf("unexpected compacted events %+v", events)
+	}
+	if compacted != 3 {
+		t.Errorf("expected 3 events compacted away, got %d", compacted)
+	}
+	if want := 2*EventSize(noPK) + EventSize(events[2]); bytes != want {
+		t.Errorf("expected %d buffered bytes, got %d", want, bytes)
+	}
+	if compactor.rows() != 0 {
+		t.Error("expected drain to reset the compactor")
+	}
+}
+
+// TestEventCompactorPack 测试热点行反复合并后整理缓冲区
+func TestEventCompactorPack(t *testing.T) {
+	compactor, _ := NewEventCompactor(1)
+	var buffer []*Event
+	for i := 0; i < 1000; i++ {
+		buffer, _, _ = compactor.add(buffer, compactTestEvent(EventTypeUpdate, i%2, i, i+1))
+	}
+	if len(buffer) > 100 {
+		t.Errorf("expected the buffer to be packed, got %d slots", len(buffer))
+	}
+	events := compactor.drain(buffer)
+	if len(events) != 2 || events[0].AfterData.Columns[1].Value != 999 || events[1].AfterData.Columns[1].Value != 1000 {
+		t.Errorf("unexpected events after packing %+v", events)
+	}
+}
+
+// TestWebhookHandlerCompaction 测试窗口合并模式下热点行只投递最新状态
+func TestWebhookHandlerCompaction(t *testing.T) {
+	var mu sync.Mutex
+	var received []*Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Events []*Event `json:"events"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode payload: %v", err)
+		}
+		mu.Lock()
+		received = append(received, payload.Events...)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler("webhook-1", server.URL, log.New(io.Discard, "", 0))
+	compactor, _ := NewEventCompactor(60)
+	handler.SetCompactor(compactor)
+
+	// 超过批大小也不提前投递
+	handler.Handle(context.Background(), compactTestEvent(EventTypeInsert, 1, 0, 0))
+	for i := 0; i < 20; i++ {
+		handler.Handle(context.Background(), compactTestEvent(EventTypeUpdate, 1, i, i+1))
+	}
+	mu.Lock()
+	if len(received) != 0 {
+		t.Errorf("expected events to be held until the window ends, got %d", len(received))
+	}
+	mu.Unlock()
+
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 {
+		t.Fatalf("expected one compacted event, got %d", len(received))
+	}
+	event := received[0]
+	if event.EventType != EventTypeInsert || event.Compacted != 21 || event.AfterData.Columns[1].Value != float64(20) {
+		t.Errorf("unexpected compacted event %+v", event)
+	}
+	if handler.GetStats()["compacted_events"] != int64(20) || handler.BufferedBytes() != 0 {
+		t.Errorf("unexpected stats %v, buffered %d", handler.GetStats(), handler.BufferedBytes())
+	}
+}
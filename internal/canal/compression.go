@@ -0,0 +1,167 @@
+package canal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Webhook 请求体压缩方式
+const (
+	CompressionNone = "none" // 不压缩
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+	CompressionAuto = "auto" // 先用 gzip，接收方通过 Accept-Encoding 声明支持 zstd 后改用 zstd
+
+	// compressionIdentity 协商结果为不压缩
+	compressionIdentity = "identity"
+)
+
+// DefaultCompressMinSize 默认只压缩不小于该字节数的请求体，小请求压缩收益有限
+const DefaultCompressMinSize = 1024
+
+// PayloadCompressor 按配置和与接收方协商的结果压缩 Webhook 请求体
+// 接收方返回 415 或在响应头 Accept-Encoding 中声明支持的编码（RFC 7694）后，之后的请求改用双方都支持的编码
+type PayloadCompressor struct {
+	Mode    string
+	MinSize int
+
+	mu         sync.Mutex
+	encoding   string // 当前使用的编码
+	preference []string
+	zstd       *zstd.Encoder
+}
+
+// NewPayloadCompressor 创建请求体压缩器，mode 为空或 none 时返回 nil，minSize 为 0 时使用默认阈值
+func NewPayloadCompressor(mode string, minSize int) (*PayloadCompressor, error) {
+	if minSize < 0 {
+		return nil, fmt.Errorf("compression min size must not be negative")
+	}
+	if minSize == 0 {
+		minSize = DefaultCompressMinSize
+	}
+
+	c := &PayloadCompressor{Mode: mode, MinSize: minSize}
+	switch mode {
+	case "", CompressionNone:
+		return nil, nil
+	case CompressionGzip:
+		c.encoding = CompressionGzip
+		c.preference = []string{CompressionGzip, CompressionZstd}
+	case CompressionZstd:
+		c.encoding = CompressionZstd
+		c.preference = []string{CompressionZstd, CompressionGzip}
+	case CompressionAuto:
+		c.encoding = CompressionGzip
+		c.preference = []string{CompressionZstd, CompressionGzip}
+	default:
+		return nil, fmt.Errorf("unknown compression %q, supported: none, gzip, zstd, auto", mode)
+	}
+	return c, nil
+}
+
+// Encoding 当前使用的编码，协商为不压缩时返回 identity
+func (c *PayloadCompressor) Encoding() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.encoding
+}
+
+// Compress 压缩请求体，返回压缩后的数据和 Content-Encoding；低于阈值或协商为不压缩时原样返回，编码为空
+func (c *PayloadCompressor) Compress(data []byte) ([]byte, string, error) {
+	if len(data) < c.MinSize {
+		return data, "", nil
+	}
+
+	c.mu.Lock()
+	encoding := c.encoding
+	c.mu.Unlock()
+
+	switch encoding {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, "", fmt.Errorf("failed to gzip payload: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, "", fmt.Errorf("failed to gzip payload: %v", err)
+		}
+		return buf.Bytes(), encoding, nil
+	case CompressionZstd:
+		encoder, err := c.zstdEncoder()
+		if err != nil {
+			return nil, "", err
+		}
+		return encoder.EncodeAll(data, nil), encoding, nil
+	default:
+		return data, "", nil
+	}
+}
+
+// zstdEncoder 延迟创建 zstd 编码器，EncodeAll 可并发调用
+func (c *PayloadCompressor) zstdEncoder() (*zstd.Encoder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.zstd == nil {
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd encoder: %v", err)
+		}
+		c.zstd = encoder
+	}
+	return c.zstd, nil
+}
+
+// Negotiate 根据响应调整之后请求使用的编码，返回编码是否变化
+// 响应头带有 Accept-Encoding 时按偏好选择其中支持的编码；否则压缩请求收到 415 时改为不压缩
+func (c *PayloadCompressor) Negotiate(statusCode int, acceptEncoding, used string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	next := c.encoding
+	switch {
+	case strings.TrimSpace(acceptEncoding) != "":
+		accepted := parseAcceptEncoding(acceptEncoding)
+		next = compressionIdentity
+		for _, encoding := range c.preference {
+			if accepted[encoding] {
+				next = encoding
+				break
+			}
+		}
+	case statusCode == 415 && used != "":
+		next = compressionIdentity
+	}
+
+	if next == c.encoding {
+		return false
+	}
+	c.encoding = next
+	return true
+}
+
+// parseAcceptEncoding 解析 Accept-Encoding，q=0 的编码视为不支持
+func parseAcceptEncoding(header string) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+		enabled := true
+		for _, param := range fields[1:] {
+			param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+			if param == "q=0" || strings.HasPrefix(param, "q=0.") && strings.Trim(param[4:], "0") == "" {
+				enabled = false
+			}
+		}
+		accepted[name] = enabled
+	}
+	return accepted
+}
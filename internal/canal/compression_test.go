@@ -0,0 +1,108 @@
+package canal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// TestPayloadCompressorThreshold 测试只压缩不小于阈值的请求体
+func TestPayloadCompressorThreshold(t *testing.T) {
+	if c, err := NewPayloadCompressor(CompressionNone, 0); c != nil || err != nil {
+		t.Fatalf("expected nil compressor for none, got %v, %v", c, err)
+	}
+	if _, err := NewPayloadCompressor("brotli", 0); err == nil {
+		t.Fatal("expected error for unknown compression")
+	}
+
+	c, err := NewPayloadCompressor(CompressionGzip, 0)
+	if err != nil {
+		t.Fatalf("NewPayloadCompressor failed: %v", err)
+	}
+	small := []byte(`{"events":[]}`)
+	if data, encoding, _ := c.Compress(small); encoding != "" || !bytes.Equal(data, small) {
+		t.Errorf("expected small payload uncompressed, got %q", encoding)
+	}
+
+	large := []byte(strings.Repeat(`{"name":"widget"},`, 200))
+	data, encoding, err := c.Compress(large)
+	if err != nil || encoding != CompressionGzip || len(data) >= len(large) {
+		t.Fatalf("expected gzip payload, got %q (%d bytes), %v", encoding, len(data), err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip reader failed: %v", err)
+	}
+	if decoded, _ := io.ReadAll(r); !bytes.Equal(decoded, large) {
+		t.Error("gzip round trip mismatch")
+	}
+}
+
+// TestPayloadCompressorNegotiate 测试按 Accept-Encoding 和 415 响应调整编码
+func TestPayloadCompressorNegotiate(t *testing.T) {
+	c, _ := NewPayloadCompressor(CompressionAuto, 0)
+	if c.Encoding() != CompressionGzip {
+		t.Fatalf("expected auto to start with gzip, got %s", c.Encoding())
+	}
+	if !c.Negotiate(http.StatusOK, "gzip, zstd;q=0.8", CompressionGzip) || c.Encoding() != CompressionZstd {
+		t.Errorf("expected zstd after receiver advertised it, got %s", c.Encoding())
+	}
+	if !c.Negotiate(http.StatusOK, "zstd;q=0, gzip", CompressionZstd) || c.Encoding() != CompressionGzip {
+		t.Errorf("expected gzip when zstd is refused, got %s", c.Encoding())
+	}
+	if c.Negotiate(http.StatusOK, "", CompressionGzip) {
+		t.Error("expected no change without Accept-Encoding")
+	}
+	if !c.Negotiate(http.StatusUnsupportedMediaType, "", CompressionGzip) || c.Encoding() != compressionIdentity {
+		t.Errorf("expected identity after 415, got %s", c.Encoding())
+	}
+}
+
+// TestWebhookHandlerCompression 测试接收方返回 415 后重试时不再压缩
+func TestWebhookHandlerCompression(t *testing.T) {
+	var mu sync.Mutex
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		encoding := r.Header.Get("Content-Encoding")
+		encodings = append(encodings, encoding)
+		if encoding == CompressionZstd {
+			decoder, _ := zstd.NewReader(r.Body)
+			defer decoder.Close()
+			if _, err := io.ReadAll(decoder); err != nil {
+				t.Errorf("failed to decode zstd body: %v", err)
+			}
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+		}
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler("webhook-1", server.URL, log.New(os.Stdout, "[Test] ", log.LstdFlags))
+	compressor, _ := NewPayloadCompressor(CompressionZstd, 1)
+	handler.SetCompressor(compressor)
+
+	events := []*Event{{ID: "e1", Schema: "shop", Table: "orders"}}
+	if _, err := handler.Deliver(context.Background(), events, 1); err == nil {
+		t.Fatal("expected first delivery to fail with 415")
+	}
+	if _, err := handler.Deliver(context.Background(), events, 2); err != nil {
+		t.Fatalf("expected uncompressed retry to succeed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(encodings) != 2 || encodings[0] != CompressionZstd || encodings[1] != "" {
+		t.Errorf("unexpected encodings %v", encodings)
+	}
+}
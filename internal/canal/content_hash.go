@@ -0,0 +1,97 @@
+package canal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentHash 事件的内容哈希：按库、表、主键值和变更后的数据计算的 SHA-256（十六进制）。
+// 与事件ID、binlog 位置和事件类型无关，同一行变更为相同内容时哈希相同，消费端和重新投递据此识别重复的内容。
+// 删除事件没有变更后的数据，只由库、表和主键决定；表没有主键或结构未知时以整行数据作为键
+func ContentHash(event *Event) string {
+	var b strings.Builder
+	b.WriteString(event.Schema)
+	b.WriteByte(0)
+	b.WriteString(event.Table)
+	b.WriteByte(0)
+
+	row := event.AfterData
+	if row == nil {
+		row = event.BeforeData
+	}
+	if row == nil {
+		// 墓碑事件只有主键列
+		row = event.Key
+	}
+	writeContentColumns(&b, "key", keyColumns(row, event.PrimaryKey))
+	if event.AfterData != nil {
+		writeContentColumns(&b, "after", event.AfterData.Columns)
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// keyColumns 行中的主键列，缺少主键列时返回整行
+func keyColumns(row *RowData, primaryKey []string) []Column {
+	if row == nil {
+		return nil
+	}
+	if len(primaryKey) == 0 {
+		return row.Columns
+	}
+	key := make([]Column, 0, len(primaryKey))
+	for _, name := range primaryKey {
+		found := false
+		for _, column := range row.Columns {
+			if column.Name == name {
+				key = append(key, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return row.Columns
+		}
+	}
+	return key
+}
+
+// writeContentColumns 按列顺序写入列名和值，每部分以名称开头，避免键和数据相互混淆
+func writeContentColumns(b *strings.Builder, section string, columns []Column) {
+	b.WriteString(section)
+	b.WriteByte(':')
+	b.WriteString(strconv.Itoa(len(columns)))
+	b.WriteByte(0)
+	for _, column := range columns {
+		b.WriteString(column.Name)
+		b.WriteByte('=')
+		if column.IsNull || column.Value == nil {
+			b.WriteString("null")
+		} else {
+			b.WriteString(contentValue(column.Value))
+		}
+		b.WriteByte(0)
+	}
+}
+
+// contentValue 值的规范文本：字符串和字节加引号，与数字区分；数字不区分位宽，
+// 从事件日志还原为 json.Number 的值与原始值得到相同的文本
+func contentValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []byte:
+		return strconv.Quote(string(v))
+	case time.Time:
+		return strconv.Quote(v.UTC().Format(time.RFC3339Nano))
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
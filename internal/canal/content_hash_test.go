@@ -0,0 +1,95 @@
+package canal
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestContentHash 测试内容哈希只由库、表、主键和变更后的数据决定
+func TestContentHash(t *testing.T) {
+	row := func(id interface{}, status interface{}) *RowData {
+		return &RowData{Columns: []Column{
+			{Name: "id", Type: "bigint", Value: id},
+			{Name: "status", Type: "varchar", Value: status, IsNull: status == nil},
+		}}
+	}
+	insert := &Event{
+		ID: "mysql-bin.000001:0000000120:00000", Schema: "shop", Table: "orders", EventType: EventTypeInsert,
+		Position: Position{Name: "mysql-bin.000001", Pos: 120}, PrimaryKey: []string{"id"}, AfterData: row(int64(1), "paid"),
+	}
+	hash := ContentHash(insert)
+	if len(hash) != 64 {
+		t.Fatalf("expected a hex SHA-256, got %q", hash)
+	}
+
+	// 重新读取同一位置或更新为相同内容时哈希相同
+	replayed := *insert
+	replayed.ID, replayed.Position = "01HZX", Position{Name: "mysql-bin.000002", Pos: 4}
+	update := *insert
+	update.EventType, update.BeforeData = EventTypeUpdate, row(int64(1), "new")
+	for name, event := range map[string]*Event{"replayed": &replayed, "update": &update} {
+		if got := ContentHash(event); got != hash {
+			t.Errorf("%s: expected %s, got %s", name, hash, got)
+		}
+	}
+
+	different := map[string]*Event{
+		"value":  {Schema: "shop", Table: "orders", PrimaryKey: []string{"id"}, AfterData: row(int64(1), "shipped")},
+		"key":    {Schema: "shop", Table: "orders", PrimaryKey: []string{"id"}, AfterData: row(int64(2), "paid")},
+		"table":  {Schema: "shop", Table: "orders_archive", PrimaryKey: []string{"id"}, AfterData: row(int64(1), "paid")},
+		"null":   {Schema: "shop", Table: "orders", PrimaryKey: []string{"id"}, AfterData: row(int64(1), nil)},
+		"string": {Schema: "shop", Table: "orders", PrimaryKey: []string{"id"}, AfterData: row("1", "paid")},
+		"delete": {Schema: "shop", Table: "orders", PrimaryKey: []string{"id"}, EventType: EventTypeDelete, BeforeData: row(int64(1), "paid")},
+	}
+	for name, event := range different {
+		if ContentHash(event) == hash {
+			t.Errorf("%s: content differs but hash is the same", name)
+		}
+	}
+}
+
+// TestContentHashDelete 测试删除事件按主键计算，没有主键时使用整行
+func TestContentHashDelete(t *testing.T) {
+	before := &RowData{Columns: []Column{{Name: "id", Value: int64(7)}, {Name: "status", Value: "paid"}}}
+	deleted := &Event{Schema: "shop", Table: "orders", EventType: EventTypeDelete, PrimaryKey: []string{"id"}, BeforeData: before}
+	hash := ContentHash(deleted)
+
+	// 删除前的其他列不影响哈希，墓碑事件与原删除事件相同
+	changed := *deleted
+	changed.BeforeData = &RowData{Columns: []Column{{Name: "id", Value: int64(7)}, {Name: "status", Value: "new"}}}
+	tombstone := tombstoneEvent(deleted)
+	if ContentHash(&changed) != hash || ContentHash(tombstone) != hash {
+		t.Error("delete events of the same key should have the same hash")
+	}
+
+	noPK := *deleted
+	noPK.PrimaryKey, noPK.BeforeData = nil, changed.BeforeData
+	deleted.PrimaryKey = nil
+	if ContentHash(&noPK) == ContentHash(deleted) {
+		t.Error("without a primary key the whole row should be the key")
+	}
+}
+
+// TestContentHashRestoredEvent 测试从事件日志还原的数据（数值为 json.Number）得到相同的哈希
+func TestContentHashRestoredEvent(t *testing.T) {
+	event := &Event{Schema: "shop", Table: "orders", PrimaryKey: []string{"id"}, AfterData: &RowData{Columns: []Column{
+		{Name: "id", Value: uint64(18446744073709551615)},
+		{Name: "amount", Value: 12.5},
+		{Name: "note", Value: "gift"},
+	}}}
+
+	data, err := json.Marshal(event.AfterData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored RowData
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&restored); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ContentHash(&Event{Schema: "shop", Table: "orders", PrimaryKey: []string{"id"}, AfterData: &restored}), ContentHash(event); got != want {
+		t.Errorf("restored event hash %s, want %s", got, want)
+	}
+}
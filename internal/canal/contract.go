@@ -0,0 +1,275 @@
+package canal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// 消费端契约中的列类型
+const (
+	ContractTypeString  = "string"
+	ContractTypeInteger = "integer"
+	ContractTypeNumber  = "number" // 整数、浮点数和定点小数
+	ContractTypeBoolean = "boolean"
+	ContractTypeAny     = "any"
+)
+
+// ConsumerContract 消费端契约：消费端登记期望的载荷结构，投递前按契约校验事件，
+// 不符合契约的事件不投递，在事件日志中记为 failed 并附带诊断信息，修正后可重新投递
+type ConsumerContract struct {
+	// Strict 为 true 时，契约中没有列出的表的事件也视为违约
+	Strict bool                      `json:"strict"`
+	Tables map[string]*TableContract `json:"tables"` // 库.表 -> 表的契约
+}
+
+// ContractChecker 按消费端契约校验事件实际投递的载荷，返回违约项的诊断信息，见 WebhookHandler.CheckContract
+type ContractChecker interface {
+	CheckContract(event *Event) []string
+}
+
+// TableContract 一张表的契约
+type TableContract struct {
+	EventTypes []string                  `json:"event_types,omitempty"` // 允许的事件类型，为空表示不限制
+	Columns    map[string]ColumnContract `json:"columns"`
+	// AdditionalColumns 是否允许契约中没有列出的列，默认允许
+	AdditionalColumns *bool `json:"additional_columns,omitempty"`
+}
+
+// ColumnContract 一列的契约
+type ColumnContract struct {
+	Type     string        `json:"type"`               // string、integer、number、boolean、any
+	Required bool          `json:"required,omitempty"` // 行数据中必须包含该列
+	Nullable bool          `json:"nullable,omitempty"` // 允许 NULL
+	Enum     []interface{} `json:"enum,omitempty"`     // 允许的取值
+}
+
+// ParseConsumerContract 解析并校验契约定义，定义为空时返回 nil，表示不校验
+func ParseConsumerContract(definition string) (*ConsumerContract, error) {
+	if strings.TrimSpace(definition) == "" {
+		return nil, nil
+	}
+
+	var contract ConsumerContract
+	decoder := json.NewDecoder(strings.NewReader(definition))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&contract); err != nil {
+		return nil, fmt.Errorf("invalid contract: %v", err)
+	}
+	if len(contract.Tables) == 0 {
+		return nil, fmt.Errorf("contract must list at least one table")
+	}
+
+	for name, table := range contract.Tables {
+		if table == nil || !strings.Contains(name, ".") {
+			return nil, fmt.Errorf("invalid table %q, expected database.table", name)
+		}
+		for _, eventType := range table.EventTypes {
+			switch EventType(strings.ToUpper(eventType)) {
+			case EventTypeInsert, EventTypeUpdate, EventTypeDelete:
+			default:
+				return nil, fmt.Errorf("table %s: unsupported event type %q", name, eventType)
+			}
+		}
+		for column, spec := range table.Columns {
+			switch spec.Type {
+			case ContractTypeString, ContractTypeInteger, ContractTypeNumber, ContractTypeBoolean, ContractTypeAny:
+			default:
+				return nil, fmt.Errorf("table %s column %s: unsupported type %q, supported: string, integer, number, boolean, any", name, column, spec.Type)
+			}
+		}
+	}
+	return &contract, nil
+}
+
+// Validate 按契约校验事件序列化后的载荷，mapping 为载荷映射，nil 表示按 Event 的 JSON 编码。
+// 返回违约项的诊断信息，符合契约时返回 nil；nil 契约不校验。列名为映射后的名称，
+// 列值按载荷 JSON 中的类型校验，如开启数值安全编码后的 64 位整数为 string
+func (c *ConsumerContract) Validate(event *Event, mapping *PayloadMapping) []string {
+	if c == nil {
+		return nil
+	}
+
+	name := event.Schema + "." + event.Table
+	table, ok := c.Tables[name]
+	if !ok {
+		if c.Strict {
+			return []string{fmt.Sprintf("table %s is not in the contract", name)}
+		}
+		return nil
+	}
+
+	var violations []string
+	if len(table.EventTypes) > 0 && !containsFold(table.EventTypes, string(event.EventType)) {
+		violations = append(violations, fmt.Sprintf("event type %s is not allowed, expected %s", event.EventType, strings.Join(table.EventTypes, ", ")))
+	}
+	before, after, err := payloadRows(event, mapping)
+	if err != nil {
+		return append(violations, fmt.Sprintf("failed to serialize event: %v", err))
+	}
+	violations = append(violations, table.validateRow("before_data", before)...)
+	violations = append(violations, table.validateRow("after_data", after)...)
+	return violations
+}
+
+// payloadRows 序列化事件后解码出载荷中的变更前后数据，列值为 JSON 中的值（数值为 json.Number）。
+// 载荷映射展开行数据时按列的字段名从事件顶层取值
+func payloadRows(event *Event, mapping *PayloadMapping) (before, after *RowData, err error) {
+	var payload interface{} = event
+	if mapping != nil {
+		payload = mapping.mapEvent(event)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if mapping == nil || !mapping.Flatten {
+		var rows struct {
+			BeforeData *RowData `json:"before_data"`
+			AfterData  *RowData `json:"after_data"`
+		}
+		err := decoder.Decode(&rows)
+		return rows.BeforeData, rows.AfterData, err
+	}
+
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, nil, err
+	}
+	unflatten := func(field string, row *RowData) *RowData {
+		if row == nil {
+			return nil
+		}
+		result := &RowData{Columns: make([]Column, 0, len(row.Columns))}
+		for _, column := range row.Columns {
+			value, ok := fields[mapping.flattenedName(field, event.Table, column.Name)]
+			if !ok {
+				continue
+			}
+			result.Columns = append(result.Columns, Column{Name: mapping.renameColumn(event.Table, column.Name), Value: value, IsNull: value == nil})
+		}
+		return result
+	}
+	return unflatten("before_data", event.BeforeData), unflatten("after_data", event.AfterData), nil
+}
+
+// validateRow 校验一行数据，row 为空时不校验
+func (t *TableContract) validateRow(field string, row *RowData) []string {
+	if row == nil {
+		return nil
+	}
+
+	var violations []string
+	present := make(map[string]bool, len(row.Columns))
+	for _, column := range row.Columns {
+		present[column.Name] = true
+		spec, ok := t.Columns[column.Name]
+		if !ok {
+			if t.AdditionalColumns != nil && !*t.AdditionalColumns {
+				violations = append(violations, fmt.Sprintf("%s.%s: column is not in the contract", field, column.Name))
+			}
+			continue
+		}
+		if violation := spec.check(column); violation != "" {
+			violations = append(violations, fmt.Sprintf("%s.%s: %s", field, column.Name, violation))
+		}
+	}
+
+	// 按列名排序，诊断信息稳定
+	names := make([]string, 0, len(t.Columns))
+	for name, spec := range t.Columns {
+		if spec.Required && !present[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		violations = append(violations, fmt.Sprintf("%s.%s: required column is missing", field, name))
+	}
+	return violations
+}
+
+// check 校验列值，符合契约时返回空字符串
+func (s ColumnContract) check(column Column) string {
+	if column.IsNull || column.Value == nil {
+		if !s.Nullable {
+			return "null is not allowed"
+		}
+		return ""
+	}
+
+	actual := contractValueType(column.Value)
+	switch s.Type {
+	case ContractTypeAny:
+	case ContractTypeNumber:
+		if actual != ContractTypeInteger && actual != ContractTypeNumber {
+			return fmt.Sprintf("expected number, got %s (%v)", actual, column.Value)
+		}
+	default:
+		if actual != s.Type {
+			return fmt.Sprintf("expected %s, got %s (%v)", s.Type, actual, column.Value)
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		value := fmt.Sprint(column.Value)
+		if b, ok := column.Value.([]byte); ok {
+			value = string(b)
+		}
+		for _, allowed := range s.Enum {
+			if fmt.Sprint(allowed) == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("value %q is not one of %v", value, s.Enum)
+	}
+	return ""
+}
+
+// contractValueType 列值对应的契约类型
+func contractValueType(v interface{}) string {
+	switch n := v.(type) {
+	case bool:
+		return ContractTypeBoolean
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return ContractTypeInteger
+	case float32, float64, decimal.Decimal:
+		return ContractTypeNumber
+	case json.Number:
+		if _, err := n.Int64(); err == nil {
+			return ContractTypeInteger
+		}
+		return ContractTypeNumber
+	case string, []byte, time.Time:
+		return ContractTypeString
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// containsFold 列表中是否包含 s，不区分大小写
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContractViolationError 违约事件在事件日志中记录的错误信息
+func ContractViolationError(violations []string) string {
+	return "contract violation: " + strings.Join(violations, "; ")
+}
@@ -0,0 +1,219 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"pikachun/internal/config"
+)
+
+const testContract = `{
+	"tables": {
+		"shop.orders": {
+			"event_types": ["INSERT", "UPDATE"],
+			"additional_columns": false,
+			"columns": {
+				"id": {"type": "integer", "required": true},
+				"amount": {"type": "number", "nullable": true},
+				"status": {"type": "string", "enum": ["new", "paid"]}
+			}
+		}
+	}
+}`
+
+// contractTestEvent 构造 shop.orders 表上的 INSERT 事件
+func contractTestEvent(columns ...Column) *Event {
+	return &Event{ID: "e1", Schema: "shop", Table: "orders", EventType: EventTypeInsert, AfterData: &RowData{Columns: columns}}
+}
+
+// TestParseConsumerContract 测试契约定义的校验
+func TestParseConsumerContract(t *testing.T) {
+	if contract, err := ParseConsumerContract("  "); err != nil || contract != nil {
+		t.Errorf("expected nil contract for empty definition, got %v, %v", contract, err)
+	}
+	if _, err := ParseConsumerContract(testContract); err != nil {
+		t.Fatalf("failed to parse contract: %v", err)
+	}
+
+	invalid := []string{
+		`{"tables": {}}`,
+		`{"tables": {"orders": {"columns": {}}}}`,
+		`{"tables": {"shop.orders": {"event_types": ["UPSERT"]}}}`,
+		`{"tables": {"shop.orders": {"columns": {"id": {"type": "uuid"}}}}}`,
+		`{"tables": {"shop.orders": {"colums": {}}}}`,
+	}
+	for _, definition := range invalid {
+		if _, err := ParseConsumerContract(definition); err == nil {
+			t.Errorf("expected error for %s", definition)
+		}
+	}
+}
+
+// TestConsumerContractValidate 测试按契约校验事件并给出诊断信息
+func TestConsumerContractValidate(t *testing.T) {
+	contract, err := ParseConsumerContract(testContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := contractTestEvent(
+		Column{Name: "id", Value: int64(1)},
+		Column{Name: "amount", Value: 9.9},
+		Column{Name: "status", Value: "paid"},
+	)
+	if violations := contract.Validate(valid, nil); violations != nil {
+		t.Errorf("expected no violations, got %v", violations)
+	}
+	// number 接受整数，nullable 列允许 NULL
+	if violations := contract.Validate(contractTestEvent(Column{Name: "id", Value: 1}, Column{Name: "amount", IsNull: true}), nil); violations != nil {
+		t.Errorf("expected no violations, got %v", violations)
+	}
+
+	cases := []struct {
+		name  string
+		event *Event
+		want  string
+	}{
+		{"wrong type", contractTestEvent(Column{Name: "id", Value: "1"}), "after_data.id: expected integer, got string"},
+		{"missing column", contractTestEvent(Column{Name: "status", Value: "new"}), "after_data.id: required column is missing"},
+		{"enum", contractTestEvent(Column{Name: "id", Value: 1}, Column{Name: "status", Value: "void"}), `value "void" is not one of`},
+		{"null", contractTestEvent(Column{Name: "id", Value: 1}, Column{Name: "status", IsNull: true}), "after_data.status: null is not allowed"},
+		{"additional column", contractTestEvent(Column{Name: "id", Value: 1}, Column{Name: "note", Value: "x"}), "after_data.note: column is not in the contract"},
+	}
+	for _, c := range cases {
+		violations := contract.Validate(c.event, nil)
+		if len(violations) != 1 || !strings.Contains(violations[0], c.want) {
+			t.Errorf("%s: expected violation %q, got %v", c.name, c.want, violations)
+		}
+	}
+
+	del := &Event{Schema: "shop", Table: "orders", EventType: EventTypeDelete, BeforeData: &RowData{Columns: []Column{{Name: "id", Value: 1}}}}
+	if violations := contract.Validate(del, nil); len(violations) != 1 || !strings.Contains(violations[0], "event type DELETE is not allowed") {
+		t.Errorf("expected event type violation, got %v", violations)
+	}
+
+	// 未列出的表只在 strict 模式下违约
+	other := &Event{Schema: "shop", Table: "users", EventType: EventTypeInsert}
+	if violations := contract.Validate(other, nil); violations != nil {
+		t.Errorf("expected unlisted table to pass, got %v", violations)
+	}
+	contract.Strict = true
+	if violations := contract.Validate(other, nil); len(violations) != 1 {
+		t.Errorf("expected unlisted table to violate a strict contract, got %v", violations)
+	}
+
+	var none *ConsumerContract
+	if none.Validate(other, nil) != nil {
+		t.Error("nil contract should accept every event")
+	}
+}
+
+// TestContractViolationRouting 测试违约的事件不投递，在事件日志中记为 failed 并附带诊断信息
+func TestContractViolationRouting(t *testing.T) {
+	contract, err := ParseConsumerContract(testContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.New(io.Discard, "", 0)
+
+	webhook := NewWebhookHandler("webhook-1", "http://localhost", logger)
+	webhook.SetContract(contract)
+	logs := &fakeEventLogger{}
+	dbHandler := NewDatabaseHandler("db-1", 1, logger, logs, config.DatabaseStorageConfig{Enabled: true, BatchSize: 1})
+	dbHandler.SetContract(webhook)
+
+	bad := contractTestEvent(Column{Name: "id", Value: "abc"})
+	if err := webhook.Handle(context.Background(), bad); err != nil {
+		t.Fatal(err)
+	}
+	if webhook.GetStats()["buffer_size"] != 0 || webhook.GetStats()["contract_violations"] != int64(1) {
+		t.Errorf("expected the violating event to be skipped, got %v", webhook.GetStats())
+	}
+
+	if err := dbHandler.Handle(context.Background(), bad); err != nil {
+		t.Fatal(err)
+	}
+	dbHandler.Close()
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	if len(logs.batches) != 1 || len(logs.batches[0]) != 1 {
+		t.Fatalf("expected one event log entry, got %+v", logs.batches)
+	}
+	entry := logs.batches[0][0]
+	if entry.Status != "failed" || !strings.Contains(entry.Error, "contract violation: after_data.id: expected integer") {
+		t.Errorf("unexpected event log entry %+v", entry)
+	}
+}
+
+// TestContractValidatesDeliveredPayload 测试按实际投递的载荷校验：数值安全编码、载荷映射和 DELETE 投递方式转换后再校验
+func TestContractValidatesDeliveredPayload(t *testing.T) {
+	contract, err := ParseConsumerContract(`{
+		"tables": {
+			"shop.orders": {
+				"additional_columns": false,
+				"columns": {
+					"order_id": {"type": "integer", "required": true},
+					"amount": {"type": "string"}
+				}
+			}
+		}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook := NewWebhookHandler("webhook-1", "http://localhost", log.New(io.Discard, "", 0))
+	webhook.SetContract(contract)
+	event := contractTestEvent(Column{Name: "id", Value: int64(1)}, Column{Name: "amount", Value: decimal.RequireFromString("9.90")})
+
+	// 未映射时载荷中的列名为 id
+	if violations := webhook.CheckContract(event); len(violations) != 2 || !strings.Contains(violations[0], "after_data.id: column is not in the contract") {
+		t.Errorf("expected id to violate the contract without mapping, got %v", violations)
+	}
+	mapping, err := ParsePayloadMapping(`{"rename": {"id": "order_id"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook.SetPayloadMapping(mapping)
+	if violations := webhook.CheckContract(event); violations != nil {
+		t.Errorf("expected the renamed payload to pass, got %v", violations)
+	}
+
+	// 展开行数据后按字段名取值
+	flatten, err := ParsePayloadMapping(`{"rename": {"id": "order_id"}, "flatten": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook.SetPayloadMapping(flatten)
+	if violations := webhook.CheckContract(event); violations != nil {
+		t.Errorf("expected the flattened payload to pass, got %v", violations)
+	}
+
+	// 定点小数序列化为字符串，数值安全编码后 64 位整数也为字符串
+	webhook.SetNumericStrings(true)
+	violations := webhook.CheckContract(event)
+	if len(violations) != 1 || !strings.Contains(violations[0], "after_data.order_id: expected integer, got string") {
+		t.Errorf("expected numeric strings to violate the contract, got %v", violations)
+	}
+	webhook.SetNumericStrings(false)
+
+	// both 方式的原事件和墓碑分别校验，墓碑没有行数据
+	del := &Event{ID: "e2", Schema: "shop", Table: "orders", EventType: EventTypeDelete, PrimaryKey: []string{"id"},
+		BeforeData: &RowData{Columns: []Column{{Name: "id", Value: int64(2)}, {Name: "note", Value: "x"}}}}
+	if err := webhook.SetDeleteMode(DeleteModeBoth); err != nil {
+		t.Fatal(err)
+	}
+	if violations := webhook.CheckContract(del); len(violations) != 1 || !strings.Contains(violations[0], "before_data.note: column is not in the contract") {
+		t.Errorf("expected only the original delete to violate the contract, got %v", violations)
+	}
+	if err := webhook.SetDeleteMode(DeleteModeTombstone); err != nil {
+		t.Fatal(err)
+	}
+	if violations := webhook.CheckContract(del); violations != nil {
+		t.Errorf("expected the tombstone to pass, got %v", violations)
+	}
+}
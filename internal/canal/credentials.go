@@ -0,0 +1,80 @@
+package canal
+
+import (
+	"database/sql"
+	"fmt"
+
+	"pikachun/internal/config"
+)
+
+// SourceCredentials 源库账号
+type SourceCredentials struct {
+	Username string
+	Password string
+}
+
+// ValidateReplicationAccount 用 cfg 中的账号连接源库，检查是否具有读取 binlog 所需的 REPLICATION SLAVE 权限
+func ValidateReplicationAccount(cfg config.CanalConfig) error {
+	dsn := SourceDSN(cfg.Username, cfg.Password, cfg.Host, cfg.Port, "charset=utf8mb4&timeout=5s")
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		return fmt.Errorf("failed to connect to %s:%d as %s: %v", cfg.Host, cfg.Port, cfg.Username, err)
+	}
+
+	rows, err := db.Query("SHOW GRANTS FOR CURRENT_USER()")
+	if err != nil {
+		return fmt.Errorf("failed to query grants: %v", err)
+	}
+	defer rows.Close()
+
+	var grants []string
+	for rows.Next() {
+		var grant string
+		if err := rows.Scan(&grant); err != nil {
+			return fmt.Errorf("failed to scan grants: %v", err)
+		}
+		grants = append(grants, grant)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query grants: %v", err)
+	}
+	return checkReplicationGrants(grants)
+}
+
+// checkReplicationGrants 检查 SHOW GRANTS 的结果中是否有全局的 REPLICATION SLAVE 或 ALL PRIVILEGES
+func checkReplicationGrants(grants []string) error {
+	if hasPrivilege(parseGrants(grants), "REPLICATION SLAVE", "*", "*") {
+		return nil
+	}
+	return fmt.Errorf("account lacks the REPLICATION SLAVE privilege on *.*")
+}
+
+// SetCredentials 切换源库账号：断开当前 binlog 连接，读取循环用新账号从已处理的位置重连
+func (m *MySQLBinlogSlave) SetCredentials(creds SourceCredentials) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.config.Username = creds.Username
+	m.config.Password = creds.Password
+	if !m.running || m.syncer == nil {
+		return
+	}
+
+	m.logger.Printf("🔑 Source credentials of %s rotated to user %s, reconnecting", m.instanceID, creds.Username)
+	m.credentialsRotated = true
+	m.syncer.Close()
+}
+
+// takeCredentialsRotated 读取并清除账号切换标记，binlog 流因切换账号断开时返回 true
+func (m *MySQLBinlogSlave) takeCredentialsRotated() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rotated := m.credentialsRotated
+	m.credentialsRotated = false
+	return rotated
+}
@@ -0,0 +1,32 @@
+package canal
+
+import "testing"
+
+// TestCheckReplicationGrants 测试从 SHOW GRANTS 结果中识别复制权限
+func TestCheckReplicationGrants(t *testing.T) {
+	tests := []struct {
+		name   string
+		grants []string
+		ok     bool
+	}{
+		{"replication slave", []string{"GRANT REPLICATION SLAVE, REPLICATION CLIENT ON *.* TO `repl`@`%`"}, true},
+		{"all privileges", []string{"GRANT ALL PRIVILEGES ON *.* TO `root`@`localhost` WITH GRANT OPTION"}, true},
+		{"lowercase", []string{"grant select, replication slave on *.* to 'repl'@'%'"}, true},
+		{"schema level only", []string{"GRANT USAGE ON *.* TO `app`@`%`", "GRANT ALL PRIVILEGES ON `shop`.* TO `app`@`%`"}, false},
+		{"replication client only", []string{"GRANT REPLICATION CLIENT ON *.* TO `monitor`@`%`"}, false},
+		{"dynamic admin privilege", []string{"GRANT USAGE ON *.* TO `repl`@`%`", "GRANT REPLICATION_SLAVE_ADMIN ON *.* TO `repl`@`%`"}, false},
+		{"no grants", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkReplicationGrants(tt.grants)
+			if tt.ok && err != nil {
+				t.Errorf("expected grants to be accepted, got %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("expected grants to be rejected")
+			}
+		})
+	}
+}
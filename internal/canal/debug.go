@@ -0,0 +1,117 @@
+package canal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// InstanceLabelKey pprof 协程标签名，实例启动的协程（及其派生的协程）都带有该标签，
+// 可按实例统计协程数，也可在 goroutine dump 中区分协程所属实例
+const InstanceLabelKey = "canal_instance"
+
+// QueueReporter 带内部队列的处理器，报告队列当前长度和容量，用于排查卡顿
+type QueueReporter interface {
+	QueueDepth() (length, capacity int)
+}
+
+// QueueDepth 事件日志队列的长度和容量
+func (h *DatabaseHandler) QueueDepth() (int, int) {
+	return len(h.queue), cap(h.queue)
+}
+
+// QueueDepth 批处理缓冲区的事件数和批大小
+func (h *WebhookHandler) QueueDepth() (int, int) {
+	h.bufferMu.Lock()
+	defer h.bufferMu.Unlock()
+	return len(h.eventBuffer), h.batchSize
+}
+
+// DebugInfo 接收器内部状态：事件通道深度和各处理器的队列深度
+func (s *DefaultEventSink) DebugInfo() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	handlers := make(map[string]interface{})
+	for _, subscribed := range s.handlers {
+		for name, handler := range subscribed {
+			if _, ok := handlers[name]; ok {
+				continue
+			}
+			info := map[string]interface{}{}
+			if reporter, ok := handler.(QueueReporter); ok {
+				length, capacity := reporter.QueueDepth()
+				info["queue_length"] = length
+				info["queue_capacity"] = capacity
+			}
+			if buffered, ok := handler.(BufferedHandler); ok {
+				info["buffered_bytes"] = buffered.BufferedBytes()
+			}
+			handlers[name] = info
+		}
+	}
+
+	return map[string]interface{}{
+		"event_channel_length":   len(s.eventCh),
+		"event_channel_capacity": cap(s.eventCh),
+		"queued_bytes":           atomic.LoadInt64(&s.queuedBytes),
+		"started":                s.ctx != nil,
+		"handlers":               handlers,
+	}
+}
+
+// DebugInfo 实例内部状态，用于排查生产环境中的卡顿
+func (c *MySQLCanalInstance) DebugInfo() map[string]interface{} {
+	c.mu.RLock()
+	running := c.running
+	c.mu.RUnlock()
+
+	return map[string]interface{}{
+		"id":           c.id,
+		"running":      running,
+		"event_sink":   c.eventSink.DebugInfo(),
+		"binlog_slave": c.binlogSlave.GetStats(),
+	}
+}
+
+// GoroutinesByInstance 按 InstanceLabelKey 标签统计各实例的协程数，没有标签的协程不计入
+func GoroutinesByInstance() (map[string]int, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return nil, fmt.Errorf("failed to write goroutine profile: %v", err)
+	}
+	return parseGoroutineLabels(&buf), nil
+}
+
+// parseGoroutineLabels 解析 debug=1 格式的 goroutine profile
+// 每条记录以 "N @ 0x..." 开头，带标签时紧跟一行 "# labels: {...}"
+func parseGoroutineLabels(profile *bytes.Buffer) map[string]int {
+	counts := make(map[string]int)
+	count := 0
+	scanner := bufio.NewScanner(profile)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if n, _, ok := strings.Cut(line, " @ "); ok {
+			count, _ = strconv.Atoi(n)
+			continue
+		}
+		labelsJSON, ok := strings.CutPrefix(line, "# labels: ")
+		if !ok {
+			continue
+		}
+		var labels map[string]string
+		if err := json.Unmarshal([]byte(labelsJSON), &labels); err != nil {
+			continue
+		}
+		if instance := labels[InstanceLabelKey]; instance != "" {
+			counts[instance] += count
+		}
+	}
+	return counts
+}
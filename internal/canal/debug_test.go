@@ -0,0 +1,33 @@
+package canal
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+)
+
+// TestGoroutinesByInstance 测试按实例标签统计协程数，带标签启动的协程计入对应实例
+func TestGoroutinesByInstance(t *testing.T) {
+	stop := make(chan struct{})
+	started := make(chan struct{}, 3)
+	pprof.Do(context.Background(), pprof.Labels(InstanceLabelKey, "task-42"), func(ctx context.Context) {
+		for i := 0; i < 3; i++ {
+			go func() {
+				started <- struct{}{}
+				<-stop
+			}()
+		}
+	})
+	defer close(stop)
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+
+	counts, err := GoroutinesByInstance()
+	if err != nil {
+		t.Fatalf("GoroutinesByInstance failed: %v", err)
+	}
+	if counts["task-42"] != 3 {
+		t.Errorf("expected 3 goroutines for task-42, got %d (%v)", counts["task-42"], counts)
+	}
+}
@@ -0,0 +1,87 @@
+package canal
+
+import "fmt"
+
+// DELETE 事件的投递方式，用于 Kafka 压缩主题等按键保存最新值的下游
+const (
+	DeleteModeBefore    = "before"    // 投递带完整删除前镜像的 DELETE 事件（默认）
+	DeleteModeTombstone = "tombstone" // 投递墓碑：只带主键、没有行数据的 DELETE 事件，下游按键删除
+	DeleteModeBoth      = "both"      // 先投递带删除前镜像的 DELETE 事件，再投递墓碑
+)
+
+// tombstoneIDSuffix both 模式下墓碑事件ID的后缀，与同一行的 DELETE 事件区分
+const tombstoneIDSuffix = ":tombstone"
+
+// ParseDeleteMode 校验任务的 DELETE 投递方式，为空时返回默认的 before
+func ParseDeleteMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return DeleteModeBefore, nil
+	case DeleteModeBefore, DeleteModeTombstone, DeleteModeBoth:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported delete mode %q, supported: before, tombstone, both", mode)
+	}
+}
+
+// applyDeleteMode 按 DELETE 投递方式转换一批事件，不需要转换时返回原切片。
+// 没有主键的表无法生成墓碑，照常投递删除前镜像
+func applyDeleteMode(mode string, events []*Event) []*Event {
+	if mode != DeleteModeTombstone && mode != DeleteModeBoth {
+		return events
+	}
+
+	var result []*Event
+	for i, event := range events {
+		tombstone := tombstoneEvent(event)
+		if tombstone == nil {
+			if result != nil {
+				result = append(result, event)
+			}
+			continue
+		}
+		if result == nil {
+			result = make([]*Event, i, len(events)+1)
+			copy(result, events[:i])
+		}
+		if mode == DeleteModeBoth {
+			tombstone.ID += tombstoneIDSuffix
+			result = append(result, event)
+		}
+		result = append(result, tombstone)
+	}
+	if result == nil {
+		return events
+	}
+	return result
+}
+
+// tombstoneEvent 生成 DELETE 事件对应的墓碑，key 为主键列，before_data 和 after_data 为空。
+// 不是 DELETE 事件或没有主键时返回 nil
+func tombstoneEvent(event *Event) *Event {
+	if event.EventType != EventTypeDelete || event.BeforeData == nil || len(event.PrimaryKey) == 0 {
+		return nil
+	}
+
+	key := &RowData{Columns: make([]Column, 0, len(event.PrimaryKey))}
+	for _, name := range event.PrimaryKey {
+		found := false
+		for _, column := range event.BeforeData.Columns {
+			if column.Name == name {
+				key.Columns = append(key.Columns, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+
+	tombstone := *event
+	tombstone.BeforeData = nil
+	tombstone.AfterData = nil
+	tombstone.Key = key
+	tombstone.Tombstone = true
+	return &tombstone
+}
@@ -0,0 +1,114 @@
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// deleteTestEvent 构造 shop.orders 表上主键为 id 的 DELETE 事件
+func deleteTestEvent(id int) *Event {
+	return &Event{
+		ID:         "del",
+		Schema:     "shop",
+		Table:      "orders",
+		EventType:  EventTypeDelete,
+		PrimaryKey: []string{"id"},
+		BeforeData: &RowData{Columns: []Column{{Name: "id", Value: id}, {Name: "tenant_id", Value: 7}}},
+	}
+}
+
+// TestParseDeleteMode 测试 DELETE 投递方式校验
+func TestParseDeleteMode(t *testing.T) {
+	if mode, err := ParseDeleteMode(""); err != nil || mode != DeleteModeBefore {
+		t.Errorf("expected default mode before, got %q, %v", mode, err)
+	}
+	if _, err := ParseDeleteMode("null"); err == nil {
+		t.Error("expected error for unsupported mode")
+	}
+}
+
+// TestApplyDeleteMode 测试各投递方式下 DELETE 事件的转换
+func TestApplyDeleteMode(t *testing.T) {
+	insert := partitionTestEvent("orders", 1, 7)
+	del := deleteTestEvent(2)
+	noPK := &Event{ID: "nopk", EventType: EventTypeDelete, BeforeData: &RowData{Columns: []Column{{Name: "id", Value: 3}}}}
+	events := []*Event{insert, del, noPK}
+
+	if got := applyDeleteMode(DeleteModeBefore, events); &got[0] != &events[0] {
+		t.Error("before mode should return the events unchanged")
+	}
+
+	got := applyDeleteMode(DeleteModeTombstone, events)
+	if len(got) != 3 || got[0] != insert || got[2] != noPK {
+		t.Fatalf("unexpected events %+v", got)
+	}
+	tombstone := got[1]
+	if !tombstone.Tombstone || tombstone.BeforeData != nil || tombstone.AfterData != nil || tombstone.ID != "del" {
+		t.Errorf("unexpected tombstone %+v", tombstone)
+	}
+	if len(tombstone.Key.Columns) != 1 || tombstone.Key.Columns[0].Name != "id" || tombstone.Key.Columns[0].Value != 2 {
+		t.Errorf("unexpected tombstone key %+v", tombstone.Key)
+	}
+	if del.Tombstone || del.BeforeData == nil {
+		t.Error("the original event must not be modified")
+	}
+
+	got = applyDeleteMode(DeleteModeBoth, events)
+	if len(got) != 4 || got[1] != del || !got[2].Tombstone || got[2].ID != "del:tombstone" {
+		t.Errorf("expected the before-image followed by a tombstone, got %+v", got)
+	}
+}
+
+// TestWebhookHandlerTombstone 测试墓碑与同一行的其他事件进入同一分区，载荷中没有行数据
+func TestWebhookHandlerTombstone(t *testing.T) {
+	var mu sync.Mutex
+	payloads := map[string][]map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Events []map[string]interface{} `json:"events"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode payload: %v", err)
+		}
+		mu.Lock()
+		payloads[r.Header.Get("X-Partition-Key")] = append(payloads[r.Header.Get("X-Partition-Key")], payload.Events...)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler("webhook-1", server.URL, log.New(io.Discard, "", 0))
+	router, _ := NewPartitionRouter(PartitionByPK, "", 0)
+	handler.SetPartitionRouter(router)
+	if err := handler.SetDeleteMode(DeleteModeTombstone); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.SetDeleteMode("null"); err == nil {
+		t.Error("expected error for unsupported mode")
+	}
+
+	handler.Handle(context.Background(), partitionTestEvent("orders", 2, 7))
+	handler.Handle(context.Background(), deleteTestEvent(2))
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	events := payloads["shop.orders:2"]
+	if len(payloads) != 1 || len(events) != 2 {
+		t.Fatalf("expected both events in partition shop.orders:2, got %v", payloads)
+	}
+	tombstone := events[1]
+	if tombstone["tombstone"] != true || tombstone["key"] == nil {
+		t.Errorf("unexpected tombstone %v", tombstone)
+	}
+	if _, ok := tombstone["before_data"]; ok {
+		t.Errorf("tombstone should not carry row data: %v", tombstone)
+	}
+}
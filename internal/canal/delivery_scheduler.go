@@ -0,0 +1,175 @@
+package canal
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// DeliveryScheduler 全局的 Webhook 投递并发调度，所有任务共享并发上限
+// 并发已满时按任务优先级加权公平分配（stride 调度）：每次获得并发后任务的进度增加 1/权重，
+// 空出的并发交给进度最小的等待任务，因此一个繁忙的表不会让其他任务一直等待
+type DeliveryScheduler struct {
+	limit int
+
+	mu       sync.Mutex
+	inflight int
+	vtime    float64 // 最近一次调度的进度，新进入等待的任务从这里开始，空闲的任务不会积累额度
+	queues   map[string]*deliveryQueue
+}
+
+// deliveryQueue 一个任务的等待队列
+type deliveryQueue struct {
+	key      string
+	weight   int
+	pass     float64
+	waiters  []chan struct{}
+	inflight int
+	granted  int64
+}
+
+// DeliveryQueueStats 一个任务的调度统计
+type DeliveryQueueStats struct {
+	Key      string `json:"key"`
+	Weight   int    `json:"weight"`
+	InFlight int    `json:"in_flight"`
+	Waiting  int    `json:"waiting"`
+	Granted  int64  `json:"granted"`
+}
+
+// NewDeliveryScheduler 创建投递调度器，limit 不大于 0 时不限制并发，返回 nil
+func NewDeliveryScheduler(limit int) *DeliveryScheduler {
+	if limit <= 0 {
+		return nil
+	}
+	return &DeliveryScheduler{limit: limit, queues: make(map[string]*deliveryQueue)}
+}
+
+// Acquire 为任务获取一个投递并发，返回释放函数；weight 为任务优先级，不大于 0 时按 1 计算
+func (s *DeliveryScheduler) Acquire(ctx context.Context, key string, weight int) (func(), error) {
+	if weight <= 0 {
+		weight = 1
+	}
+
+	s.mu.Lock()
+	q, ok := s.queues[key]
+	if !ok {
+		q = &deliveryQueue{key: key, pass: s.vtime}
+		s.queues[key] = q
+	}
+	q.weight = weight
+	if len(q.waiters) == 0 && q.inflight == 0 && q.pass < s.vtime {
+		q.pass = s.vtime
+	}
+
+	// 有空闲并发且没有其他任务在等待时直接获得
+	if s.inflight < s.limit && !s.hasWaitersLocked() {
+		s.grantLocked(q)
+		s.mu.Unlock()
+		return s.releaseFunc(q), nil
+	}
+
+	ch := make(chan struct{})
+	q.waiters = append(q.waiters, ch)
+	s.mu.Unlock()
+
+	select {
+	case <-ch:
+		return s.releaseFunc(q), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for i, waiter := range q.waiters {
+			if waiter == ch {
+				q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+				s.mu.Unlock()
+				return nil, ctx.Err()
+			}
+		}
+		s.mu.Unlock()
+		// 取消的同时已获得并发，归还
+		s.releaseFunc(q)()
+		return nil, ctx.Err()
+	}
+}
+
+// hasWaitersLocked 是否有任务在等待
+func (s *DeliveryScheduler) hasWaitersLocked() bool {
+	for _, q := range s.queues {
+		if len(q.waiters) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// grantLocked 任务获得一个并发，进度增加 1/权重
+func (s *DeliveryScheduler) grantLocked(q *deliveryQueue) {
+	s.inflight++
+	q.inflight++
+	q.granted++
+	if q.pass > s.vtime {
+		s.vtime = q.pass
+	}
+	q.pass += 1 / float64(q.weight)
+}
+
+// releaseFunc 归还并发并调度等待的任务，多次调用只归还一次
+func (s *DeliveryScheduler) releaseFunc(q *deliveryQueue) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.inflight--
+			q.inflight--
+			s.dispatchLocked()
+		})
+	}
+}
+
+// dispatchLocked 把空闲的并发交给进度最小的等待任务
+func (s *DeliveryScheduler) dispatchLocked() {
+	for s.inflight < s.limit {
+		var next *deliveryQueue
+		for _, q := range s.queues {
+			if len(q.waiters) == 0 {
+				continue
+			}
+			if next == nil || q.pass < next.pass || q.pass == next.pass && q.key < next.key {
+				next = q
+			}
+		}
+		if next == nil {
+			return
+		}
+
+		ch := next.waiters[0]
+		next.waiters = next.waiters[1:]
+		s.grantLocked(next)
+		close(ch)
+	}
+}
+
+// Stats 调度统计
+func (s *DeliveryScheduler) Stats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queues := make([]DeliveryQueueStats, 0, len(s.queues))
+	for _, q := range s.queues {
+		queues = append(queues, DeliveryQueueStats{
+			Key:      q.key,
+			Weight:   q.weight,
+			InFlight: q.inflight,
+			Waiting:  len(q.waiters),
+			Granted:  q.granted,
+		})
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i].Key < queues[j].Key })
+
+	return map[string]interface{}{
+		"max_concurrency": s.limit,
+		"in_flight":       s.inflight,
+		"tasks":           queues,
+	}
+}
@@ -0,0 +1,105 @@
+package canal
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForWaiting 等待调度器中等待的请求数达到 n
+func waitForWaiting(t *testing.T, s *DeliveryScheduler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		waiting := 0
+		for _, q := range s.Stats()["tasks"].([]DeliveryQueueStats) {
+			waiting += q.Waiting
+		}
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d waiters", n)
+}
+
+// TestDeliverySchedulerWeightedFairness 测试并发已满时按权重分配
+func TestDeliverySchedulerWeightedFairness(t *testing.T) {
+	s := NewDeliveryScheduler(1)
+	hold, err := s.Acquire(context.Background(), "webhook-0", 1)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	// noisy 排队 12 个请求，quiet 权重为 3，排队 6 个请求
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	enqueue := func(key string, weight, n int) {
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				release, err := s.Acquire(context.Background(), key, weight)
+				if err != nil {
+					t.Errorf("Acquire failed: %v", err)
+					return
+				}
+				mu.Lock()
+				order = append(order, key)
+				mu.Unlock()
+				release()
+			}()
+		}
+	}
+	enqueue("noisy", 1, 12)
+	waitForWaiting(t, s, 12)
+	enqueue("quiet", 3, 6)
+	waitForWaiting(t, s, 18)
+
+	hold()
+	wg.Wait()
+
+	// 前 8 个并发中 quiet 约占 3/4
+	quiet := 0
+	for _, key := range order[:8] {
+		if key == "quiet" {
+			quiet++
+		}
+	}
+	if quiet != 6 {
+		t.Errorf("expected quiet to get 6 of the first 8 slots, got %d: %v", quiet, order)
+	}
+	if stats := s.Stats(); stats["in_flight"] != 0 {
+		t.Errorf("expected no slots in flight, got %v", stats)
+	}
+}
+
+// TestDeliverySchedulerCancel 测试等待时取消不占用并发
+func TestDeliverySchedulerCancel(t *testing.T) {
+	s := NewDeliveryScheduler(1)
+	hold, _ := s.Acquire(context.Background(), "a", 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Acquire(ctx, "b", 1)
+		done <- err
+	}()
+	waitForWaiting(t, s, 1)
+	cancel()
+	if err := <-done; err == nil {
+		t.Fatal("expected cancelled acquire to fail")
+	}
+
+	hold()
+	release, err := s.Acquire(context.Background(), "c", 1)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	release()
+	if NewDeliveryScheduler(0) != nil {
+		t.Error("expected nil scheduler without limit")
+	}
+}
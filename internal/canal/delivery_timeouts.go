@@ -0,0 +1,55 @@
+package canal
+
+import (
+	"fmt"
+	"time"
+
+	"pikachun/internal/database"
+)
+
+// DeliveryTimeouts Webhook 投递超时配置
+type DeliveryTimeouts struct {
+	Request  time.Duration // 单次 HTTP 请求超时
+	Delivery time.Duration // 一批事件投递的总超时（含重试和退避）
+	Shutdown time.Duration // 停止时刷新缓冲区并等待进行中投递的超时
+}
+
+// DefaultDeliveryTimeouts 默认投递超时
+func DefaultDeliveryTimeouts() DeliveryTimeouts {
+	return DeliveryTimeouts{
+		Request:  30 * time.Second,
+		Delivery: 60 * time.Second,
+		Shutdown: 30 * time.Second,
+	}
+}
+
+// TaskDeliveryTimeouts 获取任务的投递超时，未设置（0）的项使用默认值
+func TaskDeliveryTimeouts(task *database.Task) DeliveryTimeouts {
+	timeouts := DefaultDeliveryTimeouts()
+	if task.RequestTimeout > 0 {
+		timeouts.Request = time.Duration(task.RequestTimeout) * time.Second
+	}
+	if task.DeliveryTimeout > 0 {
+		timeouts.Delivery = time.Duration(task.DeliveryTimeout) * time.Second
+	}
+	if task.ShutdownTimeout > 0 {
+		timeouts.Shutdown = time.Duration(task.ShutdownTimeout) * time.Second
+	}
+	return timeouts
+}
+
+// Validate 校验超时配置之间的关系
+func (t DeliveryTimeouts) Validate() error {
+	if t.Request <= 0 || t.Delivery <= 0 || t.Shutdown <= 0 {
+		return fmt.Errorf("timeouts must be positive")
+	}
+	// 总超时小于单次请求超时时，请求永远无法用满自身的超时
+	if t.Delivery < t.Request {
+		return fmt.Errorf("delivery timeout %v must not be less than request timeout %v", t.Delivery, t.Request)
+	}
+	// 停止时至少要能完成一次请求，否则缓冲区中的事件必然丢失
+	if t.Shutdown < t.Request {
+		return fmt.Errorf("shutdown timeout %v must not be less than request timeout %v", t.Shutdown, t.Request)
+	}
+	return nil
+}
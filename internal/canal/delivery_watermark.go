@@ -0,0 +1,136 @@
+package canal
+
+import (
+	"sync/atomic"
+)
+
+// DeliveryTracker 异步投递的处理器，Handle 返回时事件可能还在缓冲或投递中。
+// DeliveredPosition 在有未投递完成的事件时返回它们之前的位置（之前收到的事件都已投递完成，
+// 投递失败放弃重试的也算完成）和 true，无法确定时位置的 Name 为空；没有未投递完成的事件时返回 false
+type DeliveryTracker interface {
+	DeliveredPosition() (Position, bool)
+}
+
+// CommittedPosition 可以安全持久化的位置：之前的事件都已被所有 sink 投递完成，
+// 进程在任何时刻被杀死后从这里重新读取，事件最多重复投递，不会丢失。
+// 队列、sink 队列和处理器都没有未完成的事件时返回 read（读取到的位置），read 为空时返回最近处理完的事件位置；
+// 无法确定时 Name 为空，调用方不应保存
+func (s *DefaultEventSink) CommittedPosition(read Position) Position {
+	committed := s.basePosition(read)
+
+	// 先看 sink 队列再看处理器：事件交给处理器返回后才出队，不会两边都漏看
+	s.lanesMu.Lock()
+	for _, lane := range s.lanes {
+		if pos, pending := lane.pending(); pending {
+			if pos.Name == "" {
+				s.lanesMu.Unlock()
+				return Position{}
+			}
+			if ComparePositions(pos, committed) < 0 {
+				committed = pos
+			}
+		}
+	}
+	s.lanesMu.Unlock()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[string]bool)
+	for _, handlers := range s.handlers {
+		for name, handler := range handlers {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			tracker, ok := handler.(DeliveryTracker)
+			if !ok {
+				continue
+			}
+			if pos, pending := tracker.DeliveredPosition(); pending {
+				if pos.Name == "" {
+					return Position{}
+				}
+				if ComparePositions(pos, committed) < 0 {
+					committed = pos
+				}
+			}
+		}
+	}
+	return committed
+}
+
+// basePosition 只看处理队列的可保存位置：已分发给所有 sink 的事件之前的位置，队列为空时为 read
+func (s *DefaultEventSink) basePosition(read Position) Position {
+	// 先读队列计数再读处理标记，与处理协程的顺序相反，见 processEvents
+	queued := atomic.LoadInt64(&s.queued) + s.getSpill().Len()
+	busy := queued > 0 || atomic.LoadInt64(&s.processing) != 0
+
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	if busy || s.failure != nil {
+		// 下一个事件可能是同一行事件的其余行，只有 acked 之前的位置是完整的；
+		// 停止处理后读取位置越过了丢失的事件，同样只能保存到这里
+		return s.ackedBefore
+	}
+	if read.Name != "" {
+		return read
+	}
+	return s.acked
+}
+
+// deliveryMark 一次刷新的投递，from 为这批事件之前的位置
+type deliveryMark struct {
+	from Position
+	done bool
+}
+
+// noteReceived 记录收到的事件位置，调用方需持有 bufferMu
+func (h *WebhookHandler) noteReceived(pos Position) {
+	if pos.Name != "" && ComparePositions(pos, h.lastSeen) != 0 {
+		h.seenBefore = h.lastSeen
+		h.lastSeen = pos
+	}
+}
+
+// noteBuffered 缓冲区从空变为非空时记录之前的位置，调用方需持有 bufferMu
+func (h *WebhookHandler) noteBuffered() {
+	if h.eventBufferCount == 0 {
+		h.bufferFrom = h.seenBefore
+	}
+}
+
+// beginDelivery 记录一次刷新开始投递，调用方需持有 bufferMu
+func (h *WebhookHandler) beginDelivery() *deliveryMark {
+	mark := &deliveryMark{from: h.bufferFrom}
+	h.deliveryMu.Lock()
+	h.deliveries = append(h.deliveries, mark)
+	h.deliveryMu.Unlock()
+	return mark
+}
+
+// endDelivery 记录一次刷新投递完成，最早的投递都完成后水位前进
+func (h *WebhookHandler) endDelivery(mark *deliveryMark) {
+	h.deliveryMu.Lock()
+	defer h.deliveryMu.Unlock()
+	mark.done = true
+	for len(h.deliveries) > 0 && h.deliveries[0].done {
+		h.deliveries = h.deliveries[1:]
+	}
+}
+
+// DeliveredPosition 最早一次未完成的投递之前的位置，没有投递中的事件时取缓冲区之前的位置
+func (h *WebhookHandler) DeliveredPosition() (Position, bool) {
+	// 与 flushEvents 相同的加锁顺序，事件从缓冲区转入投递时不会漏看
+	h.bufferMu.Lock()
+	defer h.bufferMu.Unlock()
+	h.deliveryMu.Lock()
+	defer h.deliveryMu.Unlock()
+
+	if len(h.deliveries) > 0 {
+		return h.deliveries[0].from, true
+	}
+	if h.eventBufferCount > 0 {
+		return h.bufferFrom, true
+	}
+	return Position{}, false
+}
@@ -0,0 +1,203 @@
+package canal
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// 事件队列满时的处理方式
+const (
+	OverflowBlock = "block" // 继续等待，binlog 读取随之暂停，不丢事件
+	OverflowDrop  = "drop"  // 丢弃事件并计数
+)
+
+// 事件队列默认配置
+const (
+	defaultEventBufferSize  = 1000
+	defaultEventSendTimeout = 5 * time.Second
+
+	// 自动调整容量的检查间隔，窗口内填充率达到 bufferGrowRatio 时扩容一倍，低于 bufferShrinkRatio 时缩容一半
+	bufferResizeInterval = 10 * time.Second
+	bufferGrowRatio      = 0.9
+	bufferShrinkRatio    = 0.25
+)
+
+// EventBufferPolicy 事件队列的容量和溢出处理
+type EventBufferPolicy struct {
+	Size        int           // 初始容量，也是自动调整的下限
+	MaxSize     int           // 自动调整的上限，不大于 Size 时不调整
+	SendTimeout time.Duration // 队列满时的等待时间
+	Overflow    string        // 等待超时后的处理，见 OverflowBlock
+
+	// 队列满时落盘：SpillMaxBytes 大于 0 时启用，事件写入 SpillDir 中的临时文件
+	SpillDir      string
+	SpillMaxBytes int64
+}
+
+// NewEventBufferPolicy 根据配置创建队列策略，未配置或无效的项使用默认值
+func NewEventBufferPolicy(cfg config.EventBufferConfig) EventBufferPolicy {
+	policy := EventBufferPolicy{
+		Size:        cfg.Size,
+		MaxSize:     cfg.MaxSize,
+		SendTimeout: parseDurationOr(cfg.SendTimeout, defaultEventSendTimeout),
+		Overflow:    strings.ToLower(strings.TrimSpace(cfg.Overflow)),
+	}
+	if policy.Size <= 0 {
+		policy.Size = defaultEventBufferSize
+	}
+	if policy.SendTimeout <= 0 {
+		policy.SendTimeout = defaultEventSendTimeout
+	}
+	if policy.Overflow != OverflowDrop {
+		policy.Overflow = OverflowBlock
+	}
+	if cfg.Spill.Enabled && cfg.Spill.Dir != "" && cfg.Spill.MaxSizeMB > 0 {
+		policy.SpillDir = cfg.Spill.Dir
+		policy.SpillMaxBytes = int64(cfg.Spill.MaxSizeMB * 1024 * 1024)
+	}
+	return policy
+}
+
+// AutoResize 是否按填充率自动调整容量
+func (p EventBufferPolicy) AutoResize() bool {
+	return p.MaxSize > p.Size
+}
+
+// capacity 队列通道的容量，自动调整时按上限分配，实际容量由 limit 控制
+func (p EventBufferPolicy) capacity() int {
+	if p.AutoResize() {
+		return p.MaxSize
+	}
+	return p.Size
+}
+
+// SetBufferPolicy 设置事件队列的容量和溢出处理，需在启动前调用
+func (s *DefaultEventSink) SetBufferPolicy(policy EventBufferPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bufferPolicy = policy
+	if s.ctx == nil && cap(s.eventCh) != policy.capacity() && len(s.eventCh) == 0 {
+		s.eventCh = make(chan queuedEvent, policy.capacity())
+	}
+	atomic.StoreInt64(&s.limit, int64(policy.Size))
+	if policy.SpillMaxBytes > 0 && s.spill == nil {
+		s.spill = newSpillQueue(policy.SpillDir, policy.SpillMaxBytes, s.logger)
+	}
+	s.logger.Printf("🔧 Event queue size: %d (max %d), overflow: %s after %v",
+		policy.Size, policy.capacity(), policy.Overflow, policy.SendTimeout)
+	if s.spill != nil {
+		s.logger.Printf("🔧 Event queue spills to %s when full (quota %d MB)", policy.SpillDir, policy.SpillMaxBytes/1024/1024)
+	}
+}
+
+// notePeak 记录调整窗口内队列中事件数的峰值
+func (s *DefaultEventSink) notePeak(queued int64) {
+	for {
+		peak := atomic.LoadInt64(&s.peakQueued)
+		if queued <= peak || atomic.CompareAndSwapInt64(&s.peakQueued, peak, queued) {
+			return
+		}
+	}
+}
+
+// autoResize 按上一个窗口的填充率峰值调整队列容量，在处理协程中定时调用
+// 窗口内有发送方等待时视为已满
+func (s *DefaultEventSink) autoResize() {
+	s.mu.RLock()
+	policy := s.bufferPolicy
+	s.mu.RUnlock()
+	if !policy.AutoResize() {
+		return
+	}
+
+	limit := atomic.LoadInt64(&s.limit)
+	peak := atomic.SwapInt64(&s.peakQueued, atomic.LoadInt64(&s.queued))
+	waited := atomic.SwapInt64(&s.windowWaits, 0) > 0
+	ratio := float64(peak) / float64(limit)
+
+	newLimit := limit
+	switch {
+	case waited || ratio >= bufferGrowRatio:
+		newLimit = limit * 2
+		if newLimit > int64(policy.MaxSize) {
+			newLimit = int64(policy.MaxSize)
+		}
+	case ratio < bufferShrinkRatio:
+		newLimit = limit / 2
+		if newLimit < int64(policy.Size) {
+			newLimit = int64(policy.Size)
+		}
+	}
+	if newLimit == limit {
+		return
+	}
+
+	atomic.StoreInt64(&s.limit, newLimit)
+	atomic.AddInt64(&s.resizes, 1)
+	s.logger.Printf("📐 Event queue resized from %d to %d (peak fill %.0f%%)", limit, newLimit, ratio*100)
+	// 扩容后唤醒等待的发送方
+	s.signalSpace()
+}
+
+// signalSpace 通知等待的发送方队列有空位
+func (s *DefaultEventSink) signalSpace() {
+	select {
+	case s.space <- struct{}{}:
+	default:
+	}
+}
+
+// recordHandlerLatency 记录处理器处理单个事件的耗时
+func (s *DefaultEventSink) recordHandlerLatency(elapsed time.Duration) {
+	nanos := elapsed.Nanoseconds()
+	atomic.AddInt64(&s.handlerCalls, 1)
+	atomic.AddInt64(&s.handlerNanos, nanos)
+	for {
+		max := atomic.LoadInt64(&s.handlerMaxNanos)
+		if nanos <= max || atomic.CompareAndSwapInt64(&s.handlerMaxNanos, max, nanos) {
+			return
+		}
+	}
+}
+
+// QueueStats 事件队列的填充率、溢出和处理器耗时统计
+func (s *DefaultEventSink) QueueStats() map[string]interface{} {
+	s.mu.RLock()
+	policy := s.bufferPolicy
+	s.mu.RUnlock()
+
+	queued := atomic.LoadInt64(&s.queued)
+	if queued < 0 {
+		queued = 0
+	}
+	limit := atomic.LoadInt64(&s.limit)
+	calls := atomic.LoadInt64(&s.handlerCalls)
+
+	stats := map[string]interface{}{
+		"queued":                 queued,
+		"capacity":               limit,
+		"fill_ratio":             float64(queued) / float64(limit),
+		"overflow":               policy.Overflow,
+		"send_timeout":           policy.SendTimeout.String(),
+		"send_timeouts":          atomic.LoadInt64(&s.sendTimeouts),
+		"dropped_events":         atomic.LoadInt64(&s.droppedEvents),
+		"handler_calls":          calls,
+		"handler_latency_max_ms": float64(atomic.LoadInt64(&s.handlerMaxNanos)) / float64(time.Millisecond),
+	}
+	if calls > 0 {
+		stats["handler_latency_avg_ms"] = float64(atomic.LoadInt64(&s.handlerNanos)) / float64(calls) / float64(time.Millisecond)
+	}
+	if spill := s.getSpill(); spill != nil {
+		stats["spill"] = spill.Stats()
+	}
+	if policy.AutoResize() {
+		stats["min_capacity"] = policy.Size
+		stats["max_capacity"] = policy.MaxSize
+		stats["resizes"] = atomic.LoadInt64(&s.resizes)
+	}
+	return stats
+}
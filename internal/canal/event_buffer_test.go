@@ -0,0 +1,115 @@
+package canal
+
+import (
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// TestNewEventBufferPolicy 测试队列策略的默认值
+func TestNewEventBufferPolicy(t *testing.T) {
+	policy := NewEventBufferPolicy(config.EventBufferConfig{Overflow: "discard", SendTimeout: "oops"})
+	if policy.Size != defaultEventBufferSize || policy.SendTimeout != defaultEventSendTimeout || policy.Overflow != OverflowBlock || policy.AutoResize() {
+		t.Errorf("unexpected default policy: %+v", policy)
+	}
+	policy = NewEventBufferPolicy(config.EventBufferConfig{Size: 100, MaxSize: 400, SendTimeout: "1s", Overflow: "DROP"})
+	if policy.Overflow != OverflowDrop || !policy.AutoResize() || policy.capacity() != 400 {
+		t.Errorf("unexpected policy: %+v", policy)
+	}
+}
+
+// receiveQueued 模拟处理协程取出一个事件
+func receiveQueued(s *DefaultEventSink) {
+	<-s.eventCh
+	atomic.AddInt64(&s.queued, -1)
+	s.signalSpace()
+}
+
+// TestEventSinkOverflowDrop 测试 drop 策略在等待超时后丢弃事件并计数
+func TestEventSinkOverflowDrop(t *testing.T) {
+	s := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	s.SetBufferPolicy(EventBufferPolicy{Size: 2, SendTimeout: 10 * time.Millisecond, Overflow: OverflowDrop})
+
+	for i := 0; i < 3; i++ {
+		if err := s.SendEvent(&Event{ID: "e"}); err != nil {
+			t.Fatalf("expected dropped event not to return an error, got %v", err)
+		}
+	}
+	stats := s.QueueStats()
+	if stats["queued"] != int64(2) || stats["fill_ratio"] != 1.0 || stats["dropped_events"] != int64(1) || stats["send_timeouts"] != int64(1) {
+		t.Errorf("unexpected queue stats: %v", stats)
+	}
+}
+
+// TestEventSinkOverflowBlock 测试 block 策略超时后继续等待，出队后入队成功
+func TestEventSinkOverflowBlock(t *testing.T) {
+	s := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	s.SetBufferPolicy(EventBufferPolicy{Size: 1, SendTimeout: 10 * time.Millisecond, Overflow: OverflowBlock})
+
+	if err := s.SendEvent(&Event{ID: "e1"}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.SendEvent(&Event{ID: "e2"}) }()
+
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("expected send to block on a full queue, got %v", err)
+	default:
+	}
+	if atomic.LoadInt64(&s.sendTimeouts) == 0 {
+		t.Error("expected send timeouts to be counted while blocked")
+	}
+
+	receiveQueued(s)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected send to complete after the queue drained")
+	}
+	if atomic.LoadInt64(&s.droppedEvents) != 0 {
+		t.Error("block policy must not drop events")
+	}
+}
+
+// TestEventSinkAutoResize 测试按填充率扩容和缩容，不超出配置的范围
+func TestEventSinkAutoResize(t *testing.T) {
+	s := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	s.SetBufferPolicy(EventBufferPolicy{Size: 2, MaxSize: 6, SendTimeout: time.Millisecond, Overflow: OverflowDrop})
+
+	for i := 0; i < 3; i++ {
+		s.SendEvent(&Event{ID: "e"})
+	}
+	s.autoResize()
+	if limit := atomic.LoadInt64(&s.limit); limit != 4 {
+		t.Fatalf("expected queue to grow to 4, got %d", limit)
+	}
+	for i := 0; i < 2; i++ {
+		s.SendEvent(&Event{ID: "e"})
+	}
+	s.autoResize()
+	if limit := atomic.LoadInt64(&s.limit); limit != 6 {
+		t.Fatalf("expected queue to grow to the max size 6, got %d", limit)
+	}
+
+	for i := 0; i < 4; i++ {
+		receiveQueued(s)
+	}
+	s.autoResize() // 窗口峰值仍为 4
+	s.autoResize()
+	s.autoResize()
+	if limit := atomic.LoadInt64(&s.limit); limit != 2 {
+		t.Errorf("expected idle queue to shrink back to 2, got %d", limit)
+	}
+	if stats := s.QueueStats(); stats["resizes"] != int64(4) {
+		t.Errorf("unexpected queue stats: %v", stats)
+	}
+}
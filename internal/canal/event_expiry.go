@@ -0,0 +1,116 @@
+package canal
+
+import (
+	"fmt"
+	"time"
+
+	"pikachun/internal/database"
+)
+
+// 超过最大存活时间仍未投递成功的事件的处理方式
+const (
+	ExpiredActionDLQ  = "dlq"  // 记为 failed 进入失败事件列表，可重新投递（默认）
+	ExpiredActionDrop = "drop" // 丢弃，只计数，事件日志记为 expired
+)
+
+// EventExpiry 事件的最大存活时间：投递失败后，存活超过该时间的事件不再重试，
+// 避免消费端长期不可用时陈旧的事件无休止地重试
+type EventExpiry struct {
+	MaxAge time.Duration
+	Action string
+}
+
+// NewEventExpiry 根据任务配置创建事件存活时间，未设置最大存活时间时返回 nil
+func NewEventExpiry(task *database.Task) (*EventExpiry, error) {
+	if task.MaxEventAge < 0 {
+		return nil, fmt.Errorf("max event age must not be negative")
+	}
+	action := task.ExpiredAction
+	switch action {
+	case "":
+		action = ExpiredActionDLQ
+	case ExpiredActionDLQ, ExpiredActionDrop:
+	default:
+		return nil, fmt.Errorf("unsupported expired action %q, supported: dlq, drop", action)
+	}
+	if task.MaxEventAge == 0 {
+		return nil, nil
+	}
+	return &EventExpiry{
+		MaxAge: time.Duration(task.MaxEventAge) * time.Second,
+		Action: action,
+	}, nil
+}
+
+// eventAge 事件的存活时间，从进入处理队列时算起，没有该时间时按 binlog 事件时间
+func eventAge(event *Event, now time.Time) time.Duration {
+	if event.ProcessedMicros > 0 {
+		return now.Sub(time.UnixMicro(event.ProcessedMicros))
+	}
+	return now.Sub(event.Timestamp)
+}
+
+// split 按存活时间拆分事件，保持原有顺序；e 为 nil 时所有事件都未过期
+func (e *EventExpiry) split(events []*Event, now time.Time) (live, expired []*Event) {
+	if e == nil {
+		return events, nil
+	}
+	live = make([]*Event, 0, len(events))
+	for _, event := range events {
+		if eventAge(event, now) > e.MaxAge {
+			expired = append(expired, event)
+		} else {
+			live = append(live, event)
+		}
+	}
+	return live, expired
+}
+
+// ExpiredEventRecorder 记录超过最大存活时间、不再重试的事件，DeliveryRecorder 可选实现。
+// action 为 dlq 时事件进入失败事件列表，为 drop 时只标记为已过期
+type ExpiredEventRecorder interface {
+	RecordExpiredEvents(taskID uint, eventIDs []string, action, reason string) error
+}
+
+// SetEventExpiry 设置事件的最大存活时间，nil 表示不限制，对之后的重试生效
+func (h *WebhookHandler) SetEventExpiry(expiry *EventExpiry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.expiry = expiry
+}
+
+// expireEvents 移除超过最大存活时间的事件并返回其余的事件。过期的事件计入未能投递的事件数，
+// 按任务的处理方式记入失败事件列表或丢弃
+func (h *WebhookHandler) expireEvents(events []*Event) []*Event {
+	h.mu.RLock()
+	expiry, recorder, taskID := h.expiry, h.recorder, h.taskID
+	h.mu.RUnlock()
+
+	live, expired := expiry.split(events, time.Now())
+	if len(expired) == 0 {
+		return events
+	}
+
+	h.mu.Lock()
+	h.expiredEvents += int64(len(expired))
+	h.failedEvents += int64(len(expired))
+	if h.failingSince.IsZero() {
+		h.failingSince = time.Now()
+	}
+	h.mu.Unlock()
+
+	h.logger.Printf("⌛ %d events older than %v expired for handler %s (action: %s)",
+		len(expired), expiry.MaxAge, h.name, expiry.Action)
+
+	if r, ok := recorder.(ExpiredEventRecorder); ok {
+		ids := make([]string, len(expired))
+		for i, event := range expired {
+			ids[i] = event.ID
+		}
+		reason := fmt.Sprintf("expired: not delivered within max event age %v", expiry.MaxAge)
+		if err := r.RecordExpiredEvents(taskID, ids, expiry.Action, reason); err != nil {
+			h.logger.Printf("⚠️ Failed to record expired events for handler %s: %v", h.name, err)
+		}
+	}
+	return live
+}
@@ -0,0 +1,96 @@
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"pikachun/internal/database"
+)
+
+// TestNewEventExpiry 测试事件存活时间的校验和默认处理方式
+func TestNewEventExpiry(t *testing.T) {
+	if expiry, err := NewEventExpiry(&database.Task{}); err != nil || expiry != nil {
+		t.Errorf("expected no expiry by default, got %v, %v", expiry, err)
+	}
+	expiry, err := NewEventExpiry(&database.Task{MaxEventAge: 600})
+	if err != nil || expiry.MaxAge != 10*time.Minute || expiry.Action != ExpiredActionDLQ {
+		t.Errorf("unexpected expiry %+v, %v", expiry, err)
+	}
+	for _, task := range []*database.Task{{MaxEventAge: -1}, {MaxEventAge: 60, ExpiredAction: "archive"}} {
+		if _, err := NewEventExpiry(task); err == nil {
+			t.Errorf("expected %+v to be invalid", task)
+		}
+	}
+}
+
+// expiredEventRecorder 记录过期的事件
+type expiredEventRecorder struct {
+	fakeDeliveryRecorder
+	expired []string
+	action  string
+}
+
+func (r *expiredEventRecorder) RecordExpiredEvents(taskID uint, eventIDs []string, action, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expired = append(r.expired, eventIDs...)
+	r.action = action
+	return nil
+}
+
+// TestWebhookHandlerEventExpiry 测试投递失败后超过存活时间的事件不再重试，其余事件照常重试
+func TestWebhookHandlerEventExpiry(t *testing.T) {
+	var mu sync.Mutex
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Events []Event `json:"events"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		var ids []string
+		for _, event := range payload.Events {
+			ids = append(ids, event.ID)
+		}
+		mu.Lock()
+		requests = append(requests, ids)
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler("webhook-1", server.URL, log.New(io.Discard, "", 0))
+	handler.retryInterval = time.Millisecond
+	handler.maxRetries = 2
+	handler.SetEventExpiry(&EventExpiry{MaxAge: time.Minute, Action: ExpiredActionDrop})
+	recorder := &expiredEventRecorder{}
+	handler.SetDeliveryRecorder(7, recorder)
+
+	now := time.Now()
+	events := []*Event{
+		{ID: "stale", Timestamp: now, ProcessedMicros: now.Add(-time.Hour).UnixMicro()},
+		{ID: "fresh", Timestamp: now.Add(-time.Hour), ProcessedMicros: now.UnixMicro()},
+	}
+	handler.sendEventsWithRetry(context.Background(), deliveryBatch{sequence: 1, events: events})
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := [][]string{{"stale", "fresh"}, {"fresh"}, {"fresh"}}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("expected requests %v, got %v", want, requests)
+	}
+	if !reflect.DeepEqual(recorder.expired, []string{"stale"}) || recorder.action != ExpiredActionDrop {
+		t.Errorf("expected stale to be dropped, got %v (%s)", recorder.expired, recorder.action)
+	}
+	stats := handler.GetStats()
+	if stats["expired_events"] != int64(1) || stats["failed_events"] != int64(2) {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
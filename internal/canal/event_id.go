@@ -0,0 +1,138 @@
+package canal
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 事件ID格式
+const (
+	EventIDFormatPosition = "position" // 基于 binlog 位置，确定性，重放同一位置得到相同ID
+	EventIDFormatULID     = "ulid"     // ULID，按时间排序
+	EventIDFormatUUIDv7   = "uuidv7"   // UUIDv7，按时间排序
+)
+
+// EventKey 事件在数据源中的确定性标识
+type EventKey struct {
+	Source   string // 为空表示 binlog，快照等其他来源需注明以免与 binlog 位置冲突
+	Position Position
+	Row      int // 同一 binlog 事件中的行号
+}
+
+// EventIDGenerator 事件ID生成器
+type EventIDGenerator interface {
+	NextID(key EventKey) string
+	Format() string
+}
+
+// NewEventIDGenerator 根据格式创建事件ID生成器，格式为空时使用 position
+func NewEventIDGenerator(format string) (EventIDGenerator, error) {
+	switch format {
+	case "", EventIDFormatPosition:
+		return positionIDGenerator{}, nil
+	case EventIDFormatULID:
+		return &ulidGenerator{}, nil
+	case EventIDFormatUUIDv7:
+		return uuidV7Generator{}, nil
+	default:
+		return nil, fmt.Errorf("unknown event id format %q, expected %s, %s or %s",
+			format, EventIDFormatPosition, EventIDFormatULID, EventIDFormatUUIDv7)
+	}
+}
+
+// positionIDGenerator 基于 binlog 位置的确定性ID，数字部分补零以便按字典序排序
+type positionIDGenerator struct{}
+
+// NextID 生成事件ID
+func (positionIDGenerator) NextID(key EventKey) string {
+	id := fmt.Sprintf("%s:%010d:%05d", key.Position.Name, key.Position.Pos, key.Row)
+	if key.Source != "" {
+		return key.Source + ":" + id
+	}
+	return id
+}
+
+// Format 获取ID格式
+func (positionIDGenerator) Format() string {
+	return EventIDFormatPosition
+}
+
+// uuidV7Generator UUIDv7 生成器
+type uuidV7Generator struct{}
+
+// NextID 生成事件ID
+func (uuidV7Generator) NextID(key EventKey) string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		// 仅在系统随机源不可用时发生
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
+// Format 获取ID格式
+func (uuidV7Generator) Format() string {
+	return EventIDFormatUUIDv7
+}
+
+// crockfordBase32 ULID 使用的 Crockford Base32 字母表
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidGenerator ULID 生成器，同一毫秒内随机部分递增以保证单调
+type ulidGenerator struct {
+	mu      sync.Mutex
+	lastMs  uint64
+	lastRnd [10]byte
+}
+
+// NextID 生成事件ID
+func (g *ulidGenerator) NextID(key EventKey) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms <= g.lastMs {
+		// 同一毫秒（或时钟回拨）时沿用上一个时间戳并递增随机部分
+		ms = g.lastMs
+		for i := len(g.lastRnd) - 1; i >= 0; i-- {
+			g.lastRnd[i]++
+			if g.lastRnd[i] != 0 {
+				break
+			}
+		}
+	} else {
+		g.lastMs = ms
+		rand.Read(g.lastRnd[:])
+	}
+
+	return encodeULID(ms, g.lastRnd)
+}
+
+// Format 获取ID格式
+func (g *ulidGenerator) Format() string {
+	return EventIDFormatULID
+}
+
+// encodeULID 将 48 位毫秒时间戳和 80 位随机数编码为 26 位字符串
+func encodeULID(ms uint64, rnd [10]byte) string {
+	var raw [16]byte
+	binary.BigEndian.PutUint16(raw[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(raw[2:6], uint32(ms))
+	copy(raw[6:], rnd[:])
+
+	// 128 位按 5 位一组编码，首字符只占 3 位
+	var out [26]byte
+	hi := binary.BigEndian.Uint64(raw[0:8])
+	lo := binary.BigEndian.Uint64(raw[8:16])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordBase32[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
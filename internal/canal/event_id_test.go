@@ -0,0 +1,57 @@
+package canal
+
+import (
+	"regexp"
+	"testing"
+)
+
+// TestEventIDGenerators 测试各种事件ID格式
+func TestEventIDGenerators(t *testing.T) {
+	key := EventKey{Position: Position{Name: "mysql-bin.000003", Pos: 1234}, Row: 2}
+
+	position, err := NewEventIDGenerator("")
+	if err != nil {
+		t.Fatalf("NewEventIDGenerator failed: %v", err)
+	}
+	if got := position.NextID(key); got != "mysql-bin.000003:0000001234:00002" {
+		t.Errorf("unexpected position id %q", got)
+	}
+	if position.NextID(key) != position.NextID(key) {
+		t.Errorf("position ids should be deterministic")
+	}
+	key.Source = "snapshot:testdb.users"
+	if got := position.NextID(key); got != "snapshot:testdb.users:mysql-bin.000003:0000001234:00002" {
+		t.Errorf("unexpected snapshot id %q", got)
+	}
+
+	patterns := map[string]*regexp.Regexp{
+		EventIDFormatULID:   regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`),
+		EventIDFormatUUIDv7: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+	}
+	for format, pattern := range patterns {
+		gen, err := NewEventIDGenerator(format)
+		if err != nil {
+			t.Fatalf("NewEventIDGenerator(%s) failed: %v", format, err)
+		}
+
+		// 连续生成的ID唯一，ULID 按字典序递增
+		prev := ""
+		for i := 0; i < 1000; i++ {
+			id := gen.NextID(key)
+			if !pattern.MatchString(id) {
+				t.Fatalf("%s id %q has invalid format", format, id)
+			}
+			if format == EventIDFormatULID && id <= prev {
+				t.Fatalf("ulid ids not monotonic: %q <= %q", id, prev)
+			}
+			if id == prev {
+				t.Fatalf("%s generated duplicate id %q", format, id)
+			}
+			prev = id
+		}
+	}
+
+	if _, err := NewEventIDGenerator("snowflake"); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
@@ -5,28 +5,97 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"pikachun/internal/config"
 )
 
 // DefaultEventSink 默认事件接收器实现
 type DefaultEventSink struct {
 	mu       sync.RWMutex
 	handlers map[string]map[string]EventHandler // schema.table -> handlerName -> handler
-	eventCh  chan *Event
+	eventCh  chan queuedEvent
 	ctx      context.Context
 	cancel   context.CancelFunc
 	wg       sync.WaitGroup
 	logger   *log.Logger
+
+	logSampler *LogSampler // 逐事件日志的采样，为空时全部输出，启动前设置
+
+	handlerTimeout time.Duration // 单个处理器处理事件的超时
+
+	// 入队前的转换阶段和出队后的过滤阶段，见 eventPipeline
+	pipeline *eventPipeline
+
+	// 按处理器名称设置的过滤条件，可以在订阅前设置，见 SetHandlerEventTypes、SetHandlerRowFilter
+	filters map[string]*subscriptionFilter
+	// 各订阅的统计，键为订阅 ID，见 SubscriptionID
+	subStats map[string]*subscriptionStats
+
+	queuedBytes int64 // 已进入队列、尚未被所有 sink 处理完的事件字节数
+
+	// 事件队列容量和溢出处理，见 EventBufferPolicy
+	bufferPolicy  EventBufferPolicy
+	limit         int64         // 当前容量，自动调整时在 Size 和 MaxSize 之间变化，原子访问
+	queued        int64         // 队列中的事件数，原子访问
+	space         chan struct{} // 出队或扩容时通知等待的发送方
+	peakQueued    int64         // 调整窗口内队列中事件数的峰值，原子访问
+	windowWaits   int64         // 调整窗口内发送方等待的次数，原子访问
+	resizes       int64         // 自动调整容量的次数
+	sendTimeouts  int64         // 发送等待超过 SendTimeout 的次数
+	droppedEvents int64         // drop 策略下丢弃的事件数
+
+	// 队列满时落盘的事件，未启用时为 nil；有落盘事件时新事件也写入磁盘，保持 binlog 顺序
+	spill      *spillQueue
+	spillReady chan struct{} // 落盘后通知处理协程
+
+	// 处理器处理单个事件的耗时，原子访问
+	handlerCalls    int64
+	handlerNanos    int64
+	handlerMaxNanos int64
+
+	// 各 sink 的队列和投递进度，见 sinkLane
+	sinkPolicy SinkPolicy
+	lanesMu    sync.Mutex
+	lanes      map[string]*sinkLane
+	resume     map[string]Position // 重启前各 sink 已处理到的位置，见 SetSinkOffsets
+	laneWg     sync.WaitGroup
+
+	ackMu       sync.Mutex
+	acked       Position // 最近一个已被所有处理器处理完的事件的位置
+	ackedBefore Position // acked 之前的位置，同一行事件的多行位置相同，见 CommittedPosition
+	processing  int64    // 处理协程正在处理出队的事件，原子访问
+
+	// 落盘事件读取失败后停止处理的原因和时间，受 ackMu 保护，见 fail
+	failure  error
+	failedAt time.Time
+}
+
+// queuedEvent 队列中的事件及其入队时估算的大小
+type queuedEvent struct {
+	event *Event
+	size  int64
 }
 
 // NewDefaultEventSink 创建默认事件接收器
 func NewDefaultEventSink(logger *log.Logger) *DefaultEventSink {
-	logger.Printf("🔧 Creating Default Event Sink with buffer size: %d", 1000)
+	policy := NewEventBufferPolicy(config.EventBufferConfig{})
+	logger.Printf("🔧 Creating Default Event Sink with buffer size: %d", policy.Size)
 
 	sink := &DefaultEventSink{
 		handlers: make(map[string]map[string]EventHandler),
-		eventCh:  make(chan *Event, 1000), // 缓冲区大小
+		pipeline: newEventPipeline(),
+		eventCh:  make(chan queuedEvent, policy.capacity()),
 		logger:   logger,
+		space:    make(chan struct{}, 1),
+		limit:    int64(policy.Size),
+
+		spillReady: make(chan struct{}, 1),
+
+		bufferPolicy:   policy,
+		sinkPolicy:     SinkPolicy{QueueSize: defaultSinkQueueSize},
+		handlerTimeout: DefaultDeliveryTimeouts().Delivery,
 	}
 
 	logger.Printf("✅ Default Event Sink created successfully")
@@ -45,11 +114,16 @@ func (s *DefaultEventSink) Start(ctx context.Context) error {
 	}
 
 	s.ctx, s.cancel = context.WithCancel(ctx)
+	// 上次运行留在 sink 队列中的事件从持久化的位置重新读取
+	s.lanesMu.Lock()
+	s.lanes = nil
+	s.resume = nil
+	s.lanesMu.Unlock()
 
 	// 启动事件处理协程
 	s.logger.Printf("🔧 Starting event processing goroutine...")
 	s.wg.Add(1)
-	go s.processEvents()
+	go s.processEvents(s.bufferPolicy.AutoResize())
 
 	s.logger.Printf("✅ Event sink started")
 	return nil
@@ -66,14 +140,147 @@ func (s *DefaultEventSink) Stop() error {
 		s.cancel()
 		s.logger.Printf("🔧 Waiting for goroutines to finish...")
 		s.wg.Wait()
+		// sink 队列中未处理的事件保留，保存的位置不越过它们
+		s.laneWg.Wait()
 		s.cancel = nil
 		s.ctx = nil
 		s.logger.Printf("✅ Goroutines stopped")
 	}
 
+	// 未处理的落盘事件从持久化的位置重新读取
+	if s.spill != nil {
+		if n := s.spill.Len(); n > 0 {
+			s.logger.Printf("💾 Discarding %d spilled events, they will be re-read from the saved position", n)
+		}
+		if err := s.spill.Close(); err != nil {
+			s.logger.Printf("⚠️ Failed to remove spill file: %v", err)
+		}
+	}
+
+	// 关闭处理器，写入剩余数据并停止定时器
+	closed := make(map[EventHandler]bool)
+	for _, handlers := range s.handlers {
+		for name, handler := range handlers {
+			if closed[handler] {
+				continue
+			}
+			closed[handler] = true
+			if err := handler.Close(); err != nil {
+				s.logger.Printf("⚠️ Failed to close handler %s: %v", name, err)
+			}
+		}
+	}
+
 	s.logger.Printf("✅ Event sink stopped")
 	return nil
 }
+
+// SetHandlerTimeout 设置单个处理器处理事件的超时
+func (s *DefaultEventSink) SetHandlerTimeout(timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlerTimeout = timeout
+}
+
+// SetLargeValuePolicy 设置大字段处理策略，nil 表示不处理。事件出队后在处理协程中执行，
+// 外置到对象存储不阻塞 binlog 读取
+func (s *DefaultEventSink) SetLargeValuePolicy(policy *LargeValuePolicy) {
+	if policy == nil {
+		s.pipeline.setDispatch(StageLargeValues, nil)
+		return
+	}
+	s.pipeline.setDispatch(StageLargeValues, policy)
+}
+
+// SetSampler 设置事件采样，未被采样的事件不送往处理器，位置照常确认。nil 表示不采样
+func (s *DefaultEventSink) SetSampler(sampler *EventSampler) {
+	if sampler == nil {
+		s.pipeline.setFilter(StageSampling, nil)
+		return
+	}
+	s.pipeline.setFilter(StageSampling, sampler)
+}
+
+// SetLogSampler 设置逐事件日志的采样，需在启动前设置
+func (s *DefaultEventSink) SetLogSampler(sampler *LogSampler) {
+	s.logSampler = sampler
+}
+
+// SetTransform 设置名为 name 的转换阶段，在内置阶段之后按注册顺序执行，同名时原位替换，nil 停用
+func (s *DefaultEventSink) SetTransform(name string, transform EventTransformer) {
+	s.pipeline.setTransform(name, transform)
+}
+
+// SetFilter 设置名为 name 的过滤阶段，在采样之后按注册顺序执行，同名时原位替换，nil 停用
+func (s *DefaultEventSink) SetFilter(name string, filter EventFilter) {
+	s.pipeline.setFilter(name, filter)
+}
+
+// PipelineStats 各转换和过滤阶段的统计，按执行顺序排列
+func (s *DefaultEventSink) PipelineStats() []StageInfo {
+	return s.pipeline.stats()
+}
+
+// SamplingStats 事件采样统计
+func (s *DefaultEventSink) SamplingStats() map[string]interface{} {
+	var sampler *EventSampler
+	if stage := s.pipeline.stage(StageSampling); stage != nil {
+		sampler, _ = stage.filter.(*EventSampler)
+	}
+	return sampler.Stats()
+}
+
+// LargeValueStats 大字段处理统计
+func (s *DefaultEventSink) LargeValueStats() map[string]interface{} {
+	var policy *LargeValuePolicy
+	if stage := s.pipeline.stage(StageLargeValues); stage != nil {
+		policy, _ = stage.transform.(*LargeValuePolicy)
+	}
+	return policy.Stats()
+}
+
+// MemoryUsage 实例缓冲中的事件估算占用的字节数：接收器队列加上各处理器的缓冲区
+func (s *DefaultEventSink) MemoryUsage() int64 {
+	total := atomic.LoadInt64(&s.queuedBytes)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[string]bool)
+	for _, handlers := range s.handlers {
+		for name, handler := range handlers {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if buffered, ok := handler.(BufferedHandler); ok {
+				total += buffered.BufferedBytes()
+			}
+		}
+	}
+	return total
+}
+
+// PendingEvents 已读取但尚未投递完成的事件数：处理队列和落盘的事件加各 sink 队列、各处理器缓冲和投递中的事件
+func (s *DefaultEventSink) PendingEvents() int64 {
+	total := int64(len(s.eventCh)) + s.getSpill().Len() + s.queuedInLanes()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[string]bool)
+	for _, handlers := range s.handlers {
+		for name, handler := range handlers {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if pending, ok := handler.(PendingHandler); ok {
+				total += pending.PendingEvents()
+			}
+		}
+	}
+	return total
+}
+
 // Subscribe 订阅事件
 func (s *DefaultEventSink) Subscribe(schema, table string, handler EventHandler) error {
 	s.logger.Printf("📋 Subscribing handler %s for %s.%s", handler.GetName(), schema, table)
@@ -87,103 +294,444 @@ func (s *DefaultEventSink) Subscribe(schema, table string, handler EventHandler)
 		s.logger.Printf("🆕 Created new handler map for %s", key)
 	}
 
+	// 同一张表上的处理器以名称区分，名称相同的不同处理器会互相覆盖
+	if existing, exists := s.handlers[key][handler.GetName()]; exists && existing != handler {
+		return fmt.Errorf("subscription %s already exists", SubscriptionID(schema, table, handler.GetName()))
+	}
 	s.handlers[key][handler.GetName()] = handler
+	if s.subStats == nil {
+		s.subStats = make(map[string]*subscriptionStats)
+	}
+	if id := SubscriptionID(schema, table, handler.GetName()); s.subStats[id] == nil {
+		s.subStats[id] = &subscriptionStats{}
+	}
 	s.logger.Printf("✅ Subscribed handler %s for %s", handler.GetName(), key)
 	s.logger.Printf("📊 Total handlers for %s: %d", key, len(s.handlers[key]))
 	return nil
 }
 
-// Unsubscribe 取消订阅
+// Unsubscribe 取消订阅，处理器不再订阅任何表时关闭处理器，写入缓冲的事件并停止定时器
 func (s *DefaultEventSink) Unsubscribe(schema, table string, handlerName string) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	key := fmt.Sprintf("%s.%s", schema, table)
+	var removed EventHandler
 	if handlers, exists := s.handlers[key]; exists {
+		removed = handlers[handlerName]
 		delete(handlers, handlerName)
 		if len(handlers) == 0 {
 			delete(s.handlers, key)
 		}
 	}
+	delete(s.subStats, SubscriptionID(schema, table, handlerName))
+	if removed != nil && s.subscribedLocked(removed) {
+		removed = nil
+	}
+	s.mu.Unlock()
 
 	s.logger.Printf("Unsubscribed handler %s for %s", handlerName, key)
+	// 关闭时会等待进行中的投递，不持有锁
+	if removed != nil {
+		if err := removed.Close(); err != nil {
+			s.logger.Printf("⚠️ Failed to close handler %s: %v", handlerName, err)
+		}
+	}
 	return nil
 }
 
+// subscribedLocked 处理器是否仍订阅了某张表，调用方需持有锁
+func (s *DefaultEventSink) subscribedLocked(handler EventHandler) bool {
+	for _, handlers := range s.handlers {
+		for _, h := range handlers {
+			if h == handler {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MoveHandler 将处理器原子地迁移到新的 schema.table，返回原来订阅的 key
+// 迁移期间不会丢失通道中缓冲的事件
+func (s *DefaultEventSink) MoveHandler(handlerName, schema, table string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newKey := fmt.Sprintf("%s.%s", schema, table)
+	for key, handlers := range s.handlers {
+		handler, exists := handlers[handlerName]
+		if !exists {
+			continue
+		}
+
+		if key != newKey {
+			delete(handlers, handlerName)
+			if len(handlers) == 0 {
+				delete(s.handlers, key)
+			}
+			if s.handlers[newKey] == nil {
+				s.handlers[newKey] = make(map[string]EventHandler)
+			}
+			s.handlers[newKey][handlerName] = handler
+			if stats, ok := s.subStats[key+"/"+handlerName]; ok {
+				delete(s.subStats, key+"/"+handlerName)
+				s.subStats[newKey+"/"+handlerName] = stats
+			}
+			s.logger.Printf("🔀 Moved handler %s from %s to %s", handlerName, key, newKey)
+		}
+		return key, true
+	}
+
+	return "", false
+}
+
+// RemoveHandler 移除处理器在所有表上的订阅，不关闭处理器，返回移除的订阅数
+// 用于实例停止后摘除已关闭的处理器
+func (s *DefaultEventSink) RemoveHandler(handlerName string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for key, handlers := range s.handlers {
+		if _, exists := handlers[handlerName]; !exists {
+			continue
+		}
+		delete(handlers, handlerName)
+		if len(handlers) == 0 {
+			delete(s.handlers, key)
+		}
+		delete(s.subStats, key+"/"+handlerName)
+		removed++
+	}
+	return removed
+}
+
+// GetHandler 根据名称查找处理器
+func (s *DefaultEventSink) GetHandler(handlerName string) (EventHandler, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, handlers := range s.handlers {
+		if handler, exists := handlers[handlerName]; exists {
+			return handler, true
+		}
+	}
+	return nil, false
+}
+
 // SendEvent 发送事件
 func (s *DefaultEventSink) SendEvent(event *Event) error {
-	s.logger.Printf("📤 Sending event to sink: %s.%s %s", event.Schema, event.Table, event.EventType)
-	s.logger.Printf("📋 Event details - ID: %s, Timestamp: %s", event.ID, event.Timestamp.Format(time.RFC3339))
+	s.logSampler.Printf(s.logger, LogPathSend, "📤 Sending event to sink: %s.%s %s", event.Schema, event.Table, event.EventType)
+	s.logSampler.Printf(s.logger, LogPathSend, "📋 Event details - ID: %s, Timestamp: %s", event.ID, event.Timestamp.Format(time.RFC3339))
 
-	select {
-	case s.eventCh <- event:
-		s.logger.Printf("✅ Event sent to channel successfully")
-		return nil
-	case <-time.After(5 * time.Second):
-		s.logger.Printf("❌ Send event timeout after 5 seconds")
-		return fmt.Errorf("send event timeout")
+	s.mu.RLock()
+	bufferPolicy := s.bufferPolicy
+	spill := s.spill
+	var stopped <-chan struct{}
+	if s.ctx != nil {
+		stopped = s.ctx.Done()
+	}
+	s.mu.RUnlock()
+	// 在进入队列前执行转换阶段，所有处理器看到的都是转换后的事件
+	s.pipeline.apply(context.Background(), event)
+	if event.ProcessedMicros == 0 {
+		event.ProcessedMicros = time.Now().UnixMicro()
+	}
+
+	size := EventSize(event)
+	atomic.AddInt64(&s.queuedBytes, size)
+	queued := queuedEvent{event: event, size: size}
+
+	var timer *time.Timer
+	for {
+		// 先占位再入队，队列中的事件数不超过当前容量；有落盘事件时不进入队列，否则会先于落盘的事件处理
+		if spill.Len() == 0 {
+			if n := atomic.AddInt64(&s.queued, 1); n <= atomic.LoadInt64(&s.limit) {
+				select {
+				case s.eventCh <- queued:
+					s.notePeak(n)
+					if timer != nil {
+						timer.Stop()
+					}
+					s.logSampler.Printf(s.logger, LogPathSend, "✅ Event sent to channel successfully")
+					return nil
+				default:
+				}
+			}
+			atomic.AddInt64(&s.queued, -1)
+		}
+		atomic.AddInt64(&s.windowWaits, 1)
+
+		// 队列已满，写入磁盘不必等待；达到磁盘配额或无法编码时照常等待
+		if spill != nil {
+			err := spill.Push(event)
+			if err == nil {
+				atomic.AddInt64(&s.queuedBytes, -size)
+				if timer != nil {
+					timer.Stop()
+				}
+				select {
+				case s.spillReady <- struct{}{}:
+				default:
+				}
+				s.logger.Printf("💾 Event %s spilled to disk", event.ID)
+				return nil
+			}
+			if err != errSpillFull {
+				s.logger.Printf("⚠️ Failed to spill event %s: %v", event.ID, err)
+			}
+		}
+
+		if timer == nil {
+			timer = time.NewTimer(bufferPolicy.SendTimeout)
+		}
+		select {
+		case <-s.space:
+		case <-timer.C:
+			atomic.AddInt64(&s.sendTimeouts, 1)
+			if bufferPolicy.Overflow == OverflowDrop {
+				atomic.AddInt64(&s.queuedBytes, -size)
+				atomic.AddInt64(&s.droppedEvents, 1)
+				// 丢弃不返回错误，同一行事件中的其余行照常处理
+				s.logger.Printf("❌ Event queue full for %v, dropped event %s (%s.%s %s)",
+					bufferPolicy.SendTimeout, event.ID, event.Schema, event.Table, event.EventType)
+				return nil
+			}
+			s.logger.Printf("⚠️ Event queue full for %v, still waiting to enqueue event %s", bufferPolicy.SendTimeout, event.ID)
+			timer.Reset(bufferPolicy.SendTimeout)
+		case <-stopped:
+			timer.Stop()
+			atomic.AddInt64(&s.queuedBytes, -size)
+			return fmt.Errorf("event sink stopped")
+		}
 	}
 }
-// processEvents 处理事件
-func (s *DefaultEventSink) processEvents() {
+// processEvents 处理事件，autoResize 时定时按填充率调整队列容量
+func (s *DefaultEventSink) processEvents(autoResize bool) {
 	s.logger.Printf("👀 Starting event processing goroutine")
 	defer s.wg.Done()
 	defer s.logger.Printf("👋 Event processing goroutine stopped")
 
+	var resizeTick <-chan time.Time
+	if autoResize {
+		ticker := time.NewTicker(bufferResizeInterval)
+		defer ticker.Stop()
+		resizeTick = ticker.C
+	}
+
 	for {
 		select {
 		case <-s.ctx.Done():
 			s.logger.Printf("🛑 Event processing context cancelled")
 			return
-		case event := <-s.eventCh:
-			s.logger.Printf("📥 Received event from channel: %s.%s %s",
-				event.Schema, event.Table, event.EventType)
-			s.logger.Printf("📋 Event details - ID: %s, Timestamp: %s", event.ID, event.Timestamp.Format(time.RFC3339))
-			s.handleEvent(event)
-			s.logger.Printf("✅ Event processing completed")
+		case <-resizeTick:
+			s.autoResize()
+		case queued := <-s.eventCh:
+			// 先标记处理中再减少队列计数，CommittedPosition 不会看到事件既不在队列也不在处理中
+			atomic.StoreInt64(&s.processing, 1)
+			atomic.AddInt64(&s.queued, -1)
+			s.signalSpace()
+			s.logSampler.Printf(s.logger, LogPathDispatch, "📥 Received event from channel: %s.%s %s",
+				queued.event.Schema, queued.event.Table, queued.event.EventType)
+			s.processQueued(queued)
+		case <-s.spillReady:
+			s.drainSpill(resizeTick)
 		}
 	}
 }
 
-// handleEvent 处理单个事件
-func (s *DefaultEventSink) handleEvent(event *Event) {
-	s.logger.Printf("🔧 Handling event: %s.%s %s", event.Schema, event.Table, event.EventType)
-	s.logger.Printf("📋 Event details - ID: %s, Timestamp: %s", event.ID, event.Timestamp.Format(time.RFC3339))
+// processQueued 处理一个出队的事件，调用方已标记处理中
+func (s *DefaultEventSink) processQueued(queued queuedEvent) {
+	event := queued.event
+	if s.Err() != nil {
+		// 已停止处理，事件不投递也不确认，重启后从已提交的位置重新读取
+		atomic.AddInt64(&s.queuedBytes, -queued.size)
+		atomic.StoreInt64(&s.processing, 0)
+		return
+	}
+	s.logSampler.Printf(s.logger, LogPathDispatch, "📋 Event details - ID: %s, Timestamp: %s", event.ID, event.Timestamp.Format(time.RFC3339))
+	// 过滤阶段在出队时按 binlog 顺序判断，采样按主键限频依赖事件顺序
+	if keep, stage := s.pipeline.keep(event); keep {
+		// ctx 在处理协程启动前设置，停止时等处理协程退出后才清空
+		ctx := s.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if s.pipeline.applyDispatch(ctx, event) && ctx.Err() != nil {
+			// 停止时出队转换可能被中断（如外置未完成），事件不投递也不确认，从持久化的位置重新读取
+			atomic.AddInt64(&s.queuedBytes, -queued.size)
+			atomic.StoreInt64(&s.processing, 0)
+			return
+		}
+		s.handleEvent(event, queued.size)
+	} else {
+		s.logSampler.Printf(s.logger, LogPathDispatch, "🎲 Event %s skipped by %s", event.ID, stage)
+		atomic.AddInt64(&s.queuedBytes, -queued.size)
+	}
+	s.ack(event.Position)
+	atomic.StoreInt64(&s.processing, 0)
+	s.logSampler.Printf(s.logger, LogPathDispatch, "✅ Event processing completed")
+}
+
+// drainSpill 按顺序处理落盘的事件，直到读空。队列中剩余的事件早于所有落盘的事件，先处理完
+func (s *DefaultEventSink) drainSpill(resizeTick <-chan time.Time) {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-resizeTick:
+			s.autoResize()
+		case queued := <-s.eventCh:
+			atomic.StoreInt64(&s.processing, 1)
+			atomic.AddInt64(&s.queued, -1)
+			s.signalSpace()
+			s.processQueued(queued)
+			continue
+		default:
+		}
+
+		// 与队列相同，先标记处理中再读取，CommittedPosition 不会漏看落盘的事件
+		atomic.StoreInt64(&s.processing, 1)
+		event, ok, err := s.spill.Pop()
+		if !ok {
+			atomic.StoreInt64(&s.processing, 0)
+			return
+		}
+		// 落盘有空间后唤醒达到配额而等待的发送方
+		s.signalSpace()
+		if err != nil {
+			// 读取文件失败时未读取的事件都已丢弃，继续处理会把已提交位置推过它们
+			s.fail(err)
+			atomic.StoreInt64(&s.processing, 0)
+			s.logger.Printf("❌ Lost spilled events, event sink stopped until the instance restarts from the committed position: %v", err)
+			continue
+		}
+		size := EventSize(event)
+		atomic.AddInt64(&s.queuedBytes, size)
+		s.logSampler.Printf(s.logger, LogPathDispatch, "📥 Received spilled event: %s.%s %s", event.Schema, event.Table, event.EventType)
+		s.processQueued(queuedEvent{event: event, size: size})
+	}
+}
 
+// getSpill 落盘队列，未启用时为 nil
+func (s *DefaultEventSink) getSpill() *spillQueue {
 	s.mu.RLock()
-	key := fmt.Sprintf("%s.%s", event.Schema, event.Table)
-	s.logger.Printf("📋 Looking up handlers for %s", key)
+	defer s.mu.RUnlock()
+	return s.spill
+}
+
+// ack 记录已处理完的事件位置，事件按 binlog 顺序处理，位置只会前进
+func (s *DefaultEventSink) ack(pos Position) {
+	if pos.Name == "" {
+		return
+	}
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	if ComparePositions(pos, s.acked) > 0 {
+		s.ackedBefore = s.acked
+		s.acked = pos
+	}
+}
+
+// fail 停止处理事件：之后出队的事件不再分发和确认，已提交位置停在丢失的事件之前，
+// 实例重启后从保存的位置重新读取，丢失的事件不会被跳过
+func (s *DefaultEventSink) fail(err error) {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	if s.failure == nil {
+		s.failure = err
+		s.failedAt = time.Now()
+	}
+}
+
+// Err 事件接收器因落盘事件读取失败停止处理时返回原因，需要重启实例从已提交的位置重新读取
+func (s *DefaultEventSink) Err() error {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	return s.failure
+}
+
+// FailedAt 停止处理的时间，未停止时为零值
+func (s *DefaultEventSink) FailedAt() time.Time {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	return s.failedAt
+}
+
+// resetAck 清除已处理位置，binlog 位置被回退（如从最早的 binlog 恢复）后调用
+func (s *DefaultEventSink) resetAck() {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	s.acked = Position{}
+	s.ackedBefore = Position{}
+	s.clearSinkOffsets()
+}
+
+// AckedPosition 最近一个已分发给所有 sink 的事件的位置，尚无事件时 Name 为空
+func (s *DefaultEventSink) AckedPosition() Position {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	return s.acked
+}
+
+// Route 路由阶段：订阅了事件所在库表、且通过该处理器过滤条件的处理器
+func (s *DefaultEventSink) Route(event *Event) []Route {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	handlers := make(map[string]EventHandler)
-	if h, exists := s.handlers[key]; exists {
-		for name, handler := range h {
-			handlers[name] = handler
+	key := fmt.Sprintf("%s.%s", event.Schema, event.Table)
+	s.logSampler.Printf(s.logger, LogPathDispatch, "📋 Looking up handlers for %s", key)
+	var routes []Route
+	for name, handler := range s.handlers[key] {
+		stat := s.subStats[key+"/"+name]
+		if filter := s.filters[name]; filter != nil && !filter.match(event) {
+			stat.addFiltered()
+			continue
 		}
+		routes = append(routes, Route{Name: name, Handler: handler, stats: stat})
 	}
-	s.mu.RUnlock()
+	return routes
+}
 
-	s.logger.Printf("📊 Found %d handlers for event", len(handlers))
+// handleEvent 处理单个事件：路由后放入各 sink 的队列，不等待处理完成。size 为事件占用的队列字节数，
+// 所有 sink 处理完后释放
+func (s *DefaultEventSink) handleEvent(event *Event, size int64) {
+	s.logSampler.Printf(s.logger, LogPathDispatch, "🔧 Handling event: %s.%s %s", event.Schema, event.Table, event.EventType)
+	s.logSampler.Printf(s.logger, LogPathDispatch, "📋 Event details - ID: %s, Timestamp: %s", event.ID, event.Timestamp.Format(time.RFC3339))
 
-	// 并发处理所有订阅的处理器
-	var wg sync.WaitGroup
-	for name, handler := range handlers {
-		s.logger.Printf("🚀 Starting handler %s for event", name)
-		wg.Add(1)
-		go func(name string, handler EventHandler) {
-			defer wg.Done()
-			s.logger.Printf("🔄 Handler %s started processing event", name)
+	routes := s.Route(event)
+	s.logSampler.Printf(s.logger, LogPathDispatch, "📊 Found %d handlers for event", len(routes))
+	s.dispatch(&sinkEvent{event: event, size: size, from: s.positionBefore(event.Position)}, routes)
+}
 
-			ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
-			defer cancel()
+// dispatch 把事件放入路由选出的各 sink 的队列，跳过 sink 重启前已处理过的事件，队列满时等待
+func (s *DefaultEventSink) dispatch(item *sinkEvent, routes []Route) {
+	s.mu.RLock()
+	ctx := s.ctx
+	handlerTimeout := s.handlerTimeout
+	capacity := s.sinkPolicy.QueueSize
+	s.mu.RUnlock()
 
-			if err := handler.Handle(ctx, event); err != nil {
-				s.logger.Printf("❌ Handler %s failed to process event %s: %v", name, event.ID, err)
-			} else {
-				s.logger.Printf("✅ Handler %s completed processing event", name)
-			}
-		}(name, handler)
+	lanes := make([]*sinkLane, 0, len(routes))
+	targets := make([]Route, 0, len(routes))
+	for _, route := range routes {
+		lane := s.lane(ctx, route.Name)
+		if lane.skip(item.event.Position) {
+			s.logSampler.Printf(s.logger, LogPathDispatch, "⏭️ Sink %s already handled event %s before restart, skipped", route.Name, item.event.ID)
+			continue
+		}
+		lanes = append(lanes, lane)
+		targets = append(targets, route)
+	}
+	if len(lanes) == 0 {
+		atomic.AddInt64(&s.queuedBytes, -item.size)
+		return
 	}
 
-	wg.Wait()
-	s.logger.Printf("🎉 All handlers completed for event")
+	item.remaining = int32(len(lanes))
+	for i, lane := range lanes {
+		if lane.push(ctx, laneItem{sinkEvent: item, route: targets[i], timeout: handlerTimeout}, capacity) {
+			s.logger.Printf("⏳ Sink %s queue was full, event %s waited for it", lane.name, item.event.ID)
+		}
+	}
+	s.logSampler.Printf(s.logger, LogPathDispatch, "📨 Event queued for %d sinks", len(lanes))
 }
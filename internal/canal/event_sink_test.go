@@ -10,6 +10,7 @@ package canal
 
 import (
 	"context"
+	"io"
 	"log"
 	"os"
 	"testing"
@@ -70,6 +71,169 @@ func TestEventSinkSubscribeLogging(t *testing.T) {
 	}
 }
 
+// TestEventSinkMoveHandler 测试处理器迁移到新表
+func TestEventSinkMoveHandler(t *testing.T) {
+	logger := log.New(os.Stdout, "[TestEventSinkMoveHandler] ", log.LstdFlags|log.Lshortfile)
+	eventSink := NewDefaultEventSink(logger)
+
+	handler := &testEventHandler{name: "webhook-1"}
+	if err := eventSink.Subscribe("test", "users", handler); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	oldKey, ok := eventSink.MoveHandler("webhook-1", "test", "orders")
+	if !ok || oldKey != "test.users" {
+		t.Fatalf("Unexpected move result: %s, %v", oldKey, ok)
+	}
+
+	if _, exists := eventSink.handlers["test.users"]; exists {
+		t.Errorf("Expected old subscription to be removed")
+	}
+	if eventSink.handlers["test.orders"]["webhook-1"] != handler {
+		t.Errorf("Expected handler to be subscribed to new table")
+	}
+
+	if _, ok := eventSink.MoveHandler("missing", "test", "orders"); ok {
+		t.Errorf("Expected move of unknown handler to fail")
+	}
+}
+
+// TestEventSinkHandlerEventTypes 测试按处理器过滤事件类型，未设置的处理器接收所有类型
+func TestEventSinkHandlerEventTypes(t *testing.T) {
+	eventSink := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	eventSink.ctx = context.Background()
+
+	deletes := &countingEventHandler{name: "webhook-1"}
+	all := &countingEventHandler{name: "webhook-2"}
+	eventSink.Subscribe("shop", "orders", deletes)
+	eventSink.Subscribe("shop", "orders", all)
+	eventSink.SetHandlerEventTypes("webhook-1", []EventType{EventTypeDelete})
+
+	for _, eventType := range []EventType{EventTypeInsert, EventTypeUpdate, EventTypeDelete} {
+		eventSink.handleEvent(&Event{ID: string(eventType), Schema: "shop", Table: "orders", EventType: eventType}, 0)
+	}
+	waitSinkLanes(t, eventSink)
+	if deletes.count != 1 || all.count != 3 {
+		t.Errorf("expected 1 and 3 events, got %d and %d", deletes.count, all.count)
+	}
+
+	// 清除过滤后接收所有类型
+	eventSink.SetHandlerEventTypes("webhook-1", nil)
+	eventSink.handleEvent(&Event{ID: "i2", Schema: "shop", Table: "orders", EventType: EventTypeInsert}, 0)
+	waitSinkLanes(t, eventSink)
+	if deletes.count != 2 {
+		t.Errorf("expected filter to be cleared, got %d events", deletes.count)
+	}
+}
+
+// TestEventSinkSubscriptions 测试同一张表上多个任务的订阅：名称冲突、行过滤和订阅统计
+func TestEventSinkSubscriptions(t *testing.T) {
+	eventSink := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	eventSink.ctx = context.Background()
+
+	paid := &countingEventHandler{name: "webhook-1"}
+	all := &countingEventHandler{name: "webhook-2"}
+	if err := eventSink.Subscribe("shop", "orders", paid); err != nil {
+		t.Fatal(err)
+	}
+	if err := eventSink.Subscribe("shop", "orders", all); err != nil {
+		t.Fatal(err)
+	}
+	// 重复订阅同一处理器不报错，同名的其他处理器不能覆盖已有订阅
+	if err := eventSink.Subscribe("shop", "orders", paid); err != nil {
+		t.Errorf("expected re-subscribing the same handler to succeed, got %v", err)
+	}
+	if err := eventSink.Subscribe("shop", "orders", &countingEventHandler{name: "webhook-1"}); err == nil {
+		t.Error("expected error for a different handler with the same name")
+	}
+
+	filter, err := ParseRowFilter("status = 'paid'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventSink.SetHandlerRowFilter("webhook-1", filter)
+	eventSink.SetHandlerEventTypes("webhook-1", []EventType{EventTypeUpdate})
+
+	for _, status := range []string{"new", "paid"} {
+		eventSink.handleEvent(&Event{ID: status, Schema: "shop", Table: "orders", EventType: EventTypeUpdate,
+			AfterData: &RowData{Columns: []Column{{Name: "status", Value: status}}}}, 0)
+	}
+	waitSinkLanes(t, eventSink)
+	if paid.count != 1 || all.count != 2 {
+		t.Errorf("expected 1 and 2 events, got %d and %d", paid.count, all.count)
+	}
+
+	subscriptions := eventSink.Subscriptions()
+	if len(subscriptions) != 2 || subscriptions[0].ID != "shop.orders/webhook-1" {
+		t.Fatalf("unexpected subscriptions: %+v", subscriptions)
+	}
+	first := subscriptions[0]
+	if first.Delivered != 1 || first.Filtered != 1 || first.RowFilter != "status = 'paid'" || len(first.EventTypes) != 1 {
+		t.Errorf("unexpected subscription info: %+v", first)
+	}
+	if subscriptions[1].Delivered != 2 || subscriptions[1].Filtered != 0 {
+		t.Errorf("unexpected subscription info: %+v", subscriptions[1])
+	}
+
+	// 迁移后统计跟随订阅，取消订阅后删除
+	eventSink.MoveHandler("webhook-1", "shop", "payments")
+	if subscriptions := eventSink.Subscriptions(); subscriptions[1].ID != "shop.payments/webhook-1" || subscriptions[1].Delivered != 1 {
+		t.Errorf("expected stats to follow the moved subscription, got %+v", subscriptions)
+	}
+	eventSink.Unsubscribe("shop", "payments", "webhook-1")
+	if _, exists := eventSink.subStats["shop.payments/webhook-1"]; exists {
+		t.Error("expected stats to be removed with the subscription")
+	}
+}
+
+// TestEventSinkUnsubscribeClosesHandler 测试处理器不再订阅任何表时取消订阅会关闭处理器
+func TestEventSinkUnsubscribeClosesHandler(t *testing.T) {
+	eventSink := NewDefaultEventSink(log.New(io.Discard, "", 0))
+
+	handler := &countingEventHandler{name: "webhook-1"}
+	eventSink.Subscribe("shop", "orders", handler)
+	eventSink.Subscribe("shop", "payments", handler)
+
+	eventSink.Unsubscribe("shop", "orders", "webhook-1")
+	if handler.closed != 0 {
+		t.Errorf("handler still subscribed to shop.payments must not be closed")
+	}
+	eventSink.Unsubscribe("shop", "payments", "webhook-1")
+	if handler.closed != 1 {
+		t.Errorf("expected handler to be closed once, got %d", handler.closed)
+	}
+	// 未订阅的处理器不会重复关闭
+	eventSink.Unsubscribe("shop", "payments", "webhook-1")
+	if handler.closed != 1 {
+		t.Errorf("expected handler to be closed once, got %d", handler.closed)
+	}
+}
+
+// countingEventHandler 统计收到的事件数和关闭次数
+type countingEventHandler struct {
+	name   string
+	count  int
+	closed int
+}
+
+func (h *countingEventHandler) Handle(ctx context.Context, event *Event) error {
+	h.count++
+	return nil
+}
+
+func (h *countingEventHandler) GetName() string {
+	return h.name
+}
+
+func (h *countingEventHandler) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (h *countingEventHandler) Close() error {
+	h.closed++
+	return nil
+}
+
 // testEventHandler 简单的事件处理器实现
 type testEventHandler struct {
 	name string
@@ -82,3 +246,11 @@ func (h *testEventHandler) Handle(ctx context.Context, event *Event) error {
 func (h *testEventHandler) GetName() string {
 	return h.name
 }
+
+func (h *testEventHandler) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (h *testEventHandler) Close() error {
+	return nil
+}
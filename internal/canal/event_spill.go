@@ -0,0 +1,188 @@
+package canal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func init() {
+	// 列值为 interface{}，基本类型之外的值类型需要注册才能编码
+	gob.Register(time.Time{})
+	gob.Register(decimal.Decimal{})
+	gob.Register(json.Number(""))
+}
+
+// errSpillFull 落盘队列达到磁盘配额
+var errSpillFull = errors.New("spill queue disk quota exceeded")
+
+// spillRecordHeader 每条记录前的长度字段字节数
+const spillRecordHeader = 4
+
+// spillQueue 事件队列满时落盘的事件，按写入顺序读取。
+// 文件在首次落盘时创建，读空后截断释放磁盘空间；内容只在进程内有效，
+// 进程退出后未处理的事件从持久化的 binlog 位置重新读取
+type spillQueue struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	logger   *log.Logger
+
+	file     *os.File
+	readOff  int64
+	writeOff int64
+
+	count     int64 // 未读取的事件数，原子访问
+	spilled   int64 // 累计落盘的事件数
+	rejected  int64 // 达到磁盘配额未能落盘的次数
+	discarded int64 // 读取失败丢弃的事件数
+	peakBytes int64 // 文件大小的峰值
+}
+
+// newSpillQueue 创建落盘队列，文件写在 dir 中，大小不超过 maxBytes
+func newSpillQueue(dir string, maxBytes int64, logger *log.Logger) *spillQueue {
+	return &spillQueue{dir: dir, maxBytes: maxBytes, logger: logger}
+}
+
+// Len 未读取的事件数
+func (q *spillQueue) Len() int64 {
+	if q == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&q.count)
+}
+
+// Push 写入一个事件，达到磁盘配额时返回 errSpillFull，值无法编码时返回编码错误
+func (q *spillQueue) Push(event *Event) error {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, spillRecordHeader))
+	if err := gob.NewEncoder(&buf).Encode(event); err != nil {
+		return fmt.Errorf("failed to encode event %s: %v", event.ID, err)
+	}
+	record := buf.Bytes()
+	binary.BigEndian.PutUint32(record, uint32(len(record)-spillRecordHeader))
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.writeOff+int64(len(record)) > q.maxBytes {
+		q.rejected++
+		return errSpillFull
+	}
+	if q.file == nil {
+		if err := os.MkdirAll(q.dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create spill directory: %v", err)
+		}
+		file, err := os.CreateTemp(q.dir, "events-*.spill")
+		if err != nil {
+			return fmt.Errorf("failed to create spill file: %v", err)
+		}
+		q.file = file
+		q.logger.Printf("💾 Event queue full, spilling events to %s", file.Name())
+	}
+	if _, err := q.file.WriteAt(record, q.writeOff); err != nil {
+		return fmt.Errorf("failed to write spill file: %v", err)
+	}
+
+	q.writeOff += int64(len(record))
+	if q.writeOff > q.peakBytes {
+		q.peakBytes = q.writeOff
+	}
+	q.spilled++
+	atomic.AddInt64(&q.count, 1)
+	return nil
+}
+
+// Pop 读取最早的事件，没有事件时返回 false。解码失败时跳过该事件，读取文件失败时丢弃所有未读取的事件，
+// 两者都返回 true 和错误；读取失败后调用方不能再确认之后的位置，见 DefaultEventSink.fail
+func (q *spillQueue) Pop() (*Event, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.file == nil || q.readOff >= q.writeOff {
+		return nil, false, nil
+	}
+
+	var header [spillRecordHeader]byte
+	if _, err := q.file.ReadAt(header[:], q.readOff); err != nil {
+		q.resetLocked()
+		return nil, true, fmt.Errorf("failed to read spill file: %v", err)
+	}
+	record := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := q.file.ReadAt(record, q.readOff+spillRecordHeader); err != nil && err != io.EOF {
+		q.resetLocked()
+		return nil, true, fmt.Errorf("failed to read spill file: %v", err)
+	}
+	q.readOff += spillRecordHeader + int64(len(record))
+	atomic.AddInt64(&q.count, -1)
+
+	var event Event
+	err := gob.NewDecoder(bytes.NewReader(record)).Decode(&event)
+
+	// 读空后截断文件，下次落盘从头写入
+	if q.readOff >= q.writeOff {
+		if truncErr := q.file.Truncate(0); truncErr != nil {
+			q.logger.Printf("⚠️ Failed to truncate spill file: %v", truncErr)
+		}
+		q.readOff, q.writeOff = 0, 0
+		q.logger.Printf("💾 Spilled events drained")
+	}
+	if err != nil {
+		q.discarded++
+		return nil, true, fmt.Errorf("failed to decode spilled event: %v", err)
+	}
+	return &event, true, nil
+}
+
+// resetLocked 丢弃所有未读取的事件，调用方需持有锁
+func (q *spillQueue) resetLocked() {
+	if q.file != nil {
+		q.file.Truncate(0)
+	}
+	q.readOff, q.writeOff = 0, 0
+	q.discarded += atomic.SwapInt64(&q.count, 0)
+}
+
+// Close 删除落盘文件，未读取的事件被丢弃，之后仍可继续使用
+func (q *spillQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.file == nil {
+		return nil
+	}
+	name := q.file.Name()
+	q.file.Close()
+	q.file = nil
+	q.readOff, q.writeOff = 0, 0
+	atomic.StoreInt64(&q.count, 0)
+	return os.Remove(name)
+}
+
+// Stats 落盘队列统计
+func (q *spillQueue) Stats() map[string]interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return map[string]interface{}{
+		"dir":             q.dir,
+		"queued":          atomic.LoadInt64(&q.count),
+		"disk_bytes":      q.writeOff,
+		"max_bytes":       q.maxBytes,
+		"peak_bytes":      q.peakBytes,
+		"spilled_events":  q.spilled,
+		"quota_exhausted": q.rejected,
+		"discarded":       q.discarded,
+	}
+}
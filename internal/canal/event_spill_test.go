@@ -0,0 +1,211 @@
+package canal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// TestSpillQueueRoundTrip 测试事件落盘后按顺序读回，列值类型不变，读空后截断文件
+func TestSpillQueueRoundTrip(t *testing.T) {
+	q := newSpillQueue(t.TempDir(), 1<<20, log.New(io.Discard, "", 0))
+	defer q.Close()
+
+	at := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	first := &Event{
+		ID:        "e1",
+		Schema:    "shop",
+		Table:     "orders",
+		EventType: EventTypeInsert,
+		Timestamp: at,
+		Position:  Position{Name: "mysql-bin.000001", Pos: 120},
+		AfterData: &RowData{Columns: []Column{
+			{Name: "id", Type: "bigint", Value: int64(9007199254740993)},
+			{Name: "qty", Type: "int unsigned", Value: uint32(3)},
+			{Name: "amount", Type: "decimal(10,2)", Value: decimal.RequireFromString("12.30")},
+			{Name: "note", Type: "blob", Value: []byte{0, 1, 2}},
+			{Name: "paid_at", Type: "datetime", Value: at},
+			{Name: "deleted_at", Type: "datetime", IsNull: true},
+		}},
+	}
+	for i, event := range []*Event{first, {ID: "e2"}} {
+		if err := q.Push(event); err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+	}
+	if q.Len() != 2 {
+		t.Fatalf("expected 2 spilled events, got %d", q.Len())
+	}
+
+	got, ok, err := q.Pop()
+	if !ok || err != nil {
+		t.Fatalf("pop: %v %v", ok, err)
+	}
+	if got.ID != "e1" || !got.Timestamp.Equal(at) || got.Position != first.Position {
+		t.Errorf("unexpected event %+v", got)
+	}
+	for i, col := range got.AfterData.Columns {
+		want := first.AfterData.Columns[i].Value
+		if d, ok := want.(decimal.Decimal); ok {
+			if !d.Equal(col.Value.(decimal.Decimal)) {
+				t.Errorf("column %s: got %v, want %v", col.Name, col.Value, want)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(col.Value, want) {
+			t.Errorf("column %s: got %#v, want %#v", col.Name, col.Value, want)
+		}
+	}
+
+	if got, _, _ := q.Pop(); got == nil || got.ID != "e2" {
+		t.Fatalf("expected e2, got %+v", got)
+	}
+	if _, ok, _ := q.Pop(); ok {
+		t.Error("expected spill queue to be empty")
+	}
+	if info, err := q.file.Stat(); err != nil || info.Size() != 0 {
+		t.Errorf("expected drained spill file to be truncated, got %v %v", info.Size(), err)
+	}
+}
+
+// TestSpillQueueQuota 测试达到磁盘配额后拒绝落盘，Close 删除文件
+func TestSpillQueueQuota(t *testing.T) {
+	q := newSpillQueue(t.TempDir(), 600, log.New(io.Discard, "", 0))
+
+	pushed := 0
+	for ; pushed < 100; pushed++ {
+		if err := q.Push(&Event{ID: fmt.Sprintf("event-%d", pushed), Schema: "shop", Table: "orders"}); err != nil {
+			if err != errSpillFull {
+				t.Fatalf("unexpected error %v", err)
+			}
+			break
+		}
+	}
+	if pushed == 0 || pushed == 100 {
+		t.Fatalf("expected quota to stop spilling, pushed %d", pushed)
+	}
+	if stats := q.Stats(); stats["quota_exhausted"] != int64(1) || stats["disk_bytes"].(int64) > 600 {
+		t.Errorf("unexpected stats %v", stats)
+	}
+
+	name := q.file.Name()
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("expected spill file to be removed, got %v", err)
+	}
+}
+
+// TestEventSinkSpill 测试队列满时事件落盘不阻塞发送，处理协程按 binlog 顺序处理队列和落盘的事件
+func TestEventSinkSpill(t *testing.T) {
+	s := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	s.SetBufferPolicy(EventBufferPolicy{Size: 2, SendTimeout: time.Hour, Overflow: OverflowBlock,
+		SpillDir: t.TempDir(), SpillMaxBytes: 1 << 20})
+	handler := &captureHandler{events: make(chan *Event, 10)}
+	if err := s.Subscribe("shop", "orders", handler); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 6; i++ {
+		event := &Event{ID: fmt.Sprintf("e%d", i), Schema: "shop", Table: "orders",
+			Position: Position{Name: "mysql-bin.000001", Pos: uint32(100 + i)}}
+		if err := s.SendEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := s.PendingEvents(); got != 6 {
+		t.Fatalf("expected 6 pending events, got %d", got)
+	}
+	if spilled := s.QueueStats()["spill"].(map[string]interface{})["spilled_events"]; spilled != int64(4) {
+		t.Fatalf("expected 4 spilled events, got %v", spilled)
+	}
+	// 有未处理的落盘事件时不能把读取位置作为已提交位置
+	if pos := s.CommittedPosition(Position{Name: "mysql-bin.000001", Pos: 200}); pos.Pos == 200 {
+		t.Errorf("expected committed position to stay behind spilled events, got %+v", pos)
+	}
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	for i := 0; i < 6; i++ {
+		select {
+		case event := <-handler.events:
+			if want := fmt.Sprintf("e%d", i); event.ID != want {
+				t.Fatalf("expected %s, got %s", want, event.ID)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+}
+
+// TestEventSinkSpillReadFailure 测试落盘文件读取失败后事件接收器停止处理，已提交位置停在丢失的事件之前
+func TestEventSinkSpillReadFailure(t *testing.T) {
+	s := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	s.SetBufferPolicy(EventBufferPolicy{Size: 2, SendTimeout: time.Hour, Overflow: OverflowBlock,
+		SpillDir: t.TempDir(), SpillMaxBytes: 1 << 20})
+	handler := &captureHandler{events: make(chan *Event, 10)}
+	if err := s.Subscribe("shop", "orders", handler); err != nil {
+		t.Fatal(err)
+	}
+	send := func(i int) {
+		t.Helper()
+		event := &Event{ID: fmt.Sprintf("e%d", i), Schema: "shop", Table: "orders",
+			Position: Position{Name: "mysql-bin.000001", Pos: uint32(100 + i)}}
+		if err := s.SendEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		send(i)
+	}
+	// 关闭落盘文件，读取落盘的事件时失败
+	s.spill.file.Close()
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Err() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the sink to stop after the spill read failure")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 停止后新到的事件不投递
+	send(6)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-handler.events:
+			if want := fmt.Sprintf("e%d", i); event.ID != want {
+				t.Fatalf("expected %s, got %s", want, event.ID)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+	select {
+	case event := <-handler.events:
+		t.Fatalf("expected no events after the failure, got %s", event.ID)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 丢失的事件从 e2 开始，已提交位置不能越过 e1
+	if pos := s.CommittedPosition(Position{Name: "mysql-bin.000001", Pos: 200}); pos.Name == "" || pos.Pos > 101 {
+		t.Errorf("expected committed position before the lost events, got %+v", pos)
+	}
+	if offsets := s.SinkOffsets(Position{Name: "mysql-bin.000001", Pos: 200}); offsets["capture"].Pos > 101 {
+		t.Errorf("expected sink offset before the lost events, got %+v", offsets)
+	}
+}
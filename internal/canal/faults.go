@@ -0,0 +1,200 @@
+package canal
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FaultKind 可注入的故障类型，用于混沌测试验证重连、重试和恢复逻辑
+type FaultKind string
+
+const (
+	FaultConnectionFail  FaultKind = "connection_fail"  // 启动时连接测试失败
+	FaultDropConnection  FaultKind = "drop_connection"  // 读取 binlog 时断开复制连接，触发重连
+	FaultCorruptPosition FaultKind = "corrupt_position" // 将 binlog 位置改为不存在的文件并断开连接，触发 binlog 清除恢复流程
+	FaultDelayDelivery   FaultKind = "delay_delivery"   // 事件交给处理器前延迟
+	FaultWebhook5xx      FaultKind = "webhook_5xx"      // Webhook 不发送请求，直接返回 5xx
+)
+
+// FaultKinds 所有故障类型
+var FaultKinds = []FaultKind{
+	FaultConnectionFail, FaultDropConnection, FaultCorruptPosition, FaultDelayDelivery, FaultWebhook5xx,
+}
+
+// Fault 故障配置
+// Target 为空时作用于所有实例和处理器；binlog 类故障按实例 ID 匹配，投递类故障按处理器名匹配
+type Fault struct {
+	Kind        FaultKind `json:"kind"`
+	Target      string    `json:"target,omitempty"`
+	Probability float64   `json:"probability,omitempty"` // 每次检查时触发的概率，0 表示每次都触发
+	Remaining   int       `json:"remaining,omitempty"`   // 剩余触发次数，0 表示不限，用完后自动移除
+	DelayMs     int       `json:"delay_ms,omitempty"`    // delay_delivery 的延迟
+	StatusCode  int       `json:"status_code,omitempty"` // webhook_5xx 返回的状态码，默认 503
+
+	Triggered int64     `json:"triggered"`
+	EnabledAt time.Time `json:"enabled_at"`
+}
+
+// Validate 校验并补全故障配置
+func (f *Fault) Validate() error {
+	known := false
+	for _, kind := range FaultKinds {
+		if f.Kind == kind {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown fault kind: %s", f.Kind)
+	}
+	if f.Probability < 0 || f.Probability > 1 {
+		return fmt.Errorf("probability must be between 0 and 1, got %v", f.Probability)
+	}
+	if f.Remaining < 0 {
+		return fmt.Errorf("remaining must not be negative, got %d", f.Remaining)
+	}
+
+	switch f.Kind {
+	case FaultDelayDelivery:
+		if f.DelayMs <= 0 {
+			return fmt.Errorf("delay_ms must be positive for %s", f.Kind)
+		}
+	case FaultWebhook5xx:
+		if f.StatusCode == 0 {
+			f.StatusCode = 503
+		}
+		if f.StatusCode < 500 || f.StatusCode > 599 {
+			return fmt.Errorf("status_code must be 5xx, got %d", f.StatusCode)
+		}
+	}
+	return nil
+}
+
+// Delay delay_delivery 的延迟时间
+func (f Fault) Delay() time.Duration {
+	return time.Duration(f.DelayMs) * time.Millisecond
+}
+
+// Err 故障对应的错误
+func (f Fault) Err() error {
+	return fmt.Errorf("fault injected: %s", f.Kind)
+}
+
+// FaultInjector 故障注入器，每种故障类型同时只有一个配置
+type FaultInjector struct {
+	mu     sync.Mutex
+	faults map[FaultKind]*Fault
+	rand   *rand.Rand
+	active int32 // 已启用的故障数，为 0 时跳过加锁
+}
+
+// NewFaultInjector 创建故障注入器
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{
+		faults: make(map[FaultKind]*Fault),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Faults 进程级故障注入器，由管理接口开关；未启用任何故障时注入点没有额外开销
+var Faults = NewFaultInjector()
+
+// Enable 启用故障，替换同类型的已有配置
+func (i *FaultInjector) Enable(fault Fault) (Fault, error) {
+	if err := fault.Validate(); err != nil {
+		return Fault{}, err
+	}
+	fault.Triggered = 0
+	fault.EnabledAt = time.Now()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults[fault.Kind] = &fault
+	atomic.StoreInt32(&i.active, int32(len(i.faults)))
+	return fault, nil
+}
+
+// Disable 移除故障，返回是否存在
+func (i *FaultInjector) Disable(kind FaultKind) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	_, ok := i.faults[kind]
+	delete(i.faults, kind)
+	atomic.StoreInt32(&i.active, int32(len(i.faults)))
+	return ok
+}
+
+// Reset 移除所有故障
+func (i *FaultInjector) Reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults = make(map[FaultKind]*Fault)
+	atomic.StoreInt32(&i.active, 0)
+}
+
+// List 已启用的故障，按类型排序
+func (i *FaultInjector) List() []Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	faults := make([]Fault, 0, len(i.faults))
+	for _, fault := range i.faults {
+		faults = append(faults, *fault)
+	}
+	sort.Slice(faults, func(a, b int) bool { return faults[a].Kind < faults[b].Kind })
+	return faults
+}
+
+// Trigger 检查故障是否对 target 生效，生效时计数并返回故障配置
+func (i *FaultInjector) Trigger(kind FaultKind, target string) (Fault, bool) {
+	if atomic.LoadInt32(&i.active) == 0 {
+		return Fault{}, false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	fault, ok := i.faults[kind]
+	if !ok || (fault.Target != "" && fault.Target != target) {
+		return Fault{}, false
+	}
+	if fault.Probability > 0 && i.rand.Float64() >= fault.Probability {
+		return Fault{}, false
+	}
+
+	fault.Triggered++
+	triggered := *fault
+	if fault.Remaining > 0 {
+		fault.Remaining--
+		if fault.Remaining == 0 {
+			delete(i.faults, kind)
+			atomic.StoreInt32(&i.active, int32(len(i.faults)))
+		}
+	}
+	return triggered, true
+}
+
+// injectStreamFaults binlog 读取循环中的故障注入点，返回错误时调用方断开连接并走重连流程
+func (m *MySQLBinlogSlave) injectStreamFaults() error {
+	if fault, ok := Faults.Trigger(FaultCorruptPosition, m.instanceID); ok {
+		m.mu.Lock()
+		m.binlogPos.Name += ".corrupted"
+		pos := m.binlogPos
+		m.syncer.Close()
+		m.mu.Unlock()
+		m.logger.Printf("💥 Injected position corruption for %s: %s:%d", m.instanceID, pos.Name, pos.Pos)
+		return fault.Err()
+	}
+
+	if fault, ok := Faults.Trigger(FaultDropConnection, m.instanceID); ok {
+		m.mu.Lock()
+		m.syncer.Close()
+		m.mu.Unlock()
+		m.logger.Printf("💥 Injected replication connection drop for %s", m.instanceID)
+		return fault.Err()
+	}
+	return nil
+}
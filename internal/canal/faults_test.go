@@ -0,0 +1,108 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestFaultInjectorTrigger 测试目标匹配、次数限制和参数校验
+func TestFaultInjectorTrigger(t *testing.T) {
+	faults := NewFaultInjector()
+
+	if _, ok := faults.Trigger(FaultDropConnection, "task-1"); ok {
+		t.Fatalf("expected no fault before enabling")
+	}
+
+	if _, err := faults.Enable(Fault{Kind: FaultDropConnection, Target: "task-1", Remaining: 2}); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	if _, ok := faults.Trigger(FaultDropConnection, "task-2"); ok {
+		t.Errorf("expected fault not to apply to other targets")
+	}
+	for i := 0; i < 2; i++ {
+		if _, ok := faults.Trigger(FaultDropConnection, "task-1"); !ok {
+			t.Errorf("expected trigger %d to fire", i+1)
+		}
+	}
+	if _, ok := faults.Trigger(FaultDropConnection, "task-1"); ok {
+		t.Errorf("expected fault to be removed after remaining triggers")
+	}
+	if len(faults.List()) != 0 {
+		t.Errorf("expected no faults left, got %+v", faults.List())
+	}
+
+	fault, err := faults.Enable(Fault{Kind: FaultWebhook5xx})
+	if err != nil || fault.StatusCode != 503 {
+		t.Errorf("expected default status 503, got %+v, %v", fault, err)
+	}
+	if !faults.Disable(FaultWebhook5xx) || faults.Disable(FaultWebhook5xx) {
+		t.Errorf("expected Disable to report whether the fault existed")
+	}
+
+	for _, invalid := range []Fault{
+		{Kind: "unknown"},
+		{Kind: FaultDropConnection, Probability: 1.5},
+		{Kind: FaultDelayDelivery},
+		{Kind: FaultWebhook5xx, StatusCode: 404},
+	} {
+		if _, err := faults.Enable(invalid); err == nil {
+			t.Errorf("expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+// TestWebhook5xxFault 测试注入 5xx 时不发送请求并按失败记录
+func TestWebhook5xxFault(t *testing.T) {
+	defer Faults.Reset()
+
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler("webhook-1", server.URL, log.New(io.Discard, "", 0))
+	if _, err := Faults.Enable(Fault{Kind: FaultWebhook5xx, Target: "webhook-1", StatusCode: 502, Remaining: 1}); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+
+	events := []*Event{{ID: "e1", Schema: "shop", Table: "orders", EventType: EventTypeInsert}}
+	attempt, err := handler.Deliver(context.Background(), events, 1)
+	if err == nil || attempt.StatusCode != 502 || calls != 0 {
+		t.Errorf("expected injected 502 without request, got %+v, %v, %d calls", attempt, err, calls)
+	}
+
+	// 次数用完后恢复正常投递
+	attempt, err = handler.Deliver(context.Background(), events, 2)
+	if err != nil || attempt.StatusCode != http.StatusOK || calls != 1 {
+		t.Errorf("expected normal delivery after fault, got %+v, %v, %d calls", attempt, err, calls)
+	}
+}
+
+// TestConnectionFailFault 测试注入连接失败时启动失败
+func TestConnectionFailFault(t *testing.T) {
+	defer Faults.Reset()
+
+	logger := log.New(io.Discard, "", 0)
+	slave, err := NewMySQLBinlogSlave(MySQLConfig{Host: "127.0.0.1", Port: 3306, ServerID: 1001},
+		NewDefaultEventSink(logger), logger)
+	if err != nil {
+		t.Fatalf("Failed to create binlog slave: %v", err)
+	}
+
+	if _, err := Faults.Enable(Fault{Kind: FaultConnectionFail}); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	err = slave.Start()
+	if err == nil || !strings.Contains(err.Error(), "fault injected: connection_fail") {
+		t.Errorf("expected injected connection failure, got %v", err)
+	}
+	if slave.IsRunning() {
+		t.Errorf("expected slave not to be running")
+	}
+}
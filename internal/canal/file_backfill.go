@@ -0,0 +1,136 @@
+package canal
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 回填状态
+const (
+	BackfillRunning   = "running"
+	BackfillCompleted = "completed"
+	BackfillFailed    = "failed"
+)
+
+// BackfillRequest 从本地 binlog 文件回填任务的请求，Start/Stop 的 Name 为空时从第一个文件开头读到最后一个文件末尾
+type BackfillRequest struct {
+	Files         []string // 按顺序读取的 binlog 文件路径
+	Schema        string   // 回填的库表，与任务监听的库表相同
+	Table         string
+	ExcludeTables []string
+	Start         Position
+	Stop          Position
+}
+
+// BackfillProgress 最近一次回填的进度
+type BackfillProgress struct {
+	State      string    `json:"state"` // running, completed, failed
+	Files      []string  `json:"files"`
+	Position   Position  `json:"position"` // 已读取到的文件位置
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// instanceBackfill 实例正在进行或最近一次的回填
+type instanceBackfill struct {
+	mu       sync.Mutex
+	parser   *FileBinlogParser
+	progress *BackfillProgress
+	stopped  bool // 实例停止时中止了回填
+}
+
+// Backfill 在后台从本地 binlog 文件读取事件，送入实例的事件接收器，经过与在线读取相同的过滤和处理器，
+// 用于从归档的 binlog 回填数据。只读取任务监听的库表，同一时间只能有一个回填，进度见实例状态的 backfill
+func (c *MySQLCanalInstance) Backfill(req BackfillRequest) error {
+	c.mu.RLock()
+	running, ctx, config := c.running, c.ctx, c.config
+	excludes := append(append([]string(nil), c.globalExcludes...), req.ExcludeTables...)
+	eventTypes := append([]EventType(nil), c.taskTypes...)
+	c.mu.RUnlock()
+	if !running {
+		return fmt.Errorf("mysql canal instance %s is not running", c.id)
+	}
+
+	c.backfill.mu.Lock()
+	defer c.backfill.mu.Unlock()
+	if c.backfill.progress != nil && c.backfill.progress.State == BackfillRunning {
+		return fmt.Errorf("a backfill is already running on instance %s", c.id)
+	}
+
+	parser, err := NewFileBinlogParser(req.Files, config, c.eventSink, c.logger)
+	if err != nil {
+		return err
+	}
+	parser.AddWatchTable(req.Schema, req.Table)
+	parser.SetExcludeTables(excludes)
+	if len(eventTypes) > 0 {
+		parser.SetEventTypes(eventTypes)
+	}
+	if req.Start.Name != "" {
+		if err := parser.SetPosition(req.Start); err != nil {
+			return err
+		}
+	}
+	if req.Stop.Name != "" {
+		if err := parser.SetStopPosition(req.Stop); err != nil {
+			return err
+		}
+	}
+	if err := parser.Start(ctx); err != nil {
+		return err
+	}
+
+	progress := &BackfillProgress{State: BackfillRunning, Files: append([]string(nil), req.Files...), StartedAt: time.Now()}
+	c.backfill.parser, c.backfill.progress, c.backfill.stopped = parser, progress, false
+	c.logger.Printf("📂 Backfilling %s.%s of instance %s from %d binlog files", req.Schema, req.Table, c.id, len(req.Files))
+
+	go func() {
+		err := parser.Wait()
+
+		c.backfill.mu.Lock()
+		defer c.backfill.mu.Unlock()
+		progress.Position = parser.GetPosition()
+		progress.FinishedAt = time.Now()
+		// 实例停止时解析器被中止，Wait 不返回错误
+		if err == nil && (c.backfill.stopped || ctx.Err() != nil) {
+			err = fmt.Errorf("interrupted because instance %s stopped", c.id)
+		}
+		if err != nil {
+			progress.State, progress.Error = BackfillFailed, err.Error()
+			c.logger.Printf("❌ Backfill of instance %s failed: %v", c.id, err)
+			return
+		}
+		progress.State = BackfillCompleted
+		c.logger.Printf("✅ Backfill of instance %s finished at %s:%d", c.id, progress.Position.Name, progress.Position.Pos)
+	}()
+	return nil
+}
+
+// backfillProgress 返回回填进度的副本，运行中时位置为当前读取到的位置，没有回填时返回 nil
+func (c *MySQLCanalInstance) backfillProgress() *BackfillProgress {
+	c.backfill.mu.Lock()
+	defer c.backfill.mu.Unlock()
+	if c.backfill.progress == nil {
+		return nil
+	}
+	progress := *c.backfill.progress
+	if progress.State == BackfillRunning {
+		progress.Position = c.backfill.parser.GetPosition()
+	}
+	return &progress
+}
+
+// stopBackfill 停止正在进行的回填
+func (c *MySQLCanalInstance) stopBackfill() {
+	c.backfill.mu.Lock()
+	parser := c.backfill.parser
+	if parser != nil && c.backfill.progress.State == BackfillRunning {
+		c.backfill.stopped = true
+	}
+	c.backfill.mu.Unlock()
+	if parser != nil {
+		parser.Stop()
+	}
+}
@@ -0,0 +1,275 @@
+package canal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// errStopPositionReached 到达结束位置，用于中止文件解析
+var errStopPositionReached = errors.New("stop position reached")
+
+// FileBinlogParser 从本地 binlog 文件读取事件的解析器，用于从归档的 binlog 回填数据和可重复的测试。
+// 文件需要是原始 binlog 格式，如从源库复制的 binlog 文件或 mysqlbinlog --read-from-remote-server --raw 的输出；
+// 事件经过与 MySQLBinlogSlave 相同的表过滤、表结构解析和事件ID生成后送入事件接收器
+type FileBinlogParser struct {
+	files  []string
+	logger *log.Logger
+
+	// 复用 binlog slave 的事件处理，不连接源库读取 binlog
+	slave *MySQLBinlogSlave
+
+	mu      sync.Mutex
+	start   Position // 起始位置，Name 为空时从第一个文件开头读取
+	stop    Position // 结束位置，Name 为空时读到最后一个文件末尾
+	running bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+}
+
+// NewFileBinlogParser 创建按顺序读取 files 的解析器。config 中的源库地址只用于 binlog 未携带列名时
+// 查询 information_schema（需要 binlog_row_metadata=FULL 才能完全离线），为空时列名为 col_N
+func NewFileBinlogParser(files []string, config MySQLConfig, eventSink *DefaultEventSink, logger *log.Logger) (*FileBinlogParser, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no binlog files to read")
+	}
+
+	slave, err := NewMySQLBinlogSlave(config, eventSink, logger)
+	if err != nil {
+		return nil, err
+	}
+	slave.restoreGTIDSet(Position{})
+
+	return &FileBinlogParser{
+		files:  files,
+		logger: logger,
+		slave:  slave,
+	}, nil
+}
+
+// SetEventSink 设置事件接收器，只支持 *DefaultEventSink
+func (p *FileBinlogParser) SetEventSink(sink EventSink) {
+	defaultSink, ok := sink.(*DefaultEventSink)
+	if !ok {
+		p.logger.Printf("⚠️ File binlog parser only supports DefaultEventSink, got %T", sink)
+		return
+	}
+	p.slave.mu.Lock()
+	p.slave.eventSink = defaultSink
+	p.slave.mu.Unlock()
+}
+
+// AddWatchTable 添加监听表，未添加时读取所有表
+func (p *FileBinlogParser) AddWatchTable(schema, table string) {
+	p.slave.AddWatchTable(schema, table)
+}
+
+// SetExcludeTables 设置排除的表
+func (p *FileBinlogParser) SetExcludeTables(patterns []string) {
+	p.slave.SetExcludeTables(patterns)
+}
+
+// SetEventTypes 设置读取的事件类型
+func (p *FileBinlogParser) SetEventTypes(eventTypes []EventType) {
+	p.slave.SetEventTypes(eventTypes)
+}
+
+// SetPosition 设置起始位置，pos.Name 需要是某个文件的文件名，之前的文件被跳过
+func (p *FileBinlogParser) SetPosition(pos Position) error {
+	if p.fileIndex(pos.Name) < 0 {
+		return fmt.Errorf("binlog file %s is not in the file list", pos.Name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running {
+		return fmt.Errorf("file binlog parser is already running")
+	}
+	p.start = pos
+	p.slave.restoreGTIDSet(pos)
+	return nil
+}
+
+// SetStopPosition 设置结束位置，读到该位置（含）之后的第一个事件时停止
+func (p *FileBinlogParser) SetStopPosition(pos Position) error {
+	if p.fileIndex(pos.Name) < 0 {
+		return fmt.Errorf("binlog file %s is not in the file list", pos.Name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop = pos
+	return nil
+}
+
+// GetPosition 已处理到的位置
+func (p *FileBinlogParser) GetPosition() Position {
+	p.slave.mu.RLock()
+	defer p.slave.mu.RUnlock()
+	pos := Position{Name: p.slave.binlogPos.Name, Pos: p.slave.binlogPos.Pos}
+	if p.slave.gtidSet != nil {
+		pos.GTIDSet = p.slave.gtidSet.String()
+	}
+	return pos
+}
+
+// Start 在后台按顺序读取文件，读完、到达结束位置或出错后停止，结果见 Wait
+func (p *FileBinlogParser) Start(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running {
+		return fmt.Errorf("file binlog parser is already running")
+	}
+
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.running = true
+	p.err = nil
+	p.done = make(chan struct{})
+
+	p.logger.Printf("📂 Reading %d binlog files offline", len(p.files))
+	go p.run(ctx, p.start, p.stop, p.done)
+	return nil
+}
+
+// Stop 停止读取并等待当前事件处理完成
+func (p *FileBinlogParser) Stop() error {
+	p.mu.Lock()
+	cancel, done := p.cancel, p.done
+	p.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	<-done
+	return nil
+}
+
+// Wait 等待读取结束，返回读取中的错误；被 Stop 中止时返回 nil
+func (p *FileBinlogParser) Wait() error {
+	p.mu.Lock()
+	done := p.done
+	p.mu.Unlock()
+
+	if done == nil {
+		return fmt.Errorf("file binlog parser is not started")
+	}
+	<-done
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// run 按顺序读取文件
+func (p *FileBinlogParser) run(ctx context.Context, start, stop Position, done chan struct{}) {
+	defer close(done)
+
+	err := p.readFiles(ctx, start, stop)
+	if errors.Is(err, errStopPositionReached) || ctx.Err() != nil {
+		err = nil
+	}
+	if err != nil {
+		p.logger.Printf("❌ Failed to read binlog files: %v", err)
+		p.slave.recordError(err)
+	} else {
+		pos := p.GetPosition()
+		p.logger.Printf("✅ Finished reading binlog files at %s:%d", pos.Name, pos.Pos)
+	}
+
+	p.mu.Lock()
+	p.err = err
+	p.running = false
+	p.mu.Unlock()
+}
+
+// readFiles 从 start 读到 stop 或最后一个文件末尾
+func (p *FileBinlogParser) readFiles(ctx context.Context, start, stop Position) error {
+	first := 0
+	if start.Name != "" {
+		first = p.fileIndex(start.Name)
+	}
+
+	for i := first; i < len(p.files); i++ {
+		path := p.files[i]
+		name := filepath.Base(path)
+		offset := uint32(4)
+		if i == first && start.Pos > offset {
+			offset = start.Pos
+		}
+
+		p.slave.mu.Lock()
+		p.slave.binlogPos = mysql.Position{Name: name, Pos: offset}
+		p.slave.mu.Unlock()
+		p.slave.rowsQuery = ""
+		p.slave.currentGTID = ""
+		p.slave.inTransaction = false
+
+		p.logger.Printf("📂 Reading binlog file %s from position %d", path, offset)
+		parser := replication.NewBinlogParser()
+		parser.SetUseDecimal(true)
+		parser.SetParseTime(true)
+		parser.SetVerifyChecksum(true)
+		parser.SetRowsEventDecodeFunc(p.slave.decodeRowsEvent)
+
+		err := parser.ParseFile(path, int64(offset), func(ev *replication.BinlogEvent) error {
+			return p.handleEvent(ctx, name, stop, ev)
+		})
+		if err != nil {
+			// ParseFile 会包装 onEvent 返回的错误
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if stop.Name == name && p.GetPosition().Pos >= stop.Pos {
+				return errStopPositionReached
+			}
+			return fmt.Errorf("failed to read binlog file %s: %v", path, err)
+		}
+		if stop.Name == name {
+			return errStopPositionReached
+		}
+	}
+	return nil
+}
+
+// handleEvent 处理文件中的一个事件
+func (p *FileBinlogParser) handleEvent(ctx context.Context, name string, stop Position, ev *replication.BinlogEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// 偏移大于 4 时解析器会先读取文件开头的 FORMAT_DESCRIPTION 事件，不参与位置计算
+	if ev.Header.EventType == replication.FORMAT_DESCRIPTION_EVENT {
+		return nil
+	}
+
+	if err := p.slave.handleBinlogEvent(ev); err != nil {
+		p.logger.Printf("❌ Failed to handle binlog event: %v", err)
+	}
+	// 文件末尾的 ROTATE 指向下一个文件，由下一轮读取设置位置
+	if ev.Header.EventType != replication.ROTATE_EVENT {
+		p.slave.updatePosition(ev)
+	}
+
+	if stop.Name == name && ev.Header.LogPos >= stop.Pos {
+		return errStopPositionReached
+	}
+	return nil
+}
+
+// fileIndex 按文件名查找文件，不存在时返回 -1
+func (p *FileBinlogParser) fileIndex(name string) int {
+	for i, path := range p.files {
+		if filepath.Base(path) == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,304 @@
+package canal
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// testBinlogWriter 构造最小的 binlog 文件：FORMAT_DESCRIPTION、TABLE_MAP 和 WRITE_ROWS 事件，不带校验和
+type testBinlogWriter struct {
+	buf bytes.Buffer
+}
+
+func newTestBinlogWriter() *testBinlogWriter {
+	w := &testBinlogWriter{}
+	w.buf.Write(replication.BinLogFileHeader)
+
+	body := make([]byte, 0, 100)
+	body = binary.LittleEndian.AppendUint16(body, 4)
+	version := make([]byte, 50)
+	copy(version, "8.0.30")
+	body = append(body, version...)
+	body = binary.LittleEndian.AppendUint32(body, 0)
+	body = append(body, byte(replication.EventHeaderSize))
+	// 各事件类型的 post-header 长度，TABLE_MAP 为 8，WRITE_ROWS_EVENTv2 为 10
+	body = append(body, 0x38, 0xd, 0x0, 0x8, 0x0, 0x12, 0x0, 0x4, 0x4, 0x4, 0x4, 0x12, 0x0, 0x0, 0x5c, 0x0, 0x4, 0x1a, 0x8, 0x0,
+		0x0, 0x0, 0x8, 0x8, 0x8, 0x2, 0x0, 0x0, 0x0, 0xa, 0xa, 0xa, 0x19, 0x19, 0x0, 0x12, 0x34, 0x0, 0xa, 0x28, 0x0)
+	body = append(body, replication.BINLOG_CHECKSUM_ALG_OFF, 0, 0, 0, 0)
+	w.event(replication.FORMAT_DESCRIPTION_EVENT, body)
+	return w
+}
+
+// event 追加一个事件，返回事件结束的位置
+func (w *testBinlogWriter) event(eventType replication.EventType, body []byte) uint32 {
+	size := uint32(replication.EventHeaderSize + len(body))
+	end := uint32(w.buf.Len()) + size
+
+	header := make([]byte, 0, replication.EventHeaderSize)
+	header = binary.LittleEndian.AppendUint32(header, 1700000000)
+	header = append(header, byte(eventType))
+	header = binary.LittleEndian.AppendUint32(header, 1)
+	header = binary.LittleEndian.AppendUint32(header, size)
+	header = binary.LittleEndian.AppendUint32(header, end)
+	header = binary.LittleEndian.AppendUint16(header, 0)
+	w.buf.Write(header)
+	w.buf.Write(body)
+	return end
+}
+
+// ordersTableMap shop.orders (id INT 主键, note VARCHAR(64) NULL)，携带列名
+func (w *testBinlogWriter) ordersTableMap() uint32 {
+	body := []byte{42, 0, 0, 0, 0, 0, 1, 0}
+	body = append(body, 4, 's', 'h', 'o', 'p', 0)
+	body = append(body, 6, 'o', 'r', 'd', 'e', 'r', 's', 0)
+	body = append(body, 2, 3, 15)                                 // 列数和类型 LONG、VARCHAR
+	body = append(body, 2, 0x40, 0)                               // VARCHAR 的最大长度
+	body = append(body, 0x02)                                     // note 可为 NULL
+	body = append(body, 4, 8, 2, 'i', 'd', 4, 'n', 'o', 't', 'e') // 列名
+	body = append(body, 8, 1, 0)                                  // 主键为第一列
+	return w.event(replication.TABLE_MAP_EVENT, body)
+}
+
+// ordersInsert 插入若干行
+func (w *testBinlogWriter) ordersInsert(ids ...uint32) uint32 {
+	body := []byte{42, 0, 0, 0, 0, 0, 1, 0, 2, 0}
+	body = append(body, 2, 0x03)
+	for _, id := range ids {
+		body = append(body, 0)
+		body = binary.LittleEndian.AppendUint32(body, id)
+		body = append(body, 1, byte('a'+id))
+	}
+	return w.event(replication.WRITE_ROWS_EVENTv2, body)
+}
+
+// rotate 追加指向下一个文件的 ROTATE 事件
+func (w *testBinlogWriter) rotate(next string) uint32 {
+	body := binary.LittleEndian.AppendUint64(nil, 4)
+	return w.event(replication.ROTATE_EVENT, append(body, next...))
+}
+
+func (w *testBinlogWriter) save(t *testing.T, dir, name string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, w.buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("failed to write binlog file: %v", err)
+	}
+	return path
+}
+
+// collectingHandler 记录收到的事件
+type collectingHandler struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (h *collectingHandler) Handle(ctx context.Context, event *Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+	return nil
+}
+
+func (h *collectingHandler) GetName() string {
+	return "collector"
+}
+
+func (h *collectingHandler) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (h *collectingHandler) Close() error {
+	return nil
+}
+
+// waitEvents 等待收到 n 个事件
+func (h *collectingHandler) waitEvents(t *testing.T, n int) []*Event {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		h.mu.Lock()
+		events := append([]*Event(nil), h.events...)
+		h.mu.Unlock()
+		if len(events) >= n {
+			return events
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d events", n)
+	return nil
+}
+
+// writeTestBinlogs 生成两个文件：第一个文件插入 1、2 后轮转，第二个文件插入 3
+func writeTestBinlogs(t *testing.T) (files []string, firstInsertEnd uint32) {
+	dir := t.TempDir()
+
+	first := newTestBinlogWriter()
+	first.ordersTableMap()
+	firstInsertEnd = first.ordersInsert(1, 2)
+	first.rotate("mysql-bin.000002")
+
+	second := newTestBinlogWriter()
+	second.ordersTableMap()
+	second.ordersInsert(3)
+
+	return []string{first.save(t, dir, "mysql-bin.000001"), second.save(t, dir, "mysql-bin.000002")}, firstInsertEnd
+}
+
+// runFileParser 读取 files 并返回送入事件接收器的事件
+func runFileParser(t *testing.T, files []string, setup func(p *FileBinlogParser), want int) ([]*Event, *FileBinlogParser) {
+	logger := log.New(io.Discard, "", 0)
+	sink := NewDefaultEventSink(logger)
+	handler := &collectingHandler{}
+	sink.Subscribe("shop", "orders", handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := sink.Start(ctx); err != nil {
+		t.Fatalf("failed to start sink: %v", err)
+	}
+	defer sink.Stop()
+
+	parser, err := NewFileBinlogParser(files, MySQLConfig{ServerID: 1001}, sink, logger)
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	if setup != nil {
+		setup(parser)
+	}
+	if err := parser.Start(ctx); err != nil {
+		t.Fatalf("failed to start parser: %v", err)
+	}
+	if err := parser.Wait(); err != nil {
+		t.Fatalf("failed to read binlog files: %v", err)
+	}
+
+	events := handler.waitEvents(t, want)
+	// 多出的事件说明没有在结束位置停下
+	time.Sleep(20 * time.Millisecond)
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if len(handler.events) != want {
+		t.Fatalf("expected %d events, got %d", want, len(handler.events))
+	}
+	return events, parser
+}
+
+// TestFileBinlogParserReadsFiles 测试按顺序读取多个文件，事件与在线读取的格式一致
+func TestFileBinlogParserReadsFiles(t *testing.T) {
+	files, _ := writeTestBinlogs(t)
+	events, parser := runFileParser(t, files, nil, 3)
+
+	for i, event := range events {
+		if event.EventType != EventTypeInsert || event.Schema != "shop" || event.Table != "orders" {
+			t.Fatalf("unexpected event %+v", event)
+		}
+		id := event.AfterData.Columns[0]
+		if id.Name != "id" || id.Value != int32(i+1) {
+			t.Errorf("event %d: expected id %d, got %+v", i, i+1, id)
+		}
+		if note := event.AfterData.Columns[1]; note.Name != "note" || note.Value != string(rune('a'+i+1)) {
+			t.Errorf("event %d: unexpected note %+v", i, note)
+		}
+		if len(event.PrimaryKey) != 1 || event.PrimaryKey[0] != "id" {
+			t.Errorf("event %d: expected primary key id, got %v", i, event.PrimaryKey)
+		}
+	}
+	if events[0].Position.Name != "mysql-bin.000001" || events[2].Position.Name != "mysql-bin.000002" {
+		t.Errorf("unexpected positions %+v, %+v", events[0].Position, events[2].Position)
+	}
+	if events[0].ID == events[1].ID {
+		t.Error("rows of the same event should have distinct IDs")
+	}
+
+	stat, err := os.Stat(files[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos := parser.GetPosition(); pos.Name != "mysql-bin.000002" || pos.Pos != uint32(stat.Size()) {
+		t.Errorf("expected position at end of second file, got %+v", pos)
+	}
+}
+
+// TestFileBinlogParserPositions 测试起始位置和结束位置
+func TestFileBinlogParserPositions(t *testing.T) {
+	files, firstInsertEnd := writeTestBinlogs(t)
+
+	events, _ := runFileParser(t, files, func(p *FileBinlogParser) {
+		if err := p.SetPosition(Position{Name: "mysql-bin.000002", Pos: 4}); err != nil {
+			t.Fatal(err)
+		}
+	}, 1)
+	if events[0].AfterData.Columns[0].Value != int32(3) {
+		t.Errorf("expected only the row of the second file, got %+v", events[0].AfterData)
+	}
+
+	events, parser := runFileParser(t, files, func(p *FileBinlogParser) {
+		if err := p.SetStopPosition(Position{Name: "mysql-bin.000001", Pos: firstInsertEnd}); err != nil {
+			t.Fatal(err)
+		}
+	}, 2)
+	if events[1].AfterData.Columns[0].Value != int32(2) {
+		t.Errorf("unexpected last event %+v", events[1].AfterData)
+	}
+	if pos := parser.GetPosition(); pos.Name != "mysql-bin.000001" || pos.Pos != firstInsertEnd {
+		t.Errorf("expected to stop at %d, got %+v", firstInsertEnd, pos)
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	parser, err := NewFileBinlogParser(files, MySQLConfig{ServerID: 1001}, NewDefaultEventSink(logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parser.SetPosition(Position{Name: "mysql-bin.000009", Pos: 4}); err == nil {
+		t.Error("expected error for a file that is not in the list")
+	}
+}
+
+// TestMySQLCanalInstanceBackfill 测试回填的事件送入实例的事件接收器，完成后进度记为 completed，实例未运行时拒绝回填
+func TestMySQLCanalInstanceBackfill(t *testing.T) {
+	files, _ := writeTestBinlogs(t)
+	logger := log.New(io.Discard, "", 0)
+	sink := NewDefaultEventSink(logger)
+	handler := &collectingHandler{}
+	sink.Subscribe("shop", "orders", handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := sink.Start(ctx); err != nil {
+		t.Fatalf("failed to start sink: %v", err)
+	}
+	defer sink.Stop()
+
+	c := &MySQLCanalInstance{id: "task-1", config: MySQLConfig{ServerID: 1001}, eventSink: sink, logger: logger}
+	req := BackfillRequest{Files: files, Schema: "shop", Table: "orders"}
+	if err := c.Backfill(req); err == nil {
+		t.Fatal("expected error when the instance is not running")
+	}
+
+	c.running, c.ctx = true, ctx
+	if err := c.Backfill(req); err != nil {
+		t.Fatalf("Backfill failed: %v", err)
+	}
+	handler.waitEvents(t, 3)
+
+	deadline := time.Now().Add(5 * time.Second)
+	progress := c.backfillProgress()
+	for progress.State == BackfillRunning && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		progress = c.backfillProgress()
+	}
+	if progress.State != BackfillCompleted || progress.Position.Name != "mysql-bin.000002" || len(progress.Files) != 2 {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+	if status := c.backfillProgress(); status == progress {
+		t.Error("expected a copy of the progress")
+	}
+}
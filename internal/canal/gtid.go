@@ -0,0 +1,130 @@
+package canal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// handleGTIDEvent 处理 GTID 事件，记录随后事务的 GTID
+func (m *MySQLBinlogSlave) handleGTIDEvent(header *replication.EventHeader, e *replication.GTIDEvent) error {
+	// MySQL 8.0.1 起 GTID 事件（包括匿名 GTID 事件）带微秒级的提交时间，早于事务的行事件
+	m.commitTime = e.OriginalCommitTime()
+	// gtid_mode=OFF 时每个事务前是匿名 GTID 事件，没有 GTID，不能并入已执行集合
+	if header.EventType == replication.ANONYMOUS_GTID_EVENT {
+		m.currentGTID = ""
+		return nil
+	}
+	next, err := e.GTIDNext()
+	if err != nil {
+		m.currentGTID = ""
+		return fmt.Errorf("failed to parse gtid event: %v", err)
+	}
+	m.currentGTID = next.String()
+	return nil
+}
+
+// handleMariadbGTIDEvent 处理 MariaDB 的 GTID 事件。
+// 非独立的事件同时是事务的开始，之后没有 BEGIN 语句；独立的事件（DDL 等）之后的语句即提交
+func (m *MySQLBinlogSlave) handleMariadbGTIDEvent(header *replication.EventHeader, e *replication.MariadbGTIDEvent) error {
+	m.commitTime = time.Time{}
+	m.currentGTID = e.GTID.String()
+	m.inTransaction = !e.IsStandalone()
+	return nil
+}
+
+// handlePreviousGTIDsEvent 处理每个 binlog 文件开头的 PREVIOUS_GTIDS 事件：
+// 其中的事务都在当前文件之前执行，并入已执行集合，从文件中间或没有持久化集合时启动也能得到完整的集合
+func (m *MySQLBinlogSlave) handlePreviousGTIDsEvent(header *replication.EventHeader, e *replication.PreviousGTIDsEvent) error {
+	return m.mergeGTIDSet(mysql.MySQLFlavor, e.GTIDSets)
+}
+
+// handleMariadbGTIDListEvent 处理 MariaDB binlog 文件开头的 GTID_LIST 事件，作用同 PREVIOUS_GTIDS
+func (m *MySQLBinlogSlave) handleMariadbGTIDListEvent(header *replication.EventHeader, e *replication.MariadbGTIDListEvent) error {
+	gtids := make([]string, 0, len(e.GTIDs))
+	for i := range e.GTIDs {
+		gtids = append(gtids, e.GTIDs[i].String())
+	}
+	return m.mergeGTIDSet(mysql.MariaDBFlavor, strings.Join(gtids, ","))
+}
+
+// mergeGTIDSet 把 set 并入已执行集合
+func (m *MySQLBinlogSlave) mergeGTIDSet(flavor, set string) error {
+	if set == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// MariaDB 的集合每个域只记录最新的 GTID，并入文件开头较旧的列表会使其回退，只在集合为空时采用
+	if flavor == mysql.MariaDBFlavor && m.gtidSet != nil && m.gtidSet.String() != "" {
+		return nil
+	}
+	m.ensureGTIDSetLocked(flavor)
+	if err := m.gtidSet.Update(set); err != nil {
+		return fmt.Errorf("failed to merge gtid set %q: %v", set, err)
+	}
+	return nil
+}
+
+// commitGTID 事务提交后把当前 GTID 并入已执行集合，并推进提交顺序号。
+// 在 updatePosition 之前调用，保存位置时文件、偏移和 GTID 集合是同一时刻的快照
+func (m *MySQLBinlogSlave) commitGTID() {
+	m.commits++
+	m.commitTime = time.Time{}
+	m.inTransaction = false
+
+	gtid := m.currentGTID
+	m.currentGTID = ""
+	if gtid == "" {
+		return
+	}
+
+	flavor := mysql.MySQLFlavor
+	if !strings.Contains(gtid, ":") {
+		flavor = mysql.MariaDBFlavor
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ensureGTIDSetLocked(flavor)
+	if err := m.gtidSet.Update(gtid); err != nil {
+		m.logger.Printf("⚠️ Failed to add GTID %s to executed set: %v", gtid, err)
+	}
+}
+
+// ensureGTIDSetLocked 确保已执行集合是 flavor 对应的类型：空集合按源库实际的 GTID 格式重建，
+// 已有内容时保持不变，由 Update 报告格式不符。调用方需持有锁
+func (m *MySQLBinlogSlave) ensureGTIDSetLocked(flavor string) {
+	if m.gtidSet == nil || (gtidSetFlavor(m.gtidSet) != flavor && m.gtidSet.String() == "") {
+		m.gtidSet, _ = mysql.ParseGTIDSet(flavor, "")
+	}
+}
+
+// gtidSetFlavor GTID 集合对应的源库类型
+func gtidSetFlavor(set mysql.GTIDSet) string {
+	if _, ok := set.(*mysql.MariadbGTIDSet); ok {
+		return mysql.MariaDBFlavor
+	}
+	return mysql.MySQLFlavor
+}
+
+// restoreGTIDSet 从持久化位置恢复已执行的 GTID 集合，没有记录时从空集合开始累计。
+// MySQL 的集合形如 uuid:1-5，MariaDB 的形如 0-1-100
+func (m *MySQLBinlogSlave) restoreGTIDSet(pos Position) {
+	flavor := mysql.MySQLFlavor
+	if pos.GTIDSet != "" && !strings.Contains(pos.GTIDSet, ":") {
+		flavor = mysql.MariaDBFlavor
+	}
+	set, err := mysql.ParseGTIDSet(flavor, pos.GTIDSet)
+	if err != nil {
+		m.logger.Printf("⚠️ Invalid persisted GTID set %q, tracking from empty set: %v", pos.GTIDSet, err)
+		set, _ = mysql.ParseMysqlGTIDSet("")
+	}
+	m.gtidSet = set
+	m.currentGTID = ""
+	m.inTransaction = false
+}
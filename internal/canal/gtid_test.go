@@ -0,0 +1,212 @@
+package canal
+
+import (
+	"encoding/hex"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// TestGTIDTracking 测试事件携带所属事务的 GTID，提交后并入已执行集合并随位置一起保存
+func TestGTIDTracking(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	meta := &memoryMetaManager{positions: make(map[string]Position)}
+	slave, err := NewMySQLBinlogSlaveWithMeta(MySQLConfig{Host: "127.0.0.1", Port: 1, ServerID: 1001}, NewDefaultEventSink(logger), logger, meta)
+	if err != nil {
+		t.Fatalf("Failed to create binlog slave: %v", err)
+	}
+
+	const sid = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	meta.SavePosition(slave.instanceID, Position{Name: "mysql-bin.000001", Pos: 100, GTIDSet: sid + ":1-5"})
+	if err := slave.getCurrentPosition(); err != nil {
+		t.Fatalf("getCurrentPosition failed: %v", err)
+	}
+
+	sidBytes := []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}
+	logPos := uint32(100)
+	handle := func(e replication.Event) {
+		logPos += 50
+		ev := &replication.BinlogEvent{Header: &replication.EventHeader{LogPos: logPos}, Event: e}
+		if err := slave.handleBinlogEvent(ev); err != nil {
+			t.Fatalf("handleBinlogEvent failed: %v", err)
+		}
+		slave.updatePosition(ev)
+		// 异步保存可能乱序，逐个等待
+		waitSaves(t, slave)
+	}
+
+	handle(&replication.GTIDEvent{SID: sidBytes, GNO: 6})
+	handle(&replication.QueryEvent{Query: []byte("BEGIN")})
+	schema := slave.getTableSchema("testdb", "users", newTestTableMap(100, []byte{3}, "id"))
+	event := slave.createCanalEvent(&replication.EventHeader{LogPos: logPos}, schema, EventTypeInsert, []interface{}{int32(1)}, 0, nil)
+	if event.Position.GTID != sid+":6" || event.Position.GTIDSet != sid+":1-5" {
+		t.Errorf("unexpected event position %+v", event.Position)
+	}
+
+	handle(&replication.XIDEvent{})
+	if pos, _ := meta.LoadPosition(slave.instanceID); pos.GTIDSet != sid+":1-6" || pos.Pos != logPos {
+		t.Errorf("expected gtid set saved with position, got %+v", pos)
+	}
+
+	// DDL 没有 XID 事件，语句本身即提交
+	handle(&replication.GTIDEvent{SID: sidBytes, GNO: 7})
+	handle(&replication.QueryEvent{Query: []byte("ALTER TABLE users ADD COLUMN age INT")})
+	if pos, _ := meta.LoadPosition(slave.instanceID); pos.GTIDSet != sid+":1-7" {
+		t.Errorf("expected ddl gtid to be committed, got %+v", pos)
+	}
+	if got := slave.GetStats()["gtid_set"]; got != sid+":1-7" {
+		t.Errorf("expected gtid_set in stats, got %v", got)
+	}
+}
+
+// waitSaves 等待异步保存完成
+func waitSaves(t *testing.T, slave *MySQLBinlogSlave) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&slave.savesStarted) != atomic.LoadInt64(&slave.savesFinished) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for position saves")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestCommitTimeAndOrder 测试事件携带 GTID 事件中的提交时间和所属事务的提交顺序号
+func TestCommitTimeAndOrder(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	sink := NewDefaultEventSink(logger)
+	slave, err := NewMySQLBinlogSlaveWithMeta(MySQLConfig{Host: "127.0.0.1", Port: 1, ServerID: 1001}, sink, logger, &memoryMetaManager{positions: make(map[string]Position)})
+	if err != nil {
+		t.Fatalf("Failed to create binlog slave: %v", err)
+	}
+	schema := slave.getTableSchema("testdb", "users", newTestTableMap(100, []byte{3}, "id"))
+	create := func() *Event {
+		return slave.createCanalEvent(&replication.EventHeader{LogPos: 200}, schema, EventTypeInsert, []interface{}{int32(1)}, 0, nil)
+	}
+
+	// 2026-01-02 03:04:05.123456 UTC，微秒
+	const commitMicros = 1767323045123456
+	sid := []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}
+	slave.handleBinlogEvent(&replication.BinlogEvent{Header: &replication.EventHeader{}, Event: &replication.GTIDEvent{SID: sid, GNO: 1, OriginalCommitTimestamp: commitMicros}})
+	first, second := create(), create()
+	if first.CommitMicros != commitMicros || first.CommitIndex != 1 || second.CommitIndex != 1 {
+		t.Errorf("unexpected commit metadata %d/%d, %d", first.CommitMicros, first.CommitIndex, second.CommitIndex)
+	}
+	// 处理时间在进入处理队列时设置
+	before := time.Now().UnixMicro()
+	if err := sink.SendEvent(first); err != nil {
+		t.Fatal(err)
+	}
+	if first.ProcessedMicros < before || first.ProcessedMicros > time.Now().UnixMicro() {
+		t.Errorf("unexpected processed time %d", first.ProcessedMicros)
+	}
+
+	// 提交后进入下一个事务，没有 GTID 事件时不带提交时间
+	slave.handleBinlogEvent(&replication.BinlogEvent{Header: &replication.EventHeader{}, Event: &replication.XIDEvent{}})
+	if next := create(); next.CommitIndex != 2 || next.CommitMicros != 0 {
+		t.Errorf("unexpected commit metadata for the next transaction %d/%d", next.CommitMicros, next.CommitIndex)
+	}
+}
+
+// TestGTIDEventCoverage 测试 PREVIOUS_GTIDS、匿名 GTID、INTVAR 和 MariaDB GTID 事件的位置和 GTID 统计，
+// 事件体取自源库 binlog 的原始字节
+func TestGTIDEventCoverage(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	newSlave := func() *MySQLBinlogSlave {
+		slave, err := NewMySQLBinlogSlaveWithMeta(MySQLConfig{Host: "127.0.0.1", Port: 1, ServerID: 1001}, NewDefaultEventSink(logger), logger, &memoryMetaManager{positions: make(map[string]Position)})
+		if err != nil {
+			t.Fatalf("Failed to create binlog slave: %v", err)
+		}
+		slave.restoreGTIDSet(Position{})
+		return slave
+	}
+	decode := func(e replication.Event, raw string) replication.Event {
+		data, err := hex.DecodeString(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Decode(data); err != nil {
+			t.Fatalf("failed to decode %T: %v", e, err)
+		}
+		return e
+	}
+	handle := func(slave *MySQLBinlogSlave, eventType replication.EventType, e replication.Event) {
+		if err := slave.handleBinlogEvent(&replication.BinlogEvent{Header: &replication.EventHeader{EventType: eventType}, Event: e}); err != nil {
+			t.Fatalf("handleBinlogEvent(%s) failed: %v", eventType, err)
+		}
+	}
+
+	const sid = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	slave := newSlave()
+
+	// 文件开头的 PREVIOUS_GTIDS：3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5
+	handle(slave, replication.PREVIOUS_GTIDS_EVENT, decode(&replication.PreviousGTIDsEvent{},
+		"0100000000000000"+"3e11fa4771ca11e19e33c80aa9429562"+"0100000000000000"+"0100000000000000"+"0600000000000000"))
+	if got := slave.GetStats()["gtid_set"]; got != sid+":1-5" {
+		t.Fatalf("expected previous gtids merged, got %v", got)
+	}
+
+	// gtid_mode=OFF 的事务：匿名 GTID 不并入集合，事务仍然计入提交
+	handle(slave, replication.ANONYMOUS_GTID_EVENT, &replication.GTIDEvent{SID: make([]byte, 16)})
+	handle(slave, replication.QUERY_EVENT, &replication.QueryEvent{Query: []byte("BEGIN")})
+	handle(slave, replication.XID_EVENT, &replication.XIDEvent{})
+	if got := slave.GetStats()["gtid_set"]; got != sid+":1-5" || slave.commits != 1 {
+		t.Errorf("expected anonymous gtid skipped, got %v after %d commits", got, slave.commits)
+	}
+
+	// 按语句记录的事务：INTVAR 和事务中的语句都不是提交点，XID 才提交
+	handle(slave, replication.GTID_EVENT, &replication.GTIDEvent{SID: []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}, GNO: 6})
+	handle(slave, replication.QUERY_EVENT, &replication.QueryEvent{Query: []byte("BEGIN")})
+	handle(slave, replication.INTVAR_EVENT, decode(&replication.IntVarEvent{}, "02"+"2a00000000000000"))
+	handle(slave, replication.QUERY_EVENT, &replication.QueryEvent{Schema: []byte("shop"), Query: []byte("INSERT INTO orders (note) VALUES ('a')")})
+	handle(slave, replication.QUERY_EVENT, &replication.QueryEvent{Query: []byte("SAVEPOINT `sp1`")})
+	if slave.commits != 1 || slave.currentGTID != sid+":6" {
+		t.Errorf("expected statements inside the transaction not to commit, got %d commits, gtid %q", slave.commits, slave.currentGTID)
+	}
+	handle(slave, replication.XID_EVENT, &replication.XIDEvent{})
+	if got := slave.GetStats()["gtid_set"]; got != sid+":1-6" || slave.commits != 2 {
+		t.Errorf("expected one commit for the statement transaction, got %v after %d commits", got, slave.commits)
+	}
+
+	// MariaDB：GTID_LIST 0-1-10 作为起点，非独立 GTID 事件开始事务，独立 GTID 事件之后的 DDL 即提交
+	maria := newSlave()
+	handle(maria, replication.MARIADB_GTID_LIST_EVENT, decode(&replication.MariadbGTIDListEvent{},
+		"01000000"+"00000000"+"01000000"+"0a00000000000000"))
+	if got := maria.GetStats()["gtid_set"]; got != "0-1-10" {
+		t.Fatalf("expected gtid list merged, got %v", got)
+	}
+	gtidEvent := func(raw string) replication.Event {
+		e := decode(&replication.MariadbGTIDEvent{}, raw).(*replication.MariadbGTIDEvent)
+		e.GTID.ServerID = 1 // 解析器从事件头填入
+		return e
+	}
+	handle(maria, replication.MARIADB_GTID_EVENT, gtidEvent("0b00000000000000"+"00000000"+"00"+"000000000000"))
+	handle(maria, replication.QUERY_EVENT, &replication.QueryEvent{Schema: []byte("shop"), Query: []byte("UPDATE orders SET note = 'b'")})
+	if maria.commits != 0 {
+		t.Errorf("expected no commit before XID, got %d", maria.commits)
+	}
+	handle(maria, replication.XID_EVENT, &replication.XIDEvent{})
+	handle(maria, replication.MARIADB_GTID_EVENT, gtidEvent("0c00000000000000"+"00000000"+"01"+"000000000000"))
+	handle(maria, replication.QUERY_EVENT, &replication.QueryEvent{Query: []byte("ALTER TABLE orders ADD COLUMN qty INT")})
+	if got := maria.GetStats()["gtid_set"]; got != "0-1-12" || maria.commits != 2 {
+		t.Errorf("expected 0-1-12 after 2 commits, got %v after %d", got, maria.commits)
+	}
+
+	// 较旧的 GTID_LIST 不会使已执行集合回退
+	handle(maria, replication.MARIADB_GTID_LIST_EVENT, decode(&replication.MariadbGTIDListEvent{},
+		"01000000"+"00000000"+"01000000"+"0a00000000000000"))
+	if got := maria.GetStats()["gtid_set"]; got != "0-1-12" {
+		t.Errorf("expected gtid set kept, got %v", got)
+	}
+
+	// 持久化的 MariaDB 集合按格式恢复
+	maria.restoreGTIDSet(Position{GTIDSet: "0-1-12"})
+	if _, ok := maria.gtidSet.(*mysql.MariadbGTIDSet); !ok {
+		t.Errorf("expected mariadb gtid set restored, got %T", maria.gtidSet)
+	}
+}
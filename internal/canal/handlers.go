@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"pikachun/internal/config"
 )
 
 // WebhookHandler Webhook事件处理器
@@ -18,6 +23,12 @@ type WebhookHandler struct {
 	callbackURL string
 	client      *http.Client
 	logger      *log.Logger
+	logSampler  *LogSampler        // 逐事件日志的采样，为空时全部输出
+	transports  *WebhookTransports // 按端点共享的连接池，为空时使用默认连接池
+
+	// 全局投递并发调度，为空表示不限制；priority 为任务的调度权重
+	scheduler *DeliveryScheduler
+	priority  int
 
 	// 批处理配置
 	batchSize    int
@@ -26,29 +37,110 @@ type WebhookHandler struct {
 	bufferMu     sync.Mutex
 	flushTimer   *time.Timer
 
+	// 内存记账：缓冲区和投递中的事件
+	eventBufferBytes int64 // 缓冲区中事件的字节数，受 bufferMu 保护
+	bufferedBytes    int64 // 缓冲区加投递中事件的字节数，原子访问
+	eventBufferCount int64 // 缓冲区收到的事件数（含被合并掉的），受 bufferMu 保护
+	pendingEvents    int64 // 缓冲区加投递中的事件数，原子访问
+
+	// 投递水位，见 DeliveredPosition
+	lastSeen   Position        // 最近收到的事件位置，受 bufferMu 保护
+	seenBefore Position        // lastSeen 之前的位置，受 bufferMu 保护
+	bufferFrom Position        // 缓冲区第一个事件之前的位置，受 bufferMu 保护
+	deliveryMu sync.Mutex      // 保护 deliveries，在 bufferMu 之后获取
+	deliveries []*deliveryMark // 未完成的投递，按刷新顺序
+
 	// 重试配置
 	maxRetries    int
 	retryInterval time.Duration
 
+	// 超时配置
+	timeouts DeliveryTimeouts
+	inflight sync.WaitGroup // 排队和进行中的异步投递
+
+	// 顺序投递队列，见 enqueueFlush
+	dispatchMu    sync.Mutex
+	dispatchQueue []*queuedFlush
+	dispatching   bool // 投递协程正在运行
+
+	// 投递历史记录
+	taskID   uint
+	recorder DeliveryRecorder
+
+	// 演练模式：不发送请求，只记录将要投递的内容
+	dryRun bool
+
+	// 分区路由：一批事件按分区键拆成多个请求，请求头 X-Partition-Key 标明分区
+	partitioner *PartitionRouter
+
+	// 数值安全编码：64 位整数和定点小数编码为字符串
+	numericStrings bool
+
+	// DELETE 事件的投递方式，见 DeleteModeBefore
+	deleteMode string
+
+	// 载荷映射：序列化时重命名列、展开行数据、删除元数据，为空表示原样序列化
+	mapping *PayloadMapping
+
+	// 来源标识：载荷的 source、environment 字段和 User-Agent 等请求头
+	identity SourceIdentity
+
+	// 事件最大存活时间：投递失败后超过存活时间的事件不再重试，为空表示不限制
+	expiry *EventExpiry
+
+	// 消费端契约：不符合契约的事件不投递，由 DatabaseHandler 在事件日志中记为 failed
+	contract           *ConsumerContract
+	contractViolations int64 // 违约的事件数，原子访问
+
+	// 数据质量规则：违规的事件计数并采样进报告，设置了隔离处理器时改投到隔离地址
+	quality      *QualityRules
+	qualityStats qualityTracker
+	quarantine   *WebhookHandler
+
+	// 请求体压缩，为空表示不压缩
+	compressor *PayloadCompressor
+
+	// 窗口合并：同一行在窗口内的变更合并后投递，为空表示不合并，受 bufferMu 保护
+	compactor      *EventCompactor
+	compactedCount int64 // 被合并掉的事件数，原子访问
+
+	// 表结构元数据：载荷中附带 schema 部分，只在表结构哈希变化后重新发送
+	includeSchema bool
+	sentSchemas   map[string]string // schema.table -> 已成功发送的结构哈希
+
+	// 请求序号：每个请求分配递增的序号，投递成功后记录序号与 binlog 位置的对应关系
+	sequence uint64 // 最近分配的序号，原子访问
+	cursors  CursorRecorder
+	seqMu    sync.Mutex
+	reserved uint64 // 已持久化的序号上限，受 seqMu 保护
+
 	// 性能统计
-	successCount int64
-	errorCount   int64
-	mu           sync.RWMutex
+	successCount  int64
+	errorCount    int64
+	failedEvents  int64     // 重试全部失败、未能投递的事件数
+	expiredEvents int64     // 超过最大存活时间不再重试的事件数，也计入 failedEvents
+	failingSince  time.Time // 连续投递失败的起始时间，投递成功后清零
+	lastError     string
+	lastErrorAt   time.Time
+	lastSuccessAt time.Time
+	mu            sync.RWMutex
 }
 
 // NewWebhookHandler 创建Webhook处理器
 func NewWebhookHandler(name, callbackURL string, logger *log.Logger) *WebhookHandler {
 	logger.Printf("🔧 Creating Webhook Handler (Name: %s, URL: %s)", name, callbackURL)
 
+	timeouts := DefaultDeliveryTimeouts()
 	handler := &WebhookHandler{
 		name:          name,
 		callbackURL:   callbackURL,
 		logger:        logger,
-		client:        &http.Client{Timeout: 30 * time.Second},
+		client:        &http.Client{Timeout: timeouts.Request},
 		batchSize:     10,              // 批处理大小
 		batchTimeout:  5 * time.Second, // 批处理超时
 		maxRetries:    3,               // 最大重试次数
 		retryInterval: time.Second,     // 重试间隔
+		timeouts:      timeouts,
 		eventBuffer:   make([]*Event, 0, 10),
 	}
 
@@ -56,6 +148,334 @@ func NewWebhookHandler(name, callbackURL string, logger *log.Logger) *WebhookHan
 	return handler
 }
 
+// maxResponseBodySize 投递历史中保存的响应体最大长度
+const maxResponseBodySize = 2048
+
+// maxDryRunPayloadSize 演练模式下投递历史中保存的请求体最大长度
+const maxDryRunPayloadSize = 16 * 1024
+
+// SetDeliveryRecorder 设置投递尝试记录器
+func (h *WebhookHandler) SetDeliveryRecorder(taskID uint, recorder DeliveryRecorder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.taskID = taskID
+	h.recorder = recorder
+	h.configureQuarantineLocked()
+}
+
+// SetDryRun 开启或关闭演练模式，对之后发起的投递生效
+func (h *WebhookHandler) SetDryRun(dryRun bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.dryRun != dryRun {
+		h.logger.Printf("🧪 Webhook handler %s dry run: %v", h.name, dryRun)
+		h.dryRun = dryRun
+	}
+	h.configureQuarantineLocked()
+}
+
+// SetNumericStrings 开启或关闭数值安全编码，对之后构建的请求体生效
+func (h *WebhookHandler) SetNumericStrings(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.numericStrings = enabled
+}
+
+// SetTransports 设置按端点共享的连接池
+func (h *WebhookHandler) SetTransports(transports *WebhookTransports) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.transports = transports
+	h.configureQuarantineLocked()
+}
+
+// SetLogSampler 设置逐事件日志的采样，需在处理事件前设置
+func (h *WebhookHandler) SetLogSampler(sampler *LogSampler) {
+	h.logSampler = sampler
+}
+
+// SetScheduler 设置全局投递并发调度器，为 nil 表示不限制
+func (h *WebhookHandler) SetScheduler(scheduler *DeliveryScheduler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.scheduler = scheduler
+	h.configureQuarantineLocked()
+}
+
+// SetPriority 设置任务的调度权重，并发已满时按权重分配，不大于 0 时按 1 计算
+func (h *WebhookHandler) SetPriority(priority int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.priority = priority
+	h.configureQuarantineLocked()
+}
+
+// acquireSlot 获取全局投递并发，没有调度器时直接返回
+func (h *WebhookHandler) acquireSlot(ctx context.Context) (func(), error) {
+	h.mu.RLock()
+	scheduler, priority := h.scheduler, h.priority
+	h.mu.RUnlock()
+	if scheduler == nil {
+		return func() {}, nil
+	}
+	return scheduler.Acquire(ctx, h.name, priority)
+}
+
+// SetCompressor 设置请求体压缩器，为 nil 表示不压缩
+func (h *WebhookHandler) SetCompressor(compressor *PayloadCompressor) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.compressor = compressor
+}
+
+// SetDeleteMode 设置 DELETE 事件的投递方式，对之后刷新的批次生效
+func (h *WebhookHandler) SetDeleteMode(mode string) error {
+	mode, err := ParseDeleteMode(mode)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.deleteMode = mode
+	return nil
+}
+
+// SetPayloadMapping 设置载荷映射规则，nil 表示原样序列化，对之后发送的请求生效
+func (h *WebhookHandler) SetPayloadMapping(mapping *PayloadMapping) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.mapping = mapping
+}
+
+// SetSourceIdentity 设置来源标识，对之后发送的请求生效
+func (h *WebhookHandler) SetSourceIdentity(identity SourceIdentity) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.identity = identity
+	h.configureQuarantineLocked()
+}
+
+// SetContract 设置消费端契约，nil 表示不校验，对之后收到的事件生效
+func (h *WebhookHandler) SetContract(contract *ConsumerContract) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.contract = contract
+}
+
+// CheckContract 按消费端契约校验事件投递时的载荷：按 DELETE 投递方式、数值安全编码和载荷映射转换后，
+// 逐个校验序列化后的事件（both 方式的原事件和墓碑分别校验）。未设置契约时返回 nil
+func (h *WebhookHandler) CheckContract(event *Event) []string {
+	h.mu.RLock()
+	contract, mode, numericStrings, mapping := h.contract, h.deleteMode, h.numericStrings, h.mapping
+	h.mu.RUnlock()
+	if contract == nil {
+		return nil
+	}
+
+	events := applyDeleteMode(mode, []*Event{event})
+	if numericStrings {
+		events = numericSafeEvents(events)
+	}
+	var violations []string
+	for _, delivered := range events {
+		violations = append(violations, contract.Validate(delivered, mapping)...)
+	}
+	return violations
+}
+
+// SetQualityRules 设置数据质量规则，nil 表示不检查，对之后收到的事件生效
+func (h *WebhookHandler) SetQualityRules(rules *QualityRules) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.quality = rules
+}
+
+// SetQuarantineURL 设置隔离地址，违反数据质量规则的事件改投到该地址而不是回调地址，为空表示照常投递。
+// 隔离处理器沿用主处理器的设置，见 configureQuarantineLocked，移除时先投递完缓冲中的事件
+func (h *WebhookHandler) SetQuarantineURL(quarantineURL string) {
+	h.mu.Lock()
+	current := h.quarantine
+	switch {
+	case quarantineURL == "":
+		h.quarantine = nil
+	case current == nil:
+		quarantine := NewWebhookHandler(h.name+"-quarantine", quarantineURL, h.logger)
+		quarantine.SetLogSampler(h.logSampler)
+		quarantine.maxRetries, quarantine.retryInterval = h.maxRetries, h.retryInterval
+		h.quarantine = quarantine
+	default:
+		current.SetCallbackURL(quarantineURL)
+	}
+	h.configureQuarantineLocked()
+	h.mu.Unlock()
+
+	if quarantineURL == "" && current != nil {
+		if err := current.Close(); err != nil {
+			h.logger.Printf("⚠️ Quarantine handler of %s closed with error: %v", h.name, err)
+		}
+	}
+}
+
+// configureQuarantineLocked 隔离处理器沿用主处理器的演练模式、投递超时、投递记录、并发调度、来源标识和连接池，
+// 主处理器的设置变化时重新应用，调用方持有 h.mu
+func (h *WebhookHandler) configureQuarantineLocked() {
+	quarantine := h.quarantine
+	if quarantine == nil {
+		return
+	}
+	quarantine.SetDryRun(h.dryRun)
+	if err := quarantine.SetTimeouts(h.timeouts); err != nil {
+		h.logger.Printf("⚠️ Failed to apply delivery timeouts to quarantine handler of %s: %v", h.name, err)
+	}
+	quarantine.SetDeliveryRecorder(h.taskID, h.recorder)
+	quarantine.SetScheduler(h.scheduler)
+	quarantine.SetPriority(h.priority)
+	quarantine.SetTransports(h.transports)
+	quarantine.SetSourceIdentity(h.identity)
+}
+
+// QualityReport 数据质量报告
+func (h *WebhookHandler) QualityReport() QualityReport {
+	h.mu.RLock()
+	quality := h.quality
+	h.mu.RUnlock()
+
+	report := h.qualityStats.report()
+	report.LookupErrors = quality.LookupErrors()
+	return report
+}
+
+// withDeleteMode 按 DELETE 投递方式转换事件
+func (h *WebhookHandler) withDeleteMode(events []*Event) []*Event {
+	h.mu.RLock()
+	mode := h.deleteMode
+	h.mu.RUnlock()
+	return applyDeleteMode(mode, events)
+}
+
+// SetIncludeSchema 开启或关闭载荷中的表结构元数据，重新开启后所有表的结构会再发送一次
+func (h *WebhookHandler) SetIncludeSchema(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.includeSchema != enabled {
+		h.includeSchema = enabled
+		h.sentSchemas = make(map[string]string)
+	}
+}
+
+// pendingSchemas 一批事件中尚未发送过当前结构的表，未开启时返回 nil
+func (h *WebhookHandler) pendingSchemas(events []*Event) map[string]*EventSchema {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if !h.includeSchema {
+		return nil
+	}
+
+	var schemas map[string]*EventSchema
+	for _, event := range events {
+		key := event.Schema + "." + event.Table
+		if _, ok := schemas[key]; ok {
+			continue
+		}
+		schema := eventSchema(event)
+		if schema == nil || h.sentSchemas[key] == schema.Hash {
+			continue
+		}
+		if schemas == nil {
+			schemas = make(map[string]*EventSchema)
+		}
+		schemas[key] = schema
+	}
+	return schemas
+}
+
+// markSchemasSent 投递成功后记录已发送的表结构
+func (h *WebhookHandler) markSchemasSent(schemas map[string]*EventSchema) {
+	if len(schemas) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.includeSchema {
+		return
+	}
+	for key, schema := range schemas {
+		h.sentSchemas[key] = schema.Hash
+	}
+}
+
+// deliveryBatch 作为一个请求投递的一批事件
+type deliveryBatch struct {
+	key      string // 分区键，为空表示不分区
+	sequence uint64 // 请求序号，为 0 表示不分配（如重新投递）
+	events   []*Event
+	schemas  map[string]*EventSchema // 随请求发送的表结构，见 pendingSchemas
+}
+
+// SetCursorRecorder 设置请求序号记录器，lastSequence 为已分配的最大序号，之后的请求从它的下一个开始
+func (h *WebhookHandler) SetCursorRecorder(recorder CursorRecorder, lastSequence uint64) {
+	h.seqMu.Lock()
+	defer h.seqMu.Unlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cursors = recorder
+	h.reserved = lastSequence
+	atomic.StoreUint64(&h.sequence, lastSequence)
+}
+
+// sequenceReserveBlock 每次预留的请求序号数量
+const sequenceReserveBlock = 1000
+
+// nextSequence 分配请求序号。超出已预留的上限时先持久化新的上限，
+// 投递失败或重启前未用完的序号不会再次分配，重启后从上限之后继续，序号可能不连续
+func (h *WebhookHandler) nextSequence() uint64 {
+	h.seqMu.Lock()
+	defer h.seqMu.Unlock()
+
+	sequence := atomic.AddUint64(&h.sequence, 1)
+	h.mu.RLock()
+	cursors, taskID := h.cursors, h.taskID
+	h.mu.RUnlock()
+	if cursors != nil && sequence > h.reserved {
+		reserved := sequence + sequenceReserveBlock - 1
+		if err := cursors.ReserveDeliverySequence(taskID, reserved); err != nil {
+			h.logger.Printf("⚠️ Failed to reserve delivery sequences for handler %s: %v", h.name, err)
+		} else {
+			h.reserved = reserved
+		}
+	}
+	return sequence
+}
+
+// SetPartitionRouter 设置分区路由，nil 表示不分区，对之后刷新的批次生效
+func (h *WebhookHandler) SetPartitionRouter(router *PartitionRouter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.partitioner = router
+}
+
+// SetTimeouts 设置投递超时，对之后发起的投递生效
+func (h *WebhookHandler) SetTimeouts(timeouts DeliveryTimeouts) error {
+	if err := timeouts.Validate(); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.timeouts = timeouts
+	h.client = &http.Client{Timeout: timeouts.Request}
+	h.configureQuarantineLocked()
+	return nil
+}
+
+// getTimeouts 获取当前投递超时配置
+func (h *WebhookHandler) getTimeouts() DeliveryTimeouts {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.timeouts
+}
+
 // GetName 获取处理器名称
 func (h *WebhookHandler) GetName() string {
 	return h.name
@@ -63,19 +483,48 @@ func (h *WebhookHandler) GetName() string {
 
 // Handle 处理事件（支持批处理）
 func (h *WebhookHandler) Handle(ctx context.Context, event *Event) error {
-	h.logger.Printf("📥 Webhook handler %s received event: %s.%s %s",
+	h.logSampler.Printf(h.logger, LogPathHandle, "📥 Webhook handler %s received event: %s.%s %s",
 		h.name, event.Schema, event.Table, event.EventType)
 
 	h.bufferMu.Lock()
 	defer h.bufferMu.Unlock()
+	h.noteReceived(event.Position)
+
+	if violations := h.CheckContract(event); len(violations) > 0 {
+		atomic.AddInt64(&h.contractViolations, 1)
+		h.logger.Printf("📜 Event %s skipped by handler %s: %s", event.ID, h.name, ContractViolationError(violations))
+		return nil
+	}
+
+	h.mu.RLock()
+	quality, quarantine := h.quality, h.quarantine
+	h.mu.RUnlock()
+	if quality != nil {
+		violations := quality.Check(ctx, event)
+		h.qualityStats.record(event, violations, len(violations) > 0 && quarantine != nil)
+		if len(violations) > 0 && quarantine != nil {
+			h.logger.Printf("🧪 Event %s quarantined by handler %s: %s", event.ID, h.name, QualityViolationError(violations))
+			return quarantine.Handle(ctx, event)
+		}
+	}
+
+	if h.compactor != nil {
+		return h.handleCompacted(ctx, event)
+	}
 
 	// 添加事件到缓冲区
+	h.noteBuffered()
 	h.eventBuffer = append(h.eventBuffer, event)
-	h.logger.Printf("📦 Added event to buffer, current buffer size: %d", len(h.eventBuffer))
+	size := EventSize(event)
+	h.eventBufferBytes += size
+	atomic.AddInt64(&h.bufferedBytes, size)
+	h.eventBufferCount++
+	atomic.AddInt64(&h.pendingEvents, 1)
+	h.logSampler.Printf(h.logger, LogPathHandle, "📦 Added event to buffer, current buffer size: %d", len(h.eventBuffer))
 
 	// 检查是否需要立即刷新
 	if len(h.eventBuffer) >= h.batchSize {
-		h.logger.Printf("📊 Buffer size reached batch size %d, flushing events", h.batchSize)
+		h.logSampler.Printf(h.logger, LogPathFlush, "📊 Buffer size reached batch size %d, flushing events", h.batchSize)
 		return h.flushEvents(ctx)
 	}
 
@@ -84,61 +533,123 @@ func (h *WebhookHandler) Handle(ctx context.Context, event *Event) error {
 		h.flushTimer.Stop()
 	}
 	h.flushTimer = time.AfterFunc(h.batchTimeout, func() {
-		h.logger.Printf("⏰ Batch timeout reached, flushing events")
+		h.logSampler.Printf(h.logger, LogPathFlush, "⏰ Batch timeout reached, flushing events")
 		h.bufferMu.Lock()
 		defer h.bufferMu.Unlock()
 		if len(h.eventBuffer) > 0 {
-			// 创建新的context，避免使用已取消的context
-			timeoutCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
-			h.flushEvents(timeoutCtx)
+			h.flushEvents(context.Background())
 		}
 	})
 
-	h.logger.Printf("✅ Event handled by webhook handler %s", h.name)
+	h.logSampler.Printf(h.logger, LogPathHandle, "✅ Event handled by webhook handler %s", h.name)
 	return nil
 }
 
+// handleCompacted 窗口合并模式：事件合并进缓冲区，窗口结束或缓冲的行数达到上限时投递，调用方需持有 bufferMu
+func (h *WebhookHandler) handleCompacted(ctx context.Context, event *Event) error {
+	var delta int64
+	var compacted int
+	h.noteBuffered()
+	h.eventBuffer, delta, compacted = h.compactor.add(h.eventBuffer, event)
+	h.eventBufferBytes += delta
+	atomic.AddInt64(&h.bufferedBytes, delta)
+	h.eventBufferCount++
+	atomic.AddInt64(&h.pendingEvents, 1)
+	if compacted > 0 {
+		atomic.AddInt64(&h.compactedCount, int64(compacted))
+	}
+
+	if h.compactor.rows() >= maxCompactRows {
+		h.logSampler.Printf(h.logger, LogPathFlush, "📊 Compaction buffer reached %d rows, flushing events", maxCompactRows)
+		return h.flushEvents(ctx)
+	}
+
+	// 窗口从缓冲区的第一个事件开始计时，之后的事件不重置定时器，热点行不会一直不投递
+	if h.flushTimer == nil {
+		h.flushTimer = time.AfterFunc(h.compactor.Window, func() {
+			h.logSampler.Printf(h.logger, LogPathFlush, "⏰ Compaction window reached, flushing events")
+			h.bufferMu.Lock()
+			defer h.bufferMu.Unlock()
+			h.flushEvents(context.Background())
+		})
+	}
+	return nil
+}
+
+// SetCompactor 设置窗口合并，nil 表示不合并；缓冲中的事件先按原配置投递
+func (h *WebhookHandler) SetCompactor(compactor *EventCompactor) {
+	h.bufferMu.Lock()
+	defer h.bufferMu.Unlock()
+	h.flushEvents(context.Background())
+	h.compactor = compactor
+}
+
 // flushEvents 刷新事件缓冲区
 func (h *WebhookHandler) flushEvents(ctx context.Context) error {
-	h.logger.Printf("🔄 Flushing events buffer, size: %d", len(h.eventBuffer))
-	if len(h.eventBuffer) == 0 {
-		h.logger.Printf("⚠️ Event buffer is empty, nothing to flush")
+	h.logSampler.Printf(h.logger, LogPathFlush, "🔄 Flushing events buffer, size: %d", len(h.eventBuffer))
+
+	// 停止定时器，缓冲区为空时也停止，关闭后不会残留定时器
+	if h.flushTimer != nil {
+		h.logSampler.Printf(h.logger, LogPathFlush, "⏰ Stopping flush timer")
+		h.flushTimer.Stop()
+		h.flushTimer = nil
+	}
+
+	// 窗口合并后缓冲区可能为空，但收到的事件仍需计为投递完成
+	if len(h.eventBuffer) == 0 && h.eventBufferCount == 0 {
+		h.logSampler.Printf(h.logger, LogPathFlush, "⚠️ Event buffer is empty, nothing to flush")
 		return nil
 	}
 
 	// 复制事件并清空缓冲区
-	events := make([]*Event, len(h.eventBuffer))
-	copy(events, h.eventBuffer)
+	var events []*Event
+	if h.compactor != nil {
+		events = h.compactor.drain(h.eventBuffer)
+	} else {
+		events = make([]*Event, len(h.eventBuffer))
+		copy(events, h.eventBuffer)
+	}
 	h.eventBuffer = h.eventBuffer[:0]
-	h.logger.Printf("📋 Copied %d events from buffer", len(events))
+	batchBytes, batchCount := h.eventBufferBytes, h.eventBufferCount
+	h.eventBufferBytes, h.eventBufferCount = 0, 0
+	h.logSampler.Printf(h.logger, LogPathFlush, "📋 Copied %d events from buffer", len(events))
 
-	// 停止定时器
-	if h.flushTimer != nil {
-		h.logger.Printf("⏰ Stopping flush timer")
-		h.flushTimer.Stop()
-		h.flushTimer = nil
+	// 合并后所有事件相互抵消
+	if len(events) == 0 {
+		atomic.AddInt64(&h.bufferedBytes, -batchBytes)
+		atomic.AddInt64(&h.pendingEvents, -batchCount)
+		return nil
 	}
 
-	// 异步发送事件 - 创建新的context避免使用已取消的context
-	h.logger.Printf("🚀 Sending %d events asynchronously", len(events))
-	sendCtx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	go func() {
-		defer cancel()
-		h.sendEventsWithRetry(sendCtx, events)
-	}()
-	h.logger.Printf("✅ Flush events completed")
+	// 异步发送事件，按刷新顺序逐个投递
+	h.logSampler.Printf(h.logger, LogPathFlush, "🚀 Sending %d events asynchronously", len(events))
+	batches := []deliveryBatch{{events: events}}
+	h.mu.RLock()
+	if h.partitioner != nil {
+		batches = h.partitioner.groupByPartition(events)
+	}
+	h.mu.RUnlock()
+	// 刷新时分配序号，重试沿用同一序号，消费端可据此去重
+	for i := range batches {
+		// 分区后再生成墓碑，墓碑与同一行的其他事件进入同一分区
+		batches[i].events = h.withDeleteMode(batches[i].events)
+		batches[i].sequence = h.nextSequence()
+	}
+	h.enqueueFlush(&queuedFlush{batches: batches, mark: h.beginDelivery(), bytes: batchBytes, count: batchCount})
+	h.logSampler.Printf(h.logger, LogPathFlush, "✅ Flush events completed")
 	return nil
 }
 
 // sendEventsWithRetry 带重试的事件发送
-func (h *WebhookHandler) sendEventsWithRetry(ctx context.Context, events []*Event) {
-	h.logger.Printf("🔄 Starting send events with retry, events: %d, max retries: %d",
-		len(events), h.maxRetries)
+// 接收方在逐事件确认中拒绝部分事件时，已确认的事件计为投递成功，之后只重试被拒绝的事件
+func (h *WebhookHandler) sendEventsWithRetry(ctx context.Context, batch deliveryBatch) {
+	original := batch
+	h.logSampler.Printf(h.logger, LogPathFlush, "🔄 Starting send events with retry, events: %d, max retries: %d",
+		len(batch.events), h.maxRetries)
 	var lastErr error
 
 	for attempt := 0; attempt <= h.maxRetries; attempt++ {
-		h.logger.Printf("📤 Sending attempt %d/%d", attempt+1, h.maxRetries+1)
+		h.logSampler.Printf(h.logger, LogPathFlush, "📤 Sending attempt %d/%d", attempt+1, h.maxRetries+1)
 		if attempt > 0 {
 			// 指数退避
 			backoff := time.Duration(attempt) * h.retryInterval
@@ -148,87 +659,431 @@ func (h *WebhookHandler) sendEventsWithRetry(ctx context.Context, events []*Even
 				h.logger.Printf("🛑 Context cancelled during backoff")
 				return
 			case <-time.After(backoff):
-				h.logger.Printf("⏰ Backoff completed")
+				h.logSampler.Printf(h.logger, LogPathFlush, "⏰ Backoff completed")
+			}
+
+			// 超过最大存活时间的事件不再重试
+			if batch.events = h.expireEvents(batch.events); len(batch.events) == 0 {
+				break
 			}
 		}
 
-		if err := h.sendEvents(ctx, events); err != nil {
+		if _, err := h.deliver(ctx, batch, attempt+1); err != nil {
 			lastErr = err
 			h.logger.Printf("❌ Attempt %d failed for handler %s: %v", attempt+1, h.name, err)
 
 			h.mu.Lock()
 			h.errorCount++
+			h.lastError, h.lastErrorAt = err.Error(), time.Now()
 			h.mu.Unlock()
 
+			var nackErr *NackError
+			if errors.As(err, &nackErr) {
+				remaining := nackErr.nackedEvents(batch.events)
+				h.mu.Lock()
+				h.successCount += int64(len(batch.events) - len(remaining))
+				h.mu.Unlock()
+				batch.events = remaining
+			}
 			continue
 		}
 
 		// 成功发送
-		h.logger.Printf("✅ Successfully sent %d events to %s", len(events), h.callbackURL)
+		h.logSampler.Printf(h.logger, LogPathFlush, "✅ Successfully sent %d events to %s", len(batch.events), h.getCallbackURL())
 		h.mu.Lock()
-		h.successCount += int64(len(events))
+		h.successCount += int64(len(batch.events))
+		h.failingSince = time.Time{}
+		h.lastSuccessAt = time.Now()
 		h.mu.Unlock()
 
-		h.logger.Printf("🎉 All events sent successfully on attempt %d", attempt+1)
+		h.recordCursor(original)
+		h.logSampler.Printf(h.logger, LogPathFlush, "🎉 All events sent successfully on attempt %d", attempt+1)
 		return
 	}
 
 	// 所有重试都失败了
+	if ctx.Err() != nil {
+		h.logger.Printf("⏰ Delivery timeout %v exceeded for handler %s", h.getTimeouts().Delivery, h.name)
+	}
 	h.logger.Printf("💥 Failed to send events after %d attempts to %s: %v",
-		h.maxRetries+1, h.callbackURL, lastErr)
+		h.maxRetries+1, h.getCallbackURL(), lastErr)
+
+	h.mu.Lock()
+	h.failedEvents += int64(len(batch.events))
+	if h.failingSince.IsZero() {
+		h.failingSince = time.Now()
+	}
+	recorder, taskID := h.recorder, h.taskID
+	h.mu.Unlock()
+
+	// 仍被拒绝的事件记入失败事件列表，全部过期时已经记录过
+	var nackErr *NackError
+	if errors.As(lastErr, &nackErr) && len(batch.events) > 0 {
+		if failed, ok := recorder.(FailedEventRecorder); ok {
+			if err := failed.RecordFailedEvents(failedEventEntries(taskID, nackErr.Nacks, batch.events)); err != nil {
+				h.logger.Printf("⚠️ Failed to record nacked events for handler %s: %v", h.name, err)
+			}
+		}
+	}
 }
 
-// sendEvents 发送事件到Webhook
-func (h *WebhookHandler) sendEvents(ctx context.Context, events []*Event) error {
-	h.logger.Printf("📤 Sending %d events to webhook: %s", len(events), h.callbackURL)
+// Deliver 同步投递一批事件（不重试），并记录本次投递尝试
+func (h *WebhookHandler) Deliver(ctx context.Context, events []*Event, attempt int) (DeliveryAttempt, error) {
+	return h.deliver(ctx, deliveryBatch{events: h.withDeleteMode(events)}, attempt)
+}
 
-	// 构建请求体
-	h.logger.Printf("🔧 Building payload with %d events", len(events))
+// DeliverPartition 同步投递同一分区的一批事件（不重试），partitionKey 为空表示不分区
+func (h *WebhookHandler) DeliverPartition(ctx context.Context, partitionKey string, events []*Event, attempt int) (DeliveryAttempt, error) {
+	return h.deliver(ctx, deliveryBatch{key: partitionKey, events: h.withDeleteMode(events)}, attempt)
+}
+
+// deliver 投递一个请求（不重试），并记录本次投递尝试
+func (h *WebhookHandler) deliver(ctx context.Context, batch deliveryBatch, attempt int) (DeliveryAttempt, error) {
+	events := batch.events
+	h.mu.RLock()
+	recorder, taskID, dryRun := h.recorder, h.taskID, h.dryRun
+	h.mu.RUnlock()
+
+	eventIDs := make([]string, len(events))
+	for i, event := range events {
+		eventIDs[i] = event.ID
+	}
+
+	batch.schemas = h.pendingSchemas(events)
+
+	start := time.Now()
+	var statusCode int
+	var body, payload string
+	var nacks []EventNack
+	var sendErr error
+	if dryRun {
+		payload, sendErr = h.dryRunEvents(batch)
+	} else if release, err := h.acquireSlot(ctx); err != nil {
+		sendErr = fmt.Errorf("failed to acquire delivery slot: %v", err)
+	} else {
+		statusCode, body, nacks, sendErr = h.sendEvents(ctx, batch)
+		release()
+		if sendErr == nil {
+			// 接收方已收到请求，表结构不随被拒绝的事件重发
+			h.markSchemasSent(batch.schemas)
+			if len(nacks) > 0 {
+				sendErr = &NackError{Total: len(events), Nacks: nacks}
+			}
+		}
+	}
+
+	record := DeliveryAttempt{
+		TaskID:       taskID,
+		EventIDs:     eventIDs,
+		Attempt:      attempt,
+		URL:          h.getCallbackURL(),
+		StatusCode:   statusCode,
+		Latency:      time.Since(start),
+		ResponseBody: body,
+		DryRun:       dryRun,
+		Payload:      payload,
+		PartitionKey: batch.key,
+		Sequence:     batch.sequence,
+		Nacked:       nacks,
+	}
+	if sendErr != nil {
+		record.Error = sendErr.Error()
+	}
+
+	// 记录失败不影响投递
+	if recorder != nil {
+		if err := recorder.RecordDeliveryAttempt(record); err != nil {
+			h.logger.Printf("⚠️ Failed to record delivery attempt for handler %s: %v", h.name, err)
+		}
+	}
+
+	return record, sendErr
+}
+
+// buildPayload 构建 Webhook 请求体
+func (h *WebhookHandler) buildPayload(batch deliveryBatch) ([]byte, error) {
+	h.logSampler.Printf(h.logger, LogPathFlush, "🔧 Building payload with %d events", len(batch.events))
+	h.mu.RLock()
+	numericStrings, mapping, identity := h.numericStrings, h.mapping, h.identity
+	h.mu.RUnlock()
+
+	events := batch.events
+	if numericStrings {
+		events = numericSafeEvents(events)
+	}
 	payload := map[string]interface{}{
 		"events":    events,
 		"timestamp": time.Now().Unix(),
-		"source":    "canal-pikachun",
+		"source":    identity.source(),
+	}
+	if identity.Environment != "" {
+		payload["environment"] = identity.Environment
+	}
+	if mapping != nil {
+		payload["events"] = mapping.apply(events)
+	}
+	if batch.key != "" {
+		payload["partition_key"] = batch.key
+	}
+	if len(batch.schemas) > 0 {
+		payload["schema"] = batch.schemas
+	}
+	if batch.sequence > 0 {
+		payload["sequence"] = batch.sequence
+		if numericStrings {
+			payload["sequence"] = strconv.FormatUint(batch.sequence, 10)
+		}
 	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		h.logger.Printf("❌ Failed to marshal events: %v", err)
-		return fmt.Errorf("failed to marshal events: %v", err)
+		return nil, fmt.Errorf("failed to marshal events: %v", err)
+	}
+	h.logSampler.Printf(h.logger, LogPathFlush, "✅ Payload marshaled, size: %d bytes", len(jsonData))
+	return jsonData, nil
+}
+
+// dryRunEvents 演练模式下构建请求体但不发送，返回截断后的请求体
+func (h *WebhookHandler) dryRunEvents(batch deliveryBatch) (string, error) {
+	jsonData, err := h.buildPayload(batch)
+	if err != nil {
+		return "", err
+	}
+	h.logger.Printf("🧪 Dry run: would send %d events (%d bytes) to %s", len(batch.events), len(jsonData), h.getCallbackURL())
+
+	if len(jsonData) > maxDryRunPayloadSize {
+		jsonData = jsonData[:maxDryRunPayloadSize]
+	}
+	return string(jsonData), nil
+}
+
+// sendEvents 发送事件到Webhook，返回响应状态码、截断后的响应体和 2xx 响应中被接收方拒绝的事件
+func (h *WebhookHandler) sendEvents(ctx context.Context, batch deliveryBatch) (int, string, []EventNack, error) {
+	events := batch.events
+	callbackURL := h.getCallbackURL()
+	h.logSampler.Printf(h.logger, LogPathFlush, "📤 Sending %d events to webhook: %s", len(events), callbackURL)
+
+	// 构建请求体
+	jsonData, err := h.buildPayload(batch)
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	if fault, ok := Faults.Trigger(FaultWebhook5xx, h.name); ok {
+		body := fault.Err().Error()
+		h.logger.Printf("💥 Injected status %d for webhook %s", fault.StatusCode, callbackURL)
+		return fault.StatusCode, body, nil, fmt.Errorf("webhook %s returned status %d: %s", callbackURL, fault.StatusCode, body)
+	}
+
+	// 压缩请求体
+	h.mu.RLock()
+	compressor, identity := h.compressor, h.identity
+	h.mu.RUnlock()
+	var encoding string
+	if compressor != nil {
+		size := len(jsonData)
+		jsonData, encoding, err = compressor.Compress(jsonData)
+		if err != nil {
+			return 0, "", nil, err
+		}
+		if encoding != "" {
+			h.logSampler.Printf(h.logger, LogPathFlush, "🗜️ Payload compressed with %s: %d -> %d bytes", encoding, size, len(jsonData))
+		}
 	}
-	h.logger.Printf("✅ Payload marshaled, size: %d bytes", len(jsonData))
 
 	// 创建HTTP请求
-	h.logger.Printf("🔧 Creating HTTP request to %s", h.callbackURL)
-	req, err := http.NewRequestWithContext(ctx, "POST", h.callbackURL, bytes.NewBuffer(jsonData))
+	h.logSampler.Printf(h.logger, LogPathFlush, "🔧 Creating HTTP request to %s", callbackURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", callbackURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		h.logger.Printf("❌ Failed to create request: %v", err)
-		return fmt.Errorf("failed to create request: %v", err)
+		return 0, "", nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "Canal-Pikachun/1.0")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	identity.setHeaders(req.Header)
 	req.Header.Set("X-Event-Count", fmt.Sprintf("%d", len(events)))
-	h.logger.Printf("📋 Request headers set: Content-Type=application/json, User-Agent=Canal-Pikachun/1.0, X-Event-Count=%d", len(events))
+	if batch.key != "" {
+		req.Header.Set("X-Partition-Key", batch.key)
+	}
+	if batch.sequence > 0 {
+		req.Header.Set("X-Delivery-Sequence", strconv.FormatUint(batch.sequence, 10))
+	}
+	h.logSampler.Printf(h.logger, LogPathFlush, "📋 Request headers set: Content-Type=application/json, User-Agent=%s, X-Event-Count=%d", req.Header.Get("User-Agent"), len(events))
 
 	// 发送请求
-	h.logger.Printf("🚀 Sending HTTP request to %s", h.callbackURL)
-	resp, err := h.client.Do(req)
+	h.logSampler.Printf(h.logger, LogPathFlush, "🚀 Sending HTTP request to %s", callbackURL)
+	h.mu.RLock()
+	client, transports := h.client, h.transports
+	h.mu.RUnlock()
+	if transports != nil {
+		pooled := *client
+		pooled.Transport = transports.RoundTripper(callbackURL)
+		client = &pooled
+	}
+	resp, err := client.Do(req)
 	if err != nil {
-		h.logger.Printf("❌ Failed to send request to %s: %v", h.callbackURL, err)
-		return fmt.Errorf("failed to send request to %s: %v", h.callbackURL, err)
+		h.logger.Printf("❌ Failed to send request to %s: %v", callbackURL, err)
+		return 0, "", nil, fmt.Errorf("failed to send request to %s: %v", callbackURL, err)
 	}
 	defer resp.Body.Close()
-	h.logger.Printf("✅ HTTP request sent to %s, status: %d", h.callbackURL, resp.StatusCode)
+	h.logSampler.Printf(h.logger, LogPathFlush, "✅ HTTP request sent to %s, status: %d", callbackURL, resp.StatusCode)
+
+	// 读取足够解析逐事件确认的长度，投递历史中只保存截断后的响应体
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxAckResponseSize))
+	body := string(data)
+	if len(body) > maxResponseBodySize {
+		body = body[:maxResponseBodySize]
+	}
+
+	// 按接收方声明的 Accept-Encoding 或 415 响应调整之后请求的编码
+	if compressor != nil && compressor.Negotiate(resp.StatusCode, resp.Header.Get("Accept-Encoding"), encoding) {
+		h.logger.Printf("🗜️ Webhook %s negotiated payload encoding: %s", callbackURL, compressor.Encoding())
+	}
 
 	// 检查响应状态
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		h.logger.Printf("❌ Webhook %s returned status %d: %s", h.callbackURL, resp.StatusCode, string(body))
-		return fmt.Errorf("webhook %s returned status %d: %s", h.callbackURL, resp.StatusCode, string(body))
+		h.logger.Printf("❌ Webhook %s returned status %d: %s", callbackURL, resp.StatusCode, body)
+		return resp.StatusCode, body, nil, fmt.Errorf("webhook %s returned status %d: %s", callbackURL, resp.StatusCode, body)
 	}
 
-	h.logger.Printf("🎉 Webhook request to %s successful", h.callbackURL)
-	return nil
+	if nacks := parseAckResponse(data, events); len(nacks) > 0 {
+		h.logger.Printf("↩️ Webhook %s nacked %d of %d events", callbackURL, len(nacks), len(events))
+		return resp.StatusCode, body, nacks, nil
+	}
+
+	h.logSampler.Printf(h.logger, LogPathFlush, "🎉 Webhook request to %s successful", callbackURL)
+	return resp.StatusCode, body, nil, nil
+}
+
+// recordCursor 记录投递成功的请求序号与 binlog 位置的对应关系，演练模式不记录
+func (h *WebhookHandler) recordCursor(batch deliveryBatch) {
+	h.mu.RLock()
+	cursors, taskID, dryRun := h.cursors, h.taskID, h.dryRun
+	h.mu.RUnlock()
+	if cursors == nil || dryRun || batch.sequence == 0 || len(batch.events) == 0 {
+		return
+	}
+
+	cursor := DeliveryCursor{
+		TaskID:       taskID,
+		Sequence:     batch.sequence,
+		PartitionKey: batch.key,
+		First:        batch.events[0].Position,
+		Last:         batch.events[len(batch.events)-1].Position,
+		EventCount:   len(batch.events),
+	}
+	// 记录失败不影响投递
+	if err := cursors.RecordDeliveryCursor(cursor); err != nil {
+		h.logger.Printf("⚠️ Failed to record delivery cursor for handler %s: %v", h.name, err)
+	}
+}
+
+// BufferedBytes 缓冲区和投递中的事件占用的字节数
+func (h *WebhookHandler) BufferedBytes() int64 {
+	return atomic.LoadInt64(&h.bufferedBytes)
+}
+
+// PendingEvents 缓冲区加投递中的事件数，用于延迟保护
+func (h *WebhookHandler) PendingEvents() int64 {
+	return atomic.LoadInt64(&h.pendingEvents)
+}
+
+// Flush 立即投递缓冲区中的事件，投递异步进行
+func (h *WebhookHandler) Flush(ctx context.Context) error {
+	h.mu.RLock()
+	quarantine := h.quarantine
+	h.mu.RUnlock()
+	if quarantine != nil {
+		if err := quarantine.Flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	h.bufferMu.Lock()
+	defer h.bufferMu.Unlock()
+	return h.flushEvents(ctx)
+}
+
+// Close 刷新缓冲区，并在停止超时内等待进行中的投递完成
+func (h *WebhookHandler) Close() error {
+	shutdown := h.getTimeouts().Shutdown
+
+	h.mu.RLock()
+	quarantine := h.quarantine
+	h.mu.RUnlock()
+	if quarantine != nil {
+		if err := quarantine.Close(); err != nil {
+			h.logger.Printf("⚠️ Quarantine handler of %s closed with error: %v", h.name, err)
+		}
+	}
+
+	h.bufferMu.Lock()
+	h.flushEvents(context.Background())
+	h.bufferMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(shutdown):
+		return fmt.Errorf("timed out after %v waiting for in-flight deliveries", shutdown)
+	}
+}
+
+// SetCallbackURL 更新回调地址，缓冲区中未发送的事件将发往新地址
+func (h *WebhookHandler) SetCallbackURL(callbackURL string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.callbackURL != callbackURL {
+		h.logger.Printf("🔀 Webhook handler %s callback URL changed: %s -> %s", h.name, h.callbackURL, callbackURL)
+		h.callbackURL = callbackURL
+	}
+}
+
+// getCallbackURL 获取当前回调地址
+func (h *WebhookHandler) getCallbackURL() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.callbackURL
+}
+
+// FailingSince 获取连续投递失败的起始时间，未失败时返回零值
+func (h *WebhookHandler) FailingSince() time.Time {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.failingSince
+}
+
+// DeliveryCounts 获取累计投递成功和重试全部失败的事件数
+func (h *WebhookHandler) DeliveryCounts() (delivered, failed int64) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.successCount, h.failedEvents
+}
+
+// DeliveryStatus 投递到回调地址的结果，重试全部失败的事件记入失败事件列表
+func (h *WebhookHandler) DeliveryStatus() DeliveryStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return DeliveryStatus{
+		Delivered:     h.successCount,
+		Pending:       atomic.LoadInt64(&h.pendingEvents),
+		Failed:        h.errorCount,
+		DeadLettered:  h.failedEvents,
+		LastError:     h.lastError,
+		LastErrorAt:   h.lastErrorAt,
+		LastSuccessAt: h.lastSuccessAt,
+	}
 }
 
 // GetStats 获取处理器统计信息
@@ -236,38 +1091,121 @@ func (h *WebhookHandler) GetStats() map[string]interface{} {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"name":          h.name,
 		"callback_url":  h.callbackURL,
 		"success_count": h.successCount,
 		"error_count":   h.errorCount,
+		"failed_events": h.failedEvents,
+		"failing_since": h.failingSince,
 		"buffer_size":   len(h.eventBuffer),
+		"dry_run":       h.dryRun,
+	}
+	if queued := h.queuedFlushes(); queued > 0 {
+		stats["queued_flushes"] = queued
+	}
+	if h.partitioner != nil {
+		stats["partition_by"] = h.partitioner.Strategy
+	}
+	if h.compressor != nil {
+		stats["compression"] = h.compressor.Encoding()
+	}
+	if h.cursors != nil {
+		stats["last_sequence"] = atomic.LoadUint64(&h.sequence)
+	}
+	if h.deleteMode != "" && h.deleteMode != DeleteModeBefore {
+		stats["delete_mode"] = h.deleteMode
+	}
+	if h.mapping != nil {
+		stats["payload_mapping"] = true
+	}
+	if h.identity.Name != "" {
+		stats["source_name"] = h.identity.Name
+	}
+	if h.identity.Environment != "" {
+		stats["environment"] = h.identity.Environment
+	}
+	if h.contract != nil {
+		stats["contract_violations"] = atomic.LoadInt64(&h.contractViolations)
+	}
+	if h.quality != nil {
+		stats["quality_violations"], stats["quarantined_events"] = h.qualityStats.totals()
+	}
+	if compacted := atomic.LoadInt64(&h.compactedCount); compacted > 0 {
+		stats["compacted_events"] = compacted
+	}
+	if h.expiry != nil {
+		stats["max_event_age"] = h.expiry.MaxAge.Seconds()
+		stats["expired_action"] = h.expiry.Action
+		stats["expired_events"] = h.expiredEvents
 	}
+	return stats
 }
 
 // DatabaseHandler 数据库处理器
+// 事件日志先进入有界队列，由后台协程批量写入，避免高吞吐表受限于 SQLite 写入延迟
 type DatabaseHandler struct {
-	name      string
-	taskID    uint
-	logger    *log.Logger
-	dbService EventLogger
-	enabled   bool
+	name       string
+	taskID     uint
+	logger     *log.Logger
+	logSampler *LogSampler // 逐事件日志的采样，为空时全部输出
+	dbService  EventLogger
+	enabled    bool
+
+	// 批量写入配置
+	batchSize     int
+	flushInterval time.Duration
+	queue         chan EventLogEntry
+	startOnce     sync.Once
+	closeOnce     sync.Once
+	closed        chan struct{}
+	done          chan struct{}
+	flushReq      chan chan struct{} // 请求后台协程立即写入，写入后关闭回复通道
+	queuedBytes   int64              // 队列和待写入批次中事件日志的字节数，原子访问
 
 	mu           sync.RWMutex
+	dryRun       bool            // 演练模式，事件日志状态记为 dry_run
+	contract     ContractChecker // 消费端契约校验，违约的事件记为 failed
+	quality      *QualityRules   // 数据质量规则，quarantine 为 true 时违规的事件记为 quarantined
+	quarantine   bool
 	processCount int64
+	writtenCount int64
+	batchCount   int64
+	errorCount   int64
+	lastError    string
+	lastErrorAt  time.Time
+	lastWriteAt  time.Time
 }
 
 // NewDatabaseHandler 创建数据库处理器
-// NewDatabaseHandler 创建数据库处理器
-func NewDatabaseHandler(name string, taskID uint, logger *log.Logger, dbService EventLogger, enabled bool) *DatabaseHandler {
-	logger.Printf("🔧 Creating Database Handler (Name: %s, TaskID: %d, Enabled: %t)", name, taskID, enabled)
+func NewDatabaseHandler(name string, taskID uint, logger *log.Logger, dbService EventLogger, cfg config.DatabaseStorageConfig) *DatabaseHandler {
+	logger.Printf("🔧 Creating Database Handler (Name: %s, TaskID: %d, Enabled: %t)", name, taskID, cfg.Enabled)
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	queueSize := cfg.QueueSize
+	if queueSize < batchSize {
+		queueSize = batchSize
+	}
+	flushInterval, err := time.ParseDuration(cfg.FlushInterval)
+	if err != nil || flushInterval <= 0 {
+		flushInterval = time.Second
+	}
 
 	handler := &DatabaseHandler{
-		name:      name,
-		taskID:    taskID,
-		logger:    logger,
-		dbService: dbService,
-		enabled:   enabled,
+		name:          name,
+		taskID:        taskID,
+		logger:        logger,
+		dbService:     dbService,
+		enabled:       cfg.Enabled,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		queue:         make(chan EventLogEntry, queueSize),
+		closed:        make(chan struct{}),
+		done:          make(chan struct{}),
+		flushReq:      make(chan chan struct{}),
 	}
 
 	logger.Printf("✅ Database Handler created successfully (Name: %s)", name)
@@ -279,50 +1217,308 @@ func (h *DatabaseHandler) GetName() string {
 	return h.name
 }
 
-// Handle 处理事件
+// Handle 处理事件，事件日志异步批量写入；队列满时阻塞直到超时
 func (h *DatabaseHandler) Handle(ctx context.Context, event *Event) error {
 	h.mu.Lock()
 	h.processCount++
+	dryRun, contract := h.dryRun, h.contract
+	quality, quarantine := h.quality, h.quarantine
 	h.mu.Unlock()
 
 	// 检查是否启用了数据库存储功能
 	if !h.enabled {
-		h.logger.Printf("📥 Database handler %s received event: %s.%s %s (database storage disabled)",
+		h.logSampler.Printf(h.logger, LogPathHandle, "📥 Database handler %s received event: %s.%s %s (database storage disabled)",
 			h.name, event.Schema, event.Table, event.EventType)
 		return nil
 	}
 
-	// 这里可以将事件保存到数据库
-	h.logger.Printf("📥 Database handler %s received event: %s.%s %s",
+	h.logSampler.Printf(h.logger, LogPathHandle, "📥 Database handler %s received event: %s.%s %s",
 		h.name, event.Schema, event.Table, event.EventType)
 
-	// 记录事件详情
-	if event.BeforeData != nil {
-		h.logger.Printf("📋 Before data: %+v", event.BeforeData)
+	// 实际的数据库保存逻辑
+	entry := newEventLogEntry(h.taskID, event)
+	entry.Status = "success"
+	if dryRun {
+		entry.Status = "dry_run"
 	}
-	if event.AfterData != nil {
-		h.logger.Printf("📋 After data: %+v", event.AfterData)
+	// 违反数据质量规则的事件改投到隔离地址，记为 quarantined
+	if quarantine {
+		if violations := quality.Check(ctx, event); len(violations) > 0 {
+			entry.Status = "quarantined"
+			entry.Error = QualityViolationError(violations)
+		}
+	}
+	// 违约的事件不会投递，记为 failed 进入失败事件列表，修正契约或消费端后可重新投递
+	if contract != nil {
+		if violations := contract.CheckContract(event); len(violations) > 0 {
+			entry.Status = "failed"
+			entry.Error = ContractViolationError(violations)
+		}
 	}
 
-	// 实际的数据库保存逻辑
+	// 已关闭时同步写入
+	select {
+	case <-h.closed:
+		return h.writeBatch([]EventLogEntry{entry})
+	default:
+	}
+
+	h.startOnce.Do(func() {
+		go h.run()
+	})
+
+	size := eventLogEntrySize(entry)
+	atomic.AddInt64(&h.queuedBytes, size)
+	select {
+	case h.queue <- entry:
+		return nil
+	case <-h.closed:
+		atomic.AddInt64(&h.queuedBytes, -size)
+		return h.writeBatch([]EventLogEntry{entry})
+	case <-ctx.Done():
+		atomic.AddInt64(&h.queuedBytes, -size)
+		h.logger.Printf("❌ Event log queue of handler %s is full, dropping event %s", h.name, event.ID)
+		err := fmt.Errorf("event log queue full: %v", ctx.Err())
+		h.recordError(err)
+		return err
+	}
+}
+
+// newEventLogEntry 事件的事件日志记录，状态由调用方设置
+func newEventLogEntry(taskID uint, event *Event) EventLogEntry {
 	data := ""
-	if event.AfterData != nil {
-		// 将AfterData转换为JSON字符串
-		dataBytes, _ := json.Marshal(event.AfterData)
+	rowData := event.AfterData
+	if rowData == nil {
+		// DELETE 事件保存删除前的数据，便于重新投递
+		rowData = event.BeforeData
+	}
+	if rowData != nil {
+		// 将行数据转换为JSON字符串
+		dataBytes, _ := json.Marshal(rowData)
 		data = string(dataBytes)
 	}
+	beforeData := ""
+	if event.AfterData != nil && event.BeforeData != nil {
+		// UPDATE 事件同时保存修改前的数据，重新投递时两份数据都能还原
+		dataBytes, _ := json.Marshal(event.BeforeData)
+		beforeData = string(dataBytes)
+	}
+	return EventLogEntry{
+		TaskID:      taskID,
+		EventID:     event.ID,
+		Database:    event.Schema,
+		Table:       event.Table,
+		EventType:   string(event.EventType),
+		ContentHash: event.ContentHash,
+		Data:        data,
+		BeforeData:  beforeData,
+	}
+}
 
-	// 调用TaskService的CreateEventLog方法
-	err := h.dbService.CreateEventLog(h.taskID, event.Schema, event.Table, string(event.EventType), data, "success", "")
-	if err != nil {
-		h.logger.Printf("❌ Failed to save event log to database: %v", err)
+// SetLogSampler 设置逐事件日志的采样，需在处理事件前设置
+func (h *DatabaseHandler) SetLogSampler(sampler *LogSampler) {
+	h.logSampler = sampler
+}
+
+// SetDryRun 开启或关闭演练模式，之后的事件日志状态记为 dry_run
+func (h *DatabaseHandler) SetDryRun(dryRun bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dryRun = dryRun
+}
+
+// SetContract 设置契约校验，通常为同一任务的 WebhookHandler，按实际投递的载荷校验，nil 表示不校验
+func (h *DatabaseHandler) SetContract(checker ContractChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.contract = checker
+}
+
+// SetQualityRules 设置数据质量规则，与同一任务的 WebhookHandler 使用同一规则；
+// quarantine 表示任务设置了隔离地址，违规的事件不发往回调地址
+func (h *DatabaseHandler) SetQualityRules(rules *QualityRules, quarantine bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.quality = rules
+	h.quarantine = quarantine
+}
+
+// run 后台批量写入协程
+func (h *DatabaseHandler) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]EventLogEntry, 0, h.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		h.writeBatch(batch)
+		h.releaseBytes(batch)
+		batch = make([]EventLogEntry, 0, h.batchSize)
+	}
+
+	for {
+		select {
+		case entry := <-h.queue:
+			batch = append(batch, entry)
+			if len(batch) >= h.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case reply := <-h.flushReq:
+			// 写入队列中已有的事件
+			for drained := false; !drained; {
+				select {
+				case entry := <-h.queue:
+					batch = append(batch, entry)
+					if len(batch) >= h.batchSize {
+						flush()
+					}
+				default:
+					drained = true
+				}
+			}
+			flush()
+			close(reply)
+		case <-h.closed:
+			// 写入队列中剩余的事件
+			for {
+				select {
+				case entry := <-h.queue:
+					batch = append(batch, entry)
+					if len(batch) >= h.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeBatch 批量写入事件日志
+func (h *DatabaseHandler) writeBatch(batch []EventLogEntry) error {
+	if err := h.dbService.CreateEventLogs(batch); err != nil {
+		h.logger.Printf("❌ Failed to save %d event logs to database: %v", len(batch), err)
+		h.recordError(err)
 		return err
 	}
 
-	h.logger.Printf("✅ Database handler %s processed event successfully", h.name)
+	h.mu.Lock()
+	h.writtenCount += int64(len(batch))
+	h.batchCount++
+	h.lastWriteAt = time.Now()
+	h.mu.Unlock()
+
+	h.logSampler.Printf(h.logger, LogPathFlush, "✅ Database handler %s saved %d event logs", h.name, len(batch))
+	return nil
+}
+
+// Flush 立即写入队列中的事件日志
+func (h *DatabaseHandler) Flush(ctx context.Context) error {
+	select {
+	case <-h.closed:
+		return nil
+	default:
+	}
+	h.startOnce.Do(func() {
+		go h.run()
+	})
+
+	reply := make(chan struct{})
+	select {
+	case h.flushReq <- reply:
+	case <-h.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-reply:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close 停止后台写入并写入队列中剩余的事件
+func (h *DatabaseHandler) Close() error {
+	h.closeOnce.Do(func() {
+		close(h.closed)
+	})
+
+	// 后台协程未启动时不再启动，关闭 done 使重复关闭也无需等待
+	h.startOnce.Do(func() {
+		close(h.done)
+	})
+	<-h.done
+
+	// 关闭期间并发进入队列的事件
+	var rest []EventLogEntry
+	for {
+		select {
+		case entry := <-h.queue:
+			rest = append(rest, entry)
+			continue
+		default:
+		}
+		break
+	}
+	if len(rest) > 0 {
+		defer h.releaseBytes(rest)
+		return h.writeBatch(rest)
+	}
 	return nil
 }
 
+// BufferedBytes 队列中等待写入的事件日志占用的字节数
+func (h *DatabaseHandler) BufferedBytes() int64 {
+	return atomic.LoadInt64(&h.queuedBytes)
+}
+
+// releaseBytes 批次写入后释放记账的字节数
+func (h *DatabaseHandler) releaseBytes(batch []EventLogEntry) {
+	var size int64
+	for _, entry := range batch {
+		size += eventLogEntrySize(entry)
+	}
+	atomic.AddInt64(&h.queuedBytes, -size)
+}
+
+// eventLogEntrySize 估算事件日志占用的字节数
+func eventLogEntrySize(entry EventLogEntry) int64 {
+	return int64(128 + len(entry.EventID) + len(entry.Database) + len(entry.Table) +
+		len(entry.EventType) + len(entry.Data) + len(entry.Status) + len(entry.Error))
+}
+
+// recordError 记录最近一次错误
+func (h *DatabaseHandler) recordError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errorCount++
+	h.lastError, h.lastErrorAt = err.Error(), time.Now()
+}
+
+// DeliveryStatus 写入事件日志的结果，队列中的事件日志计为待写入
+func (h *DatabaseHandler) DeliveryStatus() DeliveryStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return DeliveryStatus{
+		Delivered:     h.writtenCount,
+		Pending:       int64(len(h.queue)),
+		Failed:        h.errorCount,
+		LastError:     h.lastError,
+		LastErrorAt:   h.lastErrorAt,
+		LastSuccessAt: h.lastWriteAt,
+	}
+}
+
 // GetStats 获取处理器统计信息
 func (h *DatabaseHandler) GetStats() map[string]interface{} {
 	h.mu.RLock()
@@ -332,5 +1528,9 @@ func (h *DatabaseHandler) GetStats() map[string]interface{} {
 		"name":          h.name,
 		"task_id":       h.taskID,
 		"process_count": h.processCount,
+		"written_count": h.writtenCount,
+		"batch_count":   h.batchCount,
+		"error_count":   h.errorCount,
+		"queue_length":  len(h.queue),
 	}
 }
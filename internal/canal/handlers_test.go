@@ -0,0 +1,539 @@
+package canal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"pikachun/internal/config"
+)
+
+// fakeDeliveryRecorder 记录投递尝试
+type fakeDeliveryRecorder struct {
+	mu       sync.Mutex
+	attempts []DeliveryAttempt
+}
+
+func (r *fakeDeliveryRecorder) RecordDeliveryAttempt(attempt DeliveryAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts = append(r.attempts, attempt)
+	return nil
+}
+
+// TestWebhookHandlerRecordsAttempts 测试每次投递尝试都会被记录
+func TestWebhookHandlerRecordsAttempts(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(strings.Repeat("x", maxResponseBodySize*2)))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
+	handler := NewWebhookHandler("webhook-1", server.URL, logger)
+	handler.retryInterval = time.Millisecond
+
+	recorder := &fakeDeliveryRecorder{}
+	handler.SetDeliveryRecorder(1, recorder)
+
+	events := []*Event{{ID: "e1"}, {ID: "e2"}}
+	handler.sendEventsWithRetry(context.Background(), deliveryBatch{events: events})
+
+	if len(recorder.attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(recorder.attempts))
+	}
+
+	failed, succeeded := recorder.attempts[0], recorder.attempts[1]
+	if failed.Attempt != 1 || failed.StatusCode != http.StatusServiceUnavailable || failed.Error == "" {
+		t.Errorf("unexpected failed attempt: %+v", failed)
+	}
+	if len(failed.ResponseBody) != maxResponseBodySize {
+		t.Errorf("response body should be truncated to %d bytes, got %d", maxResponseBodySize, len(failed.ResponseBody))
+	}
+	if succeeded.Attempt != 2 || succeeded.StatusCode != http.StatusOK || succeeded.ResponseBody != "ok" || succeeded.Error != "" {
+		t.Errorf("unexpected successful attempt: %+v", succeeded)
+	}
+	if succeeded.TaskID != 1 || len(succeeded.EventIDs) != 2 || succeeded.EventIDs[1] != "e2" {
+		t.Errorf("attempt not linked to events: %+v", succeeded)
+	}
+}
+
+// TestDryRun 测试演练模式不调用 Webhook，只记录将要投递的内容，事件日志状态为 dry_run
+func TestDryRun(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
+	handler := NewWebhookHandler("webhook-1", server.URL, logger)
+	recorder := &fakeDeliveryRecorder{}
+	handler.SetDeliveryRecorder(1, recorder)
+	handler.SetDryRun(true)
+
+	event := &Event{ID: "e1", Schema: "shop", Table: "orders", EventType: EventTypeInsert,
+		AfterData: &RowData{Columns: []Column{{Name: "id", Value: 1}}}}
+	handler.sendEventsWithRetry(context.Background(), deliveryBatch{events: []*Event{event}})
+
+	if calls != 0 {
+		t.Errorf("dry run should not call the webhook, got %d calls", calls)
+	}
+	if len(recorder.attempts) != 1 {
+		t.Fatalf("expected 1 recorded attempt, got %d", len(recorder.attempts))
+	}
+	attempt := recorder.attempts[0]
+	if !attempt.DryRun || attempt.StatusCode != 0 || attempt.Error != "" || attempt.URL != server.URL {
+		t.Errorf("unexpected dry run attempt: %+v", attempt)
+	}
+	if !strings.Contains(attempt.Payload, `"events":[`) || !strings.Contains(attempt.Payload, `"id":"e1"`) {
+		t.Errorf("expected would-be payload to be recorded, got %s", attempt.Payload)
+	}
+
+	// 关闭演练模式后正常投递
+	handler.SetDryRun(false)
+	handler.sendEventsWithRetry(context.Background(), deliveryBatch{events: []*Event{event}})
+	if calls != 1 || recorder.attempts[1].DryRun || recorder.attempts[1].Payload != "" {
+		t.Errorf("expected real delivery after dry run disabled, calls=%d attempt=%+v", calls, recorder.attempts[1])
+	}
+
+	eventLogger := &fakeEventLogger{}
+	dbHandler := NewDatabaseHandler("db-1", 1, logger, eventLogger, config.DatabaseStorageConfig{Enabled: true})
+	dbHandler.SetDryRun(true)
+	if err := dbHandler.Handle(context.Background(), event); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if err := dbHandler.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if len(eventLogger.batches) != 1 || eventLogger.batches[0][0].Status != "dry_run" {
+		t.Errorf("expected event log with dry_run status, got %+v", eventLogger.batches)
+	}
+}
+
+// fakeEventLogger 记录批量写入的事件日志
+type fakeEventLogger struct {
+	mu      sync.Mutex
+	batches [][]EventLogEntry
+}
+
+func (l *fakeEventLogger) CreateEventLog(entry EventLogEntry) error {
+	return l.CreateEventLogs([]EventLogEntry{entry})
+}
+
+func (l *fakeEventLogger) CreateEventLogs(entries []EventLogEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.batches = append(l.batches, append([]EventLogEntry(nil), entries...))
+	return nil
+}
+
+func (l *fakeEventLogger) count() (batches, entries int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, batch := range l.batches {
+		entries += len(batch)
+	}
+	return len(l.batches), entries
+}
+
+// TestDatabaseHandlerBatchWrite 测试事件日志批量写入与关闭时写入剩余事件
+func TestDatabaseHandlerBatchWrite(t *testing.T) {
+	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
+	eventLogger := &fakeEventLogger{}
+	handler := NewDatabaseHandler("db-1", 1, logger, eventLogger, config.DatabaseStorageConfig{
+		Enabled:       true,
+		BatchSize:     10,
+		FlushInterval: "1h",
+		QueueSize:     100,
+	})
+
+	for i := 0; i < 25; i++ {
+		event := &Event{ID: fmt.Sprintf("e%d", i), Schema: "testdb", Table: "users", EventType: EventTypeInsert,
+			AfterData: &RowData{Columns: []Column{{Name: "id", Value: i}}}}
+		if err := handler.Handle(context.Background(), event); err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+	}
+
+	// 两个满批次由后台协程写入，剩余 5 条等待定时刷新
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if batches, _ := eventLogger.count(); batches >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if batches, entries := eventLogger.count(); batches != 2 || entries != 20 {
+		t.Fatalf("expected 2 full batches before close, got %d batches with %d entries", batches, entries)
+	}
+
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if batches, entries := eventLogger.count(); batches != 3 || entries != 25 {
+		t.Fatalf("expected remaining entries flushed on close, got %d batches with %d entries", batches, entries)
+	}
+
+	// 关闭后同步写入
+	if err := handler.Handle(context.Background(), &Event{ID: "late", Schema: "testdb", Table: "users", EventType: EventTypeInsert}); err != nil {
+		t.Fatalf("Handle after close failed: %v", err)
+	}
+	if _, entries := eventLogger.count(); entries != 26 {
+		t.Errorf("expected synchronous write after close, got %d entries", entries)
+	}
+	if stats := handler.GetStats(); stats["written_count"].(int64) != 26 {
+		t.Errorf("unexpected written_count: %v", stats["written_count"])
+	}
+}
+
+// TestDatabaseHandlerCloseTwice 测试未处理过事件的处理器可以重复关闭，事件接收器停止后取消订阅时会再次关闭
+func TestDatabaseHandlerCloseTwice(t *testing.T) {
+	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
+	handler := NewDatabaseHandler("db-1", 1, logger, &fakeEventLogger{}, config.DatabaseStorageConfig{Enabled: true})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2; i++ {
+			if err := handler.Close(); err != nil {
+				t.Errorf("Close failed: %v", err)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the second Close to return")
+	}
+}
+
+// TestDatabaseHandlerUpdateBeforeData 测试 UPDATE 事件的事件日志同时保存修改前后的数据，DELETE 事件只保存删除前的数据
+func TestDatabaseHandlerUpdateBeforeData(t *testing.T) {
+	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
+	eventLogger := &fakeEventLogger{}
+	handler := NewDatabaseHandler("db-1", 1, logger, eventLogger, config.DatabaseStorageConfig{Enabled: true})
+
+	update := &Event{ID: "u1", Schema: "shop", Table: "orders", EventType: EventTypeUpdate,
+		BeforeData: &RowData{Columns: []Column{{Name: "status", Value: "pending"}}},
+		AfterData:  &RowData{Columns: []Column{{Name: "status", Value: "paid"}}}}
+	remove := &Event{ID: "d1", Schema: "shop", Table: "orders", EventType: EventTypeDelete,
+		BeforeData: &RowData{Columns: []Column{{Name: "status", Value: "paid"}}}}
+	for _, event := range []*Event{update, remove} {
+		if err := handler.Handle(context.Background(), event); err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	var entries []EventLogEntry
+	for _, batch := range eventLogger.batches {
+		entries = append(entries, batch...)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if !strings.Contains(entries[0].Data, "paid") || !strings.Contains(entries[0].BeforeData, "pending") {
+		t.Errorf("expected both row images for UPDATE, got %+v", entries[0])
+	}
+	if !strings.Contains(entries[1].Data, "paid") || entries[1].BeforeData != "" {
+		t.Errorf("expected only the deleted row for DELETE, got %+v", entries[1])
+	}
+}
+
+// TestDeliveryTimeoutsValidate 测试投递超时之间的约束
+func TestDeliveryTimeoutsValidate(t *testing.T) {
+	if err := DefaultDeliveryTimeouts().Validate(); err != nil {
+		t.Fatalf("default timeouts should be valid: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		timeouts DeliveryTimeouts
+	}{
+		{"delivery shorter than request", DeliveryTimeouts{Request: 10 * time.Second, Delivery: 5 * time.Second, Shutdown: 10 * time.Second}},
+		{"shutdown shorter than request", DeliveryTimeouts{Request: 10 * time.Second, Delivery: 20 * time.Second, Shutdown: 5 * time.Second}},
+		{"zero", DeliveryTimeouts{Delivery: 20 * time.Second, Shutdown: 5 * time.Second}},
+	}
+	for _, c := range cases {
+		if err := c.timeouts.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", c.name)
+		}
+	}
+}
+
+// TestWebhookHandlerRequestTimeout 测试单次请求超时与停止时的刷新
+func TestWebhookHandlerRequestTimeout(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(500 * time.Millisecond):
+		}
+	}))
+	defer server.Close()
+
+	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
+	handler := NewWebhookHandler("webhook-1", server.URL, logger)
+	handler.retryInterval = 10 * time.Millisecond
+	if err := handler.SetTimeouts(DeliveryTimeouts{Request: 50 * time.Millisecond, Delivery: time.Second, Shutdown: time.Second}); err != nil {
+		t.Fatalf("SetTimeouts failed: %v", err)
+	}
+
+	attempt, err := handler.Deliver(context.Background(), []*Event{{ID: "e1"}}, 1)
+	if err == nil {
+		t.Fatalf("expected request timeout error")
+	}
+	if attempt.Latency >= 500*time.Millisecond {
+		t.Errorf("request was not cut off by request timeout, latency %v", attempt.Latency)
+	}
+
+	// 停止时刷新缓冲区并等待重试结束
+	if err := handler.Handle(context.Background(), &Event{ID: "e2"}); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1+handler.maxRetries+1 {
+		t.Errorf("expected buffered event to be retried before close returned, got %d calls", calls)
+	}
+}
+
+// fakeCursorRecorder 记录请求序号
+type fakeCursorRecorder struct {
+	mu       sync.Mutex
+	cursors  []DeliveryCursor
+	reserved []uint64
+}
+
+func (r *fakeCursorRecorder) ReserveDeliverySequence(taskID uint, sequence uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reserved = append(r.reserved, sequence)
+	return nil
+}
+
+func (r *fakeCursorRecorder) RecordDeliveryCursor(cursor DeliveryCursor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cursors = append(r.cursors, cursor)
+	return nil
+}
+
+// TestWebhookHandlerDeliverySequence 测试请求序号接着已有序号递增，重试沿用同一序号，成功后记录位置范围
+func TestWebhookHandlerDeliverySequence(t *testing.T) {
+	var mu sync.Mutex
+	var sequences []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		sequences = append(sequences, r.Header.Get("X-Delivery-Sequence"))
+		if len(sequences) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler("webhook-1", server.URL, log.New(os.Stdout, "[Test] ", log.LstdFlags))
+	handler.retryInterval = time.Millisecond
+	cursors := &fakeCursorRecorder{}
+	handler.SetDeliveryRecorder(1, &fakeDeliveryRecorder{})
+	handler.SetCursorRecorder(cursors, 41)
+
+	for _, pos := range []uint32{100, 200} {
+		event := &Event{ID: fmt.Sprintf("e%d", pos), Position: Position{Name: "mysql-bin.000001", Pos: pos}}
+		if err := handler.Handle(context.Background(), event); err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sequences) != 2 || sequences[0] != "42" || sequences[1] != "42" {
+		t.Fatalf("expected sequence 42 on both attempts, got %v", sequences)
+	}
+	if len(cursors.cursors) != 1 {
+		t.Fatalf("expected one cursor, got %+v", cursors.cursors)
+	}
+	cursor := cursors.cursors[0]
+	if cursor.TaskID != 1 || cursor.Sequence != 42 || cursor.First.Pos != 100 || cursor.Last.Pos != 200 || cursor.EventCount != 2 {
+		t.Errorf("unexpected cursor %+v", cursor)
+	}
+	// 分配前预留一块序号，块内的序号不再持久化
+	if len(cursors.reserved) != 1 || cursors.reserved[0] != 41+sequenceReserveBlock {
+		t.Errorf("expected sequences reserved up to %d, got %v", 41+sequenceReserveBlock, cursors.reserved)
+	}
+}
+
+// TestWebhookHandlerNumericStrings 测试数值安全编码把 64 位整数和定点小数编码为字符串
+func TestWebhookHandlerNumericStrings(t *testing.T) {
+	handler := NewWebhookHandler("webhook-1", "http://localhost", log.New(os.Stdout, "[Test] ", log.LstdFlags))
+	event := &Event{ID: "e1", AfterData: &RowData{Columns: []Column{
+		{Name: "id", Value: int64(9007199254740993)},
+		{Name: "amount", Value: decimal.RequireFromString("12345678901234567.89")},
+		{Name: "qty", Value: int32(3)},
+		{Name: "name", Value: "widget"},
+	}}}
+	batch := deliveryBatch{sequence: 7, events: []*Event{event}}
+
+	decode := func() map[string]interface{} {
+		data, err := handler.buildPayload(batch)
+		if err != nil {
+			t.Fatalf("buildPayload failed: %v", err)
+		}
+		var payload map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&payload); err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		return payload
+	}
+	values := func(payload map[string]interface{}) []interface{} {
+		columns := payload["events"].([]interface{})[0].(map[string]interface{})["after_data"].(map[string]interface{})["columns"].([]interface{})
+		result := make([]interface{}, len(columns))
+		for i, col := range columns {
+			result[i] = col.(map[string]interface{})["value"]
+		}
+		return result
+	}
+
+	// 默认 BIGINT 编码为数字
+	if got := values(decode()); got[0] != json.Number("9007199254740993") {
+		t.Fatalf("expected numeric id, got %#v", got[0])
+	}
+
+	handler.SetNumericStrings(true)
+	payload := decode()
+	if payload["sequence"] != "7" {
+		t.Errorf("expected string sequence, got %#v", payload["sequence"])
+	}
+	want := []interface{}{"9007199254740993", "12345678901234567.89", json.Number("3"), "widget"}
+	if got := values(payload); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %#v, got %#v", want, got)
+	}
+	// 原事件不受影响
+	if _, ok := event.AfterData.Columns[0].Value.(int64); !ok {
+		t.Errorf("original event modified: %#v", event.AfterData.Columns[0].Value)
+	}
+}
+
+// TestWebhookHandlerIncludeSchema 测试表结构只在首次投递成功前和结构变化后附带
+func TestWebhookHandlerIncludeSchema(t *testing.T) {
+	var mu sync.Mutex
+	var schemas []map[string]EventSchema
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Schema map[string]EventSchema `json:"schema"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode failed: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		schemas = append(schemas, payload.Schema)
+		w.WriteHeader(status)
+		status = http.StatusOK
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler("webhook-1", server.URL, log.New(os.Stdout, "[Test] ", log.LstdFlags))
+	handler.SetIncludeSchema(true)
+
+	newEvent := func(columns ...Column) *Event {
+		return &Event{ID: "e1", Schema: "shop", Table: "orders", PrimaryKey: []string{"id"}, AfterData: &RowData{Columns: columns}}
+	}
+	v1 := newEvent(Column{Name: "id", Type: "bigint", Value: int64(1)})
+	v2 := newEvent(Column{Name: "id", Type: "bigint", Value: int64(1)}, Column{Name: "note", Type: "varchar", Value: "x"})
+
+	// 第一次失败，重试时仍附带；成功后不再附带，结构变化后重新附带
+	for i, event := range []*Event{v1, v1, v1, v2} {
+		_, err := handler.Deliver(context.Background(), []*Event{event}, 1)
+		if (err != nil) != (i == 0) {
+			t.Fatalf("delivery %d: unexpected error %v", i, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(schemas) != 4 {
+		t.Fatalf("expected 4 requests, got %d", len(schemas))
+	}
+	for i, want := range []bool{true, true, false, true} {
+		if _, ok := schemas[i]["shop.orders"]; ok != want {
+			t.Errorf("request %d: expected schema included %v, got %+v", i, want, schemas[i])
+		}
+	}
+	first, changed := schemas[0]["shop.orders"], schemas[3]["shop.orders"]
+	if len(first.Columns) != 1 || !first.Columns[0].PrimaryKey || first.Columns[0].Type != "bigint" {
+		t.Errorf("unexpected schema %+v", first)
+	}
+	if first.Hash == "" || first.Hash == changed.Hash || len(changed.Columns) != 2 {
+		t.Errorf("expected new hash after schema change, got %+v and %+v", first, changed)
+	}
+}
+
+// TestWebhookHandlerClose 测试关闭时投递缓冲区中的事件并停止定时器
+func TestWebhookHandlerClose(t *testing.T) {
+	var received int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&received, 1)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler("webhook-1", server.URL, log.New(os.Stdout, "[Test] ", log.LstdFlags))
+	handler.batchTimeout = time.Hour
+	if err := handler.Handle(context.Background(), &Event{ID: "e1"}); err != nil {
+		t.Fatal(err)
+	}
+	if handler.flushTimer == nil {
+		t.Fatal("expected a pending flush timer")
+	}
+
+	if err := handler.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt64(&received) != 1 {
+		t.Errorf("expected buffered events to be delivered on close, got %d requests", received)
+	}
+	if handler.flushTimer != nil || handler.PendingEvents() != 0 {
+		t.Errorf("expected timer stopped and no pending events, got %v, %d", handler.flushTimer, handler.PendingEvents())
+	}
+	// 重复关闭没有副作用
+	if err := handler.Close(); err != nil {
+		t.Errorf("expected second close to succeed, got %v", err)
+	}
+}
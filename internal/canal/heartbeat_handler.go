@@ -0,0 +1,108 @@
+package canal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// HeartbeatHandler 心跳事件处理器
+// 接收心跳表的变更事件，计算从写入到流经管道的端到端延迟
+type HeartbeatHandler struct {
+	name   string
+	logger *log.Logger
+
+	mu            sync.RWMutex
+	lastWritten   time.Time // 最近一次收到的心跳写入时间
+	lastReceived  time.Time // 最近一次收到心跳事件的时间
+	lastLatency   time.Duration
+	receivedCount int64
+}
+
+// NewHeartbeatHandler 创建心跳事件处理器
+func NewHeartbeatHandler(name string, logger *log.Logger) *HeartbeatHandler {
+	return &HeartbeatHandler{
+		name:   name,
+		logger: logger,
+	}
+}
+
+// GetName 获取处理器名称
+func (h *HeartbeatHandler) GetName() string {
+	return h.name
+}
+
+// Handle 处理心跳事件
+func (h *HeartbeatHandler) Handle(ctx context.Context, event *Event) error {
+	if event.AfterData == nil {
+		return nil
+	}
+
+	// 心跳表结构为 (id, ts)，ts 为写入时的纳秒时间戳
+	writtenAt, err := heartbeatTimestamp(event.AfterData)
+	if err != nil {
+		h.logger.Printf("⚠️ Invalid heartbeat event %s: %v", event.ID, err)
+		return nil
+	}
+
+	now := time.Now()
+	h.mu.Lock()
+	h.lastWritten = writtenAt
+	h.lastReceived = now
+	h.lastLatency = now.Sub(writtenAt)
+	h.receivedCount++
+	h.mu.Unlock()
+
+	return nil
+}
+
+// Flush 心跳处理器没有缓冲
+func (h *HeartbeatHandler) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close 心跳处理器没有需要释放的资源
+func (h *HeartbeatHandler) Close() error {
+	return nil
+}
+
+// heartbeatTimestamp 从心跳行数据中解析写入时间
+func heartbeatTimestamp(row *RowData) (time.Time, error) {
+	if len(row.Columns) < 2 {
+		return time.Time{}, fmt.Errorf("expected 2 columns, got %d", len(row.Columns))
+	}
+
+	switch v := row.Columns[1].Value.(type) {
+	case int64:
+		return time.Unix(0, v), nil
+	case uint64:
+		return time.Unix(0, int64(v)), nil
+	case int32:
+		return time.Unix(0, int64(v)), nil
+	default:
+		return time.Time{}, fmt.Errorf("unexpected heartbeat value type %T", v)
+	}
+}
+
+// GetStats 获取心跳统计信息
+func (h *HeartbeatHandler) GetStats() map[string]interface{} {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stats := map[string]interface{}{
+		"name":           h.name,
+		"received_count": h.receivedCount,
+		"last_written":   h.lastWritten,
+		"last_received":  h.lastReceived,
+		"latency_ms":     h.lastLatency.Milliseconds(),
+	}
+
+	// 端到端新鲜度：距离最近一次成功流经管道的心跳写入时间
+	if !h.lastWritten.IsZero() {
+		stats["freshness_seconds"] = time.Since(h.lastWritten).Seconds()
+	}
+
+	return stats
+}
@@ -0,0 +1,77 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// heartbeatEvent 心跳表 (id, ts) 的写入事件
+func heartbeatEvent(ts interface{}) *Event {
+	return &Event{
+		ID:        "hb",
+		EventType: EventTypeUpdate,
+		AfterData: &RowData{Columns: []Column{{Name: "id", Value: int32(1001)}, {Name: "ts", Value: ts}}},
+	}
+}
+
+// TestHeartbeatHandlerTimestampTypes 测试 ts 列按驱动返回的各种整数类型解析
+func TestHeartbeatHandlerTimestampTypes(t *testing.T) {
+	writtenAt := time.Now().Add(-2 * time.Second)
+	for _, ts := range []interface{}{writtenAt.UnixNano(), uint64(writtenAt.UnixNano()), int32(1000)} {
+		h := NewHeartbeatHandler("heartbeat-1", log.New(io.Discard, "", 0))
+		if err := h.Handle(context.Background(), heartbeatEvent(ts)); err != nil {
+			t.Fatalf("%T: Handle failed: %v", ts, err)
+		}
+		stats := h.GetStats()
+		if stats["received_count"].(int64) != 1 {
+			t.Errorf("%T: expected the heartbeat received, got %v", ts, stats)
+		}
+		if _, ok := stats["freshness_seconds"]; !ok {
+			t.Errorf("%T: expected freshness reported after a heartbeat", ts)
+		}
+	}
+}
+
+// TestHeartbeatHandlerLatency 测试延迟和新鲜度按心跳写入时间计算
+func TestHeartbeatHandlerLatency(t *testing.T) {
+	h := NewHeartbeatHandler("heartbeat-1", log.New(io.Discard, "", 0))
+	if _, ok := h.GetStats()["freshness_seconds"]; ok {
+		t.Error("expected no freshness before the first heartbeat")
+	}
+
+	writtenAt := time.Now().Add(-1500 * time.Millisecond)
+	if err := h.Handle(context.Background(), heartbeatEvent(writtenAt.UnixNano())); err != nil {
+		t.Fatal(err)
+	}
+	stats := h.GetStats()
+	if !stats["last_written"].(time.Time).Equal(time.Unix(0, writtenAt.UnixNano())) {
+		t.Errorf("expected last_written %v, got %v", writtenAt, stats["last_written"])
+	}
+	if latency := stats["latency_ms"].(int64); latency < 1500 || latency > 5000 {
+		t.Errorf("expected a latency of about 1500ms, got %dms", latency)
+	}
+	if freshness := stats["freshness_seconds"].(float64); freshness < 1.5 {
+		t.Errorf("expected freshness of at least 1.5s, got %v", freshness)
+	}
+}
+
+// TestHeartbeatHandlerIgnoresInvalidRows 测试删除事件和格式不对的行不更新统计，也不返回错误阻塞管道
+func TestHeartbeatHandlerIgnoresInvalidRows(t *testing.T) {
+	h := NewHeartbeatHandler("heartbeat-1", log.New(io.Discard, "", 0))
+	events := []*Event{
+		{ID: "delete", EventType: EventTypeDelete, BeforeData: heartbeatEvent(time.Now().UnixNano()).AfterData},
+		{ID: "short", EventType: EventTypeInsert, AfterData: &RowData{Columns: []Column{{Name: "id", Value: int32(1)}}}},
+		heartbeatEvent("2024-01-01 00:00:00"),
+	}
+	for _, event := range events {
+		if err := h.Handle(context.Background(), event); err != nil {
+			t.Errorf("%s: expected invalid heartbeats ignored, got %v", event.ID, err)
+		}
+	}
+	if stats := h.GetStats(); stats["received_count"].(int64) != 0 || !stats["last_written"].(time.Time).IsZero() {
+		t.Errorf("expected no heartbeat recorded, got %v", stats)
+	}
+}
@@ -21,6 +21,7 @@ type Position struct {
 	Name    string `json:"name"`
 	Pos     uint32 `json:"pos"`
 	GTIDSet string `json:"gtid_set,omitempty"`
+	GTID    string `json:"gtid,omitempty"` // 事件所属事务的 GTID，只在事件中设置
 }
 
 // RowData 行数据
@@ -35,25 +36,46 @@ type Column struct {
 	Value   interface{} `json:"value"`
 	IsNull  bool        `json:"is_null"`
 	Updated bool        `json:"updated,omitempty"`
+
+	// 大字段处理结果，见 LargeValuePolicy
+	Truncated    bool   `json:"truncated,omitempty"`     // 值已被截断
+	Dropped      bool   `json:"dropped,omitempty"`       // 值已被丢弃
+	OriginalSize int    `json:"original_size,omitempty"` // 原始值字节数
+	Ref          string `json:"ref,omitempty"`           // 外置到对象存储后的引用地址
 }
 
 // Event 数据变更事件
 type Event struct {
-	ID         string    `json:"id"`
-	Schema     string    `json:"schema"`
-	Table      string    `json:"table"`
-	EventType  EventType `json:"event_type"`
-	Timestamp  time.Time `json:"timestamp"`
-	Position   Position  `json:"position"`
-	BeforeData *RowData  `json:"before_data,omitempty"`
-	AfterData  *RowData  `json:"after_data,omitempty"`
-	SQL        string    `json:"sql,omitempty"`
+	ID          string    `json:"id"`
+	Schema      string    `json:"schema"`
+	Table       string    `json:"table"`
+	EventType   EventType `json:"event_type"`
+	Timestamp   time.Time `json:"timestamp" format:"date-time"`
+	Position    Position  `json:"position"`
+	BeforeData  *RowData  `json:"before_data,omitempty"`
+	AfterData   *RowData  `json:"after_data,omitempty"`
+	SQL         string    `json:"sql,omitempty"`
+	PrimaryKey  []string  `json:"primary_key,omitempty"`  // 主键列名，表没有主键或结构未知时为空
+	SchemaHash  string    `json:"schema_hash,omitempty"`  // 表结构哈希，与载荷 schema 部分的 hash 对应
+	ContentHash string    `json:"content_hash,omitempty"` // 内容哈希（库、表、主键和变更后的数据），内容相同的重复投递哈希相同
+	Compacted   int       `json:"compacted,omitempty"`    // 窗口合并时合并的事件数，见 EventCompactor
+	Key         *RowData  `json:"key,omitempty"`          // 墓碑事件的主键列，见 applyDeleteMode
+	Tombstone   bool      `json:"tombstone,omitempty"`    // 墓碑事件：只有主键没有行数据，下游按键删除
+
+	// 排序和端到端延迟：timestamp 为 binlog 事件时间（秒级），以下时间为 Unix 微秒
+	CommitMicros    int64  `json:"commit_time_us,omitempty"`  // 事务在原始源库的提交时间，来自 GTID 事件，MySQL 8.0.1 以下没有
+	ProcessedMicros int64  `json:"processed_at_us,omitempty"` // 事件进入处理队列的时间
+	CommitIndex     uint64 `json:"commit_index,omitempty"`    // 事务的提交顺序号，同一事务的事件相同，进程内单调递增，重启后从 1 开始
 }
 
 // EventHandler 事件处理器接口
+// Flush 立即写出缓冲的事件；Close 在取消订阅或实例停止时调用，写出剩余事件并释放定时器等资源，
+// 可能被调用多次，之后仍可能收到事件
 type EventHandler interface {
 	Handle(ctx context.Context, event *Event) error
 	GetName() string
+	Flush(ctx context.Context) error
+	Close() error
 }
 
 // EventSink 事件接收器接口
@@ -83,10 +105,11 @@ type MetaManager interface {
 
 // TableMeta 表元数据
 type TableMeta struct {
-	Schema  string   `json:"schema"`
-	Table   string   `json:"table"`
-	Columns []string `json:"columns"`
-	Types   []string `json:"types"`
+	Schema     string   `json:"schema"`
+	Table      string   `json:"table"`
+	Columns    []string `json:"columns"`
+	Types      []string `json:"types"`
+	PrimaryKey []string `json:"primary_key,omitempty"` // 主键列名，表没有主键或结构未知时为空
 }
 
 // CanalInstance Canal实例接口
@@ -109,6 +132,23 @@ type InstanceStatus struct {
 	Position  Position  `json:"position"`
 	LastEvent time.Time `json:"last_event"`
 	ErrorMsg  string    `json:"error_msg,omitempty"`
+	ErrorAt   time.Time `json:"error_at,omitempty"`
+	Alert     string    `json:"alert,omitempty"` // 需要人工处理的告警，如 binlog_purged
+	Lag       float64   `json:"lag_seconds"`     // 复制延迟（秒），按最近事件的 binlog 时间戳估算
+	Paused    bool      `json:"paused"`          // 处于维护窗口，暂停读取 binlog
+
+	BufferedBytes int64 `json:"buffered_bytes"` // 缓冲中的事件估算占用的字节数
+	MemoryPaused  bool  `json:"memory_paused"`  // 缓冲区达到硬限制，暂停读取 binlog
+
+	MaintenancePaused bool `json:"maintenance_paused"` // 人工维护暂停，停止读取 binlog
+
+	PositionDrift *PositionDrift `json:"position_drift,omitempty"` // 最近一次位置检查发现的漂移
+
+	Recovery *RecoveryProgress `json:"recovery,omitempty"` // 最近一次 binlog 被清除后的恢复进度
+
+	Backfill *BackfillProgress `json:"backfill,omitempty"` // 最近一次从本地 binlog 文件回填的进度
+
+	Sinks []SinkStatus `json:"sinks,omitempty"` // 各 sink 的投递状态
 }
 
 // BinlogSlave binlog 从库接口
@@ -117,7 +157,9 @@ type BinlogSlave interface {
 	Stop() error
 	AddWatchTable(schema, table string)
 	RemoveWatchTable(schema, table string)
+	SetExcludeTables(patterns []string)
 	SetEventTypes(eventTypes []EventType)
+	Recover(action RecoveryAction) error
 	GetBinlogPosition() Position
 	IsRunning() bool
 	GetStats() map[string]interface{}
@@ -126,5 +168,59 @@ type BinlogSlave interface {
 
 // EventLogger 事件日志接口
 type EventLogger interface {
-	CreateEventLog(taskID uint, database, table, eventType, data, status, errorMsg string) error
+	CreateEventLog(entry EventLogEntry) error
+	CreateEventLogs(entries []EventLogEntry) error
+}
+
+// EventLogEntry 待写入的事件日志
+type EventLogEntry struct {
+	TaskID      uint
+	EventID     string
+	Database    string
+	Table       string
+	EventType   string
+	ContentHash string
+	Data        string
+	BeforeData  string // UPDATE 事件修改前的数据
+	Status      string
+	Error       string
+}
+
+// DeliveryAttempt 一次 Webhook 投递尝试
+type DeliveryAttempt struct {
+	TaskID       uint          `json:"task_id"`
+	EventIDs     []string      `json:"event_ids"` // 同一批次投递的事件
+	Attempt      int           `json:"attempt"`
+	URL          string        `json:"url"`
+	StatusCode   int           `json:"status_code"`
+	Latency      time.Duration `json:"latency" swaggertype:"integer"` // 耗时（纳秒）
+	ResponseBody string        `json:"response_body"`
+	Error        string        `json:"error,omitempty"`
+	DryRun       bool          `json:"dry_run,omitempty"` // 演练模式，未实际发送请求
+	Payload      string        `json:"payload,omitempty"` // 演练模式下将要发送的请求体（截断）
+	PartitionKey string        `json:"partition_key,omitempty"`
+	Sequence     uint64        `json:"sequence,omitempty"` // 请求序号，重新投递时为 0
+	Nacked       []EventNack   `json:"nacked,omitempty"`   // 接收方在逐事件确认中拒绝的事件
+}
+
+// DeliveryCursor 投递成功的请求序号与其中事件 binlog 位置范围的对应关系
+type DeliveryCursor struct {
+	TaskID       uint     `json:"task_id"`
+	Sequence     uint64   `json:"sequence"`
+	PartitionKey string   `json:"partition_key,omitempty"`
+	First        Position `json:"first"` // 请求中第一个事件的位置
+	Last         Position `json:"last"`  // 请求中最后一个事件的位置
+	EventCount   int      `json:"event_count"`
+}
+
+// CursorRecorder 请求序号记录接口
+type CursorRecorder interface {
+	RecordDeliveryCursor(cursor DeliveryCursor) error
+	// ReserveDeliverySequence 持久化已分配序号的上限，重启后从上限之后分配
+	ReserveDeliverySequence(taskID uint, sequence uint64) error
+}
+
+// DeliveryRecorder 投递尝试记录接口
+type DeliveryRecorder interface {
+	RecordDeliveryAttempt(attempt DeliveryAttempt) error
 }
@@ -0,0 +1,198 @@
+package canal
+
+import (
+	"fmt"
+	"time"
+
+	"pikachun/internal/database"
+)
+
+// 超过延迟限制时的处理方式
+const (
+	LagActionAlert  = "alert"  // 只告警（默认）
+	LagActionPause  = "pause"  // 告警并暂停任务，需要人工恢复
+	LagActionSample = "sample" // 告警并切换为采样投递，回落到限制的一半以下后恢复
+)
+
+// defaultLagSamplePercent sample 方式未设置比例时保留的百分比
+const defaultLagSamplePercent = 10
+
+// PendingHandler 报告已收到但尚未投递完成的事件数的处理器
+type PendingHandler interface {
+	PendingEvents() int64
+}
+
+// LagGuard 任务的延迟保护：复制延迟或未投递事件数超过限制时按配置告警、暂停任务或切换为采样，
+// 避免停机后的追赶流量冲垮下游
+type LagGuard struct {
+	MaxSeconds    int     // 复制延迟上限（秒），0 表示不限制
+	MaxEvents     int64   // 未投递事件数上限（处理队列加 Webhook 缓冲和投递中的事件），0 表示不限制
+	Action        string  // alert、pause、sample
+	SamplePercent float64 // sample 方式保留的百分比
+}
+
+// LagBreach 超过延迟限制的情况，延迟和未投递事件数都回落到限制的一半以下后解除
+type LagBreach struct {
+	Action        string    `json:"action"`
+	Reason        string    `json:"reason"`
+	LagSeconds    float64   `json:"lag_seconds"`
+	PendingEvents int64     `json:"pending_events"`
+	Since         time.Time `json:"since"`
+}
+
+// NewLagGuard 根据任务配置创建延迟保护，都未配置限制时返回 nil，表示不检查
+func NewLagGuard(task *database.Task) (*LagGuard, error) {
+	if task.MaxLagSeconds < 0 || task.MaxLagEvents < 0 {
+		return nil, fmt.Errorf("lag limits must not be negative")
+	}
+	action := task.LagAction
+	switch action {
+	case "":
+		action = LagActionAlert
+	case LagActionAlert, LagActionPause, LagActionSample:
+	default:
+		return nil, fmt.Errorf("unsupported lag action %q, supported: alert, pause, sample", action)
+	}
+	percent := task.LagSample
+	if percent < 0 || percent >= 100 {
+		return nil, fmt.Errorf("lag sample percent must be between 0 and 100, got %v", percent)
+	}
+	if percent == 0 {
+		percent = defaultLagSamplePercent
+	}
+	if task.MaxLagSeconds == 0 && task.MaxLagEvents == 0 {
+		return nil, nil
+	}
+	return &LagGuard{
+		MaxSeconds:    task.MaxLagSeconds,
+		MaxEvents:     int64(task.MaxLagEvents),
+		Action:        action,
+		SamplePercent: percent,
+	}, nil
+}
+
+// exceeded 返回超过的限制，未超过时返回空字符串
+func (g *LagGuard) exceeded(lag float64, pending int64) string {
+	if g.MaxSeconds > 0 && lag > float64(g.MaxSeconds) {
+		return fmt.Sprintf("replication lag %.0fs exceeds %ds", lag, g.MaxSeconds)
+	}
+	if g.MaxEvents > 0 && pending > g.MaxEvents {
+		return fmt.Sprintf("%d pending events exceed %d", pending, g.MaxEvents)
+	}
+	return ""
+}
+
+// recovered 延迟和未投递事件数都回落到限制的一半以下，避免在限制附近反复切换
+func (g *LagGuard) recovered(lag float64, pending int64) bool {
+	if g.MaxSeconds > 0 && lag > float64(g.MaxSeconds)/2 {
+		return false
+	}
+	if g.MaxEvents > 0 && pending > g.MaxEvents/2 {
+		return false
+	}
+	return true
+}
+
+// SetLagGuard 根据任务配置设置延迟保护
+func (c *MySQLCanalInstance) SetLagGuard(task *database.Task) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setLagGuardLocked(task)
+}
+
+// setLagGuardLocked 设置延迟保护，调用方需持有锁。正在按延迟采样时恢复任务自身的采样配置
+func (c *MySQLCanalInstance) setLagGuardLocked(task *database.Task) error {
+	guard, err := NewLagGuard(task)
+	if err != nil {
+		return fmt.Errorf("invalid lag guard for task %d: %v", task.ID, err)
+	}
+	c.lagGuard = guard
+	c.lagBreach = nil
+	if c.lagSampling {
+		c.lagSampling = false
+		return c.setSamplingLocked(task)
+	}
+	return nil
+}
+
+// CheckLag 检查延迟保护，超过限制或尚未恢复时返回当前情况，否则返回 nil。
+// sample 方式在超过限制时切换为采样，恢复后还原任务自身的采样配置；告警和暂停任务由调用方处理
+func (c *MySQLCanalInstance) CheckLag() *LagBreach {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lagGuard == nil || !c.running || c.binlogSlave == nil {
+		return nil
+	}
+
+	stats := c.binlogSlave.GetStats()
+	// 维护窗口或人工暂停期间延迟增长是预期行为，保持当前状态
+	if paused, _ := stats["paused"].(bool); paused {
+		return c.lagBreachCopy()
+	}
+	if paused, _ := stats["maintenance_paused"].(bool); paused {
+		return c.lagBreachCopy()
+	}
+
+	lag, _ := stats["lag_seconds"].(float64)
+	pending := c.eventSink.PendingEvents()
+	guard := c.lagGuard
+
+	if reason := guard.exceeded(lag, pending); reason != "" {
+		if c.lagBreach == nil {
+			c.lagBreach = &LagBreach{Action: guard.Action, Since: time.Now()}
+			c.logger.Printf("🐢 Instance %s exceeded lag limits (%s), action: %s", c.id, reason, guard.Action)
+		}
+		c.lagBreach.Reason = reason
+		c.lagBreach.LagSeconds = lag
+		c.lagBreach.PendingEvents = pending
+
+		if guard.Action == LagActionSample && !c.lagSampling {
+			// 任务自身的采样比例更低时沿用自身的比例
+			percent := guard.SamplePercent
+			if c.samplePercent > 0 && c.samplePercent < percent {
+				percent = c.samplePercent
+			}
+			sampler, err := NewEventSampler(percent, c.sampleInterval)
+			if err != nil {
+				c.logger.Printf("⚠️ Failed to switch instance %s to sampling: %v", c.id, err)
+			} else {
+				c.eventSink.SetSampler(sampler)
+				c.lagSampling = true
+				c.logger.Printf("🎯 Instance %s switched to %.2f%% sampling until lag recovers", c.id, percent)
+			}
+		}
+		return c.lagBreachCopy()
+	}
+
+	if c.lagBreach == nil {
+		return nil
+	}
+	c.lagBreach.LagSeconds = lag
+	c.lagBreach.PendingEvents = pending
+	if !guard.recovered(lag, pending) {
+		return c.lagBreachCopy()
+	}
+
+	c.logger.Printf("✅ Instance %s recovered from lag (lag %.0fs, %d pending events)", c.id, lag, pending)
+	c.lagBreach = nil
+	if c.lagSampling {
+		c.lagSampling = false
+		sampler, err := NewEventSampler(c.samplePercent, c.sampleInterval)
+		if err != nil {
+			c.logger.Printf("⚠️ Failed to restore sampling for instance %s: %v", c.id, err)
+			return nil
+		}
+		c.eventSink.SetSampler(sampler)
+	}
+	return nil
+}
+
+// lagBreachCopy 返回当前超限情况的副本，调用方需持有锁
+func (c *MySQLCanalInstance) lagBreachCopy() *LagBreach {
+	if c.lagBreach == nil {
+		return nil
+	}
+	breach := *c.lagBreach
+	return &breach
+}
@@ -0,0 +1,118 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+
+	"pikachun/internal/database"
+)
+
+// lagTestSlave 只报告延迟的 binlog 读取器
+type lagTestSlave struct {
+	BinlogSlave
+	lag    float64
+	paused bool
+}
+
+func (s *lagTestSlave) GetStats() map[string]interface{} {
+	return map[string]interface{}{"lag_seconds": s.lag, "paused": s.paused}
+}
+
+// TestNewLagGuard 测试延迟保护配置校验
+func TestNewLagGuard(t *testing.T) {
+	if guard, err := NewLagGuard(&database.Task{LagAction: LagActionPause}); err != nil || guard != nil {
+		t.Errorf("expected nil guard without limits, got %v, %v", guard, err)
+	}
+	guard, err := NewLagGuard(&database.Task{MaxLagSeconds: 60})
+	if err != nil || guard.Action != LagActionAlert || guard.SamplePercent != defaultLagSamplePercent {
+		t.Errorf("unexpected default guard %+v, %v", guard, err)
+	}
+
+	invalid := []*database.Task{
+		{MaxLagSeconds: -1},
+		{MaxLagEvents: 10, LagAction: "drop"},
+		{MaxLagEvents: 10, LagSample: 100},
+	}
+	for _, task := range invalid {
+		if _, err := NewLagGuard(task); err == nil {
+			t.Errorf("expected error for %+v", task)
+		}
+	}
+}
+
+// TestCheckLagSampling 测试超过限制后切换为采样，回落到限制的一半以下后恢复任务自身的采样配置
+func TestCheckLagSampling(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	slave := &lagTestSlave{}
+	c := &MySQLCanalInstance{id: "task-1", eventSink: NewDefaultEventSink(logger), binlogSlave: slave, logger: logger, running: true}
+	task := &database.Task{ID: 1, SampleInterval: 5, MaxLagSeconds: 100, LagAction: LagActionSample, LagSample: 20}
+	if err := c.SetSampling(task); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetLagGuard(task); err != nil {
+		t.Fatal(err)
+	}
+
+	slave.lag = 10
+	if breach := c.CheckLag(); breach != nil {
+		t.Fatalf("unexpected breach %+v", breach)
+	}
+
+	slave.lag = 150
+	breach := c.CheckLag()
+	if breach == nil || breach.Action != LagActionSample || breach.LagSeconds != 150 {
+		t.Fatalf("expected sample breach, got %+v", breach)
+	}
+	if stats := c.eventSink.SamplingStats(); stats["percent"] != float64(20) || stats["interval_seconds"] != 5 {
+		t.Errorf("expected lag sampling, got %v", stats)
+	}
+
+	// 维护窗口内不判断
+	slave.lag, slave.paused = 0, true
+	if c.CheckLag() == nil {
+		t.Error("breach should be kept while paused")
+	}
+	slave.paused = false
+
+	// 回落到限制以下但未到一半，仍在采样
+	slave.lag = 80
+	if c.CheckLag() == nil {
+		t.Error("breach should last until lag drops below half the limit")
+	}
+	slave.lag = 40
+	if breach := c.CheckLag(); breach != nil {
+		t.Errorf("expected recovery, got %+v", breach)
+	}
+	if stats := c.eventSink.SamplingStats(); stats["percent"] != float64(0) || stats["interval_seconds"] != 5 {
+		t.Errorf("expected task sampling to be restored, got %v", stats)
+	}
+}
+
+// TestCheckLagPendingEvents 测试按未投递事件数判断
+func TestCheckLagPendingEvents(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	sink := NewDefaultEventSink(logger)
+	c := &MySQLCanalInstance{id: "task-1", eventSink: sink, binlogSlave: &lagTestSlave{}, logger: logger, running: true}
+	if err := c.SetLagGuard(&database.Task{ID: 1, MaxLagEvents: 2, LagAction: LagActionPause}); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewWebhookHandler("webhook-1", "http://localhost", logger)
+	sink.Subscribe("shop", "orders", handler)
+	for i := 0; i < 3; i++ {
+		handler.Handle(context.Background(), partitionTestEvent("orders", i, 7))
+	}
+	if handler.PendingEvents() != 3 {
+		t.Fatalf("expected 3 pending events, got %d", handler.PendingEvents())
+	}
+
+	breach := c.CheckLag()
+	if breach == nil || breach.Action != LagActionPause || breach.PendingEvents != 3 {
+		t.Errorf("expected pause breach, got %+v", breach)
+	}
+	if c.GetStats()["lag_breach"] == nil {
+		t.Error("expected lag_breach in stats")
+	}
+}
@@ -0,0 +1,234 @@
+package canal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"pikachun/internal/config"
+	"pikachun/internal/objectstore"
+)
+
+// 大字段处理策略
+const (
+	LargeValueNone        = "none"
+	LargeValueTruncate    = "truncate"
+	LargeValueDrop        = "drop"
+	LargeValueExternalize = "externalize"
+)
+
+// truncatedMarker 截断后追加在字符串值末尾的标记
+const truncatedMarker = "...[truncated]"
+
+// externalizeTimeout 一个事件的所有值写入对象存储的总时间，超时未写入的值退化为截断
+const externalizeTimeout = 30 * time.Second
+
+// externalizeConcurrency 一个事件同时写入对象存储的值数
+const externalizeConcurrency = 4
+
+// LargeValuePolicy 处理超过大小限制的 BLOB/TEXT 列值，避免单个事件撑爆下游的请求体或存储。
+// 作为出队转换阶段在事件处理协程中执行，写对象存储不阻塞 binlog 读取
+type LargeValuePolicy struct {
+	mode    string
+	maxSize int
+	store   objectstore.Store
+	logger  *log.Logger
+	timeout time.Duration // 一个事件外置的总时间
+
+	truncated    int64
+	dropped      int64
+	externalized int64
+}
+
+// NewLargeValuePolicy 根据配置创建大字段处理策略，策略为 none 时返回 nil
+// externalize 策略需要 store，其他策略忽略
+func NewLargeValuePolicy(cfg config.LargeValueConfig, store objectstore.Store, logger *log.Logger) (*LargeValuePolicy, error) {
+	mode := cfg.Policy
+	if mode == "" {
+		mode = LargeValueNone
+	}
+	switch mode {
+	case LargeValueNone:
+		return nil, nil
+	case LargeValueTruncate, LargeValueDrop:
+	case LargeValueExternalize:
+		if store == nil {
+			return nil, fmt.Errorf("externalize policy requires object_store to be configured")
+		}
+	default:
+		return nil, fmt.Errorf("unknown large value policy %q, expected none, truncate, drop or externalize", mode)
+	}
+	if cfg.MaxSize <= 0 {
+		return nil, fmt.Errorf("large value max_size must be positive")
+	}
+
+	return &LargeValuePolicy{mode: mode, maxSize: cfg.MaxSize, store: store, logger: logger, timeout: externalizeTimeout}, nil
+}
+
+// Apply 处理事件中的大字段，nil 表示不处理
+func (p *LargeValuePolicy) Apply(ctx context.Context, event *Event) {
+	if p == nil {
+		return
+	}
+	// 前后镜像中的同名列外置时使用不同的 key，避免相互覆盖
+	images := []struct {
+		name string
+		data *RowData
+	}{{"before", event.BeforeData}, {"after", event.AfterData}}
+	var pending []*largeValue
+	for _, image := range images {
+		if image.data == nil {
+			continue
+		}
+		for i := range image.data.Columns {
+			col := &image.data.Columns[i]
+			raw, ok := p.oversized(col)
+			if !ok {
+				continue
+			}
+			col.OriginalSize = len(raw)
+			switch p.mode {
+			case LargeValueDrop:
+				col.Value = nil
+				col.Dropped = true
+				atomic.AddInt64(&p.dropped, 1)
+			case LargeValueExternalize:
+				pending = append(pending, &largeValue{image: image.name, col: col, raw: raw})
+			default:
+				p.truncate(col)
+			}
+		}
+	}
+	if len(pending) > 0 {
+		p.externalizeAll(ctx, event, pending)
+	}
+}
+
+// largeValue 待外置的值及写入结果
+type largeValue struct {
+	image string
+	col   *Column
+	raw   []byte
+	ref   string
+	err   error
+}
+
+// oversized 返回超过大小限制的字符串或二进制值
+func (p *LargeValuePolicy) oversized(col *Column) ([]byte, bool) {
+	switch v := col.Value.(type) {
+	case string:
+		if len(v) > p.maxSize {
+			return []byte(v), true
+		}
+	case []byte:
+		if len(v) > p.maxSize {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// truncate 截断列值并计数
+func (p *LargeValuePolicy) truncate(col *Column) {
+	col.Value = truncateValue(col.Value, p.maxSize)
+	col.Truncated = true
+	atomic.AddInt64(&p.truncated, 1)
+}
+
+// externalizeAll 并发将事件中的大字段写入对象存储，所有值共用 externalizeTimeout。
+// 对象存储不可用或超时时退化为截断，不阻塞同步
+func (p *LargeValuePolicy) externalizeAll(ctx context.Context, event *Event, values []*largeValue) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	slots := make(chan struct{}, externalizeConcurrency)
+	var wg sync.WaitGroup
+	for _, v := range values {
+		wg.Add(1)
+		go func(v *largeValue) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+				v.ref, v.err = p.externalize(ctx, event, v.image, v.col, v.raw)
+			case <-ctx.Done():
+				v.err = ctx.Err()
+			}
+		}(v)
+	}
+	wg.Wait()
+
+	for _, v := range values {
+		if v.err == nil {
+			v.col.Value = nil
+			v.col.Ref = v.ref
+			atomic.AddInt64(&p.externalized, 1)
+			continue
+		}
+		p.logger.Printf("⚠️ Failed to externalize %s.%s.%s (%d bytes), truncating instead: %v",
+			event.Schema, event.Table, v.col.Name, len(v.raw), v.err)
+		p.truncate(v.col)
+	}
+}
+
+// externalize 将值写入对象存储，返回引用地址
+func (p *LargeValuePolicy) externalize(ctx context.Context, event *Event, image string, col *Column, raw []byte) (string, error) {
+	key := strings.Join([]string{
+		"large-values",
+		objectKeySegment(event.Schema),
+		objectKeySegment(event.Table),
+		objectKeySegment(event.ID),
+		image,
+		objectKeySegment(col.Name),
+	}, "/")
+
+	contentType := "application/octet-stream"
+	if _, ok := col.Value.(string); ok {
+		contentType = "text/plain; charset=utf-8"
+	}
+	return p.store.Put(ctx, key, raw, contentType)
+}
+
+// Stats 大字段处理统计
+func (p *LargeValuePolicy) Stats() map[string]interface{} {
+	if p == nil {
+		return map[string]interface{}{"policy": LargeValueNone}
+	}
+	return map[string]interface{}{
+		"policy":       p.mode,
+		"max_size":     p.maxSize,
+		"truncated":    atomic.LoadInt64(&p.truncated),
+		"dropped":      atomic.LoadInt64(&p.dropped),
+		"externalized": atomic.LoadInt64(&p.externalized),
+	}
+}
+
+// truncateValue 截断到 maxSize 字节，字符串按 UTF-8 字符边界截断并追加标记
+func truncateValue(value interface{}, maxSize int) interface{} {
+	switch v := value.(type) {
+	case string:
+		cut := maxSize
+		for cut > 0 && !utf8.RuneStart(v[cut]) {
+			cut--
+		}
+		return v[:cut] + truncatedMarker
+	case []byte:
+		return append([]byte(nil), v[:maxSize]...)
+	}
+	return value
+}
+
+// objectKeySegment 将名称转换为对象存储 key 中安全的片段
+func objectKeySegment(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,208 @@
+package canal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// fakeObjectStore 记录写入的对象
+type fakeObjectStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	err     error
+}
+
+func (s *fakeObjectStore) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return "", s.err
+	}
+	s.objects[key] = data
+	return "s3://bucket/" + key, nil
+}
+
+func (s *fakeObjectStore) setErr(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+func (s *fakeObjectStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.objects)
+}
+
+// newLargeValueEvent 创建包含一个大字段和一个小字段的更新事件
+func newLargeValueEvent(body string) *Event {
+	row := func() *RowData {
+		return &RowData{Columns: []Column{
+			{Name: "id", Type: "int", Value: int64(1)},
+			{Name: "title", Type: "string", Value: "hello"},
+			{Name: "body", Type: "string", Value: body},
+		}}
+	}
+	return &Event{ID: "mysql-bin.000001:0000000004:00000", Schema: "testdb", Table: "posts",
+		EventType: EventTypeUpdate, BeforeData: row(), AfterData: row()}
+}
+
+// TestLargeValuePolicy 测试各种大字段处理策略
+func TestLargeValuePolicy(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	// 多字节字符跨越截断位置
+	body := strings.Repeat("a", 9) + "中文内容"
+
+	policy, err := NewLargeValuePolicy(config.LargeValueConfig{Policy: LargeValueTruncate, MaxSize: 10}, nil, logger)
+	if err != nil {
+		t.Fatalf("NewLargeValuePolicy failed: %v", err)
+	}
+	event := newLargeValueEvent(body)
+	policy.Apply(context.Background(), event)
+	col := event.AfterData.Columns[2]
+	if col.Value != strings.Repeat("a", 9)+truncatedMarker || !col.Truncated || col.OriginalSize != len(body) {
+		t.Errorf("unexpected truncated column: %+v", col)
+	}
+	if event.AfterData.Columns[1].Truncated || event.AfterData.Columns[1].Value != "hello" {
+		t.Errorf("small column should be untouched: %+v", event.AfterData.Columns[1])
+	}
+
+	policy, _ = NewLargeValuePolicy(config.LargeValueConfig{Policy: LargeValueDrop, MaxSize: 10}, nil, logger)
+	event = newLargeValueEvent(body)
+	event.AfterData.Columns[2].Value = []byte(body)
+	policy.Apply(context.Background(), event)
+	col = event.AfterData.Columns[2]
+	if col.Value != nil || !col.Dropped || col.OriginalSize != len(body) {
+		t.Errorf("unexpected dropped column: %+v", col)
+	}
+
+	store := &fakeObjectStore{objects: make(map[string][]byte)}
+	policy, _ = NewLargeValuePolicy(config.LargeValueConfig{Policy: LargeValueExternalize, MaxSize: 10}, store, logger)
+	event = newLargeValueEvent(body)
+	policy.Apply(context.Background(), event)
+	col = event.AfterData.Columns[2]
+	wantKey := "large-values/testdb/posts/mysql-bin.000001_0000000004_00000/after/body"
+	if col.Value != nil || col.Ref != "s3://bucket/"+wantKey || string(store.objects[wantKey]) != body {
+		t.Errorf("unexpected externalized column: %+v", col)
+	}
+	if len(store.objects) != 2 {
+		t.Errorf("expected before and after images stored separately, got %d objects", len(store.objects))
+	}
+
+	// 对象存储失败时退化为截断
+	store.err = fmt.Errorf("unavailable")
+	event = newLargeValueEvent(body)
+	policy.Apply(context.Background(), event)
+	if col = event.AfterData.Columns[2]; !col.Truncated || col.Ref != "" {
+		t.Errorf("expected fallback to truncation: %+v", col)
+	}
+	if stats := policy.Stats(); stats["externalized"] != int64(2) || stats["truncated"] != int64(2) {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+
+	if policy, err := NewLargeValuePolicy(config.LargeValueConfig{Policy: LargeValueNone}, nil, logger); err != nil || policy != nil {
+		t.Errorf("none policy should be nil, got %v, %v", policy, err)
+	}
+	if _, err := NewLargeValuePolicy(config.LargeValueConfig{Policy: LargeValueExternalize, MaxSize: 10}, nil, logger); err == nil {
+		t.Errorf("expected error for externalize without store")
+	}
+	if _, err := NewLargeValuePolicy(config.LargeValueConfig{Policy: "compress", MaxSize: 10}, nil, logger); err == nil {
+		t.Errorf("expected error for unknown policy")
+	}
+}
+
+// blockingObjectStore 写入等待 release 关闭或 ctx 取消
+type blockingObjectStore struct {
+	release chan struct{}
+	puts    int32
+}
+
+func (s *blockingObjectStore) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	atomic.AddInt32(&s.puts, 1)
+	select {
+	case <-s.release:
+		return "s3://bucket/" + key, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// TestLargeValueExternalizeBudget 测试一个事件的所有值并发写入，共用总时间，超时的值退化为截断
+func TestLargeValueExternalizeBudget(t *testing.T) {
+	store := &blockingObjectStore{release: make(chan struct{})}
+	policy, err := NewLargeValuePolicy(config.LargeValueConfig{Policy: LargeValueExternalize, MaxSize: 10}, store, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.timeout = 100 * time.Millisecond
+
+	event := newLargeValueEvent(strings.Repeat("x", 20))
+	start := time.Now()
+	policy.Apply(context.Background(), event)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected both values to share the budget, took %s", elapsed)
+	}
+	if atomic.LoadInt32(&store.puts) != 2 {
+		t.Errorf("expected 2 concurrent puts, got %d", store.puts)
+	}
+	for _, data := range []*RowData{event.BeforeData, event.AfterData} {
+		if col := data.Columns[2]; !col.Truncated || col.Ref != "" {
+			t.Errorf("expected fallback to truncation after the budget, got %+v", col)
+		}
+	}
+}
+
+// TestLargeValuesDoNotBlockReading 测试外置在出队后执行，对象存储慢时 SendEvent 不等待
+func TestLargeValuesDoNotBlockReading(t *testing.T) {
+	eventSink := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	store := &blockingObjectStore{release: make(chan struct{})}
+	policy, err := NewLargeValuePolicy(config.LargeValueConfig{Policy: LargeValueExternalize, MaxSize: 10}, store, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventSink.SetLargeValuePolicy(policy)
+	handler := &blockingEventHandler{name: "webhook-1", release: make(chan struct{})}
+	close(handler.release)
+	eventSink.Subscribe("testdb", "posts", handler)
+	if err := eventSink.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer eventSink.Stop()
+
+	event := newLargeValueEvent(strings.Repeat("x", 20))
+	event.Position = Position{Name: "mysql-bin.000001", Pos: 100}
+	done := make(chan error, 1)
+	go func() { done <- eventSink.SendEvent(event) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("SendEvent blocked on the object store")
+	}
+	if event.AfterData.Columns[2].Value == nil {
+		t.Error("large values should not be externalized before the event is queued")
+	}
+
+	close(store.release)
+	deadline := time.Now().Add(2 * time.Second)
+	for eventSink.AckedPosition().Pos != 100 {
+		if time.Now().After(deadline) {
+			t.Fatal("event was not processed after the store recovered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if col := event.AfterData.Columns[2]; col.Ref == "" || col.Value != nil {
+		t.Errorf("expected the value externalized before delivery, got %+v", col)
+	}
+}
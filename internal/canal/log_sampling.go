@@ -0,0 +1,134 @@
+package canal
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// 热点路径：每个事件都会经过、逐条输出日志的路径，见 LogSampler
+const (
+	LogPathRead     = "read"     // binlog 读取：逐行解析、生成事件
+	LogPathSend     = "send"     // 事件进入事件接收器的队列
+	LogPathDispatch = "dispatch" // 出队、路由并放入各 sink 的队列
+	LogPathHandle   = "handle"   // 处理器收到并处理单个事件
+	LogPathFlush    = "flush"    // 处理器批量刷新、发送请求和写库
+)
+
+// LogPaths 支持采样的热点路径
+var LogPaths = []string{LogPathRead, LogPathSend, LogPathDispatch, LogPathHandle, LogPathFlush}
+
+// LogSampler 热点路径日志采样：每条路径在每个时间窗口内输出前 initial 条日志，之后每 thereafter 条输出一条，
+// thereafter 为 0 时窗口内剩余的日志全部丢弃。只用于逐事件的调试和进度日志，错误和警告不经过采样。
+// nil 表示不采样，所有日志照常输出
+type LogSampler struct {
+	interval int64 // 时间窗口，纳秒
+	paths    map[string]*sampledPath
+}
+
+// sampledPath 单条路径的采样状态和统计，原子访问
+type sampledPath struct {
+	initial    int64
+	thereafter int64
+
+	window     int64 // 当前窗口的开始时间，UnixNano
+	count      int64 // 当前窗口内的日志条数
+	logged     int64
+	suppressed int64
+}
+
+// NewLogSampler 根据配置创建日志采样，未启用时返回 nil
+func NewLogSampler(cfg config.LogSamplingConfig) (*LogSampler, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	interval := time.Second
+	if cfg.Interval != "" {
+		d, err := time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval %q: %v", cfg.Interval, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("interval must be positive, got %s", cfg.Interval)
+		}
+		interval = d
+	}
+	if cfg.Initial < 0 || cfg.Thereafter < 0 {
+		return nil, fmt.Errorf("initial and thereafter must not be negative")
+	}
+
+	sampler := &LogSampler{interval: int64(interval), paths: make(map[string]*sampledPath, len(LogPaths))}
+	for _, path := range LogPaths {
+		sampler.paths[path] = &sampledPath{initial: int64(cfg.Initial), thereafter: int64(cfg.Thereafter)}
+	}
+	// 按路径设置的采样覆盖默认值
+	for path, override := range cfg.Paths {
+		p, ok := sampler.paths[path]
+		if !ok {
+			return nil, fmt.Errorf("unknown log path %q, supported: %v", path, LogPaths)
+		}
+		if override.Initial < 0 || override.Thereafter < 0 {
+			return nil, fmt.Errorf("initial and thereafter of log path %q must not be negative", path)
+		}
+		p.initial, p.thereafter = int64(override.Initial), int64(override.Thereafter)
+	}
+	return sampler, nil
+}
+
+// Allow 判断路径上的这条日志是否输出，并计入统计
+func (l *LogSampler) Allow(path string) bool {
+	if l == nil {
+		return true
+	}
+	p, ok := l.paths[path]
+	if !ok {
+		return true
+	}
+	return p.allow(time.Now().UnixNano(), l.interval)
+}
+
+func (p *sampledPath) allow(now, interval int64) bool {
+	// 进入新窗口时重新计数，只有一个调用方能切换窗口
+	if window := atomic.LoadInt64(&p.window); now-window >= interval && atomic.CompareAndSwapInt64(&p.window, window, now) {
+		atomic.StoreInt64(&p.count, 0)
+	}
+	n := atomic.AddInt64(&p.count, 1)
+	if n <= p.initial || (p.thereafter > 0 && (n-p.initial)%p.thereafter == 0) {
+		atomic.AddInt64(&p.logged, 1)
+		return true
+	}
+	atomic.AddInt64(&p.suppressed, 1)
+	return false
+}
+
+// Printf 按路径采样后输出到 logger，输出的文件名和行号为调用方的位置
+func (l *LogSampler) Printf(logger *log.Logger, path, format string, args ...interface{}) {
+	if !l.Allow(path) {
+		return
+	}
+	logger.Output(2, fmt.Sprintf(format, args...))
+}
+
+// Stats 各路径输出和丢弃的日志条数
+func (l *LogSampler) Stats() map[string]interface{} {
+	if l == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	paths := make(map[string]interface{}, len(l.paths))
+	for path, p := range l.paths {
+		paths[path] = map[string]interface{}{
+			"initial":    p.initial,
+			"thereafter": p.thereafter,
+			"logged":     atomic.LoadInt64(&p.logged),
+			"suppressed": atomic.LoadInt64(&p.suppressed),
+		}
+	}
+	return map[string]interface{}{
+		"enabled":  true,
+		"interval": time.Duration(l.interval).String(),
+		"paths":    paths,
+	}
+}
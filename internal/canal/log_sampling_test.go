@@ -0,0 +1,71 @@
+package canal
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// TestLogSampler 测试每个窗口输出前 initial 条，之后每 thereafter 条输出一条，新窗口重新计数
+func TestLogSampler(t *testing.T) {
+	sampler, err := NewLogSampler(config.LogSamplingConfig{Enabled: true, Interval: "1s", Initial: 2, Thereafter: 3,
+		Paths: map[string]config.LogPathSampling{LogPathFlush: {}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	interval := int64(time.Second)
+	now := time.Now().UnixNano()
+	p := sampler.paths[LogPathSend]
+	var got []bool
+	for i := 0; i < 8; i++ {
+		got = append(got, p.allow(now, interval))
+	}
+	want := []bool{true, true, false, false, true, false, false, true}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+	if !p.allow(now+interval, interval) || !p.allow(now+interval, interval) || p.allow(now+interval, interval) {
+		t.Error("expected the count reset in a new window")
+	}
+
+	// 按路径覆盖为 0 时该路径的日志全部丢弃
+	if sampler.paths[LogPathFlush].allow(now, interval) {
+		t.Error("expected flush logs suppressed")
+	}
+	stats := sampler.Stats()["paths"].(map[string]interface{})[LogPathSend].(map[string]interface{})
+	if stats["logged"] != int64(6) || stats["suppressed"] != int64(5) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+// TestLogSamplerPrintf 测试未启用时照常输出，采样输出的行号为调用方的位置
+func TestLogSamplerPrintf(t *testing.T) {
+	var nilSampler *LogSampler
+	var out bytes.Buffer
+	logger := log.New(&out, "", log.Lshortfile)
+	for i := 0; i < 3; i++ {
+		nilSampler.Printf(logger, LogPathHandle, "event %d", i)
+	}
+	if n := strings.Count(out.String(), "\n"); n != 3 {
+		t.Errorf("expected all 3 lines without sampling, got %d", n)
+	}
+	if !strings.HasPrefix(out.String(), "log_sampling_test.go:") {
+		t.Errorf("expected the caller position, got %q", out.String())
+	}
+
+	if sampler, err := NewLogSampler(config.LogSamplingConfig{}); sampler != nil || err != nil {
+		t.Errorf("expected nil sampler when disabled, got %v, %v", sampler, err)
+	}
+	if _, err := NewLogSampler(config.LogSamplingConfig{Enabled: true, Paths: map[string]config.LogPathSampling{"unknown": {}}}); err == nil {
+		t.Error("expected error for unknown log path")
+	}
+	if _, err := NewLogSampler(config.LogSamplingConfig{Enabled: true, Interval: "0s"}); err == nil {
+		t.Error("expected error for non-positive interval")
+	}
+}
@@ -0,0 +1,233 @@
+package canal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSourceID canal 配置的源库 ID，目前所有实例都读取这个源库
+const DefaultSourceID = "default"
+
+// MaintenancePause 一次维护暂停
+type MaintenancePause struct {
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since" format:"date-time"`
+}
+
+// MaintenanceStatus 维护暂停开关的状态
+type MaintenanceStatus struct {
+	Paused  bool                         `json:"paused"`           // 全局或任一源库处于暂停
+	Global  *MaintenancePause            `json:"global,omitempty"` // 全局暂停
+	Sources map[string]*MaintenancePause `json:"sources"`          // 按源库的暂停
+}
+
+// MaintenanceSwitch 全局及按源库的维护暂停开关，所有实例共享。
+// 暂停期间实例停止读取 binlog，恢复后从保存的位置继续
+type MaintenanceSwitch struct {
+	mu      sync.Mutex
+	global  *MaintenancePause
+	sources map[string]*MaintenancePause
+	changed chan struct{} // 状态变化时关闭并替换，唤醒等待中的实例
+}
+
+// NewMaintenanceSwitch 创建维护暂停开关
+func NewMaintenanceSwitch() *MaintenanceSwitch {
+	return &MaintenanceSwitch{
+		sources: make(map[string]*MaintenancePause),
+		changed: make(chan struct{}),
+	}
+}
+
+// Pause 暂停读取，source 为空表示全局暂停。已暂停时保留原来的开始时间，只更新原因
+func (s *MaintenanceSwitch) Pause(source, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.global
+	if source != "" {
+		current = s.sources[source]
+	}
+	pause := &MaintenancePause{Reason: reason, Since: time.Now()}
+	if current != nil {
+		pause.Since = current.Since
+	}
+	if source == "" {
+		s.global = pause
+	} else {
+		s.sources[source] = pause
+	}
+	s.notifyLocked()
+}
+
+// Restore 按给定的开始时间设置暂停，用于恢复重启前持久化的暂停，source 为空表示全局暂停
+func (s *MaintenanceSwitch) Restore(source string, pause MaintenancePause) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if source == "" {
+		s.global = &pause
+	} else {
+		s.sources[source] = &pause
+	}
+	s.notifyLocked()
+}
+
+// Resume 恢复读取，source 为空表示解除全局暂停。返回之前是否处于暂停
+func (s *MaintenanceSwitch) Resume(source string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if source == "" {
+		if s.global == nil {
+			return false
+		}
+		s.global = nil
+	} else {
+		if s.sources[source] == nil {
+			return false
+		}
+		delete(s.sources, source)
+	}
+	s.notifyLocked()
+	return true
+}
+
+// notifyLocked 唤醒等待状态变化的实例，调用方需持有锁
+func (s *MaintenanceSwitch) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Paused 返回 source 当前生效的暂停，全局暂停优先，未暂停时返回 nil
+func (s *MaintenanceSwitch) Paused(source string) *MaintenancePause {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.global != nil {
+		return s.global
+	}
+	return s.sources[source]
+}
+
+// Changed 返回在下一次状态变化时关闭的通道，nil 开关返回 nil（永不关闭）
+func (s *MaintenanceSwitch) Changed() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changed
+}
+
+// Wait 等待 source 解除暂停，ctx 取消时返回错误。onPause 在开始等待前调用一次
+func (s *MaintenanceSwitch) Wait(ctx context.Context, source string, onPause func(*MaintenancePause)) error {
+	if s == nil {
+		return nil
+	}
+
+	notified := false
+	for {
+		s.mu.Lock()
+		pause := s.global
+		if pause == nil {
+			pause = s.sources[source]
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if pause == nil {
+			return nil
+		}
+		if !notified && onPause != nil {
+			onPause(pause)
+			notified = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Status 获取开关状态
+func (s *MaintenanceSwitch) Status() MaintenanceStatus {
+	status := MaintenanceStatus{Sources: make(map[string]*MaintenancePause)}
+	if s == nil {
+		return status
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status.Global = s.global
+	for source, pause := range s.sources {
+		status.Sources[source] = pause
+	}
+	status.Paused = s.global != nil || len(s.sources) > 0
+	return status
+}
+
+// SetMaintenance 设置维护暂停开关，nil 表示不支持人工暂停
+func (m *MySQLBinlogSlave) SetMaintenance(maintenance *MaintenanceSwitch) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maintenance = maintenance
+}
+
+// SavePosition 立即保存已投递完成的位置，人工维护暂停时调用，不等待 binlog 流协程断开
+func (m *MySQLBinlogSlave) SavePosition() (Position, error) {
+	return m.savePositionSync()
+}
+
+// savePositionSync 同步保存已投递完成的位置和各 sink 的进度，暂停或停止后进程重启也能从这里继续。
+// 返回保存的位置，没有可保存的位置时 Name 为空
+func (m *MySQLBinlogSlave) savePositionSync() (Position, error) {
+	if m.metaManager == nil {
+		return Position{}, nil
+	}
+
+	m.mu.Lock()
+	pos := Position{}
+	var offsets map[string]Position
+	if m.binlogPos.Name != "" {
+		pos = m.committedPositionLocked()
+		offsets = m.eventSink.SinkOffsets(m.readPositionLocked())
+	}
+	if pos.Name != "" {
+		m.savedPos = pos
+	}
+	m.mu.Unlock()
+	if pos.Name == "" {
+		return pos, nil
+	}
+
+	atomic.AddInt64(&m.savesStarted, 1)
+	defer atomic.AddInt64(&m.savesFinished, 1)
+	m.saveSinkOffsets(offsets)
+	if err := m.metaManager.SavePosition(m.instanceID, pos); err != nil {
+		return Position{}, err
+	}
+	return pos, nil
+}
+
+// setMaintenancePaused 更新人工维护暂停状态并在变化时记录日志
+func (m *MySQLBinlogSlave) setMaintenancePaused(paused bool, reason string) {
+	m.mu.Lock()
+	changed := m.maintenancePaused != paused
+	m.maintenancePaused = paused
+	m.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if paused {
+		m.logger.Printf("⏸️ Maintenance pause requested (%s), pausing binlog reading for %s", reason, m.instanceID)
+	} else {
+		m.logger.Printf("▶️ Maintenance pause lifted, resuming binlog reading for %s", m.instanceID)
+	}
+}
@@ -0,0 +1,187 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// TestMaintenanceSwitchScopes 测试全局暂停和按源库暂停相互独立
+func TestMaintenanceSwitchScopes(t *testing.T) {
+	s := NewMaintenanceSwitch()
+	if s.Paused(DefaultSourceID) != nil || s.Status().Paused {
+		t.Fatal("new switch should not be paused")
+	}
+
+	s.Pause(DefaultSourceID, "failover")
+	if pause := s.Paused(DefaultSourceID); pause == nil || pause.Reason != "failover" {
+		t.Fatalf("expected source pause, got %+v", pause)
+	}
+	if s.Paused("other") != nil {
+		t.Error("source pause should not affect other sources")
+	}
+
+	// 全局暂停优先，解除全局暂停后源库暂停仍然生效
+	s.Pause("", "upgrade")
+	if pause := s.Paused("other"); pause == nil || pause.Reason != "upgrade" {
+		t.Fatalf("expected global pause, got %+v", pause)
+	}
+	if !s.Resume("") {
+		t.Error("Resume should report the global pause")
+	}
+	if s.Resume("") {
+		t.Error("second Resume should report nothing to resume")
+	}
+	if s.Paused(DefaultSourceID) == nil {
+		t.Error("source pause should survive global resume")
+	}
+
+	// 重复暂停保留开始时间
+	since := s.Paused(DefaultSourceID).Since
+	s.Pause(DefaultSourceID, "still failover")
+	if pause := s.Paused(DefaultSourceID); !pause.Since.Equal(since) || pause.Reason != "still failover" {
+		t.Errorf("repeated pause should keep since and update reason, got %+v", pause)
+	}
+
+	s.Resume(DefaultSourceID)
+	if s.Status().Paused {
+		t.Error("switch should not be paused after resuming all scopes")
+	}
+}
+
+// TestMaintenanceSwitchWait 测试等待在恢复后返回，ctx 取消时返回错误
+func TestMaintenanceSwitchWait(t *testing.T) {
+	var nilSwitch *MaintenanceSwitch
+	if err := nilSwitch.Wait(context.Background(), DefaultSourceID, nil); err != nil {
+		t.Fatalf("nil switch should not block: %v", err)
+	}
+
+	s := NewMaintenanceSwitch()
+	s.Pause("", "maintenance")
+
+	paused := make(chan *MaintenancePause, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Wait(context.Background(), DefaultSourceID, func(pause *MaintenancePause) {
+			paused <- pause
+		})
+	}()
+
+	select {
+	case pause := <-paused:
+		if pause.Reason != "maintenance" {
+			t.Errorf("unexpected pause %+v", pause)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("onPause was not called")
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Wait returned while paused: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	s.Resume("")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Wait failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait did not return after resume")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Pause(DefaultSourceID, "")
+	go func() {
+		done <- s.Wait(ctx, DefaultSourceID, nil)
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait did not return after cancel")
+	}
+}
+
+// TestMaintenancePauseDisconnects 测试人工维护暂停时在事务之间断开 binlog 连接并保存位置，恢复后立即返回
+func TestMaintenancePauseDisconnects(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	meta := &memoryMetaManager{positions: make(map[string]Position)}
+	slave, err := NewMySQLBinlogSlaveWithMeta(MySQLConfig{Host: "127.0.0.1", Port: 1, ServerID: 1001},
+		NewDefaultEventSink(logger), logger, meta)
+	if err != nil {
+		t.Fatalf("Failed to create binlog slave: %v", err)
+	}
+	slave.ctx, slave.cancel = context.WithCancel(context.Background())
+	defer slave.cancel()
+	slave.binlogPos.Name, slave.binlogPos.Pos = "mysql-bin.000001", 400
+	maintenance := NewMaintenanceSwitch()
+	slave.SetMaintenance(maintenance)
+
+	next := &replication.BinlogEvent{Header: &replication.EventHeader{EventType: replication.QUERY_EVENT}}
+	maintenance.Pause(DefaultSourceID, "failover")
+	if err := slave.waitReadLimits(next); err != nil {
+		t.Fatalf("expected reading to continue inside a transaction, got %v", err)
+	}
+	slave.atResumePoint = true
+	if err := slave.waitReadLimits(next); err != errStreamPaused {
+		t.Fatalf("expected errStreamPaused, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- slave.waitStreamResume() }()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pos, _ := meta.LoadPosition(slave.instanceID)
+		if pos.Pos == 400 && slave.GetStats()["maintenance_paused"] == true {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected position saved and maintenance paused, got %+v", pos)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 恢复后不等到下一分钟
+	maintenance.Resume(DefaultSourceID)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("waitStreamResume failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waitStreamResume did not return after resume")
+	}
+	if slave.GetStats()["maintenance_paused"] == true {
+		t.Error("expected maintenance pause cleared")
+	}
+}
+
+// TestMaintenanceSwitchRestore 测试恢复持久化的暂停保留开始时间并唤醒等待方
+func TestMaintenanceSwitchRestore(t *testing.T) {
+	var nilSwitch *MaintenanceSwitch
+	if nilSwitch.Changed() != nil {
+		t.Error("nil switch should return a nil channel")
+	}
+
+	s := NewMaintenanceSwitch()
+	changed := s.Changed()
+	since := time.Now().Add(-time.Hour)
+	s.Restore("", MaintenancePause{Reason: "upgrade", Since: since})
+	select {
+	case <-changed:
+	default:
+		t.Error("expected Changed closed after Restore")
+	}
+	if pause := s.Paused(DefaultSourceID); pause == nil || !pause.Since.Equal(since) || pause.Reason != "upgrade" {
+		t.Errorf("expected restored global pause, got %+v", pause)
+	}
+}
@@ -0,0 +1,149 @@
+package canal
+
+import (
+	"sync/atomic"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// BufferedHandler 缓冲事件的处理器，报告缓冲中的事件占用的字节数
+type BufferedHandler interface {
+	BufferedBytes() int64
+}
+
+// 事件大小估算的固定开销
+const (
+	eventOverhead  = 256 // Event 结构体、ID、库表名等
+	columnOverhead = 64  // Column 结构体和接口值
+)
+
+// EventSize 估算事件在内存中占用的字节数，用于缓冲区记账，不追求精确
+func EventSize(event *Event) int64 {
+	size := int64(eventOverhead + len(event.ID) + len(event.Schema) + len(event.Table) + len(event.SQL))
+	for _, data := range []*RowData{event.BeforeData, event.AfterData} {
+		if data == nil {
+			continue
+		}
+		for _, col := range data.Columns {
+			size += int64(columnOverhead + len(col.Name) + len(col.Type) + len(col.Ref))
+			switch v := col.Value.(type) {
+			case string:
+				size += int64(len(v))
+			case []byte:
+				size += int64(len(v))
+			default:
+				size += 16
+			}
+		}
+	}
+	return size
+}
+
+// MemoryLimits 实例缓冲区内存限制，0 表示不限制
+// 超过软限制时放慢 binlog 读取形成背压，超过硬限制时暂停读取，直到回落到软限制以下
+type MemoryLimits struct {
+	Soft int64
+	Hard int64
+}
+
+// NewMemoryLimits 根据配置创建内存限制
+func NewMemoryLimits(cfg config.MemoryConfig) MemoryLimits {
+	return MemoryLimits{
+		Soft: int64(cfg.SoftLimitMB * 1024 * 1024),
+		Hard: int64(cfg.HardLimitMB * 1024 * 1024),
+	}
+}
+
+// Enabled 是否配置了任一限制
+func (l MemoryLimits) Enabled() bool {
+	return l.Soft > 0 || l.Hard > 0
+}
+
+// resumeThreshold 硬限制暂停后恢复读取的阈值，未配置软限制时取硬限制的一半
+func (l MemoryLimits) resumeThreshold() int64 {
+	if l.Soft > 0 && l.Soft < l.Hard {
+		return l.Soft
+	}
+	return l.Hard / 2
+}
+
+// 背压等待参数
+const (
+	backpressureDelay  = 10 * time.Millisecond  // 超过软限制时每个事件的等待时间
+	memoryPollInterval = 100 * time.Millisecond // 暂停期间检查缓冲区的间隔
+)
+
+// SetMemoryLimits 设置缓冲区内存限制，usage 返回实例当前缓冲的字节数
+func (m *MySQLBinlogSlave) SetMemoryLimits(limits MemoryLimits, usage func() int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.memoryLimits = limits
+	m.memoryUsage = usage
+}
+
+// waitMemory 缓冲区超过限制时等待下游消化，ctx 取消时返回错误
+func (m *MySQLBinlogSlave) waitMemory() error {
+	m.mu.RLock()
+	limits, usage := m.memoryLimits, m.memoryUsage
+	m.mu.RUnlock()
+	if !limits.Enabled() || usage == nil {
+		return nil
+	}
+
+	for {
+		used := usage()
+
+		m.mu.RLock()
+		paused := m.memoryPaused
+		m.mu.RUnlock()
+
+		// 达到硬限制或暂停后尚未回落到恢复阈值：暂停读取
+		if limits.Hard > 0 && (used >= limits.Hard || (paused && used >= limits.resumeThreshold())) {
+			m.setMemoryPaused(true, used)
+			if err := m.sleep(memoryPollInterval); err != nil {
+				return err
+			}
+			atomic.AddInt64(&m.memoryWaitNanos, int64(memoryPollInterval))
+			continue
+		}
+		m.setMemoryPaused(false, used)
+
+		// 超过软限制：每个事件等待一小段时间，让下游追上
+		if limits.Soft > 0 && used >= limits.Soft {
+			atomic.AddInt64(&m.backpressureCount, 1)
+			atomic.AddInt64(&m.memoryWaitNanos, int64(backpressureDelay))
+			return m.sleep(backpressureDelay)
+		}
+		return nil
+	}
+}
+
+// sleep 等待一段时间，ctx 取消时返回错误
+func (m *MySQLBinlogSlave) sleep(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-m.ctx.Done():
+		return m.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// setMemoryPaused 更新内存暂停状态并在变化时记录日志
+func (m *MySQLBinlogSlave) setMemoryPaused(paused bool, used int64) {
+	m.mu.Lock()
+	changed := m.memoryPaused != paused
+	m.memoryPaused = paused
+	m.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if paused {
+		m.logger.Printf("⏸️ Buffered events of %s reached hard memory limit (%d bytes), pausing binlog reading", m.instanceID, used)
+	} else {
+		m.logger.Printf("▶️ Buffered events of %s dropped to %d bytes, resuming binlog reading", m.instanceID, used)
+	}
+}
@@ -0,0 +1,116 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// bufferedTestHandler 报告固定缓冲字节数的处理器
+type bufferedTestHandler struct {
+	name  string
+	bytes int64
+}
+
+func (h *bufferedTestHandler) Handle(ctx context.Context, event *Event) error { return nil }
+func (h *bufferedTestHandler) GetName() string                                { return h.name }
+func (h *bufferedTestHandler) BufferedBytes() int64                           { return h.bytes }
+func (h *bufferedTestHandler) Flush(ctx context.Context) error                { return nil }
+func (h *bufferedTestHandler) Close() error                                   { return nil }
+
+// TestSinkMemoryUsage 测试接收器汇总各处理器的缓冲字节数，同一处理器订阅多张表时只计一次
+func TestSinkMemoryUsage(t *testing.T) {
+	sink := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	handler := &bufferedTestHandler{name: "webhook-1", bytes: 1000}
+	sink.Subscribe("test", "users", handler)
+	sink.Subscribe("test", "orders", handler)
+	sink.Subscribe("*", "*", &bufferedTestHandler{name: "db-1", bytes: 24})
+
+	if got := sink.MemoryUsage(); got != 1024 {
+		t.Errorf("expected 1024 buffered bytes, got %d", got)
+	}
+
+	small := &Event{ID: "1", Schema: "test", Table: "users"}
+	large := &Event{ID: "2", Schema: "test", Table: "users", AfterData: &RowData{Columns: []Column{
+		{Name: "body", Type: "text", Value: string(make([]byte, 4096))},
+	}}}
+	if EventSize(large)-EventSize(small) < 4096 {
+		t.Errorf("expected column value to be counted, got %d vs %d", EventSize(large), EventSize(small))
+	}
+}
+
+// TestWaitMemory 测试软限制背压和硬限制暂停、回落后恢复
+func TestWaitMemory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var used int64
+	slave := &MySQLBinlogSlave{ctx: ctx, logger: log.New(io.Discard, "", 0)}
+	slave.SetMemoryLimits(NewMemoryLimits(config.MemoryConfig{SoftLimitMB: 1, HardLimitMB: 2}),
+		func() int64 { return atomic.LoadInt64(&used) })
+
+	// 低于软限制：不等待
+	if err := slave.waitMemory(); err != nil {
+		t.Fatalf("waitMemory failed: %v", err)
+	}
+	if slave.backpressureCount != 0 {
+		t.Errorf("expected no backpressure below soft limit")
+	}
+
+	// 超过软限制：背压
+	atomic.StoreInt64(&used, 1<<20+1)
+	if err := slave.waitMemory(); err != nil {
+		t.Fatalf("waitMemory failed: %v", err)
+	}
+	if atomic.LoadInt64(&slave.backpressureCount) != 1 {
+		t.Errorf("expected backpressure above soft limit")
+	}
+
+	// 超过硬限制：暂停，回落到硬限制以下但仍高于软限制时保持暂停，低于软限制后恢复
+	atomic.StoreInt64(&used, 2<<20)
+	done := make(chan error, 1)
+	go func() { done <- slave.waitMemory() }()
+
+	time.Sleep(2 * memoryPollInterval)
+	atomic.StoreInt64(&used, 1<<20+512<<10)
+	time.Sleep(2 * memoryPollInterval)
+	select {
+	case <-done:
+		t.Fatalf("expected reading to stay paused above soft limit")
+	default:
+	}
+	slave.mu.RLock()
+	paused := slave.memoryPaused
+	slave.mu.RUnlock()
+	if !paused {
+		t.Errorf("expected slave to be memory paused")
+	}
+
+	atomic.StoreInt64(&used, 0)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("waitMemory failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected reading to resume below soft limit")
+	}
+
+	// 暂停期间取消
+	atomic.StoreInt64(&used, 2<<20)
+	go func() { done <- slave.waitMemory() }()
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("expected error when context cancelled")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("waitMemory did not return after cancel")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // DBMetaManager 基于数据库的元数据管理器
@@ -30,15 +31,27 @@ type BinlogPosition struct {
 	CreatedAt  time.Time `gorm:"autoCreateTime"`
 }
 
+// SinkOffset 实例中一个 sink 的投递进度，见 SinkOffsetStore
+type SinkOffset struct {
+	ID         uint      `gorm:"primarykey"`
+	InstanceID string    `gorm:"uniqueIndex:idx_instance_sink;size:100;not null"`
+	Sink       string    `gorm:"uniqueIndex:idx_instance_sink;size:100;not null"`
+	Filename   string    `gorm:"size:255"`
+	Position   uint32    `gorm:"not null"`
+	GTIDSet    string    `gorm:"type:text"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
 // TableMetadata 表元数据记录
 type TableMetadata struct {
-	ID        uint      `gorm:"primarykey"`
-	Schema    string    `gorm:"size:100;not null"`
-	Table     string    `gorm:"size:100;not null"`
-	Columns   string    `gorm:"type:text"` // JSON 格式存储列信息
-	Types     string    `gorm:"type:text"` // JSON 格式存储类型信息
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
+	ID         uint      `gorm:"primarykey"`
+	Schema     string    `gorm:"size:100;not null"`
+	Table      string    `gorm:"size:100;not null"`
+	Columns    string    `gorm:"type:text"` // JSON 格式存储列信息
+	Types      string    `gorm:"type:text"` // JSON 格式存储类型信息
+	PrimaryKey string    `gorm:"type:text"` // JSON 格式存储主键列名
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
 }
 
 // TableName 指定表名
@@ -46,6 +59,11 @@ func (BinlogPosition) TableName() string {
 	return "binlog_positions"
 }
 
+// TableName 指定表名
+func (SinkOffset) TableName() string {
+	return "sink_offsets"
+}
+
 // TableName 指定表名
 func (TableMetadata) TableName() string {
 	return "table_metadata"
@@ -60,7 +78,7 @@ func NewDBMetaManager(db *gorm.DB, logger *log.Logger) (*DBMetaManager, error) {
 		tables: make(map[string]*TableMeta),
 	}
 
-	if err := db.AutoMigrate(&BinlogPosition{}, &TableMetadata{}); err != nil {
+	if err := db.AutoMigrate(&BinlogPosition{}, &SinkOffset{}, &TableMetadata{}); err != nil {
 		return nil, fmt.Errorf("failed to auto migrate tables: %v", err)
 	}
 
@@ -108,10 +126,11 @@ func (m *DBMetaManager) loadCache() error {
 		}
 
 		m.tables[key] = &TableMeta{
-			Schema:  table.Schema,
-			Table:   table.Table,
-			Columns: columns,
-			Types:   types,
+			Schema:     table.Schema,
+			Table:      table.Table,
+			Columns:    columns,
+			Types:      types,
+			PrimaryKey: decodePrimaryKey(table.PrimaryKey),
 		}
 	}
 	m.mu.Unlock()
@@ -153,7 +172,9 @@ func (m *DBMetaManager) SavePosition(instanceID string, pos Position) error {
 	} else {
 		// 更新现有记录
 		log.Printf("🔄 Updating existing binlog position record for instance %s", instanceID)
-		if err := m.db.Where("instance_id = ?", instanceID).Updates(&binlogPos).Error; err != nil {
+		// 显式列出更新的列，回退到没有 GTID 的位置时也要清空 GTIDSet
+		if err := m.db.Model(&BinlogPosition{}).Where("instance_id = ?", instanceID).
+			Select("filename", "position", "gtid_set").Updates(&binlogPos).Error; err != nil {
 			log.Printf("❌ Failed to update binlog position: %v", err)
 			return fmt.Errorf("failed to update binlog position: %v", err)
 		}
@@ -166,14 +187,14 @@ func (m *DBMetaManager) SavePosition(instanceID string, pos Position) error {
 
 // LoadPosition 加载 binlog 位置
 func (m *DBMetaManager) LoadPosition(instanceID string) (Position, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
 	// 记录日志
 	m.logger.Printf("🔍 Loading binlog position for instance %s", instanceID)
 
-	// 先从缓存查找
-	if pos, exists := m.cache[instanceID]; exists {
+	// 先从缓存查找，更新缓存时需要写锁，查库前释放读锁
+	m.mu.RLock()
+	pos, exists := m.cache[instanceID]
+	m.mu.RUnlock()
+	if exists {
 		m.logger.Printf("✅ Found position in cache for instance %s: %s:%d", instanceID, pos.Name, pos.Pos)
 		return pos, nil
 	}
@@ -191,7 +212,7 @@ func (m *DBMetaManager) LoadPosition(instanceID string) (Position, error) {
 		return Position{}, fmt.Errorf("failed to load binlog position: %v", err)
 	}
 
-	pos := Position{
+	pos = Position{
 		Name:    binlogPos.Filename,
 		Pos:     binlogPos.Position,
 		GTIDSet: binlogPos.GTIDSet,
@@ -250,10 +271,11 @@ func (m *DBMetaManager) LoadTableMeta(schema, table string) (*TableMeta, error)
 	}
 
 	meta := &TableMeta{
-		Schema:  tableMeta.Schema,
-		Table:   tableMeta.Table,
-		Columns: columns,
-		Types:   types,
+		Schema:     tableMeta.Schema,
+		Table:      tableMeta.Table,
+		Columns:    columns,
+		Types:      types,
+		PrimaryKey: decodePrimaryKey(tableMeta.PrimaryKey),
 	}
 
 	m.logger.Printf("✅ Loaded table metadata from database for %s.%s with %d columns", schema, table, len(columns))
@@ -268,6 +290,15 @@ func (m *DBMetaManager) LoadTableMeta(schema, table string) (*TableMeta, error)
 	return meta, nil
 }
 
+// decodePrimaryKey 解析保存的主键列名，升级前保存的记录没有主键信息
+func decodePrimaryKey(value string) []string {
+	var primaryKey []string
+	if value != "" {
+		json.Unmarshal([]byte(value), &primaryKey)
+	}
+	return primaryKey
+}
+
 // SaveTableMeta 保存表元数据
 func (m *DBMetaManager) SaveTableMeta(schema, table string, meta *TableMeta) error {
 	m.mu.Lock()
@@ -291,11 +322,17 @@ func (m *DBMetaManager) SaveTableMeta(schema, table string, meta *TableMeta) err
 		return fmt.Errorf("failed to marshal types: %v", err)
 	}
 
+	primaryKeyJSON, err := json.Marshal(meta.PrimaryKey)
+	if err != nil {
+		return fmt.Errorf("failed to marshal primary key: %v", err)
+	}
+
 	tableMeta := TableMetadata{
-		Schema:  schema,
-		Table:   table,
-		Columns: string(columnsJSON),
-		Types:   string(typesJSON),
+		Schema:     schema,
+		Table:      table,
+		Columns:    string(columnsJSON),
+		Types:      string(typesJSON),
+		PrimaryKey: string(primaryKeyJSON),
 	}
 
 	// 使用 UPSERT 操作
@@ -358,7 +395,50 @@ func (m *DBMetaManager) DeletePosition(instanceID string) error {
 	if err := m.db.Where("instance_id = ?", instanceID).Delete(&BinlogPosition{}).Error; err != nil {
 		return fmt.Errorf("failed to delete binlog position: %v", err)
 	}
+	if err := m.db.Where("instance_id = ?", instanceID).Delete(&SinkOffset{}).Error; err != nil {
+		return fmt.Errorf("failed to delete sink offsets: %v", err)
+	}
+
+	return nil
+}
+
+// SaveSinkOffsets 保存实例中各 sink 的投递进度，不在 offsets 中的 sink 保持不变
+func (m *DBMetaManager) SaveSinkOffsets(instanceID string, offsets map[string]Position) error {
+	if len(offsets) == 0 {
+		return nil
+	}
+	rows := make([]SinkOffset, 0, len(offsets))
+	for sink, pos := range offsets {
+		rows = append(rows, SinkOffset{InstanceID: instanceID, Sink: sink, Filename: pos.Name, Position: pos.Pos, GTIDSet: pos.GTIDSet})
+	}
+	err := m.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "instance_id"}, {Name: "sink"}},
+		DoUpdates: clause.AssignmentColumns([]string{"filename", "position", "gtid_set", "updated_at"}),
+	}).Create(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to save sink offsets: %v", err)
+	}
+	return nil
+}
 
+// LoadSinkOffsets 加载实例中各 sink 的投递进度，键为 sink（处理器）名称
+func (m *DBMetaManager) LoadSinkOffsets(instanceID string) (map[string]Position, error) {
+	var rows []SinkOffset
+	if err := m.db.Where("instance_id = ?", instanceID).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load sink offsets: %v", err)
+	}
+	offsets := make(map[string]Position, len(rows))
+	for _, row := range rows {
+		offsets[row.Sink] = Position{Name: row.Filename, Pos: row.Position, GTIDSet: row.GTIDSet}
+	}
+	return offsets, nil
+}
+
+// DeleteSinkOffsets 删除实例中各 sink 的投递进度，实例位置被整体修改后各 sink 从新位置开始
+func (m *DBMetaManager) DeleteSinkOffsets(instanceID string) error {
+	if err := m.db.Where("instance_id = ?", instanceID).Delete(&SinkOffset{}).Error; err != nil {
+		return fmt.Errorf("failed to delete sink offsets: %v", err)
+	}
 	return nil
 }
 
@@ -3,10 +3,12 @@ package canal
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-mysql-org/go-mysql/mysql"
@@ -14,6 +16,14 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 )
 
+// replicationConnections 本进程到源库的复制（binlog dump）连接数，所有实例共用
+var replicationConnections atomic.Int64
+
+// ReplicationConnections 当前已建立的复制连接数，用于准入控制和监控
+func ReplicationConnections() int {
+	return int(replicationConnections.Load())
+}
+
 // MySQLBinlogSlave 纯粹的 MySQL Binlog 从库实现
 // 借鉴 Canal 思想，但只使用 go-mysql-org/go-mysql 的 replication 包
 type MySQLBinlogSlave struct {
@@ -26,8 +36,9 @@ type MySQLBinlogSlave struct {
 	mu        sync.RWMutex
 
 	// 监听配置
-	watchTables map[string]bool    // schema.table -> enabled
-	eventTypes  map[EventType]bool // 监听的事件类型
+	watchTables   map[string]bool    // schema.table -> enabled
+	eventTypes    map[EventType]bool // 监听的事件类型
+	excludeFilter *TableFilter       // 排除的表（在行数据解析前过滤）
 
 	// 运行状态
 	running    bool
@@ -39,31 +50,109 @@ type MySQLBinlogSlave struct {
 
 	// binlog 位置信息
 	binlogPos mysql.Position
-	gtidSet   mysql.GTIDSet
+	gtidSet   mysql.GTIDSet // 已执行（已提交）事务的 GTID 集合，与 binlogPos 一起持久化
+
+	// 当前事务的 GTID，事务提交后并入 gtidSet，只在 binlog 流协程中访问
+	currentGTID string
+	// 当前是否在 BEGIN（或 MariaDB 非独立 GTID 事件）开始的事务中，事务内的语句不是提交点，只在 binlog 流协程中访问
+	inTransaction bool
+	// 当前读取位置是否可以断开后重新连接：不在事务中，也不在 GTID、TABLE_MAP 等随后事件依赖的事件之后，只在 binlog 流协程中访问
+	atResumePoint bool
+
+	// 当前事务在原始源库的提交时间（GTID 事件携带）和已提交的事务数，只在 binlog 流协程中访问
+	commitTime time.Time
+	commits    uint64
+
+	// 当前语句的原始 SQL（ROWS_QUERY 事件），遇到新语句或事务提交时清除，只在 binlog 流协程中访问
+	rowsQuery string
 
 	// 重连和容错机制
 	reconnectInterval time.Duration
 	maxReconnectCount int
 	reconnectCount    int
 	lastEventTime     time.Time
-
-	// 表结构缓存
-	tableSchemas map[string]*TableSchema // schema.table -> TableSchema
+	lag               time.Duration // 最近事件相对源库的延迟
+
+	// 最近一次错误（启动失败、流错误、重连耗尽），收到新事件后清除
+	lastError   string
+	lastErrorAt time.Time
+
+	// binlog 被清除后的告警状态，等待通过 Recover 人工恢复
+	purged     bool
+	purgeError string
+	purgedAt   time.Time
+	recoverCh  chan struct{}
+	recovery   *RecoveryProgress // 最近一次恢复的进度，字段在 mu 下修改
+
+	// 事件ID生成器
+	idGenerator EventIDGenerator
+
+	// 读取限速与维护窗口
+	readThrottle    *ReadThrottle     // 全局读取限速，所有实例共享
+	logSampler      *LogSampler       // 热点路径日志采样，所有实例共享，启动前设置
+	priority        int               // 任务优先级，读取限速时的调度权重
+	schedule        *DeliverySchedule // 任务维护窗口
+	scheduleLimiter *RateLimiter      // 维护窗口 throttle 模式的限速器
+	paused          bool              // 当前处于暂停窗口
+
+	// 切换源库账号后主动断开，重连时不计为错误
+	credentialsRotated bool
+
+	// 人工维护暂停
+	maintenance       *MaintenanceSwitch // 全局及按源库的暂停开关，所有实例共享
+	maintenancePaused bool               // 当前处于人工维护暂停
+
+	// 缓冲区内存限制
+	memoryLimits      MemoryLimits
+	memoryUsage       func() int64 // 实例当前缓冲的字节数
+	memoryPaused      bool         // 达到硬限制暂停读取
+	backpressureCount int64        // 超过软限制放慢读取的事件数
+	memoryWaitNanos   int64        // 因内存限制累计等待的时间
+
+	// 表结构缓存，按 binlog table_id 区分版本，ALTER 后 table_id 变化时重建
+	tableSchemas   map[uint64]*TableSchema // table_id -> TableSchema
+	schemaVersions map[string]int          // schema.table -> 最新版本号
 
 	// 性能统计
 	eventCounter  map[EventType]int64
 	lastStatsTime time.Time
 
-	// 元数据管理器（用于断点续传）
+	// 元数据管理器（用于断点续传），保存的是事件接收器报告的已投递完成的位置，见 CommittedPosition
 	metaManager MetaManager
+	savedPos    Position // 最近一次交给元数据管理器保存的位置
+
+	// checkpoint 为 transaction 时读取到、尚未保存的事务边界，见 boundaryBeforeLocked
+	boundaries     []Position
+	boundaryCommit uint64 // 最近一次记录边界时的提交数
+
+	// 位置漂移检测：进行中的异步保存计数和最近一次检查发现的漂移
+	savesStarted  int64
+	savesFinished int64
+	positionDrift *PositionDrift
+
+	// 收到按语句记录的数据修改，说明源库的 binlog_format 在运行中被改掉了
+	statementBinlog bool
+
+	// 事件校验和：源库使用的算法、校验失败计数和跳过事件的审计记录
+	checksum checksumState
+
+	// 停滞检测与自动重启
+	watchdog watchdogState
+
+	// 各表在 binlog 中的活跃度
+	activity *TableActivityTracker
 }
 
 // TableSchema 表结构信息
 type TableSchema struct {
-	Schema    string
-	Table     string
-	Columns   []ColumnInfo
-	PKColumns []int // 主键列索引
+	Schema      string
+	Table       string
+	TableID     uint64 // binlog 中的 table_id
+	Version     int    // 同一张表的结构版本，每次重建递增
+	ColumnTypes []byte // TableMapEvent 中的列类型，用于检测结构变化
+	Columns     []ColumnInfo
+	PKColumns   []int  // 主键列索引
+	Hash        string // 列名、类型和主键的哈希，见 schemaHash
 }
 
 // ColumnInfo 列信息
@@ -84,6 +173,12 @@ func NewMySQLBinlogSlaveWithMeta(config MySQLConfig, eventSink *DefaultEventSink
 	logger.Printf("🔧 Creating MySQL binlog slave for %s:%d (serverID: %d, database: %s)", config.Host, config.Port, config.ServerID, config.Database)
 
 	instanceID := fmt.Sprintf("mysql-slave-%s-%d-%d", config.Host, config.Port, config.ServerID)
+	config.Checksum = config.Checksum.withDefaults()
+
+	idGenerator, err := NewEventIDGenerator(config.EventIDFormat)
+	if err != nil {
+		return nil, err
+	}
 
 	slave := &MySQLBinlogSlave{
 		config:            config,
@@ -92,7 +187,9 @@ func NewMySQLBinlogSlaveWithMeta(config MySQLConfig, eventSink *DefaultEventSink
 		instanceID:        instanceID,
 		watchTables:       make(map[string]bool),
 		eventTypes:        make(map[EventType]bool),
-		tableSchemas:      make(map[string]*TableSchema),
+		excludeFilter:     NewTableFilter(nil),
+		tableSchemas:      make(map[uint64]*TableSchema),
+		schemaVersions:    make(map[string]int),
 		eventCounter:      make(map[EventType]int64),
 		reconnectInterval: 5 * time.Second,
 		maxReconnectCount: 10,
@@ -100,6 +197,9 @@ func NewMySQLBinlogSlaveWithMeta(config MySQLConfig, eventSink *DefaultEventSink
 		lastStatsTime:     time.Now(),
 		metaManager:       metaManager,
 		binlogPos:         mysql.Position{Name: "mysql-bin.000001", Pos: 4},
+		recoverCh:         make(chan struct{}, 1),
+		idGenerator:       idGenerator,
+		activity:          NewTableActivityTracker(),
 	}
 
 	logger.Printf("🔧 Initialized binlog position: %s:%d", "mysql-bin.000001", 4)
@@ -125,6 +225,7 @@ func NewMySQLBinlogSlaveWithMeta(config MySQLConfig, eventSink *DefaultEventSink
 // initBinlogSyncer 初始化 binlog 同步器
 func (m *MySQLBinlogSlave) initBinlogSyncer() error {
 	m.logger.Printf("🔧 Initializing binlog syncer for %s:%d with ServerID: %d", m.config.Host, m.config.Port, m.config.ServerID)
+	conn := m.config.Connection.withDefaults()
 
 	cfg := replication.BinlogSyncerConfig{
 		ServerID: m.config.ServerID,
@@ -134,23 +235,27 @@ func (m *MySQLBinlogSlave) initBinlogSyncer() error {
 		User:     m.config.Username,
 		Password: m.config.Password,
 
-		// 启用校验和验证
+		// 校验和验证，见 BinlogChecksum
 		UseDecimal:     true,
-		VerifyChecksum: true,
+		VerifyChecksum: m.config.Checksum.Verify,
 
-		// 心跳和超时配置
-		HeartbeatPeriod: 30 * time.Second,
-		ReadTimeout:     90 * time.Second,
+		// 心跳和超时配置，见 SourceConnection
+		HeartbeatPeriod: conn.HeartbeatPeriod,
+		ReadTimeout:     conn.ReadTimeout,
+		Dialer:          conn.dialer(),
 
 		// 启用 GTID 支持
 		ParseTime: true,
 
 		// 设置字符集
 		Charset: "utf8mb4",
+
+		// 先解析行事件头部，被排除的表跳过行数据解析
+		RowsEventDecodeFunc: m.decodeRowsEvent,
 	}
 
-	m.logger.Printf("🔧 Binlog syncer config: Host=%s, Port=%d, ServerID=%d, User=%s",
-		m.config.Host, m.config.Port, m.config.ServerID, m.config.Username)
+	m.logger.Printf("🔧 Binlog syncer config: Host=%s, Port=%d, ServerID=%d, User=%s, Heartbeat=%v, ReadTimeout=%v, VerifyChecksum=%v",
+		m.config.Host, m.config.Port, m.config.ServerID, m.config.Username, conn.HeartbeatPeriod, conn.ReadTimeout, m.config.Checksum.Verify)
 
 	m.syncer = replication.NewBinlogSyncer(cfg)
 	m.logger.Printf("✅ MySQL Binlog Syncer initialized with ServerID: %d", m.config.ServerID)
@@ -190,6 +295,19 @@ func (m *MySQLBinlogSlave) Start() error {
 		m.logger.Printf("🔧 Using meta manager for connection")
 	}
 
+	if m.config.CaptureSQL {
+		m.checkRowsQueryLogEvents()
+	}
+
+	// binlog 未开启或不是 ROW 格式时读不到行变更，直接拒绝启动而不是静默运行
+	if err := m.checkBinlogSettings(); err != nil {
+		m.logger.Printf("❌ %v", err)
+		m.running = false
+		m.lastError = err.Error()
+		m.lastErrorAt = time.Now()
+		return err
+	}
+
 	// 获取当前 binlog 位置
 	m.logger.Printf("🔧 Getting current binlog position...")
 	if err := m.getCurrentPosition(); err != nil {
@@ -216,6 +334,13 @@ func (m *MySQLBinlogSlave) Start() error {
 	m.wg.Add(1)
 	go m.monitor()
 
+	// 启动位置漂移检查协程
+	if m.metaManager != nil && m.config.PositionCheckInterval > 0 {
+		m.logger.Printf("🔧 Starting position drift checker (interval %v)...", m.config.PositionCheckInterval)
+		m.wg.Add(1)
+		go m.positionChecker()
+	}
+
 	// 启动统计协程
 	m.logger.Printf("🔧 Starting stats reporter goroutine...")
 	m.wg.Add(1)
@@ -251,8 +376,10 @@ func (m *MySQLBinlogSlave) Stop() error {
 		m.syncer.Close()
 	}
 
-	// 等待所有协程结束
+	// 等待所有协程结束；读取循环重连前需要获取锁（如切换账号后重建连接），等待时不能持有锁
+	m.mu.Unlock()
 	m.wg.Wait()
+	m.mu.Lock()
 
 	m.running = false
 	m.logger.Printf("✅ MySQL Binlog Slave stopped")
@@ -271,7 +398,9 @@ func (m *MySQLBinlogSlave) getCurrentPosition() error {
 				Name: pos.Name,
 				Pos:  pos.Pos,
 			}
+			m.restoreGTIDSet(pos)
 			m.logger.Printf("📍 Restored binlog position from metadata: %s:%d", m.binlogPos.Name, m.binlogPos.Pos)
+			m.restoreSinkOffsets()
 			return nil
 		} else {
 			m.logger.Printf("⚠️ Failed to load position from metadata: %v", err)
@@ -282,6 +411,7 @@ func (m *MySQLBinlogSlave) getCurrentPosition() error {
 
 	// 使用默认位置
 	m.binlogPos = mysql.Position{Name: "", Pos: 4}
+	m.restoreGTIDSet(Position{})
 	m.logger.Printf("📍 Starting from default binlog position: %s:%d", m.binlogPos.Name, m.binlogPos.Pos)
 	return nil
 }
@@ -299,7 +429,51 @@ func (m *MySQLBinlogSlave) runBinlogStream() {
 			return
 		default:
 			if err := m.processBinlogStream(); err != nil {
+				// 进入暂停：已断开连接，恢复后从断开的位置重新连接，不计为错误
+				if errors.Is(err, errStreamPaused) {
+					if err := m.waitStreamResume(); err != nil {
+						return
+					}
+					m.mu.Lock()
+					err := m.initBinlogSyncer()
+					m.mu.Unlock()
+					if err == nil {
+						continue
+					}
+				}
+
+				// 切换账号主动断开：立即用新账号重建连接
+				if m.takeCredentialsRotated() {
+					m.mu.Lock()
+					err := m.initBinlogSyncer()
+					m.mu.Unlock()
+					if err == nil {
+						continue
+					}
+				}
+
+				// 事件校验和不匹配：错误中带上损坏事件的位置，on_mismatch 为 skip 时跳过该事件
+				if IsChecksumMismatchError(err) {
+					var skipped bool
+					if skipped, err = m.handleChecksumMismatch(err); skipped {
+						continue
+					}
+				}
+
 				m.logger.Printf("❌ Binlog stream error: %v", err)
+				m.recordError(err)
+
+				// binlog 文件已被清除时重连无意义，等待人工选择恢复方式
+				if IsBinlogPurgedError(err) {
+					m.markPurged(err)
+					select {
+					case <-m.ctx.Done():
+						return
+					case <-m.recoverCh:
+						continue
+					}
+				}
+
 				m.handleReconnect("Binlog stream failed")
 
 				// 等待一段时间后重试
@@ -322,6 +496,12 @@ func (m *MySQLBinlogSlave) processBinlogStream() error {
 		return fmt.Errorf("failed to start sync: %v", err)
 	}
 	m.streamer = streamer
+	replicationConnections.Add(1)
+	defer replicationConnections.Add(-1)
+	m.rowsQuery = ""
+	m.currentGTID = ""
+	m.inTransaction = false
+	m.atResumePoint = true
 
 	m.logger.Printf("📡 Binlog stream started from position: %s:%d", m.binlogPos.Name, m.binlogPos.Pos)
 
@@ -330,10 +510,33 @@ func (m *MySQLBinlogSlave) processBinlogStream() error {
 		case <-m.ctx.Done():
 			return nil
 		default:
-			// 读取 binlog 事件
-			ev, err := streamer.GetEvent(m.ctx)
+			// 读取 binlog 事件，等待期间进入暂停时断开连接
+			ev, err := m.nextEvent(streamer)
 			if err != nil {
-				return fmt.Errorf("failed to get binlog event: %v", err)
+				if errors.Is(err, errStreamPaused) {
+					return err
+				}
+				return fmt.Errorf("failed to get binlog event: %w", err)
+			}
+
+			// 要求 CRC32 而源库的 binlog 不带校验和时停止读取
+			if e, ok := ev.Event.(*replication.FormatDescriptionEvent); ok {
+				if err := m.checkChecksumAlgorithm(e); err != nil {
+					return err
+				}
+			}
+
+			// 故障注入：丢弃刚读到的事件并断开连接，重连后从当前位置重新读取
+			if err := m.injectStreamFaults(); err != nil {
+				return err
+			}
+
+			// 限速和维护窗口，暂停期间断开连接，事件留在 binlog 中，窗口结束后从该事件重新读取
+			if err := m.waitReadLimits(ev); err != nil {
+				if errors.Is(err, errStreamPaused) {
+					return err
+				}
+				return nil
 			}
 
 			// 更新最后事件时间
@@ -346,21 +549,46 @@ func (m *MySQLBinlogSlave) processBinlogStream() error {
 
 			// 更新位置
 			m.updatePosition(ev)
+			m.atResumePoint = m.isResumePoint(ev)
 		}
 	}
 }
 
+// isResumePoint 处理完 ev 之后能否从当前位置重新连接：事务之外，且 ev 不是随后事件依赖的
+// GTID、TABLE_MAP、INTVAR、ROWS_QUERY 事件。只在 binlog 流协程中调用
+func (m *MySQLBinlogSlave) isResumePoint(ev *replication.BinlogEvent) bool {
+	if m.inTransaction || m.currentGTID != "" {
+		return false
+	}
+	switch ev.Header.EventType {
+	case replication.GTID_EVENT, replication.ANONYMOUS_GTID_EVENT, replication.MARIADB_GTID_EVENT,
+		replication.TABLE_MAP_EVENT, replication.INTVAR_EVENT, replication.ROWS_QUERY_EVENT:
+		return false
+	}
+	return true
+}
+
 // handleBinlogEvent 处理 binlog 事件
 func (m *MySQLBinlogSlave) handleBinlogEvent(ev *replication.BinlogEvent) error {
 	switch e := ev.Event.(type) {
 	case *replication.RowsEvent:
 		return m.handleRowsEvent(ev.Header, e)
+	case *replication.RowsQueryEvent:
+		return m.handleRowsQueryEvent(ev.Header, e)
 	case *replication.QueryEvent:
 		return m.handleQueryEvent(ev.Header, e)
 	case *replication.XIDEvent:
 		return m.handleXIDEvent(ev.Header, e)
 	case *replication.GTIDEvent:
 		return m.handleGTIDEvent(ev.Header, e)
+	case *replication.MariadbGTIDEvent:
+		return m.handleMariadbGTIDEvent(ev.Header, e)
+	case *replication.PreviousGTIDsEvent:
+		return m.handlePreviousGTIDsEvent(ev.Header, e)
+	case *replication.MariadbGTIDListEvent:
+		return m.handleMariadbGTIDListEvent(ev.Header, e)
+	case *replication.IntVarEvent:
+		return m.handleIntVarEvent(ev.Header, e)
 	case *replication.RotateEvent:
 		return m.handleRotateEvent(ev.Header, e)
 	case *replication.TableMapEvent:
@@ -371,16 +599,35 @@ func (m *MySQLBinlogSlave) handleBinlogEvent(ev *replication.BinlogEvent) error
 	}
 }
 
+// decodeRowsEvent 解析行事件，排除的表只解析头部
+func (m *MySQLBinlogSlave) decodeRowsEvent(e *replication.RowsEvent, data []byte) error {
+	pos, err := e.DecodeHeader(data)
+	if err != nil {
+		return err
+	}
+
+	if e.Table != nil && m.excludeFilter.Excluded(string(e.Table.Schema), string(e.Table.Table)) {
+		return nil
+	}
+
+	return e.DecodeData(pos, data)
+}
+
 // handleRowsEvent 处理行变更事件
 func (m *MySQLBinlogSlave) handleRowsEvent(header *replication.EventHeader, e *replication.RowsEvent) error {
-	m.logger.Printf("📥 Processing rows event: %s", header.EventType.String())
-
 	// 获取表信息
 	schemaName := string(e.Table.Schema)
 	tableName := string(e.Table.Table)
 	tableKey := fmt.Sprintf("%s.%s", schemaName, tableName)
 
-	m.logger.Printf("📋 Table info: schema=%s, table=%s, tableKey=%s", schemaName, tableName, tableKey)
+	// 排除的表直接跳过
+	if m.excludeFilter.Excluded(schemaName, tableName) {
+		return nil
+	}
+
+	m.logSampler.Printf(m.logger, LogPathRead, "📥 Processing rows event: %s", header.EventType.String())
+
+	m.logSampler.Printf(m.logger, LogPathRead, "📋 Table info: schema=%s, table=%s, tableKey=%s", schemaName, tableName, tableKey)
 
 	// 检查是否需要监听此表
 	m.mu.RLock()
@@ -414,74 +661,45 @@ func (m *MySQLBinlogSlave) handleRowsEvent(header *replication.EventHeader, e *r
 	}
 
 	// 获取表结构
-	m.logger.Printf("🔍 Getting table schema for %s.%s", schemaName, tableName)
+	m.logSampler.Printf(m.logger, LogPathRead, "🔍 Getting table schema for %s.%s", schemaName, tableName)
 	tableSchema := m.getTableSchema(schemaName, tableName, e.Table)
-	m.logger.Printf("✅ Got table schema with %d columns", len(tableSchema.Columns))
+	m.logSampler.Printf(m.logger, LogPathRead, "✅ Got table schema with %d columns", len(tableSchema.Columns))
 
 	// 处理每一行数据
-	m.logger.Printf("🔄 Processing %d rows", len(e.Rows))
+	m.logSampler.Printf(m.logger, LogPathRead, "🔄 Processing %d rows", len(e.Rows))
 	for i, row := range e.Rows {
-		m.logger.Printf("📝 Processing row %d/%d", i+1, len(e.Rows))
+		// UPDATE 的行成对出现，后镜像随前镜像生成同一个事件
+		if eventType == EventTypeUpdate && i%2 == 1 {
+			continue
+		}
+		m.logSampler.Printf(m.logger, LogPathRead, "📝 Processing row %d/%d", i+1, len(e.Rows))
 		event := m.createCanalEvent(header, tableSchema, eventType, row, i, e.Rows)
-		m.logger.Printf("🔧 Created canal event: %s.%s %s", event.Schema, event.Table, event.EventType)
+		m.logSampler.Printf(m.logger, LogPathRead, "🔧 Created canal event: %s.%s %s", event.Schema, event.Table, event.EventType)
 
 		if err := m.eventSink.SendEvent(event); err != nil {
 			m.logger.Printf("❌ Failed to send event: %v", err)
 			return fmt.Errorf("failed to send event: %v", err)
 		}
-		m.logger.Printf("✅ Event sent to sink successfully")
+		m.logSampler.Printf(m.logger, LogPathRead, "✅ Event sent to sink successfully")
 
 		// 更新统计
 		m.mu.Lock()
 		m.eventCounter[eventType]++
 		m.mu.Unlock()
 
-		m.logger.Printf("🔥 MYSQL BINLOG EVENT PROCESSED:")
-		m.logger.Printf("   📋 Table: %s.%s", event.Schema, event.Table)
-		m.logger.Printf("   🎯 Event Type: %s", event.EventType)
-		m.logger.Printf("   📍 Position: %s:%d", event.Position.Name, event.Position.Pos)
-		m.logger.Printf("   🆔 Event ID: %s", event.ID)
-		m.logger.Printf("   📊 Data: %v", m.formatEventData(event))
-	}
-	m.logger.Printf("✅ Finished processing %d rows", len(e.Rows))
-
-	return nil
-}
-
-// getTableSchema 获取表结构
-func (m *MySQLBinlogSlave) getTableSchema(schema, table string, tableInfo *replication.TableMapEvent) *TableSchema {
-	tableKey := fmt.Sprintf("%s.%s", schema, table)
-
-	m.mu.RLock()
-	if ts, exists := m.tableSchemas[tableKey]; exists {
-		m.mu.RUnlock()
-		return ts
-	}
-	m.mu.RUnlock()
-
-	// 创建基本的表结构信息
-	ts := &TableSchema{
-		Schema:  schema,
-		Table:   table,
-		Columns: make([]ColumnInfo, len(tableInfo.ColumnType)),
-	}
-
-	// 填充列信息（这里简化处理，实际应该查询 information_schema）
-	for i, colType := range tableInfo.ColumnType {
-		ts.Columns[i] = ColumnInfo{
-			Name:     fmt.Sprintf("col_%d", i), // 实际应该查询真实列名
-			Type:     m.getColumnTypeName(colType),
-			Nullable: true,  // 实际应该查询真实的 nullable 信息
-			IsPK:     false, // 实际应该查询主键信息
+		// 多行的事件摘要作为一条日志采样，不会只输出其中几行
+		if m.logSampler.Allow(LogPathRead) {
+			m.logger.Printf("🔥 MYSQL BINLOG EVENT PROCESSED:")
+			m.logger.Printf("   📋 Table: %s.%s", event.Schema, event.Table)
+			m.logger.Printf("   🎯 Event Type: %s", event.EventType)
+			m.logger.Printf("   📍 Position: %s:%d", event.Position.Name, event.Position.Pos)
+			m.logger.Printf("   🆔 Event ID: %s", event.ID)
+			m.logger.Printf("   📊 Data: %v", m.formatEventData(event))
 		}
 	}
+	m.logSampler.Printf(m.logger, LogPathRead, "✅ Finished processing %d rows", len(e.Rows))
 
-	// 缓存表结构
-	m.mu.Lock()
-	m.tableSchemas[tableKey] = ts
-	m.mu.Unlock()
-
-	return ts
+	return nil
 }
 
 // getColumnTypeName 获取列类型名称
@@ -519,7 +737,10 @@ func (m *MySQLBinlogSlave) getColumnTypeName(colType byte) string {
 // createCanalEvent 创建 Canal 事件
 func (m *MySQLBinlogSlave) createCanalEvent(header *replication.EventHeader, tableSchema *TableSchema, eventType EventType, row []interface{}, rowIndex int, allRows [][]interface{}) *Event {
 	event := &Event{
-		ID:        fmt.Sprintf("mysql-binlog-%d-%d-%d", header.LogPos, header.Timestamp, rowIndex),
+		ID: m.idGenerator.NextID(EventKey{
+			Position: Position{Name: m.binlogPos.Name, Pos: header.LogPos},
+			Row:      rowIndex,
+		}),
 		Schema:    tableSchema.Schema,
 		Table:     tableSchema.Table,
 		EventType: eventType,
@@ -528,9 +749,21 @@ func (m *MySQLBinlogSlave) createCanalEvent(header *replication.EventHeader, tab
 			Name: m.binlogPos.Name,
 			Pos:  header.LogPos,
 		},
+		SQL:         m.rowsQuery,
+		SchemaHash:  tableSchema.Hash,
+		CommitIndex: m.commits + 1,
+	}
+	if !m.commitTime.IsZero() {
+		event.CommitMicros = m.commitTime.UnixMicro()
+	}
+	for _, idx := range tableSchema.PKColumns {
+		if idx < len(tableSchema.Columns) {
+			event.PrimaryKey = append(event.PrimaryKey, tableSchema.Columns[idx].Name)
+		}
 	}
 
-	// 设置 GTID
+	// 设置 GTID：所属事务的 GTID 和此前已执行的集合，从该集合恢复会重新读取当前事务
+	event.Position.GTID = m.currentGTID
 	if m.gtidSet != nil {
 		event.Position.GTIDSet = m.gtidSet.String()
 	}
@@ -614,18 +847,48 @@ func (m *MySQLBinlogSlave) formatEventData(event *Event) map[string]interface{}
 // handleQueryEvent 处理查询事件
 func (m *MySQLBinlogSlave) handleQueryEvent(header *replication.EventHeader, e *replication.QueryEvent) error {
 	m.logger.Printf("📝 DDL Query: %s", string(e.Query))
+	m.rowsQuery = ""
+	dml := isStatementDML(string(e.Query))
+	if dml {
+		m.reportStatementBinlog(string(e.Schema))
+	}
+	// BEGIN 是事务的开始，非事务表的事务以 COMMIT 语句结束；事务中按语句记录的数据修改和
+	// SAVEPOINT 不是提交点，其他语句（DDL）隐式提交，自成一个事务，没有 XID 事件
+	switch strings.ToUpper(strings.TrimSpace(string(e.Query))) {
+	case "BEGIN":
+		m.inTransaction = true
+	case "COMMIT", "ROLLBACK":
+		m.commitGTID()
+	default:
+		if !m.inTransaction || !(dml || isSavepointStatement(string(e.Query))) {
+			m.commitGTID()
+		}
+	}
 	return nil
 }
 
-// handleXIDEvent 处理事务提交事件
-func (m *MySQLBinlogSlave) handleXIDEvent(header *replication.EventHeader, e *replication.XIDEvent) error {
-	m.logger.Printf("💾 Transaction committed")
+// isSavepointStatement 判断 QUERY 事件是否为事务中的 SAVEPOINT 或 ROLLBACK TO
+func isSavepointStatement(query string) bool {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	return strings.HasPrefix(query, "SAVEPOINT ") || strings.HasPrefix(query, "ROLLBACK TO ")
+}
+
+// handleIntVarEvent 处理 INTVAR 事件：按语句记录时随后语句使用的 LAST_INSERT_ID 或自增值。
+// 行格式下不会出现，不影响事务和 GTID 的统计；之后的位置不保存，见 updatePosition
+func (m *MySQLBinlogSlave) handleIntVarEvent(header *replication.EventHeader, e *replication.IntVarEvent) error {
+	name := "INSERT_ID"
+	if e.Type == replication.LAST_INSERT_ID {
+		name = "LAST_INSERT_ID"
+	}
+	m.logSampler.Printf(m.logger, LogPathRead, "🔢 Statement context %s=%d", name, e.Value)
 	return nil
 }
 
-// handleGTIDEvent 处理 GTID 事件
-func (m *MySQLBinlogSlave) handleGTIDEvent(header *replication.EventHeader, e *replication.GTIDEvent) error {
-	m.logger.Printf("🔗 GTID Event received")
+// handleXIDEvent 处理事务提交事件
+func (m *MySQLBinlogSlave) handleXIDEvent(header *replication.EventHeader, e *replication.XIDEvent) error {
+	m.logSampler.Printf(m.logger, LogPathRead, "💾 Transaction committed")
+	m.rowsQuery = ""
+	m.commitGTID()
 	return nil
 }
 
@@ -638,10 +901,22 @@ func (m *MySQLBinlogSlave) handleRotateEvent(header *replication.EventHeader, e
 // handleTableMapEvent 处理表映射事件
 func (m *MySQLBinlogSlave) handleTableMapEvent(header *replication.EventHeader, e *replication.TableMapEvent) error {
 	tableKey := fmt.Sprintf("%s.%s", string(e.Schema), string(e.Table))
-	m.logger.Printf("🗺️ Table map event: %s", tableKey)
+	m.logSampler.Printf(m.logger, LogPathRead, "🗺️ Table map event: %s", tableKey)
+
+	// 按事件在源库的时间统计活跃度，追赶旧 binlog 时不会误判为近期活跃
+	at := time.Now()
+	if header.Timestamp > 0 {
+		at = time.Unix(int64(header.Timestamp), 0)
+	}
+	m.activity.Record(string(e.Schema), string(e.Table), at)
 	return nil
 }
 
+// TableActivity 各表在 binlog 中的活跃度，包括未监听的表
+func (m *MySQLBinlogSlave) TableActivity() []TableActivity {
+	return m.activity.Snapshot(time.Now())
+}
+
 // updatePosition 更新 binlog 位置
 func (m *MySQLBinlogSlave) updatePosition(ev *replication.BinlogEvent) {
 	m.mu.Lock()
@@ -650,6 +925,22 @@ func (m *MySQLBinlogSlave) updatePosition(ev *replication.BinlogEvent) {
 	oldPos := m.binlogPos
 	m.binlogPos.Pos = ev.Header.LogPos
 
+	// 能继续收到事件说明已恢复；伪造的 ROTATE 等事件位置为 0，重连后收到它们不代表已越过损坏的事件
+	m.lastError = ""
+	if ev.Header.LogPos > 0 {
+		m.checksum.failing = false
+	}
+
+	// 心跳事件表示已追上主库；伪造的 ROTATE 等事件时间戳为 0，不参与计算
+	if ev.Header.EventType == replication.HEARTBEAT_EVENT {
+		m.lag = 0
+	} else if ev.Header.Timestamp > 0 {
+		m.lag = time.Since(time.Unix(int64(ev.Header.Timestamp), 0))
+		if m.lag < 0 {
+			m.lag = 0
+		}
+	}
+
 	if ev.Header.EventType == replication.ROTATE_EVENT {
 		if rotateEvent, ok := ev.Event.(*replication.RotateEvent); ok {
 			m.binlogPos.Name = string(rotateEvent.NextLogName)
@@ -657,23 +948,42 @@ func (m *MySQLBinlogSlave) updatePosition(ev *replication.BinlogEvent) {
 		}
 	}
 
-	// 如果位置发生变化且有元数据管理器，保存位置
-	if m.metaManager != nil && (oldPos.Name != m.binlogPos.Name || oldPos.Pos != m.binlogPos.Pos) {
-		pos := Position{
-			Name: m.binlogPos.Name,
-			Pos:  m.binlogPos.Pos,
-		}
-		if m.gtidSet != nil {
-			pos.GTIDSet = m.gtidSet.String()
+	// 事务提交后和新文件开头是事务边界
+	if ev.Header.EventType == replication.ROTATE_EVENT || m.commits != m.boundaryCommit {
+		m.boundaryCommit = m.commits
+		m.noteBoundaryLocked(m.readPositionLocked())
+	}
+
+	// 如果位置发生变化且有元数据管理器，保存已投递完成的位置。
+	// TABLE_MAP 之后不保存，从这里继续读取时后面的行事件找不到表映射；
+	// INTVAR 之后同样不保存，它是随后语句的一部分
+	if m.metaManager != nil && (oldPos.Name != m.binlogPos.Name || oldPos.Pos != m.binlogPos.Pos) &&
+		ev.Header.EventType != replication.TABLE_MAP_EVENT && ev.Header.EventType != replication.INTVAR_EVENT {
+		if pos := m.committedPositionLocked(); pos.Name != "" && pos != m.savedPos {
+			// 异步保存位置，避免阻塞事件处理；乱序覆盖由位置漂移检查发现并修正
+			m.savedPos = pos
+			m.savePositionAsync(pos, m.eventSink.SinkOffsets(m.readPositionLocked()))
 		}
+	}
+}
 
-		// 异步保存位置，避免阻塞事件处理
-		go func() {
-			if err := m.metaManager.SavePosition(m.instanceID, pos); err != nil {
-				m.logger.Printf("❌ Failed to save binlog position: %v", err)
-			}
-		}()
+// readPositionLocked 当前读取位置及已执行的 GTID 集合，调用方需持有锁
+func (m *MySQLBinlogSlave) readPositionLocked() Position {
+	read := Position{Name: m.binlogPos.Name, Pos: m.binlogPos.Pos}
+	if m.gtidSet != nil {
+		read.GTIDSet = m.gtidSet.String()
+	}
+	return read
+}
+
+// committedPositionLocked 可以安全保存的位置，事件都已投递完成时为读取位置；
+// checkpoint 为 transaction 时取不超过它的最近一个事务边界。调用方需持有写锁
+func (m *MySQLBinlogSlave) committedPositionLocked() Position {
+	committed := m.eventSink.CommittedPosition(m.readPositionLocked())
+	if m.config.Checkpoint == CheckpointTransaction && committed.Name != "" {
+		committed = m.boundaryBeforeLocked(committed)
 	}
+	return committed
 }
 
 // monitor 监控协程
@@ -694,6 +1004,7 @@ func (m *MySQLBinlogSlave) monitor() {
 			m.logger.Printf("📊 Running periodic status check")
 			m.logStatus()
 			m.checkHealth()
+			m.checkStall()
 			m.logger.Printf("✅ Periodic status check completed")
 		}
 	}
@@ -766,6 +1077,8 @@ func (m *MySQLBinlogSlave) handleReconnect(reason string) {
 	m.reconnectCount++
 	if m.reconnectCount > m.maxReconnectCount {
 		m.logger.Printf("❌ Max reconnect attempts reached, stopping slave")
+		m.lastError = fmt.Sprintf("max reconnect attempts (%d) reached: %s", m.maxReconnectCount, reason)
+		m.lastErrorAt = time.Now()
 		return
 	}
 
@@ -783,6 +1096,14 @@ func (m *MySQLBinlogSlave) handleReconnect(reason string) {
 	}
 }
 
+// recordError 记录最近一次错误
+func (m *MySQLBinlogSlave) recordError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastError = err.Error()
+	m.lastErrorAt = time.Now()
+}
+
 // AddWatchTable 添加监听表
 func (m *MySQLBinlogSlave) AddWatchTable(schema, table string) {
 	m.mu.Lock()
@@ -803,6 +1124,12 @@ func (m *MySQLBinlogSlave) RemoveWatchTable(schema, table string) {
 	m.logger.Printf("📋 Removed watch table: %s", key)
 }
 
+// SetExcludeTables 设置排除的表规则
+func (m *MySQLBinlogSlave) SetExcludeTables(patterns []string) {
+	m.excludeFilter.SetPatterns(patterns)
+	m.logger.Printf("🚫 Set exclude tables: %v", patterns)
+}
+
 // SetEventTypes 设置监听的事件类型
 func (m *MySQLBinlogSlave) SetEventTypes(eventTypes []EventType) {
 	m.mu.Lock()
@@ -823,19 +1150,13 @@ func (m *MySQLBinlogSlave) SetEventTypes(eventTypes []EventType) {
 func (m *MySQLBinlogSlave) testConnection() error {
 	m.logger.Printf("🔧 Testing MySQL connection to %s:%d with user %s", m.config.Host, m.config.Port, m.config.Username)
 
-	// 在测试环境中，如果设置了 TEST_MYSQL_CONNECTION_FAIL 环境变量，则模拟连接失败
-	if os.Getenv("TEST_MYSQL_CONNECTION_FAIL") == "true" {
-		m.logger.Printf("❌ Simulated connection failure for testing")
-		return fmt.Errorf("simulated connection failure for testing")
+	if fault, ok := Faults.Trigger(FaultConnectionFail, m.instanceID); ok {
+		m.logger.Printf("💥 Injected connection failure for %s", m.instanceID)
+		return fault.Err()
 	}
 
 	// 创建一个简单的连接来测试 MySQL 服务器是否可达
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/?charset=utf8mb4",
-		m.config.Username,
-		m.config.Password,
-		m.config.Host,
-		m.config.Port,
-	)
+	dsn := SourceDSN(m.config.Username, m.config.Password, m.config.Host, m.config.Port, "charset=utf8mb4")
 
 	m.logger.Printf("🔧 DSN for connection test: %s:***@tcp(%s:%d)/?charset=utf8mb4",
 		m.config.Username, m.config.Host, m.config.Port)
@@ -883,12 +1204,64 @@ func (m *MySQLBinlogSlave) GetStats() map[string]interface{} {
 	defer m.mu.RUnlock()
 
 	stats := map[string]interface{}{
+		"instance_id":     m.instanceID,
 		"running":         m.running,
 		"position":        m.binlogPos,
 		"last_event_time": m.lastEventTime,
 		"reconnect_count": m.reconnectCount,
 		"watched_tables":  len(m.watchTables),
+		"exclude_tables":  m.excludeFilter.Patterns(),
 		"event_counter":   m.eventCounter,
+		"binlog_purged":   m.purged,
+		"last_error":      m.lastError,
+		"last_error_at":   m.lastErrorAt,
+		"lag_seconds":     m.lag.Seconds(),
+		"table_schemas":   len(m.tableSchemas),
+		"paused":          m.paused,
+		"memory_paused":   m.memoryPaused,
+		// 人工维护暂停
+		"maintenance_paused": m.maintenancePaused,
+	}
+	if m.gtidSet != nil {
+		stats["gtid_set"] = m.gtidSet.String()
+	}
+	if m.config.Checkpoint == CheckpointTransaction {
+		stats["checkpoint"] = CheckpointTransaction
+		stats["pending_boundaries"] = len(m.boundaries)
+	}
+	if m.memoryLimits.Enabled() {
+		stats["backpressure_count"] = atomic.LoadInt64(&m.backpressureCount)
+		stats["memory_wait_seconds"] = time.Duration(atomic.LoadInt64(&m.memoryWaitNanos)).Seconds()
+	}
+	if m.readThrottle != nil {
+		stats["throttle_wait_seconds"] = m.readThrottle.WaitedSeconds()
+	}
+
+	// 每张表当前的结构版本
+	versions := make(map[string]int, len(m.schemaVersions))
+	for key, version := range m.schemaVersions {
+		versions[key] = version
+	}
+	stats["schema_versions"] = versions
+
+	if m.positionDrift != nil {
+		stats["position_drift"] = m.positionDrift
+	}
+	stats["statement_binlog"] = m.statementBinlog
+	stats["checksum_failing"] = m.checksum.failing
+	stats["checksum_mismatches"] = m.checksum.mismatches
+	stats["checksum_skipped"] = m.checksum.skippedTotal
+	if m.watchdog.policy.Enabled() {
+		stats["watchdog"] = m.watchdogStatsLocked()
+	}
+
+	if m.purged {
+		stats["purge_error"] = m.purgeError
+		stats["purged_at"] = m.purgedAt
+	}
+	if m.recovery != nil {
+		recovery := *m.recovery
+		stats["recovery"] = &recovery
 	}
 
 	return stats
@@ -2,13 +2,17 @@ package canal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"runtime/pprof"
+	"strings"
 	"sync"
 	"time"
 
 	"pikachun/internal/config"
 	"pikachun/internal/database"
+	"pikachun/internal/objectstore"
 )
 
 // MySQLCanalInstance 基于真实 MySQL binlog 的 Canal 实例实现
@@ -23,6 +27,38 @@ type MySQLCanalInstance struct {
 	ctx         context.Context
 	cancel      context.CancelFunc
 	status      InstanceStatus
+
+	// 全局排除规则（来自全局监听策略，见 SetWatchPolicy）
+	globalExcludes []string
+
+	// 全局事件类型（来自全局监听策略），任务的事件类型在订阅处过滤，见 SetEventTypes
+	globalTypes []EventType
+
+	// 任务自身的排除规则和事件类型，全局监听策略更新时与之重新合并
+	taskExcludes []string
+	taskTypes    []EventType
+
+	// 全局监听策略额外监听的表（schema.table）
+	policyTables []string
+
+	// 模拟事件的ID生成器和序号，见 Simulate
+	simulateIDs EventIDGenerator
+	simulateSeq int64
+
+	// 任务自身的采样配置，延迟保护切换为采样后据此恢复
+	samplePercent  float64
+	sampleInterval int
+
+	// 延迟保护，见 CheckLag
+	lagGuard    *LagGuard
+	lagBreach   *LagBreach
+	lagSampling bool // 正在按延迟保护的比例采样
+
+	// 数据质量规则中引用存在断言的查询，更新任务时设置到新的规则上
+	qualityLookup QualityLookup
+
+	// 从本地 binlog 文件回填，见 Backfill
+	backfill instanceBackfill
 }
 
 // NewMySQLCanalInstance 创建基于真实 MySQL binlog 的 Canal 实例
@@ -31,6 +67,14 @@ func NewMySQLCanalInstance(id string, cfg *config.Config, logger *log.Logger, me
 
 	// 转换配置
 	logger.Printf("🔧 Converting configuration...")
+	connection, err := NewSourceConnection(cfg.Canal.Connection)
+	if err != nil {
+		return nil, fmt.Errorf("invalid canal.connection config: %v", err)
+	}
+	checksum, err := NewBinlogChecksum(cfg.Canal.Binlog.Checksum)
+	if err != nil {
+		return nil, fmt.Errorf("invalid canal.binlog.checksum config: %v", err)
+	}
 	mysqlConfig := MySQLConfig{
 		Host:       cfg.Canal.Host,
 		Port:       cfg.Canal.Port,
@@ -40,6 +84,19 @@ func NewMySQLCanalInstance(id string, cfg *config.Config, logger *log.Logger, me
 		ServerID:   cfg.Canal.ServerID,
 		BinlogFile: cfg.Canal.Binlog.Filename,
 		BinlogPos:  cfg.Canal.Binlog.Position,
+
+		EventIDFormat: cfg.Canal.EventIDFormat,
+
+		PositionCheckInterval: parsePositionCheckInterval(cfg.Canal.PositionCheckInterval, logger),
+		Checkpoint:            parseCheckpointMode(cfg.Canal.Checkpoint, logger),
+		Connection:            connection,
+		MinBinlogRetention:    ParseMinBinlogRetention(cfg.Canal.MinBinlogRetention),
+		Checksum:              checksum,
+
+		CaptureSQL:   cfg.Canal.RowsQuery.Enabled,
+		MaxSQLLength: cfg.Canal.RowsQuery.MaxLength,
+
+		NumericStrings: cfg.Canal.NumericStrings,
 	}
 
 	logger.Printf("🔧 MySQL Config: Host=%s, Port=%d, Username=%s, ServerID=%d",
@@ -48,6 +105,14 @@ func NewMySQLCanalInstance(id string, cfg *config.Config, logger *log.Logger, me
 	// 创建事件接收器
 	logger.Printf("🔧 Creating event sink...")
 	eventSink := NewDefaultEventSink(logger)
+	eventSink.SetBufferPolicy(NewEventBufferPolicy(cfg.Canal.EventBuffer))
+	eventSink.SetSinkPolicy(NewSinkPolicy(cfg.Canal.Sinks))
+
+	valuePolicy, err := newLargeValuePolicyFromConfig(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	eventSink.SetLargeValuePolicy(valuePolicy)
 
 	// 尝试创建真实的 MySQL binlog slave
 	logger.Printf("🔧 Creating MySQL binlog slave...")
@@ -57,11 +122,15 @@ func NewMySQLCanalInstance(id string, cfg *config.Config, logger *log.Logger, me
 		logger.Printf("❌ Failed to create real MySQL binlog slave: %v", err)
 		return nil, fmt.Errorf("failed to create real MySQL binlog slave: %v", err)
 	}
+	realSlave.SetMemoryLimits(NewMemoryLimits(cfg.Canal.Memory), eventSink.MemoryUsage)
+	realSlave.SetWatchdog(NewWatchdogPolicy(cfg.Canal.Watchdog))
 	binlogSlave = realSlave
 
-	// 配置监听的表和事件类型
-	logger.Printf("🔧 Configuring binlog slave from config...")
-	configureBinlogSlaveFromConfig(binlogSlave, cfg)
+	// 格式已在创建 binlog slave 时校验
+	simulateIDs, err := NewEventIDGenerator(mysqlConfig.EventIDFormat)
+	if err != nil {
+		return nil, err
+	}
 
 	instance := &MySQLCanalInstance{
 		id:          id,
@@ -69,6 +138,7 @@ func NewMySQLCanalInstance(id string, cfg *config.Config, logger *log.Logger, me
 		eventSink:   eventSink,
 		binlogSlave: binlogSlave,
 		logger:      logger,
+		simulateIDs: simulateIDs,
 		status: InstanceStatus{
 			Running:   false,
 			Position:  Position{},
@@ -76,17 +146,48 @@ func NewMySQLCanalInstance(id string, cfg *config.Config, logger *log.Logger, me
 		},
 	}
 
+	// 配置文件的 canal.watch 作为初始监听策略，服务会用持久化的全局监听策略覆盖
+	instance.SetWatchPolicy(WatchPolicyFromConfig(cfg.Canal.Watch))
+
 	logger.Printf("✅ MySQL Canal Instance created successfully (ID: %s)", id)
 
 	return instance, nil
 }
 
-// configureBinlogSlaveFromConfig 配置 binlog slave
-func configureBinlogSlaveFromConfig(slave BinlogSlave, cfg *config.Config) {
-	// 设置监听的事件类型
+// newLargeValuePolicyFromConfig 根据配置创建大字段处理策略，仅 externalize 策略需要对象存储
+func newLargeValuePolicyFromConfig(cfg *config.Config, logger *log.Logger) (*LargeValuePolicy, error) {
+	var store objectstore.Store
+	if cfg.Canal.LargeValues.Policy == LargeValueExternalize {
+		var err error
+		if store, err = objectstore.New(cfg.ObjectStore); err != nil {
+			return nil, fmt.Errorf("failed to create object store: %v", err)
+		}
+	}
+	policy, err := NewLargeValuePolicy(cfg.Canal.LargeValues, store, logger)
+	if err != nil {
+		return nil, fmt.Errorf("invalid large value policy: %v", err)
+	}
+	return policy, nil
+}
+
+// parsePositionCheckInterval 解析位置漂移检查间隔，未配置或无效时默认 1 分钟，"0" 表示不检查
+func parsePositionCheckInterval(value string, logger *log.Logger) time.Duration {
+	if value == "" {
+		return time.Minute
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 {
+		logger.Printf("⚠️ Invalid position_check_interval %q, using 1m", value)
+		return time.Minute
+	}
+	return interval
+}
+
+// parseEventTypes 解析事件类型列表，忽略不支持的类型
+func parseEventTypes(types []string) []EventType {
 	var eventTypes []EventType
-	for _, et := range cfg.Canal.Watch.EventTypes {
-		switch et {
+	for _, et := range types {
+		switch strings.ToUpper(strings.TrimSpace(et)) {
 		case "INSERT":
 			eventTypes = append(eventTypes, EventTypeInsert)
 		case "UPDATE":
@@ -95,19 +196,7 @@ func configureBinlogSlaveFromConfig(slave BinlogSlave, cfg *config.Config) {
 			eventTypes = append(eventTypes, EventTypeDelete)
 		}
 	}
-	slave.SetEventTypes(eventTypes)
-
-	// 添加监听的表
-	for _, db := range cfg.Canal.Watch.Databases {
-		for _, table := range cfg.Canal.Watch.Tables {
-			slave.AddWatchTable(db, table)
-		}
-	}
-
-	// 如果没有指定具体的表，则监听所有表
-	if len(cfg.Canal.Watch.Databases) == 0 && len(cfg.Canal.Watch.Tables) == 0 {
-		// 不添加任何监听表，表示监听所有表
-	}
+	return eventTypes
 }
 
 // Start 启动 MySQL Canal 实例
@@ -123,6 +212,10 @@ func (c *MySQLCanalInstance) Start(ctx context.Context) error {
 
 	c.ctx, c.cancel = context.WithCancel(ctx)
 
+	// 接收器和 binlog slave 启动的协程继承实例标签，返回时恢复调用方协程的标签
+	pprof.SetGoroutineLabels(pprof.WithLabels(c.ctx, pprof.Labels(InstanceLabelKey, c.id)))
+	defer pprof.SetGoroutineLabels(ctx)
+
 	c.logger.Printf("🚀 Starting MySQL Canal Instance: %s", c.id)
 	c.logger.Printf("📡 MySQL Config: %s:%d", c.config.Host, c.config.Port)
 	c.logger.Printf("🆔 Server ID: %d", c.config.ServerID)
@@ -132,6 +225,7 @@ func (c *MySQLCanalInstance) Start(ctx context.Context) error {
 	c.logger.Printf("🔧 Starting event sink...")
 	if err := c.eventSink.Start(c.ctx); err != nil {
 		c.logger.Printf("❌ Failed to start event sink: %v", err)
+		c.setError(fmt.Sprintf("failed to start event sink: %v", err))
 		return fmt.Errorf("failed to start event sink: %v", err)
 	}
 	c.logger.Printf("✅ Event sink started successfully")
@@ -142,6 +236,11 @@ func (c *MySQLCanalInstance) Start(ctx context.Context) error {
 		c.logger.Printf("❌ Failed to start mysql binlog slave: %v", err)
 		c.logger.Printf("🔧 Stopping event sink due to binlog slave start failure...")
 		c.eventSink.Stop()
+		c.setError(fmt.Sprintf("failed to start mysql binlog slave: %v", err))
+		var settingsErr *BinlogSettingsError
+		if errors.As(err, &settingsErr) {
+			c.status.Alert = AlertBinlogSettings
+		}
 		return fmt.Errorf("failed to start mysql binlog slave: %v", err)
 	}
 	c.logger.Printf("✅ MySQL binlog slave started successfully")
@@ -150,15 +249,25 @@ func (c *MySQLCanalInstance) Start(ctx context.Context) error {
 	c.status.Running = true
 	c.status.Position = c.binlogSlave.GetBinlogPosition()
 	c.status.LastEvent = time.Now()
+	c.status.ErrorMsg = ""
 
 	c.logger.Printf("✅ MySQL Canal Instance %s started successfully", c.id)
 	c.logger.Printf("📊 Initial position: %s:%d", c.status.Position.Name, c.status.Position.Pos)
 	return nil
 }
 
+// setError 记录实例错误，调用方需持有锁
+func (c *MySQLCanalInstance) setError(msg string) {
+	c.status.ErrorMsg = msg
+	c.status.ErrorAt = time.Now()
+}
+
 // Stop 停止 MySQL Canal 实例
 func (c *MySQLCanalInstance) Stop() error {
 	c.logger.Printf("🛑 Stopping MySQL Canal Instance: %s", c.id)
+	// 回填的事件同样送入事件接收器，先于事件接收器停止
+	c.stopBackfill()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -181,6 +290,15 @@ func (c *MySQLCanalInstance) Stop() error {
 		c.logger.Printf("❌ Error stopping event sink: %v", err)
 	}
 
+	// 处理器关闭时已投递完缓冲的事件，保存投递完成的位置，重启后从这里继续
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		if pos, err := slave.savePositionSync(); err != nil {
+			c.logger.Printf("❌ Failed to save binlog position on stop: %v", err)
+		} else if pos.Name != "" {
+			c.logger.Printf("💾 Saved binlog position %s:%d on stop", pos.Name, pos.Pos)
+		}
+	}
+
 	if c.cancel != nil {
 		c.logger.Printf("🔧 Cancelling context...")
 		c.cancel()
@@ -193,16 +311,448 @@ func (c *MySQLCanalInstance) Stop() error {
 	return nil
 }
 
-// StopInstance 停止指定实例
+// StopInstance 停止任务的实例：先停止 binlog 读取，处理器投递完缓冲的事件后保存位置，
+// 再从事件接收器摘除任务的处理器。先摘除处理器会让停止前读到的事件没有处理器接收，位置越过未投递的事件
 func (c *MySQLCanalInstance) StopInstance(instanceID uint) error {
+	if c.id != fmt.Sprintf("task-%d", instanceID) {
+		return fmt.Errorf("instance %s does not serve task %d", c.id, instanceID)
+	}
+
+	if err := c.Stop(); err != nil {
+		return err
+	}
+
+	for _, name := range TaskHandlerNames(instanceID) {
+		if removed := c.eventSink.RemoveHandler(name); removed > 0 {
+			c.logger.Printf("🔌 Unsubscribed handler %s from %d table(s) of instance %s", name, removed, c.id)
+		}
+	}
 	return nil
 }
 
-// UpdateTask 更新任务
+// TaskHandlerNames 任务可能订阅的处理器名称
+func TaskHandlerNames(taskID uint) []string {
+	names := make([]string, 0, 5)
+	for _, prefix := range []string{"webhook", "db", "clickhouse", "archive", "heartbeat"} {
+		names = append(names, fmt.Sprintf("%s-%d", prefix, taskID))
+	}
+	return names
+}
+
+// instanceUpdate 更新任务配置前解析好的设置，全部解析成功后才修改实例，任一项无效时实例保持原样
+type instanceUpdate struct {
+	eventTypes []EventType
+	rowFilter  *RowFilter
+	schedule   *DeliverySchedule
+	sampler    *EventSampler
+	lagGuard   *LagGuard
+	timeouts   DeliveryTimeouts
+	router     *PartitionRouter
+	compressor *PayloadCompressor
+	compactor  *EventCompactor
+	mapping    *PayloadMapping
+	identity   SourceIdentity
+	expiry     *EventExpiry
+	quality    *QualityRules
+	webhook    *WebhookHandler
+	dbHandler  *DatabaseHandler
+}
+
+// parseInstanceUpdate 解析并校验任务配置，不修改实例
+func (c *MySQLCanalInstance) parseInstanceUpdate(instanceID uint, task *database.Task) (*instanceUpdate, error) {
+	update := &instanceUpdate{eventTypes: parseEventTypes(SplitList(task.EventTypes))}
+	if len(update.eventTypes) == 0 {
+		return nil, fmt.Errorf("no valid event types in %q", task.EventTypes)
+	}
+
+	// Webhook 和数据库处理器都存在才迁移，避免只迁移了一个
+	for _, name := range []string{fmt.Sprintf("webhook-%d", instanceID), fmt.Sprintf("db-%d", instanceID)} {
+		handler, ok := c.eventSink.GetHandler(name)
+		if !ok {
+			return nil, fmt.Errorf("handler %s not found in instance %s", name, c.id)
+		}
+		switch h := handler.(type) {
+		case *WebhookHandler:
+			update.webhook = h
+		case *DatabaseHandler:
+			update.dbHandler = h
+		}
+	}
+
+	var err error
+	if update.rowFilter, err = ParseRowFilter(task.RowFilter); err != nil {
+		return nil, fmt.Errorf("invalid row filter: %v", err)
+	}
+	if update.schedule, err = ParseDeliverySchedule(task.Schedule, task.ScheduleMode, task.ScheduleRate); err != nil {
+		return nil, fmt.Errorf("invalid schedule for task %d: %v", instanceID, err)
+	}
+	if update.sampler, err = NewEventSampler(task.SamplePercent, task.SampleInterval); err != nil {
+		return nil, fmt.Errorf("invalid sampling for task %d: %v", instanceID, err)
+	}
+	if update.lagGuard, err = NewLagGuard(task); err != nil {
+		return nil, fmt.Errorf("invalid lag guard for task %d: %v", instanceID, err)
+	}
+	update.timeouts = TaskDeliveryTimeouts(task)
+	if err := update.timeouts.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid delivery timeouts for task %d: %v", instanceID, err)
+	}
+	if _, err := ParseDeleteMode(task.DeleteMode); err != nil {
+		return nil, fmt.Errorf("invalid delete mode for task %d: %v", instanceID, err)
+	}
+	if update.router, err = NewPartitionRouter(task.PartitionBy, task.PartitionColumn, task.PartitionCount); err != nil {
+		return nil, fmt.Errorf("invalid partition routing for task %d: %v", instanceID, err)
+	}
+	if update.compressor, err = NewPayloadCompressor(task.Compression, task.CompressMinSize); err != nil {
+		return nil, fmt.Errorf("invalid compression for task %d: %v", instanceID, err)
+	}
+	if update.compactor, err = NewEventCompactor(task.CompactWindow); err != nil {
+		return nil, fmt.Errorf("invalid compact window for task %d: %v", instanceID, err)
+	}
+	if update.mapping, err = ParsePayloadMapping(task.PayloadMapping); err != nil {
+		return nil, fmt.Errorf("invalid payload mapping for task %d: %v", instanceID, err)
+	}
+	if update.identity, err = TaskSourceIdentity(task); err != nil {
+		return nil, fmt.Errorf("invalid source identity for task %d: %v", instanceID, err)
+	}
+	if update.expiry, err = NewEventExpiry(task); err != nil {
+		return nil, fmt.Errorf("invalid event expiry for task %d: %v", instanceID, err)
+	}
+	if update.quality, err = ParseQualityRules(task.QualityRules); err != nil {
+		return nil, fmt.Errorf("invalid quality rules for task %d: %v", instanceID, err)
+	}
+	update.quality.SetLookup(c.qualityLookup)
+	return update, nil
+}
+
+// UpdateInstance 原地更新任务配置（监听表、事件类型、回调地址、排除规则、演练模式）
+// 只替换订阅关系，不重启 binlog 流，缓冲中的事件和 binlog 位置都会保留。
+// 先解析和校验全部配置，再迁移处理器和应用设置，配置无效时实例保持原样
 func (c *MySQLCanalInstance) UpdateInstance(instanceID uint, task *database.Task) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logger.Printf("🔧 Reconfiguring MySQL Canal Instance %s for task %d: %s.%s -> %s",
+		c.id, instanceID, task.Database, task.Table, task.CallbackURL)
+
+	update, err := c.parseInstanceUpdate(instanceID, task)
+	if err != nil {
+		return err
+	}
+
+	// 先把处理器迁移到新表，再调整监听表，避免中间状态丢事件；迁移失败时把已迁移的处理器移回原表
+	newKey := fmt.Sprintf("%s.%s", task.Database, task.Table)
+	oldKeys := make(map[string]bool)
+	moved := make(map[string]string)
+	for _, name := range []string{fmt.Sprintf("webhook-%d", instanceID), fmt.Sprintf("db-%d", instanceID)} {
+		oldKey, ok := c.eventSink.MoveHandler(name, task.Database, task.Table)
+		if !ok {
+			for movedName, key := range moved {
+				if parts := strings.SplitN(key, ".", 2); len(parts) == 2 {
+					c.eventSink.MoveHandler(movedName, parts[0], parts[1])
+				}
+			}
+			return fmt.Errorf("handler %s not found in instance %s", name, c.id)
+		}
+		moved[name] = oldKey
+		oldKeys[oldKey] = true
+	}
+
+	// 可选处理器，未启用时不存在
+	for _, name := range []string{fmt.Sprintf("clickhouse-%d", instanceID), fmt.Sprintf("archive-%d", instanceID)} {
+		if oldKey, ok := c.eventSink.MoveHandler(name, task.Database, task.Table); ok {
+			oldKeys[oldKey] = true
+		}
+	}
+
+	c.binlogSlave.AddWatchTable(task.Database, task.Table)
+	for key := range oldKeys {
+		if key == newKey || c.isPolicyTableLocked(key) {
+			continue
+		}
+		if parts := strings.SplitN(key, ".", 2); len(parts) == 2 {
+			c.binlogSlave.RemoveWatchTable(parts[0], parts[1])
+		}
+	}
+
+	c.applyEventTypesLocked(instanceID, update.eventTypes)
+	for _, name := range []string{fmt.Sprintf("webhook-%d", instanceID), fmt.Sprintf("db-%d", instanceID)} {
+		c.eventSink.SetHandlerRowFilter(name, update.rowFilter)
+	}
+	c.SetPriority(task.Priority)
+	c.setExcludeTablesLocked(SplitList(task.ExcludeTables))
+
+	// 回调地址和投递超时原地更新，缓冲中未发送的事件会发往新地址
+	if webhook := update.webhook; webhook != nil {
+		webhook.SetCallbackURL(task.CallbackURL)
+		webhook.SetDryRun(task.DryRun)
+		webhook.SetPartitionRouter(update.router)
+		webhook.SetNumericStrings(TaskNumericStrings(task, c.config.NumericStrings))
+		webhook.SetIncludeSchema(TaskIncludeSchema(task))
+		webhook.SetCompressor(update.compressor)
+		webhook.SetCompactor(update.compactor)
+		webhook.SetPayloadMapping(update.mapping)
+		webhook.SetSourceIdentity(update.identity)
+		webhook.SetEventExpiry(update.expiry)
+		webhook.SetQualityRules(update.quality)
+		webhook.SetQuarantineURL(task.QuarantineURL)
+		webhook.SetDeleteMode(task.DeleteMode)
+		webhook.SetPriority(task.Priority)
+		webhook.SetTimeouts(update.timeouts)
+	}
+	if dbHandler := update.dbHandler; dbHandler != nil {
+		dbHandler.SetDryRun(task.DryRun)
+		dbHandler.SetQualityRules(update.quality, task.QuarantineURL != "")
+	}
+	c.eventSink.SetHandlerTimeout(update.timeouts.Delivery)
+
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		slave.SetSchedule(update.schedule)
+	}
+	c.samplePercent, c.sampleInterval = task.SamplePercent, task.SampleInterval
+	c.eventSink.SetSampler(update.sampler)
+	c.lagGuard = update.lagGuard
+	c.lagBreach = nil
+	c.lagSampling = false
+
+	c.logger.Printf("✅ MySQL Canal Instance %s reconfigured", c.id)
+	return nil
+}
+
+// SetContract 设置任务的消费端契约，Webhook 处理器跳过违约的事件，数据库处理器按 Webhook 处理器的校验将其记为 failed
+func (c *MySQLCanalInstance) SetContract(instanceID uint, contract *ConsumerContract) {
+	handler, ok := c.eventSink.GetHandler(fmt.Sprintf("webhook-%d", instanceID))
+	if !ok {
+		return
+	}
+	webhook, ok := handler.(*WebhookHandler)
+	if !ok {
+		return
+	}
+	webhook.SetContract(contract)
+	if handler, ok := c.eventSink.GetHandler(fmt.Sprintf("db-%d", instanceID)); ok {
+		if dbHandler, ok := handler.(*DatabaseHandler); ok {
+			dbHandler.SetContract(webhook)
+		}
+	}
+}
+
+// SetSchedule 根据任务配置设置维护窗口
+func (c *MySQLCanalInstance) SetSchedule(task *database.Task) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setScheduleLocked(task)
+}
+
+// setScheduleLocked 设置维护窗口，调用方需持有锁
+func (c *MySQLCanalInstance) setScheduleLocked(task *database.Task) error {
+	schedule, err := ParseDeliverySchedule(task.Schedule, task.ScheduleMode, task.ScheduleRate)
+	if err != nil {
+		return fmt.Errorf("invalid schedule for task %d: %v", task.ID, err)
+	}
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		slave.SetSchedule(schedule)
+	}
 	return nil
 }
 
+// SetSampling 根据任务配置设置事件采样
+func (c *MySQLCanalInstance) SetSampling(task *database.Task) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setSamplingLocked(task)
+}
+
+// setSamplingLocked 设置事件采样，调用方需持有锁。重新设置后采样统计和限频状态清零
+func (c *MySQLCanalInstance) setSamplingLocked(task *database.Task) error {
+	sampler, err := NewEventSampler(task.SamplePercent, task.SampleInterval)
+	if err != nil {
+		return fmt.Errorf("invalid sampling for task %d: %v", task.ID, err)
+	}
+	c.samplePercent, c.sampleInterval = task.SamplePercent, task.SampleInterval
+	c.eventSink.SetSampler(sampler)
+	return nil
+}
+
+// SetReadThrottle 设置全局读取限速
+func (c *MySQLCanalInstance) SetReadThrottle(throttle *ReadThrottle) {
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		slave.SetReadThrottle(throttle)
+	}
+}
+
+// SetLogSampler 设置热点路径日志采样，事件接收器和 binlog 读取共用，需在启动前设置
+func (c *MySQLCanalInstance) SetLogSampler(sampler *LogSampler) {
+	c.eventSink.SetLogSampler(sampler)
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		slave.SetLogSampler(sampler)
+	}
+}
+
+// SetMaintenance 设置全局维护暂停开关
+func (c *MySQLCanalInstance) SetMaintenance(maintenance *MaintenanceSwitch) {
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		slave.SetMaintenance(maintenance)
+	}
+}
+
+// SetQualityLookup 设置数据质量规则中引用存在断言的查询，需在更新任务前设置
+func (c *MySQLCanalInstance) SetQualityLookup(lookup QualityLookup) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.qualityLookup = lookup
+}
+
+// SavePosition 立即保存已投递完成的 binlog 位置，返回保存的位置，没有可保存的位置时 Name 为空
+func (c *MySQLCanalInstance) SavePosition() (Position, error) {
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		return slave.SavePosition()
+	}
+	return Position{}, nil
+}
+
+// SetCredentials 切换源库账号，binlog 连接用新账号从当前位置重连
+func (c *MySQLCanalInstance) SetCredentials(creds SourceCredentials) {
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		slave.SetCredentials(creds)
+	}
+}
+
+// SetPriority 设置任务优先级，全局读取限速时作为调度权重
+func (c *MySQLCanalInstance) SetPriority(priority int) {
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		slave.SetPriority(priority)
+	}
+}
+
+// StreamRestarts 停滞检测触发的 binlog 流重启记录
+func (c *MySQLCanalInstance) StreamRestarts() []StreamRestart {
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		return slave.StreamRestarts()
+	}
+	return nil
+}
+
+// ChecksumStatus 事件校验和设置和校验失败的审计记录
+func (c *MySQLCanalInstance) ChecksumStatus() ChecksumStatus {
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		return slave.ChecksumStatus()
+	}
+	return ChecksumStatus{BinlogChecksum: c.config.Checksum.withDefaults(), Skipped: []SkippedEvent{}}
+}
+
+// SetChecksumAuditor 设置跳过损坏事件后的回调，用于持久化审计记录和告警
+func (c *MySQLCanalInstance) SetChecksumAuditor(onSkip func(SkippedEvent)) {
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		slave.SetChecksumAuditor(onSkip)
+	}
+}
+
+// TableActivity 各表在 binlog 中的活跃度
+func (c *MySQLCanalInstance) TableActivity() []TableActivity {
+	if slave, ok := c.binlogSlave.(*MySQLBinlogSlave); ok {
+		return slave.TableActivity()
+	}
+	return nil
+}
+
+// SetHandlerTimeout 设置事件处理器的处理超时
+func (c *MySQLCanalInstance) SetHandlerTimeout(timeout time.Duration) {
+	c.eventSink.SetHandlerTimeout(timeout)
+}
+
+// SetExcludeTables 设置任务级排除规则（与全局排除规则合并）
+func (c *MySQLCanalInstance) SetExcludeTables(patterns []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setExcludeTablesLocked(patterns)
+}
+
+// setExcludeTablesLocked 设置排除规则，调用方需持有锁
+func (c *MySQLCanalInstance) setExcludeTablesLocked(patterns []string) {
+	c.taskExcludes = append([]string(nil), patterns...)
+	merged := make([]string, 0, len(c.globalExcludes)+len(patterns))
+	merged = append(merged, c.globalExcludes...)
+	merged = append(merged, patterns...)
+	c.binlogSlave.SetExcludeTables(merged)
+}
+
+// SetEventTypes 按任务的事件类型过滤任务的 Webhook 投递和事件日志，同一张表上的任务可以接收不同类型的事件。
+// binlog 按全局事件类型与任务事件类型的并集读取，心跳等其他订阅不受任务的事件类型影响
+func (c *MySQLCanalInstance) SetEventTypes(task *database.Task) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setEventTypesLocked(task)
+}
+
+// setEventTypesLocked 设置任务的事件类型，调用方需持有锁
+func (c *MySQLCanalInstance) setEventTypesLocked(task *database.Task) error {
+	eventTypes := parseEventTypes(SplitList(task.EventTypes))
+	if len(eventTypes) == 0 {
+		return fmt.Errorf("no valid event types in %q", task.EventTypes)
+	}
+
+	c.applyEventTypesLocked(task.ID, eventTypes)
+	return nil
+}
+
+// applyEventTypesLocked 应用已解析的任务事件类型，调用方需持有锁
+func (c *MySQLCanalInstance) applyEventTypesLocked(taskID uint, eventTypes []EventType) {
+	c.taskTypes = eventTypes
+	c.applyReadEventTypesLocked()
+
+	for _, name := range []string{fmt.Sprintf("webhook-%d", taskID), fmt.Sprintf("db-%d", taskID)} {
+		c.eventSink.SetHandlerEventTypes(name, eventTypes)
+	}
+}
+
+// applyReadEventTypesLocked binlog 按全局事件类型与任务事件类型的并集读取，调用方需持有锁
+func (c *MySQLCanalInstance) applyReadEventTypesLocked() {
+	read := append([]EventType(nil), c.globalTypes...)
+	for _, eventType := range c.taskTypes {
+		if !containsEventType(read, eventType) {
+			read = append(read, eventType)
+		}
+	}
+	c.binlogSlave.SetEventTypes(read)
+}
+
+// SetRowFilter 按任务的行过滤条件过滤任务的 Webhook 投递和事件日志，条件为空时不过滤
+func (c *MySQLCanalInstance) SetRowFilter(task *database.Task) error {
+	rowFilter, err := ParseRowFilter(task.RowFilter)
+	if err != nil {
+		return fmt.Errorf("invalid row filter: %v", err)
+	}
+	for _, name := range []string{fmt.Sprintf("webhook-%d", task.ID), fmt.Sprintf("db-%d", task.ID)} {
+		c.eventSink.SetHandlerRowFilter(name, rowFilter)
+	}
+	return nil
+}
+
+// containsEventType 事件类型列表中是否包含 eventType
+func containsEventType(eventTypes []EventType, eventType EventType) bool {
+	for _, t := range eventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Recover 从 binlog 被清除的告警状态中恢复，snapshot 方式的快照在后台进行，进度见实例状态的 recovery。
+// 查询源库位置时不持有实例的锁
+func (c *MySQLCanalInstance) Recover(action RecoveryAction) error {
+	c.mu.RLock()
+	running, slave := c.running, c.binlogSlave
+	c.mu.RUnlock()
+
+	if !running {
+		return fmt.Errorf("mysql canal instance %s is not running", c.id)
+	}
+
+	c.logger.Printf("🔧 Recovering MySQL Canal Instance %s with action %s", c.id, action)
+	return slave.Recover(action)
+}
+
 // Subscribe 订阅事件
 func (c *MySQLCanalInstance) Subscribe(schema, table string, handler EventHandler) error {
 	c.mu.Lock()
@@ -250,7 +800,42 @@ func (c *MySQLCanalInstance) GetStatus() InstanceStatus {
 		if lastEventTime, ok := stats["last_event_time"].(time.Time); ok {
 			c.status.LastEvent = lastEventTime
 		}
+
+		// binlog 被清除、事件校验和不匹配、位置漂移无法自动修正或源库改为按语句记录时进入告警状态
+		c.status.PositionDrift, _ = stats["position_drift"].(*PositionDrift)
+		c.status.Recovery, _ = stats["recovery"].(*RecoveryProgress)
+		if purged, ok := stats["binlog_purged"].(bool); ok && purged {
+			c.status.Alert = AlertBinlogPurged
+		} else if failing, ok := stats["checksum_failing"].(bool); ok && failing {
+			c.status.Alert = AlertBinlogChecksum
+		} else if c.status.PositionDrift != nil && c.status.PositionDrift.Reconciled == nil {
+			c.status.Alert = AlertPositionDrift
+		} else if statement, ok := stats["statement_binlog"].(bool); ok && statement {
+			c.status.Alert = AlertBinlogSettings
+		} else {
+			c.status.Alert = ""
+		}
+
+		// 同步 binlog 流的最近错误
+		c.status.ErrorMsg, _ = stats["last_error"].(string)
+		if c.status.ErrorMsg != "" {
+			c.status.ErrorAt, _ = stats["last_error_at"].(time.Time)
+		}
+		// 事件接收器停止处理后按实例已停止报告，由核对任务重启实例，从已提交的位置重新读取
+		if err := c.eventSink.Err(); err != nil {
+			c.status.Running = false
+			c.status.ErrorMsg = fmt.Sprintf("event sink stopped: %v", err)
+			c.status.ErrorAt = c.eventSink.FailedAt()
+		}
+
+		c.status.Lag, _ = stats["lag_seconds"].(float64)
+		c.status.Paused, _ = stats["paused"].(bool)
+		c.status.MemoryPaused, _ = stats["memory_paused"].(bool)
+		c.status.MaintenancePaused, _ = stats["maintenance_paused"].(bool)
 	}
+	c.status.BufferedBytes = c.eventSink.MemoryUsage()
+	c.status.Sinks = c.eventSink.SinkStatuses()
+	c.status.Backfill = c.backfillProgress()
 
 	return c.status
 }
@@ -290,6 +875,17 @@ func (c *MySQLCanalInstance) GetStats() map[string]interface{} {
 		binlogStats := c.binlogSlave.GetStats()
 		stats["binlog"] = binlogStats
 	}
+	stats["large_values"] = c.eventSink.LargeValueStats()
+	stats["sampling"] = c.eventSink.SamplingStats()
+	stats["pending_events"] = c.eventSink.PendingEvents()
+	stats["subscriptions"] = c.eventSink.Subscriptions()
+	stats["pipeline"] = c.eventSink.PipelineStats()
+	stats["sinks"] = c.eventSink.SinkStats()
+	stats["sink_delivery"] = c.eventSink.SinkStatuses()
+	stats["event_queue"] = c.eventSink.QueueStats()
+	if c.lagBreach != nil {
+		stats["lag_breach"] = c.lagBreachCopy()
+	}
 
 	return stats
 }
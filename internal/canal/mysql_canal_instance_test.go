@@ -1,11 +1,13 @@
 package canal
 
 import (
+	"io"
 	"log"
 	"os"
 	"testing"
 
 	"pikachun/internal/config"
+	"pikachun/internal/database"
 )
 
 // TestMySQLCanalInstanceLogging 测试 MySQLCanalInstance 的日志功能
@@ -43,3 +45,232 @@ func TestMySQLCanalInstanceLogging(t *testing.T) {
 	str := instance.String()
 	t.Logf("String representation: %s", str)
 }
+
+// eventTypesSlave 记录读取的事件类型的 binlog 读取器
+type eventTypesSlave struct {
+	BinlogSlave
+	types []EventType
+}
+
+func (s *eventTypesSlave) SetEventTypes(eventTypes []EventType) {
+	s.types = eventTypes
+}
+
+// TestMySQLCanalInstanceSetEventTypes 测试任务的事件类型在订阅处过滤，binlog 按全局与任务事件类型的并集读取
+func TestMySQLCanalInstanceSetEventTypes(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	slave := &eventTypesSlave{}
+	sink := NewDefaultEventSink(logger)
+	c := &MySQLCanalInstance{id: "task-3", eventSink: sink, binlogSlave: slave, logger: logger, globalTypes: []EventType{EventTypeInsert}}
+
+	if err := c.SetEventTypes(&database.Task{ID: 3, EventTypes: "UPSERT"}); err == nil {
+		t.Error("expected error for invalid event types")
+	}
+	if err := c.SetEventTypes(&database.Task{ID: 3, EventTypes: "DELETE"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(slave.types) != 2 || slave.types[0] != EventTypeInsert || slave.types[1] != EventTypeDelete {
+		t.Errorf("expected binlog to read INSERT and DELETE, got %v", slave.types)
+	}
+	for _, name := range []string{"webhook-3", "db-3"} {
+		if filter := sink.filters[name]; filter == nil || len(filter.eventTypes) != 1 || !filter.eventTypes[EventTypeDelete] {
+			t.Errorf("expected %s to receive only DELETE, got %v", name, filter)
+		}
+	}
+	if _, filtered := sink.filters["heartbeat-3"]; filtered {
+		t.Error("heartbeat handler must not be filtered")
+	}
+}
+
+// watchSlave 记录监听的表、排除规则和读取的事件类型的 binlog 读取器
+type watchSlave struct {
+	eventTypesSlave
+	tables   map[string]bool
+	excludes []string
+}
+
+func (s *watchSlave) AddWatchTable(schema, table string) {
+	s.tables[schema+"."+table] = true
+}
+
+func (s *watchSlave) RemoveWatchTable(schema, table string) {
+	delete(s.tables, schema+"."+table)
+}
+
+func (s *watchSlave) SetExcludeTables(patterns []string) {
+	s.excludes = patterns
+}
+
+// TestMySQLCanalInstanceSetWatchPolicy 测试全局监听策略即时生效：与任务的排除规则和事件类型重新合并，
+// 移出策略的表仍有订阅时继续监听
+func TestMySQLCanalInstanceSetWatchPolicy(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	slave := &watchSlave{tables: make(map[string]bool)}
+	sink := NewDefaultEventSink(logger)
+	c := &MySQLCanalInstance{id: "task-4", eventSink: sink, binlogSlave: slave, logger: logger}
+
+	c.SetWatchPolicy(WatchPolicy{Tables: []string{"shop.orders", "shop.users"}, EventTypes: []string{"INSERT"}, ExcludeTables: []string{"*_tmp"}})
+	c.SetExcludeTables([]string{"shop.audit"})
+	if err := c.SetEventTypes(&database.Task{ID: 4, EventTypes: "DELETE"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Subscribe("shop", "orders", &captureHandler{events: make(chan *Event, 1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c.SetWatchPolicy(WatchPolicy{Tables: []string{"shop.items"}, EventTypes: []string{"UPDATE"}, ExcludeTables: []string{"*_bak"}})
+	for table, want := range map[string]bool{"shop.orders": true, "shop.users": false, "shop.items": true} {
+		if slave.tables[table] != want {
+			t.Errorf("expected %s watched=%v, got %v", table, want, slave.tables)
+		}
+	}
+	if len(slave.excludes) != 2 || slave.excludes[0] != "*_bak" || slave.excludes[1] != "shop.audit" {
+		t.Errorf("expected merged excludes, got %v", slave.excludes)
+	}
+	if len(slave.types) != 2 || slave.types[0] != EventTypeUpdate || slave.types[1] != EventTypeDelete {
+		t.Errorf("expected binlog to read UPDATE and DELETE, got %v", slave.types)
+	}
+}
+
+// TestMySQLCanalInstanceUpdateInstanceAtomic 测试处理器缺失或配置无效时更新失败，处理器仍订阅原表
+func TestMySQLCanalInstanceUpdateInstanceAtomic(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	slave := &watchSlave{tables: map[string]bool{"shop.orders": true}}
+	sink := NewDefaultEventSink(logger)
+	c := &MySQLCanalInstance{id: "task-5", eventSink: sink, binlogSlave: slave, logger: logger, config: MySQLConfig{}}
+	if err := sink.Subscribe("shop", "orders", NewWebhookHandler("webhook-5", "http://localhost", logger)); err != nil {
+		t.Fatal(err)
+	}
+	// 可选的归档处理器也要随任务迁移
+	if err := sink.Subscribe("shop", "orders", &stopOrderHandler{name: "archive-5"}); err != nil {
+		t.Fatal(err)
+	}
+	task := &database.Task{ID: 5, Database: "shop", Table: "items", EventTypes: "INSERT", CallbackURL: "http://localhost/new"}
+
+	assertOnOrders := func(name string) {
+		t.Helper()
+		if _, ok := sink.handlers["shop.orders"][name]; !ok {
+			t.Errorf("expected %s still subscribed on shop.orders, got %v", name, sink.handlers)
+		}
+		if _, ok := sink.handlers["shop.items"][name]; ok {
+			t.Errorf("expected %s not moved to shop.items", name)
+		}
+		if !slave.tables["shop.orders"] || slave.tables["shop.items"] {
+			t.Errorf("expected watched tables unchanged, got %v", slave.tables)
+		}
+	}
+
+	// 数据库处理器不存在时 Webhook 处理器不能被迁移
+	if err := c.UpdateInstance(5, task); err == nil {
+		t.Fatal("expected error for missing db handler")
+	}
+	assertOnOrders("webhook-5")
+
+	if err := sink.Subscribe("shop", "orders", &DatabaseHandler{name: "db-5"}); err != nil {
+		t.Fatal(err)
+	}
+	invalid := *task
+	invalid.RowFilter = "amount >"
+	if err := c.UpdateInstance(5, &invalid); err == nil {
+		t.Fatal("expected error for invalid row filter")
+	}
+	assertOnOrders("webhook-5")
+	assertOnOrders("db-5")
+	assertOnOrders("archive-5")
+
+	if err := c.UpdateInstance(5, task); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"webhook-5", "db-5", "archive-5"} {
+		if _, ok := sink.handlers["shop.items"][name]; !ok {
+			t.Errorf("expected %s moved to shop.items, got %v", name, sink.handlers)
+		}
+	}
+	if slave.tables["shop.orders"] || !slave.tables["shop.items"] {
+		t.Errorf("expected only shop.items watched, got %v", slave.tables)
+	}
+}
+
+// TestWatchPolicyNormalize 测试监听策略的校验和规范化
+func TestWatchPolicyNormalize(t *testing.T) {
+	policy, err := WatchPolicy{Tables: []string{" shop.orders", "shop.orders", ""}, EventTypes: []string{"insert", "DELETE"}}.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Tables) != 1 || len(policy.EventTypes) != 2 || policy.EventTypes[0] != "INSERT" || policy.ExcludeTables == nil {
+		t.Errorf("unexpected normalized policy %+v", policy)
+	}
+	for _, invalid := range []WatchPolicy{{Tables: []string{"orders"}}, {Tables: []string{"shop.*"}}, {EventTypes: []string{"UPSERT"}}} {
+		if _, err := invalid.Normalize(); err == nil {
+			t.Errorf("expected %+v to be invalid", invalid)
+		}
+	}
+}
+
+// stopOrderSlave 记录停止顺序的 binlog 读取器
+type stopOrderSlave struct {
+	BinlogSlave
+	order *[]string
+}
+
+func (s *stopOrderSlave) Stop() error {
+	*s.order = append(*s.order, "slave")
+	return nil
+}
+
+// stopOrderHandler 记录关闭顺序的处理器
+type stopOrderHandler struct {
+	captureHandler
+	name  string
+	order *[]string
+}
+
+func (h *stopOrderHandler) GetName() string {
+	return h.name
+}
+
+func (h *stopOrderHandler) Close() error {
+	*h.order = append(*h.order, h.name)
+	return nil
+}
+
+// TestMySQLCanalInstanceStopInstance 测试停止任务的实例时先停止 binlog 读取再关闭处理器，
+// 之后从事件接收器摘除任务的处理器
+func TestMySQLCanalInstanceStopInstance(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	var order []string
+	sink := NewDefaultEventSink(logger)
+	c := &MySQLCanalInstance{id: "task-5", eventSink: sink, binlogSlave: &stopOrderSlave{order: &order}, logger: logger, running: true}
+
+	for _, name := range []string{"webhook-5", "db-5"} {
+		if err := sink.Subscribe("shop", "orders", &stopOrderHandler{name: name, order: &order}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Subscribe("shop", "users", &stopOrderHandler{name: "webhook-5", order: &order}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.StopInstance(6); err == nil {
+		t.Error("expected error for another task's instance")
+	}
+	if err := c.StopInstance(5); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) < 3 || order[0] != "slave" {
+		t.Errorf("expected binlog to stop before handlers close, got %v", order)
+	}
+	if c.running {
+		t.Error("expected instance to be stopped")
+	}
+	for _, name := range []string{"webhook-5", "db-5"} {
+		if _, exists := sink.GetHandler(name); exists {
+			t.Errorf("expected %s to be unsubscribed", name)
+		}
+	}
+
+	// 重复停止只摘除处理器
+	if err := c.StopInstance(5); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,66 @@
+package canal
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+
+	"pikachun/internal/database"
+)
+
+// TaskNumericStrings 任务是否把 64 位整数和定点小数编码为字符串，任务未设置时使用全局配置
+func TaskNumericStrings(task *database.Task, global bool) bool {
+	if task.NumericStrings != nil {
+		return *task.NumericStrings
+	}
+	return global
+}
+
+// numericSafeEvents 返回列值中的 64 位整数和定点小数已转为字符串的事件副本
+// JavaScript 的数字是双精度浮点数，超过 2^53 的 BIGINT 和高精度 DECIMAL 解析后会丢失精度
+func numericSafeEvents(events []*Event) []*Event {
+	result := make([]*Event, len(events))
+	for i, event := range events {
+		copied := *event
+		copied.BeforeData = numericSafeRow(event.BeforeData)
+		copied.AfterData = numericSafeRow(event.AfterData)
+		copied.Key = numericSafeRow(event.Key)
+		result[i] = &copied
+	}
+	return result
+}
+
+// numericSafeRow 复制行数据并转换列值
+func numericSafeRow(row *RowData) *RowData {
+	if row == nil {
+		return nil
+	}
+	columns := make([]Column, len(row.Columns))
+	for i, col := range row.Columns {
+		col.Value = NumericSafeValue(col.Value)
+		columns[i] = col
+	}
+	return &RowData{Columns: columns}
+}
+
+// NumericSafeValue 把 64 位整数和定点小数转为十进制字符串，其他值原样返回
+// BIGINT 列的值为 int64/uint64，DECIMAL 列为 decimal.Decimal，从事件日志还原的数值为 json.Number
+func NumericSafeValue(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int64:
+		return strconv.FormatInt(n, 10)
+	case uint64:
+		return strconv.FormatUint(n, 10)
+	case int:
+		return strconv.Itoa(n)
+	case uint:
+		return strconv.FormatUint(uint64(n), 10)
+	case decimal.Decimal:
+		return n.String()
+	case json.Number:
+		return n.String()
+	default:
+		return v
+	}
+}
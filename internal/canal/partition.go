@@ -0,0 +1,129 @@
+package canal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// 分区路由策略
+const (
+	PartitionNone       = "none"        // 不分区（默认）
+	PartitionByPK       = "pk"          // 按主键，同一行的变更进入同一分区
+	PartitionByTable    = "table"       // 按库表，同一张表的变更进入同一分区
+	PartitionByColumn   = "column"      // 按指定列的值，如租户 ID
+	PartitionRoundRobin = "round_robin" // 轮询，不保证顺序，需要指定分区数
+)
+
+// PartitionRouter 计算事件的分区键，供需要保序或按分片消费的下游使用
+type PartitionRouter struct {
+	Strategy string
+	Column   string // column 策略使用的列名
+	Count    int    // 分区数，大于 0 时分区键为分区号 0..Count-1，否则为原始路由值
+
+	next uint64 // 轮询计数
+}
+
+// NewPartitionRouter 根据任务配置创建分区路由，strategy 为空或 none 时返回 nil
+func NewPartitionRouter(strategy, column string, count int) (*PartitionRouter, error) {
+	strategy = strings.TrimSpace(strategy)
+	column = strings.TrimSpace(column)
+	if count < 0 {
+		return nil, fmt.Errorf("partition count must not be negative")
+	}
+
+	switch strategy {
+	case "", PartitionNone:
+		return nil, nil
+	case PartitionByPK, PartitionByTable:
+	case PartitionByColumn:
+		if column == "" {
+			return nil, fmt.Errorf("partition column is required for strategy %s", strategy)
+		}
+	case PartitionRoundRobin:
+		if count == 0 {
+			return nil, fmt.Errorf("partition count is required for strategy %s", strategy)
+		}
+	default:
+		return nil, fmt.Errorf("unknown partition strategy: %s", strategy)
+	}
+	return &PartitionRouter{Strategy: strategy, Column: column, Count: count}, nil
+}
+
+// Key 计算事件的分区键。
+// 没有主键的表和缺少路由列的事件退回按库表路由，保证同一张表内的顺序
+func (r *PartitionRouter) Key(event *Event) string {
+	if r.Strategy == PartitionRoundRobin {
+		n := atomic.AddUint64(&r.next, 1) - 1
+		return strconv.FormatUint(n%uint64(r.Count), 10)
+	}
+
+	key := event.Schema + "." + event.Table
+	switch r.Strategy {
+	case PartitionByPK:
+		if values, ok := rowValues(event, event.PrimaryKey); ok {
+			key += ":" + values
+		}
+	case PartitionByColumn:
+		if values, ok := rowValues(event, []string{r.Column}); ok {
+			key = values
+		}
+	}
+
+	if r.Count > 0 {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		return strconv.FormatUint(uint64(h.Sum32()%uint32(r.Count)), 10)
+	}
+	return key
+}
+
+// rowValues 拼接行中指定列的值，优先使用变更后的数据，删除事件使用变更前的数据
+func rowValues(event *Event, columns []string) (string, bool) {
+	row := event.AfterData
+	if row == nil {
+		row = event.BeforeData
+	}
+	if row == nil || len(columns) == 0 {
+		return "", false
+	}
+
+	values := make([]string, 0, len(columns))
+	for _, name := range columns {
+		found := false
+		for _, column := range row.Columns {
+			if column.Name == name {
+				if column.IsNull {
+					values = append(values, "NULL")
+				} else {
+					values = append(values, fmt.Sprint(column.Value))
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", false
+		}
+	}
+	return strings.Join(values, ","), true
+}
+
+// groupByPartition 按分区键拆分一批事件，分组按首次出现的顺序排列，组内保持原有顺序
+func (r *PartitionRouter) groupByPartition(events []*Event) []deliveryBatch {
+	var groups []deliveryBatch
+	index := make(map[string]int)
+	for _, event := range events {
+		key := r.Key(event)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, deliveryBatch{key: key})
+		}
+		groups[i].events = append(groups[i].events, event)
+	}
+	return groups
+}
@@ -0,0 +1,114 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// partitionTestEvent 构造带主键和租户列的事件
+func partitionTestEvent(table string, id, tenant int) *Event {
+	return &Event{
+		Schema:     "shop",
+		Table:      table,
+		EventType:  EventTypeInsert,
+		PrimaryKey: []string{"id"},
+		AfterData: &RowData{Columns: []Column{
+			{Name: "id", Value: id},
+			{Name: "tenant_id", Value: tenant},
+		}},
+	}
+}
+
+// TestPartitionRouterKey 测试各路由策略的分区键
+func TestPartitionRouterKey(t *testing.T) {
+	event := partitionTestEvent("orders", 42, 7)
+
+	cases := []struct {
+		strategy, column string
+		count            int
+		want             string
+	}{
+		{PartitionByTable, "", 0, "shop.orders"},
+		{PartitionByPK, "", 0, "shop.orders:42"},
+		{PartitionByColumn, "tenant_id", 0, "7"},
+		// 缺少路由列时退回按库表
+		{PartitionByColumn, "region", 0, "shop.orders"},
+	}
+	for _, c := range cases {
+		router, err := NewPartitionRouter(c.strategy, c.column, c.count)
+		if err != nil {
+			t.Fatalf("NewPartitionRouter(%s) failed: %v", c.strategy, err)
+		}
+		if got := router.Key(event); got != c.want {
+			t.Errorf("%s/%s: expected key %q, got %q", c.strategy, c.column, c.want, got)
+		}
+	}
+
+	// 指定分区数时同一行总是落在同一个分区
+	router, _ := NewPartitionRouter(PartitionByPK, "", 4)
+	if a, b := router.Key(event), router.Key(partitionTestEvent("orders", 42, 8)); a != b || len(a) != 1 {
+		t.Errorf("expected stable partition number, got %q and %q", a, b)
+	}
+
+	router, _ = NewPartitionRouter(PartitionRoundRobin, "", 3)
+	for i, want := range []string{"0", "1", "2", "0"} {
+		if got := router.Key(event); got != want {
+			t.Errorf("round robin #%d: expected %s, got %s", i, want, got)
+		}
+	}
+
+	if router, err := NewPartitionRouter(PartitionNone, "", 0); router != nil || err != nil {
+		t.Errorf("expected no router for none, got %v, %v", router, err)
+	}
+	for _, invalid := range [][2]string{{PartitionByColumn, ""}, {PartitionRoundRobin, ""}, {"hash", ""}} {
+		if _, err := NewPartitionRouter(invalid[0], invalid[1], 0); err == nil {
+			t.Errorf("expected error for strategy %q", invalid[0])
+		}
+	}
+}
+
+// TestWebhookPartitionedDelivery 测试一批事件按分区拆成多个请求并带上 X-Partition-Key
+func TestWebhookPartitionedDelivery(t *testing.T) {
+	var mu sync.Mutex
+	counts := make(map[string]string)
+	var order []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		key := r.Header.Get("X-Partition-Key")
+		counts[key] = r.Header.Get("X-Event-Count")
+		order = append(order, key)
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler("webhook-1", server.URL, log.New(io.Discard, "", 0))
+	router, _ := NewPartitionRouter(PartitionByTable, "", 0)
+	handler.SetPartitionRouter(router)
+
+	for _, event := range []*Event{
+		partitionTestEvent("orders", 1, 1),
+		partitionTestEvent("users", 1, 1),
+		partitionTestEvent("orders", 2, 1),
+	} {
+		if err := handler.Handle(context.Background(), event); err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 2 || order[0] != "shop.orders" || order[1] != "shop.users" {
+		t.Fatalf("expected one request per partition in order, got %v", order)
+	}
+	if counts["shop.orders"] != "2" || counts["shop.users"] != "1" {
+		t.Errorf("unexpected event counts per partition: %v", counts)
+	}
+}
@@ -0,0 +1,205 @@
+package canal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 载荷映射中可以删除的元数据字段
+var mappingMetadataFields = map[string]bool{
+	"id":              true,
+	"schema":          true,
+	"table":           true,
+	"event_type":      true,
+	"timestamp":       true,
+	"position":        true,
+	"sql":             true,
+	"primary_key":     true,
+	"schema_hash":     true,
+	"content_hash":    true,
+	"compacted":       true,
+	"tombstone":       true,
+	"commit_time_us":  true,
+	"processed_at_us": true,
+	"commit_index":    true,
+}
+
+// 展开行数据时的默认前缀
+const (
+	defaultBeforePrefix = "before_"
+	defaultAfterPrefix  = "after_"
+	defaultKeyPrefix    = "key_"
+)
+
+// PayloadMapping 载荷映射：按任务声明的规则改写 Webhook 载荷中的事件，
+// 支持列重命名、把行数据展开到事件顶层、删除元数据字段，在序列化时应用，不影响事件日志
+type PayloadMapping struct {
+	// Rename 列重命名，键为 "列名" 或 "表名.列名"，后者只作用于该表且优先
+	Rename map[string]string `json:"rename,omitempty"`
+	// Flatten 为 true 时 before_data、after_data 和墓碑的 key 展开为顶层字段，值为列值，
+	// 字段名为前缀加列名，与元数据字段重名时覆盖元数据字段
+	Flatten      bool    `json:"flatten,omitempty"`
+	BeforePrefix *string `json:"before_prefix,omitempty"` // 默认 before_
+	AfterPrefix  *string `json:"after_prefix,omitempty"`  // 默认 after_
+	KeyPrefix    *string `json:"key_prefix,omitempty"`    // 默认 key_
+	// Drop 删除的元数据字段，如 position、sql、schema_hash
+	Drop []string `json:"drop,omitempty"`
+}
+
+// ParsePayloadMapping 解析并校验载荷映射规则，定义为空时返回 nil，表示不改写
+func ParsePayloadMapping(definition string) (*PayloadMapping, error) {
+	if strings.TrimSpace(definition) == "" {
+		return nil, nil
+	}
+
+	var mapping PayloadMapping
+	decoder := json.NewDecoder(strings.NewReader(definition))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&mapping); err != nil {
+		return nil, fmt.Errorf("invalid payload mapping: %v", err)
+	}
+
+	for from, to := range mapping.Rename {
+		if strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+			return nil, fmt.Errorf("invalid rename %q -> %q, column names must not be empty", from, to)
+		}
+	}
+	for _, field := range mapping.Drop {
+		if !mappingMetadataFields[field] {
+			return nil, fmt.Errorf("unsupported drop field %q, only event metadata can be dropped", field)
+		}
+	}
+	if !mapping.Flatten && (mapping.BeforePrefix != nil || mapping.AfterPrefix != nil || mapping.KeyPrefix != nil) {
+		return nil, fmt.Errorf("prefixes only apply when flatten is enabled")
+	}
+	return &mapping, nil
+}
+
+// apply 按规则改写一批事件，返回用于序列化的载荷
+func (m *PayloadMapping) apply(events []*Event) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(events))
+	for i, event := range events {
+		result[i] = m.mapEvent(event)
+	}
+	return result
+}
+
+// mapEvent 改写单个事件，字段与 Event 的 JSON 编码一致
+func (m *PayloadMapping) mapEvent(event *Event) map[string]interface{} {
+	out := map[string]interface{}{
+		"id":         event.ID,
+		"schema":     event.Schema,
+		"table":      event.Table,
+		"event_type": event.EventType,
+		"timestamp":  event.Timestamp,
+		"position":   event.Position,
+	}
+	if event.SQL != "" {
+		out["sql"] = event.SQL
+	}
+	if len(event.PrimaryKey) > 0 {
+		out["primary_key"] = m.renameList(event.Table, event.PrimaryKey)
+	}
+	if event.SchemaHash != "" {
+		out["schema_hash"] = event.SchemaHash
+	}
+	if event.ContentHash != "" {
+		out["content_hash"] = event.ContentHash
+	}
+	if event.Compacted > 0 {
+		out["compacted"] = event.Compacted
+	}
+	if event.Tombstone {
+		out["tombstone"] = true
+	}
+	if event.CommitMicros > 0 {
+		out["commit_time_us"] = event.CommitMicros
+	}
+	if event.ProcessedMicros > 0 {
+		out["processed_at_us"] = event.ProcessedMicros
+	}
+	if event.CommitIndex > 0 {
+		out["commit_index"] = event.CommitIndex
+	}
+	for _, field := range m.Drop {
+		delete(out, field)
+	}
+
+	rows := []struct {
+		field string
+		row   *RowData
+	}{
+		{"before_data", event.BeforeData},
+		{"after_data", event.AfterData},
+		{"key", event.Key},
+	}
+	for _, r := range rows {
+		if r.row == nil {
+			continue
+		}
+		if !m.Flatten {
+			out[r.field] = m.renameRow(event.Table, r.row)
+			continue
+		}
+		for _, column := range r.row.Columns {
+			var value interface{}
+			if !column.IsNull {
+				value = column.Value
+			}
+			out[m.flattenedName(r.field, event.Table, column.Name)] = value
+		}
+	}
+	return out
+}
+
+// flattenedName 展开后列值在事件顶层的字段名，field 为 before_data、after_data 或 key
+func (m *PayloadMapping) flattenedName(field, table, column string) string {
+	prefix, def := m.KeyPrefix, defaultKeyPrefix
+	switch field {
+	case "before_data":
+		prefix, def = m.BeforePrefix, defaultBeforePrefix
+	case "after_data":
+		prefix, def = m.AfterPrefix, defaultAfterPrefix
+	}
+	if prefix != nil {
+		def = *prefix
+	}
+	return def + m.renameColumn(table, column)
+}
+
+// renameRow 复制行数据并重命名列
+func (m *PayloadMapping) renameRow(table string, row *RowData) *RowData {
+	if len(m.Rename) == 0 {
+		return row
+	}
+	columns := make([]Column, len(row.Columns))
+	for i, column := range row.Columns {
+		column.Name = m.renameColumn(table, column.Name)
+		columns[i] = column
+	}
+	return &RowData{Columns: columns}
+}
+
+// renameList 重命名列名列表
+func (m *PayloadMapping) renameList(table string, names []string) []string {
+	if len(m.Rename) == 0 {
+		return names
+	}
+	renamed := make([]string, len(names))
+	for i, name := range names {
+		renamed[i] = m.renameColumn(table, name)
+	}
+	return renamed
+}
+
+// renameColumn 列的新名称，"表名.列名" 规则优先，没有规则时返回原名
+func (m *PayloadMapping) renameColumn(table, column string) string {
+	if to, ok := m.Rename[table+"."+column]; ok {
+		return to
+	}
+	if to, ok := m.Rename[column]; ok {
+		return to
+	}
+	return column
+}
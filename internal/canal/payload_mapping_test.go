@@ -0,0 +1,128 @@
+package canal
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"testing"
+)
+
+// TestParsePayloadMapping 测试映射规则校验
+func TestParsePayloadMapping(t *testing.T) {
+	if mapping, err := ParsePayloadMapping(""); err != nil || mapping != nil {
+		t.Errorf("expected nil mapping for empty definition, got %v, %v", mapping, err)
+	}
+
+	invalid := []string{
+		`{"rename": {"user_name": ""}}`,
+		`{"drop": ["after_data"]}`,
+		`{"after_prefix": "new_"}`,
+		`{"flaten": true}`,
+		`[]`,
+	}
+	for _, definition := range invalid {
+		if _, err := ParsePayloadMapping(definition); err == nil {
+			t.Errorf("expected error for %s", definition)
+		}
+	}
+}
+
+// TestPayloadMappingRename 测试列重命名，表名限定的规则优先
+func TestPayloadMappingRename(t *testing.T) {
+	mapping, err := ParsePayloadMapping(`{"rename": {"id": "order_id", "users.id": "user_id"}, "drop": ["position", "sql"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := partitionTestEvent("orders", 1, 7)
+	order.SQL = "INSERT INTO orders ..."
+	user := partitionTestEvent("users", 2, 7)
+	mapped := mapping.apply([]*Event{order, user})
+
+	if name := mapped[0]["after_data"].(*RowData).Columns[0].Name; name != "order_id" {
+		t.Errorf("expected order_id, got %s", name)
+	}
+	if name := mapped[1]["after_data"].(*RowData).Columns[0].Name; name != "user_id" {
+		t.Errorf("expected user_id, got %s", name)
+	}
+	if _, ok := mapped[0]["position"]; ok {
+		t.Error("position should be dropped")
+	}
+	if _, ok := mapped[0]["sql"]; ok {
+		t.Error("sql should be dropped")
+	}
+	if mapped[0]["id"] != order.ID || mapped[0]["table"] != "orders" {
+		t.Errorf("metadata should be kept, got %v", mapped[0])
+	}
+	if order.AfterData.Columns[0].Name != "id" {
+		t.Error("the original event must not be modified")
+	}
+}
+
+// TestPayloadMappingFlatten 测试行数据展开为顶层字段
+func TestPayloadMappingFlatten(t *testing.T) {
+	mapping, err := ParsePayloadMapping(`{"rename": {"tenant_id": "tenant"}, "flatten": true, "after_prefix": ""}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := &Event{
+		ID:         "u1",
+		Schema:     "shop",
+		Table:      "orders",
+		EventType:  EventTypeUpdate,
+		BeforeData: &RowData{Columns: []Column{{Name: "status", Value: "new"}, {Name: "tenant_id", Value: 7}}},
+		AfterData:  &RowData{Columns: []Column{{Name: "status", Value: "paid"}, {Name: "note", IsNull: true}}},
+	}
+	mapped := mapping.apply([]*Event{event})[0]
+
+	if mapped["before_status"] != "new" || mapped["before_tenant"] != 7 || mapped["status"] != "paid" {
+		t.Errorf("unexpected flattened event %v", mapped)
+	}
+	if value, ok := mapped["note"]; !ok || value != nil {
+		t.Errorf("expected null column to be flattened as nil, got %v", value)
+	}
+	if _, ok := mapped["before_data"]; ok {
+		t.Error("before_data should be flattened")
+	}
+
+	tombstone := tombstoneEvent(deleteTestEvent(3))
+	if mapped := mapping.apply([]*Event{tombstone})[0]; mapped["key_id"] != 3 || mapped["tombstone"] != true {
+		t.Errorf("unexpected flattened tombstone %v", mapped)
+	}
+}
+
+// TestWebhookHandlerPayloadMapping 测试映射在序列化请求体时应用
+func TestWebhookHandlerPayloadMapping(t *testing.T) {
+	handler := NewWebhookHandler("webhook-1", "http://localhost", log.New(io.Discard, "", 0))
+	mapping, err := ParsePayloadMapping(`{"flatten": true, "drop": ["position"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.SetPayloadMapping(mapping)
+	handler.SetNumericStrings(true)
+
+	event := partitionTestEvent("orders", 1, 7)
+	event.AfterData.Columns[0].Value = int64(1)
+	data, err := handler.buildPayload(deliveryBatch{events: []*Event{event}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var payload struct {
+		Events []map[string]interface{} `json:"events"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	got := payload.Events[0]
+	if got["after_id"] != "1" {
+		t.Errorf("expected numeric string after_id, got %v", got["after_id"])
+	}
+	if _, ok := got["position"]; ok {
+		t.Errorf("position should be dropped: %v", got)
+	}
+	if handler.GetStats()["payload_mapping"] != true {
+		t.Error("expected payload_mapping in stats")
+	}
+}
@@ -0,0 +1,238 @@
+package canal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// 事件处理流水线：解析器 → 转换 → 队列 → 过滤 → 路由 → sink。
+// 解析器（binlog 读取）通过 SendEvent 把事件送入 DefaultEventSink：转换阶段在入队前按注册顺序原地修改事件，
+// 所有 sink 看到相同的结果；事件出队后按 binlog 顺序经过过滤阶段，被丢弃的事件不送往任何 sink，位置照常确认；
+// 保留的事件再经过出队转换阶段，写对象存储等耗时的转换在处理协程中执行，不阻塞 binlog 读取；
+// 路由按库表订阅和各处理器的过滤条件选出 sink（EventHandler），由 sink 缓冲、投递和重试。
+// 新的过滤、转换功能注册为阶段即可组合，不必在每个处理器中实现
+
+// 内置阶段的名称
+const (
+	StageContentHash  = "content_hash"  // 计算内容哈希，见 ContentHash
+	StageLargeValues  = "large_values"  // 大字段处理，见 LargeValuePolicy
+	StageTableChanges = "table_changes" // 表变更统计，见 TableChangeCounter
+	StageSampling     = "sampling"      // 事件采样，见 EventSampler
+)
+
+// EventTransformer 转换阶段，入队前原地修改事件
+type EventTransformer interface {
+	Apply(ctx context.Context, event *Event)
+}
+
+// EventFilter 过滤阶段，事件出队后按 binlog 顺序调用，返回 false 的事件不再处理
+type EventFilter interface {
+	Keep(event *Event) bool
+}
+
+// EventRouter 路由阶段，选出接收事件的 sink
+type EventRouter interface {
+	Route(event *Event) []Route
+}
+
+// Route 路由的结果：接收事件的处理器及其订阅统计
+type Route struct {
+	Name    string
+	Handler EventHandler
+	stats   *subscriptionStats
+}
+
+// TransformFunc 函数形式的转换阶段
+type TransformFunc func(ctx context.Context, event *Event)
+
+// Apply 调用函数
+func (f TransformFunc) Apply(ctx context.Context, event *Event) {
+	f(ctx, event)
+}
+
+// FilterFunc 函数形式的过滤阶段
+type FilterFunc func(event *Event) bool
+
+// Keep 调用函数
+func (f FilterFunc) Keep(event *Event) bool {
+	return f(event)
+}
+
+// pipelineStage 一个阶段及其统计，transform 和 filter 只设置一个，都为 nil 时阶段停用、保留位置
+type pipelineStage struct {
+	name      string
+	transform EventTransformer
+	filter    EventFilter
+	events    int64 // 经过阶段的事件数，原子访问
+	dropped   int64 // 被过滤阶段丢弃的事件数，原子访问
+}
+
+// StageInfo 阶段的统计
+type StageInfo struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"` // transform、filter 或 dispatch（出队转换）
+	Enabled bool   `json:"enabled"`
+	Events  int64  `json:"events"`
+	Dropped int64  `json:"dropped,omitempty"`
+}
+
+// eventPipeline 按注册顺序排列的转换和过滤阶段。阶段列表只整体替换，处理中的事件使用替换前的列表
+type eventPipeline struct {
+	mu         sync.RWMutex
+	transforms []*pipelineStage
+	filters    []*pipelineStage
+	dispatches []*pipelineStage // 出队转换阶段，在过滤阶段之后执行
+}
+
+// newEventPipeline 创建流水线，内置阶段按固定顺序占位：内容哈希在入队前按原始数据计算，
+// 大字段处理在出队后执行，被过滤掉的事件不写对象存储
+func newEventPipeline() *eventPipeline {
+	p := &eventPipeline{}
+	p.setTransform(StageContentHash, TransformFunc(func(ctx context.Context, event *Event) {
+		if event.ContentHash == "" {
+			event.ContentHash = ContentHash(event)
+		}
+	}))
+	p.setTransform(StageTableChanges, nil)
+	p.setFilter(StageSampling, nil)
+	p.setDispatch(StageLargeValues, nil)
+	return p
+}
+
+// setTransform 设置名为 name 的转换阶段，已存在时原位替换，否则追加到末尾；nil 停用该阶段
+func (p *eventPipeline) setTransform(name string, transform EventTransformer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.transforms = replaceStage(p.transforms, &pipelineStage{name: name, transform: transform})
+}
+
+// setFilter 设置名为 name 的过滤阶段，规则同 setTransform
+func (p *eventPipeline) setFilter(name string, filter EventFilter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.filters = replaceStage(p.filters, &pipelineStage{name: name, filter: filter})
+}
+
+// setDispatch 设置名为 name 的出队转换阶段，规则同 setTransform
+func (p *eventPipeline) setDispatch(name string, transform EventTransformer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dispatches = replaceStage(p.dispatches, &pipelineStage{name: name, transform: transform})
+}
+
+// replaceStage 返回替换或追加阶段后的新列表，替换后统计清零
+func replaceStage(stages []*pipelineStage, stage *pipelineStage) []*pipelineStage {
+	result := make([]*pipelineStage, 0, len(stages)+1)
+	replaced := false
+	for _, s := range stages {
+		if s.name == stage.name {
+			s = stage
+			replaced = true
+		}
+		result = append(result, s)
+	}
+	if !replaced {
+		result = append(result, stage)
+	}
+	return result
+}
+
+// stage 查找名为 name 的阶段，不存在时返回 nil
+func (p *eventPipeline) stage(name string) *pipelineStage {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, stages := range [][]*pipelineStage{p.transforms, p.filters, p.dispatches} {
+		for _, s := range stages {
+			if s.name == name {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// apply 依次执行转换阶段
+func (p *eventPipeline) apply(ctx context.Context, event *Event) {
+	p.mu.RLock()
+	transforms := p.transforms
+	p.mu.RUnlock()
+	applyStages(ctx, transforms, event)
+}
+
+// applyDispatch 依次执行出队转换阶段，返回是否执行了阶段
+func (p *eventPipeline) applyDispatch(ctx context.Context, event *Event) bool {
+	p.mu.RLock()
+	dispatches := p.dispatches
+	p.mu.RUnlock()
+	return applyStages(ctx, dispatches, event)
+}
+
+func applyStages(ctx context.Context, stages []*pipelineStage, event *Event) bool {
+	applied := false
+	for _, s := range stages {
+		if s.transform == nil {
+			continue
+		}
+		atomic.AddInt64(&s.events, 1)
+		s.transform.Apply(ctx, event)
+		applied = true
+	}
+	return applied
+}
+
+// keep 依次执行过滤阶段，返回事件是否保留和丢弃事件的阶段
+func (p *eventPipeline) keep(event *Event) (bool, string) {
+	p.mu.RLock()
+	filters := p.filters
+	p.mu.RUnlock()
+	for _, s := range filters {
+		if s.filter == nil {
+			continue
+		}
+		atomic.AddInt64(&s.events, 1)
+		if !s.filter.Keep(event) {
+			atomic.AddInt64(&s.dropped, 1)
+			return false, s.name
+		}
+	}
+	return true, ""
+}
+
+// stats 各阶段的统计，依次为转换、过滤和出队转换，按执行顺序排列
+func (p *eventPipeline) stats() []StageInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	infos := make([]StageInfo, 0, len(p.transforms)+len(p.filters)+len(p.dispatches))
+	for _, s := range p.transforms {
+		infos = append(infos, s.info("transform", s.transform != nil))
+	}
+	for _, s := range p.filters {
+		infos = append(infos, s.info("filter", s.filter != nil))
+	}
+	for _, s := range p.dispatches {
+		infos = append(infos, s.info("dispatch", s.transform != nil))
+	}
+	return infos
+}
+
+func (s *pipelineStage) info(kind string, enabled bool) StageInfo {
+	return StageInfo{
+		Name:    s.name,
+		Kind:    kind,
+		Enabled: enabled,
+		Events:  atomic.LoadInt64(&s.events),
+		Dropped: atomic.LoadInt64(&s.dropped),
+	}
+}
+
+// tableChangeStage 按任务统计表变更的转换阶段，不修改事件
+type tableChangeStage struct {
+	counter *TableChangeCounter
+	taskID  uint
+}
+
+// Apply 记录事件
+func (t tableChangeStage) Apply(ctx context.Context, event *Event) {
+	t.counter.Record(t.taskID, event)
+}
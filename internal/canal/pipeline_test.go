@@ -0,0 +1,113 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEventPipelineStages 测试内置阶段的顺序、自定义阶段的追加和原位替换
+func TestEventPipelineStages(t *testing.T) {
+	eventSink := NewDefaultEventSink(log.New(io.Discard, "", 0))
+
+	var order []string
+	eventSink.SetTransform("enrich", TransformFunc(func(ctx context.Context, event *Event) {
+		order = append(order, "enrich")
+	}))
+	eventSink.SetLargeValuePolicy(&LargeValuePolicy{mode: LargeValueTruncate, maxSize: 4})
+	eventSink.setChangeCounter(NewTableChangeCounter(), 1)
+
+	names := func() string {
+		var result []string
+		for _, stage := range eventSink.PipelineStats() {
+			if stage.Enabled {
+				result = append(result, stage.Kind+":"+stage.Name)
+			}
+		}
+		return strings.Join(result, ",")
+	}
+	want := "transform:content_hash,transform:table_changes,transform:enrich,dispatch:large_values"
+	if got := names(); got != want {
+		t.Fatalf("expected stages %s, got %s", want, got)
+	}
+
+	// 内容哈希和入队前的自定义阶段看到原始数据，大字段在出队后截断
+	event := &Event{Schema: "shop", Table: "orders", EventType: EventTypeInsert,
+		AfterData: &RowData{Columns: []Column{{Name: "note", Value: "gift wrapped"}}}}
+	eventSink.SetTransform("enrich", TransformFunc(func(ctx context.Context, event *Event) {
+		order = append(order, event.AfterData.Columns[0].Value.(string))
+	}))
+	eventSink.pipeline.apply(context.Background(), event)
+	full := *event
+	full.AfterData = &RowData{Columns: []Column{{Name: "note", Value: "gift wrapped"}}}
+	if event.ContentHash != ContentHash(&full) {
+		t.Error("content hash should be computed before large values are truncated")
+	}
+	if len(order) != 1 || order[0] != "gift wrapped" {
+		t.Errorf("replaced stage should run once on the original event, got %v", order)
+	}
+	if !eventSink.pipeline.applyDispatch(context.Background(), event) || !event.AfterData.Columns[0].Truncated {
+		t.Errorf("expected large values truncated after dequeue, got %+v", event.AfterData.Columns[0])
+	}
+
+	// 停用后保留位置，重新启用时顺序不变
+	eventSink.SetLargeValuePolicy(nil)
+	if eventSink.LargeValueStats()["policy"] != LargeValueNone {
+		t.Error("large value policy should be disabled")
+	}
+	eventSink.SetLargeValuePolicy(&LargeValuePolicy{mode: LargeValueTruncate, maxSize: 4})
+	if got := names(); got != want {
+		t.Errorf("expected stages %s after re-enabling, got %s", want, got)
+	}
+}
+
+// TestEventSinkPipelineFilters 测试过滤阶段按顺序丢弃事件，被丢弃的事件不送往处理器但位置照常确认
+func TestEventSinkPipelineFilters(t *testing.T) {
+	eventSink := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	eventSink.ctx = context.Background()
+	handler := &countingEventHandler{name: "webhook-1"}
+	eventSink.Subscribe("shop", "orders", handler)
+
+	// 每行每秒最多一个事件，事件相隔 10 秒，都被保留
+	sampler, err := NewEventSampler(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventSink.SetSampler(sampler)
+	eventSink.SetFilter("no_deletes", FilterFunc(func(event *Event) bool {
+		return event.EventType != EventTypeDelete
+	}))
+
+	for i, eventType := range []EventType{EventTypeInsert, EventTypeDelete, EventTypeUpdate} {
+		event := &Event{ID: string(eventType), Schema: "shop", Table: "orders", EventType: eventType,
+			Timestamp: time.Unix(int64(1717200000+10*i), 0),
+			Position:  Position{Name: "mysql-bin.000001", Pos: uint32(100 * (i + 1))}}
+		eventSink.processQueued(queuedEvent{event: event})
+	}
+	waitSinkLanes(t, eventSink)
+	if handler.count != 2 {
+		t.Errorf("expected 2 events delivered, got %d", handler.count)
+	}
+	if acked := eventSink.AckedPosition(); acked.Pos != 300 {
+		t.Errorf("expected position 300 acked, got %d", acked.Pos)
+	}
+
+	stats := map[string]StageInfo{}
+	for _, stage := range eventSink.PipelineStats() {
+		stats[stage.Name] = stage
+	}
+	if s := stats[StageSampling]; !s.Enabled || s.Events != 3 || s.Dropped != 0 {
+		t.Errorf("unexpected sampling stats %+v", s)
+	}
+	if s := stats["no_deletes"]; s.Kind != "filter" || s.Events != 3 || s.Dropped != 1 {
+		t.Errorf("unexpected filter stats %+v", s)
+	}
+
+	// 路由只选出订阅了该表的处理器
+	if routes := eventSink.Route(&Event{Schema: "shop", Table: "users"}); len(routes) != 0 {
+		t.Errorf("expected no routes for an unsubscribed table, got %d", len(routes))
+	}
+}
@@ -0,0 +1,240 @@
+package canal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// AlertPositionDrift 实例告警：持久化位置漂移且无法安全修正，需要人工处理
+const AlertPositionDrift = "position_drift"
+
+// 位置漂移类型
+const (
+	DriftRegression   = "regression"    // 持久化位置落后于已投递完成的位置，如异步保存乱序覆盖
+	DriftAheadOfRead  = "ahead_of_read" // 持久化位置超过当前读取位置
+	DriftBeyondSource = "beyond_source" // 持久化位置超过源库最新位置，如源库重置或切换
+	DriftPurged       = "purged"        // 持久化位置早于源库最早的 binlog
+)
+
+// PositionDrift 一次检查发现的位置漂移
+type PositionDrift struct {
+	Issues     []string  `json:"issues"`
+	Persisted  Position  `json:"persisted"`
+	Read       Position  `json:"read"`      // binlog 流当前读取位置
+	Acked      Position  `json:"acked"`     // 最近一个已被所有处理器处理完的事件
+	Committed  Position  `json:"committed"` // 已投递完成、可以安全保存的位置，见 CommittedPosition
+	Earliest   Position  `json:"earliest,omitempty"`
+	Latest     Position  `json:"latest,omitempty"`
+	Reconciled *Position `json:"reconciled,omitempty"` // 修正后保存的位置，无法安全修正时为空
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// ComparePositions 比较两个 binlog 位置，a 在前返回负数，相同返回 0，a 在后返回正数
+// 文件名按序号比较（mysql-bin.000010 在 mysql-bin.000009 之后），空文件名排在最前
+func ComparePositions(a, b Position) int {
+	if a.Name != b.Name {
+		if a.Name == "" {
+			return -1
+		}
+		if b.Name == "" {
+			return 1
+		}
+		aBase, aSeq, aOK := splitBinlogName(a.Name)
+		bBase, bSeq, bOK := splitBinlogName(b.Name)
+		if aOK && bOK && aBase == bBase && aSeq != bSeq {
+			if aSeq < bSeq {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	}
+
+	switch {
+	case a.Pos < b.Pos:
+		return -1
+	case a.Pos > b.Pos:
+		return 1
+	}
+	return 0
+}
+
+// ParsePosition 解析 "文件名:偏移" 形式的位置，如 mysql-bin.000003:1234
+func ParsePosition(value string) (Position, error) {
+	i := strings.LastIndexByte(value, ':')
+	if i <= 0 {
+		return Position{}, fmt.Errorf("invalid position %q, expected file:pos", value)
+	}
+	pos, err := strconv.ParseUint(value[i+1:], 10, 32)
+	if err != nil {
+		return Position{}, fmt.Errorf("invalid position %q: %v", value, err)
+	}
+	return Position{Name: value[:i], Pos: uint32(pos)}, nil
+}
+
+// splitBinlogName 拆分 binlog 文件名的前缀和序号
+func splitBinlogName(name string) (string, uint64, bool) {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseUint(name[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return name[:i], seq, true
+}
+
+// savePositionAsync 异步保存位置和各 sink 的进度，记录进行中的保存，漂移检查只在没有保存进行时比较
+func (m *MySQLBinlogSlave) savePositionAsync(pos Position, offsets map[string]Position) {
+	atomic.AddInt64(&m.savesStarted, 1)
+	go func() {
+		defer atomic.AddInt64(&m.savesFinished, 1)
+		m.saveSinkOffsets(offsets)
+		if err := m.metaManager.SavePosition(m.instanceID, pos); err != nil {
+			m.logger.Printf("❌ Failed to save binlog position: %v", err)
+		}
+	}()
+}
+
+// positionChecker 定期检查持久化位置是否漂移
+func (m *MySQLBinlogSlave) positionChecker() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.config.PositionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.CheckPositionDrift()
+		}
+	}
+}
+
+// CheckPositionDrift 比较持久化位置、读取位置、已处理事件位置和源库 binlog 范围，
+// 发现漂移时记录日志，并在可以安全修正时以最保守的位置覆盖持久化位置。
+// 返回本次发现的漂移，没有漂移或无法比较时返回 nil
+func (m *MySQLBinlogSlave) CheckPositionDrift() *PositionDrift {
+	if m.metaManager == nil {
+		return nil
+	}
+
+	// 有保存进行中时持久化位置还在变化，跳过本轮
+	started := atomic.LoadInt64(&m.savesStarted)
+	if atomic.LoadInt64(&m.savesFinished) != started {
+		return nil
+	}
+
+	acked := m.eventSink.AckedPosition()
+	persisted, err := m.metaManager.LoadPosition(m.instanceID)
+	if err != nil {
+		m.logger.Printf("⚠️ Failed to load persisted position for drift check: %v", err)
+		return nil
+	}
+	m.mu.Lock()
+	read := Position{Name: m.binlogPos.Name, Pos: m.binlogPos.Pos}
+	committed := m.committedPositionLocked()
+	saved := m.savedPos
+	m.mu.Unlock()
+
+	if atomic.LoadInt64(&m.savesStarted) != started || atomic.LoadInt64(&m.savesFinished) != started {
+		return nil
+	}
+
+	// 持久化位置就是最近保存的位置，只是之后投递完成的事件还没有新的 binlog 事件触发保存，
+	// 补存最新的投递位置，不算漂移
+	if persisted == saved && committed.Name != "" && ComparePositions(persisted, committed) < 0 {
+		if pos, err := m.savePositionSync(); err != nil {
+			m.logger.Printf("⚠️ Failed to save committed position for %s: %v", m.instanceID, err)
+		} else if pos.Name != "" {
+			persisted = pos
+		}
+	}
+	if persisted.Name == "" {
+		return nil
+	}
+
+	drift := &PositionDrift{Persisted: persisted, Read: read, Acked: acked, Committed: committed, DetectedAt: time.Now()}
+	if committed.Name != "" && ComparePositions(persisted, committed) < 0 {
+		drift.Issues = append(drift.Issues, DriftRegression)
+	}
+	if ComparePositions(persisted, read) > 0 {
+		drift.Issues = append(drift.Issues, DriftAheadOfRead)
+	}
+
+	// 源库不可达时只做本地比较
+	if earliest, err := m.queryEarliestPosition(); err != nil {
+		m.logger.Printf("⚠️ Failed to query earliest binlog for drift check: %v", err)
+	} else {
+		drift.Earliest = Position{Name: earliest.Name, Pos: earliest.Pos}
+		if ComparePositions(persisted, drift.Earliest) < 0 {
+			drift.Issues = append(drift.Issues, DriftPurged)
+		}
+	}
+	if latest, err := m.queryLatestPosition(); err != nil {
+		m.logger.Printf("⚠️ Failed to query latest binlog for drift check: %v", err)
+	} else {
+		drift.Latest = Position{Name: latest.Name, Pos: latest.Pos}
+		if ComparePositions(persisted, drift.Latest) > 0 {
+			drift.Issues = append(drift.Issues, DriftBeyondSource)
+		}
+	}
+
+	if len(drift.Issues) == 0 {
+		m.setPositionDrift(nil)
+		return nil
+	}
+
+	m.logger.Printf("⚠️ Position drift detected for %s: %v (persisted %s:%d, read %s:%d, acked %s:%d)",
+		m.instanceID, drift.Issues, persisted.Name, persisted.Pos, read.Name, read.Pos, acked.Name, acked.Pos)
+
+	if pos, ok := drift.conservativePosition(); ok {
+		if err := m.metaManager.SavePosition(m.instanceID, pos); err != nil {
+			m.logger.Printf("❌ Failed to save reconciled position: %v", err)
+		} else {
+			m.mu.Lock()
+			m.savedPos = pos
+			m.mu.Unlock()
+			drift.Reconciled = &pos
+			m.logger.Printf("🔧 Reconciled persisted position of %s to %s:%d", m.instanceID, pos.Name, pos.Pos)
+		}
+	} else {
+		m.logger.Printf("🚨 Position drift of %s cannot be reconciled safely, manual recovery required", m.instanceID)
+	}
+
+	m.setPositionDrift(drift)
+	return drift
+}
+
+// conservativePosition 修正用的位置：已投递完成的位置、已处理事件与读取位置中最早的一个，不超过源库最新位置。
+// 该位置之前的事件都已投递，从这里重新读取最多产生重复，不会丢事件；
+// 早于源库最早的 binlog 时无法安全修正
+func (d *PositionDrift) conservativePosition() (Position, bool) {
+	pos := d.Read
+	if d.Acked.Name != "" && ComparePositions(d.Acked, pos) < 0 {
+		pos = d.Acked
+	}
+	if d.Committed.Name != "" && ComparePositions(d.Committed, pos) < 0 {
+		pos = d.Committed
+	}
+	if d.Latest.Name != "" && ComparePositions(pos, d.Latest) > 0 {
+		pos = d.Latest
+	}
+	if d.Earliest.Name != "" && ComparePositions(pos, d.Earliest) < 0 {
+		return Position{}, false
+	}
+	return pos, pos.Name != ""
+}
+
+// setPositionDrift 记录最近一次检查的漂移，检查正常时清除
+func (m *MySQLBinlogSlave) setPositionDrift(drift *PositionDrift) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.positionDrift = drift
+}
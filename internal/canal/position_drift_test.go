@@ -0,0 +1,111 @@
+package canal
+
+import (
+	"io"
+	"log"
+	"sync"
+	"testing"
+)
+
+// memoryMetaManager 内存中的元数据管理器
+type memoryMetaManager struct {
+	mu        sync.Mutex
+	positions map[string]Position
+}
+
+func (m *memoryMetaManager) SavePosition(instanceID string, pos Position) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.positions[instanceID] = pos
+	return nil
+}
+
+func (m *memoryMetaManager) LoadPosition(instanceID string) (Position, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.positions[instanceID], nil
+}
+
+func (m *memoryMetaManager) SaveTableMeta(schema, table string, meta *TableMeta) error { return nil }
+func (m *memoryMetaManager) LoadTableMeta(schema, table string) (*TableMeta, error)    { return nil, nil }
+
+// TestComparePositions 测试按 binlog 文件序号和偏移比较位置
+func TestComparePositions(t *testing.T) {
+	cases := []struct {
+		a, b Position
+		want int
+	}{
+		{Position{Name: "mysql-bin.000001", Pos: 4}, Position{Name: "mysql-bin.000001", Pos: 4}, 0},
+		{Position{Name: "mysql-bin.000001", Pos: 100}, Position{Name: "mysql-bin.000001", Pos: 4}, 1},
+		{Position{Name: "mysql-bin.000009", Pos: 900}, Position{Name: "mysql-bin.000010", Pos: 4}, -1},
+		{Position{Name: "mysql-bin.99", Pos: 4}, Position{Name: "mysql-bin.100", Pos: 4}, -1},
+		{Position{}, Position{Name: "mysql-bin.000001", Pos: 4}, -1},
+	}
+	for _, c := range cases {
+		if got := ComparePositions(c.a, c.b); got != c.want {
+			t.Errorf("ComparePositions(%v, %v) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+// TestCheckPositionDrift 测试异步保存乱序导致的回退被发现并修正为已处理事件的位置
+func TestCheckPositionDrift(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	meta := &memoryMetaManager{positions: make(map[string]Position)}
+	sink := NewDefaultEventSink(logger)
+	// 源库不可达，只做本地比较
+	slave, err := NewMySQLBinlogSlaveWithMeta(MySQLConfig{Host: "127.0.0.1", Port: 1, ServerID: 1001}, sink, logger, meta)
+	if err != nil {
+		t.Fatalf("Failed to create binlog slave: %v", err)
+	}
+	slave.binlogPos.Name, slave.binlogPos.Pos = "mysql-bin.000003", 900
+	sink.ack(Position{Name: "mysql-bin.000003", Pos: 800})
+
+	// 没有漂移
+	meta.SavePosition(slave.instanceID, Position{Name: "mysql-bin.000003", Pos: 900})
+	if drift := slave.CheckPositionDrift(); drift != nil {
+		t.Fatalf("expected no drift, got %+v", drift)
+	}
+
+	// 较旧的保存覆盖了较新的位置
+	meta.SavePosition(slave.instanceID, Position{Name: "mysql-bin.000002", Pos: 500})
+	drift := slave.CheckPositionDrift()
+	if drift == nil || len(drift.Issues) != 1 || drift.Issues[0] != DriftRegression {
+		t.Fatalf("expected regression, got %+v", drift)
+	}
+	want := Position{Name: "mysql-bin.000003", Pos: 800}
+	if drift.Reconciled == nil || *drift.Reconciled != want {
+		t.Errorf("expected reconcile to acked position %v, got %+v", want, drift.Reconciled)
+	}
+	if pos, _ := meta.LoadPosition(slave.instanceID); pos != want {
+		t.Errorf("expected persisted position %v, got %v", want, pos)
+	}
+	if stats := slave.GetStats(); stats["position_drift"] != drift {
+		t.Errorf("expected drift in stats")
+	}
+
+	// 修正后检查恢复正常
+	if drift := slave.CheckPositionDrift(); drift != nil {
+		t.Errorf("expected no drift after reconcile, got %+v", drift)
+	}
+	if _, ok := slave.GetStats()["position_drift"]; ok {
+		t.Errorf("expected drift to be cleared")
+	}
+}
+
+// TestConservativePosition 测试修正位置受源库 binlog 范围约束
+func TestConservativePosition(t *testing.T) {
+	drift := &PositionDrift{
+		Read:   Position{Name: "mysql-bin.000005", Pos: 100},
+		Acked:  Position{Name: "mysql-bin.000004", Pos: 300},
+		Latest: Position{Name: "mysql-bin.000004", Pos: 200},
+	}
+	if pos, ok := drift.conservativePosition(); !ok || pos != drift.Latest {
+		t.Errorf("expected position capped at source latest, got %v, %v", pos, ok)
+	}
+
+	drift.Earliest = Position{Name: "mysql-bin.000006", Pos: 4}
+	if _, ok := drift.conservativePosition(); ok {
+		t.Errorf("expected no safe position before earliest binlog")
+	}
+}
@@ -0,0 +1,267 @@
+package canal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	"pikachun/internal/config"
+)
+
+// ErrTimeBeforeBinlogs 查找的时间点早于源库上最早的 binlog，之前的事件已被清除
+var ErrTimeBeforeBinlogs = errors.New("time is before the earliest available binlog")
+
+// LocatedPosition 时间点对应的 binlog 位置
+type LocatedPosition struct {
+	At            time.Time  `json:"at" format:"date-time"`                   // 查找的时间点，精确到秒（binlog 事件时间戳的精度）
+	Position      Position   `json:"position"`                                // 可直接作为读取位置，gtid_set 为该位置之前已执行的 GTID 集合（源库开启 GTID 时）
+	EventTime     *time.Time `json:"event_time,omitempty" format:"date-time"` // 该位置事务的时间戳，Latest 时为空
+	Latest        bool       `json:"latest"`                                  // at 之后还没有事务，位置为源库的最新位置
+	ScannedFiles  int        `json:"scanned_files"`                           // 逐个事件扫描的文件数
+	ScannedEvents int        `json:"scanned_events"`
+}
+
+// binlogReader 按文件读取源库的 binlog 事件。end 不为 0 时读到该位置为止，否则读到文件末尾；
+// fn 返回 false 时停止读取
+type binlogReader interface {
+	readFile(ctx context.Context, file string, end uint32, fn func(ev *replication.BinlogEvent) bool) error
+}
+
+// LocatePosition 查找源库上时间点 at 对应的 binlog 位置：第一个时间戳不早于 at 的事务的起始位置，
+// 以及该事务之前已执行的 GTID 集合。先按各文件第一个事件的时间二分查找所在的文件，再逐个事件扫描该文件，
+// 扫描会读取整个文件，文件较大时需要一些时间。at 早于最早的 binlog 时返回 ErrTimeBeforeBinlogs
+func LocatePosition(ctx context.Context, cfg config.CanalConfig, at time.Time) (*LocatedPosition, error) {
+	if cfg.ServerID == 0 {
+		return nil, fmt.Errorf("canal.server_id is required to read binlog")
+	}
+	status, err := QuerySourceStatus(cfg)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := NewSourceConnection(cfg.Connection)
+	if err != nil {
+		return nil, fmt.Errorf("invalid canal.connection config: %v", err)
+	}
+	return locatePosition(ctx, &syncerBinlogReader{cfg: cfg, conn: conn}, status, at)
+}
+
+// locatePosition 在 status 列出的 binlog 文件中查找时间点 at 对应的位置
+func locatePosition(ctx context.Context, reader binlogReader, status *SourceStatus, at time.Time) (*LocatedPosition, error) {
+	logs := status.BinaryLogs
+	if len(logs) == 0 {
+		return nil, fmt.Errorf("no binary logs available on source")
+	}
+	// binlog 事件的时间戳只到秒，同一秒内较早的事务也应包含在内
+	at = at.Truncate(time.Second)
+	end := func(i int) uint32 {
+		if logs[i].Name == status.File {
+			return status.Pos
+		}
+		return 0
+	}
+	startTime := func(i int) (time.Time, error) {
+		var start time.Time
+		err := reader.readFile(ctx, logs[i].Name, end(i), func(ev *replication.BinlogEvent) bool {
+			start = time.Unix(int64(ev.Header.Timestamp), 0)
+			return false
+		})
+		if err == nil && start.IsZero() {
+			err = fmt.Errorf("binlog %s has no events", logs[i].Name)
+		}
+		return start, err
+	}
+
+	earliest, err := startTime(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binlog %s: %v", logs[0].Name, err)
+	}
+	if at.Before(earliest) {
+		return nil, fmt.Errorf("%w: %s starts at %s", ErrTimeBeforeBinlogs, logs[0].Name, earliest.UTC().Format(time.RFC3339))
+	}
+
+	// 最后一个开始时间不晚于 at 的文件
+	lo, hi := 0, len(logs)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		start, err := startTime(mid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read binlog %s: %v", logs[mid].Name, err)
+		}
+		if start.After(at) {
+			hi = mid - 1
+		} else {
+			lo = mid
+		}
+	}
+
+	// 该文件中没有不早于 at 的事务时，结果是之后文件的第一个事务
+	located := &LocatedPosition{At: at}
+	locator := &positionLocator{at: at}
+	for i := lo; i < len(logs); i++ {
+		locator.file = logs[i].Name
+		located.ScannedFiles++
+		if err := reader.readFile(ctx, logs[i].Name, end(i), locator.feed); err != nil {
+			return nil, fmt.Errorf("failed to scan binlog %s: %v", logs[i].Name, err)
+		}
+		if locator.found {
+			located.Position = locator.position
+			located.EventTime = &locator.eventTime
+			located.ScannedEvents = locator.events
+			return located, nil
+		}
+	}
+
+	located.Position = Position{Name: status.File, Pos: status.Pos, GTIDSet: locator.gtidString()}
+	located.Latest = true
+	located.ScannedEvents = locator.events
+	return located, nil
+}
+
+// positionLocator 逐个事件查找第一个时间戳不早于 at 的事务，同时累计之前已执行的 GTID 集合
+type positionLocator struct {
+	at      time.Time
+	file    string
+	gtidSet mysql.GTIDSet
+	inTxn   bool // 在事务中，之后的事件不是事务的开始
+	begun   bool // 事务由 BEGIN（或 MariaDB 非独立的 GTID 事件）开始，到 COMMIT 或 XID 结束
+	events  int
+
+	found     bool
+	position  Position
+	eventTime time.Time
+}
+
+// feed 处理一个事件，找到目标事务时返回 false 停止读取
+func (l *positionLocator) feed(ev *replication.BinlogEvent) bool {
+	l.events++
+	switch e := ev.Event.(type) {
+	case *replication.PreviousGTIDsEvent:
+		l.setGTIDSet(mysql.MySQLFlavor, e.GTIDSets)
+	case *replication.MariadbGTIDListEvent:
+		gtids := make([]string, 0, len(e.GTIDs))
+		for i := range e.GTIDs {
+			gtids = append(gtids, e.GTIDs[i].String())
+		}
+		l.setGTIDSet(mysql.MariaDBFlavor, strings.Join(gtids, ","))
+	case *replication.GTIDEvent:
+		if l.begin(ev.Header) {
+			return false
+		}
+		l.inTxn, l.begun = true, false
+		// 匿名 GTID 事件（gtid_mode=OFF）没有 GTID
+		if ev.Header.EventType == replication.GTID_EVENT {
+			if next, err := e.GTIDNext(); err == nil {
+				l.addGTID(mysql.MySQLFlavor, next.String())
+			}
+		}
+	case *replication.MariadbGTIDEvent:
+		if l.begin(ev.Header) {
+			return false
+		}
+		l.inTxn, l.begun = true, !e.IsStandalone()
+		l.addGTID(mysql.MariaDBFlavor, e.GTID.String())
+	case *replication.QueryEvent:
+		// 没有 GTID 事件（MySQL 5.6 关闭 GTID）时事务从 BEGIN 或 DDL 语句开始
+		if !l.inTxn {
+			if l.begin(ev.Header) {
+				return false
+			}
+			l.inTxn = true
+		}
+		switch query := strings.ToUpper(strings.TrimSpace(string(e.Query))); {
+		case query == "BEGIN":
+			l.begun = true
+		case query == "COMMIT" || !l.begun:
+			l.inTxn, l.begun = false, false
+		}
+	case *replication.XIDEvent:
+		l.inTxn, l.begun = false, false
+	}
+	return true
+}
+
+// begin 遇到事务的第一个事件：时间戳不早于 at 时记录该事务的起始位置，返回 true
+func (l *positionLocator) begin(header *replication.EventHeader) bool {
+	eventTime := time.Unix(int64(header.Timestamp), 0).UTC()
+	if eventTime.Before(l.at) {
+		return false
+	}
+	l.found = true
+	l.position = Position{Name: l.file, Pos: header.LogPos - header.EventSize, GTIDSet: l.gtidString()}
+	l.eventTime = eventTime
+	return true
+}
+
+// setGTIDSet 每个文件开头的 PREVIOUS_GTIDS（MariaDB 为 GTID_LIST）事件给出该文件之前已执行的集合
+func (l *positionLocator) setGTIDSet(flavor, set string) {
+	if parsed, err := mysql.ParseGTIDSet(flavor, set); err == nil {
+		l.gtidSet = parsed
+	}
+}
+
+// addGTID 把事务的 GTID 并入已执行集合
+func (l *positionLocator) addGTID(flavor, gtid string) {
+	if l.gtidSet == nil || gtidSetFlavor(l.gtidSet) != flavor {
+		l.gtidSet, _ = mysql.ParseGTIDSet(flavor, "")
+	}
+	l.gtidSet.Update(gtid)
+}
+
+// gtidString 已执行集合的文本形式，源库未开启 GTID 时为空
+func (l *positionLocator) gtidString() string {
+	if l.gtidSet == nil {
+		return ""
+	}
+	return l.gtidSet.String()
+}
+
+// syncerBinlogReader 通过复制连接读取源库的 binlog，与实例使用同一个 server_id（各连接的 replica_uuid 不同）
+type syncerBinlogReader struct {
+	cfg  config.CanalConfig
+	conn SourceConnection
+}
+
+func (r *syncerBinlogReader) readFile(ctx context.Context, file string, end uint32, fn func(ev *replication.BinlogEvent) bool) error {
+	syncer := replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		ServerID:        r.cfg.ServerID,
+		Flavor:          "mysql",
+		Host:            r.cfg.Host,
+		Port:            uint16(r.cfg.Port),
+		User:            r.cfg.Username,
+		Password:        r.cfg.Password,
+		HeartbeatPeriod: r.conn.HeartbeatPeriod,
+		ReadTimeout:     r.conn.ReadTimeout,
+		Dialer:          r.conn.dialer(),
+		Charset:         "utf8mb4",
+		// 只需要事件头、GTID 和语句，跳过行数据的解析
+		RowsEventDecodeFunc: func(*replication.RowsEvent, []byte) error { return nil },
+	})
+	defer syncer.Close()
+
+	streamer, err := syncer.StartSync(mysql.Position{Name: file, Pos: 4})
+	if err != nil {
+		return err
+	}
+	for {
+		ev, err := streamer.GetEvent(ctx)
+		if err != nil {
+			return err
+		}
+		// 开始时伪造的 ROTATE 等事件位置为 0，不属于文件内容
+		if ev.Header.LogPos == 0 {
+			continue
+		}
+		if !fn(ev) {
+			return nil
+		}
+		// 文件末尾的 ROTATE 事件之后是下一个文件；当前文件读到查询时的最新位置为止
+		if ev.Header.EventType == replication.ROTATE_EVENT || (end > 0 && ev.Header.LogPos >= end) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package canal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// fixtureBinlogReader 从语料目录读取 binlog 文件，代替复制连接
+type fixtureBinlogReader struct {
+	dir   string
+	reads []string
+}
+
+var errStopReading = errors.New("stop reading")
+
+func (r *fixtureBinlogReader) readFile(ctx context.Context, file string, end uint32, fn func(ev *replication.BinlogEvent) bool) error {
+	r.reads = append(r.reads, file)
+	err := replication.NewBinlogParser().ParseFile(filepath.Join(r.dir, file), 4, func(ev *replication.BinlogEvent) error {
+		if !fn(ev) || (end > 0 && ev.Header.LogPos >= end) {
+			return errStopReading
+		}
+		return nil
+	})
+	if errors.Is(err, errStopReading) {
+		return nil
+	}
+	return err
+}
+
+// fixtureSourceStatus 以语料目录中的文件作为源库上的 binlog，最新位置为最后一个文件的末尾
+func fixtureSourceStatus(t *testing.T, dir string) *SourceStatus {
+	files, err := filepath.Glob(filepath.Join(dir, "*-bin.[0-9]*"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no binlog files in %s: %v", dir, err)
+	}
+	sort.Strings(files)
+	status := &SourceStatus{}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status.BinaryLogs = append(status.BinaryLogs, BinaryLog{Name: filepath.Base(file), Size: info.Size()})
+	}
+	last := status.BinaryLogs[len(status.BinaryLogs)-1]
+	status.File, status.Pos = last.Name, uint32(last.Size)
+	return status
+}
+
+// TestLocatePosition 测试按时间点找到事务的起始位置和之前已执行的 GTID 集合
+func TestLocatePosition(t *testing.T) {
+	const mysqlUUID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	tests := []struct {
+		name   string
+		dir    string
+		at     int64
+		want   Position
+		latest bool
+	}{
+		// 第一个文件开始之后、第一个事务之前
+		{"mysql 8.0 first transaction", "mysql-8.0.36", 1717200000, Position{Name: "mysql-bin.000001", Pos: 197, GTIDSet: mysqlUUID + ":1-10"}, false},
+		{"mysql 8.0 gtid", "mysql-8.0.36", 1717200002, Position{Name: "mysql-bin.000001", Pos: 997, GTIDSet: mysqlUUID + ":1-11"}, false},
+		{"mysql 8.0 ddl", "mysql-8.0.36", 1717200004, Position{Name: "mysql-bin.000001", Pos: 2318, GTIDSet: mysqlUUID + ":1-13"}, false},
+		{"mysql 8.0 after last transaction", "mysql-8.0.36", 1717200100, Position{Name: "mysql-bin.000001", Pos: 2506, GTIDSet: mysqlUUID + ":1-14"}, true},
+		// 匿名 GTID，没有 GTID 集合
+		{"mysql 5.7 first file", "mysql-5.7.44", 1577836802, Position{Name: "mysql-bin.000001", Pos: 509}, false},
+		{"mysql 5.7 second file", "mysql-5.7.44", 1577836803, Position{Name: "mysql-bin.000002", Pos: 154}, false},
+		{"mariadb standalone ddl", "mariadb-10.6.16", 1700000021, Position{Name: "mariadb-bin.000001", Pos: 685, GTIDSet: "0-1-101"}, false},
+		{"mariadb transaction", "mariadb-10.6.16", 1700000022, Position{Name: "mariadb-bin.000001", Pos: 838, GTIDSet: "0-1-102"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join("testdata", "binlog", tt.dir)
+			located, err := locatePosition(context.Background(), &fixtureBinlogReader{dir: dir}, fixtureSourceStatus(t, dir), time.Unix(tt.at, 0))
+			if err != nil {
+				t.Fatalf("locatePosition failed: %v", err)
+			}
+			if located.Position != tt.want || located.Latest != tt.latest {
+				t.Fatalf("expected %+v (latest=%v), got %+v (latest=%v)", tt.want, tt.latest, located.Position, located.Latest)
+			}
+			if !tt.latest && (located.EventTime == nil || located.EventTime.Unix() < tt.at) {
+				t.Errorf("event time %v should not be before %d", located.EventTime, tt.at)
+			}
+		})
+	}
+}
+
+// TestLocatePositionBinarySearch 测试按文件开始时间只扫描目标所在的文件，以及早于最早 binlog 的时间点
+func TestLocatePositionBinarySearch(t *testing.T) {
+	dir := filepath.Join("testdata", "binlog", "mysql-5.7.44")
+	status := fixtureSourceStatus(t, dir)
+
+	// 不足一秒的部分舍去，同一秒的事务包含在内
+	reader := &fixtureBinlogReader{dir: dir}
+	located, err := locatePosition(context.Background(), reader, status, time.Unix(1577836804, 500_000_000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if located.Position.Name != "mysql-bin.000002" || located.Position.Pos != 154 || located.ScannedFiles != 1 {
+		t.Errorf("unexpected result %+v", located)
+	}
+	// 两次读取文件开头确定所在文件，一次扫描
+	if len(reader.reads) != 3 || reader.reads[2] != "mysql-bin.000002" {
+		t.Errorf("unexpected reads %v", reader.reads)
+	}
+
+	_, err = locatePosition(context.Background(), &fixtureBinlogReader{dir: dir}, status, time.Unix(1577836799, 0))
+	if !errors.Is(err, ErrTimeBeforeBinlogs) {
+		t.Errorf("expected ErrTimeBeforeBinlogs, got %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+package canal
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// 预检项的级别
+const (
+	PreflightError   = "error"   // 未通过时无法读取 binlog 或事件不完整，阻止创建任务
+	PreflightWarning = "warning" // 可以运行，但部分功能受影响
+)
+
+// PreflightCheck 一项预检结果，未通过时 Message 给出原因和处理方法
+type PreflightCheck struct {
+	Name    string `json:"name"`                        // 检查项，如 privilege:REPLICATION SLAVE、select:shop.orders、binlog_format
+	Level   string `json:"level" enums:"error,warning"` // error 未通过时拒绝创建任务；warning 可以运行但部分功能受影响
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"` // 未通过的原因和处理方法
+}
+
+// PreflightReport 源库预检报告
+type PreflightReport struct {
+	Passed bool             `json:"passed"` // 所有 error 级检查都通过
+	Checks []PreflightCheck `json:"checks"`
+
+	Binlog *BinlogSettings `json:"binlog,omitempty"` // 查询到的源库 binlog 配置
+}
+
+// Err 未通过的 error 级检查合并为一个错误，全部通过时返回 nil
+func (r *PreflightReport) Err() error {
+	var failed []string
+	for _, check := range r.Checks {
+		if !check.Passed && check.Level == PreflightError {
+			failed = append(failed, check.Message)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(failed, "; "))
+}
+
+// add 追加一项检查
+func (r *PreflightReport) add(name, level string, passed bool, message string) {
+	if passed {
+		message = ""
+	} else if level == PreflightError {
+		r.Passed = false
+	}
+	r.Checks = append(r.Checks, PreflightCheck{Name: name, Level: level, Passed: passed, Message: message})
+}
+
+// preflightFacts 从源库查询到的预检所需信息
+type preflightFacts struct {
+	user       string
+	grants     []string
+	unresolved []string // 无法查询到权限的角色，这些角色授予的权限无法确认
+	binlog     BinlogSettings
+	serverID   uint32   // 源库自身的 server_id
+	replicaIDs []uint32 // 已连接到源库的复制客户端 server_id，查询失败时为 nil
+}
+
+// RunPreflight 检查 cfg 中的账号能否读取 tables（库.表，表名可以是通配规则）的 binlog：
+// 复制权限、表的 SELECT 权限、binlog 配置（见 BinlogSettings.check）和 server_id 是否冲突。
+// ownInstances 表示本服务已有实例以 cfg.ServerID 连接源库，此时复制客户端中出现该 ID 不算冲突
+func RunPreflight(cfg config.CanalConfig, tables []string, ownInstances bool) *PreflightReport {
+	facts, err := queryPreflightFacts(cfg)
+	if err != nil {
+		report := &PreflightReport{Passed: true}
+		report.add("connection", PreflightError, false,
+			fmt.Sprintf("无法以 %s 连接源库 %s:%d: %v，请检查地址、账号和网络", cfg.Username, cfg.Host, cfg.Port, err))
+		return report
+	}
+	// 配置无效时实例无法创建，这里按默认设置检查
+	checksum, err := NewBinlogChecksum(cfg.Binlog.Checksum)
+	if err != nil {
+		checksum = DefaultBinlogChecksum()
+	}
+	return evaluatePreflight(facts, cfg.ServerID, ParseMinBinlogRetention(cfg.MinBinlogRetention), checksum, tables, ownInstances)
+}
+
+// ParseMinBinlogRetention 解析 canal.min_binlog_retention，为空或无效时使用 24h
+func ParseMinBinlogRetention(value string) time.Duration {
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention < 0 {
+		return 24 * time.Hour
+	}
+	return retention
+}
+
+// queryPreflightFacts 查询源库的授权、binlog 配置和复制客户端
+func queryPreflightFacts(cfg config.CanalConfig) (*preflightFacts, error) {
+	dsn := SourceDSN(cfg.Username, cfg.Password, cfg.Host, cfg.Port, "charset=utf8mb4&timeout=5s")
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	facts := &preflightFacts{}
+	if err := db.QueryRow("SELECT CURRENT_USER()").Scan(&facts.user); err != nil {
+		return nil, fmt.Errorf("failed to query current user: %v", err)
+	}
+	if facts.grants, err = queryStrings(db, "SHOW GRANTS FOR CURRENT_USER()"); err != nil {
+		return nil, fmt.Errorf("failed to query grants: %v", err)
+	}
+	facts.grants, facts.unresolved = expandRoleGrants(db, facts.grants, grantedRoles(facts.grants))
+
+	settings, err := QueryBinlogSettings(db)
+	if err != nil {
+		return nil, err
+	}
+	facts.binlog = *settings
+	if err := db.QueryRow("SELECT @@GLOBAL.server_id").Scan(&facts.serverID); err != nil {
+		return nil, fmt.Errorf("failed to query server_id: %v", err)
+	}
+
+	// MySQL 8.0.22 起为 SHOW REPLICAS，两者第一列都是 server_id；需要 REPLICATION SLAVE 权限
+	for _, query := range []string{"SHOW REPLICAS", "SHOW SLAVE HOSTS"} {
+		ids, err := queryStrings(db, query)
+		if err != nil {
+			continue
+		}
+		facts.replicaIDs = []uint32{}
+		for _, id := range ids {
+			if parsed, err := strconv.ParseUint(id, 10, 32); err == nil {
+				facts.replicaIDs = append(facts.replicaIDs, uint32(parsed))
+			}
+		}
+		break
+	}
+	return facts, nil
+}
+
+// expandRoleGrants 加上角色授予的权限：MySQL 8 用 SHOW GRANTS ... USING 一次查询，
+// 不支持时（如 MariaDB）逐个查询角色的授权。返回合并后的授权和无法查询的角色
+func expandRoleGrants(db *sql.DB, grants, roles []string) ([]string, []string) {
+	if len(roles) == 0 {
+		return grants, nil
+	}
+	if expanded, err := queryStrings(db, "SHOW GRANTS FOR CURRENT_USER() USING "+strings.Join(roles, ", ")); err == nil {
+		return expanded, nil
+	}
+	var unresolved []string
+	for _, role := range roles {
+		lines, err := queryStrings(db, "SHOW GRANTS FOR "+role)
+		if err != nil {
+			unresolved = append(unresolved, role)
+			continue
+		}
+		grants = append(grants, lines...)
+	}
+	return grants, unresolved
+}
+
+// queryStrings 查询每行第一列的值
+func queryStrings(db *sql.DB, query string) ([]string, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var result []string
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		result = append(result, string(values[0]))
+	}
+	return result, rows.Err()
+}
+
+// evaluatePreflight 根据查询到的信息逐项检查
+func evaluatePreflight(facts *preflightFacts, serverID uint32, minRetention time.Duration, checksum BinlogChecksum, tables []string, ownInstances bool) *PreflightReport {
+	report := &PreflightReport{Passed: true, Binlog: &facts.binlog}
+	report.add("connection", PreflightError, true, "")
+
+	account := quoteAccount(facts.user)
+	grants := parseGrants(facts.grants)
+	// 有无法查询权限的角色时，缺少的权限可能来自这些角色，只作为警告
+	privilegeLevel, roleHint := PreflightError, ""
+	if len(facts.unresolved) > 0 {
+		privilegeLevel = PreflightWarning
+		roleHint = fmt.Sprintf("（账号的角色 %s 的权限无法查询，若已通过角色授权并激活可忽略）", strings.Join(facts.unresolved, ", "))
+	}
+	for _, privilege := range []string{"REPLICATION SLAVE", "REPLICATION CLIENT"} {
+		report.add("privilege:"+privilege, privilegeLevel, hasPrivilege(grants, privilege, "*", "*"),
+			fmt.Sprintf("账号 %s 缺少 %s 权限，请执行 GRANT %s ON *.* TO %s%s", account, privilege, privilege, account, roleHint))
+	}
+	for _, table := range tables {
+		schema, name, ok := strings.Cut(table, ".")
+		if !ok {
+			continue
+		}
+		// 通配规则需要整库的权限
+		if strings.ContainsAny(schema, "*?") {
+			schema = "*"
+		}
+		if strings.ContainsAny(name, "*?") {
+			name = "*"
+		}
+		report.add("select:"+table, privilegeLevel, hasPrivilege(grants, "SELECT", schema, name),
+			fmt.Sprintf("账号 %s 缺少 %s 的 SELECT 权限（读取表结构和快照需要），请执行 GRANT SELECT ON %s TO %s%s",
+				account, table, grantTarget(schema, name), account, roleHint))
+	}
+
+	facts.binlog.check(report, minRetention)
+	facts.binlog.checkChecksum(report, checksum)
+
+	report.add("server_id", PreflightError, serverID != 0 && serverID != facts.serverID,
+		fmt.Sprintf("canal.server_id (%d) 不能为 0 或与源库自身的 server_id (%d) 相同，请修改 canal.server_id", serverID, facts.serverID))
+	if facts.replicaIDs == nil {
+		report.add("server_id_unique", PreflightWarning, false, "无法查询源库的复制客户端，未检查 server_id 是否与其他从库冲突")
+	} else {
+		conflict := false
+		for _, id := range facts.replicaIDs {
+			if id == serverID && !ownInstances {
+				conflict = true
+			}
+		}
+		report.add("server_id_unique", PreflightError, !conflict,
+			fmt.Sprintf("已有复制客户端以 server_id %d 连接源库，同一 ID 的连接会互相断开，请修改 canal.server_id", serverID))
+	}
+	return report
+}
+
+// quoteAccount 将 CURRENT_USER() 的 user@host 转为 GRANT 语句中的 'user'@'host'
+func quoteAccount(user string) string {
+	i := strings.LastIndexByte(user, '@')
+	if i < 0 {
+		return "'" + user + "'"
+	}
+	return "'" + user[:i] + "'@'" + user[i+1:] + "'"
+}
+
+// grantTarget GRANT 语句中的库表
+func grantTarget(schema, table string) string {
+	if schema == "*" {
+		return "*.*"
+	}
+	if table == "*" {
+		return "`" + schema + "`.*"
+	}
+	return "`" + schema + "`.`" + table + "`"
+}
+
+// grant SHOW GRANTS 中的一条授权
+type grant struct {
+	privileges []string
+	schema     string // * 表示全部
+	table      string // * 表示全部
+}
+
+// parseGrants 解析 SHOW GRANTS 的结果，忽略角色授权等不含 ON 的语句
+func parseGrants(lines []string) []grant {
+	var grants []grant
+	for _, line := range lines {
+		upper := strings.ToUpper(line)
+		on := strings.Index(upper, " ON ")
+		if !strings.HasPrefix(upper, "GRANT ") || on < 0 {
+			continue
+		}
+		privileges := upper[len("GRANT "):on]
+		// 库表名区分大小写，从原始语句中截取
+		target, _, _ := strings.Cut(line[on+len(" ON "):], " ")
+		schema, table, ok := strings.Cut(target, ".")
+		if !ok {
+			continue
+		}
+
+		g := grant{schema: unquoteIdentifier(schema), table: unquoteIdentifier(table)}
+		for _, privilege := range strings.Split(privileges, ",") {
+			g.privileges = append(g.privileges, strings.TrimSpace(privilege))
+		}
+		grants = append(grants, g)
+	}
+	return grants
+}
+
+// grantedRoles 解析 SHOW GRANTS 中的角色授权（GRANT 角色 TO 账号），返回语句中的角色名
+func grantedRoles(lines []string) []string {
+	var roles []string
+	for _, line := range lines {
+		upper := strings.ToUpper(line)
+		to := strings.Index(upper, " TO ")
+		if !strings.HasPrefix(upper, "GRANT ") || to < 0 || strings.Contains(upper[:to], " ON ") {
+			continue
+		}
+		for _, role := range strings.Split(line[len("GRANT "):to], ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
+
+// unquoteIdentifier 去掉标识符的引号和通配符转义
+func unquoteIdentifier(name string) string {
+	name = strings.Trim(name, "`'\"")
+	return strings.NewReplacer(`\_`, "_", `\%`, "%").Replace(name)
+}
+
+// privilegeAliases 权限在其他版本中的名称：MariaDB 10.5 起 REPLICATION CLIENT 更名为 BINLOG MONITOR
+var privilegeAliases = map[string]string{
+	"REPLICATION CLIENT": "BINLOG MONITOR",
+}
+
+// hasPrivilege 是否有覆盖 schema.table 的 privilege（或其别名）或 ALL PRIVILEGES 授权，schema 为 * 表示需要全局授权
+func hasPrivilege(grants []grant, privilege, schema, table string) bool {
+	alias := privilegeAliases[privilege]
+	for _, g := range grants {
+		covers := g.schema == "*" ||
+			(g.schema == schema && (g.table == "*" || g.table == table))
+		if !covers {
+			continue
+		}
+		for _, p := range g.privileges {
+			if p == privilege || (alias != "" && p == alias) || p == "ALL" || p == "ALL PRIVILEGES" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package canal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// healthyFacts 满足所有检查的源库
+func healthyFacts() *preflightFacts {
+	return &preflightFacts{
+		user: "repl@%",
+		grants: []string{
+			"GRANT SELECT, REPLICATION SLAVE, REPLICATION CLIENT ON *.* TO `repl`@`%`",
+		},
+		binlog: BinlogSettings{
+			LogBin:    true,
+			Format:    "ROW",
+			RowImage:  "FULL",
+			Retention: 7 * 86400,
+		},
+		serverID:   1,
+		replicaIDs: []uint32{2},
+	}
+}
+
+// failedChecks 未通过的检查名
+func failedChecks(report *PreflightReport) []string {
+	var names []string
+	for _, check := range report.Checks {
+		if !check.Passed {
+			names = append(names, check.Name)
+		}
+	}
+	return names
+}
+
+// TestEvaluatePreflight 测试各项预检
+func TestEvaluatePreflight(t *testing.T) {
+	report := evaluatePreflight(healthyFacts(), 1001, 24*time.Hour, DefaultBinlogChecksum(), []string{"shop.orders"}, false)
+	if !report.Passed || report.Err() != nil || len(failedChecks(report)) != 0 {
+		t.Fatalf("healthy source should pass, failed: %v", failedChecks(report))
+	}
+
+	tests := []struct {
+		name   string
+		modify func(f *preflightFacts)
+		tables []string
+		failed string
+		passed bool // 只有 warning 未通过
+	}{
+		{"missing replication slave", func(f *preflightFacts) {
+			f.grants = []string{"GRANT SELECT, REPLICATION CLIENT ON *.* TO `repl`@`%`"}
+		}, nil, "privilege:REPLICATION SLAVE", false},
+		{"missing table select", func(f *preflightFacts) {
+			f.grants = []string{"GRANT REPLICATION SLAVE, REPLICATION CLIENT ON *.* TO `repl`@`%`", "GRANT SELECT ON `shop`.`users` TO `repl`@`%`"}
+		}, []string{"shop.orders"}, "select:shop.orders", false},
+		{"table level select on pattern", func(f *preflightFacts) {
+			f.grants = []string{"GRANT REPLICATION SLAVE, REPLICATION CLIENT ON *.* TO `repl`@`%`", "GRANT SELECT ON `shop`.`order_1` TO `repl`@`%`"}
+		}, []string{"shop.order_*"}, "select:shop.order_*", false},
+		{"statement format", func(f *preflightFacts) { f.binlog.Format = "MIXED" }, nil, "binlog_format", false},
+		{"binlog disabled", func(f *preflightFacts) { f.binlog.LogBin = false }, nil, "log_bin", false},
+		{"minimal row image", func(f *preflightFacts) { f.binlog.RowImage = "MINIMAL" }, nil, "binlog_row_image", true},
+		{"short retention", func(f *preflightFacts) { f.binlog.Retention = 3600 }, nil, "binlog_retention", true},
+		{"server id of source", func(f *preflightFacts) { f.serverID = 1001 }, nil, "server_id", false},
+		{"server id used by replica", func(f *preflightFacts) { f.replicaIDs = []uint32{2, 1001} }, nil, "server_id_unique", false},
+		{"replicas unknown", func(f *preflightFacts) { f.replicaIDs = nil }, nil, "server_id_unique", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			facts := healthyFacts()
+			tt.modify(facts)
+			report := evaluatePreflight(facts, 1001, 24*time.Hour, DefaultBinlogChecksum(), tt.tables, false)
+			failed := failedChecks(report)
+			if len(failed) != 1 || failed[0] != tt.failed {
+				t.Fatalf("expected only %s to fail, got %v", tt.failed, failed)
+			}
+			if report.Passed != tt.passed {
+				t.Errorf("expected passed=%v", tt.passed)
+			}
+			if !tt.passed && report.Err() == nil {
+				t.Error("expected Err to report the failure")
+			}
+		})
+	}
+
+	// 本服务的实例已以该 server_id 连接时不算冲突
+	facts := healthyFacts()
+	facts.replicaIDs = []uint32{1001}
+	if report := evaluatePreflight(facts, 1001, 24*time.Hour, DefaultBinlogChecksum(), nil, true); !report.Passed {
+		t.Errorf("own server id should not conflict, failed: %v", failedChecks(report))
+	}
+}
+
+// TestPreflightActionableMessage 测试未通过时给出可执行的授权语句
+func TestPreflightActionableMessage(t *testing.T) {
+	facts := healthyFacts()
+	facts.grants = []string{"GRANT REPLICATION SLAVE, REPLICATION CLIENT ON *.* TO `repl`@`%`"}
+	err := evaluatePreflight(facts, 1001, 24*time.Hour, DefaultBinlogChecksum(), []string{"shop.orders"}, false).Err()
+	if err == nil || !strings.Contains(err.Error(), "GRANT SELECT ON `shop`.`orders` TO 'repl'@'%'") {
+		t.Errorf("expected GRANT hint, got %v", err)
+	}
+}
+
+// TestHasPrivilege 测试授权范围的匹配
+func TestHasPrivilege(t *testing.T) {
+	grants := parseGrants([]string{
+		"GRANT USAGE ON *.* TO `app`@`%`",
+		"GRANT ALL PRIVILEGES ON `shop`.* TO `app`@`%`",
+		"GRANT SELECT, INSERT ON `crm`.`Users` TO `app`@`%`",
+		"GRANT SELECT ON `my\\_db`.* TO `app`@`%`",
+		"GRANT `reader`@`%` TO `app`@`%`",
+	})
+
+	cases := []struct {
+		privilege, schema, table string
+		want                     bool
+	}{
+		{"SELECT", "shop", "orders", true},
+		{"SELECT", "crm", "Users", true},
+		{"SELECT", "crm", "users", false},
+		{"SELECT", "crm", "*", false},
+		{"SELECT", "my_db", "t", true},
+		{"REPLICATION SLAVE", "*", "*", false},
+		{"SELECT", "*", "*", false},
+	}
+	for _, c := range cases {
+		if got := hasPrivilege(grants, c.privilege, c.schema, c.table); got != c.want {
+			t.Errorf("hasPrivilege(%s, %s.%s) = %v, want %v", c.privilege, c.schema, c.table, got, c.want)
+		}
+	}
+}
+
+// TestPreflightRolesAndAliases 测试 MariaDB 的 BINLOG MONITOR 视为 REPLICATION CLIENT，
+// 角色的权限无法查询时缺少的权限只作为警告
+func TestPreflightRolesAndAliases(t *testing.T) {
+	facts := healthyFacts()
+	facts.grants = []string{"GRANT SELECT, REPLICATION SLAVE, BINLOG MONITOR ON *.* TO `repl`@`%`"}
+	if report := evaluatePreflight(facts, 1001, 24*time.Hour, DefaultBinlogChecksum(), []string{"shop.orders"}, false); !report.Passed {
+		t.Errorf("BINLOG MONITOR should satisfy REPLICATION CLIENT, failed: %v", failedChecks(report))
+	}
+
+	roles := grantedRoles([]string{
+		"GRANT USAGE ON *.* TO `repl`@`%`",
+		"GRANT `replicator`@`%`,`reader`@`%` TO `repl`@`%`",
+		"GRANT `dba` TO `repl`@`%`",
+	})
+	if strings.Join(roles, " ") != "`replicator`@`%` `reader`@`%` `dba`" {
+		t.Errorf("unexpected roles %v", roles)
+	}
+
+	facts.grants = []string{"GRANT USAGE ON *.* TO `repl`@`%`", "GRANT `replicator`@`%` TO `repl`@`%`"}
+	facts.unresolved = []string{"`replicator`@`%`"}
+	report := evaluatePreflight(facts, 1001, 24*time.Hour, DefaultBinlogChecksum(), []string{"shop.orders"}, false)
+	if !report.Passed || len(failedChecks(report)) != 3 {
+		t.Fatalf("expected privilege warnings only, got passed=%v failed=%v", report.Passed, failedChecks(report))
+	}
+	if !strings.Contains(report.Checks[1].Message, "`replicator`@`%`") {
+		t.Errorf("expected the unresolved role in the message, got %q", report.Checks[1].Message)
+	}
+}
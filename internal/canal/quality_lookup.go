@@ -0,0 +1,131 @@
+package canal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"pikachun/internal/config"
+)
+
+const (
+	qualityLookupTimeout   = 2 * time.Second // 单次查询超时
+	qualityLookupTTL       = time.Minute     // 查询结果的缓存时间
+	qualityLookupCacheSize = 10000           // 缓存的最大条数
+)
+
+// SourceLookup 在源库中查询引用的值是否存在，用于数据质量规则的引用存在断言。
+// 查询结果（存在和不存在）缓存 qualityLookupTTL，源库连接信息变化后使用新的连接
+type SourceLookup struct {
+	source func() config.CanalConfig
+
+	mu    sync.Mutex
+	db    *sql.DB
+	dsn   string
+	cache map[string]lookupEntry
+}
+
+type lookupEntry struct {
+	exists  bool
+	expires time.Time
+}
+
+// NewSourceLookup 创建源库查询，source 返回当前的源库连接信息
+func NewSourceLookup(source func() config.CanalConfig) *SourceLookup {
+	return &SourceLookup{source: source, cache: make(map[string]lookupEntry)}
+}
+
+// Exists 查询 table（库.表）中是否存在 column 等于 value 的行
+func (l *SourceLookup) Exists(ctx context.Context, table, column string, value interface{}) (bool, error) {
+	value = lookupValue(value)
+	key := fmt.Sprintf("%s.%s=%v", table, column, value)
+	now := time.Now()
+
+	l.mu.Lock()
+	if entry, ok := l.cache[key]; ok && now.Before(entry.expires) {
+		l.mu.Unlock()
+		return entry.exists, nil
+	}
+	db, err := l.connLocked()
+	l.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	schema, name, _ := strings.Cut(table, ".")
+	query := fmt.Sprintf("SELECT 1 FROM %s.%s WHERE %s = ? LIMIT 1", quoteIdentifier(schema), quoteIdentifier(name), quoteIdentifier(column))
+	ctx, cancel := context.WithTimeout(ctx, qualityLookupTimeout)
+	defer cancel()
+	var one int
+	exists := true
+	if err := db.QueryRowContext(ctx, query, value).Scan(&one); err == sql.ErrNoRows {
+		exists = false
+	} else if err != nil {
+		return false, fmt.Errorf("failed to look up %s.%s: %v", table, column, err)
+	}
+
+	l.mu.Lock()
+	if len(l.cache) >= qualityLookupCacheSize {
+		for k, entry := range l.cache {
+			if !now.Before(entry.expires) {
+				delete(l.cache, k)
+			}
+		}
+		// 仍然没有空间时清空缓存
+		if len(l.cache) >= qualityLookupCacheSize {
+			l.cache = make(map[string]lookupEntry)
+		}
+	}
+	l.cache[key] = lookupEntry{exists: exists, expires: now.Add(qualityLookupTTL)}
+	l.mu.Unlock()
+	return exists, nil
+}
+
+// connLocked 返回到源库的连接池，连接信息变化时关闭旧的连接池
+func (l *SourceLookup) connLocked() (*sql.DB, error) {
+	cfg := l.source()
+	dsn := SourceDSN(cfg.Username, cfg.Password, cfg.Host, cfg.Port, "charset=utf8mb4&timeout=5s")
+	if l.db != nil && l.dsn == dsn {
+		return l.db, nil
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(4)
+	if l.db != nil {
+		l.db.Close()
+	}
+	l.db, l.dsn = db, dsn
+	return db, nil
+}
+
+// Close 关闭到源库的连接
+func (l *SourceLookup) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.db == nil {
+		return nil
+	}
+	err := l.db.Close()
+	l.db = nil
+	return err
+}
+
+// lookupValue 将列值转换为查询参数，定点小数和 JSON 数值按字符串比较
+func lookupValue(v interface{}) interface{} {
+	switch n := v.(type) {
+	case decimal.Decimal:
+		return n.String()
+	case json.Number:
+		return n.String()
+	default:
+		return v
+	}
+}
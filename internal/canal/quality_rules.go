@@ -0,0 +1,355 @@
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// QualitySampleSize 数据质量报告中保留的最近违规事件数
+const QualitySampleSize = 50
+
+// QualityRules 数据质量规则：对事件的行数据做断言（非空、取值范围、引用存在），违规的事件计数并采样进报告，
+// 设置了隔离地址时改投到隔离地址，否则照常投递。与消费端契约不同，规则检查的是数据本身而不是载荷结构
+type QualityRules struct {
+	Rules []QualityRule `json:"rules"`
+
+	lookup       QualityLookup // 引用存在断言的查询，为 nil 时不检查引用存在
+	lookupErrors int64         // 查询失败、未能检查引用存在的次数，原子访问
+}
+
+// QualityLookup 查询引用的值是否存在，table 为 库.表
+type QualityLookup interface {
+	Exists(ctx context.Context, table, column string, value interface{}) (bool, error)
+}
+
+// QualityRule 一条断言，作用于一张表的一列
+type QualityRule struct {
+	Name    string   `json:"name,omitempty"` // 规则名称，用于报告，默认为 库.表.列
+	Table   string   `json:"table"`          // 库.表
+	Column  string   `json:"column"`
+	NotNull bool     `json:"not_null,omitempty"` // 列值不能为 NULL，行数据中缺少该列也视为违规
+	Min     *float64 `json:"min,omitempty"`      // 数值下限（含）
+	Max     *float64 `json:"max,omitempty"`      // 数值上限（含）
+	Exists  string   `json:"exists,omitempty"`   // 引用存在：列值必须存在于 库.表.列，在源库中查询，NULL 不检查
+
+	existsTable  string // 库.表
+	existsColumn string
+}
+
+// QualityViolation 一个事件违反的一条规则
+type QualityViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ParseQualityRules 解析并校验数据质量规则，定义为空时返回 nil，表示不检查
+func ParseQualityRules(definition string) (*QualityRules, error) {
+	if strings.TrimSpace(definition) == "" {
+		return nil, nil
+	}
+
+	var rules QualityRules
+	decoder := json.NewDecoder(strings.NewReader(definition))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("invalid quality rules: %v", err)
+	}
+	if len(rules.Rules) == 0 {
+		return nil, fmt.Errorf("quality rules must contain at least one rule")
+	}
+
+	names := make(map[string]bool, len(rules.Rules))
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+		if !strings.Contains(rule.Table, ".") {
+			return nil, fmt.Errorf("rule %d: invalid table %q, expected database.table", i+1, rule.Table)
+		}
+		if rule.Column == "" {
+			return nil, fmt.Errorf("rule %d: column is required", i+1)
+		}
+		if !rule.NotNull && rule.Min == nil && rule.Max == nil && rule.Exists == "" {
+			return nil, fmt.Errorf("rule %d: at least one of not_null, min, max, exists is required", i+1)
+		}
+		if rule.Exists != "" {
+			parts := strings.Split(rule.Exists, ".")
+			if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+				return nil, fmt.Errorf("rule %d: invalid exists %q, expected database.table.column", i+1, rule.Exists)
+			}
+			rule.existsTable, rule.existsColumn = parts[0]+"."+parts[1], parts[2]
+		}
+		if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+			return nil, fmt.Errorf("rule %d: min %v is greater than max %v", i+1, *rule.Min, *rule.Max)
+		}
+		if rule.Name == "" {
+			rule.Name = rule.Table + "." + rule.Column
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return &rules, nil
+}
+
+// SetLookup 设置引用存在断言的查询，需在检查事件前设置
+func (r *QualityRules) SetLookup(lookup QualityLookup) {
+	if r != nil {
+		r.lookup = lookup
+	}
+}
+
+// LookupErrors 查询失败、未能检查引用存在的次数
+func (r *QualityRules) LookupErrors() int64 {
+	if r == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&r.lookupErrors)
+}
+
+// Check 检查事件的行数据，返回违反的规则，没有违规时返回 nil；nil 规则不检查。
+// DELETE 事件检查删除前的数据，其余事件检查变更后的数据。引用存在的查询失败时不视为违规，计入 LookupErrors
+func (r *QualityRules) Check(ctx context.Context, event *Event) []QualityViolation {
+	if r == nil {
+		return nil
+	}
+	row := event.AfterData
+	if row == nil {
+		row = event.BeforeData
+	}
+	if row == nil {
+		return nil
+	}
+
+	name := event.Schema + "." + event.Table
+	var violations []QualityViolation
+	for _, rule := range r.Rules {
+		if rule.Table != name {
+			continue
+		}
+		message := rule.check(row)
+		if message == "" && rule.Exists != "" {
+			message = r.checkExists(ctx, rule, row)
+		}
+		if message != "" {
+			violations = append(violations, QualityViolation{Rule: rule.Name, Message: message})
+		}
+	}
+	return violations
+}
+
+// check 检查一行数据，符合规则时返回空字符串
+func (r QualityRule) check(row *RowData) string {
+	column, ok := findColumn(row, r.Column)
+	if !ok || column.IsNull || column.Value == nil {
+		if r.NotNull {
+			if !ok {
+				return fmt.Sprintf("%s: column is missing", r.Column)
+			}
+			return fmt.Sprintf("%s: null is not allowed", r.Column)
+		}
+		return ""
+	}
+	if r.Min == nil && r.Max == nil {
+		return ""
+	}
+
+	value, ok := qualityNumber(column.Value)
+	if !ok {
+		return fmt.Sprintf("%s: expected number, got %v", r.Column, column.Value)
+	}
+	if r.Min != nil && value < *r.Min {
+		return fmt.Sprintf("%s: %v is less than %v", r.Column, value, *r.Min)
+	}
+	if r.Max != nil && value > *r.Max {
+		return fmt.Sprintf("%s: %v is greater than %v", r.Column, value, *r.Max)
+	}
+	return ""
+}
+
+// checkExists 在引用的表中查询列值，不存在时返回违规信息
+func (r *QualityRules) checkExists(ctx context.Context, rule QualityRule, row *RowData) string {
+	column, ok := findColumn(row, rule.Column)
+	if !ok || column.IsNull || column.Value == nil || r.lookup == nil {
+		return ""
+	}
+	exists, err := r.lookup.Exists(ctx, rule.existsTable, rule.existsColumn, column.Value)
+	if err != nil {
+		atomic.AddInt64(&r.lookupErrors, 1)
+		return ""
+	}
+	if !exists {
+		return fmt.Sprintf("%s: %v not found in %s", rule.Column, column.Value, rule.Exists)
+	}
+	return ""
+}
+
+// findColumn 按列名查找列
+func findColumn(row *RowData, name string) (Column, bool) {
+	for _, column := range row.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// qualityNumber 将列值转换为浮点数用于范围比较，字符串形式的数值（如定点小数）也可以比较
+func qualityNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case decimal.Decimal:
+		f, _ := n.Float64()
+		return f, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	case []byte:
+		f, err := strconv.ParseFloat(string(n), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// QualitySample 报告中的一个违规事件
+type QualitySample struct {
+	EventID     string             `json:"event_id"`
+	Database    string             `json:"database"`
+	Table       string             `json:"table"`
+	EventType   string             `json:"event_type"`
+	Violations  []QualityViolation `json:"violations"`
+	Quarantined bool               `json:"quarantined"`
+	At          time.Time          `json:"at" format:"date-time"`
+}
+
+// QualityReport 数据质量报告：检查和违规的事件数、各规则的违规数和最近的违规事件（从新到旧）
+//
+//	@Description	数据质量报告，统计从实例启动时开始
+type QualityReport struct {
+	Checked     int64 `json:"checked"`     // 检查的事件数
+	Violated    int64 `json:"violated"`    // 违反至少一条规则的事件数
+	Quarantined int64 `json:"quarantined"` // 改投到隔离地址的事件数
+	// 引用存在的查询失败次数，查询失败的事件不视为违规
+	LookupErrors int64            `json:"lookup_errors"`
+	Rules        map[string]int64 `json:"rules"`   // 规则名称 -> 违规次数
+	Samples      []QualitySample  `json:"samples"` // 最近 50 个违规事件，从新到旧
+}
+
+// qualityTracker 统计数据质量检查结果，最近的违规事件保存在环形缓冲区
+type qualityTracker struct {
+	mu          sync.Mutex
+	checked     int64
+	violated    int64
+	quarantined int64
+	rules       map[string]int64
+	samples     []QualitySample
+	next        int // 下一个写入的位置
+}
+
+// record 记录一个事件的检查结果
+func (t *qualityTracker) record(event *Event, violations []QualityViolation, quarantined bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.checked++
+	if len(violations) == 0 {
+		return
+	}
+	t.violated++
+	if quarantined {
+		t.quarantined++
+	}
+	if t.rules == nil {
+		t.rules = make(map[string]int64)
+	}
+	for _, violation := range violations {
+		t.rules[violation.Rule]++
+	}
+
+	sample := QualitySample{
+		EventID:     event.ID,
+		Database:    event.Schema,
+		Table:       event.Table,
+		EventType:   string(event.EventType),
+		Violations:  violations,
+		Quarantined: quarantined,
+		At:          time.Now(),
+	}
+	if len(t.samples) < QualitySampleSize {
+		t.samples = append(t.samples, sample)
+	} else {
+		t.samples[t.next] = sample
+	}
+	t.next = (t.next + 1) % QualitySampleSize
+}
+
+// totals 违规和被隔离的事件数
+func (t *qualityTracker) totals() (violated, quarantined int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.violated, t.quarantined
+}
+
+// report 生成数据质量报告
+func (t *qualityTracker) report() QualityReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := QualityReport{
+		Checked:     t.checked,
+		Violated:    t.violated,
+		Quarantined: t.quarantined,
+		Rules:       make(map[string]int64, len(t.rules)),
+		Samples:     make([]QualitySample, 0, len(t.samples)),
+	}
+	for name, count := range t.rules {
+		report.Rules[name] = count
+	}
+	for i := 1; i <= len(t.samples); i++ {
+		report.Samples = append(report.Samples, t.samples[(t.next-i+len(t.samples))%len(t.samples)])
+	}
+	return report
+}
+
+// QualityViolationError 被隔离的事件在事件日志中记录的错误信息
+func QualityViolationError(violations []QualityViolation) string {
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		messages = append(messages, violation.Rule+": "+violation.Message)
+	}
+	return "quality violation: " + strings.Join(messages, "; ")
+}
@@ -0,0 +1,309 @@
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"pikachun/internal/config"
+)
+
+const testQualityRules = `{
+	"rules": [
+		{"table": "shop.orders", "column": "customer_id", "not_null": true},
+		{"name": "amount-range", "table": "shop.orders", "column": "amount", "min": 0, "max": 1000}
+	]
+}`
+
+// TestParseQualityRules 测试数据质量规则的校验
+func TestParseQualityRules(t *testing.T) {
+	if rules, err := ParseQualityRules(" "); err != nil || rules != nil {
+		t.Errorf("expected no rules for empty definition, got %v, %v", rules, err)
+	}
+	rules, err := ParseQualityRules(testQualityRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.Rules[0].Name != "shop.orders.customer_id" || rules.Rules[1].Name != "amount-range" {
+		t.Errorf("unexpected rule names %+v", rules.Rules)
+	}
+
+	for _, definition := range []string{
+		`{"rules": []}`,
+		`{"rules": [{"table": "orders", "column": "id", "not_null": true}]}`,
+		`{"rules": [{"table": "shop.orders", "not_null": true}]}`,
+		`{"rules": [{"table": "shop.orders", "column": "id"}]}`,
+		`{"rules": [{"table": "shop.orders", "column": "id", "min": 10, "max": 1}]}`,
+		`{"rules": [{"table": "shop.orders", "column": "id", "not_null": true}, {"table": "shop.orders", "column": "id", "min": 1}]}`,
+		`{"rules": [{"table": "shop.orders", "column": "customer_id", "exists": "shop.customers"}]}`,
+		`{"rules": [{"table": "shop.orders", "column": "customer_id", "unique": true}]}`,
+	} {
+		if _, err := ParseQualityRules(definition); err == nil {
+			t.Errorf("expected %s to be invalid", definition)
+		}
+	}
+}
+
+// TestQualityRulesCheck 测试非空和取值范围断言
+func TestQualityRulesCheck(t *testing.T) {
+	rules, err := ParseQualityRules(testQualityRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		event   *Event
+		wantLen int
+	}{
+		{"valid", contractTestEvent(Column{Name: "customer_id", Value: int64(1)}, Column{Name: "amount", Value: decimal.RequireFromString("99.50")}), 0},
+		{"null", contractTestEvent(Column{Name: "customer_id", IsNull: true}, Column{Name: "amount", Value: int32(5)}), 1},
+		{"missing", contractTestEvent(Column{Name: "amount", Value: "5"}), 1},
+		{"out of range", contractTestEvent(Column{Name: "customer_id", Value: int64(1)}, Column{Name: "amount", Value: -1.5}), 1},
+		{"not a number", contractTestEvent(Column{Name: "customer_id", Value: int64(1)}, Column{Name: "amount", Value: "abc"}), 1},
+		{"both", contractTestEvent(Column{Name: "amount", Value: json.Number("1001")}), 2},
+		{"other table", &Event{Schema: "shop", Table: "users", EventType: EventTypeInsert, AfterData: &RowData{}}, 0},
+		{"delete", &Event{Schema: "shop", Table: "orders", EventType: EventTypeDelete, BeforeData: &RowData{Columns: []Column{{Name: "amount", Value: 1}}}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if violations := rules.Check(context.Background(), tt.event); len(violations) != tt.wantLen {
+				t.Errorf("expected %d violations, got %+v", tt.wantLen, violations)
+			}
+		})
+	}
+
+	var none *QualityRules
+	if none.Check(context.Background(), tests[1].event) != nil {
+		t.Error("nil rules should accept every event")
+	}
+}
+
+// fakeQualityLookup 按 库.表.列=值 判断引用是否存在
+type fakeQualityLookup struct {
+	rows  map[string]bool
+	err   error
+	calls int
+}
+
+func (l *fakeQualityLookup) Exists(ctx context.Context, table, column string, value interface{}) (bool, error) {
+	l.calls++
+	if l.err != nil {
+		return false, l.err
+	}
+	return l.rows[fmt.Sprintf("%s.%s=%v", table, column, value)], nil
+}
+
+// TestQualityRulesExists 测试引用存在断言：不存在时违规，NULL 不查询，查询失败不视为违规
+func TestQualityRulesExists(t *testing.T) {
+	rules, err := ParseQualityRules(`{"rules": [{"table": "shop.orders", "column": "customer_id", "not_null": true, "exists": "shop.customers.id"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookup := &fakeQualityLookup{rows: map[string]bool{"shop.customers.id=1": true}}
+	rules.SetLookup(lookup)
+
+	if violations := rules.Check(context.Background(), contractTestEvent(Column{Name: "customer_id", Value: int64(1)})); len(violations) != 0 {
+		t.Errorf("expected no violations, got %+v", violations)
+	}
+	violations := rules.Check(context.Background(), contractTestEvent(Column{Name: "customer_id", Value: int64(2)}))
+	if len(violations) != 1 || violations[0].Message != "customer_id: 2 not found in shop.customers.id" {
+		t.Errorf("expected missing reference, got %+v", violations)
+	}
+	// NULL 只报告非空违规，不查询引用
+	calls := lookup.calls
+	if violations := rules.Check(context.Background(), contractTestEvent(Column{Name: "customer_id", IsNull: true})); len(violations) != 1 || lookup.calls != calls {
+		t.Errorf("expected only the not_null violation without lookup, got %+v (%d lookups)", violations, lookup.calls-calls)
+	}
+
+	lookup.err = errors.New("connection refused")
+	if violations := rules.Check(context.Background(), contractTestEvent(Column{Name: "customer_id", Value: int64(2)})); len(violations) != 0 {
+		t.Errorf("lookup errors should not be violations, got %+v", violations)
+	}
+	if rules.LookupErrors() != 1 {
+		t.Errorf("expected 1 lookup error, got %d", rules.LookupErrors())
+	}
+}
+
+// TestQuarantineInheritsSettings 测试隔离处理器沿用主处理器的演练模式和投递记录，主处理器更新后重新应用
+func TestQuarantineInheritsSettings(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	rules, err := ParseQualityRules(testQualityRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &fakeDeliveryRecorder{}
+	webhook := NewWebhookHandler("webhook-1", server.URL+"/events", log.New(io.Discard, "", 0))
+	webhook.SetDeliveryRecorder(1, recorder)
+	webhook.SetDryRun(true)
+	webhook.SetQualityRules(rules)
+	webhook.SetQuarantineURL(server.URL + "/quarantine")
+	timeouts := DeliveryTimeouts{Request: time.Second, Delivery: 5 * time.Second, Shutdown: time.Second}
+	if err := webhook.SetTimeouts(timeouts); err != nil {
+		t.Fatal(err)
+	}
+	if got := webhook.quarantine.getTimeouts(); got != timeouts {
+		t.Errorf("expected quarantine timeouts %+v, got %+v", timeouts, got)
+	}
+
+	bad := contractTestEvent(Column{Name: "customer_id", IsNull: true}, Column{Name: "amount", Value: int64(10)})
+	if err := webhook.Handle(context.Background(), bad); err != nil {
+		t.Fatal(err)
+	}
+	if err := webhook.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 投递异步进行，等待演练记录
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		recorder.mu.Lock()
+		n := len(recorder.attempts)
+		recorder.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the dry run attempt recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	if requests != 0 {
+		t.Errorf("expected no requests in dry run, got %d", requests)
+	}
+	mu.Unlock()
+
+	// 关闭演练模式后隔离处理器照常投递
+	webhook.SetDryRun(false)
+	next := contractTestEvent(Column{Name: "customer_id", IsNull: true}, Column{Name: "amount", Value: int64(10)})
+	next.ID = "e2"
+	if err := webhook.Handle(context.Background(), next); err != nil {
+		t.Fatal(err)
+	}
+	if err := webhook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if requests != 1 {
+		t.Errorf("expected 1 request after dry run disabled, got %d", requests)
+	}
+	mu.Unlock()
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.attempts) != 2 || !recorder.attempts[0].DryRun || recorder.attempts[1].DryRun || recorder.attempts[1].TaskID != 1 ||
+		recorder.attempts[1].URL != server.URL+"/quarantine" {
+		t.Errorf("unexpected quarantine attempts %+v", recorder.attempts)
+	}
+}
+
+// TestQualityReportSamples 测试报告只保留最近的违规事件，从新到旧排列
+func TestQualityReportSamples(t *testing.T) {
+	var tracker qualityTracker
+	violation := []QualityViolation{{Rule: "r", Message: "m"}}
+	for i := 0; i < QualitySampleSize+5; i++ {
+		tracker.record(&Event{ID: string(rune('a' + i%26))}, violation, false)
+	}
+	tracker.record(&Event{ID: "ok"}, nil, false)
+	tracker.record(&Event{ID: "last"}, violation, true)
+
+	report := tracker.report()
+	if report.Checked != QualitySampleSize+7 || report.Violated != QualitySampleSize+6 || report.Quarantined != 1 {
+		t.Errorf("unexpected totals %+v", report)
+	}
+	if report.Rules["r"] != QualitySampleSize+6 {
+		t.Errorf("unexpected rule counts %v", report.Rules)
+	}
+	if len(report.Samples) != QualitySampleSize || report.Samples[0].EventID != "last" || !report.Samples[0].Quarantined {
+		t.Errorf("expected %d samples starting with the latest, got %d (%+v)", QualitySampleSize, len(report.Samples), report.Samples[0])
+	}
+}
+
+// TestQualityQuarantineRouting 测试设置了隔离地址时违规的事件改投到隔离地址，并在事件日志中记为 quarantined
+func TestQualityQuarantineRouting(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Events []Event `json:"events"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		for _, event := range payload.Events {
+			received[r.URL.Path] = append(received[r.URL.Path], event.ID)
+		}
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	rules, err := ParseQualityRules(testQualityRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.New(io.Discard, "", 0)
+	webhook := NewWebhookHandler("webhook-1", server.URL+"/events", logger)
+	webhook.SetQualityRules(rules)
+	webhook.SetQuarantineURL(server.URL + "/quarantine")
+	logs := &fakeEventLogger{}
+	dbHandler := NewDatabaseHandler("db-1", 1, logger, logs, config.DatabaseStorageConfig{Enabled: true, BatchSize: 2})
+	dbHandler.SetQualityRules(rules, true)
+
+	good := contractTestEvent(Column{Name: "customer_id", Value: int64(1)}, Column{Name: "amount", Value: int64(10)})
+	bad := contractTestEvent(Column{Name: "customer_id", IsNull: true}, Column{Name: "amount", Value: int64(10)})
+	bad.ID = "e2"
+	for _, event := range []*Event{good, bad} {
+		if err := webhook.Handle(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+		if err := dbHandler.Handle(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := webhook.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dbHandler.Close()
+
+	mu.Lock()
+	if strings.Join(received["/events"], ",") != "e1" || strings.Join(received["/quarantine"], ",") != "e2" {
+		t.Errorf("unexpected deliveries %v", received)
+	}
+	mu.Unlock()
+	if stats := webhook.GetStats(); stats["quality_violations"] != int64(1) || stats["quarantined_events"] != int64(1) {
+		t.Errorf("unexpected stats %v", stats)
+	}
+
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	statuses := map[string]string{}
+	for _, batch := range logs.batches {
+		for _, entry := range batch {
+			statuses[entry.EventID] = entry.Status
+			if entry.Status == "quarantined" && !strings.Contains(entry.Error, "shop.orders.customer_id: customer_id: null is not allowed") {
+				t.Errorf("unexpected error %q", entry.Error)
+			}
+		}
+	}
+	if statuses["e1"] != "success" || statuses["e2"] != "quarantined" {
+		t.Errorf("unexpected event log statuses %v", statuses)
+	}
+}
@@ -0,0 +1,385 @@
+package canal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// RowFilter 按行数据过滤事件的表达式，如 `status = 'paid' AND amount >= 100`。
+// 列名默认取 after_data（DELETE 事件取 before_data），before.列名 和 after.列名 指定取哪个镜像。
+// 支持 =、!=、<>、>、>=、<、<=、IS NULL、IS NOT NULL，AND 优先于 OR，可以用括号分组；
+// 两边都是数值时按数值比较，否则按字符串比较，列不存在时视为 NULL
+type RowFilter struct {
+	expr string
+	root filterNode
+}
+
+// filterNode 表达式节点
+type filterNode interface {
+	eval(event *Event) bool
+}
+
+// ParseRowFilter 解析行过滤表达式，表达式为空时返回 nil，表示不过滤
+func ParseRowFilter(expr string) (*RowFilter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.tokens[p.pos].text, p.tokens[p.pos].offset)
+	}
+	return &RowFilter{expr: strings.TrimSpace(expr), root: root}, nil
+}
+
+// String 返回原始表达式
+func (f *RowFilter) String() string {
+	return f.expr
+}
+
+// Match 事件是否满足表达式
+func (f *RowFilter) Match(event *Event) bool {
+	return f.root.eval(event)
+}
+
+// 词法单元类型
+const (
+	filterIdent = iota
+	filterString
+	filterNumber
+	filterOp
+	filterLParen
+	filterRParen
+)
+
+type filterToken struct {
+	kind   int
+	text   string
+	offset int
+}
+
+// tokenizeFilter 拆分表达式
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{kind: filterLParen, text: "(", offset: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{kind: filterRParen, text: ")", offset: i})
+			i++
+		case r == '\'' || r == '"':
+			// 引号内两个连续的引号表示一个引号
+			var sb strings.Builder
+			start := i
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						sb.WriteRune(r)
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			tokens = append(tokens, filterToken{kind: filterString, text: sb.String(), offset: start})
+		case strings.ContainsRune("=!<>", r):
+			start := i
+			op := string(r)
+			if i+1 < len(runes) && (runes[i+1] == '=' || (r == '<' && runes[i+1] == '>')) {
+				op += string(runes[i+1])
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected ! at position %d", start)
+			}
+			i += len([]rune(op))
+			tokens = append(tokens, filterToken{kind: filterOp, text: op, offset: start})
+		case r == '-' || r == '.' || unicode.IsDigit(r):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E') {
+				i++
+			}
+			text := string(runes[start:i])
+			if _, err := strconv.ParseFloat(text, 64); err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", text, start)
+			}
+			tokens = append(tokens, filterToken{kind: filterNumber, text: text, offset: start})
+		case r == '`':
+			// 反引号括起的列名
+			start := i
+			end := i + 1
+			for end < len(runes) && runes[end] != '`' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated identifier at position %d", start)
+			}
+			tokens = append(tokens, filterToken{kind: filterIdent, text: string(runes[start+1 : end]), offset: start})
+			i = end + 1
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || runes[i] == '.' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: filterIdent, text: string(runes[start:i]), offset: start})
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", r, i)
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser 递归下降解析器
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() *filterToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// keyword 当前词法单元是否为关键字，是则前进
+func (p *filterParser) keyword(word string) bool {
+	if t := p.peek(); t != nil && t.kind == filterIdent && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseCondition() (filterNode, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	if t.kind == filterLParen {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t == nil || t.kind != filterRParen {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return node, nil
+	}
+	if t.kind != filterIdent || isFilterKeyword(t.text) {
+		return nil, fmt.Errorf("expected column name at position %d, got %q", t.offset, t.text)
+	}
+	p.pos++
+	column, err := parseFilterColumn(t.text)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.keyword("IS") {
+		not := p.keyword("NOT")
+		if !p.keyword("NULL") {
+			return nil, fmt.Errorf("expected NULL after IS for column %s", t.text)
+		}
+		return nullNode{column: column, not: not}, nil
+	}
+
+	op := p.peek()
+	if op == nil || op.kind != filterOp {
+		return nil, fmt.Errorf("expected operator after column %s", t.text)
+	}
+	p.pos++
+	value := p.peek()
+	if value == nil || (value.kind != filterString && value.kind != filterNumber) {
+		return nil, fmt.Errorf("expected a string or number after %s %s", t.text, op.text)
+	}
+	p.pos++
+
+	node := compareNode{column: column, op: op.text, literal: value.text, numeric: value.kind == filterNumber}
+	switch node.op {
+	case "<>":
+		node.op = "!="
+	case "==":
+		node.op = "="
+	}
+	if node.numeric {
+		node.number, _ = strconv.ParseFloat(value.text, 64)
+	}
+	return node, nil
+}
+
+// isFilterKeyword 是否为保留的关键字
+func isFilterKeyword(word string) bool {
+	switch strings.ToUpper(word) {
+	case "AND", "OR", "IS", "NOT", "NULL":
+		return true
+	}
+	return false
+}
+
+// filterColumn 表达式引用的列，image 为空表示按事件类型选择镜像
+type filterColumn struct {
+	image string // before、after 或空
+	name  string
+}
+
+// parseFilterColumn 解析列引用
+func parseFilterColumn(text string) (filterColumn, error) {
+	if prefix, name, ok := strings.Cut(text, "."); ok {
+		prefix = strings.ToLower(prefix)
+		if (prefix != "before" && prefix != "after") || name == "" || strings.Contains(name, ".") {
+			return filterColumn{}, fmt.Errorf("invalid column %q, use column, before.column or after.column", text)
+		}
+		return filterColumn{image: prefix, name: name}, nil
+	}
+	return filterColumn{name: text}, nil
+}
+
+// lookup 取事件中列的值，列不存在或为 NULL 时 ok 为 false
+func (c filterColumn) lookup(event *Event) (interface{}, bool) {
+	row := event.AfterData
+	if c.image == "before" || (c.image == "" && event.EventType == EventTypeDelete) {
+		row = event.BeforeData
+	}
+	if row == nil {
+		return nil, false
+	}
+	for _, column := range row.Columns {
+		if column.Name == c.name {
+			if column.IsNull || column.Value == nil {
+				return nil, false
+			}
+			return column.Value, true
+		}
+	}
+	return nil, false
+}
+
+type orNode struct{ left, right filterNode }
+
+func (n orNode) eval(event *Event) bool { return n.left.eval(event) || n.right.eval(event) }
+
+type andNode struct{ left, right filterNode }
+
+func (n andNode) eval(event *Event) bool { return n.left.eval(event) && n.right.eval(event) }
+
+type nullNode struct {
+	column filterColumn
+	not    bool
+}
+
+func (n nullNode) eval(event *Event) bool {
+	_, ok := n.column.lookup(event)
+	return ok == n.not
+}
+
+type compareNode struct {
+	column  filterColumn
+	op      string
+	literal string
+	numeric bool
+	number  float64
+}
+
+func (n compareNode) eval(event *Event) bool {
+	value, ok := n.column.lookup(event)
+	if !ok {
+		return false
+	}
+
+	var text string
+	switch v := value.(type) {
+	case []byte:
+		text = string(v)
+	case bool:
+		text = "0"
+		if v {
+			text = "1"
+		}
+	default:
+		text = fmt.Sprint(v)
+	}
+
+	var cmp int
+	if number, err := strconv.ParseFloat(text, 64); err == nil && n.numeric {
+		switch {
+		case number < n.number:
+			cmp = -1
+		case number > n.number:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(text, n.literal)
+	}
+
+	switch n.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
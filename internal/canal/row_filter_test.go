@@ -0,0 +1,76 @@
+package canal
+
+import "testing"
+
+// TestParseRowFilter 测试行过滤表达式校验
+func TestParseRowFilter(t *testing.T) {
+	if filter, err := ParseRowFilter("  "); err != nil || filter != nil {
+		t.Errorf("expected nil filter for empty expression, got %v, %v", filter, err)
+	}
+
+	invalid := []string{
+		"status",
+		"status = ",
+		"status = paid",
+		"(status = 'paid'",
+		"status = 'paid' AND",
+		"status IS 'paid'",
+		"row.status = 'paid'",
+		"status = 'paid",
+		"status ! 'paid'",
+		"amount > 1.2.3",
+	}
+	for _, expr := range invalid {
+		if _, err := ParseRowFilter(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
+
+// TestRowFilterMatch 测试按行数据匹配，DELETE 事件默认取删除前镜像
+func TestRowFilterMatch(t *testing.T) {
+	update := &Event{
+		EventType:  EventTypeUpdate,
+		BeforeData: &RowData{Columns: []Column{{Name: "status", Value: "new"}, {Name: "amount", Value: 80}}},
+		AfterData: &RowData{Columns: []Column{
+			{Name: "status", Value: "paid"},
+			{Name: "amount", Value: 120},
+			{Name: "note", IsNull: true},
+			{Name: "name", Value: []byte("O'Brien")},
+		}},
+	}
+	remove := &Event{
+		EventType:  EventTypeDelete,
+		BeforeData: &RowData{Columns: []Column{{Name: "status", Value: "paid"}, {Name: "amount", Value: 10}}},
+	}
+
+	cases := []struct {
+		expr   string
+		event  *Event
+		expect bool
+	}{
+		{"status = 'paid'", update, true},
+		{"status != 'paid'", update, false},
+		{"amount >= 100", update, true},
+		{"amount > 100 AND status = 'new'", update, false},
+		{"before.status = 'new' AND after.status = 'paid'", update, true},
+		{"status = 'new' OR amount < 200", update, true},
+		{"status = 'new' OR amount > 100 AND note IS NULL", update, true},
+		{"(status = 'new' OR amount > 100) AND note IS NOT NULL", update, false},
+		{"missing IS NULL", update, true},
+		{"missing = 1", update, false},
+		{"`name` = 'O''Brien'", update, true},
+		{"amount <> 120", update, false},
+		{"status = 'paid' and amount < 100", remove, true},
+		{"after.status = 'paid'", remove, false},
+	}
+	for _, tc := range cases {
+		filter, err := ParseRowFilter(tc.expr)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", tc.expr, err)
+		}
+		if got := filter.Match(tc.event); got != tc.expect {
+			t.Errorf("%q: expected %v, got %v", tc.expr, tc.expect, got)
+		}
+	}
+}
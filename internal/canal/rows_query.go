@@ -0,0 +1,38 @@
+package canal
+
+import (
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// handleRowsQueryEvent 记录当前语句的原始 SQL，附加到随后的行事件上
+// 源库开启 binlog_rows_query_log_events 时，每条语句的行事件前有一个 ROWS_QUERY 事件
+func (m *MySQLBinlogSlave) handleRowsQueryEvent(header *replication.EventHeader, e *replication.RowsQueryEvent) error {
+	if !m.config.CaptureSQL {
+		return nil
+	}
+
+	query := string(e.Query)
+	if m.config.MaxSQLLength > 0 && len(query) > m.config.MaxSQLLength {
+		query = truncateValue(query, m.config.MaxSQLLength).(string)
+	}
+	m.rowsQuery = query
+	return nil
+}
+
+// checkRowsQueryLogEvents 开启原始 SQL 捕获但源库未开启 binlog_rows_query_log_events 时给出提示
+func (m *MySQLBinlogSlave) checkRowsQueryLogEvents() {
+	db, err := m.openSourceDB()
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	var enabled int
+	if err := db.QueryRow("SELECT @@GLOBAL.binlog_rows_query_log_events").Scan(&enabled); err != nil {
+		m.logger.Printf("⚠️ Failed to check binlog_rows_query_log_events: %v", err)
+		return
+	}
+	if enabled == 0 {
+		m.logger.Printf("⚠️ SQL capture is enabled but binlog_rows_query_log_events is OFF on the source, row events will have no sql")
+	}
+}
@@ -0,0 +1,57 @@
+package canal
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// TestRowsQueryCapture 测试原始 SQL 附加到随后的行事件、提交后清除以及超长截断
+func TestRowsQueryCapture(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	slave, err := NewMySQLBinlogSlave(MySQLConfig{Host: "localhost", Port: 3307, ServerID: 12345, CaptureSQL: true, MaxSQLLength: 40},
+		NewDefaultEventSink(logger), logger)
+	if err != nil {
+		t.Fatalf("Failed to create slave: %v", err)
+	}
+
+	header := &replication.EventHeader{LogPos: 200}
+	schema := slave.getTableSchema("testdb", "users", newTestTableMap(100, []byte{3, 15}, "id", "name"))
+	rowsEvent := func() *Event {
+		return slave.createCanalEvent(header, schema, EventTypeInsert, []interface{}{int32(1), "alice"}, 0, nil)
+	}
+	handle := func(e replication.Event) {
+		if err := slave.handleBinlogEvent(&replication.BinlogEvent{Header: header, Event: e}); err != nil {
+			t.Fatalf("handleBinlogEvent failed: %v", err)
+		}
+	}
+
+	query := "INSERT INTO users (id, name) VALUES (1, 'alice')"
+	handle(&replication.RowsQueryEvent{Query: []byte(query[:30])})
+	if got := rowsEvent().SQL; got != query[:30] {
+		t.Errorf("expected sql %q, got %q", query[:30], got)
+	}
+
+	// 事务提交后不再附加
+	handle(&replication.XIDEvent{})
+	if got := rowsEvent().SQL; got != "" {
+		t.Errorf("expected sql to be cleared after commit, got %q", got)
+	}
+
+	// 超过最大长度时截断
+	handle(&replication.RowsQueryEvent{Query: []byte(query)})
+	if got := rowsEvent().SQL; !strings.HasPrefix(got, query[:40]) || strings.Contains(got, "alice") {
+		t.Errorf("expected truncated sql, got %q", got)
+	}
+
+	// 未开启时忽略
+	slave.config.CaptureSQL = false
+	handle(&replication.QueryEvent{Query: []byte("BEGIN")})
+	handle(&replication.RowsQueryEvent{Query: []byte(query)})
+	if got := rowsEvent().SQL; got != "" {
+		t.Errorf("expected no sql when capture is disabled, got %q", got)
+	}
+}
@@ -0,0 +1,120 @@
+package canal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxSampleKeys 按主键限频时保留的主键数上限，超出后清理已过限频间隔的主键
+const maxSampleKeys = 100000
+
+// EventSampler 事件采样，只有被采样的事件送往处理器（Webhook、事件日志等），用于只需要观察数据变化趋势的监控类下游。
+// 两种方式可以组合：
+//   - 按比例：对库表和主键值做哈希，同一行的所有变更要么都保留要么都丢弃，重放 binlog 时结果相同；没有主键的表按事件ID哈希
+//   - 按主键限频：同一行在间隔内只保留第一个事件，按 binlog 事件时间计算，没有主键的表按整张表限频
+type EventSampler struct {
+	percent  float64       // 保留的比例（百分比），0 或 100 表示不按比例采样
+	interval time.Duration // 同一行的最小间隔，0 表示不限频
+
+	mu       sync.Mutex
+	lastKept map[string]time.Time // 各行最近保留的事件时间
+
+	seen    int64
+	dropped int64
+}
+
+// NewEventSampler 根据任务配置创建采样器，percent 为保留的百分比（0-100），interval 为同一行的最小间隔（秒）。
+// 都未配置时返回 nil，表示不采样
+func NewEventSampler(percent float64, interval int) (*EventSampler, error) {
+	if percent < 0 || percent > 100 {
+		return nil, fmt.Errorf("sample percent must be between 0 and 100, got %v", percent)
+	}
+	if interval < 0 {
+		return nil, fmt.Errorf("sample interval must not be negative")
+	}
+	if percent == 100 {
+		percent = 0
+	}
+	if percent == 0 && interval == 0 {
+		return nil, nil
+	}
+	return &EventSampler{
+		percent:  percent,
+		interval: time.Duration(interval) * time.Second,
+		lastKept: make(map[string]time.Time),
+	}, nil
+}
+
+// Keep 事件是否被采样。事件按 binlog 顺序调用，nil 采样器保留所有事件
+func (s *EventSampler) Keep(event *Event) bool {
+	if s == nil {
+		return true
+	}
+	atomic.AddInt64(&s.seen, 1)
+
+	rowKey := event.Schema + "." + event.Table
+	values, hasPK := rowValues(event, event.PrimaryKey)
+	if hasPK {
+		rowKey += ":" + values
+	}
+
+	keep := true
+	if s.percent > 0 {
+		hashKey := rowKey
+		if !hasPK {
+			hashKey = event.ID
+		}
+		h := fnv.New64a()
+		h.Write([]byte(hashKey))
+		// 以万分之一为粒度，支持 0.01% 的比例
+		keep = float64(h.Sum64()%10000) < s.percent*100
+	}
+	if keep && s.interval > 0 {
+		keep = s.keepByInterval(rowKey, event.Timestamp)
+	}
+
+	if !keep {
+		atomic.AddInt64(&s.dropped, 1)
+	}
+	return keep
+}
+
+// keepByInterval 同一行在间隔内只保留第一个事件
+func (s *EventSampler) keepByInterval(key string, ts time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.lastKept[key]; ok && ts.Sub(last) < s.interval {
+		return false
+	}
+	if len(s.lastKept) >= maxSampleKeys {
+		for k, last := range s.lastKept {
+			if ts.Sub(last) >= s.interval {
+				delete(s.lastKept, k)
+			}
+		}
+	}
+	s.lastKept[key] = ts
+	return true
+}
+
+// Stats 采样统计
+func (s *EventSampler) Stats() map[string]interface{} {
+	if s == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	s.mu.Lock()
+	keys := len(s.lastKept)
+	s.mu.Unlock()
+	return map[string]interface{}{
+		"enabled":          true,
+		"percent":          s.percent,
+		"interval_seconds": int(s.interval / time.Second),
+		"seen":             atomic.LoadInt64(&s.seen),
+		"dropped":          atomic.LoadInt64(&s.dropped),
+		"tracked_rows":     keys,
+	}
+}
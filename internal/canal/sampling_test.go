@@ -0,0 +1,146 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// TestNewEventSampler 测试采样配置校验，未配置或 100% 时不采样
+func TestNewEventSampler(t *testing.T) {
+	for _, c := range []struct {
+		percent  float64
+		interval int
+	}{{0, 0}, {100, 0}} {
+		sampler, err := NewEventSampler(c.percent, c.interval)
+		if err != nil || sampler != nil {
+			t.Errorf("NewEventSampler(%v, %d) = %v, %v, want nil sampler", c.percent, c.interval, sampler, err)
+		}
+	}
+	for _, c := range []struct {
+		percent  float64
+		interval int
+	}{{-1, 0}, {100.5, 0}, {0, -1}} {
+		if _, err := NewEventSampler(c.percent, c.interval); err == nil {
+			t.Errorf("NewEventSampler(%v, %d) should fail", c.percent, c.interval)
+		}
+	}
+
+	var sampler *EventSampler
+	if !sampler.Keep(partitionTestEvent("orders", 1, 1)) {
+		t.Error("nil sampler should keep every event")
+	}
+}
+
+// TestEventSamplerPercent 测试按比例采样：同一行的结果固定，整体比例接近配置
+func TestEventSamplerPercent(t *testing.T) {
+	sampler, err := NewEventSampler(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay, _ := NewEventSampler(10, 0)
+
+	kept := 0
+	for id := 0; id < 10000; id++ {
+		event := partitionTestEvent("orders", id, 1)
+		keep := sampler.Keep(event)
+		if keep {
+			kept++
+		}
+		// 同一行的后续变更和重放结果一致
+		update := partitionTestEvent("orders", id, 2)
+		update.EventType = EventTypeUpdate
+		if sampler.Keep(update) != keep || replay.Keep(event) != keep {
+			t.Fatalf("sampling of row %d is not deterministic", id)
+		}
+	}
+	if kept < 800 || kept > 1200 {
+		t.Errorf("expected about 1000 of 10000 rows kept, got %d", kept)
+	}
+
+	stats := sampler.Stats()
+	if stats["seen"].(int64) != 20000 || stats["dropped"].(int64) != int64(20000-2*kept) {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
+
+// TestEventSamplerInterval 测试按主键限频：同一行在间隔内只保留第一个事件
+func TestEventSamplerInterval(t *testing.T) {
+	sampler, err := NewEventSampler(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(id int, offset time.Duration) *Event {
+		event := partitionTestEvent("orders", id, 1)
+		event.Timestamp = start.Add(offset)
+		return event
+	}
+
+	cases := []struct {
+		id     int
+		offset time.Duration
+		want   bool
+	}{
+		{1, 0, true},
+		{1, 5 * time.Second, false},
+		{2, 5 * time.Second, true},
+		{1, 10 * time.Second, true},
+		{1, 19 * time.Second, false},
+	}
+	for _, c := range cases {
+		if got := sampler.Keep(at(c.id, c.offset)); got != c.want {
+			t.Errorf("row %d at +%v: keep = %v, want %v", c.id, c.offset, got, c.want)
+		}
+	}
+
+	// 没有主键的表按整张表限频
+	noPK := func(id string, offset time.Duration) *Event {
+		return &Event{ID: id, Schema: "shop", Table: "logs", Timestamp: start.Add(offset)}
+	}
+	if !sampler.Keep(noPK("a", 0)) || sampler.Keep(noPK("b", time.Second)) {
+		t.Error("expected tables without primary key to be limited per table")
+	}
+}
+
+// TestEventSinkSampling 测试未被采样的事件不送往处理器
+func TestEventSinkSampling(t *testing.T) {
+	sink := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	handler := &collectingHandler{}
+	sink.Subscribe("shop", "orders", handler)
+	sampler, err := NewEventSampler(0, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.SetSampler(sampler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := sink.Start(ctx); err != nil {
+		t.Fatalf("failed to start sink: %v", err)
+	}
+	defer sink.Stop()
+
+	now := time.Now()
+	for _, id := range []int{1, 1, 2, 1} {
+		event := partitionTestEvent("orders", id, 1)
+		event.Timestamp = now
+		if err := sink.SendEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events := handler.waitEvents(t, 2)
+	time.Sleep(20 * time.Millisecond)
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if len(handler.events) != 2 || events[1].AfterData.Columns[0].Value != 2 {
+		t.Fatalf("expected the first event of rows 1 and 2, got %d events", len(handler.events))
+	}
+	if stats := sink.SamplingStats(); stats["seen"] != int64(4) || stats["dropped"] != int64(2) {
+		t.Errorf("unexpected sampling stats %v", stats)
+	}
+}
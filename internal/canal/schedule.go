@@ -0,0 +1,187 @@
+package canal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 维护窗口内的处理方式
+const (
+	ScheduleModePause    = "pause"    // 暂停读取，窗口结束后从 binlog 追赶
+	ScheduleModeThrottle = "throttle" // 限速读取
+)
+
+// weekdayNames 星期缩写
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// TimeWindow 每周重复的时间窗口，End 小于 Start 时跨越午夜
+type TimeWindow struct {
+	Days  [7]bool // 按 time.Weekday 索引，指窗口开始的那一天
+	Start int     // 开始时间（当天分钟数）
+	End   int     // 结束时间（当天分钟数，不含）
+}
+
+// Contains 检查时间是否在窗口内
+func (w TimeWindow) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if w.Start < w.End {
+		return w.Days[day] && minute >= w.Start && minute < w.End
+	}
+	// 跨越午夜：开始当天的后半段或次日的前半段
+	yesterday := (day + 6) % 7
+	return (w.Days[day] && minute >= w.Start) || (w.Days[yesterday] && minute < w.End)
+}
+
+// DeliverySchedule 任务的维护窗口
+type DeliverySchedule struct {
+	Windows []TimeWindow
+	Mode    string // pause 或 throttle
+	Rate    int    // throttle 模式下每秒最多读取的 binlog 事件数
+}
+
+// ParseDeliverySchedule 解析维护窗口，spec 为空时返回 nil
+// 格式为分号分隔的窗口，每个窗口为 "[星期] HH:MM-HH:MM"，星期省略表示每天，例如：
+// "Mon-Fri 09:00-18:00; Sat,Sun 22:00-06:00"
+func ParseDeliverySchedule(spec, mode string, rate int) (*DeliverySchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	if mode == "" {
+		mode = ScheduleModePause
+	}
+	switch mode {
+	case ScheduleModePause:
+	case ScheduleModeThrottle:
+		if rate <= 0 {
+			return nil, fmt.Errorf("throttle mode requires a positive rate")
+		}
+	default:
+		return nil, fmt.Errorf("unknown schedule mode %q, expected %s or %s", mode, ScheduleModePause, ScheduleModeThrottle)
+	}
+
+	schedule := &DeliverySchedule{Mode: mode, Rate: rate}
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		window, err := parseTimeWindow(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %v", part, err)
+		}
+		schedule.Windows = append(schedule.Windows, window)
+	}
+	if len(schedule.Windows) == 0 {
+		return nil, fmt.Errorf("no windows in schedule %q", spec)
+	}
+	return schedule, nil
+}
+
+// Active 检查时间是否在任一窗口内
+func (s *DeliverySchedule) Active(t time.Time) bool {
+	if s == nil {
+		return false
+	}
+	for _, w := range s.Windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeWindow 解析单个窗口
+func parseTimeWindow(spec string) (TimeWindow, error) {
+	var window TimeWindow
+
+	fields := strings.Fields(spec)
+	var days, hours string
+	switch len(fields) {
+	case 1:
+		days, hours = "*", fields[0]
+	case 2:
+		days, hours = fields[0], fields[1]
+	default:
+		return window, fmt.Errorf("expected \"[days] HH:MM-HH:MM\"")
+	}
+
+	if err := parseWeekdays(days, &window.Days); err != nil {
+		return window, err
+	}
+
+	bounds := strings.Split(hours, "-")
+	if len(bounds) != 2 {
+		return window, fmt.Errorf("expected time range HH:MM-HH:MM")
+	}
+	var err error
+	if window.Start, err = parseClock(bounds[0]); err != nil {
+		return window, err
+	}
+	if window.End, err = parseClock(bounds[1]); err != nil {
+		return window, err
+	}
+	if window.Start == window.End {
+		return window, fmt.Errorf("empty time range")
+	}
+	return window, nil
+}
+
+// parseWeekdays 解析星期列表，支持 *、Mon、Mon-Fri、Sat,Sun
+func parseWeekdays(spec string, days *[7]bool) error {
+	if spec == "*" {
+		for i := range days {
+			days[i] = true
+		}
+		return nil
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+		bounds := strings.Split(strings.ToLower(item), "-")
+		if len(bounds) > 2 {
+			return fmt.Errorf("invalid weekday range %q", item)
+		}
+		first, ok := weekdayNames[bounds[0]]
+		if !ok {
+			return fmt.Errorf("unknown weekday %q", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdayNames[bounds[1]]; !ok {
+				return fmt.Errorf("unknown weekday %q", bounds[1])
+			}
+		}
+		// 支持 Fri-Mon 这样跨周末的范围
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock 解析 HH:MM 为当天分钟数，允许 24:00 表示午夜
+func parseClock(spec string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q", spec)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", spec)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 || hour < 0 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time %q", spec)
+	}
+	return hour*60 + minute, nil
+}
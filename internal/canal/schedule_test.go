@@ -0,0 +1,192 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// TestParseDeliverySchedule 测试维护窗口解析与匹配
+func TestParseDeliverySchedule(t *testing.T) {
+	schedule, err := ParseDeliverySchedule("Mon-Fri 09:00-18:00; Sat,Sun 22:00-06:00", "", 0)
+	if err != nil {
+		t.Fatalf("ParseDeliverySchedule failed: %v", err)
+	}
+	if schedule.Mode != ScheduleModePause {
+		t.Errorf("expected default mode pause, got %s", schedule.Mode)
+	}
+
+	// 2025-08-25 是周一
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, 8, day, hour, minute, 0, 0, time.Local)
+	}
+	cases := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"monday work hours", at(25, 10, 0), true},
+		{"monday window end is exclusive", at(25, 18, 0), false},
+		{"monday night", at(25, 23, 0), false},
+		{"saturday night", at(30, 23, 30), true},
+		{"sunday early morning from saturday window", at(31, 3, 0), true},
+		{"monday early morning from sunday window", at(25, 5, 59), true},
+		{"sunday window ends monday 06:00", at(25, 6, 0), false},
+		{"sunday afternoon", at(31, 15, 0), false},
+	}
+	for _, c := range cases {
+		if got := schedule.Active(c.t); got != c.want {
+			t.Errorf("%s: Active(%s) = %v, want %v", c.name, c.t.Format("Mon 15:04"), got, c.want)
+		}
+	}
+
+	everyday, err := ParseDeliverySchedule("00:00-24:00", ScheduleModeThrottle, 100)
+	if err != nil {
+		t.Fatalf("ParseDeliverySchedule failed: %v", err)
+	}
+	if !everyday.Active(at(27, 23, 59)) {
+		t.Errorf("expected all-day window to be active")
+	}
+
+	var none *DeliverySchedule
+	if none.Active(time.Now()) {
+		t.Errorf("nil schedule should never be active")
+	}
+
+	for _, bad := range []struct{ spec, mode string }{
+		{"Mon-Fri", ""},
+		{"Funday 09:00-10:00", ""},
+		{"09:00-09:00", ""},
+		{"25:00-26:00", ""},
+		{"09:00-10:00", "throttle"}, // 缺少速率
+		{"09:00-10:00", "skip"},
+	} {
+		if _, err := ParseDeliverySchedule(bad.spec, bad.mode, 0); err == nil {
+			t.Errorf("expected error for %q (mode %q)", bad.spec, bad.mode)
+		}
+	}
+}
+
+// TestRateLimiter 测试令牌桶限速
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(100)
+	ctx := context.Background()
+
+	// 桶容量内不等待
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if _, err := limiter.WaitN(ctx, 1); err != nil {
+			t.Fatalf("WaitN failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("burst should not wait, took %v", elapsed)
+	}
+
+	// 超出后按速率等待
+	waited, err := limiter.WaitN(ctx, 10)
+	if err != nil {
+		t.Fatalf("WaitN failed: %v", err)
+	}
+	if waited < 50*time.Millisecond {
+		t.Errorf("expected to wait about 100ms, waited %v", waited)
+	}
+
+	// 等待期间取消
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := limiter.WaitN(cancelled, 1000); err == nil {
+		t.Errorf("expected error when context cancelled")
+	}
+
+	// 不限速
+	if waited, _ := NewRateLimiter(0).WaitN(ctx, 1e9); waited != 0 {
+		t.Errorf("unlimited limiter should not wait")
+	}
+	var throttle *ReadThrottle
+	if err := throttle.Wait(ctx, 1<<20); err != nil {
+		t.Errorf("nil throttle should not block: %v", err)
+	}
+}
+
+// TestSchedulePauseDisconnects 测试暂停窗口只在事务之外断开连接，断开后保存位置并等待窗口结束
+func TestSchedulePauseDisconnects(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	meta := &memoryMetaManager{positions: make(map[string]Position)}
+	slave, err := NewMySQLBinlogSlaveWithMeta(MySQLConfig{Host: "127.0.0.1", Port: 1, ServerID: 1001},
+		NewDefaultEventSink(logger), logger, meta)
+	if err != nil {
+		t.Fatalf("Failed to create binlog slave: %v", err)
+	}
+	slave.ctx, slave.cancel = context.WithCancel(context.Background())
+	slave.binlogPos.Name = "mysql-bin.000001"
+	schedule, _ := ParseDeliverySchedule("00:00-24:00", "", 0)
+	slave.SetSchedule(schedule)
+	slave.atResumePoint = true
+
+	next := &replication.BinlogEvent{Header: &replication.EventHeader{EventType: replication.QUERY_EVENT}}
+	steps := []struct {
+		eventType replication.EventType
+		event     replication.Event
+		resume    bool
+	}{
+		{replication.ANONYMOUS_GTID_EVENT, &replication.GTIDEvent{}, false},
+		{replication.QUERY_EVENT, &replication.QueryEvent{Query: []byte("BEGIN")}, false},
+		{replication.ROWS_QUERY_EVENT, &replication.RowsQueryEvent{Query: []byte("INSERT INTO orders VALUES (1)")}, false},
+		{replication.XID_EVENT, &replication.XIDEvent{}, true},
+	}
+	for i, step := range steps {
+		// 事务中的事件继续读取，事务提交后才断开
+		if err := slave.waitReadLimits(next); (err == errStreamPaused) != slave.atResumePoint {
+			t.Fatalf("step %d: unexpected waitReadLimits result %v at resume point %v", i, err, slave.atResumePoint)
+		}
+		ev := &replication.BinlogEvent{Header: &replication.EventHeader{EventType: step.eventType, LogPos: uint32(100 * (i + 1))}, Event: step.event}
+		if err := slave.handleBinlogEvent(ev); err != nil {
+			t.Fatalf("handleBinlogEvent failed: %v", err)
+		}
+		slave.updatePosition(ev)
+		if slave.atResumePoint = slave.isResumePoint(ev); slave.atResumePoint != step.resume {
+			t.Errorf("step %d: expected resume point %v", i, step.resume)
+		}
+	}
+	if err := slave.waitReadLimits(next); err != errStreamPaused {
+		t.Fatalf("expected errStreamPaused after commit, got %v", err)
+	}
+
+	// 等待 sink 投递完已读取的事件，以及进行中的异步保存完成
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		slave.mu.RLock()
+		committed := slave.committedPositionLocked()
+		slave.mu.RUnlock()
+		if committed.Pos == 400 && atomic.LoadInt64(&slave.savesStarted) == atomic.LoadInt64(&slave.savesFinished) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected events delivered, got %+v", committed)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- slave.waitStreamResume() }()
+	for {
+		pos, _ := meta.LoadPosition(slave.instanceID)
+		if pos.Pos == 400 && slave.GetStats()["paused"] == true {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected position saved and paused, got %+v", pos)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	slave.cancel()
+	if err := <-done; err == nil {
+		t.Error("expected error after cancel")
+	}
+}
@@ -0,0 +1,81 @@
+package canal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"pikachun/internal/database"
+)
+
+// TaskIncludeSchema 任务是否在载荷中附带表结构元数据
+func TaskIncludeSchema(task *database.Task) bool {
+	return task.IncludeSchema != nil && *task.IncludeSchema
+}
+
+// SchemaColumn 载荷 schema 部分中的一列
+type SchemaColumn struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	PrimaryKey bool   `json:"primary_key,omitempty"`
+}
+
+// EventSchema 一张表的结构描述，Hash 随列名、列类型和主键变化
+type EventSchema struct {
+	Database string         `json:"database"`
+	Table    string         `json:"table"`
+	Hash     string         `json:"hash"`
+	Columns  []SchemaColumn `json:"columns"`
+}
+
+// schemaHash 按列顺序计算结构哈希，取 SHA-256 的前 16 个十六进制字符
+func schemaHash(columns []SchemaColumn) string {
+	var b strings.Builder
+	for _, col := range columns {
+		b.WriteString(col.Name)
+		b.WriteByte(' ')
+		b.WriteString(col.Type)
+		if col.PrimaryKey {
+			b.WriteString(" pk")
+		}
+		b.WriteByte('\n')
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// schemaColumns 表结构中的列描述
+func (ts *TableSchema) schemaColumns() []SchemaColumn {
+	columns := make([]SchemaColumn, len(ts.Columns))
+	for i, col := range ts.Columns {
+		columns[i] = SchemaColumn{Name: col.Name, Type: col.Type, PrimaryKey: col.IsPK}
+	}
+	return columns
+}
+
+// eventSchema 根据事件中的列和主键描述表结构
+// 与 binlog 中的表结构得到相同的哈希，模拟事件没有 SchemaHash 时也能计算
+func eventSchema(event *Event) *EventSchema {
+	row := event.AfterData
+	if row == nil {
+		row = event.BeforeData
+	}
+	if row == nil {
+		return nil
+	}
+
+	pk := make(map[string]bool, len(event.PrimaryKey))
+	for _, name := range event.PrimaryKey {
+		pk[name] = true
+	}
+	columns := make([]SchemaColumn, len(row.Columns))
+	for i, col := range row.Columns {
+		columns[i] = SchemaColumn{Name: col.Name, Type: col.Type, PrimaryKey: pk[col.Name]}
+	}
+
+	hash := event.SchemaHash
+	if hash == "" {
+		hash = schemaHash(columns)
+	}
+	return &EventSchema{Database: event.Schema, Table: event.Table, Hash: hash, Columns: columns}
+}
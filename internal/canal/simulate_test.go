@@ -0,0 +1,102 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// captureHandler 把收到的事件写入通道
+type captureHandler struct {
+	events chan *Event
+}
+
+func (h *captureHandler) Handle(ctx context.Context, event *Event) error {
+	h.events <- event
+	return nil
+}
+
+func (h *captureHandler) GetName() string {
+	return "capture"
+}
+
+func (h *captureHandler) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (h *captureHandler) Close() error {
+	return nil
+}
+
+// TestSimulate 测试模拟事件经事件接收器分发给订阅的处理器
+func TestSimulate(t *testing.T) {
+	cfg := &config.Config{Canal: config.CanalConfig{Host: "localhost", Port: 3307, ServerID: 12345}}
+	instance, err := NewMySQLCanalInstance("task-1", cfg, log.New(io.Discard, "", 0), nil)
+	if err != nil {
+		t.Fatalf("NewMySQLCanalInstance failed: %v", err)
+	}
+
+	handler := &captureHandler{events: make(chan *Event, 1)}
+	instance.Subscribe("shop", "orders", handler)
+
+	sim := SimulatedEvent{EventType: EventTypeInsert, Schema: "shop", Table: "orders",
+		After: map[string]interface{}{"status": "paid", "id": 1, "note": nil}}
+	if _, err := instance.Simulate(sim); err == nil {
+		t.Errorf("expected error when instance is not running")
+	}
+
+	// 不连接 MySQL，只启动事件接收器
+	if err := instance.eventSink.Start(context.Background()); err != nil {
+		t.Fatalf("Start sink failed: %v", err)
+	}
+	defer instance.eventSink.Stop()
+	instance.running = true
+
+	event, err := instance.Simulate(sim)
+	if err != nil {
+		t.Fatalf("Simulate failed: %v", err)
+	}
+	if !strings.HasPrefix(event.ID, SimulatedEventSource+":") {
+		t.Errorf("expected simulated event id, got %s", event.ID)
+	}
+
+	select {
+	case received := <-handler.events:
+		if received.ID != event.ID || received.BeforeData != nil || received.AfterData == nil {
+			t.Fatalf("unexpected event: %+v", received)
+		}
+		columns := received.AfterData.Columns
+		if len(columns) != 3 || columns[0].Name != "id" || columns[1].Name != "note" || !columns[1].IsNull || columns[2].Value != "paid" {
+			t.Errorf("unexpected columns: %+v", columns)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("simulated event was not delivered")
+	}
+
+	// 再次模拟得到不同的ID
+	second, err := instance.Simulate(sim)
+	if err != nil {
+		t.Fatalf("Simulate failed: %v", err)
+	}
+	if second.ID == event.ID {
+		t.Errorf("expected unique ids, got %s twice", event.ID)
+	}
+	<-handler.events
+
+	for _, bad := range []SimulatedEvent{
+		{EventType: EventTypeInsert, Schema: "shop", Table: "orders"},
+		{EventType: EventTypeUpdate, Schema: "shop", Table: "orders", After: sim.After},
+		{EventType: EventTypeDelete, Schema: "shop", Table: "orders", After: sim.After},
+		{EventType: "TRUNCATE", Schema: "shop", Table: "orders", After: sim.After},
+		{EventType: EventTypeInsert, After: sim.After},
+	} {
+		if _, err := instance.Simulate(bad); err == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+}
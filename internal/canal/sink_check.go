@@ -0,0 +1,45 @@
+package canal
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CheckWebhookEndpoint 检查 Webhook 端点是否可达：先发送 HEAD，端点不支持时改用 OPTIONS。
+// 端点只接受 POST 时返回的 405 视为可达；连接失败、404 和 5xx 视为不可达
+func CheckWebhookEndpoint(endpoint string, timeout time.Duration) error {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid webhook url %q", endpoint)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	status, err := probeEndpoint(client, http.MethodHead, endpoint)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = probeEndpoint(client, http.MethodOptions, endpoint)
+	}
+	if err != nil {
+		return fmt.Errorf("webhook endpoint %s is unreachable: %v", endpoint, err)
+	}
+	if status == http.StatusNotFound || (status >= 500 && status != http.StatusNotImplemented) {
+		return fmt.Errorf("webhook endpoint %s returned %d", endpoint, status)
+	}
+	return nil
+}
+
+// probeEndpoint 发送不带请求体的探测请求，返回响应状态码
+func probeEndpoint(client *http.Client, method, endpoint string) (int, error) {
+	req, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "Canal-Pikachun/1.0")
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
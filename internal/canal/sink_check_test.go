@@ -0,0 +1,49 @@
+package canal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestCheckWebhookEndpoint 测试 Webhook 端点可达性检查
+func TestCheckWebhookEndpoint(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.URL.Path {
+		case "/hook":
+			// 只接受 POST 的端点
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/options":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotImplemented)
+			}
+		case "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	if err := CheckWebhookEndpoint(server.URL+"/hook", time.Second); err != nil {
+		t.Errorf("expected POST-only endpoint to be reachable: %v", err)
+	}
+	methods = nil
+	if err := CheckWebhookEndpoint(server.URL+"/options", time.Second); err != nil {
+		t.Errorf("expected OPTIONS fallback to pass: %v", err)
+	}
+	if len(methods) != 2 || methods[1] != http.MethodOptions {
+		t.Errorf("expected HEAD then OPTIONS, got %v", methods)
+	}
+
+	for _, endpoint := range []string{server.URL + "/down", server.URL + "/missing", "ftp://localhost/hook", "http://127.0.0.1:1/hook"} {
+		if err := CheckWebhookEndpoint(endpoint, time.Second); err == nil {
+			t.Errorf("expected error for %s", endpoint)
+		}
+	}
+}
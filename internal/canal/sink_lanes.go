@@ -0,0 +1,466 @@
+package canal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// 一个任务的事件可以同时送往多个 sink（Webhook、事件日志、ClickHouse、归档等处理器）。
+// 每个 sink 有自己的队列和处理协程，按 binlog 顺序逐个处理：慢的 sink 只积压自己的队列，不阻塞其他 sink；
+// 队列满时分发等待，binlog 读取随之暂停，不丢事件。
+// 每个 sink 记录自己的投递进度（offset），之前的事件都已由该 sink 处理完成。实例保存的位置是各 sink 进度的最小值，
+// 重启后从这里重新读取，进度更靠后的 sink 跳过已处理过的事件，只有落后的 sink 收到重复的事件；
+// 回退单个 sink 的进度即可只向它重放，见 RewindSink
+
+// defaultSinkQueueSize 每个 sink 队列的默认容量
+const defaultSinkQueueSize = 1000
+
+// SinkPolicy sink 队列的配置
+type SinkPolicy struct {
+	QueueSize int // 每个 sink 队列中最多等待处理的事件数，决定慢的 sink 最多落后其他 sink 多少个事件
+}
+
+// NewSinkPolicy 根据配置创建 sink 队列配置，未配置或无效时使用默认值
+func NewSinkPolicy(cfg config.SinksConfig) SinkPolicy {
+	policy := SinkPolicy{QueueSize: cfg.QueueSize}
+	if policy.QueueSize <= 0 {
+		policy.QueueSize = defaultSinkQueueSize
+	}
+	return policy
+}
+
+// SetSinkPolicy 设置 sink 队列的配置，已在排队的事件不受影响
+func (s *DefaultEventSink) SetSinkPolicy(policy SinkPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sinkPolicy = policy
+	s.logger.Printf("🔧 Sink queue size: %d", policy.QueueSize)
+}
+
+// SinkOffsetStore 保存各 sink 投递进度的元数据管理器，见 DBMetaManager
+type SinkOffsetStore interface {
+	SaveSinkOffsets(instanceID string, offsets map[string]Position) error
+	LoadSinkOffsets(instanceID string) (map[string]Position, error)
+	DeleteSinkOffsets(instanceID string) error
+}
+
+// sinkEvent 分发给各 sink 的事件，所有 sink 处理完后释放占用的队列字节数
+type sinkEvent struct {
+	event     *Event
+	size      int64
+	from      Position // 该事件之前的位置，sink 处理完它之前的进度
+	remaining int32    // 尚未处理完的 sink 数，原子访问
+}
+
+// laneItem sink 队列中的事件
+type laneItem struct {
+	*sinkEvent
+	route   Route
+	timeout time.Duration
+}
+
+// sinkLane 一个 sink 的队列、进度和投递状态
+type sinkLane struct {
+	name  string
+	ready chan struct{} // 入队时通知处理协程
+	space chan struct{} // 出队时通知等待的分发方
+
+	mu        sync.Mutex
+	items     []laneItem // 等待处理的事件，第一个可能正在处理
+	skipUntil Position   // 重启前已处理到的位置，不晚于它的事件跳过
+
+	handled             Position // 最近处理完的事件位置
+	delivered           int64
+	failed              int64
+	skipped             int64
+	waits               int64 // 队列满时分发等待的次数
+	consecutiveFailures int64
+	lastError           string
+	lastErrorAt         time.Time
+	lastSuccessAt       time.Time
+}
+
+// SinkInfo sink 的队列和投递状态
+type SinkInfo struct {
+	Name                string     `json:"name"`
+	Queued              int        `json:"queued"` // 队列中等待处理的事件数
+	Delivered           int64      `json:"delivered"`
+	Failed              int64      `json:"failed"`
+	Skipped             int64      `json:"skipped"` // 重启后跳过的已处理过的事件数
+	Waits               int64      `json:"waits"`   // 队列满时分发等待的次数，持续增长说明该 sink 拖慢了读取
+	ConsecutiveFailures int64      `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	Position            Position   `json:"position"`             // 最近处理完的事件位置
+	SkipUntil           *Position  `json:"skip_until,omitempty"` // 不晚于该位置的事件跳过，赶上后为空
+}
+
+func newSinkLane(name string) *sinkLane {
+	return &sinkLane{
+		name:  name,
+		ready: make(chan struct{}, 1),
+		space: make(chan struct{}, 1),
+	}
+}
+
+// skip 事件是否不晚于重启前已处理到的位置，之后的事件到达后不再检查
+func (l *sinkLane) skip(pos Position) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.skipUntil.Name == "" || pos.Name == "" {
+		return false
+	}
+	if ComparePositions(pos, l.skipUntil) <= 0 {
+		l.skipped++
+		return true
+	}
+	l.skipUntil = Position{}
+	return false
+}
+
+// push 把事件加入队列，队列满时等待。停止后不再等待，照常入队，进度不会越过未处理的事件
+func (l *sinkLane) push(ctx context.Context, item laneItem, capacity int) (waited bool) {
+	for {
+		l.mu.Lock()
+		if len(l.items) < capacity || ctx.Err() != nil {
+			l.items = append(l.items, item)
+			l.mu.Unlock()
+			signal(l.ready)
+			return waited
+		}
+		if !waited {
+			l.waits++
+		}
+		l.mu.Unlock()
+		waited = true
+		select {
+		case <-l.space:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// front 队列中的第一个事件，处理完后才出队
+func (l *sinkLane) front() (laneItem, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.items) == 0 {
+		return laneItem{}, false
+	}
+	return l.items[0], true
+}
+
+// pop 第一个事件处理完成，记录结果后出队
+func (l *sinkLane) pop(err error) {
+	l.mu.Lock()
+	item := l.items[0]
+	l.items[0] = laneItem{}
+	l.items = l.items[1:]
+	if item.event.Position.Name != "" {
+		l.handled = item.event.Position
+	}
+	if err != nil {
+		l.failed++
+		l.consecutiveFailures++
+		l.lastError = err.Error()
+		l.lastErrorAt = time.Now()
+	} else {
+		l.delivered++
+		l.consecutiveFailures = 0
+		l.lastSuccessAt = time.Now()
+	}
+	l.mu.Unlock()
+	signal(l.space)
+}
+
+// pending 队列中第一个事件之前的位置和 true，队列为空时返回 false
+func (l *sinkLane) pending() (Position, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.items) == 0 {
+		return Position{}, false
+	}
+	return l.items[0].from, true
+}
+
+func (l *sinkLane) info() SinkInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	info := SinkInfo{
+		Name:                l.name,
+		Queued:              len(l.items),
+		Delivered:           l.delivered,
+		Failed:              l.failed,
+		Skipped:             l.skipped,
+		Waits:               l.waits,
+		ConsecutiveFailures: l.consecutiveFailures,
+		LastError:           l.lastError,
+		Position:            l.handled,
+	}
+	if !l.lastErrorAt.IsZero() {
+		at := l.lastErrorAt
+		info.LastErrorAt = &at
+	}
+	if !l.lastSuccessAt.IsZero() {
+		at := l.lastSuccessAt
+		info.LastSuccessAt = &at
+	}
+	if l.skipUntil.Name != "" {
+		skip := l.skipUntil
+		info.SkipUntil = &skip
+	}
+	return info
+}
+
+// signal 非阻塞地发出通知
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// lane 处理器名称对应的 sink 队列，不存在时创建并启动处理协程
+func (s *DefaultEventSink) lane(ctx context.Context, name string) *sinkLane {
+	s.lanesMu.Lock()
+	defer s.lanesMu.Unlock()
+	if lane := s.lanes[name]; lane != nil {
+		return lane
+	}
+	if s.lanes == nil {
+		s.lanes = make(map[string]*sinkLane)
+	}
+	lane := newSinkLane(name)
+	lane.skipUntil = s.resume[name]
+	s.lanes[name] = lane
+	s.laneWg.Add(1)
+	go s.runLane(ctx, lane)
+	return lane
+}
+
+// runLane sink 的处理协程，按入队顺序逐个交给处理器。停止时正在处理的事件留在队列中
+func (s *DefaultEventSink) runLane(ctx context.Context, lane *sinkLane) {
+	defer s.laneWg.Done()
+	for {
+		item, ok := lane.front()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-lane.ready:
+			}
+			continue
+		}
+		done, err := s.deliver(ctx, item)
+		if !done {
+			return
+		}
+		lane.pop(err)
+		if atomic.AddInt32(&item.remaining, -1) == 0 {
+			atomic.AddInt64(&s.queuedBytes, -item.size)
+		}
+	}
+}
+
+// deliver 把事件交给 sink 的处理器，停止导致处理中断时返回 false
+func (s *DefaultEventSink) deliver(ctx context.Context, item laneItem) (bool, error) {
+	name, event := item.route.Name, item.event
+	s.logSampler.Printf(s.logger, LogPathHandle, "🔄 Handler %s started processing event", name)
+
+	if fault, ok := Faults.Trigger(FaultDelayDelivery, name); ok {
+		s.logger.Printf("💥 Injected %v delivery delay for handler %s", fault.Delay(), name)
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-time.After(fault.Delay()):
+		}
+	}
+
+	handleCtx, cancel := context.WithTimeout(ctx, item.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := item.route.Handler.Handle(handleCtx, event)
+	if err != nil && ctx.Err() != nil {
+		s.logger.Printf("🛑 Handler %s interrupted by stop, event %s will be re-read", name, event.ID)
+		return false, nil
+	}
+	elapsed := time.Since(start)
+	s.recordHandlerLatency(elapsed)
+	item.route.stats.addHandled(err, elapsed)
+	if err != nil {
+		s.logger.Printf("❌ Handler %s failed to process event %s: %v", name, event.ID, err)
+	} else {
+		s.logSampler.Printf(s.logger, LogPathHandle, "✅ Handler %s completed processing event", name)
+	}
+	return true, err
+}
+
+// positionBefore 事件之前的位置：同一行事件的多行位置相同，其余行之前只有 ackedBefore 是完整的
+func (s *DefaultEventSink) positionBefore(pos Position) Position {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	if pos.Name != "" && ComparePositions(pos, s.acked) == 0 {
+		return s.ackedBefore
+	}
+	return s.acked
+}
+
+// SetSinkOffsets 设置重启前各 sink 已处理到的位置，之后读到不晚于该位置的事件不再送往该 sink。
+// 在实例从持久化的位置开始读取前调用
+func (s *DefaultEventSink) SetSinkOffsets(offsets map[string]Position) {
+	s.lanesMu.Lock()
+	defer s.lanesMu.Unlock()
+	s.resume = offsets
+	for name, pos := range offsets {
+		if lane := s.lanes[name]; lane != nil {
+			lane.mu.Lock()
+			lane.skipUntil = pos
+			lane.mu.Unlock()
+		}
+		s.logger.Printf("📍 Sink %s resumes after %s:%d", name, pos.Name, pos.Pos)
+	}
+}
+
+// clearSinkOffsets 清除各 sink 跳过事件的位置，binlog 位置被回退后调用
+func (s *DefaultEventSink) clearSinkOffsets() {
+	s.lanesMu.Lock()
+	defer s.lanesMu.Unlock()
+	s.resume = nil
+	for _, lane := range s.lanes {
+		lane.mu.Lock()
+		lane.skipUntil = Position{}
+		lane.mu.Unlock()
+	}
+}
+
+// SinkOffsets 各 sink 可以安全持久化的进度，read 和 CommittedPosition 相同。
+// 尚未跳过完重启前已处理的事件时不早于跳过的位置；无法确定进度的 sink 不包含在内
+func (s *DefaultEventSink) SinkOffsets(read Position) map[string]Position {
+	base := s.basePosition(read)
+
+	s.lanesMu.Lock()
+	lanes := make([]*sinkLane, 0, len(s.lanes))
+	for _, lane := range s.lanes {
+		lanes = append(lanes, lane)
+	}
+	s.lanesMu.Unlock()
+
+	offsets := make(map[string]Position, len(lanes))
+	for _, lane := range lanes {
+		// 先看队列再看处理器，与 CommittedPosition 相同
+		offset := base
+		if pos, pending := lane.pending(); pending && ComparePositions(pos, offset) < 0 {
+			offset = pos
+		}
+		if handler, ok := s.GetHandler(lane.name); ok {
+			if tracker, ok := handler.(DeliveryTracker); ok {
+				if pos, pending := tracker.DeliveredPosition(); pending && ComparePositions(pos, offset) < 0 {
+					offset = pos
+				}
+			}
+		}
+		lane.mu.Lock()
+		if ComparePositions(lane.skipUntil, offset) > 0 {
+			offset = lane.skipUntil
+		}
+		lane.mu.Unlock()
+		if offset.Name != "" {
+			offsets[lane.name] = offset
+		}
+	}
+	return offsets
+}
+
+// SinkStats 各 sink 的队列和投递状态，按名称排列
+func (s *DefaultEventSink) SinkStats() []SinkInfo {
+	s.lanesMu.Lock()
+	infos := make([]SinkInfo, 0, len(s.lanes))
+	for _, lane := range s.lanes {
+		infos = append(infos, lane.info())
+	}
+	s.lanesMu.Unlock()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// queuedInLanes 各 sink 队列中的事件数之和
+func (s *DefaultEventSink) queuedInLanes() int64 {
+	s.lanesMu.Lock()
+	defer s.lanesMu.Unlock()
+	var total int64
+	for _, lane := range s.lanes {
+		lane.mu.Lock()
+		total += int64(len(lane.items))
+		lane.mu.Unlock()
+	}
+	return total
+}
+
+// RewindSink 把实例中一个 sink 的投递进度设为 pos，实例下次启动时该 sink 重新收到 pos 之后的事件，
+// 其他 sink 跳过已处理过的事件。pos 早于实例保存的位置时实例位置随之回退，
+// sinks 中没有记录进度或进度更早的 sink 以原来保存的位置为进度。需在实例停止后调用
+func RewindSink(meta MetaManager, instanceID, sink string, pos Position, sinks []string) error {
+	store, ok := meta.(SinkOffsetStore)
+	if !ok {
+		return fmt.Errorf("meta manager does not support sink offsets")
+	}
+	saved, err := meta.LoadPosition(instanceID)
+	if err != nil {
+		return err
+	}
+	offsets, err := store.LoadSinkOffsets(instanceID)
+	if err != nil {
+		return err
+	}
+
+	rewind := saved.Name == "" || ComparePositions(pos, saved) < 0
+	update := map[string]Position{sink: pos}
+	if rewind && saved.Name != "" {
+		for _, name := range sinks {
+			if offset, ok := offsets[name]; name != sink && (!ok || ComparePositions(offset, saved) < 0) {
+				update[name] = saved
+			}
+		}
+	}
+	// 先保存进度再回退实例位置，中途失败时最多重复投递
+	if err := store.SaveSinkOffsets(instanceID, update); err != nil {
+		return err
+	}
+	if rewind {
+		return meta.SavePosition(instanceID, pos)
+	}
+	return nil
+}
+
+// restoreSinkOffsets 从元数据管理器恢复各 sink 的进度，从持久化的位置重新读取时跳过各 sink 已处理过的事件
+func (m *MySQLBinlogSlave) restoreSinkOffsets() {
+	store, ok := m.metaManager.(SinkOffsetStore)
+	if !ok {
+		return
+	}
+	offsets, err := store.LoadSinkOffsets(m.instanceID)
+	if err != nil {
+		m.logger.Printf("⚠️ Failed to load sink offsets, all sinks resume from the saved position: %v", err)
+		return
+	}
+	m.eventSink.SetSinkOffsets(offsets)
+}
+
+// saveSinkOffsets 保存各 sink 的进度，先于实例位置保存，中途失败时最多重复投递
+func (m *MySQLBinlogSlave) saveSinkOffsets(offsets map[string]Position) {
+	store, ok := m.metaManager.(SinkOffsetStore)
+	if !ok || len(offsets) == 0 {
+		return
+	}
+	if err := store.SaveSinkOffsets(m.instanceID, offsets); err != nil {
+		m.logger.Printf("❌ Failed to save sink offsets: %v", err)
+	}
+}
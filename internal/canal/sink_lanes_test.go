@@ -0,0 +1,196 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitSinkLanes 等待各 sink 队列中的事件处理完
+func waitSinkLanes(t *testing.T, eventSink *DefaultEventSink) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for eventSink.queuedInLanes() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("sink queues not drained, %d events left", eventSink.queuedInLanes())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// blockingEventHandler 收到的事件等待 release 后才处理完
+type blockingEventHandler struct {
+	name    string
+	release chan struct{}
+	mu      sync.Mutex
+	events  []string
+}
+
+func (h *blockingEventHandler) Handle(ctx context.Context, event *Event) error {
+	select {
+	case <-h.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event.ID)
+	return nil
+}
+
+func (h *blockingEventHandler) GetName() string                 { return h.name }
+func (h *blockingEventHandler) Flush(ctx context.Context) error { return nil }
+func (h *blockingEventHandler) Close() error                    { return nil }
+
+func (h *blockingEventHandler) handled() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.events)
+}
+
+// sinkTestEvent 位于 mysql-bin.000001 的 pos 处的事件
+func sinkTestEvent(pos uint32) *Event {
+	return &Event{ID: string(rune('a' + pos/100)), Schema: "shop", Table: "orders", EventType: EventTypeInsert,
+		Position: Position{Name: "mysql-bin.000001", Pos: pos}}
+}
+
+// TestSinkLanesIndependent 测试慢的 sink 不阻塞其他 sink，保存的位置不越过慢 sink 未处理的事件
+func TestSinkLanesIndependent(t *testing.T) {
+	eventSink := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	eventSink.ctx = context.Background()
+	slow := &blockingEventHandler{name: "webhook-1", release: make(chan struct{})}
+	fast := &countingEventHandler{name: "db-1"}
+	eventSink.Subscribe("shop", "orders", slow)
+	eventSink.Subscribe("shop", "orders", fast)
+
+	for _, pos := range []uint32{100, 200, 300} {
+		// 与 SendEvent 入队时相同，计入事件占用的字节数
+		atomic.AddInt64(&eventSink.queuedBytes, 10)
+		eventSink.processQueued(queuedEvent{event: sinkTestEvent(pos), size: 10})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for eventSink.SinkStats()[0].Delivered != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("fast sink should handle all events while the slow sink is blocked")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	read := Position{Name: "mysql-bin.000001", Pos: 400}
+	// 慢 sink 的第一个事件之前还没有已确认的位置，无法确定
+	if committed := eventSink.CommittedPosition(read); committed.Name != "" {
+		t.Errorf("expected undetermined position, got %+v", committed)
+	}
+	slow.release <- struct{}{}
+	for slow.handled() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	waitFor := func(want uint32) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for eventSink.CommittedPosition(read).Pos != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected committed position %d, got %+v", want, eventSink.CommittedPosition(read))
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor(100)
+	offsets := eventSink.SinkOffsets(read)
+	if offsets["db-1"].Pos != 400 || offsets["webhook-1"].Pos != 100 {
+		t.Errorf("unexpected sink offsets %+v", offsets)
+	}
+	if queued := eventSink.PendingEvents(); queued != 2 {
+		t.Errorf("expected 2 pending events, got %d", queued)
+	}
+
+	close(slow.release)
+	waitSinkLanes(t, eventSink)
+	waitFor(400)
+	if got := eventSink.MemoryUsage(); got != 0 {
+		t.Errorf("expected queue bytes released after all sinks handled the events, got %d", got)
+	}
+}
+
+// TestSinkOffsetsSkip 测试重启后各 sink 跳过已处理过的事件
+func TestSinkOffsetsSkip(t *testing.T) {
+	eventSink := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	eventSink.ctx = context.Background()
+	webhook := &countingEventHandler{name: "webhook-1"}
+	eventLog := &countingEventHandler{name: "db-1"}
+	eventSink.Subscribe("shop", "orders", webhook)
+	eventSink.Subscribe("shop", "orders", eventLog)
+	eventSink.SetSinkOffsets(map[string]Position{"webhook-1": {Name: "mysql-bin.000001", Pos: 200}})
+
+	for _, pos := range []uint32{100, 200, 300, 100} {
+		eventSink.processQueued(queuedEvent{event: sinkTestEvent(pos)})
+	}
+	waitSinkLanes(t, eventSink)
+	// 越过跳过位置后不再检查，之后位置更早的事件照常处理
+	if webhook.count != 2 || eventLog.count != 4 {
+		t.Errorf("expected 2 and 4 events, got %d and %d", webhook.count, eventLog.count)
+	}
+	stats := eventSink.SinkStats()
+	if stats[1].Name != "webhook-1" || stats[1].Skipped != 2 || stats[1].SkipUntil != nil {
+		t.Errorf("unexpected sink stats %+v", stats[1])
+	}
+}
+
+// sinkOffsetMetaManager 支持 sink 进度的内存元数据管理器
+type sinkOffsetMetaManager struct {
+	memoryMetaManager
+	offsets map[string]map[string]Position
+}
+
+func (m *sinkOffsetMetaManager) SaveSinkOffsets(instanceID string, offsets map[string]Position) error {
+	if m.offsets[instanceID] == nil {
+		m.offsets[instanceID] = make(map[string]Position)
+	}
+	for sink, pos := range offsets {
+		m.offsets[instanceID][sink] = pos
+	}
+	return nil
+}
+
+func (m *sinkOffsetMetaManager) LoadSinkOffsets(instanceID string) (map[string]Position, error) {
+	return m.offsets[instanceID], nil
+}
+
+func (m *sinkOffsetMetaManager) DeleteSinkOffsets(instanceID string) error {
+	delete(m.offsets, instanceID)
+	return nil
+}
+
+// TestRewindSink 测试回退单个 sink 的进度：实例位置随之回退，其他 sink 以原来的位置为进度
+func TestRewindSink(t *testing.T) {
+	saved := Position{Name: "mysql-bin.000002", Pos: 500}
+	meta := &sinkOffsetMetaManager{
+		memoryMetaManager: memoryMetaManager{positions: map[string]Position{"task-1": saved}},
+		offsets:           map[string]map[string]Position{"task-1": {"db-1": {Name: "mysql-bin.000002", Pos: 900}}},
+	}
+
+	rewind := Position{Name: "mysql-bin.000001", Pos: 4}
+	if err := RewindSink(meta, "task-1", "webhook-1", rewind, TaskHandlerNames(1)); err != nil {
+		t.Fatal(err)
+	}
+	if pos, _ := meta.LoadPosition("task-1"); pos != rewind {
+		t.Errorf("expected instance position rewound to %+v, got %+v", rewind, pos)
+	}
+	offsets := meta.offsets["task-1"]
+	if offsets["webhook-1"] != rewind || offsets["db-1"].Pos != 900 || offsets["archive-1"] != saved {
+		t.Errorf("unexpected sink offsets %+v", offsets)
+	}
+
+	// 前移只修改该 sink 的进度
+	forward := Position{Name: "mysql-bin.000003", Pos: 4}
+	if err := RewindSink(meta, "task-1", "archive-1", forward, TaskHandlerNames(1)); err != nil {
+		t.Fatal(err)
+	}
+	if pos, _ := meta.LoadPosition("task-1"); pos != rewind || meta.offsets["task-1"]["archive-1"] != forward {
+		t.Errorf("forward move should only change the sink offset, got %+v and %+v", pos, meta.offsets["task-1"])
+	}
+}
@@ -0,0 +1,101 @@
+package canal
+
+import (
+	"sort"
+	"time"
+)
+
+// DeliveryReporter 自行缓冲、批量投递的处理器，报告投递到下游的结果。
+// Handle 返回只表示事件已进入处理器的缓冲区，未实现该接口的处理器以 Handle 的结果作为投递结果
+type DeliveryReporter interface {
+	DeliveryStatus() DeliveryStatus
+}
+
+// DeliveryStatus 处理器投递到下游的累计结果
+type DeliveryStatus struct {
+	Delivered     int64 // 已投递到下游的事件数
+	Pending       int64 // 缓冲中和投递中的事件数
+	Failed        int64 // 失败的投递次数
+	DeadLettered  int64 // 重试全部失败、记入失败事件列表的事件数
+	LastError     string
+	LastErrorAt   time.Time
+	LastSuccessAt time.Time
+}
+
+// SinkStatus 任务某个 sink 的投递状态：sink 队列和处理器投递结果的汇总
+type SinkStatus struct {
+	Name          string     `json:"name"`
+	Delivered     int64      `json:"delivered"`
+	Pending       int64      `json:"pending"` // sink 队列中加处理器缓冲和投递中的事件数
+	Failed        int64      `json:"failed"`
+	DLQ           int64      `json:"dlq"` // 进入失败事件列表的事件数
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty" format:"date-time"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty" format:"date-time"`
+}
+
+// SinkStatuses 各 sink 的投递状态，按名称排序。已订阅但还没有收到事件的 sink 也列出
+func (s *DefaultEventSink) SinkStatuses() []SinkStatus {
+	lanes := make(map[string]SinkInfo)
+	for _, info := range s.SinkStats() {
+		lanes[info.Name] = info
+	}
+
+	s.mu.RLock()
+	handlers := make(map[string]EventHandler)
+	for _, subscribed := range s.handlers {
+		for name, handler := range subscribed {
+			handlers[name] = handler
+		}
+	}
+	s.mu.RUnlock()
+
+	statuses := make([]SinkStatus, 0, len(handlers))
+	for name, handler := range handlers {
+		statuses = append(statuses, sinkStatus(name, lanes[name], handler))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// sinkStatus 汇总 sink 队列和处理器的统计，处理器报告了投递结果时以其为准，最近错误和成功时间取两者中较晚的
+func sinkStatus(name string, lane SinkInfo, handler EventHandler) SinkStatus {
+	status := SinkStatus{
+		Name:      name,
+		Delivered: lane.Delivered,
+		Pending:   int64(lane.Queued),
+		Failed:    lane.Failed,
+		LastError: lane.LastError,
+	}
+	var lastErrorAt, lastSuccessAt time.Time
+	if lane.LastErrorAt != nil {
+		lastErrorAt = *lane.LastErrorAt
+	}
+	if lane.LastSuccessAt != nil {
+		lastSuccessAt = *lane.LastSuccessAt
+	}
+
+	if reporter, ok := handler.(DeliveryReporter); ok {
+		delivery := reporter.DeliveryStatus()
+		status.Delivered = delivery.Delivered
+		status.Pending += delivery.Pending
+		status.Failed = delivery.Failed
+		status.DLQ = delivery.DeadLettered
+		if delivery.LastErrorAt.After(lastErrorAt) {
+			status.LastError, lastErrorAt = delivery.LastError, delivery.LastErrorAt
+		}
+		if delivery.LastSuccessAt.After(lastSuccessAt) {
+			lastSuccessAt = delivery.LastSuccessAt
+		}
+	} else if pending, ok := handler.(PendingHandler); ok {
+		status.Pending += pending.PendingEvents()
+	}
+
+	if !lastErrorAt.IsZero() {
+		status.LastErrorAt = &lastErrorAt
+	}
+	if !lastSuccessAt.IsZero() {
+		status.LastSuccessAt = &lastSuccessAt
+	}
+	return status
+}
@@ -0,0 +1,57 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// reportingEventHandler 自行报告投递结果的处理器
+type reportingEventHandler struct {
+	countingEventHandler
+	status DeliveryStatus
+}
+
+func (h *reportingEventHandler) DeliveryStatus() DeliveryStatus {
+	return h.status
+}
+
+// TestSinkStatuses 测试各 sink 的投递状态：处理器报告的投递结果优先，未收到事件的 sink 也列出
+func TestSinkStatuses(t *testing.T) {
+	eventSink := NewDefaultEventSink(log.New(io.Discard, "", 0))
+	eventSink.ctx = context.Background()
+	failedAt := time.Now().Add(time.Minute)
+	webhook := &reportingEventHandler{
+		countingEventHandler: countingEventHandler{name: "webhook-1"},
+		status: DeliveryStatus{Delivered: 1, Pending: 1, Failed: 3, DeadLettered: 2,
+			LastError: "HTTP 503", LastErrorAt: failedAt},
+	}
+	eventSink.Subscribe("shop", "orders", webhook)
+	eventSink.Subscribe("shop", "orders", &countingEventHandler{name: "db-1"})
+	eventSink.Subscribe("shop", "users", &countingEventHandler{name: "archive-1"})
+
+	for _, pos := range []uint32{100, 200} {
+		eventSink.processQueued(queuedEvent{event: sinkTestEvent(pos)})
+	}
+	waitSinkLanes(t, eventSink)
+
+	statuses := eventSink.SinkStatuses()
+	if len(statuses) != 3 {
+		t.Fatalf("expected 3 sinks, got %+v", statuses)
+	}
+	if s := statuses[0]; s.Name != "archive-1" || s.Delivered != 0 || s.LastSuccessAt != nil {
+		t.Errorf("unexpected status of idle sink %+v", s)
+	}
+	if s := statuses[1]; s.Name != "db-1" || s.Delivered != 2 || s.Pending != 0 || s.LastSuccessAt == nil {
+		t.Errorf("unexpected status of db sink %+v", s)
+	}
+	s := statuses[2]
+	if s.Delivered != 1 || s.Pending != 1 || s.Failed != 3 || s.DLQ != 2 {
+		t.Errorf("expected counts reported by the handler, got %+v", s)
+	}
+	if s.LastError != "HTTP 503" || s.LastErrorAt == nil || !s.LastErrorAt.Equal(failedAt) || s.LastSuccessAt == nil {
+		t.Errorf("unexpected last error or success of webhook sink %+v", s)
+	}
+}
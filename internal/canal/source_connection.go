@@ -0,0 +1,93 @@
+package canal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+
+	"pikachun/internal/config"
+)
+
+// 源库连接默认配置
+const (
+	defaultHeartbeatPeriod = 30 * time.Second
+	defaultReadTimeout     = 90 * time.Second
+	defaultKeepAlive       = 15 * time.Second
+	defaultDialTimeout     = 10 * time.Second
+)
+
+// SourceConnection 源库连接的心跳、读超时、TCP keepalive 和连接超时，零值的项使用默认值
+type SourceConnection struct {
+	HeartbeatPeriod time.Duration `json:"heartbeat_period"`
+	ReadTimeout     time.Duration `json:"read_timeout"`
+	KeepAlive       time.Duration `json:"keepalive"` // 0 表示使用系统默认值
+	DialTimeout     time.Duration `json:"dial_timeout"`
+}
+
+// DefaultSourceConnection 默认的源库连接配置
+func DefaultSourceConnection() SourceConnection {
+	return SourceConnection{
+		HeartbeatPeriod: defaultHeartbeatPeriod,
+		ReadTimeout:     defaultReadTimeout,
+		KeepAlive:       defaultKeepAlive,
+		DialTimeout:     defaultDialTimeout,
+	}
+}
+
+// NewSourceConnection 根据配置创建源库连接配置，未配置的项使用默认值。
+// 时长无效或心跳间隔不小于读超时时返回错误：读超时内收不到心跳，空闲的连接会被反复断开重连
+func NewSourceConnection(cfg config.SourceConnectionConfig) (SourceConnection, error) {
+	conn := DefaultSourceConnection()
+	for _, item := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"heartbeat_period", cfg.HeartbeatPeriod, &conn.HeartbeatPeriod},
+		{"read_timeout", cfg.ReadTimeout, &conn.ReadTimeout},
+		{"keepalive", cfg.KeepAlive, &conn.KeepAlive},
+		{"dial_timeout", cfg.DialTimeout, &conn.DialTimeout},
+	} {
+		if item.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(item.value)
+		if err != nil || d < 0 {
+			return SourceConnection{}, fmt.Errorf("invalid %s %q", item.name, item.value)
+		}
+		*item.dst = d
+	}
+	if conn.HeartbeatPeriod <= 0 || conn.ReadTimeout <= 0 || conn.DialTimeout <= 0 {
+		return SourceConnection{}, fmt.Errorf("heartbeat_period, read_timeout and dial_timeout must be positive")
+	}
+	if conn.HeartbeatPeriod >= conn.ReadTimeout {
+		return SourceConnection{}, fmt.Errorf("heartbeat_period %v must be less than read_timeout %v", conn.HeartbeatPeriod, conn.ReadTimeout)
+	}
+	return conn, nil
+}
+
+// withDefaults 零值的项使用默认值
+func (c SourceConnection) withDefaults() SourceConnection {
+	defaults := DefaultSourceConnection()
+	if c.HeartbeatPeriod <= 0 {
+		c.HeartbeatPeriod = defaults.HeartbeatPeriod
+	}
+	if c.ReadTimeout <= 0 {
+		c.ReadTimeout = defaults.ReadTimeout
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = defaults.DialTimeout
+	}
+	return c
+}
+
+// dialer binlog 同步器建立连接使用的拨号函数
+func (c SourceConnection) dialer() client.Dialer {
+	dialer := &net.Dialer{Timeout: c.DialTimeout, KeepAlive: c.KeepAlive}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialSource(ctx, dialer, network, address)
+	}
+}
@@ -0,0 +1,38 @@
+package canal
+
+import (
+	"testing"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// TestNewSourceConnection 测试源库连接配置的默认值和校验
+func TestNewSourceConnection(t *testing.T) {
+	conn, err := NewSourceConnection(config.SourceConnectionConfig{})
+	if err != nil || conn != DefaultSourceConnection() {
+		t.Fatalf("expected defaults, got %+v, %v", conn, err)
+	}
+
+	conn, err = NewSourceConnection(config.SourceConnectionConfig{HeartbeatPeriod: "5s", ReadTimeout: "20s", KeepAlive: "0", DialTimeout: "3s"})
+	want := SourceConnection{HeartbeatPeriod: 5 * time.Second, ReadTimeout: 20 * time.Second, DialTimeout: 3 * time.Second}
+	if err != nil || conn != want {
+		t.Errorf("expected %+v, got %+v, %v", want, conn, err)
+	}
+
+	for _, cfg := range []config.SourceConnectionConfig{
+		{HeartbeatPeriod: "90s"},                    // 不小于默认的读超时
+		{HeartbeatPeriod: "10s", ReadTimeout: "5s"}, // 心跳间隔大于读超时
+		{ReadTimeout: "soon"},
+		{DialTimeout: "-1s"},
+		{HeartbeatPeriod: "0"},
+	} {
+		if _, err := NewSourceConnection(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+
+	if got := (SourceConnection{ReadTimeout: time.Minute}).withDefaults(); got.HeartbeatPeriod != defaultHeartbeatPeriod || got.ReadTimeout != time.Minute {
+		t.Errorf("unexpected defaults %+v", got)
+	}
+}
@@ -0,0 +1,81 @@
+package canal
+
+import (
+	"fmt"
+	"net/http"
+
+	"pikachun/internal/database"
+)
+
+const (
+	// DefaultSourceName 载荷 source 字段的默认值
+	DefaultSourceName = "canal-pikachun"
+	// DefaultUserAgent 投递请求默认的 User-Agent
+	DefaultUserAgent = "Canal-Pikachun/1.0"
+)
+
+// 标识字段的长度上限，与数据库列宽一致
+const (
+	maxSourceNameLen  = 100
+	maxEnvironmentLen = 50
+	maxUserAgentLen   = 200
+)
+
+// SourceIdentity 任务的来源标识，用于同一个接收端区分来自不同应用和环境的投递：
+// Name 写入载荷的 source 字段和 X-Source-Name 请求头，Environment 写入载荷的 environment 字段和
+// X-Source-Environment 请求头，UserAgent 替换默认的 User-Agent。为空的字段使用默认值或不发送
+type SourceIdentity struct {
+	Name        string
+	Environment string
+	UserAgent   string
+}
+
+// ParseSourceIdentity 校验来源标识，各字段只能包含可打印字符，不超过长度上限
+func ParseSourceIdentity(name, environment, userAgent string) (SourceIdentity, error) {
+	for _, field := range []struct {
+		key, value string
+		max        int
+	}{
+		{"source_name", name, maxSourceNameLen},
+		{"environment", environment, maxEnvironmentLen},
+		{"user_agent", userAgent, maxUserAgentLen},
+	} {
+		if len(field.value) > field.max {
+			return SourceIdentity{}, fmt.Errorf("%s must be at most %d bytes", field.key, field.max)
+		}
+		for _, r := range field.value {
+			if r < 0x20 || r == 0x7f {
+				return SourceIdentity{}, fmt.Errorf("%s must not contain control characters", field.key)
+			}
+		}
+	}
+	return SourceIdentity{Name: name, Environment: environment, UserAgent: userAgent}, nil
+}
+
+// TaskSourceIdentity 任务的来源标识
+func TaskSourceIdentity(task *database.Task) (SourceIdentity, error) {
+	return ParseSourceIdentity(task.SourceName, task.Environment, task.UserAgent)
+}
+
+// source 载荷的 source 字段
+func (i SourceIdentity) source() string {
+	if i.Name != "" {
+		return i.Name
+	}
+	return DefaultSourceName
+}
+
+// setHeaders 设置请求的 User-Agent 和来源标识请求头
+func (i SourceIdentity) setHeaders(header http.Header) {
+	userAgent := i.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	header.Set("User-Agent", userAgent)
+	if i.Name != "" {
+		header.Set("X-Source-Name", i.Name)
+	}
+	if i.Environment != "" {
+		header.Set("X-Source-Environment", i.Environment)
+	}
+}
@@ -0,0 +1,64 @@
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestParseSourceIdentity 测试来源标识校验：拒绝控制字符和超长的值
+func TestParseSourceIdentity(t *testing.T) {
+	if _, err := ParseSourceIdentity("orders-service", "production", "orders/2.1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, invalid := range [][3]string{
+		{"orders\r\nX-Admin: 1", "", ""},
+		{"", strings.Repeat("e", maxEnvironmentLen+1), ""},
+		{"", "", "agent\x7f"},
+	} {
+		if _, err := ParseSourceIdentity(invalid[0], invalid[1], invalid[2]); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
+
+// TestWebhookHandlerSourceIdentity 测试来源标识写入请求头和载荷，未设置时使用默认值
+func TestWebhookHandlerSourceIdentity(t *testing.T) {
+	var header http.Header
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		payload = nil
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler("webhook-1", server.URL, log.New(io.Discard, "", 0))
+	batch := deliveryBatch{events: []*Event{{ID: "e1"}}}
+
+	if _, _, _, err := handler.sendEvents(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("User-Agent") != DefaultUserAgent || header.Get("X-Source-Name") != "" || payload["source"] != DefaultSourceName {
+		t.Errorf("expected default identity, got headers %v, payload source %v", header, payload["source"])
+	}
+	if _, ok := payload["environment"]; ok {
+		t.Error("expected no environment in payload by default")
+	}
+
+	handler.SetSourceIdentity(SourceIdentity{Name: "orders-service", Environment: "staging", UserAgent: "orders-cdc/1.0"})
+	if _, _, _, err := handler.sendEvents(context.Background(), batch); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("User-Agent") != "orders-cdc/1.0" || header.Get("X-Source-Name") != "orders-service" || header.Get("X-Source-Environment") != "staging" {
+		t.Errorf("unexpected headers %v", header)
+	}
+	if payload["source"] != "orders-service" || payload["environment"] != "staging" {
+		t.Errorf("unexpected payload %v", payload)
+	}
+}
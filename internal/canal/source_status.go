@@ -0,0 +1,173 @@
+package canal
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// SourceStatus 源库当前的 binlog 状态，来自 SHOW MASTER STATUS 和 SHOW BINARY LOGS
+type SourceStatus struct {
+	File            string      `json:"file"`
+	Pos             uint32      `json:"pos"`
+	ExecutedGTIDSet string      `json:"executed_gtid_set,omitempty"`
+	BinaryLogs      []BinaryLog `json:"binary_logs"` // 源库上仍可读取的 binlog 文件，从旧到新
+	QueriedAt       time.Time   `json:"queried_at" format:"date-time"`
+}
+
+// BinaryLog 源库上的一个 binlog 文件
+type BinaryLog struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// PositionLag 读取位置落后源库最新位置的程度
+type PositionLag struct {
+	Bytes int64 `json:"bytes"` // 尚未读取的 binlog 字节数，不含各文件头部
+	Files int   `json:"files"` // 之间相隔的 binlog 文件数，同一文件时为 0
+}
+
+// QuerySourceStatus 查询源库的最新 binlog 位置、已执行的 GTID 集合和可读取的 binlog 文件
+func QuerySourceStatus(cfg config.CanalConfig) (*SourceStatus, error) {
+	dsn := SourceDSN(cfg.Username, cfg.Password, cfg.Host, cfg.Port, "charset=utf8mb4&timeout=5s")
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	row, err := queryMasterStatus(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query master status: %v", err)
+	}
+	pos, err := strconv.ParseUint(row["Position"], 10, 32)
+	if err != nil || row["File"] == "" {
+		return nil, fmt.Errorf("invalid master status: %v", row)
+	}
+
+	logs, err := queryBinaryLogs(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query binary logs: %v", err)
+	}
+
+	return &SourceStatus{
+		File:            row["File"],
+		Pos:             uint32(pos),
+		ExecutedGTIDSet: row["Executed_Gtid_Set"],
+		BinaryLogs:      logs,
+		QueriedAt:       time.Now(),
+	}, nil
+}
+
+// queryMasterStatus 查询源库当前写入的 binlog 位置
+func queryMasterStatus(db *sql.DB) (map[string]string, error) {
+	row, err := queryFirstRow(db, "SHOW MASTER STATUS")
+	if err != nil {
+		// MySQL 8.4 起改为 SHOW BINARY LOG STATUS
+		return queryFirstRow(db, "SHOW BINARY LOG STATUS")
+	}
+	return row, nil
+}
+
+// queryBinaryLogs 查询源库上的 binlog 文件及其大小
+func queryBinaryLogs(db *sql.DB) ([]BinaryLog, error) {
+	rows, err := db.Query("SHOW BINARY LOGS")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	// 列数随版本不同（8.0 起多出 Encrypted），只取前两列
+	var logs []BinaryLog
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		size, _ := strconv.ParseInt(values[1].String, 10, 64)
+		logs = append(logs, BinaryLog{Name: values[0].String, Size: size})
+	}
+	return logs, rows.Err()
+}
+
+// Latest 源库的最新位置
+func (s *SourceStatus) Latest() Position {
+	return Position{Name: s.File, Pos: s.Pos, GTIDSet: s.ExecutedGTIDSet}
+}
+
+// Earliest 源库上最早可读取的位置，没有 binlog 文件时 Name 为空
+func (s *SourceStatus) Earliest() Position {
+	if len(s.BinaryLogs) == 0 {
+		return Position{}
+	}
+	return Position{Name: s.BinaryLogs[0].Name, Pos: 4}
+}
+
+// Lag 计算 pos 落后源库最新位置的程度。pos 所在文件已被清除、不在源库上或超过最新位置时返回错误
+func (s *SourceStatus) Lag(pos Position) (PositionLag, error) {
+	if err := s.Validate(pos); err != nil {
+		return PositionLag{}, err
+	}
+
+	from, to := s.logIndex(pos.Name), s.logIndex(s.File)
+	if to < 0 {
+		return PositionLag{}, fmt.Errorf("current binlog file %s missing from SHOW BINARY LOGS", s.File)
+	}
+	if from == to {
+		return PositionLag{Bytes: int64(s.Pos) - int64(pos.Pos)}, nil
+	}
+
+	// 每个文件的事件从第 4 字节（文件头之后）开始
+	bytes := s.BinaryLogs[from].Size - int64(pos.Pos)
+	for i := from + 1; i < to; i++ {
+		bytes += s.BinaryLogs[i].Size - 4
+	}
+	bytes += int64(s.Pos) - 4
+	if bytes < 0 {
+		bytes = 0
+	}
+	return PositionLag{Bytes: bytes, Files: to - from}, nil
+}
+
+// Validate 检查 pos 能否作为读取位置：文件仍在源库上，偏移不超过文件大小，且不超过源库最新位置
+func (s *SourceStatus) Validate(pos Position) error {
+	if pos.Name == "" {
+		return fmt.Errorf("binlog file name is empty")
+	}
+	if pos.Pos < 4 {
+		return fmt.Errorf("binlog offset %d is before the 4-byte file header", pos.Pos)
+	}
+	i := s.logIndex(pos.Name)
+	if i < 0 {
+		return fmt.Errorf("binlog file %s not found on source (purged or from another server)", pos.Name)
+	}
+	if ComparePositions(pos, Position{Name: s.File, Pos: s.Pos}) > 0 {
+		return fmt.Errorf("position %s:%d is beyond the source's latest position %s:%d", pos.Name, pos.Pos, s.File, s.Pos)
+	}
+	if pos.Name != s.File && int64(pos.Pos) > s.BinaryLogs[i].Size {
+		return fmt.Errorf("binlog offset %d is beyond the end of %s (%d bytes)", pos.Pos, pos.Name, s.BinaryLogs[i].Size)
+	}
+	return nil
+}
+
+// logIndex name 在源库 binlog 文件列表中的下标，不存在时返回 -1
+func (s *SourceStatus) logIndex(name string) int {
+	for i, binlog := range s.BinaryLogs {
+		if binlog.Name == name {
+			return i
+		}
+	}
+	return -1
+}
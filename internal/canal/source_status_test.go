@@ -0,0 +1,78 @@
+package canal
+
+import (
+	"strings"
+	"testing"
+)
+
+// testSourceStatus 三个 binlog 文件，当前写入第三个
+func testSourceStatus() *SourceStatus {
+	return &SourceStatus{
+		File: "mysql-bin.000003",
+		Pos:  1004,
+		BinaryLogs: []BinaryLog{
+			{Name: "mysql-bin.000001", Size: 10004},
+			{Name: "mysql-bin.000002", Size: 2004},
+			{Name: "mysql-bin.000003", Size: 1004},
+		},
+	}
+}
+
+// TestSourceStatusLag 测试按 binlog 文件大小计算落后的字节数
+func TestSourceStatusLag(t *testing.T) {
+	status := testSourceStatus()
+	cases := []struct {
+		pos  Position
+		want PositionLag
+	}{
+		{Position{Name: "mysql-bin.000003", Pos: 1004}, PositionLag{}},
+		{Position{Name: "mysql-bin.000003", Pos: 504}, PositionLag{Bytes: 500}},
+		// 第二个文件剩余 1000 字节，第三个文件头之后 1000 字节
+		{Position{Name: "mysql-bin.000002", Pos: 1004}, PositionLag{Bytes: 2000, Files: 1}},
+		// 第一个文件剩余 9000 字节，加上第二、三个文件的 2000 和 1000 字节
+		{Position{Name: "mysql-bin.000001", Pos: 1004}, PositionLag{Bytes: 12000, Files: 2}},
+	}
+	for _, c := range cases {
+		got, err := status.Lag(c.pos)
+		if err != nil {
+			t.Errorf("lag of %s:%d: unexpected error %v", c.pos.Name, c.pos.Pos, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("lag of %s:%d = %+v, want %+v", c.pos.Name, c.pos.Pos, got, c.want)
+		}
+	}
+}
+
+// TestSourceStatusValidate 测试拒绝源库上无法读取的位置
+func TestSourceStatusValidate(t *testing.T) {
+	status := testSourceStatus()
+	cases := []struct {
+		pos     Position
+		wantErr string
+	}{
+		{Position{Name: "mysql-bin.000001", Pos: 4}, ""},
+		{Position{Name: "mysql-bin.000002", Pos: 2004}, ""},
+		{Position{Name: "", Pos: 4}, "empty"},
+		{Position{Name: "mysql-bin.000002", Pos: 0}, "file header"},
+		{Position{Name: "mysql-bin.000000", Pos: 4}, "not found"},
+		{Position{Name: "mysql-bin.000002", Pos: 3000}, "beyond the end"},
+		{Position{Name: "mysql-bin.000003", Pos: 2000}, "beyond the source"},
+	}
+	for _, c := range cases {
+		err := status.Validate(c.pos)
+		switch {
+		case c.wantErr == "" && err != nil:
+			t.Errorf("%s:%d: unexpected error %v", c.pos.Name, c.pos.Pos, err)
+		case c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)):
+			t.Errorf("%s:%d: expected error containing %q, got %v", c.pos.Name, c.pos.Pos, c.wantErr, err)
+		}
+	}
+
+	if _, err := status.Lag(Position{Name: "mysql-bin.000000", Pos: 4}); err == nil {
+		t.Error("expected lag of a purged position to fail")
+	}
+	if got := status.Earliest(); got != (Position{Name: "mysql-bin.000001", Pos: 4}) {
+		t.Errorf("unexpected earliest position %+v", got)
+	}
+}
@@ -0,0 +1,115 @@
+package canal
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"strings"
+
+	"pikachun/internal/config"
+)
+
+// systemSchemas 展开表规则时跳过的系统库
+var systemSchemas = map[string]bool{
+	"mysql":              true,
+	"information_schema": true,
+	"performance_schema": true,
+	"sys":                true,
+}
+
+// SourceTable 源库中的一张表
+type SourceTable struct {
+	Schema string `json:"database"`
+	Table  string `json:"table"`
+	Rows   int64  `json:"rows"` // information_schema 中的估算行数
+}
+
+// SourceTableInfo 源库中的表及其 binlog 活跃度，用于选择要监听的表
+type SourceTableInfo struct {
+	SourceTable
+	Activity   *TableActivity `json:"activity,omitempty"` // 运行中的实例观察到的活跃度，没有观察到时为空
+	Subscribed bool           `json:"subscribed"`         // 已有任务监听该表
+}
+
+// AllTablesPattern 匹配所有表的规则
+const AllTablesPattern = "*.*"
+
+// ValidateTablePattern 检查表规则，规则为 "库.表"，库名和表名都支持通配符
+func ValidateTablePattern(pattern string) error {
+	_, _, err := splitTablePattern(pattern)
+	return err
+}
+
+// splitTablePattern 拆分表规则为库名和表名的通配符
+func splitTablePattern(pattern string) (string, string, error) {
+	schemaPattern, tablePattern, ok := strings.Cut(strings.TrimSpace(pattern), ".")
+	if !ok || schemaPattern == "" || tablePattern == "" {
+		return "", "", fmt.Errorf("invalid table pattern %q, expected schema.table", pattern)
+	}
+	if _, err := path.Match(schemaPattern, ""); err != nil {
+		return "", "", fmt.Errorf("invalid table pattern %q: %v", pattern, err)
+	}
+	if _, err := path.Match(tablePattern, ""); err != nil {
+		return "", "", fmt.Errorf("invalid table pattern %q: %v", pattern, err)
+	}
+	return schemaPattern, tablePattern, nil
+}
+
+// MatchTables 过滤出匹配规则的表，规则为 "库.表"，库名和表名都支持通配符（如 shop.order_*、*.users）
+func MatchTables(tables []SourceTable, pattern string) ([]SourceTable, error) {
+	schemaPattern, tablePattern, err := splitTablePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []SourceTable{}
+	for _, t := range tables {
+		schemaOK, _ := path.Match(schemaPattern, t.Schema)
+		tableOK, _ := path.Match(tablePattern, t.Table)
+		if schemaOK && tableOK {
+			matched = append(matched, t)
+		}
+	}
+	return matched, nil
+}
+
+// ListSourceTables 查询源库中匹配规则的表，不含系统库和视图。规则无效时不查询源库
+func ListSourceTables(cfg config.CanalConfig, pattern string) ([]SourceTable, error) {
+	if err := ValidateTablePattern(pattern); err != nil {
+		return nil, err
+	}
+	tables, err := QuerySourceTables(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return MatchTables(tables, pattern)
+}
+
+// QuerySourceTables 查询源库中所有的表，不含系统库和视图
+func QuerySourceTables(cfg config.CanalConfig) ([]SourceTable, error) {
+	dsn := SourceDSN(cfg.Username, cfg.Password, cfg.Host, cfg.Port, "charset=utf8mb4&timeout=5s")
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT TABLE_SCHEMA, TABLE_NAME, COALESCE(TABLE_ROWS, 0) FROM information_schema.TABLES
+		WHERE TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_SCHEMA, TABLE_NAME`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query information_schema: %v", err)
+	}
+	defer rows.Close()
+
+	var tables []SourceTable
+	for rows.Next() {
+		var t SourceTable
+		if err := rows.Scan(&t.Schema, &t.Table, &t.Rows); err != nil {
+			return nil, fmt.Errorf("failed to scan table: %v", err)
+		}
+		if !systemSchemas[strings.ToLower(t.Schema)] {
+			tables = append(tables, t)
+		}
+	}
+	return tables, rows.Err()
+}
@@ -0,0 +1,33 @@
+package canal
+
+import "testing"
+
+// TestMatchTables 测试库名和表名的通配符匹配，无效的规则返回错误
+func TestMatchTables(t *testing.T) {
+	tables := []SourceTable{{Schema: "shop", Table: "orders"}, {Schema: "shop", Table: "order_items"}, {Schema: "crm", Table: "users"}}
+
+	cases := []struct {
+		pattern string
+		want    int
+	}{
+		{AllTablesPattern, 3},
+		{"shop.order*", 2},
+		{"*.users", 1},
+		{"billing.*", 0},
+	}
+	for _, c := range cases {
+		matched, err := MatchTables(tables, c.pattern)
+		if err != nil || len(matched) != c.want {
+			t.Errorf("MatchTables(%q) = %v, %v, want %d tables", c.pattern, matched, err, c.want)
+		}
+		if matched == nil {
+			t.Errorf("MatchTables(%q) should return an empty list, not nil", c.pattern)
+		}
+	}
+
+	for _, pattern := range []string{"", "shop", ".orders", "shop.", "shop.[orders"} {
+		if err := ValidateTablePattern(pattern); err == nil {
+			t.Errorf("expected error for pattern %q", pattern)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package canal
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/proxy"
+
+	"pikachun/internal/config"
+)
+
+// 到源库的隧道类型
+const (
+	TunnelSOCKS5 = "socks5" // SOCKS5 代理
+	TunnelHTTP   = "http"   // HTTP CONNECT 代理
+	TunnelSSH    = "ssh"    // SSH 跳板机端口转发
+)
+
+// sourceTunnelNetwork 经隧道连接源库时 DSN 使用的网络名，见 SourceDSN
+const sourceTunnelNetwork = "pikachun-tunnel"
+
+var (
+	// activeTunnel 当前使用的隧道，nil 表示直连
+	activeTunnel         atomic.Pointer[SourceTunnel]
+	registerTunnelDialer sync.Once
+)
+
+// SourceTunnel 经代理或 SSH 跳板机连接源库，复制连接和元数据查询都经过隧道
+type SourceTunnel struct {
+	kind     string
+	address  string
+	username string
+	password string
+
+	sshConfig *ssh.ClientConfig
+
+	mu        sync.Mutex
+	sshClient *ssh.Client // 到跳板机的连接，所有转发共用，断开后重新建立
+}
+
+// NewSourceTunnel 根据配置创建隧道，type 为空时返回 nil 表示直连
+func NewSourceTunnel(cfg config.SourceTunnelConfig) (*SourceTunnel, error) {
+	kind := strings.ToLower(strings.TrimSpace(cfg.Type))
+	if kind == "" {
+		return nil, nil
+	}
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("tunnel address is required")
+	}
+	if _, _, err := net.SplitHostPort(cfg.Address); err != nil {
+		return nil, fmt.Errorf("invalid tunnel address %q: %v", cfg.Address, err)
+	}
+
+	tunnel := &SourceTunnel{
+		kind:     kind,
+		address:  cfg.Address,
+		username: cfg.Username,
+		password: cfg.Password,
+	}
+	switch kind {
+	case TunnelSOCKS5, TunnelHTTP:
+	case TunnelSSH:
+		sshConfig, err := newSSHClientConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		tunnel.sshConfig = sshConfig
+	default:
+		return nil, fmt.Errorf("unsupported tunnel type %q, expected socks5, http or ssh", cfg.Type)
+	}
+	return tunnel, nil
+}
+
+// newSSHClientConfig SSH 认证和主机公钥校验配置
+func newSSHClientConfig(cfg config.SourceTunnelConfig) (*ssh.ClientConfig, error) {
+	if cfg.Username == "" {
+		return nil, fmt.Errorf("ssh tunnel requires a username")
+	}
+
+	var auth []ssh.AuthMethod
+	if cfg.PrivateKeyFile != "" {
+		key, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ssh private key: %v", err)
+		}
+		var signer ssh.Signer
+		if cfg.PrivateKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(cfg.PrivateKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ssh private key: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("ssh tunnel requires private_key_file or password")
+	}
+
+	var hostKey ssh.HostKeyCallback
+	switch {
+	case cfg.KnownHostsFile != "":
+		callback, err := knownhosts.New(cfg.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load known_hosts_file: %v", err)
+		}
+		hostKey = callback
+	case cfg.InsecureIgnoreHostKey:
+		hostKey = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, fmt.Errorf("ssh tunnel requires known_hosts_file to verify the bastion host key")
+	}
+
+	return &ssh.ClientConfig{
+		User:            cfg.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+	}, nil
+}
+
+// String 隧道描述，不含认证信息
+func (t *SourceTunnel) String() string {
+	return t.kind + "://" + t.address
+}
+
+// DialContext 经隧道连接 addr，base 用于连接代理或跳板机
+func (t *SourceTunnel) DialContext(ctx context.Context, base *net.Dialer, network, addr string) (net.Conn, error) {
+	switch t.kind {
+	case TunnelSOCKS5:
+		var auth *proxy.Auth
+		if t.username != "" {
+			auth = &proxy.Auth{User: t.username, Password: t.password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", t.address, auth, base)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+	case TunnelHTTP:
+		return t.dialHTTP(ctx, base, addr)
+	default:
+		return t.dialSSH(ctx, base, network, addr)
+	}
+}
+
+// dialHTTP 通过 HTTP CONNECT 建立到 addr 的隧道
+func (t *SourceTunnel) dialHTTP(ctx context.Context, base *net.Dialer, addr string) (net.Conn, error) {
+	conn, err := base.DialContext(ctx, "tcp", t.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy %s: %v", t.address, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if t.username != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(t.username + ":" + t.password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT to proxy %s: %v", t.address, err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response from proxy %s: %v", t.address, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused CONNECT to %s: %s", t.address, addr, resp.Status)
+	}
+	// MySQL 服务端先发握手包，可能已被读入缓冲
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// bufferedConn 先读出缓冲中的数据再读连接
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// dialSSH 通过跳板机转发到 addr，跳板机连接断开时重新建立一次
+func (t *SourceTunnel) dialSSH(ctx context.Context, base *net.Dialer, network, addr string) (net.Conn, error) {
+	client, err := t.sshClientFor(ctx, base, false)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, network, addr)
+	if err == nil {
+		return conn, nil
+	}
+	if client, err = t.sshClientFor(ctx, base, true); err != nil {
+		return nil, err
+	}
+	return client.DialContext(ctx, network, addr)
+}
+
+// sshClientFor 取到跳板机的连接，reconnect 时关闭旧连接重新建立
+func (t *SourceTunnel) sshClientFor(ctx context.Context, base *net.Dialer, reconnect bool) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.sshClient != nil && !reconnect {
+		return t.sshClient, nil
+	}
+	if t.sshClient != nil {
+		t.sshClient.Close()
+		t.sshClient = nil
+	}
+
+	conn, err := base.DialContext(ctx, "tcp", t.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh bastion %s: %v", t.address, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, t.address, t.sshConfig)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh handshake with bastion %s failed: %v", t.address, err)
+	}
+	t.sshClient = ssh.NewClient(sshConn, chans, reqs)
+	return t.sshClient, nil
+}
+
+// Close 关闭到跳板机的连接
+func (t *SourceTunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sshClient == nil {
+		return nil
+	}
+	err := t.sshClient.Close()
+	t.sshClient = nil
+	return err
+}
+
+// ConfigureSourceTunnel 设置到源库的隧道，之后建立的复制连接和元数据查询连接都经过隧道。
+// type 为空时恢复直连
+func ConfigureSourceTunnel(cfg config.SourceTunnelConfig) error {
+	tunnel, err := NewSourceTunnel(cfg)
+	if err != nil {
+		return err
+	}
+	registerTunnelDialer.Do(func() {
+		mysql.RegisterDialContext(sourceTunnelNetwork, func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialSource(ctx, &net.Dialer{KeepAlive: defaultKeepAlive}, "tcp", addr)
+		})
+	})
+	if old := activeTunnel.Swap(tunnel); old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// dialSource 连接源库，配置了隧道时经过隧道
+func dialSource(ctx context.Context, base *net.Dialer, network, addr string) (net.Conn, error) {
+	if tunnel := activeTunnel.Load(); tunnel != nil {
+		return tunnel.DialContext(ctx, base, network, addr)
+	}
+	return base.DialContext(ctx, network, addr)
+}
+
+// SourceDSN 源库连接的 DSN，配置了隧道时使用经过隧道的网络名，params 为 ? 之后的参数
+func SourceDSN(username, password, host string, port int, params string) string {
+	network := "tcp"
+	if activeTunnel.Load() != nil {
+		network = sourceTunnelNetwork
+	}
+	return fmt.Sprintf("%s:%s@%s(%s:%d)/?%s", username, password, network, host, port, params)
+}
@@ -0,0 +1,115 @@
+package canal
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"pikachun/internal/config"
+)
+
+// TestNewSourceTunnel 测试隧道配置的校验
+func TestNewSourceTunnel(t *testing.T) {
+	tunnel, err := NewSourceTunnel(config.SourceTunnelConfig{})
+	if tunnel != nil || err != nil {
+		t.Fatalf("expected direct connection, got %v, %v", tunnel, err)
+	}
+
+	tunnel, err = NewSourceTunnel(config.SourceTunnelConfig{Type: "SOCKS5", Address: "proxy:1080"})
+	if err != nil || tunnel.String() != "socks5://proxy:1080" {
+		t.Errorf("unexpected tunnel %v, %v", tunnel, err)
+	}
+
+	for _, cfg := range []config.SourceTunnelConfig{
+		{Type: "http"},                                                            // 缺少地址
+		{Type: "http", Address: "proxy"},                                          // 缺少端口
+		{Type: "vpn", Address: "proxy:1080"},                                      // 不支持的类型
+		{Type: "ssh", Address: "bastion:22"},                                      // 缺少用户名
+		{Type: "ssh", Address: "bastion:22", Username: "ops"},                     // 缺少认证方式
+		{Type: "ssh", Address: "bastion:22", Username: "ops", Password: "secret"}, // 缺少 known_hosts_file
+	} {
+		if _, err := NewSourceTunnel(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+
+	if _, err := NewSourceTunnel(config.SourceTunnelConfig{Type: "ssh", Address: "bastion:22", Username: "ops", Password: "secret", InsecureIgnoreHostKey: true}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestSourceTunnelHTTPConnect 测试经 HTTP CONNECT 代理连接，服务端先发送的数据不丢失
+func TestSourceTunnelHTTPConnect(t *testing.T) {
+	// 模拟 MySQL 服务端：连接后先发送握手包
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	go func() {
+		conn, err := backend.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("handshake"))
+		io.Copy(conn, conn)
+	}()
+
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxyListener.Close()
+	authorized := make(chan string, 1)
+	go func() {
+		client, err := proxyListener.Accept()
+		if err != nil {
+			return
+		}
+		defer client.Close()
+		req, err := http.ReadRequest(bufio.NewReader(client))
+		if err != nil || req.Method != http.MethodConnect {
+			return
+		}
+		authorized <- req.Header.Get("Proxy-Authorization")
+		target, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			client.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+			return
+		}
+		defer target.Close()
+		// 握手包与 CONNECT 响应一起到达客户端
+		greeting := make([]byte, len("handshake"))
+		io.ReadFull(target, greeting)
+		client.Write(append([]byte("HTTP/1.1 200 Connection established\r\n\r\n"), greeting...))
+		go io.Copy(target, client)
+		io.Copy(client, target)
+	}()
+
+	tunnel, err := NewSourceTunnel(config.SourceTunnelConfig{Type: TunnelHTTP, Address: proxyListener.Addr().String(), Username: "u", Password: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := tunnel.DialContext(context.Background(), &net.Dialer{}, "tcp", backend.Addr().String())
+	if err != nil {
+		t.Fatalf("dial through proxy failed: %v", err)
+	}
+	defer conn.Close()
+
+	if got := <-authorized; got != "Basic dTpw" {
+		t.Errorf("unexpected Proxy-Authorization %q", got)
+	}
+	buf := make([]byte, len("handshake"))
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "handshake" {
+		t.Fatalf("expected handshake, got %q, %v", buf, err)
+	}
+	conn.Write([]byte("ping"))
+	buf = make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("expected echo, got %q, %v", buf, err)
+	}
+}
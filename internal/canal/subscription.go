@@ -0,0 +1,153 @@
+package canal
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// SubscriptionID 订阅 ID，由 schema.table 和处理器名称组成。
+// 同一处理器可以订阅多张表，同一张表也可以有多个任务的处理器，组合后唯一
+func SubscriptionID(schema, table, handlerName string) string {
+	return schema + "." + table + "/" + handlerName
+}
+
+// subscriptionFilter 订阅的过滤条件，按处理器名称设置，对该处理器的所有订阅生效
+type subscriptionFilter struct {
+	eventTypes map[EventType]bool // 为空表示接收所有类型
+	row        *RowFilter         // nil 表示不按行过滤
+}
+
+// match 事件是否通过过滤
+func (f *subscriptionFilter) match(event *Event) bool {
+	if len(f.eventTypes) > 0 && !f.eventTypes[event.EventType] {
+		return false
+	}
+	return f.row == nil || f.row.Match(event)
+}
+
+// subscriptionStats 订阅的投递统计
+type subscriptionStats struct {
+	delivered int64 // 处理器处理成功
+	filtered  int64 // 被过滤条件丢弃
+	failed    int64 // 处理器返回错误
+	nanos     int64 // 处理器处理事件的总耗时
+}
+
+func (s *subscriptionStats) addFiltered() {
+	if s != nil {
+		atomic.AddInt64(&s.filtered, 1)
+	}
+}
+
+func (s *subscriptionStats) addHandled(err error, elapsed time.Duration) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.nanos, elapsed.Nanoseconds())
+	if err != nil {
+		atomic.AddInt64(&s.failed, 1)
+	} else {
+		atomic.AddInt64(&s.delivered, 1)
+	}
+}
+
+// SubscriptionInfo 订阅的过滤条件和统计
+type SubscriptionInfo struct {
+	ID         string      `json:"id"`
+	Schema     string      `json:"schema"`
+	Table      string      `json:"table"`
+	Handler    string      `json:"handler"`
+	EventTypes []EventType `json:"event_types,omitempty"`
+	RowFilter  string      `json:"row_filter,omitempty"`
+	Delivered  int64       `json:"delivered"`
+	Filtered   int64       `json:"filtered"`
+	Failed     int64       `json:"failed"`
+	LatencyMs  float64     `json:"avg_latency_ms"` // 处理器处理单个事件的平均耗时
+}
+
+// filterLocked 取处理器的过滤条件，不存在时创建，调用方需持有写锁
+func (s *DefaultEventSink) filterLocked(handlerName string) *subscriptionFilter {
+	if s.filters == nil {
+		s.filters = make(map[string]*subscriptionFilter)
+	}
+	filter := s.filters[handlerName]
+	if filter == nil {
+		filter = &subscriptionFilter{}
+		s.filters[handlerName] = filter
+	}
+	return filter
+}
+
+// dropEmptyFilterLocked 过滤条件都为空时删除，调用方需持有写锁
+func (s *DefaultEventSink) dropEmptyFilterLocked(handlerName string) {
+	if filter := s.filters[handlerName]; filter != nil && len(filter.eventTypes) == 0 && filter.row == nil {
+		delete(s.filters, handlerName)
+	}
+}
+
+// SetHandlerEventTypes 设置处理器接收的事件类型，为空时接收所有类型。
+// 同一张表上的处理器可以接收不同类型的事件
+func (s *DefaultEventSink) SetHandlerEventTypes(handlerName string, eventTypes []EventType) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	types := make(map[EventType]bool, len(eventTypes))
+	for _, eventType := range eventTypes {
+		types[eventType] = true
+	}
+	filter := s.filterLocked(handlerName)
+	// 替换而不是修改，处理中的事件持有的是旧的过滤条件
+	s.filters[handlerName] = &subscriptionFilter{eventTypes: types, row: filter.row}
+	s.dropEmptyFilterLocked(handlerName)
+}
+
+// SetHandlerRowFilter 设置处理器的行过滤表达式，nil 表示不按行过滤
+func (s *DefaultEventSink) SetHandlerRowFilter(handlerName string, rowFilter *RowFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filter := s.filterLocked(handlerName)
+	s.filters[handlerName] = &subscriptionFilter{eventTypes: filter.eventTypes, row: rowFilter}
+	s.dropEmptyFilterLocked(handlerName)
+}
+
+// Subscriptions 返回所有订阅的过滤条件和统计，按订阅 ID 排序
+func (s *DefaultEventSink) Subscriptions() []SubscriptionInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subscriptions := make([]SubscriptionInfo, 0, len(s.subStats))
+	for key, handlers := range s.handlers {
+		schema, table, _ := strings.Cut(key, ".")
+		for name := range handlers {
+			info := SubscriptionInfo{
+				ID:      SubscriptionID(schema, table, name),
+				Schema:  schema,
+				Table:   table,
+				Handler: name,
+			}
+			if filter := s.filters[name]; filter != nil {
+				for eventType := range filter.eventTypes {
+					info.EventTypes = append(info.EventTypes, eventType)
+				}
+				sort.Slice(info.EventTypes, func(i, j int) bool { return info.EventTypes[i] < info.EventTypes[j] })
+				if filter.row != nil {
+					info.RowFilter = filter.row.String()
+				}
+			}
+			if stats := s.subStats[info.ID]; stats != nil {
+				info.Delivered = atomic.LoadInt64(&stats.delivered)
+				info.Filtered = atomic.LoadInt64(&stats.filtered)
+				info.Failed = atomic.LoadInt64(&stats.failed)
+				if handled := info.Delivered + info.Failed; handled > 0 {
+					info.LatencyMs = float64(atomic.LoadInt64(&stats.nanos)) / float64(handled) / float64(time.Millisecond)
+				}
+			}
+			subscriptions = append(subscriptions, info)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].ID < subscriptions[j].ID })
+	return subscriptions
+}
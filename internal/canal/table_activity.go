@@ -0,0 +1,111 @@
+package canal
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// activityBuckets 活跃度统计的分钟桶数，近期速率按最近这么多分钟计算
+const activityBuckets = 10
+
+// TableActivity 一张表在 binlog 中的活跃度，按 TableMapEvent 计数估算（每条行事件前都有一个）
+type TableActivity struct {
+	Schema          string    `json:"database"`
+	Table           string    `json:"table"`
+	Total           int64     `json:"total"`             // 实例启动以来的行事件数
+	RecentPerMinute float64   `json:"recent_per_minute"` // 最近 10 分钟平均每分钟的行事件数
+	LastSeen        time.Time `json:"last_seen" format:"date-time"`
+}
+
+// tableActivityCounter 单张表的计数，按分钟分桶
+type tableActivityCounter struct {
+	total    int64
+	lastSeen time.Time
+	buckets  [activityBuckets]int64
+	minutes  [activityBuckets]int64 // 每个桶对应的分钟（Unix 分钟数）
+}
+
+// TableActivityTracker 统计各表在 binlog 中出现的频率，包括未订阅的表
+type TableActivityTracker struct {
+	mu     sync.Mutex
+	tables map[[2]string]*tableActivityCounter
+	since  time.Time
+}
+
+// NewTableActivityTracker 创建表活跃度统计
+func NewTableActivityTracker() *TableActivityTracker {
+	return &TableActivityTracker{tables: make(map[[2]string]*tableActivityCounter), since: time.Now()}
+}
+
+// Record 记录一次表映射事件
+func (t *TableActivityTracker) Record(schema, table string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := [2]string{schema, table}
+	counter, ok := t.tables[key]
+	if !ok {
+		counter = &tableActivityCounter{}
+		t.tables[key] = counter
+	}
+	counter.total++
+	if at.After(counter.lastSeen) {
+		counter.lastSeen = at
+	}
+
+	minute := at.Unix() / 60
+	i := minute % activityBuckets
+	switch {
+	case counter.minutes[i] == minute:
+		counter.buckets[i]++
+	case counter.minutes[i] < minute:
+		counter.minutes[i] = minute
+		counter.buckets[i] = 1
+	}
+	// 比桶中更早的事件只计入总数，不覆盖近期计数
+}
+
+// Snapshot 各表的活跃度，按近期速率和总数从高到低排列
+func (t *TableActivityTracker) Snapshot(now time.Time) []TableActivity {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// 启动不足 10 分钟时按实际时长计算速率
+	window := float64(activityBuckets)
+	if elapsed := now.Sub(t.since).Minutes(); elapsed < window {
+		window = elapsed
+		if window < 1 {
+			window = 1
+		}
+	}
+
+	minute := now.Unix() / 60
+	result := make([]TableActivity, 0, len(t.tables))
+	for key, counter := range t.tables {
+		var recent int64
+		for i, m := range counter.minutes {
+			if minute-m < activityBuckets {
+				recent += counter.buckets[i]
+			}
+		}
+		result = append(result, TableActivity{
+			Schema:          key[0],
+			Table:           key[1],
+			Total:           counter.total,
+			RecentPerMinute: float64(recent) / window,
+			LastSeen:        counter.lastSeen,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].RecentPerMinute != result[j].RecentPerMinute {
+			return result[i].RecentPerMinute > result[j].RecentPerMinute
+		}
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		return result[i].Schema+"."+result[i].Table < result[j].Schema+"."+result[j].Table
+	})
+	return result
+}
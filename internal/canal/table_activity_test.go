@@ -0,0 +1,56 @@
+package canal
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTableActivityTracker 测试近期速率只统计最近 10 分钟，并按活跃度排序
+func TestTableActivityTracker(t *testing.T) {
+	tracker := NewTableActivityTracker()
+	now := time.Now()
+	tracker.since = now.Add(-time.Hour)
+
+	// orders 近期活跃，logs 只在 30 分钟前写入过大量数据
+	for i := 0; i < 20; i++ {
+		tracker.Record("shop", "orders", now.Add(-time.Duration(i)*time.Minute/4))
+	}
+	for i := 0; i < 100; i++ {
+		tracker.Record("shop", "logs", now.Add(-30*time.Minute))
+	}
+	tracker.Record("shop", "users", now.Add(-time.Minute))
+
+	got := tracker.Snapshot(now)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 tables, got %d", len(got))
+	}
+
+	if got[0].Table != "orders" || got[0].Total != 20 || got[0].RecentPerMinute != 2 {
+		t.Errorf("unexpected first table: %+v", got[0])
+	}
+	if got[1].Table != "users" || got[1].RecentPerMinute != 0.1 {
+		t.Errorf("unexpected second table: %+v", got[1])
+	}
+	if got[2].Table != "logs" || got[2].Total != 100 || got[2].RecentPerMinute != 0 {
+		t.Errorf("unexpected third table: %+v", got[2])
+	}
+	if !got[2].LastSeen.Equal(now.Add(-30 * time.Minute)) {
+		t.Errorf("unexpected last seen: %v", got[2].LastSeen)
+	}
+}
+
+// TestTableActivityTrackerShortWindow 启动不足 10 分钟时按实际时长计算速率
+func TestTableActivityTrackerShortWindow(t *testing.T) {
+	tracker := NewTableActivityTracker()
+	now := time.Now()
+	tracker.since = now.Add(-2 * time.Minute)
+
+	for i := 0; i < 10; i++ {
+		tracker.Record("shop", "orders", now)
+	}
+
+	got := tracker.Snapshot(now)
+	if len(got) != 1 || got[0].RecentPerMinute != 5 {
+		t.Fatalf("unexpected activity: %+v", got)
+	}
+}
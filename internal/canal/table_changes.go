@@ -0,0 +1,90 @@
+package canal
+
+import (
+	"sync"
+	"time"
+)
+
+// TableChangeBucketWidth 表变更统计桶的宽度
+const TableChangeBucketWidth = 5 * time.Minute
+
+// TableChangeKey 一个表变更统计桶：任务、表和桶的起始时间
+type TableChangeKey struct {
+	TaskID uint
+	Schema string
+	Table  string
+	Start  time.Time
+}
+
+// TableChangeCounts 统计桶内各类型的行变更数
+type TableChangeCounts struct {
+	Inserts int64
+	Updates int64
+	Deletes int64
+}
+
+// TableChangeCounter 按任务、表和时间窗口统计进入事件接收器的行变更数，按 binlog 事件时间分桶。
+// 各实例共用一个，定期取出增量持久化，用于变更频率分析
+type TableChangeCounter struct {
+	mu      sync.Mutex
+	pending map[TableChangeKey]*TableChangeCounts
+}
+
+// NewTableChangeCounter 创建表变更统计
+func NewTableChangeCounter() *TableChangeCounter {
+	return &TableChangeCounter{pending: make(map[TableChangeKey]*TableChangeCounts)}
+}
+
+// Record 记录一个事件，c 为 nil 时不记录
+func (c *TableChangeCounter) Record(taskID uint, event *Event) {
+	if c == nil {
+		return
+	}
+	at := event.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	key := TableChangeKey{TaskID: taskID, Schema: event.Schema, Table: event.Table, Start: at.Truncate(TableChangeBucketWidth)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.pending[key]
+	if counts == nil {
+		counts = &TableChangeCounts{}
+		c.pending[key] = counts
+	}
+	switch event.EventType {
+	case EventTypeInsert:
+		counts.Inserts++
+	case EventTypeUpdate:
+		counts.Updates++
+	case EventTypeDelete:
+		counts.Deletes++
+	}
+}
+
+// Flush 取出上次取出以来的增量
+func (c *TableChangeCounter) Flush() map[TableChangeKey]TableChangeCounts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make(map[TableChangeKey]TableChangeCounts, len(c.pending))
+	for key, counts := range c.pending {
+		result[key] = *counts
+	}
+	c.pending = make(map[TableChangeKey]*TableChangeCounts)
+	return result
+}
+
+// SetChangeCounter 设置表变更统计，之后进入事件接收器的事件按任务计数，nil 表示不统计
+func (c *MySQLCanalInstance) SetChangeCounter(counter *TableChangeCounter, taskID uint) {
+	c.eventSink.setChangeCounter(counter, taskID)
+}
+
+// setChangeCounter 设置表变更统计和事件所属的任务，作为转换阶段在入队前记录
+func (s *DefaultEventSink) setChangeCounter(counter *TableChangeCounter, taskID uint) {
+	if counter == nil {
+		s.pipeline.setTransform(StageTableChanges, nil)
+		return
+	}
+	s.pipeline.setTransform(StageTableChanges, tableChangeStage{counter: counter, taskID: taskID})
+}
@@ -0,0 +1,43 @@
+package canal
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTableChangeCounter 测试按任务、表和 5 分钟时间桶计数，取出后从零开始
+func TestTableChangeCounter(t *testing.T) {
+	counter := NewTableChangeCounter()
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, event := range []*Event{
+		{Schema: "shop", Table: "orders", EventType: EventTypeInsert, Timestamp: base.Add(time.Minute)},
+		{Schema: "shop", Table: "orders", EventType: EventTypeUpdate, Timestamp: base.Add(4 * time.Minute)},
+		{Schema: "shop", Table: "orders", EventType: EventTypeDelete, Timestamp: base.Add(6 * time.Minute)},
+		{Schema: "shop", Table: "users", EventType: EventTypeInsert, Timestamp: base},
+	} {
+		counter.Record(1, event)
+	}
+	counter.Record(2, &Event{Schema: "shop", Table: "orders", EventType: EventTypeInsert, Timestamp: base})
+
+	deltas := counter.Flush()
+	want := map[TableChangeKey]TableChangeCounts{
+		{TaskID: 1, Schema: "shop", Table: "orders", Start: base}:                      {Inserts: 1, Updates: 1},
+		{TaskID: 1, Schema: "shop", Table: "orders", Start: base.Add(5 * time.Minute)}: {Deletes: 1},
+		{TaskID: 1, Schema: "shop", Table: "users", Start: base}:                       {Inserts: 1},
+		{TaskID: 2, Schema: "shop", Table: "orders", Start: base}:                      {Inserts: 1},
+	}
+	if len(deltas) != len(want) {
+		t.Fatalf("expected %d buckets, got %v", len(want), deltas)
+	}
+	for key, counts := range want {
+		if deltas[key] != counts {
+			t.Errorf("bucket %+v: expected %+v, got %+v", key, counts, deltas[key])
+		}
+	}
+	if deltas := counter.Flush(); len(deltas) != 0 {
+		t.Errorf("expected no deltas after flush, got %v", deltas)
+	}
+
+	var nilCounter *TableChangeCounter
+	nilCounter.Record(1, &Event{Schema: "shop", Table: "orders", EventType: EventTypeInsert})
+}
@@ -0,0 +1,96 @@
+package canal
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// TableFilter 表排除过滤器
+// 规则支持通配符（例如 *_tmp、migrations、testdb.heartbeat）：
+// 包含 "." 的规则匹配 schema.table，否则只匹配表名
+type TableFilter struct {
+	mu       sync.RWMutex
+	patterns []string
+	cache    map[string]bool // schema.table -> 是否排除
+}
+
+// NewTableFilter 创建表排除过滤器
+func NewTableFilter(patterns []string) *TableFilter {
+	f := &TableFilter{}
+	f.SetPatterns(patterns)
+	return f
+}
+
+// SetPatterns 设置排除规则（会清空匹配缓存）
+func (f *TableFilter) SetPatterns(patterns []string) {
+	cleaned := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			cleaned = append(cleaned, p)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.patterns = cleaned
+	f.cache = make(map[string]bool)
+}
+
+// Patterns 获取当前排除规则
+func (f *TableFilter) Patterns() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	result := make([]string, len(f.patterns))
+	copy(result, f.patterns)
+	return result
+}
+
+// Excluded 判断表是否被排除
+func (f *TableFilter) Excluded(schema, table string) bool {
+	key := schema + "." + table
+
+	f.mu.RLock()
+	if len(f.patterns) == 0 {
+		f.mu.RUnlock()
+		return false
+	}
+	if excluded, ok := f.cache[key]; ok {
+		f.mu.RUnlock()
+		return excluded
+	}
+	patterns := f.patterns
+	f.mu.RUnlock()
+
+	excluded := false
+	for _, p := range patterns {
+		target := table
+		if strings.Contains(p, ".") {
+			target = key
+		}
+		if matched, err := path.Match(p, target); err == nil && matched {
+			excluded = true
+			break
+		}
+	}
+
+	// 热点表只需匹配一次
+	f.mu.Lock()
+	f.cache[key] = excluded
+	f.mu.Unlock()
+
+	return excluded
+}
+
+// SplitList 解析逗号分隔的列表配置（例如任务中的排除规则）
+func SplitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,47 @@
+package canal
+
+import (
+	"testing"
+)
+
+// TestTableFilterExcluded 测试表排除规则匹配
+func TestTableFilterExcluded(t *testing.T) {
+	filter := NewTableFilter([]string{"*_tmp", " migrations ", "testdb.heartbeat", ""})
+
+	cases := []struct {
+		schema   string
+		table    string
+		excluded bool
+	}{
+		{"testdb", "users_tmp", true},
+		{"otherdb", "orders_tmp", true},
+		{"testdb", "migrations", true},
+		{"testdb", "heartbeat", true},
+		{"otherdb", "heartbeat", false},
+		{"testdb", "users", false},
+	}
+
+	for _, c := range cases {
+		if got := filter.Excluded(c.schema, c.table); got != c.excluded {
+			t.Errorf("Excluded(%s, %s) = %v, want %v", c.schema, c.table, got, c.excluded)
+		}
+	}
+
+	// 重新设置规则后缓存应失效
+	filter.SetPatterns(nil)
+	if filter.Excluded("testdb", "users_tmp") {
+		t.Errorf("Expected no exclusion after clearing patterns")
+	}
+}
+
+// TestSplitList 测试逗号分隔列表解析
+func TestSplitList(t *testing.T) {
+	list := SplitList(" a, b ,,c ")
+	if len(list) != 3 || list[0] != "a" || list[1] != "b" || list[2] != "c" {
+		t.Errorf("Unexpected split result: %v", list)
+	}
+
+	if len(SplitList("")) != 0 {
+		t.Errorf("Expected empty list")
+	}
+}
@@ -0,0 +1,163 @@
+package canal
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// getTableSchema 获取表结构
+// 缓存以 table_id 为键：ALTER 之后 MySQL 会为表分配新的 table_id，
+// 同一 table_id 下列类型与 TableMapEvent 不一致时也会重建，避免列错位
+func (m *MySQLBinlogSlave) getTableSchema(schema, table string, tableInfo *replication.TableMapEvent) *TableSchema {
+	m.mu.RLock()
+	ts, exists := m.tableSchemas[tableInfo.TableID]
+	m.mu.RUnlock()
+
+	if exists && ts.matches(schema, table, tableInfo) {
+		return ts
+	}
+
+	ts = m.buildTableSchema(schema, table, tableInfo)
+
+	m.mu.Lock()
+	tableKey := fmt.Sprintf("%s.%s", schema, table)
+	m.schemaVersions[tableKey]++
+	ts.Version = m.schemaVersions[tableKey]
+
+	// 清理同一张表旧 table_id 的缓存
+	for id, old := range m.tableSchemas {
+		if id != tableInfo.TableID && old.Schema == schema && old.Table == table {
+			delete(m.tableSchemas, id)
+		}
+	}
+	m.tableSchemas[tableInfo.TableID] = ts
+	m.mu.Unlock()
+
+	if exists {
+		m.logger.Printf("🔄 Table schema changed for %s (table_id %d), rebuilt as version %d",
+			tableKey, tableInfo.TableID, ts.Version)
+	} else {
+		m.logger.Printf("🆕 Cached table schema for %s (table_id %d, version %d, %d columns)",
+			tableKey, tableInfo.TableID, ts.Version, len(ts.Columns))
+	}
+
+	// 同步到元数据管理器，供下游处理器（如 ClickHouse 建表）使用
+	if m.metaManager != nil {
+		meta := &TableMeta{Schema: schema, Table: table}
+		for _, col := range ts.Columns {
+			meta.Columns = append(meta.Columns, col.Name)
+			meta.Types = append(meta.Types, col.Type)
+		}
+		for _, idx := range ts.PKColumns {
+			if idx < len(ts.Columns) {
+				meta.PrimaryKey = append(meta.PrimaryKey, ts.Columns[idx].Name)
+			}
+		}
+		if err := m.metaManager.SaveTableMeta(schema, table, meta); err != nil {
+			m.logger.Printf("⚠️ Failed to save table meta for %s: %v", tableKey, err)
+		}
+	}
+
+	return ts
+}
+
+// matches 检查缓存的表结构是否与 TableMapEvent 一致
+func (ts *TableSchema) matches(schema, table string, tableInfo *replication.TableMapEvent) bool {
+	return ts.Schema == schema && ts.Table == table && bytes.Equal(ts.ColumnTypes, tableInfo.ColumnType)
+}
+
+// buildTableSchema 根据 TableMapEvent 构建表结构
+// 列名优先取自 binlog（需要 binlog_row_metadata=FULL），否则查询 information_schema
+func (m *MySQLBinlogSlave) buildTableSchema(schema, table string, tableInfo *replication.TableMapEvent) *TableSchema {
+	ts := &TableSchema{
+		Schema:      schema,
+		Table:       table,
+		TableID:     tableInfo.TableID,
+		ColumnTypes: append([]byte(nil), tableInfo.ColumnType...),
+		Columns:     make([]ColumnInfo, len(tableInfo.ColumnType)),
+	}
+
+	names := tableInfo.ColumnNameString()
+	pkColumns := make([]int, 0, len(tableInfo.PrimaryKey))
+	for _, idx := range tableInfo.PrimaryKey {
+		pkColumns = append(pkColumns, int(idx))
+	}
+
+	// binlog 未携带列名时从源库查询，离线读取 binlog 文件且未配置源库时跳过
+	if len(names) != len(tableInfo.ColumnType) && m.config.Host != "" {
+		sourceNames, sourcePK, err := m.querySourceColumns(schema, table)
+		switch {
+		case err != nil:
+			m.logger.Printf("⚠️ Failed to query columns for %s.%s: %v", schema, table, err)
+		case len(sourceNames) != len(tableInfo.ColumnType):
+			// 当前表结构与正在回放的 binlog 不一致，无法可靠对应列名
+			m.logger.Printf("⚠️ Column count mismatch for %s.%s: binlog %d, information_schema %d",
+				schema, table, len(tableInfo.ColumnType), len(sourceNames))
+		default:
+			names = sourceNames
+			if len(pkColumns) == 0 {
+				pkColumns = sourcePK
+			}
+		}
+	}
+
+	for i, colType := range tableInfo.ColumnType {
+		name := fmt.Sprintf("col_%d", i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		nullable := true
+		if available, isNullable := tableInfo.Nullable(i); available {
+			nullable = isNullable
+		}
+
+		ts.Columns[i] = ColumnInfo{
+			Name:     name,
+			Type:     m.getColumnTypeName(colType),
+			Nullable: nullable,
+		}
+	}
+
+	for _, idx := range pkColumns {
+		if idx >= 0 && idx < len(ts.Columns) {
+			ts.Columns[idx].IsPK = true
+			ts.PKColumns = append(ts.PKColumns, idx)
+		}
+	}
+	ts.Hash = schemaHash(ts.schemaColumns())
+
+	return ts
+}
+
+// querySourceColumns 从源库 information_schema 查询列名和主键列索引
+func (m *MySQLBinlogSlave) querySourceColumns(schema, table string) ([]string, []int, error) {
+	db, err := m.openSourceDB()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT COLUMN_NAME, COLUMN_KEY FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`, schema, table)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query information_schema: %v", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	var pk []int
+	for rows.Next() {
+		var name, key string
+		if err := rows.Scan(&name, &key); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan column: %v", err)
+		}
+		if key == "PRI" {
+			pk = append(pk, len(names))
+		}
+		names = append(names, name)
+	}
+	return names, pk, rows.Err()
+}
@@ -0,0 +1,64 @@
+package canal
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// newTestTableMap 创建携带列名的 TableMapEvent
+func newTestTableMap(tableID uint64, columnTypes []byte, names ...string) *replication.TableMapEvent {
+	e := &replication.TableMapEvent{
+		TableID:     tableID,
+		Schema:      []byte("testdb"),
+		Table:       []byte("users"),
+		ColumnCount: uint64(len(columnTypes)),
+		ColumnType:  columnTypes,
+		PrimaryKey:  []uint64{0},
+	}
+	for _, name := range names {
+		e.ColumnName = append(e.ColumnName, []byte(name))
+	}
+	return e
+}
+
+// TestTableSchemaVersioning 测试表结构缓存按 table_id 区分版本
+func TestTableSchemaVersioning(t *testing.T) {
+	logger := log.New(os.Stdout, "[TestTableSchemaVersioning] ", log.LstdFlags)
+	slave, err := NewMySQLBinlogSlave(MySQLConfig{Host: "localhost", Port: 3307, ServerID: 12345}, NewDefaultEventSink(logger), logger)
+	if err != nil {
+		t.Fatalf("Failed to create slave: %v", err)
+	}
+
+	v1 := slave.getTableSchema("testdb", "users", newTestTableMap(100, []byte{3, 15}, "id", "name"))
+	if v1.Version != 1 || v1.Columns[1].Name != "name" || !v1.Columns[0].IsPK {
+		t.Fatalf("unexpected initial schema: %+v", v1)
+	}
+
+	// 相同 table_id 且结构一致时复用缓存
+	if again := slave.getTableSchema("testdb", "users", newTestTableMap(100, []byte{3, 15}, "id", "name")); again != v1 {
+		t.Errorf("expected cached schema to be reused")
+	}
+
+	// ALTER 后出现新的 table_id，列顺序变化
+	v2 := slave.getTableSchema("testdb", "users", newTestTableMap(101, []byte{3, 8, 15}, "id", "age", "name"))
+	if v2.Version != 2 || len(v2.Columns) != 3 || v2.Columns[1].Name != "age" || v2.Columns[1].Type != "bigint" {
+		t.Fatalf("unexpected schema after ALTER: %+v", v2)
+	}
+	if _, exists := slave.tableSchemas[100]; exists {
+		t.Errorf("stale table_id should be evicted")
+	}
+
+	// 同一 table_id 下列类型不一致时重建
+	v3 := slave.getTableSchema("testdb", "users", newTestTableMap(101, []byte{3, 15}, "id", "name"))
+	if v3.Version != 3 || len(v3.Columns) != 2 {
+		t.Fatalf("expected rebuild on column type mismatch: %+v", v3)
+	}
+
+	versions := slave.GetStats()["schema_versions"].(map[string]int)
+	if versions["testdb.users"] != 3 {
+		t.Errorf("unexpected schema versions: %v", versions)
+	}
+}
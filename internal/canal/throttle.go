@@ -0,0 +1,300 @@
+package canal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	"pikachun/internal/config"
+)
+
+// RateLimiter 令牌桶限速器，rate <= 0 表示不限速
+// 令牌允许透支：一次请求超过桶容量时不会永远等待，而是让后续请求按速率补齐
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒令牌数
+	burst  float64 // 桶容量
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter 创建限速器，桶容量为一秒的令牌数
+func NewRateLimiter(rate float64) *RateLimiter {
+	l := &RateLimiter{}
+	l.SetRate(rate)
+	return l
+}
+
+// SetRate 调整速率
+func (l *RateLimiter) SetRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = rate
+	l.burst = rate
+	if l.burst < 1 {
+		l.burst = 1
+	}
+	l.tokens = l.burst
+	l.last = time.Now()
+}
+
+// WaitN 等待 n 个令牌，返回实际等待时间
+func (l *RateLimiter) WaitN(ctx context.Context, n float64) (time.Duration, error) {
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return 0, nil
+	}
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= n
+
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return 0, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-timer.C:
+		return wait, nil
+	}
+}
+
+// ReadThrottle binlog 读取限速，所有实例共享，限制对源库的总读取速率
+// 限速时各实例轮流等待配额，轮次按任务优先级加权分配（与投递调度相同）
+type ReadThrottle struct {
+	events *RateLimiter
+	bytes  *RateLimiter
+	turns  *DeliveryScheduler
+
+	waitedNanos int64 // 累计限速等待时间
+}
+
+// NewReadThrottle 根据配置创建读取限速，未配置任何限制时返回 nil
+func NewReadThrottle(cfg config.ThrottleConfig) *ReadThrottle {
+	if cfg.MaxEventsPerSecond <= 0 && cfg.MaxMBPerSecond <= 0 {
+		return nil
+	}
+	return &ReadThrottle{
+		events: NewRateLimiter(float64(cfg.MaxEventsPerSecond)),
+		bytes:  NewRateLimiter(cfg.MaxMBPerSecond * 1024 * 1024),
+		turns:  NewDeliveryScheduler(1),
+	}
+}
+
+// Wait 读取一个大小为 size 字节的 binlog 事件前等待配额，nil 表示不限速
+func (t *ReadThrottle) Wait(ctx context.Context, size int) error {
+	return t.WaitFor(ctx, "", 1, size)
+}
+
+// WaitFor 以 key 的身份等待配额，priority 为调度权重，限速时优先级高的实例更频繁地获得配额
+func (t *ReadThrottle) WaitFor(ctx context.Context, key string, priority, size int) error {
+	if t == nil {
+		return nil
+	}
+
+	release, err := t.turns.Acquire(ctx, key, priority)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	waitedEvents, err := t.events.WaitN(ctx, 1)
+	if err != nil {
+		return err
+	}
+	waitedBytes, err := t.bytes.WaitN(ctx, float64(size))
+	if err != nil {
+		return err
+	}
+	atomic.AddInt64(&t.waitedNanos, int64(waitedEvents+waitedBytes))
+	return nil
+}
+
+// WaitedSeconds 累计限速等待时间（秒）
+func (t *ReadThrottle) WaitedSeconds() float64 {
+	if t == nil {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&t.waitedNanos)).Seconds()
+}
+
+// SetReadThrottle 设置全局读取限速
+func (m *MySQLBinlogSlave) SetReadThrottle(throttle *ReadThrottle) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.readThrottle = throttle
+}
+
+// SetLogSampler 设置热点路径日志采样，需在启动前设置
+func (m *MySQLBinlogSlave) SetLogSampler(sampler *LogSampler) {
+	m.logSampler = sampler
+}
+
+// SetPriority 设置任务优先级，全局读取限速时作为调度权重
+func (m *MySQLBinlogSlave) SetPriority(priority int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.priority = priority
+}
+
+// SetSchedule 设置维护窗口，nil 表示不限制
+func (m *MySQLBinlogSlave) SetSchedule(schedule *DeliverySchedule) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.schedule = schedule
+	m.scheduleLimiter = nil
+	if schedule != nil && schedule.Mode == ScheduleModeThrottle {
+		m.scheduleLimiter = NewRateLimiter(float64(schedule.Rate))
+	}
+}
+
+// waitReadLimits 处理 binlog 事件前检查人工维护暂停和维护窗口，等待全局限速和内存限制，ctx 取消时返回错误。
+// 需要暂停时在可以重新连接的位置返回 errStreamPaused，事务中的事件继续读完
+func (m *MySQLBinlogSlave) waitReadLimits(ev *replication.BinlogEvent) error {
+	if m.atResumePoint && m.pauseRequested(time.Now()) {
+		return errStreamPaused
+	}
+
+	m.mu.RLock()
+	throttle, priority := m.readThrottle, m.priority
+	m.mu.RUnlock()
+
+	if err := throttle.WaitFor(m.ctx, m.instanceID, priority, int(ev.Header.EventSize)); err != nil {
+		return err
+	}
+	if err := m.waitMemory(); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	schedule, limiter := m.schedule, m.scheduleLimiter
+	m.mu.RUnlock()
+	if schedule.Active(time.Now()) && schedule.Mode == ScheduleModeThrottle {
+		_, err := limiter.WaitN(m.ctx, 1)
+		return err
+	}
+	return nil
+}
+
+// errStreamPaused 进入暂停时断开 binlog 连接，不占用源库的 dump 连接，恢复后从断开的位置重新连接
+var errStreamPaused = errors.New("binlog stream paused")
+
+// pauseCheckInterval 等待事件期间检查暂停的间隔
+const pauseCheckInterval = time.Second
+
+// pauseState 当前生效的人工维护暂停（没有时为 nil），以及是否处于 pause 模式的维护窗口
+func (m *MySQLBinlogSlave) pauseState(now time.Time) (*MaintenancePause, bool) {
+	m.mu.RLock()
+	schedule, maintenance := m.schedule, m.maintenance
+	m.mu.RUnlock()
+	return maintenance.Paused(DefaultSourceID), schedule.Active(now) && schedule.Mode != ScheduleModeThrottle
+}
+
+// pauseRequested 当前是否需要暂停读取
+func (m *MySQLBinlogSlave) pauseRequested(now time.Time) bool {
+	pause, scheduled := m.pauseState(now)
+	return pause != nil || scheduled
+}
+
+// nextEvent 读取下一个事件。没有新事件时每隔 pauseCheckInterval 检查一次暂停，
+// 需要暂停且处于可以重新连接的位置时返回 errStreamPaused
+func (m *MySQLBinlogSlave) nextEvent(streamer *replication.BinlogStreamer) (*replication.BinlogEvent, error) {
+	for {
+		ctx, cancel := context.WithTimeout(m.ctx, pauseCheckInterval)
+		ev, err := streamer.GetEvent(ctx)
+		cancel()
+		if err == nil || m.ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+			return ev, err
+		}
+		if m.atResumePoint && m.pauseRequested(time.Now()) {
+			return nil, errStreamPaused
+		}
+	}
+}
+
+// waitStreamResume 暂停期间关闭 binlog 连接并保存位置，等待人工维护暂停解除和维护窗口结束，ctx 取消时返回错误。
+// 维护窗口以分钟为粒度，到下一分钟再检查
+func (m *MySQLBinlogSlave) waitStreamResume() error {
+	m.mu.Lock()
+	if m.syncer != nil {
+		m.syncer.Close()
+	}
+	maintenance := m.maintenance
+	m.mu.Unlock()
+
+	var saved Position
+	for {
+		changed := maintenance.Changed()
+		now := time.Now()
+		pause, scheduled := m.pauseState(now)
+		if pause != nil {
+			m.setMaintenancePaused(true, pause.Reason)
+		} else {
+			m.setMaintenancePaused(false, "")
+		}
+		m.setPaused(scheduled)
+		if pause == nil && !scheduled {
+			return nil
+		}
+
+		// 断开后 sink 仍在投递队列中的事件，每次检查时重新保存，重启后从最新的位置继续
+		pos, err := m.savePositionSync()
+		if err != nil {
+			m.logger.Printf("❌ Failed to save binlog position of %s while paused: %v", m.instanceID, err)
+		} else if pos.Name != "" && pos != saved {
+			saved = pos
+			m.logger.Printf("💾 Saved binlog position %s:%d of %s, binlog connection closed while paused", pos.Name, pos.Pos, m.instanceID)
+		}
+
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		select {
+		case <-m.ctx.Done():
+			timer.Stop()
+			return m.ctx.Err()
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// setPaused 更新暂停状态并在变化时记录日志
+func (m *MySQLBinlogSlave) setPaused(paused bool) {
+	m.mu.RLock()
+	changed := m.paused != paused
+	m.mu.RUnlock()
+	if !changed {
+		return
+	}
+
+	m.mu.Lock()
+	m.paused = paused
+	m.mu.Unlock()
+
+	if paused {
+		m.logger.Printf("⏸️ Maintenance window started, pausing binlog reading for %s", m.instanceID)
+	} else {
+		m.logger.Printf("▶️ Maintenance window ended, resuming binlog reading for %s", m.instanceID)
+	}
+}
@@ -22,6 +22,22 @@ type MySQLConfig struct {
 	ServerID   uint32 `json:"server_id"`
 	BinlogFile string `json:"binlog_file"`
 	BinlogPos  uint32 `json:"binlog_pos"`
+
+	EventIDFormat string `json:"event_id_format"` // position, ulid 或 uuidv7
+
+	PositionCheckInterval time.Duration `json:"position_check_interval"` // 位置漂移检查间隔，0 表示不检查
+	Checkpoint            string        `json:"checkpoint"`              // 位置保存时机，见 CheckpointEvent
+
+	Connection SourceConnection `json:"connection"` // 源库连接的心跳、超时和 keepalive，零值使用默认值
+
+	MinBinlogRetention time.Duration `json:"min_binlog_retention"` // 源库 binlog 保留时间低于该值时给出警告
+
+	Checksum BinlogChecksum `json:"checksum"` // 事件校验和的处理，零值使用默认设置
+
+	CaptureSQL   bool `json:"capture_sql"`    // 将 ROWS_QUERY 事件中的原始语句附加到行事件
+	MaxSQLLength int  `json:"max_sql_length"` // 原始语句的最大字节数，0 表示不截断
+
+	NumericStrings bool `json:"numeric_strings"` // 全局的数值安全编码，任务未设置时使用
 }
 
 // VitessBinlogSlave 基于Vitess的纯粹binlog dump实现
@@ -410,7 +426,7 @@ func (v *VitessBinlogSlave) Stop() error {
 
 // createDatabaseConnection 创建数据库连接
 func (v *VitessBinlogSlave) createDatabaseConnection() error {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/", v.config.Username, v.config.Password, v.config.Host, v.config.Port)
+	dsn := SourceDSN(v.config.Username, v.config.Password, v.config.Host, v.config.Port, "")
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %v", err)
@@ -428,7 +444,7 @@ func (v *VitessBinlogSlave) createDatabaseConnection() error {
 // createSlaveConnection 创建Vitess slave连接
 func (v *VitessBinlogSlave) createSlaveConnection() error {
 	dumpConnFunc := func() (dumpConn, error) {
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/", v.config.Username, v.config.Password, v.config.Host, v.config.Port)
+		dsn := SourceDSN(v.config.Username, v.config.Password, v.config.Host, v.config.Port, "")
 		db, err := sql.Open("mysql", dsn)
 		if err != nil {
 			return nil, err
@@ -869,7 +885,6 @@ func (v *VitessBinlogSlave) convertBinlogEventToCanalEvent(binlogEvent BinlogEve
 		},
 		BeforeData: beforeData,
 		AfterData:  afterData,
-		SQL:        fmt.Sprintf("%s INTO %s.%s VALUES (...)", eventType, schema, table),
 	}
 
 	return event
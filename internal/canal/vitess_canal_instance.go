@@ -160,6 +160,7 @@ func (c *VitessCanalInstance) GetStatus() InstanceStatus {
 		c.status.Position = c.vitessSlave.GetBinlogPosition()
 		c.status.Running = c.vitessSlave.IsRunning()
 	}
+	c.status.Sinks = c.eventSink.SinkStatuses()
 
 	return c.status
 }
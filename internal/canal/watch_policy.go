@@ -0,0 +1,135 @@
+package canal
+
+import (
+	"fmt"
+	"strings"
+
+	"pikachun/internal/config"
+)
+
+// WatchPolicy 全局监听策略，作用于所有实例，任务的表、事件类型和排除规则在此之上合并：
+// 任务的表总是被监听，事件类型取并集，排除规则合并
+type WatchPolicy struct {
+	Tables        []string `json:"tables"`         // 额外监听的表（schema.table），通常为空
+	EventTypes    []string `json:"event_types"`    // 所有实例读取的事件类型: INSERT、UPDATE、DELETE
+	ExcludeTables []string `json:"exclude_tables"` // 排除规则，支持通配符，见 TableFilter
+}
+
+// WatchPolicyFromConfig 由配置文件的 canal.watch 生成监听策略，databases 与 tables 两两组合为监听的表
+func WatchPolicyFromConfig(cfg config.WatchConfig) WatchPolicy {
+	policy := WatchPolicy{
+		EventTypes:    append([]string(nil), cfg.EventTypes...),
+		ExcludeTables: append([]string(nil), cfg.ExcludeTables...),
+	}
+	for _, db := range cfg.Databases {
+		for _, table := range cfg.Tables {
+			policy.Tables = append(policy.Tables, db+"."+table)
+		}
+	}
+	return policy
+}
+
+// Normalize 去除空白和重复项，事件类型转为大写，并校验表名和事件类型
+func (p WatchPolicy) Normalize() (WatchPolicy, error) {
+	var normalized WatchPolicy
+	var err error
+
+	normalized.Tables, err = normalizeList(p.Tables, func(table string) (string, error) {
+		parts := strings.SplitN(table, ".", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.ContainsAny(table, "*?") {
+			return "", fmt.Errorf("invalid watch table %q: expected schema.table", table)
+		}
+		return table, nil
+	})
+	if err != nil {
+		return WatchPolicy{}, err
+	}
+
+	normalized.EventTypes, err = normalizeList(p.EventTypes, func(eventType string) (string, error) {
+		eventType = strings.ToUpper(eventType)
+		if len(parseEventTypes([]string{eventType})) == 0 {
+			return "", fmt.Errorf("invalid event type %q: expected INSERT, UPDATE or DELETE", eventType)
+		}
+		return eventType, nil
+	})
+	if err != nil {
+		return WatchPolicy{}, err
+	}
+
+	normalized.ExcludeTables, err = normalizeList(p.ExcludeTables, func(pattern string) (string, error) {
+		return pattern, nil
+	})
+	return normalized, err
+}
+
+// normalizeList 去除空白项和重复项，保持原有顺序
+func normalizeList(items []string, normalize func(string) (string, error)) ([]string, error) {
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		item, err := normalize(item)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// SetWatchPolicy 应用全局监听策略，运行中即时生效：
+// 重新合并任务的排除规则和事件类型，增减额外监听的表（仍有订阅的表不会被移除）
+func (c *MySQLCanalInstance) SetWatchPolicy(policy WatchPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.globalExcludes = append([]string(nil), policy.ExcludeTables...)
+	c.globalTypes = parseEventTypes(policy.EventTypes)
+	c.setExcludeTablesLocked(c.taskExcludes)
+	c.applyReadEventTypesLocked()
+
+	added := make(map[string]bool)
+	for _, key := range policy.Tables {
+		added[key] = true
+	}
+	for _, key := range c.policyTables {
+		if added[key] || c.eventSink.hasSubscribers(key) {
+			continue
+		}
+		if parts := strings.SplitN(key, ".", 2); len(parts) == 2 {
+			c.binlogSlave.RemoveWatchTable(parts[0], parts[1])
+		}
+	}
+	for _, key := range policy.Tables {
+		if parts := strings.SplitN(key, ".", 2); len(parts) == 2 {
+			c.binlogSlave.AddWatchTable(parts[0], parts[1])
+		}
+	}
+	c.policyTables = append([]string(nil), policy.Tables...)
+
+	c.logger.Printf("🔧 Watch policy applied to %s: tables=%v, event types=%v, excludes=%v",
+		c.id, policy.Tables, policy.EventTypes, policy.ExcludeTables)
+}
+
+// isPolicyTableLocked schema.table 是否由全局监听策略监听，调用方需持有锁
+func (c *MySQLCanalInstance) isPolicyTableLocked(key string) bool {
+	for _, table := range c.policyTables {
+		if table == key {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSubscribers schema.table 上是否有订阅的处理器
+func (s *DefaultEventSink) hasSubscribers(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.handlers[key]) > 0
+}
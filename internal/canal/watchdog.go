@@ -0,0 +1,214 @@
+package canal
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// WatchdogPolicy 停滞检测与自动重启策略，StallTimeout 为 0 表示不检测
+type WatchdogPolicy struct {
+	StallTimeout  time.Duration
+	MaxRestarts   int
+	RestartWindow time.Duration
+	Jitter        time.Duration
+}
+
+// NewWatchdogPolicy 根据配置创建停滞检测策略，无效的时长使用默认值
+func NewWatchdogPolicy(cfg config.WatchdogConfig) WatchdogPolicy {
+	if !cfg.Enabled {
+		return WatchdogPolicy{}
+	}
+	policy := WatchdogPolicy{
+		StallTimeout:  parseDurationOr(cfg.StallTimeout, 2*time.Minute),
+		MaxRestarts:   cfg.MaxRestarts,
+		RestartWindow: parseDurationOr(cfg.RestartWindow, time.Hour),
+		Jitter:        parseDurationOr(cfg.Jitter, 10*time.Second),
+	}
+	if policy.MaxRestarts <= 0 {
+		policy.MaxRestarts = 5
+	}
+	return policy
+}
+
+// parseDurationOr 解析时长，未配置或无效时返回默认值
+func parseDurationOr(value string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return fallback
+	}
+	return d
+}
+
+// Enabled 是否开启停滞检测
+func (p WatchdogPolicy) Enabled() bool {
+	return p.StallTimeout > 0
+}
+
+// maxStreamRestarts 保留的重启记录数
+const maxStreamRestarts = 50
+
+// StreamRestart 一次由停滞检测触发的 binlog 流重启
+type StreamRestart struct {
+	At             time.Time `json:"at" format:"date-time"`
+	Reason         string    `json:"reason"`
+	Position       Position  `json:"position"`        // 停滞时的读取位置
+	SourcePosition Position  `json:"source_position"` // 源库当时的最新位置
+	Skipped        bool      `json:"skipped"`         // 达到重启次数上限，未重启
+}
+
+// watchdogState 停滞检测状态，受 MySQLBinlogSlave.mu 保护
+type watchdogState struct {
+	policy         WatchdogPolicy
+	sourcePosition func() (Position, error) // 查询源库最新位置，测试中可替换
+
+	lastPos      Position  // 上次检查时的读取位置
+	progressAt   time.Time // 读取位置最近一次变化的时间
+	stalledSince time.Time
+	restarts     []StreamRestart
+	restartTotal int
+}
+
+// SetWatchdog 设置停滞检测策略
+func (m *MySQLBinlogSlave) SetWatchdog(policy WatchdogPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watchdog.policy = policy
+	m.watchdog.progressAt = time.Now()
+}
+
+// checkStall 源库有新的 binlog 而读取位置超过 StallTimeout 没有变化时重启 binlog 流。
+// 维护窗口、人工维护暂停、内存暂停和 binlog 被清除时停滞是预期的，不计入
+func (m *MySQLBinlogSlave) checkStall() {
+	now := time.Now()
+
+	m.mu.Lock()
+	policy := m.watchdog.policy
+	read := Position{Name: m.binlogPos.Name, Pos: m.binlogPos.Pos}
+	expected := m.paused || m.maintenancePaused || m.memoryPaused || m.purged || !m.running
+	if !policy.Enabled() {
+		m.mu.Unlock()
+		return
+	}
+	if expected || ComparePositions(read, m.watchdog.lastPos) != 0 {
+		m.watchdog.lastPos = read
+		m.watchdog.progressAt = now
+		m.watchdog.stalledSince = time.Time{}
+		m.mu.Unlock()
+		return
+	}
+	idle := now.Sub(m.watchdog.progressAt)
+	sourcePosition := m.watchdog.sourcePosition
+	m.mu.Unlock()
+
+	if idle < policy.StallTimeout {
+		return
+	}
+
+	if sourcePosition == nil {
+		sourcePosition = m.querySourcePosition
+	}
+	source, err := sourcePosition()
+	if err != nil {
+		// 源库不可达时由重连逻辑处理
+		m.logger.Printf("⚠️ Watchdog failed to query source position for %s: %v", m.instanceID, err)
+		return
+	}
+	if ComparePositions(source, read) <= 0 {
+		// 源库没有新的 binlog，只是空闲
+		return
+	}
+
+	m.mu.Lock()
+	if m.watchdog.stalledSince.IsZero() {
+		m.watchdog.stalledSince = m.watchdog.progressAt
+	}
+	restart := StreamRestart{
+		At:             now,
+		Reason:         fmt.Sprintf("no progress for %v while source is at %s:%d", idle.Round(time.Second), source.Name, source.Pos),
+		Position:       read,
+		SourcePosition: source,
+	}
+	if m.recentRestartsLocked(now) >= policy.MaxRestarts {
+		restart.Skipped = true
+		m.recordRestartLocked(restart)
+		m.lastError = fmt.Sprintf("stalled at %s:%d, restart limit (%d per %v) reached", read.Name, read.Pos, policy.MaxRestarts, policy.RestartWindow)
+		m.lastErrorAt = now
+		m.mu.Unlock()
+		m.logger.Printf("🚨 Binlog stream of %s stalled at %s:%d, restart limit reached", m.instanceID, read.Name, read.Pos)
+		return
+	}
+	m.mu.Unlock()
+
+	// 随机等待，避免多个实例同时重连源库
+	if policy.Jitter > 0 {
+		if err := m.sleep(time.Duration(rand.Int63n(int64(policy.Jitter)))); err != nil {
+			return
+		}
+	}
+
+	m.logger.Printf("🐕 Binlog stream of %s stalled: %s, restarting", m.instanceID, restart.Reason)
+	m.mu.Lock()
+	m.recordRestartLocked(restart)
+	m.watchdog.progressAt = time.Now()
+	// 关闭连接后读取循环返回错误，走重连流程从当前位置重新读取
+	if m.syncer != nil {
+		m.syncer.Close()
+	}
+	m.mu.Unlock()
+}
+
+// querySourcePosition 源库当前最新的 binlog 位置
+func (m *MySQLBinlogSlave) querySourcePosition() (Position, error) {
+	pos, err := m.queryLatestPosition()
+	if err != nil {
+		return Position{}, err
+	}
+	return Position{Name: pos.Name, Pos: pos.Pos}, nil
+}
+
+// recentRestartsLocked 统计窗口内实际执行的重启次数，调用方需持有锁
+func (m *MySQLBinlogSlave) recentRestartsLocked(now time.Time) int {
+	count := 0
+	for _, restart := range m.watchdog.restarts {
+		if !restart.Skipped && now.Sub(restart.At) < m.watchdog.policy.RestartWindow {
+			count++
+		}
+	}
+	return count
+}
+
+// recordRestartLocked 记录重启，只保留最近的记录，调用方需持有锁
+func (m *MySQLBinlogSlave) recordRestartLocked(restart StreamRestart) {
+	if !restart.Skipped {
+		m.watchdog.restartTotal++
+	}
+	m.watchdog.restarts = append(m.watchdog.restarts, restart)
+	if len(m.watchdog.restarts) > maxStreamRestarts {
+		m.watchdog.restarts = m.watchdog.restarts[len(m.watchdog.restarts)-maxStreamRestarts:]
+	}
+}
+
+// StreamRestarts 停滞检测触发的重启记录，按时间先后排列
+func (m *MySQLBinlogSlave) StreamRestarts() []StreamRestart {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]StreamRestart(nil), m.watchdog.restarts...)
+}
+
+// watchdogStatsLocked 停滞检测统计，调用方需持有锁
+func (m *MySQLBinlogSlave) watchdogStatsLocked() map[string]interface{} {
+	stats := map[string]interface{}{
+		"restart_total":   m.watchdog.restartTotal,
+		"recent_restarts": m.recentRestartsLocked(time.Now()),
+	}
+	if !m.watchdog.stalledSince.IsZero() {
+		stats["stalled_since"] = m.watchdog.stalledSince
+	}
+	if n := len(m.watchdog.restarts); n > 0 {
+		stats["last_restart"] = m.watchdog.restarts[n-1]
+	}
+	return stats
+}
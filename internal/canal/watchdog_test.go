@@ -0,0 +1,69 @@
+package canal
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// TestCheckStall 测试源库有新 binlog 时重启停滞的流、空闲时不重启以及重启次数上限
+func TestCheckStall(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	slave, err := NewMySQLBinlogSlave(MySQLConfig{Host: "127.0.0.1", Port: 1, ServerID: 1001}, NewDefaultEventSink(logger), logger)
+	if err != nil {
+		t.Fatalf("Failed to create binlog slave: %v", err)
+	}
+	slave.running = true
+	slave.binlogPos.Name, slave.binlogPos.Pos = "mysql-bin.000002", 100
+	slave.SetWatchdog(WatchdogPolicy{StallTimeout: time.Millisecond, MaxRestarts: 1, RestartWindow: time.Hour})
+
+	source := Position{Name: "mysql-bin.000002", Pos: 100}
+	slave.watchdog.sourcePosition = func() (Position, error) { return source, nil }
+	stall := func() {
+		time.Sleep(2 * time.Millisecond)
+		slave.checkStall()
+	}
+
+	// 首次检查记录进度；源库没有新 binlog 时只是空闲
+	slave.checkStall()
+	stall()
+	if restarts := slave.StreamRestarts(); len(restarts) != 0 {
+		t.Fatalf("expected no restart while source is idle, got %+v", restarts)
+	}
+
+	// 源库前进而实例没有进展：重启
+	source.Pos = 500
+	stall()
+	restarts := slave.StreamRestarts()
+	if len(restarts) != 1 || restarts[0].Skipped || restarts[0].SourcePosition != source {
+		t.Fatalf("expected one restart, got %+v", restarts)
+	}
+	if _, ok := slave.GetStats()["watchdog"].(map[string]interface{})["stalled_since"]; !ok {
+		t.Errorf("expected stalled_since in stats")
+	}
+
+	// 达到重启次数上限：只记录不重启
+	stall()
+	restarts = slave.StreamRestarts()
+	if len(restarts) != 2 || !restarts[1].Skipped {
+		t.Fatalf("expected skipped restart at limit, got %+v", restarts)
+	}
+	if slave.GetStats()["last_error"] == "" {
+		t.Errorf("expected last error to be set when restart limit reached")
+	}
+
+	// 有进展后清除停滞状态；维护窗口暂停期间不检测
+	slave.binlogPos.Pos = 500
+	slave.checkStall()
+	if _, ok := slave.GetStats()["watchdog"].(map[string]interface{})["stalled_since"]; ok {
+		t.Errorf("expected stall to be cleared after progress")
+	}
+	slave.paused = true
+	source.Pos = 900
+	stall()
+	stall()
+	if len(slave.StreamRestarts()) != 2 {
+		t.Errorf("expected no restart while paused")
+	}
+}
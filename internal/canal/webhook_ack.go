@@ -0,0 +1,114 @@
+package canal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxAckResponseSize 解析逐事件确认时读取的响应体最大长度
+const maxAckResponseSize = 1 << 20
+
+// 逐事件确认的状态
+const (
+	AckStatusAck  = "ack"
+	AckStatusNack = "nack"
+)
+
+// EventNack 接收方拒绝的事件及原因
+type EventNack struct {
+	EventID string `json:"event_id"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ackResponse 接收方可选的逐事件确认响应，如
+// {"results": [{"id": "e1", "status": "ack"}, {"id": "e2", "status": "nack", "error": "duplicate key"}]}
+type ackResponse struct {
+	Results []struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"results"`
+}
+
+// parseAckResponse 解析 2xx 响应体中的逐事件确认，返回被拒绝的事件，按请求中的顺序排列。
+// 响应体不是确认格式（空、非 JSON 或没有 results）时整批视为确认；未列出的事件和未知的事件ID分别视为确认和忽略
+func parseAckResponse(body []byte, events []*Event) []EventNack {
+	var resp ackResponse
+	if len(body) == 0 || json.Unmarshal(body, &resp) != nil || len(resp.Results) == 0 {
+		return nil
+	}
+
+	nacked := make(map[string]string)
+	for _, result := range resp.Results {
+		if strings.EqualFold(result.Status, AckStatusNack) {
+			nacked[result.ID] = result.Error
+		}
+	}
+	if len(nacked) == 0 {
+		return nil
+	}
+
+	var nacks []EventNack
+	for _, event := range events {
+		if reason, ok := nacked[event.ID]; ok {
+			nacks = append(nacks, EventNack{EventID: event.ID, Error: reason})
+		}
+	}
+	return nacks
+}
+
+// NackError 接收方确认了请求但拒绝了其中部分事件，只有被拒绝的事件需要重试
+type NackError struct {
+	Total int
+	Nacks []EventNack
+}
+
+func (e *NackError) Error() string {
+	reasons := make([]string, 0, len(e.Nacks))
+	for _, nack := range e.Nacks {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", nack.EventID, nack.Error))
+	}
+	return fmt.Sprintf("%d of %d events nacked by receiver (%s)", len(e.Nacks), e.Total, strings.Join(reasons, "; "))
+}
+
+// nackedEvents 返回被拒绝的事件，保持原有顺序
+func (e *NackError) nackedEvents(events []*Event) []*Event {
+	ids := make(map[string]bool, len(e.Nacks))
+	for _, nack := range e.Nacks {
+		ids[nack.EventID] = true
+	}
+	result := make([]*Event, 0, len(e.Nacks))
+	for _, event := range events {
+		if ids[event.ID] {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+// FailedEventRecorder 记录重试后仍被接收方拒绝的事件，DeliveryRecorder 可选实现。
+// 这些事件在事件日志中记为 failed，进入失败事件列表，可通过重新投递接口再次发送；
+// 事件日志中还没有该事件（如未启用数据库存储）时插入一条
+type FailedEventRecorder interface {
+	RecordFailedEvents(entries []EventLogEntry) error
+}
+
+// failedEventEntries 被拒绝的事件的事件日志记录，状态为 failed，错误为接收方给出的原因
+func failedEventEntries(taskID uint, nacks []EventNack, events []*Event) []EventLogEntry {
+	reasons := make(map[string]string, len(nacks))
+	for _, nack := range nacks {
+		reasons[nack.EventID] = nack.Error
+	}
+	entries := make([]EventLogEntry, 0, len(nacks))
+	for _, event := range events {
+		reason, ok := reasons[event.ID]
+		if !ok {
+			continue
+		}
+		entry := newEventLogEntry(taskID, event)
+		entry.Status, entry.Error = "failed", "nacked by receiver: "+reason
+		entries = append(entries, entry)
+	}
+	return entries
+}
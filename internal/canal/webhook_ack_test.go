@@ -0,0 +1,100 @@
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestParseAckResponse 测试逐事件确认的解析：非确认格式的响应整批视为确认
+func TestParseAckResponse(t *testing.T) {
+	events := []*Event{{ID: "e1"}, {ID: "e2"}, {ID: "e3"}}
+	for _, body := range []string{"", "ok", `{"status": "ok"}`, `{"results": []}`, `{"results": [{"id": "e1", "status": "ack"}]}`} {
+		if nacks := parseAckResponse([]byte(body), events); nacks != nil {
+			t.Errorf("expected %q to ack the whole batch, got %v", body, nacks)
+		}
+	}
+
+	nacks := parseAckResponse([]byte(`{"results": [{"id": "e3", "status": "NACK", "error": "locked"}, {"id": "e2", "status": "nack"}, {"id": "e9", "status": "nack"}]}`), events)
+	want := []EventNack{{EventID: "e2"}, {EventID: "e3", Error: "locked"}}
+	if !reflect.DeepEqual(nacks, want) {
+		t.Errorf("expected %v, got %v", want, nacks)
+	}
+}
+
+// failedEventRecorder 同时记录投递尝试和仍被拒绝的事件
+type failedEventRecorder struct {
+	fakeDeliveryRecorder
+	failed []EventLogEntry
+}
+
+func (r *failedEventRecorder) RecordFailedEvents(entries []EventLogEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = append(r.failed, entries...)
+	return nil
+}
+
+// TestWebhookHandlerNackRetry 测试被拒绝的事件单独重试，已确认的事件不再发送，重试用尽后记入失败事件
+func TestWebhookHandlerNackRetry(t *testing.T) {
+	var mu sync.Mutex
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Events []Event `json:"events"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		defer mu.Unlock()
+		var ids []string
+		var results []string
+		for _, event := range payload.Events {
+			ids = append(ids, event.ID)
+			// e2 第二次发送时接受，e3 始终拒绝
+			status := "ack"
+			if event.ID == "e3" || (event.ID == "e2" && len(requests) == 0) {
+				status = "nack"
+			}
+			results = append(results, fmt.Sprintf(`{"id": %q, "status": %q, "error": "busy"}`, event.ID, status))
+		}
+		requests = append(requests, ids)
+		fmt.Fprintf(w, `{"results": [%s]}`, strings.Join(results, ", "))
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler("webhook-1", server.URL, log.New(io.Discard, "", 0))
+	handler.retryInterval = time.Millisecond
+	handler.maxRetries = 2
+	recorder := &failedEventRecorder{}
+	handler.SetDeliveryRecorder(7, recorder)
+
+	handler.sendEventsWithRetry(context.Background(), deliveryBatch{sequence: 1, events: []*Event{{ID: "e1"}, {ID: "e2"},
+		{ID: "e3", Schema: "shop", Table: "orders", EventType: EventTypeInsert, AfterData: &RowData{Columns: []Column{{Name: "id", Value: 3}}}}}})
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := [][]string{{"e1", "e2", "e3"}, {"e2", "e3"}, {"e3"}}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("expected requests %v, got %v", want, requests)
+	}
+	stats := handler.GetStats()
+	if stats["success_count"] != int64(2) || stats["failed_events"] != int64(1) {
+		t.Errorf("unexpected stats %v", stats)
+	}
+	if len(recorder.failed) != 1 || recorder.failed[0].EventID != "e3" || recorder.failed[0].Error != "nacked by receiver: busy" ||
+		recorder.failed[0].TaskID != 7 || recorder.failed[0].Table != "orders" || !strings.Contains(recorder.failed[0].Data, `"value":3`) {
+		t.Errorf("expected e3 to be recorded as failed, got %v", recorder.failed)
+	}
+	if first := recorder.attempts[0]; len(first.Nacked) != 2 || first.Error == "" || first.StatusCode != http.StatusOK {
+		t.Errorf("unexpected first attempt %+v", first)
+	}
+}
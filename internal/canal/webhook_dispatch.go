@@ -0,0 +1,69 @@
+package canal
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// queuedFlush 一次刷新产生的请求，按刷新顺序排队投递
+type queuedFlush struct {
+	batches []deliveryBatch
+	mark    *deliveryMark
+	bytes   int64 // 刷新时缓冲区的字节数
+	count   int64 // 刷新时缓冲区收到的事件数（含被合并掉的）
+}
+
+// enqueueFlush 把一次刷新加入投递队列，没有投递协程时启动一个。
+// 每个处理器同一时刻只有一个请求在投递：队首的请求重试期间之后的请求等待，
+// 请求序号（X-Delivery-Sequence）按发送顺序严格递增，重试不会与之后的请求交错。调用方需持有 bufferMu
+func (h *WebhookHandler) enqueueFlush(flush *queuedFlush) {
+	h.inflight.Add(1)
+	h.dispatchMu.Lock()
+	defer h.dispatchMu.Unlock()
+	h.dispatchQueue = append(h.dispatchQueue, flush)
+	if !h.dispatching {
+		h.dispatching = true
+		go h.dispatch()
+	}
+}
+
+// dispatch 依次投递队列中的刷新，队列为空时退出
+func (h *WebhookHandler) dispatch() {
+	for {
+		h.dispatchMu.Lock()
+		if len(h.dispatchQueue) == 0 {
+			h.dispatching = false
+			h.dispatchMu.Unlock()
+			return
+		}
+		flush := h.dispatchQueue[0]
+		h.dispatchQueue[0] = nil
+		h.dispatchQueue = h.dispatchQueue[1:]
+		h.dispatchMu.Unlock()
+
+		h.deliverFlush(flush)
+	}
+}
+
+// deliverFlush 投递一次刷新的所有请求，总超时从开始投递时计算，排队等待的时间不计入
+func (h *WebhookHandler) deliverFlush(flush *queuedFlush) {
+	defer h.inflight.Done()
+	defer h.endDelivery(flush.mark)
+	defer atomic.AddInt64(&h.bufferedBytes, -flush.bytes)
+	defer atomic.AddInt64(&h.pendingEvents, -flush.count)
+
+	// 创建新的context避免使用已取消的context，总超时覆盖所有重试
+	sendCtx, cancel := context.WithTimeout(context.Background(), h.getTimeouts().Delivery)
+	defer cancel()
+	// 各分区依次发送，同一分区内保持顺序
+	for _, batch := range flush.batches {
+		h.sendEventsWithRetry(sendCtx, batch)
+	}
+}
+
+// queuedFlushes 排队等待投递的刷新数，不含正在投递的
+func (h *WebhookHandler) queuedFlushes() int {
+	h.dispatchMu.Lock()
+	defer h.dispatchMu.Unlock()
+	return len(h.dispatchQueue)
+}
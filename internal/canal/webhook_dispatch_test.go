@@ -0,0 +1,55 @@
+package canal
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestWebhookHandlerSequentialDispatch 测试各次刷新按顺序投递：队首的请求重试期间之后的请求不发送，
+// 请求序号按发送顺序递增
+func TestWebhookHandlerSequentialDispatch(t *testing.T) {
+	var mu sync.Mutex
+	var sequences []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sequences = append(sequences, r.Header.Get("X-Delivery-Sequence"))
+		first := len(sequences) <= 2
+		mu.Unlock()
+		if first {
+			// 第一个请求前两次失败，重试期间之后的刷新已经排队
+			time.Sleep(20 * time.Millisecond)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	handler := NewWebhookHandler("webhook-1", server.URL, log.New(io.Discard, "", 0))
+	handler.retryInterval = time.Millisecond
+	for i := 0; i < 3; i++ {
+		if err := handler.Handle(context.Background(), &Event{ID: "e"}); err != nil {
+			t.Fatal(err)
+		}
+		handler.bufferMu.Lock()
+		handler.flushEvents(context.Background())
+		handler.bufferMu.Unlock()
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"1", "1", "1", "2", "3"}; !reflect.DeepEqual(sequences, want) {
+		t.Errorf("expected requests %v in order, got %v", want, sequences)
+	}
+	if stats := handler.GetStats(); stats["failed_events"] != int64(0) {
+		t.Errorf("unexpected failed events %v", stats["failed_events"])
+	}
+}
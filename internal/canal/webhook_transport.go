@@ -0,0 +1,326 @@
+package canal
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// webhookTransportSettings 解析后的端点连接配置
+type webhookTransportSettings struct {
+	maxIdleConnsPerHost int
+	maxConnsPerHost     int
+	idleConnTimeout     time.Duration
+	keepAlive           time.Duration
+	disableKeepAlives   bool
+	disableHTTP2        bool
+	dnsCacheTTL         time.Duration
+}
+
+// parseWebhookEndpointConfig 解析端点配置，空字符串的时长使用 base 中的值
+func parseWebhookEndpointConfig(cfg config.WebhookEndpointConfig, base webhookTransportSettings) (webhookTransportSettings, error) {
+	s := base
+	if cfg.MaxIdleConnsPerHost < 0 || cfg.MaxConnsPerHost < 0 {
+		return s, fmt.Errorf("connection limits must not be negative")
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		s.maxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		s.maxConnsPerHost = cfg.MaxConnsPerHost
+	}
+	s.disableKeepAlives = s.disableKeepAlives || cfg.DisableKeepAlives
+	s.disableHTTP2 = s.disableHTTP2 || cfg.DisableHTTP2
+
+	durations := []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"idle_conn_timeout", cfg.IdleConnTimeout, &s.idleConnTimeout},
+		{"keep_alive", cfg.KeepAlive, &s.keepAlive},
+		{"dns_cache_ttl", cfg.DNSCacheTTL, &s.dnsCacheTTL},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed < 0 {
+			return s, fmt.Errorf("invalid %s %q", d.name, d.value)
+		}
+		*d.dst = parsed
+	}
+	return s, nil
+}
+
+// WebhookEndpointStats 一个端点的连接统计
+type WebhookEndpointStats struct {
+	Endpoint    string `json:"endpoint"`
+	Requests    int64  `json:"requests"`
+	NewConns    int64  `json:"new_conns"`    // 新建的连接数
+	ReusedConns int64  `json:"reused_conns"` // 复用空闲连接的请求数
+	OpenConns   int64  `json:"open_conns"`   // 当前打开的连接数
+	HTTP2       int64  `json:"http2"`        // 使用 HTTP/2 的请求数
+	DNSHits     int64  `json:"dns_hits"`     // 命中域名解析缓存的次数
+	DNSLookups  int64  `json:"dns_lookups"`  // 实际解析次数
+}
+
+// webhookEndpoint 一个端点共享的连接池和统计
+type webhookEndpoint struct {
+	name      string
+	transport *http.Transport
+	dns       *dnsCache
+
+	requests, newConns, reusedConns, openConns, http2 int64
+}
+
+// WebhookTransports 按端点（scheme://host）共享的 Webhook 连接池
+// 默认的 http.Transport 每个主机只保留 2 个空闲连接，高频投递到同一主机时会反复建连
+type WebhookTransports struct {
+	defaults  webhookTransportSettings
+	overrides map[string]webhookTransportSettings
+
+	mu        sync.Mutex
+	endpoints map[string]*webhookEndpoint
+}
+
+// NewWebhookTransports 根据配置创建连接池
+func NewWebhookTransports(cfg config.WebhookTransportConfig) (*WebhookTransports, error) {
+	builtin := webhookTransportSettings{
+		maxIdleConnsPerHost: 32,
+		idleConnTimeout:     90 * time.Second,
+		keepAlive:           30 * time.Second,
+		dnsCacheTTL:         time.Minute,
+	}
+	defaults, err := parseWebhookEndpointConfig(cfg.WebhookEndpointConfig, builtin)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook transport config: %v", err)
+	}
+
+	overrides := make(map[string]webhookTransportSettings, len(cfg.Endpoints))
+	for host, endpointCfg := range cfg.Endpoints {
+		settings, err := parseWebhookEndpointConfig(endpointCfg, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook transport config for %s: %v", host, err)
+		}
+		overrides[host] = settings
+	}
+
+	return &WebhookTransports{
+		defaults:  defaults,
+		overrides: overrides,
+		endpoints: make(map[string]*webhookEndpoint),
+	}, nil
+}
+
+// RoundTripper 回调地址所在端点的连接池，同一端点的处理器共享连接
+func (t *WebhookTransports) RoundTripper(callbackURL string) http.RoundTripper {
+	u, err := url.Parse(callbackURL)
+	if err != nil || u.Host == "" {
+		// 无效地址交给默认连接池报错
+		return http.DefaultTransport
+	}
+	name := u.Scheme + "://" + u.Host
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if endpoint, ok := t.endpoints[name]; ok {
+		return endpoint
+	}
+
+	settings := t.defaults
+	if s, ok := t.overrides[u.Host]; ok {
+		settings = s
+	} else if s, ok := t.overrides[u.Hostname()]; ok {
+		settings = s
+	}
+	endpoint := newWebhookEndpoint(name, settings)
+	t.endpoints[name] = endpoint
+	return endpoint
+}
+
+// Stats 各端点的连接统计，按端点排序
+func (t *WebhookTransports) Stats() []WebhookEndpointStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make([]WebhookEndpointStats, 0, len(t.endpoints))
+	for _, endpoint := range t.endpoints {
+		stats = append(stats, endpoint.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Endpoint < stats[j].Endpoint })
+	return stats
+}
+
+// Close 关闭所有空闲连接
+func (t *WebhookTransports) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, endpoint := range t.endpoints {
+		endpoint.transport.CloseIdleConnections()
+	}
+}
+
+// newWebhookEndpoint 创建端点的连接池，拨号时经过域名解析缓存并统计打开的连接
+func newWebhookEndpoint(name string, s webhookTransportSettings) *webhookEndpoint {
+	e := &webhookEndpoint{name: name, dns: newDNSCache(s.dnsCacheTTL)}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: s.keepAlive}
+
+	e.transport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := e.dns.dial(ctx, dialer, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			atomic.AddInt64(&e.newConns, 1)
+			atomic.AddInt64(&e.openConns, 1)
+			return &countedConn{Conn: conn, open: &e.openConns}, nil
+		},
+		MaxIdleConns:          0, // 总数不限制，按端点限制
+		MaxIdleConnsPerHost:   s.maxIdleConnsPerHost,
+		MaxConnsPerHost:       s.maxConnsPerHost,
+		IdleConnTimeout:       s.idleConnTimeout,
+		DisableKeepAlives:     s.disableKeepAlives,
+		ForceAttemptHTTP2:     !s.disableHTTP2,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	if s.disableHTTP2 {
+		// 非空的 TLSNextProto 关闭 HTTP/2 协商
+		e.transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return e
+}
+
+// RoundTrip 发送请求并统计连接复用
+func (e *webhookEndpoint) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&e.requests, 1)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&e.reusedConns, 1)
+			}
+		},
+	}
+	resp, err := e.transport.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err == nil && resp.ProtoMajor == 2 {
+		atomic.AddInt64(&e.http2, 1)
+	}
+	return resp, err
+}
+
+// stats 端点的连接统计
+func (e *webhookEndpoint) stats() WebhookEndpointStats {
+	hits, lookups := e.dns.stats()
+	return WebhookEndpointStats{
+		Endpoint:    e.name,
+		Requests:    atomic.LoadInt64(&e.requests),
+		NewConns:    atomic.LoadInt64(&e.newConns),
+		ReusedConns: atomic.LoadInt64(&e.reusedConns),
+		OpenConns:   atomic.LoadInt64(&e.openConns),
+		HTTP2:       atomic.LoadInt64(&e.http2),
+		DNSHits:     hits,
+		DNSLookups:  lookups,
+	}
+}
+
+// countedConn 关闭时减少打开连接计数
+type countedConn struct {
+	net.Conn
+	open      *int64
+	closeOnce sync.Once
+}
+
+// Close 关闭连接
+func (c *countedConn) Close() error {
+	c.closeOnce.Do(func() { atomic.AddInt64(c.open, -1) })
+	return c.Conn.Close()
+}
+
+// dnsCache 缓存域名解析结果，ttl 为 0 时不缓存
+type dnsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+	hits    int64
+	lookups int64
+}
+
+// dnsEntry 一个域名的解析结果
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// newDNSCache 创建域名解析缓存
+func newDNSCache(ttl time.Duration) *dnsCache {
+	return &dnsCache{ttl: ttl, entries: make(map[string]dnsEntry)}
+}
+
+// lookup 解析域名，优先使用未过期的缓存
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	if entry, ok := c.entries[host]; ok && time.Now().Before(entry.expires) {
+		c.hits++
+		c.mu.Unlock()
+		return entry.addrs, nil
+	}
+	c.lookups++
+	c.mu.Unlock()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// dial 依次尝试缓存的地址，IP 地址和未开启缓存时直接拨号
+func (c *dnsCache) dial(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || c.ttl <= 0 || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	addrs, err := c.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	lastErr := fmt.Errorf("no addresses for %s", host)
+	for _, ip := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	// 缓存的地址都不可用时下次重新解析
+	c.mu.Lock()
+	delete(c.entries, host)
+	c.mu.Unlock()
+	return nil, lastErr
+}
+
+// stats 缓存命中和实际解析次数
+func (c *dnsCache) stats() (hits, lookups int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.lookups
+}
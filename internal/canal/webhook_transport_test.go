@@ -0,0 +1,102 @@
+package canal
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"pikachun/internal/config"
+)
+
+// TestWebhookTransportsReuseConnections 测试同一端点的处理器共享并复用连接
+func TestWebhookTransportsReuseConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	transports, err := NewWebhookTransports(config.WebhookTransportConfig{})
+	if err != nil {
+		t.Fatalf("NewWebhookTransports failed: %v", err)
+	}
+	defer transports.Close()
+
+	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
+	events := []*Event{{ID: "e1", Schema: "shop", Table: "orders"}}
+	for _, name := range []string{"webhook-1", "webhook-2"} {
+		handler := NewWebhookHandler(name, server.URL+"/"+name, logger)
+		handler.SetTransports(transports)
+		for i := 0; i < 2; i++ {
+			if _, err := handler.Deliver(context.Background(), events, 1); err != nil {
+				t.Fatalf("Deliver failed: %v", err)
+			}
+		}
+	}
+
+	stats := transports.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("expected one endpoint, got %+v", stats)
+	}
+	got := stats[0]
+	if got.Endpoint != server.URL || got.Requests != 4 || got.NewConns != 1 || got.ReusedConns != 3 || got.OpenConns != 1 {
+		t.Errorf("unexpected stats %+v", got)
+	}
+}
+
+// TestWebhookTransportsEndpointOverride 测试按端点覆盖配置
+func TestWebhookTransportsEndpointOverride(t *testing.T) {
+	cfg := config.WebhookTransportConfig{
+		WebhookEndpointConfig: config.WebhookEndpointConfig{MaxIdleConnsPerHost: 8, IdleConnTimeout: "30s"},
+		Endpoints: map[string]config.WebhookEndpointConfig{
+			"hooks.example.com": {MaxIdleConnsPerHost: 64, DisableHTTP2: true},
+		},
+	}
+	transports, err := NewWebhookTransports(cfg)
+	if err != nil {
+		t.Fatalf("NewWebhookTransports failed: %v", err)
+	}
+
+	other := transports.RoundTripper("https://other.example.com/hook").(*webhookEndpoint).transport
+	if other.MaxIdleConnsPerHost != 8 || !other.ForceAttemptHTTP2 {
+		t.Errorf("unexpected default transport: idle %d, http2 %v", other.MaxIdleConnsPerHost, other.ForceAttemptHTTP2)
+	}
+	hooks := transports.RoundTripper("https://hooks.example.com/a").(*webhookEndpoint).transport
+	if hooks.MaxIdleConnsPerHost != 64 || hooks.ForceAttemptHTTP2 || hooks.IdleConnTimeout.String() != "30s" {
+		t.Errorf("unexpected endpoint transport: idle %d, http2 %v, timeout %v", hooks.MaxIdleConnsPerHost, hooks.ForceAttemptHTTP2, hooks.IdleConnTimeout)
+	}
+	if transports.RoundTripper("https://hooks.example.com/b").(*webhookEndpoint).transport != hooks {
+		t.Error("expected handlers of the same endpoint to share a transport")
+	}
+
+	bad := config.WebhookTransportConfig{WebhookEndpointConfig: config.WebhookEndpointConfig{DNSCacheTTL: "soon"}}
+	if _, err := NewWebhookTransports(bad); err == nil {
+		t.Error("expected error for invalid dns_cache_ttl")
+	}
+}
+
+// TestDNSCache 测试域名解析结果在有效期内复用
+func TestDNSCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	transports, _ := NewWebhookTransports(config.WebhookTransportConfig{
+		WebhookEndpointConfig: config.WebhookEndpointConfig{DisableKeepAlives: true},
+	})
+	defer transports.Close()
+	client := &http.Client{Transport: transports.RoundTripper("http://localhost:" + u.Port())}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get("http://localhost:" + u.Port())
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	stats := transports.Stats()[0]
+	if stats.NewConns != 3 || stats.DNSLookups != 1 || stats.DNSHits != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
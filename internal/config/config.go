@@ -6,22 +6,59 @@ import (
 
 // Config 应用配置结构
 type Config struct {
-	Server         ServerConfig          `mapstructure:"server"`
-	Database       DatabaseConfig        `mapstructure:"database"`
-	Canal          CanalConfig           `mapstructure:"canal"`
-	Log            LogConfig             `mapstructure:"log"`
+	Server          ServerConfig          `mapstructure:"server"`
+	Database        DatabaseConfig        `mapstructure:"database"`
+	Canal           CanalConfig           `mapstructure:"canal"`
+	Log             LogConfig             `mapstructure:"log"`
 	DatabaseStorage DatabaseStorageConfig `mapstructure:"database_storage"`
+	FailurePolicy   FailurePolicyConfig   `mapstructure:"failure_policy"`
+	TaskDeletion    TaskDeletionConfig    `mapstructure:"task_deletion"`
+	Notify          NotifyConfig          `mapstructure:"notify"`
+	Alerting        AlertingConfig        `mapstructure:"alerting"`
+	ClickHouse      ClickHouseConfig      `mapstructure:"clickhouse"`
+	ObjectStore     ObjectStoreConfig     `mapstructure:"object_store"`
+	Archive         ArchiveConfig         `mapstructure:"archive"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port string `mapstructure:"port"`
-	Host string `mapstructure:"host"`
+	Port        string       `mapstructure:"port"`
+	Host        string       `mapstructure:"host"`
+	MaxBodySize int64        `mapstructure:"max_body_size"` // 请求体最大字节数
+	Gzip        bool         `mapstructure:"gzip"`          // 是否压缩响应
+	CORS        CORSConfig   `mapstructure:"cors"`
+	AdminToken  string       `mapstructure:"admin_token"` // 管理员令牌，为空时不开放 /debug 调试接口
+	Language    string       `mapstructure:"language"`    // 默认语言，请求未指定语言时使用
+	LocalesDir  string       `mapstructure:"locales_dir"` // 额外的消息目录，<语言>.json 覆盖或补充内置翻译
+	Users       []UserConfig `mapstructure:"users"`       // Web 界面和 API 的登录用户，为空时不需要登录
+	SessionTTL  string       `mapstructure:"session_ttl"` // 登录会话的空闲过期时间
+}
+
+// UserConfig 登录用户
+type UserConfig struct {
+	Username     string `mapstructure:"username"`
+	PasswordHash string `mapstructure:"password_hash"` // bcrypt 哈希，可用 pikachun hash-password 生成
+	Role         string `mapstructure:"role"`          // admin、operator 或 viewer
+}
+
+// CORSConfig 跨域配置，AllowedOrigins 为空时不启用
+type CORSConfig struct {
+	AllowedOrigins   []string `mapstructure:"allowed_origins"` // 允许的来源，* 表示全部
+	AllowedMethods   []string `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string `mapstructure:"allowed_headers"`
+	AllowCredentials bool     `mapstructure:"allow_credentials"`
+	MaxAge           string   `mapstructure:"max_age"` // 预检结果缓存时间
 }
 
 // DatabaseConfig 数据库配置
 type DatabaseConfig struct {
-	DSN string `mapstructure:"dsn"`
+	DSN             string `mapstructure:"dsn"`
+	JournalMode     string `mapstructure:"journal_mode"`      // SQLite 日志模式，WAL 允许读写并发
+	Synchronous     string `mapstructure:"synchronous"`       // SQLite 同步级别
+	BusyTimeout     string `mapstructure:"busy_timeout"`      // 数据库被锁定时的等待时间
+	MaxOpenConns    int    `mapstructure:"max_open_conns"`    // 最大打开连接数
+	MaxIdleConns    int    `mapstructure:"max_idle_conns"`    // 最大空闲连接数
+	ConnMaxLifetime string `mapstructure:"conn_max_lifetime"` // 连接最长存活时间
 }
 
 // CanalConfig Canal配置
@@ -44,6 +81,172 @@ type CanalConfig struct {
 
 	// 性能配置
 	Performance PerformanceConfig `mapstructure:"performance"`
+
+	// 心跳配置
+	Heartbeat HeartbeatConfig `mapstructure:"heartbeat"`
+
+	// 事件ID格式: position（基于 binlog 位置，确定性）、ulid、uuidv7
+	EventIDFormat string `mapstructure:"event_id_format"`
+
+	// 读取限速
+	Throttle ThrottleConfig `mapstructure:"throttle"`
+
+	// 大字段处理
+	LargeValues LargeValueConfig `mapstructure:"large_values"`
+
+	// 原始 SQL 捕获
+	RowsQuery RowsQueryConfig `mapstructure:"rows_query"`
+
+	// 实例缓冲区内存限制
+	Memory MemoryConfig `mapstructure:"memory"`
+
+	// 实例事件队列容量和溢出处理
+	EventBuffer EventBufferConfig `mapstructure:"event_buffer"`
+
+	// 各 sink（Webhook、事件日志等处理器）的独立队列
+	Sinks SinksConfig `mapstructure:"sinks"`
+
+	// 持久化位置漂移检查间隔，"0" 表示不检查
+	PositionCheckInterval string `mapstructure:"position_check_interval"`
+
+	// 位置保存时机: event（每个事件之后）、transaction（只在事务提交之后）
+	Checkpoint string `mapstructure:"checkpoint"`
+
+	// 停滞检测与自动重启
+	Watchdog WatchdogConfig `mapstructure:"watchdog"`
+
+	// 源库连接的心跳、读超时、TCP keepalive 和连接超时
+	Connection SourceConnectionConfig `mapstructure:"connection"`
+
+	// 经 SOCKS5/HTTP 代理或 SSH 跳板机连接源库，复制连接和元数据查询都经过隧道
+	Tunnel SourceTunnelConfig `mapstructure:"tunnel"`
+
+	// Webhook 请求中的 64 位整数和定点小数编码为 JSON 字符串，避免 JavaScript 消费端丢失精度，任务可单独设置
+	NumericStrings bool `mapstructure:"numeric_strings"`
+
+	// 所有任务共享的 Webhook 投递并发上限，并发已满时按任务优先级加权分配，0 表示不限制
+	MaxDeliveryConcurrency int `mapstructure:"max_delivery_concurrency"`
+
+	// 准入控制：任务数、实例数和复制连接数的上限，达到上限时拒绝创建或启动
+	Limits AdmissionLimits `mapstructure:"limits"`
+
+	// Webhook 客户端的连接池，按端点（scheme://host）共享
+	WebhookTransport WebhookTransportConfig `mapstructure:"webhook_transport"`
+
+	// 创建任务前检查源库的复制权限、binlog 配置和 server_id，未通过时拒绝创建
+	Preflight bool `mapstructure:"preflight"`
+
+	// 源库 binlog 的最短保留时间，短于该值时预检和实例启动给出警告
+	MinBinlogRetention string `mapstructure:"min_binlog_retention"`
+
+	// 创建任务前检查 Webhook 端点是否可达，不可达时拒绝创建
+	SinkCheck bool `mapstructure:"sink_check"`
+
+	// 从本地 binlog 文件回填时允许读取的目录，回填接口只能读取该目录下的文件，为空时不允许回填
+	BackfillDir string `mapstructure:"backfill_dir"`
+}
+
+// AdmissionLimits 准入控制的软上限，防止失控的脚本创建大量任务耗尽源库的复制连接数，0 表示不限制
+type AdmissionLimits struct {
+	MaxTasksPerSource         int `mapstructure:"max_tasks_per_source"`        // 每个源库的任务数，不含已删除的任务
+	MaxInstances              int `mapstructure:"max_instances"`               // 同时运行的 Canal 实例数
+	MaxReplicationConnections int `mapstructure:"max_replication_connections"` // 到源库的复制（binlog dump）连接数
+}
+
+// WebhookTransportConfig Webhook 客户端的连接池配置
+type WebhookTransportConfig struct {
+	WebhookEndpointConfig `mapstructure:",squash"`
+
+	// 按端点覆盖，键为 host 或 host:port，非零的项覆盖默认值
+	Endpoints map[string]WebhookEndpointConfig `mapstructure:"endpoints"`
+}
+
+// WebhookEndpointConfig 单个端点的连接配置，0 或空表示使用默认值
+type WebhookEndpointConfig struct {
+	MaxIdleConnsPerHost int    `mapstructure:"max_idle_conns_per_host"` // 每个端点保留的空闲连接数
+	MaxConnsPerHost     int    `mapstructure:"max_conns_per_host"`      // 每个端点的最大连接数，0 表示不限制
+	IdleConnTimeout     string `mapstructure:"idle_conn_timeout"`       // 空闲连接保留时间
+	KeepAlive           string `mapstructure:"keep_alive"`              // TCP keep-alive 探测间隔
+	DisableKeepAlives   bool   `mapstructure:"disable_keep_alives"`     // 每个请求使用新连接
+	DisableHTTP2        bool   `mapstructure:"disable_http2"`           // HTTPS 端点不协商 HTTP/2
+	DNSCacheTTL         string `mapstructure:"dns_cache_ttl"`           // 域名解析结果缓存时间，"0" 表示不缓存
+}
+
+// WatchdogConfig 实例停滞检测：源库有新 binlog 而实例长时间没有进展时重启 binlog 流
+type WatchdogConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	StallTimeout  string `mapstructure:"stall_timeout"`  // 没有进展多久视为停滞
+	MaxRestarts   int    `mapstructure:"max_restarts"`   // 窗口内最多自动重启次数
+	RestartWindow string `mapstructure:"restart_window"` // 重启次数统计窗口
+	Jitter        string `mapstructure:"jitter"`         // 重启前随机等待的上限，避免多个实例同时重连
+}
+
+// SourceConnectionConfig 源库连接配置，部分托管 MySQL 的代理会更快断开空闲的复制连接，需要调小心跳间隔
+type SourceConnectionConfig struct {
+	HeartbeatPeriod string `mapstructure:"heartbeat_period"` // 源库在没有新 binlog 时发送心跳事件的间隔，需小于 read_timeout
+	ReadTimeout     string `mapstructure:"read_timeout"`     // binlog 流多久没有收到数据视为断开并重连
+	KeepAlive       string `mapstructure:"keepalive"`        // TCP keepalive 探测间隔，"0" 表示使用系统默认值
+	DialTimeout     string `mapstructure:"dial_timeout"`     // 建立连接的超时
+}
+
+// SourceTunnelConfig 到源库的隧道，type 为空时直连
+type SourceTunnelConfig struct {
+	Type     string `mapstructure:"type"`     // socks5、http 或 ssh
+	Address  string `mapstructure:"address"`  // 代理或跳板机地址 host:port
+	Username string `mapstructure:"username"` // 代理认证用户名或 SSH 用户名
+	Password string `mapstructure:"password"` // 代理认证密码或 SSH 密码
+
+	PrivateKeyFile        string `mapstructure:"private_key_file"`         // SSH 私钥文件
+	PrivateKeyPassphrase  string `mapstructure:"private_key_passphrase"`   // SSH 私钥口令
+	KnownHostsFile        string `mapstructure:"known_hosts_file"`         // 校验跳板机公钥的 known_hosts 文件
+	InsecureIgnoreHostKey bool   `mapstructure:"insecure_ignore_host_key"` // 不校验跳板机公钥，仅用于测试
+}
+
+// EventBufferConfig 实例事件队列：binlog 读取的事件进入队列，由处理协程分发给处理器
+type EventBufferConfig struct {
+	Size        int         `mapstructure:"size"`         // 队列容量
+	MaxSize     int         `mapstructure:"max_size"`     // 大于 size 时按填充率在 size 和 max_size 之间自动调整容量
+	SendTimeout string      `mapstructure:"send_timeout"` // 队列满时的等待时间
+	Overflow    string      `mapstructure:"overflow"`     // 等待超时后的处理: block（继续等待）、drop（丢弃事件并计数）
+	Spill       SpillConfig `mapstructure:"spill"`
+}
+
+// SinksConfig 每个 sink 有自己的队列，按 binlog 顺序处理，慢的 sink 只积压自己的队列
+type SinksConfig struct {
+	QueueSize int `mapstructure:"queue_size"` // 每个 sink 队列的容量，满时 binlog 读取等待该 sink
+}
+
+// SpillConfig 事件队列满时把事件写入磁盘临时队列，下游赶上后按顺序读回处理，binlog 读取不必等待
+type SpillConfig struct {
+	Enabled   bool    `mapstructure:"enabled"`
+	Dir       string  `mapstructure:"dir"`         // 临时文件目录
+	MaxSizeMB float64 `mapstructure:"max_size_mb"` // 每个实例的磁盘配额，用完后按 overflow 处理
+}
+
+// MemoryConfig 每个实例缓冲事件的内存限制，0 表示不限制
+type MemoryConfig struct {
+	SoftLimitMB    float64 `mapstructure:"soft_limit_mb"`   // 超过后放慢 binlog 读取
+	HardLimitMB    float64 `mapstructure:"hard_limit_mb"`   // 超过后暂停读取，回落到软限制以下后恢复
+	SampleInterval string  `mapstructure:"sample_interval"` // 进程内存采样间隔
+}
+
+// LargeValueConfig 大字段处理配置
+type LargeValueConfig struct {
+	Policy  string `mapstructure:"policy"`   // none、truncate（截断并标记）、drop（丢弃值保留元数据）、externalize（写入对象存储并替换为引用地址）
+	MaxSize int    `mapstructure:"max_size"` // 超过该字节数的 BLOB/TEXT 值视为大字段
+}
+
+// RowsQueryConfig 将 ROWS_QUERY 事件中的原始语句附加到行事件的 sql 字段
+// 需要源库开启 binlog_rows_query_log_events，语句可能很长，默认关闭
+type RowsQueryConfig struct {
+	Enabled   bool `mapstructure:"enabled"`
+	MaxLength int  `mapstructure:"max_length"` // 超过该字节数的语句被截断，0 表示不截断
+}
+
+// ThrottleConfig binlog 读取限速配置，所有任务共享，0 表示不限制
+type ThrottleConfig struct {
+	MaxEventsPerSecond int     `mapstructure:"max_events_per_second"`
+	MaxMBPerSecond     float64 `mapstructure:"max_mb_per_second"`
 }
 
 // BinlogConfig binlog 配置
@@ -51,13 +254,24 @@ type BinlogConfig struct {
 	Filename    string `mapstructure:"filename"`
 	Position    uint32 `mapstructure:"position"`
 	GTIDEnabled bool   `mapstructure:"gtid_enabled"`
+
+	// 事件校验和
+	Checksum BinlogChecksumConfig `mapstructure:"checksum"`
+}
+
+// BinlogChecksumConfig 源库 binlog 事件校验和的处理
+type BinlogChecksumConfig struct {
+	Verify     bool   `mapstructure:"verify"`      // 校验每个事件的 CRC32
+	Algorithm  string `mapstructure:"algorithm"`   // auto（按源库 binlog_checksum）或 crc32（要求源库使用 CRC32）
+	OnMismatch string `mapstructure:"on_mismatch"` // 校验失败时: fail（停在损坏事件前重试）或 skip（跳过损坏事件并记录审计）
 }
 
 // WatchConfig 监听配置
 type WatchConfig struct {
-	Databases  []string `mapstructure:"databases"`
-	Tables     []string `mapstructure:"tables"`
-	EventTypes []string `mapstructure:"event_types"`
+	Databases     []string `mapstructure:"databases"`
+	Tables        []string `mapstructure:"tables"`
+	EventTypes    []string `mapstructure:"event_types"`
+	ExcludeTables []string `mapstructure:"exclude_tables"` // 排除规则，支持通配符，如 *_tmp、testdb.heartbeat
 }
 
 // ReconnectConfig 重连配置
@@ -72,6 +286,14 @@ type PerformanceConfig struct {
 	BatchSize       int `mapstructure:"batch_size"`
 }
 
+// HeartbeatConfig 心跳配置
+type HeartbeatConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Database string `mapstructure:"database"`
+	Table    string `mapstructure:"table"`
+	Interval string `mapstructure:"interval"`
+}
+
 // LogConfig 日志配置
 type LogConfig struct {
 	Level      string `mapstructure:"level"`
@@ -80,11 +302,118 @@ type LogConfig struct {
 	MaxSize    int    `mapstructure:"max_size"`
 	MaxAge     int    `mapstructure:"max_age"`
 	MaxBackups int    `mapstructure:"max_backups"`
+
+	Sampling LogSamplingConfig `mapstructure:"sampling"`
+}
+
+// LogSamplingConfig 热点路径日志采样配置，每个事件都会在读取、发送、分发、处理、刷新路径上输出多行日志，
+// 启用后每条路径在每个时间窗口内只输出前 initial 条，之后每 thereafter 条输出一条（为 0 时全部丢弃）。
+// 错误和警告不采样
+type LogSamplingConfig struct {
+	Enabled    bool                       `mapstructure:"enabled"`
+	Interval   string                     `mapstructure:"interval"` // 时间窗口，默认 1s
+	Initial    int                        `mapstructure:"initial"`
+	Thereafter int                        `mapstructure:"thereafter"`
+	Paths      map[string]LogPathSampling `mapstructure:"paths"` // 按路径覆盖默认值：read、send、dispatch、handle、flush
+}
+
+// LogPathSampling 单条路径的采样
+type LogPathSampling struct {
+	Initial    int `mapstructure:"initial"`
+	Thereafter int `mapstructure:"thereafter"`
 }
 
 // DatabaseStorageConfig 数据库存储配置
 type DatabaseStorageConfig struct {
-	Enabled bool `mapstructure:"enabled"`
+	Enabled       bool   `mapstructure:"enabled"`
+	BatchSize     int    `mapstructure:"batch_size"`     // 批量写入条数
+	FlushInterval string `mapstructure:"flush_interval"` // 最长缓冲时间
+	QueueSize     int    `mapstructure:"queue_size"`     // 写入队列长度，队列满时阻塞事件处理
+	// 请求序号记录（delivery_cursors）的保留时长，为 0 时不清理
+	CursorRetention string `mapstructure:"cursor_retention"`
+}
+
+// ClickHouseConfig ClickHouse 分析库同步配置
+type ClickHouseConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	URL           string `mapstructure:"url"`      // HTTP 接口地址，如 http://localhost:8123
+	Database      string `mapstructure:"database"` // 目标库，为空时与源库同名
+	Username      string `mapstructure:"username"`
+	Password      string `mapstructure:"password"`
+	BatchSize     int    `mapstructure:"batch_size"`
+	FlushInterval string `mapstructure:"flush_interval"`
+	MaxBufferRows int    `mapstructure:"max_buffer_rows"` // 缓冲行数上限，ClickHouse 不可用时达到上限后处理等待
+}
+
+// ObjectStoreConfig 对象存储配置
+type ObjectStoreConfig struct {
+	Type      string `mapstructure:"type"`      // local 或 s3（兼容 S3 协议的存储，如 GCS 互操作模式、MinIO）
+	LocalDir  string `mapstructure:"local_dir"` // local 存储目录
+	BaseURL   string `mapstructure:"base_url"`  // 对外访问地址前缀，为空时使用对象的存储地址
+	Endpoint  string `mapstructure:"endpoint"`  // S3 服务地址，为空时使用 AWS 区域地址
+	Region    string `mapstructure:"region"`    // GCS 使用 auto
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	PathStyle bool   `mapstructure:"path_style"` // 使用 endpoint/bucket/key 形式的地址，MinIO 等需要开启
+}
+
+// ArchiveConfig 对象存储归档配置，写入 object_store 配置的存储
+type ArchiveConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Format      string `mapstructure:"format"`        // ndjson 或 parquet
+	Prefix      string `mapstructure:"prefix"`        // 对象 key 前缀
+	MaxFileSize int    `mapstructure:"max_file_size"` // 单个文件达到该字节数时轮转
+	MaxFileAge  string `mapstructure:"max_file_age"`  // 单个文件最长缓冲时间
+	// 缓冲和等待上传的文件总字节数上限，达到后新事件等待上传完成；小于 max_file_size 时取其 4 倍
+	MaxBufferSize int64 `mapstructure:"max_buffer_size"`
+}
+
+// FailurePolicyConfig 持续失败自动停用策略配置
+type FailurePolicyConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	MaxDuration string `mapstructure:"max_duration"` // 连续失败超过该时长后将任务标记为 failed
+}
+
+// TaskDeletionConfig 任务删除配置：删除的任务先保留一段时间，期间 binlog 位置和失败事件都不清除，可以恢复
+type TaskDeletionConfig struct {
+	RetentionDays int `mapstructure:"retention_days"` // 保留天数，到期后彻底清除，0 表示删除时立即清除
+}
+
+// NotifyConfig 运维通知配置
+type NotifyConfig struct {
+	WebhookURL          string      `mapstructure:"webhook_url"`
+	SlackWebhookURL     string      `mapstructure:"slack_webhook_url"`
+	PagerDutyRoutingKey string      `mapstructure:"pagerduty_routing_key"`
+	Email               EmailConfig `mapstructure:"email"`
+}
+
+// AlertingConfig 告警配置
+type AlertingConfig struct {
+	RateLimit          string            `mapstructure:"rate_limit"`           // 同一告警的最小发送间隔
+	LagThreshold       string            `mapstructure:"lag_threshold"`        // 同步延迟告警阈值，空表示不检查
+	DLQGrowthThreshold int               `mapstructure:"dlq_growth_threshold"` // 每个检查周期新增失败事件数阈值，0 表示不检查
+	Templates          map[string]string `mapstructure:"templates"`            // 按告警类型覆盖正文模板
+	SLO                SLOConfig         `mapstructure:"slo"`
+}
+
+// SLOConfig 任务 SLO 统计，通过 /metrics 导出达标率、剩余错误预算和 burn rate
+type SLOConfig struct {
+	Window             string  `mapstructure:"window"`              // 统计窗口，达标率和剩余错误预算按该窗口计算
+	FreshnessThreshold string  `mapstructure:"freshness_threshold"` // 复制延迟不超过该值视为达标
+	AvailabilityTarget float64 `mapstructure:"availability_target"` // 可用性目标：实例运行且没有告警的时间占比
+	FreshnessTarget    float64 `mapstructure:"freshness_target"`    // 延迟目标：延迟达标的时间占比
+	DeliveryTarget     float64 `mapstructure:"delivery_target"`     // 投递目标：投递成功的事件占比
+}
+
+// EmailConfig 邮件通知配置
+type EmailConfig struct {
+	SMTPHost string   `mapstructure:"smtp_host"`
+	SMTPPort int      `mapstructure:"smtp_port"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
 }
 
 // Load 加载配置
@@ -120,7 +449,24 @@ func Load() (*Config, error) {
 func setDefaults() {
 	viper.SetDefault("server.host", "0.0.0.0")
 	viper.SetDefault("server.port", "8668")
+	viper.SetDefault("server.max_body_size", 1<<20)
+	viper.SetDefault("server.gzip", true)
+	viper.SetDefault("server.cors.allowed_origins", []string{})
+	viper.SetDefault("server.cors.allowed_methods", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
+	viper.SetDefault("server.cors.allowed_headers", []string{"Content-Type", "Authorization", "X-Request-ID", "Idempotency-Key"})
+	viper.SetDefault("server.cors.allow_credentials", false)
+	viper.SetDefault("server.cors.max_age", "12h")
+	viper.SetDefault("server.admin_token", "")
+	viper.SetDefault("server.language", "zh")
+	viper.SetDefault("server.locales_dir", "")
+	viper.SetDefault("server.session_ttl", "12h")
 	viper.SetDefault("database.dsn", "./data/pikachun.db")
+	viper.SetDefault("database.journal_mode", "WAL")
+	viper.SetDefault("database.synchronous", "NORMAL")
+	viper.SetDefault("database.busy_timeout", "5s")
+	viper.SetDefault("database.max_open_conns", 4)
+	viper.SetDefault("database.max_idle_conns", 2)
+	viper.SetDefault("database.conn_max_lifetime", "1h")
 	viper.SetDefault("canal.host", "127.0.0.1")
 	viper.SetDefault("canal.port", 3307)
 	viper.SetDefault("canal.username", "root")
@@ -132,11 +478,15 @@ func setDefaults() {
 	viper.SetDefault("canal.binlog.filename", "")
 	viper.SetDefault("canal.binlog.position", 4)
 	viper.SetDefault("canal.binlog.gtid_enabled", true)
+	viper.SetDefault("canal.binlog.checksum.verify", true)
+	viper.SetDefault("canal.binlog.checksum.algorithm", "auto")
+	viper.SetDefault("canal.binlog.checksum.on_mismatch", "fail")
 
 	// 监听默认配置
 	viper.SetDefault("canal.watch.databases", []string{})
 	viper.SetDefault("canal.watch.tables", []string{})
 	viper.SetDefault("canal.watch.event_types", []string{"INSERT", "UPDATE", "DELETE"})
+	viper.SetDefault("canal.watch.exclude_tables", []string{})
 
 	// 重连默认配置
 	viper.SetDefault("canal.reconnect.max_attempts", 10)
@@ -146,13 +496,118 @@ func setDefaults() {
 	viper.SetDefault("canal.performance.event_buffer_size", 1000)
 	viper.SetDefault("canal.performance.batch_size", 100)
 
+	// 心跳默认配置
+	viper.SetDefault("canal.heartbeat.enabled", false)
+	viper.SetDefault("canal.heartbeat.database", "pikachun")
+	viper.SetDefault("canal.heartbeat.table", "pikachun_heartbeat")
+	viper.SetDefault("canal.heartbeat.interval", "10s")
+
 	viper.SetDefault("log.level", "info")
 	viper.SetDefault("log.file", "./logs/pikachun.log")
 	viper.SetDefault("log.format", "text")
 	viper.SetDefault("log.max_size", 100)
 	viper.SetDefault("log.max_age", 30)
 	viper.SetDefault("log.max_backups", 10)
+	viper.SetDefault("log.sampling.enabled", false)
+	viper.SetDefault("log.sampling.interval", "1s")
+	viper.SetDefault("log.sampling.initial", 10)
+	viper.SetDefault("log.sampling.thereafter", 100)
 
 	// 数据库存储默认配置
 	viper.SetDefault("database_storage.enabled", true)
+	viper.SetDefault("database_storage.batch_size", 100)
+	viper.SetDefault("database_storage.flush_interval", "1s")
+	viper.SetDefault("database_storage.queue_size", 10000)
+	viper.SetDefault("database_storage.cursor_retention", "168h")
+
+	// 持续失败自动停用默认配置
+	viper.SetDefault("failure_policy.enabled", false)
+	viper.SetDefault("failure_policy.max_duration", "6h")
+	viper.SetDefault("task_deletion.retention_days", 7)
+
+	// 通知默认配置
+	viper.SetDefault("notify.webhook_url", "")
+	viper.SetDefault("notify.slack_webhook_url", "")
+	viper.SetDefault("notify.email.smtp_port", 25)
+	viper.SetDefault("notify.email.to", []string{})
+	viper.SetDefault("notify.pagerduty_routing_key", "")
+
+	viper.SetDefault("canal.event_id_format", "position")
+	viper.SetDefault("canal.throttle.max_events_per_second", 0)
+	viper.SetDefault("canal.throttle.max_mb_per_second", 0)
+	viper.SetDefault("canal.large_values.policy", "none")
+	viper.SetDefault("canal.large_values.max_size", 1<<20)
+	viper.SetDefault("canal.rows_query.enabled", false)
+	viper.SetDefault("canal.rows_query.max_length", 4096)
+	viper.SetDefault("canal.numeric_strings", false)
+	viper.SetDefault("canal.max_delivery_concurrency", 0)
+	viper.SetDefault("canal.limits.max_tasks_per_source", 0)
+	viper.SetDefault("canal.limits.max_instances", 0)
+	viper.SetDefault("canal.limits.max_replication_connections", 0)
+	viper.SetDefault("canal.preflight", true)
+	viper.SetDefault("canal.min_binlog_retention", "24h")
+	viper.SetDefault("canal.sink_check", false)
+	viper.SetDefault("canal.backfill_dir", "")
+	viper.SetDefault("canal.webhook_transport.max_idle_conns_per_host", 32)
+	viper.SetDefault("canal.webhook_transport.max_conns_per_host", 0)
+	viper.SetDefault("canal.webhook_transport.idle_conn_timeout", "90s")
+	viper.SetDefault("canal.webhook_transport.keep_alive", "30s")
+	viper.SetDefault("canal.webhook_transport.disable_keep_alives", false)
+	viper.SetDefault("canal.webhook_transport.disable_http2", false)
+	viper.SetDefault("canal.webhook_transport.dns_cache_ttl", "1m")
+	viper.SetDefault("canal.memory.soft_limit_mb", 0)
+	viper.SetDefault("canal.memory.hard_limit_mb", 0)
+	viper.SetDefault("canal.memory.sample_interval", "10s")
+	viper.SetDefault("canal.event_buffer.size", 1000)
+	viper.SetDefault("canal.event_buffer.max_size", 0)
+	viper.SetDefault("canal.event_buffer.send_timeout", "5s")
+	viper.SetDefault("canal.event_buffer.overflow", "block")
+	viper.SetDefault("canal.event_buffer.spill.enabled", false)
+	viper.SetDefault("canal.event_buffer.spill.dir", "./data/spill")
+	viper.SetDefault("canal.event_buffer.spill.max_size_mb", 1024)
+	viper.SetDefault("canal.sinks.queue_size", 1000)
+	viper.SetDefault("canal.position_check_interval", "1m")
+	viper.SetDefault("canal.checkpoint", "event")
+	viper.SetDefault("canal.connection.heartbeat_period", "30s")
+	viper.SetDefault("canal.connection.read_timeout", "90s")
+	viper.SetDefault("canal.connection.keepalive", "15s")
+	viper.SetDefault("canal.connection.dial_timeout", "10s")
+	viper.SetDefault("canal.watchdog.enabled", true)
+	viper.SetDefault("canal.watchdog.stall_timeout", "2m")
+	viper.SetDefault("canal.watchdog.max_restarts", 5)
+	viper.SetDefault("canal.watchdog.restart_window", "1h")
+	viper.SetDefault("canal.watchdog.jitter", "10s")
+
+	// 对象存储默认配置
+	viper.SetDefault("object_store.type", "")
+	viper.SetDefault("object_store.local_dir", "./data/objects")
+	viper.SetDefault("object_store.region", "us-east-1")
+
+	// 归档默认配置
+	viper.SetDefault("archive.enabled", false)
+	viper.SetDefault("archive.format", "ndjson")
+	viper.SetDefault("archive.prefix", "events")
+	viper.SetDefault("archive.max_file_size", 64<<20)
+	viper.SetDefault("archive.max_file_age", "5m")
+	viper.SetDefault("archive.max_buffer_size", 256<<20)
+
+	// ClickHouse 默认配置
+	viper.SetDefault("clickhouse.enabled", false)
+	viper.SetDefault("clickhouse.url", "http://localhost:8123")
+	viper.SetDefault("clickhouse.database", "")
+	viper.SetDefault("clickhouse.username", "default")
+	viper.SetDefault("clickhouse.password", "")
+	viper.SetDefault("clickhouse.batch_size", 1000)
+	viper.SetDefault("clickhouse.flush_interval", "5s")
+	viper.SetDefault("clickhouse.max_buffer_rows", 100000)
+
+	// 告警默认配置
+	viper.SetDefault("alerting.rate_limit", "15m")
+	viper.SetDefault("alerting.lag_threshold", "5m")
+	viper.SetDefault("alerting.dlq_growth_threshold", 100)
+	viper.SetDefault("alerting.slo.window", "672h")
+	viper.SetDefault("alerting.slo.freshness_threshold", "5m")
+	viper.SetDefault("alerting.slo.availability_target", 0.999)
+	viper.SetDefault("alerting.slo.freshness_target", 0.99)
+	viper.SetDefault("alerting.slo.delivery_target", 0.999)
 }
@@ -9,15 +9,26 @@
 package database
 
 import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+
+	"pikachun/internal/config"
 )
 
 // Init 初始化数据库连接
-func Init(dsn string) (*gorm.DB, error) {
+func Init(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dsn, err := buildDSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
@@ -25,48 +36,280 @@ func Init(dsn string) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// 连接池配置
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime != "" {
+		lifetime, err := time.ParseDuration(cfg.ConnMaxLifetime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid database.conn_max_lifetime: %v", err)
+		}
+		sqlDB.SetConnMaxLifetime(lifetime)
+	}
+
 	// 自动迁移数据表
-	if err := migrate(db); err != nil {
+	if err := Migrate(db); err != nil {
 		return nil, err
 	}
 
+	// 全文索引不可用时事件日志搜索回退到 LIKE
+	if err := setupEventLogFTS(db); err != nil {
+		db.Logger.Warn(context.Background(), "event log full-text index unavailable, falling back to LIKE search: %v", err)
+	}
+
 	return db, nil
 }
 
-// migrate 执行数据库迁移
-func migrate(db *gorm.DB) error {
+// buildDSN 将 SQLite pragma 追加到 DSN
+// pragma 通过 _pragma 参数传递，驱动会在每个新连接上执行，连接池中的连接配置一致
+func buildDSN(cfg config.DatabaseConfig) (string, error) {
+	journalMode := cfg.JournalMode
+	if journalMode == "" {
+		journalMode = "WAL"
+	}
+	synchronous := cfg.Synchronous
+	if synchronous == "" {
+		synchronous = "NORMAL"
+	}
+	busyTimeout := 5 * time.Second
+	if cfg.BusyTimeout != "" {
+		timeout, err := time.ParseDuration(cfg.BusyTimeout)
+		if err != nil {
+			return "", fmt.Errorf("invalid database.busy_timeout: %v", err)
+		}
+		busyTimeout = timeout
+	}
+
+	// busy_timeout 需要最先设置，切换 journal_mode 时也可能等待锁
+	pragmas := []struct{ name, value string }{
+		{"busy_timeout", fmt.Sprintf("%d", busyTimeout.Milliseconds())},
+		{"journal_mode", journalMode},
+		{"synchronous", synchronous},
+	}
+
+	dsn := cfg.DSN
+	params := url.Values{}
+	for _, pragma := range pragmas {
+		// DSN 中已显式指定的 pragma 优先
+		if strings.Contains(dsn, "_pragma="+pragma.name) {
+			continue
+		}
+		params.Add("_pragma", fmt.Sprintf("%s(%s)", pragma.name, pragma.value))
+	}
+	if len(params) == 0 {
+		return dsn, nil
+	}
+
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + params.Encode(), nil
+}
+
+// Migrate 执行数据库迁移，可以重复执行
+func Migrate(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&Task{},
 		&EventLog{},
+		&DeliveryAttempt{},
+		&DeliveryCursor{},
+		&DeliverySequence{},
+		&TaskContract{},
+		&SLOBucket{},
+		&WatchPolicy{},
+		&PullCursor{},
+		&TableChangeStat{},
+		&MaintenancePause{},
+		&SkippedBinlogEvent{},
 	)
 }
 
 // EventLog 事件日志模型
 type EventLog struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	TaskID      uint      `json:"task_id" gorm:"not null;index"`
+	EventID     string    `json:"event_id" gorm:"size:100;index"`
+	Database    string    `json:"database" gorm:"not null;size:100"`
+	Table       string    `json:"table" gorm:"not null;size:100"`
+	EventType   string    `json:"event_type" gorm:"not null;size:20"`
+	ContentHash string    `json:"content_hash" gorm:"size:64;index"` // 事件的内容哈希，与投递的 content_hash 相同
+	Data        string    `json:"data" gorm:"type:text"`
+	BeforeData  string    `json:"before_data,omitempty" gorm:"type:text"`                                                             // UPDATE 事件修改前的数据，重新投递时还原
+	Status      string    `json:"status" gorm:"default:'pending';size:20" enums:"pending,success,failed,dry_run,expired,quarantined"` // pending, success, failed, dry_run, expired, quarantined
+	Error       string    `json:"error" gorm:"type:text"`
+	CreatedAt   time.Time `json:"created_at" format:"date-time"`
+	Task        Task      `json:"task" gorm:"foreignKey:TaskID"`
+}
+
+// DeliveryAttempt Webhook 投递尝试记录，通过 EventID 关联事件日志
+type DeliveryAttempt struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	TaskID       uint      `json:"task_id" gorm:"not null;index"`
+	EventID      string    `json:"event_id" gorm:"not null;size:100;index"`
+	Attempt      int       `json:"attempt"`
+	URL          string    `json:"url" gorm:"size:500"`
+	StatusCode   int       `json:"status_code"` // 0 表示请求未得到响应
+	LatencyMs    int64     `json:"latency_ms"`
+	ResponseBody string    `json:"response_body" gorm:"type:text"` // 截断后的响应体
+	Error        string    `json:"error" gorm:"type:text"`
+	DryRun       bool      `json:"dry_run"`                  // 演练模式，未实际发送请求
+	Payload      string    `json:"payload" gorm:"type:text"` // 演练模式下将要发送的请求体（截断）
+	PartitionKey string    `json:"partition_key"`            // 请求的分区键，未分区时为空
+	CreatedAt    time.Time `json:"created_at" format:"date-time"`
+} // @name StoredDeliveryAttempt
+
+// DeliveryCursor Webhook 请求序号与 binlog 位置的对应关系，用于消费端按序号或位置恢复
+type DeliveryCursor struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	TaskID       uint      `json:"task_id" gorm:"not null;uniqueIndex:idx_task_sequence"`
+	Sequence     uint64    `json:"sequence" gorm:"not null;uniqueIndex:idx_task_sequence"`
+	PartitionKey string    `json:"partition_key"`
+	FirstFile    string    `json:"first_file" gorm:"size:255"` // 请求中第一个事件的位置
+	FirstPos     uint32    `json:"first_pos"`
+	LastFile     string    `json:"last_file" gorm:"size:255"` // 请求中最后一个事件的位置
+	LastPos      uint32    `json:"last_pos"`
+	EventCount   int       `json:"event_count"`
+	CreatedAt    time.Time `json:"created_at" format:"date-time"`
+}
+
+// DeliverySequence 任务已预留的请求序号上限，每个任务一行。序号分配前先预留，
+// 投递失败和重启前未用完的序号不会再次分配，清理过期的 delivery_cursors 也不影响序号递增
+type DeliverySequence struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	TaskID    uint      `json:"task_id" gorm:"not null;uniqueIndex"`
+	Reserved  uint64    `json:"reserved"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TaskContract 消费端登记的载荷契约，每个任务一份，格式见 canal.ConsumerContract
+type TaskContract struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	TaskID     uint      `json:"task_id" gorm:"not null;uniqueIndex"`
+	Version    int       `json:"version"` // 每次登记加一
+	Definition string    `json:"definition" gorm:"type:text"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SLOBucket 任务 SLO 统计桶，每 5 分钟一个，进程重启后用于继续计算统计窗口内的达标率
+type SLOBucket struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	TaskID    uint      `json:"task_id" gorm:"not null;uniqueIndex:idx_task_slo_start"`
+	Start     time.Time `json:"start" gorm:"not null;uniqueIndex:idx_task_slo_start"`
+	Observed  float64   `json:"observed"` // 计入统计的秒数
+	Up        float64   `json:"up"`       // 实例运行且没有告警的秒数
+	Fresh     float64   `json:"fresh"`    // 延迟不超过阈值的秒数
+	Delivered int64     `json:"delivered"`
+	Failed    int64     `json:"failed"`
+}
+
+// WatchPolicy 全局监听策略，只有一行，各字段为逗号分隔的列表，格式见 canal.WatchPolicy
+type WatchPolicy struct {
+	ID            uint      `json:"id" gorm:"primarykey"`
+	Tables        string    `json:"tables" gorm:"type:text"`
+	EventTypes    string    `json:"event_types" gorm:"size:100"`
+	ExcludeTables string    `json:"exclude_tables" gorm:"type:text"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// MaintenancePause 人工维护暂停，进程重启后恢复。source 为空表示全局暂停
+type MaintenancePause struct {
+	ID     uint      `json:"id" gorm:"primarykey"`
+	Source string    `json:"source" gorm:"size:100;uniqueIndex"`
+	Reason string    `json:"reason" gorm:"size:500"`
+	Since  time.Time `json:"since"`
+}
+
+// PullCursor 拉取消费者已确认的位置，即已处理的最后一条事件日志的ID，每个任务的每个消费者一行
+type PullCursor struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	TaskID    uint      `json:"task_id" gorm:"not null;uniqueIndex:idx_task_consumer"`
+	Consumer  string    `json:"consumer" gorm:"not null;size:100;uniqueIndex:idx_task_consumer"`
+	Cursor    uint      `json:"cursor"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableChangeStat 任务读取到的各表行变更数，每 5 分钟一个统计桶，用于变更频率分析
+type TableChangeStat struct {
+	ID       uint      `json:"id" gorm:"primarykey"`
+	TaskID   uint      `json:"task_id" gorm:"not null;uniqueIndex:idx_task_table_start"`
+	Database string    `json:"database" gorm:"not null;size:100;uniqueIndex:idx_task_table_start"`
+	Table    string    `json:"table" gorm:"not null;size:100;uniqueIndex:idx_task_table_start"`
+	Start    time.Time `json:"start" gorm:"not null;uniqueIndex:idx_task_table_start;index"`
+	Inserts  int64     `json:"inserts"`
+	Updates  int64     `json:"updates"`
+	Deletes  int64     `json:"deletes"`
+}
+
+// SkippedBinlogEvent 按 canal.binlog.checksum.on_mismatch=skip 跳过的损坏 binlog 事件，事件中的数据没有投递
+type SkippedBinlogEvent struct {
 	ID        uint      `json:"id" gorm:"primarykey"`
 	TaskID    uint      `json:"task_id" gorm:"not null;index"`
-	Database  string    `json:"database" gorm:"not null;size:100"`
-	Table     string    `json:"table" gorm:"not null;size:100"`
-	EventType string    `json:"event_type" gorm:"not null;size:20"`
-	Data      string    `json:"data" gorm:"type:text"`
-	Status    string    `json:"status" gorm:"default:'pending';size:20"` // pending, success, failed
+	Filename  string    `json:"filename" gorm:"size:255"`
+	Position  uint32    `json:"position"` // 损坏事件的起始位置
+	EndPos    uint32    `json:"end_pos"`  // 跳过后继续读取的位置
+	EventType string    `json:"event_type" gorm:"size:50"`
 	Error     string    `json:"error" gorm:"type:text"`
-	CreatedAt time.Time `json:"created_at"`
-	Task      Task      `json:"task" gorm:"foreignKey:TaskID"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
 }
 
 // Task 监听任务模型
 type Task struct {
-	ID          uint           `json:"id" gorm:"primarykey"`
-	Name        string         `json:"name" gorm:"not null;size:100"`
-	Database    string         `json:"database" gorm:"not null;size:100"`
-	Table       string         `json:"table" gorm:"not null;size:100"`
-	EventTypes  string         `json:"event_types" gorm:"not null;size:200"` // INSERT,UPDATE,DELETE
-	CallbackURL string         `json:"callback_url" gorm:"not null;size:500"`
-	Status      string         `json:"status" gorm:"default:'active';size:20"` // active, inactive
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID              uint           `json:"id" gorm:"primarykey"`
+	Name            string         `json:"name" gorm:"not null;size:100"`
+	Database        string         `json:"database" gorm:"not null;size:100"`
+	Table           string         `json:"table" gorm:"not null;size:100"`
+	EventTypes      string         `json:"event_types" gorm:"not null;size:200"` // INSERT,UPDATE,DELETE
+	ExcludeTables   string         `json:"exclude_tables" gorm:"size:500"`       // 排除规则，逗号分隔，如 *_tmp,migrations
+	CallbackURL     string         `json:"callback_url" gorm:"not null;size:500" format:"uri"`
+	Status          string         `json:"status" gorm:"default:'active';size:20" enums:"active,inactive,failed,pending"` // active, inactive, failed, pending
+	LastError       string         `json:"last_error" gorm:"type:text"`                                                   // 最近一次实例错误
+	LastErrorAt     *time.Time     `json:"last_error_at" format:"date-time" extensions:"x-nullable"`
+	RequestTimeout  int            `json:"request_timeout"`                                                      // 单次 HTTP 请求超时（秒），0 表示默认 30s
+	DeliveryTimeout int            `json:"delivery_timeout"`                                                     // 一批事件投递的总超时（秒，含重试），0 表示默认 60s
+	ShutdownTimeout int            `json:"shutdown_timeout"`                                                     // 停止时刷新缓冲区的超时（秒），0 表示默认 30s
+	Schedule        string         `json:"schedule" gorm:"size:500"`                                             // 维护窗口，如 "Mon-Fri 09:00-18:00; Sat,Sun 22:00-06:00"
+	ScheduleMode    string         `json:"schedule_mode" gorm:"size:20" enums:"pause,throttle"`                  // 窗口内的处理方式: pause（默认）、throttle
+	ScheduleRate    int            `json:"schedule_rate"`                                                        // throttle 模式下每秒最多读取的 binlog 事件数
+	DryRun          bool           `json:"dry_run"`                                                              // 演练模式：完整运行处理流程，只记录将要投递的内容，不调用 Webhook
+	PartitionBy     string         `json:"partition_by" gorm:"size:20" enums:"none,pk,table,column,round_robin"` // 分区路由: pk、table、column、round_robin，空或 none 表示不分区
+	PartitionColumn string         `json:"partition_column"`                                                     // column 策略使用的列名
+	PartitionCount  int            `json:"partition_count"`                                                      // 分区数，大于 0 时分区键为分区号
+	NumericStrings  *bool          `json:"numeric_strings" extensions:"x-nullable"`                              // 64 位整数和定点小数编码为 JSON 字符串，为空时使用全局配置
+	IncludeSchema   *bool          `json:"include_schema" extensions:"x-nullable"`                               // 载荷中附带表结构元数据，为空表示不附带
+	Compression     string         `json:"compression" gorm:"size:10" enums:"none,gzip,zstd,auto"`               // 请求体压缩: gzip、zstd、auto，空或 none 表示不压缩
+	CompressMinSize int            `json:"compress_min_size"`                                                    // 只压缩不小于该字节数的请求体，0 表示默认 1024
+	Priority        int            `json:"priority"`                                                             // 投递调度权重，全局投递并发已满时按权重分配，0 表示默认 1
+	SamplePercent   float64        `json:"sample_percent"`                                                       // 事件采样比例（百分比），按库表和主键哈希，0 表示不按比例采样
+	SampleInterval  int            `json:"sample_interval"`                                                      // 同一行的最小事件间隔（秒），0 表示不限频
+	CompactWindow   int            `json:"compact_window"`                                                       // 窗口合并（秒）：同一行在窗口内的变更合并为最新状态后投递，0 表示不合并
+	DeleteMode      string         `json:"delete_mode" gorm:"size:20" enums:"before,tombstone,both"`             // DELETE 事件投递方式: before（默认，删除前镜像）、tombstone（墓碑）、both
+	PayloadMapping  string         `json:"payload_mapping" gorm:"type:text"`                                     // 载荷映射规则（JSON）：列重命名、展开行数据、删除元数据，空表示不改写
+	RowFilter       string         `json:"row_filter" gorm:"type:text"`                                          // 行过滤条件，如 status = 'paid' AND amount >= 100，空表示不过滤
+	SourceName      string         `json:"source_name" gorm:"size:100"`                                          // 来源标识：载荷的 source 字段和 X-Source-Name 请求头，空表示 canal-pikachun
+	Environment     string         `json:"environment" gorm:"size:50"`                                           // 来源环境：载荷的 environment 字段和 X-Source-Environment 请求头，空表示不发送
+	UserAgent       string         `json:"user_agent" gorm:"size:200"`                                           // 投递请求的 User-Agent，空表示 Canal-Pikachun/1.0
+	MaxLagSeconds   int            `json:"max_lag_seconds"`                                                      // 延迟保护：复制延迟上限（秒），0 表示不限制
+	MaxLagEvents    int            `json:"max_lag_events"`                                                       // 延迟保护：未投递事件数上限，0 表示不限制
+	LagAction       string         `json:"lag_action" gorm:"size:20" enums:"alert,pause,sample"`                 // 超过限制时的处理方式: alert（默认）、pause、sample
+	LagSample       float64        `json:"lag_sample"`                                                           // sample 方式保留的百分比，0 表示默认 10
+	MaxEventAge     int            `json:"max_event_age"`                                                        // 事件最大存活时间（秒）：投递失败后超过该时间的事件不再重试，0 表示不限制
+	ExpiredAction   string         `json:"expired_action" gorm:"size:10" enums:"dlq,drop"`                       // 过期事件的处理方式: dlq（默认，进入失败事件列表）、drop（丢弃）
+	QualityRules    string         `json:"quality_rules" gorm:"type:text"`                                       // 数据质量规则（JSON）：非空、取值范围断言，违规的事件计数并采样，空表示不检查
+	QuarantineURL   string         `json:"quarantine_url" gorm:"size:500" format:"uri"`                          // 隔离地址：违反数据质量规则的事件改投到该地址，空表示照常投递
+	IdempotencyKey  *string        `json:"idempotency_key,omitempty" gorm:"uniqueIndex;size:100"`
+	CreatedAt       time.Time      `json:"created_at" format:"date-time"`
+	UpdatedAt       time.Time      `json:"updated_at" format:"date-time"`
+	DeletedAt       gorm.DeletedAt `json:"deleted_at" gorm:"index" swaggertype:"string" format:"date-time" extensions:"x-nullable"`
 }
 
 // TableName 指定表名
@@ -78,3 +321,43 @@ func (Task) TableName() string {
 func (EventLog) TableName() string {
 	return "event_logs"
 }
+
+// TableName 指定表名
+func (DeliveryAttempt) TableName() string {
+	return "delivery_attempts"
+}
+
+// TableName 指定表名
+func (DeliveryCursor) TableName() string {
+	return "delivery_cursors"
+}
+
+// TableName 指定表名
+func (DeliverySequence) TableName() string {
+	return "delivery_sequences"
+}
+
+// TableName 指定表名
+func (MaintenancePause) TableName() string {
+	return "maintenance_pauses"
+}
+
+// TableName 指定表名
+func (SLOBucket) TableName() string {
+	return "slo_buckets"
+}
+
+// TableName 指定表名
+func (WatchPolicy) TableName() string {
+	return "watch_policies"
+}
+
+// TableName 指定表名
+func (PullCursor) TableName() string {
+	return "pull_cursors"
+}
+
+// TableName 指定表名
+func (TableChangeStat) TableName() string {
+	return "table_change_stats"
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pikachun/internal/config"
+)
+
+// TestBuildDSN 测试 pragma 参数拼接
+func TestBuildDSN(t *testing.T) {
+	dsn, err := buildDSN(config.DatabaseConfig{DSN: "./data/pikachun.db", BusyTimeout: "3s"})
+	if err != nil {
+		t.Fatalf("buildDSN failed: %v", err)
+	}
+	for _, want := range []string{"busy_timeout%283000%29", "journal_mode%28WAL%29", "synchronous%28NORMAL%29"} {
+		if !strings.Contains(dsn, want) {
+			t.Errorf("DSN %q missing %q", dsn, want)
+		}
+	}
+
+	// DSN 中显式指定的 pragma 不会被覆盖
+	dsn, err = buildDSN(config.DatabaseConfig{DSN: "file.db?_pragma=journal_mode(DELETE)"})
+	if err != nil {
+		t.Fatalf("buildDSN failed: %v", err)
+	}
+	if strings.Count(dsn, "journal_mode") != 1 || !strings.Contains(dsn, "&_pragma=") {
+		t.Errorf("unexpected DSN %q", dsn)
+	}
+
+	if _, err := buildDSN(config.DatabaseConfig{DSN: "file.db", BusyTimeout: "soon"}); err == nil {
+		t.Errorf("expected error for invalid busy_timeout")
+	}
+}
+
+// TestInitPragmas 测试每个连接都应用了 pragma
+func TestInitPragmas(t *testing.T) {
+	db, err := Init(config.DatabaseConfig{DSN: filepath.Join(t.TempDir(), "test.db"), MaxOpenConns: 2})
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	var journalMode string
+	var busyTimeout, synchronous int
+	db.Raw("PRAGMA journal_mode").Scan(&journalMode)
+	db.Raw("PRAGMA busy_timeout").Scan(&busyTimeout)
+	db.Raw("PRAGMA synchronous").Scan(&synchronous)
+
+	if !strings.EqualFold(journalMode, "wal") {
+		t.Errorf("expected WAL journal mode, got %q", journalMode)
+	}
+	if busyTimeout != 5000 {
+		t.Errorf("expected busy_timeout 5000, got %d", busyTimeout)
+	}
+	// NORMAL = 1
+	if synchronous != 1 {
+		t.Errorf("expected synchronous NORMAL, got %d", synchronous)
+	}
+
+	sqlDB, _ := db.DB()
+	if stats := sqlDB.Stats(); stats.MaxOpenConnections != 2 {
+		t.Errorf("expected 2 max open connections, got %d", stats.MaxOpenConnections)
+	}
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"gorm.io/gorm"
+)
+
+// EventLogFTSTable 事件日志全文索引表
+const EventLogFTSTable = "event_logs_fts"
+
+// setupEventLogFTS 为事件日志数据创建 FTS5 全文索引，并通过触发器保持同步
+// SQLite 未编译 FTS5 时返回错误，调用方应回退到 LIKE 搜索
+func setupEventLogFTS(db *gorm.DB) error {
+	exists := db.Migrator().HasTable(EventLogFTSTable)
+
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS event_logs_fts USING fts5(data, content='event_logs', content_rowid='id')`,
+		`CREATE TRIGGER IF NOT EXISTS event_logs_fts_ai AFTER INSERT ON event_logs BEGIN
+			INSERT INTO event_logs_fts(rowid, data) VALUES (new.id, new.data);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS event_logs_fts_ad AFTER DELETE ON event_logs BEGIN
+			INSERT INTO event_logs_fts(event_logs_fts, rowid, data) VALUES ('delete', old.id, old.data);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS event_logs_fts_au AFTER UPDATE ON event_logs BEGIN
+			INSERT INTO event_logs_fts(event_logs_fts, rowid, data) VALUES ('delete', old.id, old.data);
+			INSERT INTO event_logs_fts(rowid, data) VALUES (new.id, new.data);
+		END`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	// 首次创建时为已有数据建立索引
+	if !exists {
+		return db.Exec(`INSERT INTO event_logs_fts(event_logs_fts) VALUES ('rebuild')`).Error
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+// Package i18n 消息本地化：API 错误信息和 Web 界面文字以中文原文作为消息ID，
+// 各语言的消息目录将原文映射为译文，缺少译文时返回原文。
+// 内置 zh、en 两种语言，集成方可以通过 Register 或 LoadDir 补充或覆盖翻译、添加新语言
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLanguage 消息ID使用的语言
+const DefaultLanguage = "zh"
+
+//go:embed locales/*.json
+var builtinLocales embed.FS
+
+var (
+	mu       sync.RWMutex
+	catalogs = map[string]map[string]string{}
+)
+
+func init() {
+	entries, err := builtinLocales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		data, err := builtinLocales.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		if err := registerJSON(strings.TrimSuffix(entry.Name(), ".json"), data); err != nil {
+			panic(fmt.Sprintf("invalid builtin locale %s: %v", entry.Name(), err))
+		}
+	}
+}
+
+// Register 合并一种语言的消息目录，已有的译文被覆盖
+func Register(lang string, messages map[string]string) {
+	lang = Normalize(lang)
+	if lang == "" {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	catalog := catalogs[lang]
+	if catalog == nil {
+		catalog = make(map[string]string, len(messages))
+		catalogs[lang] = catalog
+	}
+	for msgid, text := range messages {
+		catalog[msgid] = text
+	}
+}
+
+// LoadDir 加载目录中的 <语言>.json 消息目录，文件内容为 {"原文": "译文"}
+func LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list locales in %s: %v", dir, err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read locale %s: %v", path, err)
+		}
+		if err := registerJSON(strings.TrimSuffix(filepath.Base(path), ".json"), data); err != nil {
+			return fmt.Errorf("invalid locale %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func registerJSON(lang string, data []byte) error {
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return err
+	}
+	Register(lang, messages)
+	return nil
+}
+
+// Languages 已注册的语言
+func Languages() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Supported 语言是否已注册
+func Supported(lang string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := catalogs[Normalize(lang)]
+	return ok
+}
+
+// Messages 一种语言的消息目录副本
+func Messages(lang string) map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+	catalog := catalogs[Normalize(lang)]
+	messages := make(map[string]string, len(catalog))
+	for msgid, text := range catalog {
+		messages[msgid] = text
+	}
+	return messages
+}
+
+// T 翻译消息，args 按 fmt.Sprintf 格式化；缺少译文时使用原文
+func T(lang, msgid string, args ...interface{}) string {
+	text := msgid
+	mu.RLock()
+	if translated, ok := catalogs[Normalize(lang)][msgid]; ok && translated != "" {
+		text = translated
+	}
+	mu.RUnlock()
+
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// Normalize 规范化语言标签，只保留主语言：zh-CN、zh_Hans 为 zh，en-US 为 en
+func Normalize(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// Match 按 Accept-Language 的权重选择已注册的语言，没有匹配时返回空字符串
+func Match(acceptLanguage string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ && Supported(tag) {
+			best, bestQ = Normalize(tag), q
+		}
+	}
+	return best
+}
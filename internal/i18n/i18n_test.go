@@ -0,0 +1,84 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestT 测试翻译、格式化和缺少译文时使用原文
+func TestT(t *testing.T) {
+	if got := T("en", "任务不存在"); got != "Task not found" {
+		t.Errorf("unexpected translation %q", got)
+	}
+	if got := T("en-US", "一次最多创建 %d 个任务，请求中有 %d 个", 100, 120); got != "At most 100 tasks can be created at once, got 120" {
+		t.Errorf("unexpected formatted translation %q", got)
+	}
+	if got := T("zh", "请求参数错误: %v", "缺少 name"); got != "请求参数错误: 缺少 name" {
+		t.Errorf("zh should use the message id, got %q", got)
+	}
+	if got := T("fr", "任务不存在"); got != "任务不存在" {
+		t.Errorf("unknown language should fall back to the message id, got %q", got)
+	}
+}
+
+// TestBuiltinCatalogFormatVerbs 测试内置译文与原文的格式化占位符一致
+func TestBuiltinCatalogFormatVerbs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[vsdq]|\{\d\}`)
+	for msgid, text := range Messages("en") {
+		if strings.Join(verbs.FindAllString(msgid, -1), ",") != strings.Join(verbs.FindAllString(text, -1), ",") {
+			t.Errorf("placeholders of %q do not match %q", text, msgid)
+		}
+	}
+}
+
+// TestLoadDir 测试集成方的消息目录覆盖内置译文并添加新语言
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "en.json"), []byte(`{"任务不存在": "No such task"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ja.json"), []byte(`{"中文": "日本語", "任务不存在": "タスクが存在しません"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	original := T("en", "任务不存在")
+	defer Register("en", map[string]string{"任务不存在": original})
+
+	if err := LoadDir(dir); err != nil {
+		t.Fatalf("failed to load locales: %v", err)
+	}
+	if got := T("en", "任务不存在"); got != "No such task" {
+		t.Errorf("expected overridden translation, got %q", got)
+	}
+	if got := T("en", "无效的任务ID"); got != "Invalid task ID" {
+		t.Errorf("other builtin translations should be kept, got %q", got)
+	}
+	if !Supported("ja") || T("ja", "任务不存在") != "タスクが存在しません" {
+		t.Error("expected the new language to be registered")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "bad.json"), []byte(`[`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDir(dir); err == nil {
+		t.Error("expected error for an invalid catalog")
+	}
+}
+
+// TestMatch 测试按 Accept-Language 权重选择语言
+func TestMatch(t *testing.T) {
+	tests := map[string]string{
+		"en-US,en;q=0.9":          "en",
+		"zh-CN,zh;q=0.9,en;q=0.8": "zh",
+		"fr;q=0.9,en;q=0.5":       "en",
+		"de, fr":                  "",
+		"":                        "",
+	}
+	for header, want := range tests {
+		if got := Match(header); got != want {
+			t.Errorf("Match(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
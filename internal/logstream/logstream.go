@@ -0,0 +1,170 @@
+// Package logstream 按任务收集实例和处理器输出的日志，保存最近的若干行，
+// 并实时转发给订阅者，用于在界面上查看单个任务的日志，不必在进程标准输出中查找
+package logstream
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTailSize 每个任务保存的最近日志行数
+const DefaultTailSize = 500
+
+// subscriberBuffer 订阅者的缓冲行数，订阅者跟不上时丢弃新行，不阻塞写日志
+const subscriberBuffer = 256
+
+// Labels 日志行所属的任务、实例和源库，附加在任务日志记录器的前缀之后，
+// 同一进程中多个实例的日志交错输出时可以按标签区分
+type Labels struct {
+	TaskID     uint   `json:"task_id"`
+	InstanceID string `json:"instance_id"`
+	Source     string `json:"source"`
+}
+
+// String 以 key=value 形式输出标签
+func (l Labels) String() string {
+	return fmt.Sprintf("task_id=%d instance_id=%s source=%s", l.TaskID, l.InstanceID, l.Source)
+}
+
+// Entry 一行日志
+type Entry struct {
+	Time time.Time `json:"time" format:"date-time"`
+	Labels
+	Message string `json:"message"` // 去掉前缀和标签后的日志行
+} // @name LogEntry
+
+// Hub 各任务的日志
+type Hub struct {
+	mu    sync.Mutex
+	size  int
+	tasks map[uint]*taskLog
+}
+
+// taskLog 任务最近的日志（环形缓冲）和订阅者
+type taskLog struct {
+	recent  []Entry
+	next    int // 下一行写入 recent 的位置，写满后覆盖最早的行
+	subs    map[chan Entry]struct{}
+	dropped int64 // 因订阅者跟不上而未送达的行数
+}
+
+// NewHub 创建日志中心，size 为每个任务保存的最近日志行数，不大于 0 时使用 DefaultTailSize
+func NewHub(size int) *Hub {
+	if size <= 0 {
+		size = DefaultTailSize
+	}
+	return &Hub{size: size, tasks: make(map[uint]*taskLog)}
+}
+
+// Logger 创建任务的日志记录器：前缀为 base 的前缀加上标签，格式与 base 相同，
+// 输出仍写到 base，同时按行记入任务的日志
+func (h *Hub) Logger(base *log.Logger, labels Labels) *log.Logger {
+	prefix := base.Prefix() + labels.String() + " "
+	return log.New(&taskWriter{hub: h, labels: labels, out: base.Writer(), prefix: prefix}, prefix, base.Flags())
+}
+
+// taskWriter 写到原输出并记入任务日志，log.Logger 每次 Write 写入一行
+type taskWriter struct {
+	hub    *Hub
+	labels Labels
+	out    io.Writer
+	prefix string
+}
+
+func (w *taskWriter) Write(p []byte) (int, error) {
+	w.hub.Publish(w.labels, strings.TrimPrefix(strings.TrimRight(string(p), "\n"), w.prefix))
+	return w.out.Write(p)
+}
+
+// Publish 记入任务的一行日志并转发给订阅者
+func (h *Hub) Publish(labels Labels, message string) {
+	entry := Entry{Time: time.Now(), Labels: labels, Message: message}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t := h.task(labels.TaskID)
+	if len(t.recent) < h.size {
+		t.recent = append(t.recent, entry)
+	} else {
+		t.recent[t.next] = entry
+	}
+	t.next = (t.next + 1) % h.size
+	for ch := range t.subs {
+		select {
+		case ch <- entry:
+		default:
+			t.dropped++
+		}
+	}
+}
+
+// Subscribe 订阅任务的日志，返回最近的日志（按时间顺序）和之后的新行，
+// 调用 cancel 或 Remove 后取消订阅并关闭通道
+func (h *Hub) Subscribe(taskID uint) (recent []Entry, entries <-chan Entry, cancel func()) {
+	ch := make(chan Entry, subscriberBuffer)
+
+	h.mu.Lock()
+	t := h.task(taskID)
+	recent = make([]Entry, 0, len(t.recent))
+	if len(t.recent) == h.size {
+		recent = append(recent, t.recent[t.next:]...)
+		recent = append(recent, t.recent[:t.next]...)
+	} else {
+		recent = append(recent, t.recent...)
+	}
+	t.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			// Remove 已关闭通道时不再关闭
+			if _, ok := t.subs[ch]; ok {
+				delete(t.subs, ch)
+				close(ch)
+			}
+		})
+	}
+	return recent, ch, cancel
+}
+
+// Remove 丢弃任务的日志和订阅者，任务删除后调用以释放内存，订阅者的通道被关闭
+func (h *Hub) Remove(taskID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, ok := h.tasks[taskID]
+	if !ok {
+		return
+	}
+	for ch := range t.subs {
+		delete(t.subs, ch)
+		close(ch)
+	}
+	delete(h.tasks, taskID)
+}
+
+// Dropped 任务因订阅者跟不上而未送达的日志行数
+func (h *Hub) Dropped(taskID uint) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t, ok := h.tasks[taskID]; ok {
+		return t.dropped
+	}
+	return 0
+}
+
+// task 获取任务的日志，不存在时创建，调用方需持有 mu
+func (h *Hub) task(taskID uint) *taskLog {
+	t, ok := h.tasks[taskID]
+	if !ok {
+		t = &taskLog{subs: make(map[chan Entry]struct{})}
+		h.tasks[taskID] = t
+	}
+	return t
+}
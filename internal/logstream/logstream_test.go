@@ -0,0 +1,97 @@
+package logstream
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+// TestHubTail 测试每个任务只保留最近的日志，按时间顺序返回
+func TestHubTail(t *testing.T) {
+	hub := NewHub(3)
+	for _, message := range []string{"a", "b", "c", "d"} {
+		hub.Publish(Labels{TaskID: 1}, message)
+	}
+	hub.Publish(Labels{TaskID: 2}, "other")
+
+	recent, _, cancel := hub.Subscribe(1)
+	defer cancel()
+	var got []string
+	for _, entry := range recent {
+		if entry.TaskID != 1 {
+			t.Errorf("unexpected entry of task %d", entry.TaskID)
+		}
+		got = append(got, entry.Message)
+	}
+	if strings.Join(got, ",") != "b,c,d" {
+		t.Errorf("expected the last 3 lines in order, got %v", got)
+	}
+}
+
+// TestHubSubscribe 测试任务日志记录器带有标签，同时写到原输出和订阅者，取消订阅后关闭通道
+func TestHubSubscribe(t *testing.T) {
+	hub := NewHub(10)
+	var stdout bytes.Buffer
+	labels := Labels{TaskID: 7, InstanceID: "task-7", Source: "default"}
+	logger := hub.Logger(log.New(&stdout, "[EnhancedCanal] ", 0), labels)
+
+	_, entries, cancel := hub.Subscribe(7)
+	logger.Printf("🚀 Starting task %d", 7)
+	hub.Publish(Labels{TaskID: 8}, "another task")
+
+	entry := <-entries
+	if entry.Labels != labels || entry.Message != "🚀 Starting task 7" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if stdout.String() != "[EnhancedCanal] task_id=7 instance_id=task-7 source=default 🚀 Starting task 7\n" {
+		t.Errorf("expected the labeled line written to the base logger, got %q", stdout.String())
+	}
+
+	cancel()
+	cancel()
+	if _, ok := <-entries; ok {
+		t.Error("expected channel closed after cancel")
+	}
+	logger.Printf("after cancel")
+}
+
+// TestHubSlowSubscriber 测试订阅者跟不上时丢弃新行，不阻塞写日志
+func TestHubSlowSubscriber(t *testing.T) {
+	hub := NewHub(10)
+	_, _, cancel := hub.Subscribe(1)
+	defer cancel()
+	for i := 0; i < subscriberBuffer+5; i++ {
+		hub.Publish(Labels{TaskID: 1}, "line")
+	}
+	if dropped := hub.Dropped(1); dropped != 5 {
+		t.Errorf("expected 5 dropped lines, got %d", dropped)
+	}
+}
+
+// TestHubRemove 测试删除任务后丢弃日志并关闭订阅者的通道，之后取消订阅不会重复关闭
+func TestHubRemove(t *testing.T) {
+	hub := NewHub(10)
+	hub.Publish(Labels{TaskID: 1}, "line")
+	hub.Publish(Labels{TaskID: 2}, "other")
+	_, entries, cancel := hub.Subscribe(1)
+
+	hub.Remove(1)
+	if _, ok := <-entries; ok {
+		t.Error("expected channel closed after remove")
+	}
+	cancel()
+	if _, ok := hub.tasks[1]; ok {
+		t.Error("expected the task's log removed")
+	}
+	if len(hub.tasks) != 1 {
+		t.Errorf("expected other tasks kept, got %d", len(hub.tasks))
+	}
+	hub.Remove(1)
+
+	recent, _, cancel := hub.Subscribe(1)
+	defer cancel()
+	if len(recent) != 0 {
+		t.Errorf("expected no lines after remove, got %v", recent)
+	}
+}
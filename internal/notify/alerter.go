@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// AlertType 告警类型
+type AlertType string
+
+const (
+	AlertTaskFailed AlertType = "task_failed" // 任务持续失败被停用
+	AlertLag        AlertType = "lag"         // 同步延迟超过阈值
+	AlertDLQGrowth  AlertType = "dlq_growth"  // 失败事件快速增长
+
+	AlertPositionDrift AlertType = "position_drift" // 持久化位置漂移
+	AlertTaskLag       AlertType = "task_lag"       // 任务超过延迟保护限制
+	AlertChecksumSkip  AlertType = "checksum_skip"  // 跳过了校验和不匹配的 binlog 事件
+)
+
+// defaultTemplates 默认告警消息模板（标题, 正文）
+var defaultTemplates = map[AlertType][2]string{
+	AlertTaskFailed: {
+		"Task {{.task_id}} disabled after persistent failure",
+		"Task {{.task_id}} has been failing since {{.failing_since}} and was marked as failed: {{.reason}}",
+	},
+	AlertLag: {
+		"Replication lag on {{.instance_id}}",
+		"Instance {{.instance_id}} is {{.lag}} behind the source (threshold {{.threshold}}).",
+	},
+	AlertDLQGrowth: {
+		"Failed events growing for task {{.task_id}}",
+		"Task {{.task_id}} recorded {{.growth}} new failed events since the last check (total {{.total}}).",
+	},
+	AlertPositionDrift: {
+		"Position drift on {{.instance_id}}",
+		"Instance {{.instance_id}} persisted position {{.persisted}} drifted ({{.issues}}); {{.resolution}}.",
+	},
+	AlertTaskLag: {
+		"Task {{.task_id}} exceeded its lag limits",
+		"Task {{.task_id}} {{.reason}} (action: {{.action}}, since {{.since}}).",
+	},
+	AlertChecksumSkip: {
+		"Corrupt binlog event skipped on task {{.task_id}}",
+		"Task {{.task_id}} skipped a corrupt {{.event_type}} event at {{.position}} (continuing from {{.end_pos}}); its rows were not delivered: {{.error}}",
+	},
+}
+
+// DefaultRateLimit 未配置时同一告警的最小发送间隔
+const DefaultRateLimit = 15 * time.Minute
+
+// Alert 告警
+type Alert struct {
+	Type  AlertType
+	Key   string // 去重键，例如 task-1；同类型同键的告警受频率限制
+	Level Level
+	Data  map[string]interface{}
+}
+
+// Alerter 告警器：按模板渲染消息，并限制同一告警的发送频率
+type Alerter struct {
+	notifier    Notifier
+	minInterval time.Duration
+	titles      map[AlertType]*template.Template
+	texts       map[AlertType]*template.Template
+
+	mu         sync.Mutex
+	lastSent   map[string]time.Time // 最近一次发送成功的时间
+	sending    map[string]bool      // 正在发送的告警，期间同一告警不重复发送
+	sent       int64
+	suppressed int64
+	failed     int64
+}
+
+// NewAlerter 创建告警器，overrides 可按告警类型覆盖正文模板
+func NewAlerter(notifier Notifier, minInterval time.Duration, overrides map[string]string) (*Alerter, error) {
+	a := &Alerter{
+		notifier:    notifier,
+		minInterval: minInterval,
+		titles:      make(map[AlertType]*template.Template),
+		texts:       make(map[AlertType]*template.Template),
+		lastSent:    make(map[string]time.Time),
+		sending:     make(map[string]bool),
+	}
+
+	for alertType, tpl := range defaultTemplates {
+		text := tpl[1]
+		if override, ok := overrides[string(alertType)]; ok && override != "" {
+			text = override
+		}
+
+		title, err := template.New(string(alertType) + "-title").Option("missingkey=zero").Parse(tpl[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid title template for %s: %v", alertType, err)
+		}
+		body, err := template.New(string(alertType)).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for %s: %v", alertType, err)
+		}
+		a.titles[alertType] = title
+		a.texts[alertType] = body
+	}
+
+	return a, nil
+}
+
+// Fire 发送告警，返回是否实际发送（被频率限制时返回 false）。
+// 发送成功后才开始计算频率限制，发送失败的告警下次触发时重试
+func (a *Alerter) Fire(ctx context.Context, alert Alert) (bool, error) {
+	key := string(alert.Type) + ":" + alert.Key
+
+	a.mu.Lock()
+	if last, ok := a.lastSent[key]; (ok && time.Since(last) < a.minInterval) || a.sending[key] {
+		a.suppressed++
+		a.mu.Unlock()
+		return false, nil
+	}
+	a.sending[key] = true
+	a.mu.Unlock()
+
+	err := a.send(ctx, alert)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sending, key)
+	if err != nil {
+		a.failed++
+		return false, err
+	}
+	a.lastSent[key] = time.Now()
+	a.sent++
+	return true, nil
+}
+
+// send 渲染并发送告警消息
+func (a *Alerter) send(ctx context.Context, alert Alert) error {
+	title, err := render(a.titles[alert.Type], alert.Data)
+	if err != nil {
+		return err
+	}
+	text, err := render(a.texts[alert.Type], alert.Data)
+	if err != nil {
+		return err
+	}
+
+	fields := make(map[string]string, len(alert.Data)+1)
+	fields["alert"] = string(alert.Type)
+	for k, v := range alert.Data {
+		fields[k] = fmt.Sprintf("%v", v)
+	}
+
+	return a.notifier.Notify(ctx, Message{
+		Level:  alert.Level,
+		Title:  title,
+		Text:   text,
+		Fields: fields,
+		Time:   time.Now(),
+	})
+}
+
+// render 渲染模板
+func render(tpl *template.Template, data map[string]interface{}) (string, error) {
+	if tpl == nil {
+		return "", fmt.Errorf("unknown alert type")
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render alert template: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// GetStats 获取告警统计
+func (a *Alerter) GetStats() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return map[string]interface{}{
+		"sent":         a.sent,
+		"suppressed":   a.suppressed,
+		"failed":       a.failed,
+		"min_interval": a.minInterval.String(),
+		"notifiers":    a.notifier.Name(),
+	}
+}
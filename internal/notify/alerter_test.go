@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNotifier 记录收到的消息，err 不为空时发送失败
+type fakeNotifier struct {
+	messages []Message
+	err      error
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, msg Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.messages = append(f.messages, msg)
+	return nil
+}
+
+func (f *fakeNotifier) Name() string {
+	return "fake"
+}
+
+// TestAlerterRateLimit 测试同一告警的频率限制
+func TestAlerterRateLimit(t *testing.T) {
+	fake := &fakeNotifier{}
+	alerter, err := NewAlerter(fake, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("NewAlerter failed: %v", err)
+	}
+
+	alert := Alert{
+		Type:  AlertLag,
+		Key:   "task-1",
+		Level: LevelWarning,
+		Data:  map[string]interface{}{"instance_id": "task-1", "lag": "10m0s", "threshold": "5m0s"},
+	}
+
+	if sent, err := alerter.Fire(context.Background(), alert); err != nil || !sent {
+		t.Fatalf("first alert should be sent, sent=%v err=%v", sent, err)
+	}
+	if sent, _ := alerter.Fire(context.Background(), alert); sent {
+		t.Errorf("repeated alert should be suppressed")
+	}
+
+	// 不同的键不受影响
+	alert.Key = "task-2"
+	if sent, _ := alerter.Fire(context.Background(), alert); !sent {
+		t.Errorf("alert for another key should be sent")
+	}
+
+	if len(fake.messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(fake.messages))
+	}
+
+	stats := alerter.GetStats()
+	if stats["sent"].(int64) != 2 || stats["suppressed"].(int64) != 1 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+// TestAlerterRetryAfterFailure 测试发送失败的告警不计入频率限制，下次触发时重试
+func TestAlerterRetryAfterFailure(t *testing.T) {
+	fake := &fakeNotifier{err: errors.New("webhook unavailable")}
+	alerter, err := NewAlerter(fake, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("NewAlerter failed: %v", err)
+	}
+	alert := Alert{Type: AlertTaskFailed, Key: "task-1", Data: map[string]interface{}{"task_id": 1}}
+
+	if sent, err := alerter.Fire(context.Background(), alert); err == nil || sent {
+		t.Fatalf("expected failed send, sent=%v err=%v", sent, err)
+	}
+	fake.err = nil
+	if sent, err := alerter.Fire(context.Background(), alert); err != nil || !sent {
+		t.Fatalf("expected retry to be sent, sent=%v err=%v", sent, err)
+	}
+	if sent, _ := alerter.Fire(context.Background(), alert); sent {
+		t.Errorf("alert should be rate limited after a successful send")
+	}
+
+	stats := alerter.GetStats()
+	if stats["sent"].(int64) != 1 || stats["failed"].(int64) != 1 || stats["suppressed"].(int64) != 1 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+// TestAlerterTemplates 测试默认模板与自定义模板渲染
+func TestAlerterTemplates(t *testing.T) {
+	fake := &fakeNotifier{}
+	alerter, err := NewAlerter(fake, 0, map[string]string{
+		string(AlertDLQGrowth): "task={{.task_id}} +{{.growth}}",
+	})
+	if err != nil {
+		t.Fatalf("NewAlerter failed: %v", err)
+	}
+
+	alerter.Fire(context.Background(), Alert{
+		Type: AlertDLQGrowth,
+		Key:  "task-7",
+		Data: map[string]interface{}{"task_id": 7, "growth": 150, "total": 300},
+	})
+	alerter.Fire(context.Background(), Alert{
+		Type: AlertLag,
+		Key:  "task-7",
+		Data: map[string]interface{}{"instance_id": "task-7", "lag": "6m0s", "threshold": "5m0s"},
+	})
+
+	if len(fake.messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(fake.messages))
+	}
+	if got := fake.messages[0].Text; got != "task=7 +150" {
+		t.Errorf("override template rendered %q", got)
+	}
+	if got := fake.messages[0].Fields["alert"]; got != string(AlertDLQGrowth) {
+		t.Errorf("alert field = %q", got)
+	}
+	if got := fake.messages[1].Text; !strings.Contains(got, "task-7 is 6m0s behind") {
+		t.Errorf("default template rendered %q", got)
+	}
+
+	// 非法模板应在创建时报错
+	if _, err := NewAlerter(fake, 0, map[string]string{string(AlertLag): "{{.lag"}); err == nil {
+		t.Errorf("expected error for invalid template")
+	}
+}
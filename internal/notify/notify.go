@@ -0,0 +1,270 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// Level 通知级别
+type Level string
+
+const (
+	LevelInfo     Level = "info"
+	LevelWarning  Level = "warning"
+	LevelCritical Level = "critical"
+)
+
+// Message 运维通知消息
+type Message struct {
+	Level  Level             `json:"level"`
+	Title  string            `json:"title"`
+	Text   string            `json:"text"`
+	Fields map[string]string `json:"fields,omitempty"`
+	Time   time.Time         `json:"time"`
+}
+
+// Notifier 通知发送接口
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+	Name() string
+}
+
+// MultiNotifier 向多个通知渠道广播
+type MultiNotifier struct {
+	notifiers []Notifier
+	logger    *log.Logger
+}
+
+// New 根据配置创建通知器，未配置任何渠道时返回空的 MultiNotifier
+func New(cfg config.NotifyConfig, logger *log.Logger) *MultiNotifier {
+	m := &MultiNotifier{logger: logger}
+
+	if cfg.WebhookURL != "" {
+		m.notifiers = append(m.notifiers, NewWebhookNotifier(cfg.WebhookURL))
+	}
+	if cfg.SlackWebhookURL != "" {
+		m.notifiers = append(m.notifiers, NewSlackNotifier(cfg.SlackWebhookURL))
+	}
+	if cfg.Email.SMTPHost != "" && len(cfg.Email.To) > 0 {
+		m.notifiers = append(m.notifiers, NewEmailNotifier(cfg.Email))
+	}
+	if cfg.PagerDutyRoutingKey != "" {
+		m.notifiers = append(m.notifiers, NewPagerDutyNotifier(cfg.PagerDutyRoutingKey))
+	}
+
+	return m
+}
+
+// Name 获取通知器名称
+func (m *MultiNotifier) Name() string {
+	names := make([]string, 0, len(m.notifiers))
+	for _, n := range m.notifiers {
+		names = append(names, n.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+// Enabled 是否配置了通知渠道
+func (m *MultiNotifier) Enabled() bool {
+	return len(m.notifiers) > 0
+}
+
+// Notify 向所有渠道发送通知，单个渠道失败不影响其他渠道
+func (m *MultiNotifier) Notify(ctx context.Context, msg Message) error {
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+
+	var failed []string
+	for _, n := range m.notifiers {
+		if err := n.Notify(ctx, msg); err != nil {
+			m.logger.Printf("❌ Failed to send %s notification: %v", n.Name(), err)
+			failed = append(failed, n.Name())
+			continue
+		}
+		m.logger.Printf("📣 Notification sent via %s: %s", n.Name(), msg.Title)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("notification failed for: %s", strings.Join(failed, ","))
+	}
+	return nil
+}
+
+// formatText 将消息格式化为纯文本
+func formatText(msg Message) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s\n%s\n", strings.ToUpper(string(msg.Level)), msg.Title, msg.Text)
+
+	keys := make([]string, 0, len(msg.Fields))
+	for k := range msg.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\n", k, msg.Fields[k])
+	}
+	fmt.Fprintf(&b, "time: %s\n", msg.Time.Format(time.RFC3339))
+	return b.String()
+}
+
+// postJSON 以 JSON 格式 POST 请求
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Canal-Pikachun/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// WebhookNotifier 通用 Webhook 通知
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier 创建 Webhook 通知器
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Name 获取通知器名称
+func (n *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify 发送通知
+func (n *WebhookNotifier) Notify(ctx context.Context, msg Message) error {
+	return postJSON(ctx, n.client, n.url, map[string]interface{}{
+		"source":  "canal-pikachun",
+		"message": msg,
+	})
+}
+
+// SlackNotifier Slack Incoming Webhook 通知
+type SlackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewSlackNotifier 创建 Slack 通知器
+func NewSlackNotifier(url string) *SlackNotifier {
+	return &SlackNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Name 获取通知器名称
+func (n *SlackNotifier) Name() string {
+	return "slack"
+}
+
+// Notify 发送通知
+func (n *SlackNotifier) Notify(ctx context.Context, msg Message) error {
+	return postJSON(ctx, n.client, n.url, map[string]string{
+		"text": formatText(msg),
+	})
+}
+
+// EmailNotifier SMTP 邮件通知
+type EmailNotifier struct {
+	cfg config.EmailConfig
+}
+
+// NewEmailNotifier 创建邮件通知器
+func NewEmailNotifier(cfg config.EmailConfig) *EmailNotifier {
+	return &EmailNotifier{cfg: cfg}
+}
+
+// Name 获取通知器名称
+func (n *EmailNotifier) Name() string {
+	return "email"
+}
+
+// Notify 发送通知
+func (n *EmailNotifier) Notify(ctx context.Context, msg Message) error {
+	addr := fmt.Sprintf("%s:%d", n.cfg.SMTPHost, n.cfg.SMTPPort)
+
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.SMTPHost)
+	}
+
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: [Pikachun] %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		n.cfg.From, strings.Join(n.cfg.To, ", "), msg.Title, formatText(msg))
+
+	if err := smtp.SendMail(addr, auth, n.cfg.From, n.cfg.To, []byte(body)); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
+
+// pagerDutyEventsURL PagerDuty Events API v2 地址
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyNotifier PagerDuty Events API v2 通知
+type PagerDutyNotifier struct {
+	routingKey string
+	url        string
+	client     *http.Client
+}
+
+// NewPagerDutyNotifier 创建 PagerDuty 通知器
+func NewPagerDutyNotifier(routingKey string) *PagerDutyNotifier {
+	return &PagerDutyNotifier{
+		routingKey: routingKey,
+		url:        pagerDutyEventsURL,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name 获取通知器名称
+func (n *PagerDutyNotifier) Name() string {
+	return "pagerduty"
+}
+
+// Notify 发送通知
+func (n *PagerDutyNotifier) Notify(ctx context.Context, msg Message) error {
+	// PagerDuty 只接受 critical, error, warning, info
+	severity := string(msg.Level)
+	if severity == "" {
+		severity = string(LevelInfo)
+	}
+
+	return postJSON(ctx, n.client, n.url, map[string]interface{}{
+		"routing_key":  n.routingKey,
+		"event_action": "trigger",
+		"payload": map[string]interface{}{
+			"summary":        msg.Title,
+			"severity":       severity,
+			"source":         "canal-pikachun",
+			"timestamp":      msg.Time.Format(time.RFC3339),
+			"custom_details": msg.Fields,
+		},
+	})
+}
@@ -0,0 +1,191 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// captureServer 记录收到的 JSON 请求体，status 为响应状态码
+func captureServer(t *testing.T, status int) (*httptest.Server, <-chan map[string]interface{}) {
+	bodies := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+		}
+		bodies <- body
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, bodies
+}
+
+func testMessage() Message {
+	return Message{
+		Level:  LevelCritical,
+		Title:  "Task 1 disabled",
+		Text:   "failing since 10:00",
+		Fields: map[string]string{"task_id": "1", "alert": "task_failed"},
+		Time:   time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+// TestWebhookNotifier 测试通用 Webhook 通知的请求体和失败状态码
+func TestWebhookNotifier(t *testing.T) {
+	server, bodies := captureServer(t, http.StatusOK)
+	if err := NewWebhookNotifier(server.URL).Notify(context.Background(), testMessage()); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	body := <-bodies
+	message, _ := body["message"].(map[string]interface{})
+	if body["source"] != "canal-pikachun" || message["title"] != "Task 1 disabled" || message["level"] != "critical" {
+		t.Errorf("unexpected body %v", body)
+	}
+
+	failing, _ := captureServer(t, http.StatusInternalServerError)
+	if err := NewWebhookNotifier(failing.URL).Notify(context.Background(), testMessage()); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("expected error for status 500, got %v", err)
+	}
+}
+
+// TestSlackNotifier 测试 Slack 通知以纯文本发送，字段按名称排列
+func TestSlackNotifier(t *testing.T) {
+	server, bodies := captureServer(t, http.StatusOK)
+	if err := NewSlackNotifier(server.URL).Notify(context.Background(), testMessage()); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	want := "[CRITICAL] Task 1 disabled\nfailing since 10:00\nalert: task_failed\ntask_id: 1\ntime: 2025-08-01T10:00:00Z\n"
+	if text := (<-bodies)["text"]; text != want {
+		t.Errorf("expected text %q, got %q", want, text)
+	}
+}
+
+// TestPagerDutyNotifier 测试 PagerDuty 事件的路由键、级别和详情
+func TestPagerDutyNotifier(t *testing.T) {
+	server, bodies := captureServer(t, http.StatusAccepted)
+	notifier := NewPagerDutyNotifier("routing-key")
+	notifier.url = server.URL
+
+	msg := testMessage()
+	msg.Level = ""
+	if err := notifier.Notify(context.Background(), msg); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	body := <-bodies
+	payload, _ := body["payload"].(map[string]interface{})
+	details, _ := payload["custom_details"].(map[string]interface{})
+	if body["routing_key"] != "routing-key" || body["event_action"] != "trigger" {
+		t.Errorf("unexpected body %v", body)
+	}
+	if payload["summary"] != "Task 1 disabled" || payload["severity"] != "info" || payload["timestamp"] != "2025-08-01T10:00:00Z" || details["task_id"] != "1" {
+		t.Errorf("unexpected payload %v", payload)
+	}
+}
+
+// TestEmailNotifier 测试通过 SMTP 发送邮件的收件人和内容
+func TestEmailNotifier(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan []string, 1)
+	go serveSMTP(listener, received)
+
+	addr := listener.Addr().(*net.TCPAddr)
+	notifier := NewEmailNotifier(config.EmailConfig{SMTPHost: "127.0.0.1", SMTPPort: addr.Port, From: "canal@example.com", To: []string{"ops@example.com"}})
+	if err := notifier.Notify(context.Background(), testMessage()); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	var lines []string
+	select {
+	case lines = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an email")
+	}
+	mail := strings.Join(lines, "\n")
+	for _, want := range []string{"RCPT TO:<ops@example.com>", "Subject: [Pikachun] Task 1 disabled", "task_id: 1"} {
+		if !strings.Contains(mail, want) {
+			t.Errorf("expected %q in the email, got %s", want, mail)
+		}
+	}
+}
+
+// serveSMTP 最小的 SMTP 服务器，接收一封邮件并返回收到的命令和内容
+func serveSMTP(listener net.Listener, received chan<- []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	var lines []string
+	reply("220 localhost ESMTP")
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		switch {
+		case inData && line == ".":
+			inData = false
+			reply("250 OK")
+			received <- lines
+		case inData:
+		case strings.HasPrefix(line, "EHLO"):
+			reply("250 localhost")
+		case strings.HasPrefix(line, "DATA"):
+			inData = true
+			reply("354 End data with <CR><LF>.<CR><LF>")
+		case strings.HasPrefix(line, "QUIT"):
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// TestMultiNotifier 测试按配置创建渠道，单个渠道失败不影响其他渠道
+func TestMultiNotifier(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	if n := New(config.NotifyConfig{}, logger); n.Enabled() {
+		t.Error("expected no channels without config")
+	}
+	n := New(config.NotifyConfig{WebhookURL: "http://localhost", SlackWebhookURL: "http://localhost", PagerDutyRoutingKey: "key",
+		Email: config.EmailConfig{SMTPHost: "localhost", To: []string{"ops@example.com"}}}, logger)
+	if name := n.Name(); name != "webhook,slack,email,pagerduty" {
+		t.Errorf("unexpected channels %q", name)
+	}
+
+	ok, failing := &fakeNotifier{}, &fakeNotifier{err: errors.New("unavailable")}
+	multi := &MultiNotifier{notifiers: []Notifier{failing, ok}, logger: logger}
+	err := multi.Notify(context.Background(), Message{Title: "t"})
+	if err == nil || !strings.Contains(err.Error(), "fake") {
+		t.Errorf("expected error naming the failed channel, got %v", err)
+	}
+	if len(ok.messages) != 1 || ok.messages[0].Time.IsZero() {
+		t.Errorf("expected the other channel notified with a timestamp, got %+v", ok.messages)
+	}
+}
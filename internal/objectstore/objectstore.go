@@ -0,0 +1,76 @@
+package objectstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pikachun/internal/config"
+)
+
+// Store 对象存储
+type Store interface {
+	// Put 写入对象，返回可访问的地址
+	Put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+}
+
+// New 根据配置创建对象存储
+func New(cfg config.ObjectStoreConfig) (Store, error) {
+	switch cfg.Type {
+	case "local":
+		return NewLocalStore(cfg.LocalDir, cfg.BaseURL)
+	case "s3":
+		return NewS3Store(cfg)
+	case "":
+		return nil, fmt.Errorf("object store not configured")
+	default:
+		return nil, fmt.Errorf("unknown object store type %q, expected local or s3", cfg.Type)
+	}
+}
+
+// LocalStore 本地目录存储
+type LocalStore struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalStore 创建本地目录存储，baseURL 为空时返回 file:// 地址
+func NewLocalStore(dir, baseURL string) (*LocalStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("local_dir is required for local object store")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local_dir: %v", err)
+	}
+	return &LocalStore{dir: abs, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// Put 写入对象
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	// 防止 key 中的 .. 写到目录之外
+	if !strings.HasPrefix(path, s.dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %v", err)
+	}
+	// 先写临时文件再改名，读取方不会看到写了一半的文件
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write object: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write object: %v", err)
+	}
+
+	if s.baseURL != "" {
+		return s.baseURL + "/" + key, nil
+	}
+	return "file://" + filepath.ToSlash(path), nil
+}
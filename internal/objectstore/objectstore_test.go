@@ -0,0 +1,86 @@
+package objectstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// TestLocalStore 测试本地目录存储
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir, "https://files.example.com/")
+	if err != nil {
+		t.Fatalf("NewLocalStore failed: %v", err)
+	}
+
+	url, err := store.Put(context.Background(), "a/b/c.txt", []byte("hello"), "text/plain")
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if url != "https://files.example.com/a/b/c.txt" {
+		t.Errorf("unexpected url %q", url)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "a", "b", "c.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Errorf("unexpected file content %q: %v", data, err)
+	}
+
+	if _, err := store.Put(context.Background(), "../escape", []byte("x"), ""); err == nil {
+		t.Errorf("expected error for key outside directory")
+	}
+}
+
+// TestS3StorePut 测试 S3 上传的地址和签名
+func TestS3StorePut(t *testing.T) {
+	var gotPath, gotAuth, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotPath, gotAuth, gotBody = r.URL.EscapedPath(), r.Header.Get("Authorization"), string(body)
+		if r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	store, err := NewS3Store(config.ObjectStoreConfig{
+		Endpoint: srv.URL, Region: "auto", Bucket: "archive", PathStyle: true,
+		AccessKey: "AKID", SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store failed: %v", err)
+	}
+	store.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	url, err := store.Put(context.Background(), "db/t 1/x.json", []byte(`{"a":1}`), "application/json")
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if gotPath != "/archive/db/t%201/x.json" || url != srv.URL+gotPath {
+		t.Errorf("unexpected path %q url %q", gotPath, url)
+	}
+	if gotBody != `{"a":1}` {
+		t.Errorf("unexpected body %q", gotBody)
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKID/20240501/auto/s3/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature=") {
+		t.Errorf("unexpected authorization %q", gotAuth)
+	}
+
+	// 非 2xx 响应返回错误
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	})
+	if _, err := store.Put(context.Background(), "x", []byte("x"), ""); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected 403 error, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Thrift compact 协议类型
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// compactWriter Thrift compact 协议编码器，只实现 Parquet 元数据需要的部分
+type compactWriter struct {
+	buf    bytes.Buffer
+	lastID []int16 // 每层结构体上一个字段ID，字段头按差值编码
+}
+
+// beginStruct 开始结构体（列表元素或顶层结构体，不写字段头）
+func (w *compactWriter) beginStruct() {
+	w.lastID = append(w.lastID, 0)
+}
+
+// endStruct 结束结构体
+func (w *compactWriter) endStruct() {
+	w.buf.WriteByte(0)
+	w.lastID = w.lastID[:len(w.lastID)-1]
+}
+
+// fieldHeader 写字段头
+func (w *compactWriter) fieldHeader(id int16, typ byte) {
+	top := len(w.lastID) - 1
+	delta := id - w.lastID[top]
+	if delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(zigzag(int64(id)))
+	}
+	w.lastID[top] = id
+}
+
+// structField 开始结构体类型的字段，需以 endStruct 结束
+func (w *compactWriter) structField(id int16) {
+	w.fieldHeader(id, compactStruct)
+	w.beginStruct()
+}
+
+// i32Field 写 i32 字段
+func (w *compactWriter) i32Field(id int16, v int32) {
+	w.fieldHeader(id, compactI32)
+	w.varint(zigzag(int64(v)))
+}
+
+// i64Field 写 i64 字段
+func (w *compactWriter) i64Field(id int16, v int64) {
+	w.fieldHeader(id, compactI64)
+	w.varint(zigzag(v))
+}
+
+// stringField 写 string 字段
+func (w *compactWriter) stringField(id int16, v string) {
+	w.fieldHeader(id, compactBinary)
+	w.str(v)
+}
+
+// listField 写列表字段头，随后写 size 个元素
+func (w *compactWriter) listField(id int16, elemType byte, size int) {
+	w.fieldHeader(id, compactList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		w.buf.WriteByte(0xF0 | elemType)
+		w.varint(uint64(size))
+	}
+}
+
+// i32 写列表中的 i32 元素
+func (w *compactWriter) i32(v int32) {
+	w.varint(zigzag(int64(v)))
+}
+
+// str 写 string 值
+func (w *compactWriter) str(v string) {
+	w.varint(uint64(len(v)))
+	w.buf.WriteString(v)
+}
+
+// varint 写无符号变长整数
+func (w *compactWriter) varint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	w.buf.Write(tmp[:n])
+}
+
+// zigzag 有符号整数的 zigzag 编码
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
@@ -0,0 +1,399 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Type 列类型
+type Type int
+
+const (
+	String          Type = iota // BYTE_ARRAY (UTF8)
+	Int64                       // INT64
+	TimestampMillis             // INT64 (TIMESTAMP_MILLIS)
+)
+
+// Column 列定义，所有列均允许为 NULL
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Parquet 格式常量
+const (
+	magic = "PAR1"
+
+	physicalInt64     = 2
+	physicalByteArray = 6
+
+	repetitionOptional = 1
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecGzip = 2
+
+	pageTypeData = 0
+)
+
+// DefaultRowGroupSize 默认每个行组的行数
+const DefaultRowGroupSize = 10000
+
+// columnBuffer 当前行组中一列的数据
+type columnBuffer struct {
+	defined []bool       // 定义级别，false 表示 NULL
+	values  bytes.Buffer // PLAIN 编码的非空值
+}
+
+// rowGroupMeta 已写出行组的元数据
+type rowGroupMeta struct {
+	numRows   int64
+	totalSize int64
+	chunks    []chunkMeta
+}
+
+// chunkMeta 已写出列块的元数据
+type chunkMeta struct {
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+// Writer 流式写入 Parquet 文件，行按行组缓冲，写满一个行组即输出，内存占用与文件大小无关
+// 每个列块只有一个 gzip 压缩的数据页，使用 PLAIN 编码
+type Writer struct {
+	out          io.Writer
+	columns      []Column
+	rowGroupSize int
+
+	offset    int64
+	buffers   []columnBuffer
+	rows      int
+	numRows   int64
+	rowGroups []rowGroupMeta
+	closed    bool
+}
+
+// NewWriter 创建写入器
+func NewWriter(out io.Writer, columns []Column) *Writer {
+	return &Writer{
+		out:          out,
+		columns:      columns,
+		rowGroupSize: DefaultRowGroupSize,
+		buffers:      make([]columnBuffer, len(columns)),
+	}
+}
+
+// SetRowGroupSize 设置每个行组的行数
+func (w *Writer) SetRowGroupSize(n int) {
+	if n > 0 {
+		w.rowGroupSize = n
+	}
+}
+
+// Write 写入一行，值按列顺序排列，nil 表示 NULL
+// String 列接受 string、[]byte，其他类型按 fmt.Sprint 转换；Int64 列接受整数；TimestampMillis 列接受 time.Time
+func (w *Writer) Write(row []interface{}) error {
+	if w.closed {
+		return fmt.Errorf("parquet writer is closed")
+	}
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("row has %d values, expected %d", len(row), len(w.columns))
+	}
+
+	for i, value := range row {
+		if err := w.appendValue(i, value); err != nil {
+			return fmt.Errorf("column %s: %v", w.columns[i].Name, err)
+		}
+	}
+	w.rows++
+
+	if w.rows >= w.rowGroupSize {
+		return w.flushRowGroup()
+	}
+	return nil
+}
+
+// appendValue 追加一个值
+func (w *Writer) appendValue(i int, value interface{}) error {
+	buf := &w.buffers[i]
+	if value == nil {
+		buf.defined = append(buf.defined, false)
+		return nil
+	}
+
+	switch w.columns[i].Type {
+	case String:
+		var data []byte
+		switch v := value.(type) {
+		case string:
+			data = []byte(v)
+		case []byte:
+			data = v
+		default:
+			data = []byte(fmt.Sprint(v))
+		}
+		binary.Write(&buf.values, binary.LittleEndian, uint32(len(data)))
+		buf.values.Write(data)
+	case Int64:
+		n, ok := toInt64(value)
+		if !ok {
+			return fmt.Errorf("unsupported int64 value %T", value)
+		}
+		binary.Write(&buf.values, binary.LittleEndian, n)
+	case TimestampMillis:
+		t, ok := value.(time.Time)
+		if !ok {
+			return fmt.Errorf("unsupported timestamp value %T", value)
+		}
+		binary.Write(&buf.values, binary.LittleEndian, t.UnixMilli())
+	}
+	buf.defined = append(buf.defined, true)
+	return nil
+}
+
+// Close 写出剩余的行和文件尾，不关闭底层 io.Writer
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.writeMagic(); err != nil {
+		return err
+	}
+	if w.rows > 0 {
+		if err := w.flushRowGroup(); err != nil {
+			return err
+		}
+	}
+	w.closed = true
+
+	footer := w.fileMetaData()
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	if err := w.write(length[:]); err != nil {
+		return err
+	}
+	return w.write([]byte(magic))
+}
+
+// writeMagic 文件开头写入魔数
+func (w *Writer) writeMagic() error {
+	if w.offset > 0 {
+		return nil
+	}
+	return w.write([]byte(magic))
+}
+
+// flushRowGroup 写出当前行组
+func (w *Writer) flushRowGroup() error {
+	if err := w.writeMagic(); err != nil {
+		return err
+	}
+
+	group := rowGroupMeta{numRows: int64(w.rows)}
+	for i := range w.columns {
+		chunk, err := w.writeColumnChunk(&w.buffers[i])
+		if err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		group.totalSize += chunk.uncompressedSize
+		w.buffers[i] = columnBuffer{}
+	}
+
+	w.rowGroups = append(w.rowGroups, group)
+	w.numRows += int64(w.rows)
+	w.rows = 0
+	return nil
+}
+
+// writeColumnChunk 以单个数据页写出一列
+func (w *Writer) writeColumnChunk(buf *columnBuffer) (chunkMeta, error) {
+	// 数据页：定义级别（4 字节长度 + RLE）后接非空值
+	levels := encodeLevels(buf.defined)
+	var page bytes.Buffer
+	binary.Write(&page, binary.LittleEndian, uint32(len(levels)))
+	page.Write(levels)
+	page.Write(buf.values.Bytes())
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(page.Bytes()); err != nil {
+		return chunkMeta{}, fmt.Errorf("failed to compress page: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return chunkMeta{}, fmt.Errorf("failed to compress page: %v", err)
+	}
+
+	header := pageHeader(len(buf.defined), page.Len(), compressed.Len())
+	chunk := chunkMeta{
+		offset:           w.offset,
+		uncompressedSize: int64(len(header) + page.Len()),
+		compressedSize:   int64(len(header) + compressed.Len()),
+	}
+	if err := w.write(header); err != nil {
+		return chunkMeta{}, err
+	}
+	if err := w.write(compressed.Bytes()); err != nil {
+		return chunkMeta{}, err
+	}
+	return chunk, nil
+}
+
+// write 写入并记录偏移量
+func (w *Writer) write(data []byte) error {
+	n, err := w.out.Write(data)
+	w.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write parquet data: %v", err)
+	}
+	return nil
+}
+
+// pageHeader 编码数据页头
+func pageHeader(numValues, uncompressedSize, compressedSize int) []byte {
+	var t compactWriter
+	t.beginStruct()
+	t.i32Field(1, pageTypeData)
+	t.i32Field(2, int32(uncompressedSize))
+	t.i32Field(3, int32(compressedSize))
+	t.structField(5)
+	t.i32Field(1, int32(numValues))
+	t.i32Field(2, encodingPlain)
+	t.i32Field(3, encodingRLE)
+	t.i32Field(4, encodingRLE)
+	t.endStruct()
+	t.endStruct()
+	return t.buf.Bytes()
+}
+
+// fileMetaData 编码文件元数据
+func (w *Writer) fileMetaData() []byte {
+	var t compactWriter
+	t.beginStruct()
+	t.i32Field(1, 1)
+
+	// schema：根节点后接各列
+	t.listField(2, compactStruct, len(w.columns)+1)
+	t.beginStruct()
+	t.stringField(4, "schema")
+	t.i32Field(5, int32(len(w.columns)))
+	t.endStruct()
+	for _, col := range w.columns {
+		t.beginStruct()
+		physical, converted := physicalType(col.Type)
+		t.i32Field(1, physical)
+		t.i32Field(3, repetitionOptional)
+		t.stringField(4, col.Name)
+		if converted >= 0 {
+			t.i32Field(6, converted)
+		}
+		t.endStruct()
+	}
+
+	t.i64Field(3, w.numRows)
+
+	t.listField(4, compactStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		t.beginStruct()
+		t.listField(1, compactStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			col := w.columns[i]
+			physical, _ := physicalType(col.Type)
+			t.beginStruct()
+			t.i64Field(2, chunk.offset)
+			t.structField(3)
+			t.i32Field(1, physical)
+			t.listField(2, compactI32, 2)
+			t.i32(encodingPlain)
+			t.i32(encodingRLE)
+			t.listField(3, compactBinary, 1)
+			t.str(col.Name)
+			t.i32Field(4, codecGzip)
+			t.i64Field(5, group.numRows)
+			t.i64Field(6, chunk.uncompressedSize)
+			t.i64Field(7, chunk.compressedSize)
+			t.i64Field(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64Field(2, group.totalSize)
+		t.i64Field(3, group.numRows)
+		t.endStruct()
+	}
+
+	t.stringField(6, "pikachun")
+	t.endStruct()
+	return t.buf.Bytes()
+}
+
+// physicalType 列类型对应的物理类型和转换类型，转换类型为 -1 表示没有
+func physicalType(typ Type) (int32, int32) {
+	switch typ {
+	case Int64:
+		return physicalInt64, -1
+	case TimestampMillis:
+		return physicalInt64, convertedTimestampMillis
+	default:
+		return physicalByteArray, convertedUTF8
+	}
+}
+
+// encodeLevels 以 RLE/bit-packing 混合编码（位宽 1）编码定义级别，只使用 RLE 游程
+func encodeLevels(defined []bool) []byte {
+	var buf bytes.Buffer
+	var tmp [binary.MaxVarintLen64]byte
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		n := binary.PutUvarint(tmp[:], uint64(j-i)<<1)
+		buf.Write(tmp[:n])
+		if defined[i] {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		i = j
+	}
+	return buf.Bytes()
+}
+
+// toInt64 整数转换为 int64
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,155 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// compactReader 测试用的 Thrift compact 解码器，结构体解码为 字段ID -> 值
+type compactReader struct {
+	r *bytes.Reader
+}
+
+func (c *compactReader) varint() int64 {
+	v, _ := binary.ReadUvarint(c.r)
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (c *compactReader) value(typ byte) interface{} {
+	switch typ {
+	case compactI32, compactI64:
+		return c.varint()
+	case compactBinary:
+		n, _ := binary.ReadUvarint(c.r)
+		data := make([]byte, n)
+		io.ReadFull(c.r, data)
+		return string(data)
+	case compactList:
+		header, _ := c.r.ReadByte()
+		size := int(header >> 4)
+		if size == 15 {
+			n, _ := binary.ReadUvarint(c.r)
+			size = int(n)
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = c.value(header & 0x0F)
+		}
+		return list
+	case compactStruct:
+		return c.structValue()
+	}
+	panic(fmt.Sprintf("unsupported type %d", typ))
+}
+
+func (c *compactReader) structValue() map[int]interface{} {
+	fields := make(map[int]interface{})
+	last := 0
+	for {
+		header, _ := c.r.ReadByte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int(header>>4)
+		if header>>4 == 0 {
+			id = int(c.varint())
+		}
+		fields[id] = c.value(header & 0x0F)
+		last = id
+	}
+}
+
+// TestWriterRoundTrip 测试写出的文件结构和数据可以被解析
+func TestWriterRoundTrip(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out, []Column{
+		{Name: "id", Type: Int64},
+		{Name: "name", Type: String},
+		{Name: "created_at", Type: TimestampMillis},
+	})
+	w.SetRowGroupSize(2)
+
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	rows := [][]interface{}{
+		{int64(1), "alice", ts},
+		{2, nil, ts},
+		{uint32(3), []byte("carol"), nil},
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Write([]interface{}{1}); err == nil {
+		t.Errorf("expected error for short row")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data := out.Bytes()
+	if string(data[:4]) != magic || string(data[len(data)-4:]) != magic {
+		t.Fatalf("missing magic bytes")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := data[len(data)-8-footerLen : len(data)-8]
+	meta := (&compactReader{r: bytes.NewReader(footer)}).structValue()
+
+	if meta[3] != int64(3) {
+		t.Errorf("expected 3 rows, got %v", meta[3])
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != 4 || schema[2].(map[int]interface{})[4] != "name" {
+		t.Errorf("unexpected schema %v", schema)
+	}
+	groups := meta[4].([]interface{})
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 row groups, got %d", len(groups))
+	}
+
+	// 解析第一个行组的 name 列：alice, NULL
+	chunk := groups[0].(map[int]interface{})[1].([]interface{})[1].(map[int]interface{})
+	offset := chunk[3].(map[int]interface{})[9].(int64)
+	page := bytes.NewReader(data[offset:])
+	header := (&compactReader{r: page}).structValue()
+	if header[5].(map[int]interface{})[1] != int64(2) {
+		t.Errorf("expected 2 values in page, got %v", header)
+	}
+	compressed := make([]byte, header[3].(int64))
+	io.ReadFull(page, compressed)
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("invalid gzip page: %v", err)
+	}
+	body, _ := io.ReadAll(gz)
+
+	levelsLen := binary.LittleEndian.Uint32(body)
+	levels := body[4 : 4+levelsLen]
+	// 两个游程：1 个非空，1 个空
+	if !bytes.Equal(levels, []byte{2, 1, 2, 0}) {
+		t.Errorf("unexpected definition levels %v", levels)
+	}
+	values := body[4+levelsLen:]
+	if n := binary.LittleEndian.Uint32(values); string(values[4:4+n]) != "alice" || len(values) != int(4+n) {
+		t.Errorf("unexpected values %q", values)
+	}
+}
+
+// TestWriterEmpty 测试没有数据行时也能写出合法文件
+func TestWriterEmpty(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out, []Column{{Name: "id", Type: Int64}})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	data := out.Bytes()
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if string(data[:4]) != magic || 4+footerLen+8 != len(data) {
+		t.Errorf("unexpected empty file layout: %d bytes, footer %d", len(data), footerLen)
+	}
+}
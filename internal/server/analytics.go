@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pikachun/internal/service"
+)
+
+// defaultAnalyticsWindow 变更分析默认的时间窗口
+const defaultAnalyticsWindow = 24 * time.Hour
+
+// parseAnalyticsQuery 解析变更分析的时间窗口和任务ID，窗口最长为统计的保留时长
+func parseAnalyticsQuery(c *gin.Context) (window time.Duration, taskID uint, ok bool) {
+	window = defaultAnalyticsWindow
+	if value := c.Query("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > service.TableChangeRetention {
+			respondError(c, ErrCodeInvalidRequest, tr(c, "无效的时间窗口 %s，最长为 %v", value, service.TableChangeRetention))
+			return 0, 0, false
+		}
+		window = parsed
+	}
+	if value := c.Query("task_id"); value != "" {
+		parsed, err := parseUintDefault(value, 0)
+		if err != nil || parsed == 0 {
+			respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+			return 0, 0, false
+		}
+		taskID = parsed
+	}
+	return window, taskID, true
+}
+
+// hottestTablesHandler 时间窗口内变更最多的表，统计来自进入事件接收器的事件
+//
+//	@Summary		变更最多的表
+//	@Description	按进入事件接收器的 binlog 事件，每 5 分钟统计各表的 INSERT、UPDATE、DELETE 行数，返回时间窗口内变更最多的表及平均和峰值的每分钟变更数。只统计任务监听的表（含全局监听策略的表），统计每个健康检查周期写入一次。
+//	@Tags			analytics
+//	@ID				getHottestTables
+//	@Produce		json
+//	@Param			window	query		string																	false	"时间窗口，如 1h、24h，默认 24h，最长 168h（统计保留 7 天）"
+//	@Param			task_id	query		int																		false	"只统计该任务读取到的事件，不传表示所有任务（同一张表被多个任务监听时不重复计算）"	minimum(1)
+//	@Param			limit	query		int																		false	"最多返回的表数，默认 10，最大 100"						minimum(1)	maximum(100)
+//	@Success		200		{object}	object{data=object{window=string,tables=[]service.TableChangeSummary}}	"变更最多的表"
+//	@Failure		400		{object}	APIError																"无效的时间窗口或任务ID"
+//	@Failure		500		{object}	APIError																"查询变更统计失败"
+//	@Router			/analytics/tables [get]
+func (s *Server) hottestTablesHandler(c *gin.Context) {
+	window, taskID, ok := parseAnalyticsQuery(c)
+	if !ok {
+		return
+	}
+	limit := 10
+	if value := c.Query("limit"); value != "" {
+		if parsed, _ := parseIntDefault(value, limit); parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	tables, err := s.taskService.HottestTables(taskID, window, limit)
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "查询变更统计失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"window": window.String(),
+			"tables": tables,
+		},
+	})
+}
+
+// tableChangeSeriesHandler 一张表在时间窗口内每 5 分钟的变更数
+//
+//	@Summary		表的变更趋势
+//	@Description	一张表在时间窗口内每 5 分钟的行变更数，按时间顺序，没有变更的时间段不返回。
+//	@Tags			analytics
+//	@ID				getTableChangeSeries
+//	@Produce		json
+//	@Param			database	path		string																								true	"数据库名"
+//	@Param			table		path		string																								true	"表名"
+//	@Param			window		query		string																								false	"时间窗口，如 1h、24h，默认 24h，最长 168h（统计保留 7 天）"
+//	@Param			task_id		query		int																									false	"只统计该任务读取到的事件，不传表示所有任务（同一张表被多个任务监听时不重复计算）"	minimum(1)
+//	@Success		200			{object}	object{data=object{database=string,table=string,window=string,points=[]service.TableChangePoint}}	"各时间段的变更数"
+//	@Failure		400			{object}	APIError																							"无效的时间窗口或任务ID"
+//	@Failure		500			{object}	APIError																							"查询变更统计失败"
+//	@Router			/analytics/tables/{database}/{table} [get]
+func (s *Server) tableChangeSeriesHandler(c *gin.Context) {
+	window, taskID, ok := parseAnalyticsQuery(c)
+	if !ok {
+		return
+	}
+
+	points, err := s.taskService.TableChangeSeries(taskID, c.Param("database"), c.Param("table"), window)
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "查询变更统计失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"database": c.Param("database"),
+			"table":    c.Param("table"),
+			"window":   window.String(),
+			"points":   points,
+		},
+	})
+}
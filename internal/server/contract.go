@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/database"
+)
+
+// taskContractResponse 契约接口的返回内容
+type taskContractResponse struct {
+	TaskID    uint            `json:"task_id"`
+	Version   int             `json:"version"`                       // 每次登记加一
+	Contract  json.RawMessage `json:"contract" swaggertype:"object"` // 登记的原始定义
+	UpdatedAt time.Time       `json:"updated_at" format:"date-time"`
+} // @name TaskContract
+
+// contractResponse 契约接口的返回内容，contract 为登记的原始定义
+func contractResponse(contract *database.TaskContract) taskContractResponse {
+	return taskContractResponse{
+		TaskID:    contract.TaskID,
+		Version:   contract.Version,
+		Contract:  json.RawMessage(contract.Definition),
+		UpdatedAt: contract.UpdatedAt,
+	}
+}
+
+// getTaskContractHandler 获取任务的消费端契约
+//
+//	@Summary	获取任务的消费端契约
+//	@Tags		tasks
+//	@ID			getTaskContract
+//	@Produce	json
+//	@Param		id	path		int																								true	"任务ID"	minimum(1)
+//	@Success	200	{object}	object{data=taskContractResponse{contract=canal.ConsumerContract}}	"当前登记的契约"
+//	@Failure	400	{object}	APIError																						"无效的任务ID"
+//	@Failure	404	{object}	APIError																						"任务没有登记消费端契约"
+//	@Failure	500	{object}	APIError																						"获取消费端契约失败"
+//	@Router		/tasks/{id}/contract [get]
+func (s *Server) getTaskContractHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	contract, err := s.taskService.GetTaskContract(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, ErrCodeNotFound, tr(c, "任务没有登记消费端契约"))
+			return
+		}
+		respondError(c, ErrCodeInternal, tr(c, "获取消费端契约失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": contractResponse(contract),
+	})
+}
+
+// putTaskContractHandler 登记或替换任务的消费端契约，请求体为契约定义，对运行中的任务立即生效
+//
+//	@Summary		登记或替换任务的消费端契约
+//	@Description	消费端登记期望的载荷结构，对运行中的任务立即生效，每次登记版本号加一。投递前按契约校验事件实际发送的载荷中的 before_data 和 after_data（经过 DELETE 投递方式、数值安全编码和载荷映射转换，列名为映射后的名称，类型为 JSON 中的类型），不符合契约的事件不投递，在事件日志中记为 failed，error 字段给出违约的列和原因；修正契约或消费端后可通过 POST /logs/{id}/redeliver 重新投递，重新投递时同样按当前契约校验。
+//	@Tags			tasks
+//	@ID				putTaskContract
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int																								true	"任务ID"	minimum(1)
+//	@Param			request	body		canal.ConsumerContract																			true	"请求体"
+//	@Success		200		{object}	object{data=taskContractResponse{contract=canal.ConsumerContract}}	"契约已登记"
+//	@Failure		400		{object}	APIError																						"无效的任务ID（invalid_request）"
+//	@Failure		404		{object}	APIError																						"任务不存在"
+//	@Failure		422		{object}	APIError																						"无效的消费端契约（validation_failed）"
+//	@Failure		500		{object}	APIError																						"保存消费端契约失败"
+//	@Router			/tasks/{id}/contract [put]
+func (s *Server) putTaskContractHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+		return
+	}
+	if parsed, err := canal.ParseConsumerContract(string(body)); err != nil || parsed == nil {
+		if err == nil {
+			err = errors.New("empty contract")
+		}
+		respondError(c, ErrCodeValidationFailed, tr(c, "无效的消费端契约: %v", err))
+		return
+	}
+
+	contract, err := s.taskService.SaveTaskContract(id, string(body))
+	if err != nil {
+		respondTaskError(c, err, "保存消费端契约失败: %v")
+		return
+	}
+
+	if !s.applyTaskContract(c, id) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":    contractResponse(contract),
+		"message": tr(c, "消费端契约已登记"),
+	})
+}
+
+// deleteTaskContractHandler 删除任务的消费端契约，之后的事件不再校验
+//
+//	@Summary	删除任务的消费端契约
+//	@Tags		tasks
+//	@ID			deleteTaskContract
+//	@Produce	json
+//	@Param		id	path		int						true	"任务ID"	minimum(1)
+//	@Success	200	{object}	object{message=string}	"契约已删除，之后的事件不再校验"
+//	@Failure	400	{object}	APIError				"无效的任务ID"
+//	@Failure	500	{object}	APIError				"删除消费端契约失败"
+//	@Router		/tasks/{id}/contract [delete]
+func (s *Server) deleteTaskContractHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	if err := s.taskService.DeleteTaskContract(id); err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "删除消费端契约失败: %v", err))
+		return
+	}
+
+	if !s.applyTaskContract(c, id) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "消费端契约已删除"),
+	})
+}
+
+// applyTaskContract 将契约应用到运行中的实例，失败时写入错误响应并返回 false
+func (s *Server) applyTaskContract(c *gin.Context, id uint) bool {
+	if s.enhancedHandlers == nil {
+		return true
+	}
+	if err := s.enhancedHandlers.enhancedCanalService.ApplyTaskContract(id); err != nil {
+		respondError(c, ErrCodeInstanceFailed, tr(c, "更新Canal任务失败: %v", err))
+		return false
+	}
+	return true
+}
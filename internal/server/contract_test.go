@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"pikachun/internal/config"
+	"pikachun/internal/database"
+)
+
+// TestTaskContractRoutes 测试消费端契约的登记、查询、替换与删除
+func TestTaskContractRoutes(t *testing.T) {
+	s, taskService := newTestServer(t, &config.Config{}, &fakeCanalService{})
+
+	task := &database.Task{Name: "orders", Database: "shop", Table: "orders", EventTypes: "INSERT", CallbackURL: "http://localhost/hook"}
+	if err := taskService.CreateTask(task); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		s.router.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+	path := "/api/v1/tasks/" + strconv.FormatUint(uint64(task.ID), 10) + "/contract"
+	contract := `{"tables":{"shop.orders":{"columns":{"id":{"type":"integer","required":true}}}}}`
+
+	if code, _ := do(http.MethodGet, path, ""); code != http.StatusNotFound {
+		t.Errorf("expected 404 before a contract is registered, got %d", code)
+	}
+	if code, _ := do(http.MethodPut, path, `{"tables":{"shop.orders":{"columns":{"id":{"type":"uuid"}}}}}`); code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an invalid contract, got %d", code)
+	}
+	if code, _ := do(http.MethodPut, "/api/v1/tasks/999/contract", contract); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown task, got %d", code)
+	}
+
+	for version := 1; version <= 2; version++ {
+		code, resp := do(http.MethodPut, path, contract)
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %v", code, resp)
+		}
+		if got := resp["data"].(map[string]interface{})["version"]; got != float64(version) {
+			t.Errorf("expected version %d, got %v", version, got)
+		}
+	}
+
+	code, resp := do(http.MethodGet, path, "")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	if _, ok := resp["data"].(map[string]interface{})["contract"].(map[string]interface{})["tables"]; !ok {
+		t.Errorf("expected the registered definition, got %v", resp)
+	}
+
+	if code, _ := do(http.MethodDelete, path, ""); code != http.StatusOK {
+		t.Errorf("expected 200 on delete, got %d", code)
+	}
+	if code, _ := do(http.MethodGet, path, ""); code != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", code)
+	}
+}
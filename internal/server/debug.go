@@ -0,0 +1,112 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"pikachun/internal/canal"
+)
+
+// registerDebugRoutes 注册 pprof 和运行时调试路由，需要管理员认证，未配置管理员令牌时不注册
+func (s *Server) registerDebugRoutes() {
+	token := s.config.Server.AdminToken
+	if token == "" {
+		return
+	}
+
+	debugGroup := s.router.Group("/debug", adminAuthMiddleware(token))
+	debugGroup.GET("/pprof/*profile", pprofHandler)
+	debugGroup.POST("/pprof/symbol", gin.WrapF(pprof.Symbol))
+	debugGroup.GET("/goroutines", goroutineDumpHandler)
+	debugGroup.GET("/faults", listFaultsHandler)
+	debugGroup.POST("/faults", enableFaultHandler)
+	debugGroup.DELETE("/faults", resetFaultsHandler)
+	debugGroup.DELETE("/faults/:kind", disableFaultHandler)
+	if s.enhancedHandlers != nil {
+		debugGroup.GET("/instances", s.enhancedHandlers.debugInstancesHandler)
+	}
+
+	log.Printf("🔧 Debug endpoints enabled at /debug (admin auth required)")
+}
+
+// pprofHandler 按路径分发到 net/http/pprof 的处理器，/debug/pprof/ 列出所有 profile
+func pprofHandler(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("profile"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// 首页及 goroutine、heap 等命名 profile 由 Index 按路径处理
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+// goroutineDumpHandler 输出所有协程的堆栈，默认逐个输出完整堆栈（debug=2）；
+// debug=1 时按堆栈聚合并显示 canal_instance 标签，便于定位某个实例的协程
+func goroutineDumpHandler(c *gin.Context) {
+	if c.Query("debug") == "" {
+		c.Request.URL.RawQuery = "debug=2"
+	}
+	pprof.Handler("goroutine").ServeHTTP(c.Writer, c.Request)
+}
+
+// listFaultsHandler 列出已启用的故障和支持的故障类型
+func listFaultsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data":  canal.Faults.List(),
+		"kinds": canal.FaultKinds,
+	})
+}
+
+// enableFaultHandler 启用故障，同类型的已有配置被替换
+func enableFaultHandler(c *gin.Context) {
+	var req canal.Fault
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+		return
+	}
+
+	fault, err := canal.Faults.Enable(req)
+	if err != nil {
+		respondError(c, ErrCodeValidationFailed, tr(c, "故障配置无效: %v", err))
+		return
+	}
+
+	log.Printf("💥 Fault %s enabled (target: %q)", fault.Kind, fault.Target)
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "故障已启用"),
+		"data":    fault,
+	})
+}
+
+// disableFaultHandler 关闭指定类型的故障
+func disableFaultHandler(c *gin.Context) {
+	kind := canal.FaultKind(c.Param("kind"))
+	if !canal.Faults.Disable(kind) {
+		respondError(c, ErrCodeNotFound, tr(c, "故障未启用"))
+		return
+	}
+
+	log.Printf("🔧 Fault %s disabled", kind)
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "故障已关闭"),
+	})
+}
+
+// resetFaultsHandler 关闭所有故障
+func resetFaultsHandler(c *gin.Context) {
+	canal.Faults.Reset()
+	log.Printf("🔧 All faults disabled")
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "所有故障已关闭"),
+	})
+}
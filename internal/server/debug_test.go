@@ -0,0 +1,106 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/config"
+)
+
+// newDebugTestServer 创建只注册调试路由的测试服务器
+func newDebugTestServer(token string) *Server {
+	gin.SetMode(gin.TestMode)
+	s := &Server{config: &config.Config{Server: config.ServerConfig{AdminToken: token}}}
+	s.router = gin.New()
+	s.router.Use(requestIDMiddleware())
+	s.registerDebugRoutes()
+	return s
+}
+
+// TestDebugRoutesRequireAdminAuth 测试调试接口的管理员认证
+func TestDebugRoutesRequireAdminAuth(t *testing.T) {
+	s := newDebugTestServer("secret")
+
+	get := func(path, auth string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, auth := range []string{"", "Bearer wrong", "secret", "Basic c2VjcmV0"} {
+		if w := get("/debug/pprof/", auth); w.Code != http.StatusUnauthorized {
+			t.Errorf("auth %q: expected 401, got %d", auth, w.Code)
+		}
+	}
+
+	w := get("/debug/pprof/", "Bearer secret")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("expected pprof index, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = get("/debug/pprof/heap?debug=1", "Bearer secret")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "heap profile") {
+		t.Errorf("expected heap profile, got %d", w.Code)
+	}
+
+	w = get("/debug/goroutines", "Bearer secret")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine ") {
+		t.Errorf("expected goroutine dump, got %d", w.Code)
+	}
+
+	// 未配置管理员令牌时不开放调试接口
+	s = newDebugTestServer("")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without admin token, got %d", w.Code)
+	}
+}
+
+// TestFaultRoutes 测试通过管理接口开关故障
+func TestFaultRoutes(t *testing.T) {
+	defer canal.Faults.Reset()
+	s := newDebugTestServer("secret")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", "application/json")
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/debug/faults", `{"kind":"delay_delivery"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for missing delay, got %d", w.Code)
+	}
+
+	w := do(http.MethodPost, "/debug/faults", `{"kind":"delay_delivery","delay_ms":500,"target":"webhook-1"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if fault, ok := canal.Faults.Trigger(canal.FaultDelayDelivery, "webhook-1"); !ok || fault.DelayMs != 500 {
+		t.Errorf("expected delay fault to be enabled, got %+v", fault)
+	}
+
+	w = do(http.MethodGet, "/debug/faults", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"triggered":1`) {
+		t.Errorf("expected fault in list, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := do(http.MethodDelete, "/debug/faults/delay_delivery", ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/debug/faults/delay_delivery", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for disabled fault, got %d", w.Code)
+	}
+}
@@ -1,10 +1,14 @@
 package server
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"pikachun/internal/canal"
 	"pikachun/internal/service"
 )
 
@@ -24,9 +28,7 @@ func NewEnhancedHandlers(enhancedCanalService *service.EnhancedCanalService) *En
 func (h *EnhancedHandlers) getBinlogInfoHandler(c *gin.Context) {
 	info, err := h.enhancedCanalService.GetBinlogInfo()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "获取binlog信息失败: " + err.Error(),
-		})
+		respondError(c, ErrCodeInternal, tr(c, "获取binlog信息失败: %v", err))
 		return
 	}
 
@@ -52,3 +54,768 @@ func (h *EnhancedHandlers) getPerformanceMetricsHandler(c *gin.Context) {
 		"data": metrics,
 	})
 }
+
+// simulateTaskEventHandler 向任务注入模拟事件，用于下游的端到端测试
+//
+//	@Summary		注入模拟事件
+//	@Description	构造一个行变更事件注入任务的事件接收器，与 binlog 事件经过相同的处理流程（Webhook、事件日志等），用于在不改动源库的情况下对下游做端到端测试。事件ID以 simulate 来源生成，与 binlog 事件区分。
+//	@Tags			tasks
+//	@ID				simulateTaskEvent
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int									true	"任务ID"	minimum(1)
+//	@Param			request	body		SimulateEventRequest				true	"请求体"
+//	@Success		202		{object}	object{message=string,data=object}	"已注入"
+//	@Failure		400		{object}	APIError							"请求参数错误"
+//	@Failure		422		{object}	APIError							"模拟事件无效（validation_failed）"
+//	@Failure		500		{object}	APIError							"注入失败（实例不存在或未运行）"
+//	@Router			/tasks/{id}/simulate [post]
+func (h *EnhancedHandlers) simulateTaskEventHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	var req SimulateEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+		return
+	}
+
+	sim := canal.SimulatedEvent{
+		EventType: canal.EventType(req.EventType),
+		Schema:    req.Schema,
+		Table:     req.Table,
+		Before:    req.Before,
+		After:     req.After,
+	}
+	if err := sim.Validate(); err != nil {
+		respondError(c, ErrCodeValidationFailed, tr(c, "请求参数错误: %v", err))
+		return
+	}
+
+	event, err := h.enhancedCanalService.SimulateEvent(id, sim)
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "注入模拟事件失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": tr(c, "模拟事件已注入"),
+		"data":    event,
+	})
+}
+
+// debugInstancesHandler 各实例的事件通道深度、处理器队列深度和协程数
+func (h *EnhancedHandlers) debugInstancesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": h.enhancedCanalService.DebugInstances(),
+	})
+}
+
+// taskRestartsHandler 停滞检测触发的 binlog 流重启记录
+//
+//	@Summary	停滞检测触发的 binlog 流重启记录
+//	@Tags		tasks
+//	@ID			listTaskRestarts
+//	@Produce	json
+//	@Param		id	path		int									true	"任务ID"	minimum(1)
+//	@Success	200	{object}	object{data=[]canal.StreamRestart}	"重启记录，按时间先后排列"
+//	@Failure	400	{object}	APIError							"无效的任务ID"
+//	@Failure	404	{object}	APIError							"任务实例不存在"
+//	@Router		/tasks/{id}/restarts [get]
+func (h *EnhancedHandlers) taskRestartsHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	restarts, err := h.enhancedCanalService.TaskRestarts(id)
+	if err != nil {
+		respondError(c, ErrCodeNotFound, tr(c, "获取重启记录失败: %v", err))
+		return
+	}
+	if restarts == nil {
+		restarts = []canal.StreamRestart{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": restarts,
+	})
+}
+
+// logStreamKeepalive 日志流空闲时发送注释行的间隔
+const logStreamKeepalive = 15 * time.Second
+
+// streamTaskLogsHandler 以 SSE 推送任务实例的日志：先发送最近的日志，之后实时推送新行，直到客户端断开
+//
+//	@Summary		实时推送任务实例的日志（SSE）
+//	@Description	以 Server-Sent Events 推送任务实例和处理器输出的日志：连接后先发送最近的日志（每个任务保留最近 500 行），之后实时推送新行，直到客户端断开。每行是一个 `log` 事件，data 为 LogEntry；空闲时每 15 秒发送一个注释行保持连接。客户端跟不上时丢弃新行，不阻塞日志输出。
+//	@Tags			tasks
+//	@ID				streamTaskLogs
+//	@Produce		text/event-stream,application/json
+//	@Param			id	path		int				true	"任务ID"	minimum(1)
+//	@Success		200	{object}	logstream.Entry	"日志事件流"
+//	@Failure		400	{object}	APIError		"无效的任务ID"
+//	@Failure		404	{object}	APIError		"任务不存在"
+//	@Router			/tasks/{id}/logs/stream [get]
+func (h *EnhancedHandlers) streamTaskLogsHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	recent, entries, cancel, err := h.enhancedCanalService.SubscribeTaskLogs(id)
+	if err != nil {
+		respondError(c, ErrCodeNotFound, tr(c, "任务不存在"))
+		return
+	}
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	// 经过 nginx 等反向代理时不缓冲响应
+	c.Header("X-Accel-Buffering", "no")
+	for _, entry := range recent {
+		c.SSEvent("log", entry)
+	}
+	c.Writer.Flush()
+
+	// 定时发送注释行，避免空闲连接被代理断开
+	keepalive := time.NewTicker(logStreamKeepalive)
+	defer keepalive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case entry, ok := <-entries:
+			// 任务已删除，日志流结束
+			if !ok {
+				return false
+			}
+			c.SSEvent("log", entry)
+			return true
+		case <-keepalive.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// taskChecksumHandler 事件校验和设置、源库使用的算法和被跳过的损坏事件
+//
+//	@Summary	事件校验和设置、源库使用的算法和被跳过的损坏事件
+//	@Tags		tasks
+//	@ID			getTaskChecksum
+//	@Produce	json
+//	@Param		id	path		int									true	"任务ID"	minimum(1)
+//	@Success	200	{object}	object{data=canal.ChecksumStatus}	"校验和状态，skipped 中为按 on_mismatch=skip 跳过的损坏事件（审计记录）"
+//	@Failure	400	{object}	APIError							"无效的任务ID"
+//	@Failure	404	{object}	APIError							"任务实例不存在"
+//	@Router		/tasks/{id}/checksum [get]
+func (h *EnhancedHandlers) taskChecksumHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	status, err := h.enhancedCanalService.TaskChecksum(id)
+	if err != nil {
+		respondError(c, ErrCodeNotFound, tr(c, "获取校验和状态失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": status,
+	})
+}
+
+// taskQualityHandler 任务的数据质量报告，统计从实例启动时开始
+//
+//	@Summary	任务的数据质量报告：各规则的违规数和最近的违规事件
+//	@Tags		tasks
+//	@ID			getTaskQualityReport
+//	@Produce	json
+//	@Param		id	path		int									true	"任务ID"	minimum(1)
+//	@Success	200	{object}	object{data=canal.QualityReport}	"数据质量报告"
+//	@Failure	400	{object}	APIError							"无效的任务ID"
+//	@Failure	404	{object}	APIError							"任务实例不存在"
+//	@Router		/tasks/{id}/quality [get]
+func (h *EnhancedHandlers) taskQualityHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	report, err := h.enhancedCanalService.QualityReport(id)
+	if err != nil {
+		respondError(c, ErrCodeNotFound, tr(c, "获取数据质量报告失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": report,
+	})
+}
+
+// sourceTablesHandler 列出源库中的表及其行数和 binlog 活跃度，帮助选择要监听的表
+//
+//	@Summary		列出源库中的表及其 binlog 活跃度
+//	@Description	返回源库中的表（不含系统库和视图），附带 information_schema 中的估算行数、运行中实例从 TableMapEvent 统计的 binlog 活跃度以及是否已被任务监听，按近期活跃度从高到低排序，帮助选择要监听的表。没有运行中的实例时活跃度为空。
+//	@Tags			sources
+//	@ID				listSourceTables
+//	@Produce		json
+//	@Param			id		path		string								true	"数据源ID，目前只有 default（canal 配置的 MySQL）"
+//	@Param			pattern	query		string								false	"库.表 的匹配规则，支持 * 和 ? 通配符，如 shop.order_*，不传时列出所有表"	default(*.*)
+//	@Success		200		{object}	object{data=[]canal.SourceTableInfo}	"源库中的表"
+//	@Failure		400		{object}	APIError							"无效的表规则"
+//	@Failure		404		{object}	APIError							"数据源不存在"
+//	@Failure		500		{object}	APIError							"查询源库失败"
+//	@Router			/sources/{id}/tables [get]
+func (h *EnhancedHandlers) sourceTablesHandler(c *gin.Context) {
+	// 目前只有 canal 配置的一个源库
+	if c.Param("id") != defaultSourceID {
+		respondError(c, ErrCodeNotFound, tr(c, "数据源不存在"))
+		return
+	}
+
+	// 不传规则时列出所有表
+	pattern := c.Query("pattern")
+	if pattern == "" {
+		pattern = canal.AllTablesPattern
+	}
+	if err := canal.ValidateTablePattern(pattern); err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的库表规则: %v", err))
+		return
+	}
+
+	tables, err := h.enhancedCanalService.SourceTables(pattern)
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "获取源库表失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": tables,
+	})
+}
+
+// sourcePreflightHandler 检查源库能否读取 tables 参数中各表的 binlog，未通过的项给出处理方法
+//
+//	@Summary		源库预检
+//	@Description	检查配置的账号能否读取指定表的 binlog：REPLICATION SLAVE 和 REPLICATION CLIENT 权限、各表的 SELECT 权限（通配规则需要整库权限）、log_bin、binlog_format=ROW、binlog_row_image=FULL（warning）、binlog 保留时间不短于 canal.min_binlog_retention（warning）以及 canal.server_id 是否与源库或其他从库冲突。未通过的项在 message 中给出原因和处理方法。启用 canal.preflight 时创建任务前会自动执行，error 级检查未通过时拒绝创建。 实例启动时也会检查 binlog 配置，未开启 binlog 或不是 ROW 格式时拒绝启动，任务记录错误原因，实例状态的 alert 为 binlog_settings。
+//	@Tags			sources
+//	@ID				sourcePreflight
+//	@Produce		json
+//	@Param			id		path		string								true	"数据源ID，目前只有 default（canal 配置的 MySQL）"
+//	@Param			tables	query		string								false	"逗号分隔的 库.表，如 shop.orders,shop.order_*"
+//	@Success		200		{object}	object{data=canal.PreflightReport}	"预检报告"
+//	@Failure		404		{object}	APIError							"数据源不存在"
+//	@Router			/sources/{id}/preflight [get]
+func (h *EnhancedHandlers) sourcePreflightHandler(c *gin.Context) {
+	if c.Param("id") != defaultSourceID {
+		respondError(c, ErrCodeNotFound, tr(c, "数据源不存在"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": h.enhancedCanalService.Preflight(canal.SplitList(c.Query("tables"))),
+	})
+}
+
+// sourcePositionHandler 查找 at 参数（RFC3339）时间点对应的 binlog 位置，可用于设置任务的读取位置以从该时间点重放
+//
+//	@Summary		查找时间点对应的 binlog 位置
+//	@Description	按 SHOW BINARY LOGS 中各文件第一个事件的时间二分查找时间点所在的文件，再逐个事件扫描，返回第一个时间戳不早于 at 的事务的起始位置和该事务之前已执行的 GTID 集合（源库开启 GTID 时）。binlog 时间戳精确到秒，at 中不足一秒的部分舍去。at 之后还没有事务时返回源库的最新位置，latest 为 true。结果可直接用于 PUT /tasks/{id}/position，从该时间点重放。扫描需读取整个文件，文件较大时需要一些时间。
+//	@Tags			sources
+//	@ID				locateSourcePosition
+//	@Produce		json
+//	@Param			id	path		string								true	"数据源ID，目前只有 default（canal 配置的 MySQL）"
+//	@Param			at	query		string								true	"RFC3339 时间，如 2024-06-01T00:00:00Z"	format(date-time)
+//	@Success		200	{object}	object{data=canal.LocatedPosition}	"时间点对应的位置"
+//	@Failure		400	{object}	APIError							"at 参数缺失或不是 RFC3339 时间（invalid_request）"
+//	@Failure		404	{object}	APIError							"数据源不存在"
+//	@Failure		422	{object}	APIError							"该时间点早于源库上最早的 binlog（validation_failed）"
+//	@Failure		500	{object}	APIError							"连接源库或读取 binlog 失败（internal_error）"
+//	@Router			/sources/{id}/position [get]
+func (h *EnhancedHandlers) sourcePositionHandler(c *gin.Context) {
+	if c.Param("id") != defaultSourceID {
+		respondError(c, ErrCodeNotFound, tr(c, "数据源不存在"))
+		return
+	}
+
+	at, err := time.Parse(time.RFC3339, c.Query("at"))
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "at 参数必须是 RFC3339 时间，如 2024-06-01T00:00:00Z"))
+		return
+	}
+
+	located, err := h.enhancedCanalService.LocatePosition(c.Request.Context(), at)
+	if errors.Is(err, canal.ErrTimeBeforeBinlogs) {
+		respondError(c, ErrCodeValidationFailed, tr(c, "该时间点的 binlog 已被清除: %v", err))
+		return
+	}
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "查找 binlog 位置失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": located,
+	})
+}
+
+// rotateCredentialsHandler 轮换源库账号，新账号通过复制权限校验后运行中的实例用新账号重连
+//
+//	@Summary		轮换源库账号
+//	@Description	先用新账号连接源库并检查 SHOW GRANTS 中是否有全局的 REPLICATION SLAVE（或 ALL PRIVILEGES）权限，校验失败时不做任何切换。校验通过后，运行中的实例断开 binlog 连接并用新账号从已处理的位置重连，心跳写入连接和之后创建的实例也使用新账号。新账号只保存在内存中，需同时更新配置文件，否则进程重启后恢复为配置中的账号。配置了 server.admin_token 时需携带 Authorization: Bearer <令牌>。
+//	@Tags			sources
+//	@ID				rotateSourceCredentials
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string															true	"数据源ID，目前只有 default（canal 配置的 MySQL）"
+//	@Param			request	body		RotateCredentialsRequest										true	"请求体"
+//	@Success		200		{object}	object{message=string,data=object{reconnected_instances=int}}	"已切换"
+//	@Failure		400		{object}	APIError														"请求参数错误（invalid_request）"
+//	@Failure		401		{object}	APIError														"需要管理员认证"
+//	@Failure		404		{object}	APIError														"数据源不存在"
+//	@Failure		422		{object}	APIError														"新账号校验失败（validation_failed）"
+//	@Router			/sources/{id}/credentials [put]
+func (h *EnhancedHandlers) rotateCredentialsHandler(c *gin.Context) {
+	if c.Param("id") != defaultSourceID {
+		respondError(c, ErrCodeNotFound, tr(c, "数据源不存在"))
+		return
+	}
+
+	var req RotateCredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+		return
+	}
+
+	rotated, err := h.enhancedCanalService.RotateSourceCredentials(canal.SourceCredentials{
+		Username: req.Username,
+		Password: req.Password,
+	})
+	if err != nil {
+		respondError(c, ErrCodeValidationFailed, tr(c, "新账号校验失败，未切换: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "源库账号已切换"),
+		"data": gin.H{
+			"reconnected_instances": rotated,
+		},
+	})
+}
+
+// pauseReadingHandler 维护暂停：立即保存已处理的位置，实例断开 binlog 连接，暂停在重启后仍然生效
+//
+//	@Summary		维护暂停：停止读取 binlog
+//	@Description	立即保存所有读取该源库的实例已处理的 binlog 位置，实例在事务之间断开 binlog 连接并停止读取，用于协调源库维护。不指定 source 时全局暂停。暂停期间 /readyz 返回 503，状态页显示横幅。暂停保存在数据库中，进程重启后仍然生效。配置了 server.admin_token 时需携带 Authorization: Bearer <令牌>。
+//	@Tags			admin
+//	@ID				pauseReading
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		MaintenanceRequest									false	"请求体"
+//	@Success		200		{object}	object{message=string,data=canal.MaintenanceStatus}	"暂停后的开关状态"
+//	@Failure		400		{object}	APIError											"请求参数错误"
+//	@Failure		401		{object}	APIError											"需要管理员认证"
+//	@Failure		404		{object}	APIError											"数据源不存在"
+//	@Failure		500		{object}	APIError											"保存暂停状态失败"
+//	@Router			/admin/pause [post]
+func (h *EnhancedHandlers) pauseReadingHandler(c *gin.Context) {
+	req, ok := bindMaintenanceRequest(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.enhancedCanalService.PauseReading(req.Source, req.Reason)
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "暂停读取失败: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "已暂停读取 binlog"),
+		"data":    status,
+	})
+}
+
+// resumeReadingHandler 解除维护暂停，实例从保存的位置继续读取
+//
+//	@Summary		解除维护暂停
+//	@Description	解除全局或指定源库的暂停，实例从保存的位置继续读取。全局暂停和按源库的暂停相互独立，需分别解除。配置了 server.admin_token 时需携带 Authorization: Bearer <令牌>。
+//	@Tags			admin
+//	@ID				resumeReading
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		MaintenanceRequest									false	"请求体"
+//	@Success		200		{object}	object{message=string,data=canal.MaintenanceStatus}	"恢复后的开关状态"
+//	@Failure		400		{object}	APIError											"请求参数错误"
+//	@Failure		401		{object}	APIError											"需要管理员认证"
+//	@Failure		404		{object}	APIError											"数据源不存在"
+//	@Failure		500		{object}	APIError											"删除暂停状态失败"
+//	@Router			/admin/resume [post]
+func (h *EnhancedHandlers) resumeReadingHandler(c *gin.Context) {
+	req, ok := bindMaintenanceRequest(c)
+	if !ok {
+		return
+	}
+
+	resumed, status, err := h.enhancedCanalService.ResumeReading(req.Source)
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "恢复读取失败: %v", err))
+		return
+	}
+	message := tr(c, "已恢复读取 binlog")
+	if !resumed {
+		message = tr(c, "未处于暂停状态")
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data":    status,
+	})
+}
+
+// bindMaintenanceRequest 解析维护暂停请求，请求体可以为空
+func bindMaintenanceRequest(c *gin.Context) (MaintenanceRequest, bool) {
+	var req MaintenanceRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+			return req, false
+		}
+	}
+	if req.Source != "" && req.Source != defaultSourceID {
+		respondError(c, ErrCodeNotFound, tr(c, "数据源不存在"))
+		return req, false
+	}
+	return req, true
+}
+
+// restartTaskHandler 重启任务的 Canal 实例，用于轮换源库账号或表结构漂移后重新连接
+//
+//	@Summary		重启任务的 Canal 实例
+//	@Description	停止并重新创建任务的实例：刷新缓冲中的事件，重新连接源库并重建表结构缓存，从保存的 binlog 位置继续读取，任务本身不受影响。用于轮换源库账号密码或表结构漂移后。reload_credentials 为 true 时先重新读取配置文件和环境变量中的源库地址和账号，之后创建的实例都使用新的连接信息；配置中的账号与上次读取时相同时保留通过 API 轮换的账号。只能重启启用中的任务。
+//	@Tags			tasks
+//	@ID				restartTask
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"任务ID"	minimum(1)
+//	@Param			request	body		RestartTaskRequest		false	"请求体"
+//	@Success		200		{object}	object{message=string}	"重启成功"
+//	@Failure		400		{object}	APIError				"请求参数错误"
+//	@Failure		500		{object}	APIError				"任务不存在、未启用或重启失败"
+//	@Router			/tasks/{id}/restart [post]
+func (h *EnhancedHandlers) restartTaskHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	// 请求体可选
+	var req RestartTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+		return
+	}
+
+	if err := h.enhancedCanalService.RestartTask(id, req.ReloadCredentials); err != nil {
+		respondError(c, ErrCodeInstanceFailed, tr(c, "重启任务失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "任务实例已重启"),
+	})
+}
+
+// recoverTaskHandler binlog 被清除后恢复任务
+//
+//	@Summary		binlog 被清除后恢复任务
+//	@Description	earliest 和 latest 切换位置后立即恢复读取。snapshot 先确定源库的最新位置，在后台把监听的表以 INSERT 事件发送（数值列保持数值类型），完成后从该位置继续读取，返回 202；进度见实例状态的 recovery（state 为 running、completed 或 failed），快照失败时任务仍处于告警状态，可以重新恢复。恢复进行中时再次恢复返回错误
+//	@Tags			tasks
+//	@ID				recoverTask
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"任务ID"	minimum(1)
+//	@Param			request	body		RecoverTaskRequest		true	"请求体"
+//	@Success		200		{object}	object{message=string}	"恢复成功"
+//	@Success		202		{object}	object{message=string}	"快照已在后台开始"
+//	@Failure		400		{object}	APIError				"请求参数错误"
+//	@Failure		500		{object}	APIError				"恢复失败"
+//	@Router			/tasks/{id}/recover [post]
+func (h *EnhancedHandlers) recoverTaskHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	var req RecoverTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+		return
+	}
+
+	action := canal.RecoveryAction(req.Action)
+	if err := h.enhancedCanalService.RecoverTask(id, action); err != nil {
+		respondError(c, ErrCodeInstanceFailed, tr(c, "恢复任务失败: %v", err))
+		return
+	}
+
+	// 快照在后台进行，进度见实例状态的 recovery
+	if action == canal.RecoverWithSnapshot {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": tr(c, "快照已在后台开始，完成后任务自动恢复"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "任务恢复成功"),
+	})
+}
+
+// positionsHandler 源库的最新位置和各任务的当前、持久化位置及延迟
+//
+//	@Summary		各任务的 binlog 位置和延迟
+//	@Description	返回源库的最新位置（SHOW MASTER STATUS）、已执行的 GTID 集合和可读取的 binlog 文件，以及每个任务实例当前读取的位置、保存的位置和延迟。字节延迟按 SHOW BINARY LOGS 中的文件大小计算，没有运行中的实例时按保存的位置计算；源库查询失败时 source 为空并在 source_error 中给出原因。
+//	@Tags			status
+//	@ID				getPositions
+//	@Produce		json
+//	@Success		200	{object}	object{data=service.PositionsOverview}	"成功"
+//	@Failure		500	{object}	APIError								"加载任务或保存的位置失败"
+//	@Router			/positions [get]
+func (h *EnhancedHandlers) positionsHandler(c *gin.Context) {
+	overview, err := h.enhancedCanalService.Positions()
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "获取binlog位置失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": overview,
+	})
+}
+
+// setTaskPositionHandler 修改任务持久化的 binlog 位置，实例从新位置重新开始读取
+//
+//	@Summary		修改任务保存的 binlog 位置
+//	@Description	新位置需在源库上可读取：文件在 SHOW BINARY LOGS 中，偏移不小于 4、不超过文件大小和源库最新位置。运行中的实例先停止（投递完缓冲中的事件并保存位置），写入新位置后重新创建，从新位置开始读取；未启用的任务只保存位置。gtid_set 为该位置之前已执行的 GTID 集合，可选。修改位置可能跳过或重复投递事件。配置了 server.admin_token 或用户时需要管理员认证。
+//	@Tags			tasks
+//	@ID				setTaskPosition
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int											true	"任务ID"	minimum(1)
+//	@Param			request	body		SetPositionRequest							true	"请求体"
+//	@Success		200		{object}	object{message=string,data=canal.Position}	"已修改，返回保存的位置"
+//	@Failure		400		{object}	APIError									"请求参数错误（invalid_request）"
+//	@Failure		401		{object}	APIError									"需要管理员认证"
+//	@Failure		422		{object}	APIError									"位置在源库上不可读取（validation_failed）"
+//	@Router			/tasks/{id}/position [put]
+func (h *EnhancedHandlers) setTaskPositionHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	var req SetPositionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+		return
+	}
+
+	pos, err := h.enhancedCanalService.SetTaskPosition(id, canal.Position{Name: req.Name, Pos: req.Pos, GTIDSet: req.GTIDSet})
+	if err != nil {
+		respondError(c, ErrCodeValidationFailed, tr(c, "修改binlog位置失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "binlog位置已修改"),
+		"data":    pos,
+	})
+}
+
+// resetTaskPositionHandler 把任务的 binlog 位置重置到源库最早或最新的位置
+//
+//	@Summary		把任务的 binlog 位置重置到源库最早或最新位置
+//	@Description	earliest 从源库最早可读取的 binlog 文件开头重新读取，之前的变更会再次投递；latest 跳到源库当前位置（附带 Executed_Gtid_Set），尚未读取的变更不再投递。实例的处理方式与修改位置相同。配置了 server.admin_token 或用户时需要管理员认证。
+//	@Tags			tasks
+//	@ID				resetTaskPosition
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int											true	"任务ID"	minimum(1)
+//	@Param			request	body		ResetPositionRequest						true	"请求体"
+//	@Success		200		{object}	object{message=string,data=canal.Position}	"已重置，返回保存的位置"
+//	@Failure		400		{object}	APIError									"请求参数错误"
+//	@Failure		401		{object}	APIError									"需要管理员认证"
+//	@Failure		500		{object}	APIError									"查询源库状态或重启实例失败"
+//	@Router			/tasks/{id}/position/reset [post]
+func (h *EnhancedHandlers) resetTaskPositionHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	var req ResetPositionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+		return
+	}
+
+	pos, err := h.enhancedCanalService.ResetTaskPosition(id, canal.RecoveryAction(req.Action))
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "重置binlog位置失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "binlog位置已重置"),
+		"data":    pos,
+	})
+}
+
+// replaySinkHandler 只向任务的一个 sink 重放指定位置之后的事件，其他 sink 跳过已处理过的事件
+//
+//	@Summary		只向任务的一个 sink 重放事件
+//	@Description	把该 sink 的投递进度设为指定位置，实例停止后从该位置重新读取，只有该 sink 重新收到之后的事件，其他 sink 跳过已处理过的事件。位置早于实例保存的位置时实例位置随之回退；晚于时只修改该 sink 的进度，之间的事件不再送往该 sink。位置需在源库上可读取，规则同修改任务位置。配置了 server.admin_token 或用户时需要管理员认证。
+//	@Tags			tasks
+//	@ID				replaySink
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int											true	"任务ID"	minimum(1)
+//	@Param			sink	path		string										true	"sink 名称：处理器名称（如 webhook-1）或其前缀 webhook、db（事件日志）、clickhouse、archive"
+//	@Param			request	body		SetPositionRequest							true	"请求体"
+//	@Success		200		{object}	object{message=string,data=canal.Position}	"已修改，返回该 sink 的进度"
+//	@Failure		400		{object}	APIError									"请求参数错误（invalid_request）"
+//	@Failure		401		{object}	APIError									"需要管理员认证"
+//	@Failure		422		{object}	APIError									"位置在源库上不可读取或 sink 名称无效（validation_failed）"
+//	@Router			/tasks/{id}/sinks/{sink}/replay [post]
+func (h *EnhancedHandlers) replaySinkHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	var req SetPositionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+		return
+	}
+
+	pos, err := h.enhancedCanalService.ReplaySink(id, c.Param("sink"), canal.Position{Name: req.Name, Pos: req.Pos, GTIDSet: req.GTIDSet})
+	if err != nil {
+		respondError(c, ErrCodeValidationFailed, tr(c, "重放sink失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "sink将从指定位置重放"),
+		"data":    pos,
+	})
+}
+
+// backfillTaskHandler 从 canal.backfill_dir 下的 binlog 文件回填任务，在后台进行，进度见实例状态的 backfill
+//
+//	@Summary		从本地 binlog 文件回填任务
+//	@Description	在后台按顺序读取 canal.backfill_dir 下的 binlog 文件（原始 binlog 格式，如从源库复制的归档 binlog 或 mysqlbinlog --read-from-remote-server --raw 的输出），只读取任务监听的库表和事件类型，事件送入任务运行中的实例，经过与在线读取相同的过滤和处理器（Webhook、事件日志等），用于从归档的 binlog 回填数据。不修改任务保存的 binlog 位置，实例停止时回填中止（state 为 failed）。start/stop 可选，name 为文件名。返回 202，进度见实例状态的 backfill（state 为 running、completed 或 failed），同一任务同时只能有一个回填。配置了 server.admin_token 或用户时需要管理员认证。
+//	@Tags			tasks
+//	@ID				backfillTask
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"任务ID"	minimum(1)
+//	@Param			request	body		BackfillTaskRequest		true	"请求体"
+//	@Success		202		{object}	object{message=string}	"回填已在后台开始"
+//	@Failure		400		{object}	APIError				"请求参数错误（invalid_request）"
+//	@Failure		401		{object}	APIError				"需要管理员认证"
+//	@Failure		409		{object}	APIError				"未配置 canal.backfill_dir（conflict）"
+//	@Failure		422		{object}	APIError				"文件不在回填目录中或不存在、实例未运行、起止位置的文件不在列表中或已有回填在进行（validation_failed）"
+//	@Router			/tasks/{id}/backfill [post]
+func (h *EnhancedHandlers) backfillTaskHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	var req BackfillTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+		return
+	}
+
+	if !h.enhancedCanalService.BackfillEnabled() {
+		respondError(c, ErrCodeConflict, tr(c, "未配置 canal.backfill_dir，不允许从本地 binlog 文件回填"))
+		return
+	}
+
+	if err := h.enhancedCanalService.BackfillTask(id, req.Files, req.Start, req.Stop); err != nil {
+		respondError(c, ErrCodeValidationFailed, tr(c, "回填失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": tr(c, "回填已在后台开始，进度见任务状态的 backfill"),
+	})
+}
+
+// watchPolicyHandler 查看全局监听策略
+//
+//	@Summary		查看全局监听策略
+//	@Description	所有实例共用的监听策略，任务的表、事件类型和排除规则在此之上合并。首次启动时由配置文件的 canal.watch 生成，之后 canal.watch 不再生效。配置了 server.admin_token 时需携带 Authorization: Bearer <令牌>。
+//	@Tags			admin
+//	@ID				getWatchPolicy
+//	@Produce		json
+//	@Success		200	{object}	object{data=canal.WatchPolicy}	"当前的全局监听策略"
+//	@Failure		401	{object}	APIError						"需要管理员认证"
+//	@Router			/watch [get]
+func (h *EnhancedHandlers) watchPolicyHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": h.enhancedCanalService.WatchPolicy(),
+	})
+}
+
+// setWatchPolicyHandler 修改全局监听策略，推送到运行中的实例，无需重启
+//
+//	@Summary		修改全局监听策略
+//	@Description	保存并推送到所有运行中的实例，无需重启即时生效。移出 tables 的表如果仍有任务订阅则继续监听。配置了 server.admin_token 时需携带 Authorization: Bearer <令牌>。
+//	@Tags			admin
+//	@ID				setWatchPolicy
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		canal.WatchPolicy								true	"请求体"
+//	@Success		200		{object}	object{message=string,data=canal.WatchPolicy}	"修改后的全局监听策略"
+//	@Failure		400		{object}	APIError										"请求参数错误（invalid_request）"
+//	@Failure		401		{object}	APIError										"需要管理员认证"
+//	@Failure		422		{object}	APIError										"策略无效（validation_failed）"
+//	@Router			/watch [put]
+func (h *EnhancedHandlers) setWatchPolicyHandler(c *gin.Context) {
+	var req canal.WatchPolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+		return
+	}
+
+	policy, err := h.enhancedCanalService.SetWatchPolicy(req)
+	if err != nil {
+		respondError(c, ErrCodeValidationFailed, tr(c, "修改监听策略失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "监听策略已更新"),
+		"data":    policy,
+	})
+}
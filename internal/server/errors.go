@@ -0,0 +1,118 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"pikachun/internal/service"
+)
+
+// ErrorCode API 错误码，取值稳定，客户端应按错误码而不是错误信息判断失败原因
+type ErrorCode string
+
+// 错误码目录，每个错误码对应固定的 HTTP 状态码
+const (
+	ErrCodeInvalidRequest   ErrorCode = "invalid_request"   // 请求体无法解析，或路径、查询参数、请求头无效
+	ErrCodeValidationFailed ErrorCode = "validation_failed" // 请求格式正确，但内容未通过校验
+	ErrCodePreflightFailed  ErrorCode = "preflight_failed"  // 源库预检未通过
+	ErrCodeSinkCheckFailed  ErrorCode = "sink_check_failed" // 回调地址检查未通过
+	ErrCodeUnauthorized     ErrorCode = "unauthorized"      // 需要登录或管理员令牌
+	ErrCodeForbidden        ErrorCode = "forbidden"         // 当前角色没有权限
+	ErrCodeNotFound         ErrorCode = "not_found"         // 请求的资源不存在
+	ErrCodeTaskNotFound     ErrorCode = "task_not_found"    // 任务不存在
+	ErrCodeConflict         ErrorCode = "conflict"          // 与已有资源冲突，如幂等键已用于其他任务
+	ErrCodePayloadTooLarge  ErrorCode = "payload_too_large" // 请求体过大
+	ErrCodeLimitExceeded    ErrorCode = "limit_exceeded"    // 达到 canal.limits 的任务数、实例数或复制连接数上限
+	ErrCodeInstanceFailed   ErrorCode = "instance_failed"   // Canal 实例启动、更新或停止失败
+	ErrCodeDeliveryFailed   ErrorCode = "delivery_failed"   // 已投递但下游返回失败
+	ErrCodeInternal         ErrorCode = "internal_error"    // 服务器内部错误
+)
+
+// errorStatus 错误码对应的 HTTP 状态码
+var errorStatus = map[ErrorCode]int{
+	ErrCodeInvalidRequest:   http.StatusBadRequest,
+	ErrCodeValidationFailed: http.StatusUnprocessableEntity,
+	ErrCodePreflightFailed:  http.StatusUnprocessableEntity,
+	ErrCodeSinkCheckFailed:  http.StatusUnprocessableEntity,
+	ErrCodeUnauthorized:     http.StatusUnauthorized,
+	ErrCodeForbidden:        http.StatusForbidden,
+	ErrCodeNotFound:         http.StatusNotFound,
+	ErrCodeTaskNotFound:     http.StatusNotFound,
+	ErrCodeConflict:         http.StatusConflict,
+	ErrCodePayloadTooLarge:  http.StatusRequestEntityTooLarge,
+	ErrCodeLimitExceeded:    http.StatusTooManyRequests,
+	ErrCodeInstanceFailed:   http.StatusInternalServerError,
+	ErrCodeDeliveryFailed:   http.StatusBadGateway,
+	ErrCodeInternal:         http.StatusInternalServerError,
+}
+
+// Status 错误码对应的 HTTP 状态码，未登记的错误码为 500
+func (code ErrorCode) Status() int {
+	if status, ok := errorStatus[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// APIError 结构化的错误响应
+//
+//	@Description	错误响应。客户端应按 code 判断失败原因，message 为按请求语言翻译的错误信息，仅供展示
+type APIError struct {
+	Code      ErrorCode   `json:"code" binding:"required"`       // 稳定的错误码，客户端据此判断失败原因
+	Message   string      `json:"message" binding:"required"`    // 按请求语言翻译的错误信息，供展示，不应用于判断
+	Details   interface{} `json:"details,omitempty"`             // 错误详情，如字段校验错误（fields）、批量创建中每个任务的校验结果（results）、源库预检报告或 limit_exceeded 时达到的上限
+	RequestID string      `json:"request_id" binding:"required"` // 请求 ID，与响应头 X-Request-ID 相同
+	Legacy    string      `json:"error" binding:"required"`      // 同 message，兼容按 error 字段读取错误信息的旧客户端
+}
+
+// newAPIError 创建当前请求的错误响应
+func newAPIError(c *gin.Context, code ErrorCode, message string, details interface{}) APIError {
+	return APIError{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: requestID(c),
+		Legacy:    message,
+	}
+}
+
+// respondError 返回错误响应，HTTP 状态码由错误码决定
+func respondError(c *gin.Context, code ErrorCode, message string) {
+	respondErrorDetails(c, code, message, nil)
+}
+
+// respondErrorDetails 返回带详情的错误响应，如批量创建中每个任务的校验结果
+func respondErrorDetails(c *gin.Context, code ErrorCode, message string, details interface{}) {
+	c.JSON(code.Status(), newAPIError(c, code, message, details))
+}
+
+// abortWithError 中断请求并返回错误响应，用于中间件
+func abortWithError(c *gin.Context, code ErrorCode, message string) {
+	c.AbortWithStatusJSON(code.Status(), newAPIError(c, code, message, nil))
+}
+
+// respondTaskError 返回任务服务的错误：校验错误为 422，任务不存在为 404，达到准入上限为 429，其余为 500。
+// msgid 为错误信息的翻译模板，带一个 %v 参数
+func respondTaskError(c *gin.Context, err error, msgid string) {
+	if limitErr, ok := service.AsLimitError(err); ok {
+		respondLimitError(c, limitErr, msgid)
+		return
+	}
+	switch {
+	case service.IsValidationError(err):
+		respondError(c, ErrCodeValidationFailed, tr(c, msgid, err))
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondError(c, ErrCodeTaskNotFound, tr(c, "任务不存在"))
+	default:
+		respondError(c, ErrCodeInternal, tr(c, msgid, err))
+	}
+}
+
+// respondLimitError 返回 429 limit_exceeded，details 中为达到的上限、配置值和当前数量
+func respondLimitError(c *gin.Context, limitErr *service.LimitError, msgid string) {
+	message := tr(c, "已达到 canal.limits.%s 上限 %d（当前 %d）", limitErr.Limit, limitErr.Max, limitErr.Current)
+	respondErrorDetails(c, ErrCodeLimitExceeded, tr(c, msgid, message), limitErr)
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"pikachun/internal/service"
+)
+
+// TestRespondTaskError 测试任务服务的错误映射为错误码和 HTTP 状态码，响应中带请求ID，达到准入上限时 details 中为上限
+func TestRespondTaskError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		err    error
+		code   ErrorCode
+		status int
+	}{
+		{&service.ValidationError{Err: errors.New("回调URL不能为空")}, ErrCodeValidationFailed, http.StatusUnprocessableEntity},
+		{fmt.Errorf("load: %w", gorm.ErrRecordNotFound), ErrCodeTaskNotFound, http.StatusNotFound},
+		{fmt.Errorf("start: %w", &service.LimitError{Limit: service.LimitInstances, Max: 2, Current: 2}), ErrCodeLimitExceeded, http.StatusTooManyRequests},
+		{errors.New("database is locked"), ErrCodeInternal, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		router := gin.New()
+		router.Use(requestIDMiddleware())
+		router.GET("/", func(c *gin.Context) {
+			respondTaskError(c, tt.err, "更新任务失败: %v")
+		})
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(requestIDHeader, "req-1")
+		router.ServeHTTP(w, req)
+
+		var resp APIError
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != tt.status || resp.Code != tt.code {
+			t.Errorf("%v: expected %d %s, got %d %s", tt.err, tt.status, tt.code, w.Code, resp.Code)
+		}
+		if resp.RequestID != "req-1" || resp.Message == "" || resp.Legacy != resp.Message {
+			t.Errorf("%v: unexpected response %s", tt.err, w.Body.String())
+		}
+		if details, _ := resp.Details.(map[string]interface{}); tt.code == ErrCodeLimitExceeded && (details["limit"] != service.LimitInstances || details["max"] != float64(2)) {
+			t.Errorf("%v: expected the limit in details, got %s", tt.err, w.Body.String())
+		}
+	}
+}
+
+// TestErrorCodeStatus 测试错误码目录中的每个错误码都有对应的错误状态码
+func TestErrorCodeStatus(t *testing.T) {
+	for code, status := range errorStatus {
+		if status < http.StatusBadRequest {
+			t.Errorf("%s: expected an error status, got %d", code, status)
+		}
+	}
+	if ErrCodeConflict.Status() != http.StatusConflict || ErrorCode("unknown").Status() != http.StatusInternalServerError {
+		t.Error("unexpected status mapping")
+	}
+}
@@ -9,42 +9,153 @@
 package server
 
 import (
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"pikachun/internal/canal"
 	"pikachun/internal/database"
 )
 
 // CreateTaskRequest 创建任务请求
 type CreateTaskRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Database    string `json:"database" binding:"required"`
-	Table       string `json:"table" binding:"required"`
-	EventTypes  string `json:"event_types" binding:"required"`
-	CallbackURL string `json:"callback_url" binding:"required"`
+	Name            string  `json:"name" binding:"required,max=100"`                                           // 任务名称
+	Database        string  `json:"database" binding:"required,identifier" maxLength:"64"`                     // 字母、数字、下划线、$ 和 -，最长 64 个字符
+	Table           string  `json:"table" binding:"required,identifier" maxLength:"64"`                        // 字母、数字、下划线、$ 和 -，最长 64 个字符
+	EventTypes      string  `json:"event_types" binding:"required,event_types" example:"INSERT,UPDATE,DELETE"` // 逗号分隔的事件类型，取值为 INSERT、UPDATE、DELETE，不区分大小写
+	CallbackURL     string  `json:"callback_url" binding:"required,max=500,webhook_url" format:"uri"`          // http 或 https 地址
+	ExcludeTables   string  `json:"exclude_tables" binding:"max=500"`                                          // 排除规则，逗号分隔，支持通配符，如 *_tmp,migrations
+	RequestTimeout  int     `json:"request_timeout" binding:"min=0,max=3600"`                                  // 单次 HTTP 请求超时（秒），0 表示默认 30s
+	DeliveryTimeout int     `json:"delivery_timeout" binding:"min=0,max=3600"`                                 // 一批事件投递的总超时（秒，含重试），0 表示默认 60s
+	ShutdownTimeout int     `json:"shutdown_timeout" binding:"min=0,max=3600"`                                 // 停止时刷新缓冲区的超时（秒），0 表示默认 30s
+	Schedule        string  `json:"schedule" binding:"max=500"`                                                // 维护窗口，分号分隔，如 "Mon-Fri 09:00-18:00; Sat,Sun 22:00-06:00"（服务器本地时间）
+	ScheduleMode    string  `json:"schedule_mode" binding:"omitempty,oneof=pause throttle"`                    // 窗口内暂停读取（在事务之间断开 binlog 连接，窗口结束后从断开的位置重新连接）或限速读取，默认 pause
+	ScheduleRate    int     `json:"schedule_rate" binding:"min=0,max=1000000"`                                 // throttle 模式下每秒最多读取的 binlog 事件数
+	DryRun          bool    `json:"dry_run"`                                                                   // 演练模式：完整运行处理流程，只记录将要投递的内容，不调用 Webhook
+	PartitionBy     string  `json:"partition_by" binding:"omitempty,oneof=none pk table column round_robin"`   // 分区路由策略，为空或 none 时不分区。开启后一批事件按分区键拆成多个请求，请求头 X-Partition-Key 标明分区
+	PartitionColumn string  `json:"partition_column" binding:"max=64"`                                         // column 策略使用的列名
+	PartitionCount  int     `json:"partition_count" binding:"min=0,max=1024"`                                  // 分区数，大于 0 时分区键为分区号 0..N-1，否则为原始路由值；round_robin 策略必填
+	NumericStrings  *bool   `json:"numeric_strings" extensions:"x-nullable"`                                   // 64 位整数（BIGINT）和定点小数（DECIMAL）列值以及请求序号编码为 JSON 字符串，避免 JavaScript 消费端丢失精度；不设置时使用全局配置 canal.numeric_strings
+	IncludeSchema   *bool   `json:"include_schema" extensions:"x-nullable"`                                    // Webhook 请求体中附带 schema 部分，按 库.表 描述列名、列类型、主键和结构哈希；每张表只在首次发送和结构哈希变化后附带，事件的 schema_hash 字段与之对应
+	Compression     string  `json:"compression" binding:"omitempty,oneof=none gzip zstd auto"`                 // 请求体压缩，设置 Content-Encoding；auto 先用 gzip，接收方在响应头 Accept-Encoding 中声明支持 zstd 后改用 zstd。接收方声明的编码或返回 415 时自动改用双方都支持的编码或不压缩
+	CompressMinSize int     `json:"compress_min_size" binding:"min=0,max=16777216"`                            // 只压缩不小于该字节数的请求体，0 表示默认 1024
+	Priority        int     `json:"priority" binding:"min=0,max=100"`                                          // 投递调度权重：全局投递并发（canal.max_delivery_concurrency）已满时，各任务按权重比例获得空出的并发，0 表示默认 1
+	SamplePercent   float64 `json:"sample_percent" binding:"min=0,max=100"`                                    // 事件采样：保留的百分比，按库表和主键值哈希，同一行的变更要么都保留要么都丢弃；0 或 100 表示不按比例采样
+	SampleInterval  int     `json:"sample_interval" binding:"min=0,max=86400"`                                 // 事件采样：同一行的最小事件间隔（秒），间隔内只保留第一个事件，0 表示不限频
+	CompactWindow   int     `json:"compact_window" binding:"min=0,max=3600"`                                   // 窗口合并（秒）：同一行在窗口内的多次变更合并为最新状态后投递（后写覆盖），合并后的事件带有 compacted 字段；0 表示不合并
+	DeleteMode      string  `json:"delete_mode" binding:"omitempty,oneof=before tombstone both"`               // DELETE 事件投递方式：before 投递带删除前镜像的事件（默认）；tombstone 投递只带主键（key）、没有行数据的墓碑（tombstone: true）；both 先投递删除前镜像再投递墓碑。没有主键的表总是投递删除前镜像
+	PayloadMapping  string  `json:"payload_mapping"`                                                           // 载荷映射规则（JSON）：rename 列重命名（键为列名或表名.列名），flatten 把 before_data/after_data/key 展开为顶层字段（前缀 before_prefix/after_prefix/key_prefix，默认 before_/after_/key_），drop 删除元数据字段。空字符串表示不改写
+	RowFilter       string  `json:"row_filter"`                                                                // 行过滤条件，如 status = 'paid' AND amount >= 100，只投递匹配的行。列名默认指 after_data（DELETE 为 before_data），可加 before. 或 after. 前缀；空表示不过滤
+	SourceName      string  `json:"source_name" binding:"max=100"`                                             // 来源标识，写入载荷的 source 字段和 X-Source-Name 请求头，为空时 source 为 canal-pikachun、不发送请求头
+	Environment     string  `json:"environment" binding:"max=50"`                                              // 来源环境（如 production），写入载荷的 environment 字段和 X-Source-Environment 请求头，为空时不发送
+	UserAgent       string  `json:"user_agent" binding:"max=200"`                                              // 投递请求的 User-Agent，为空时为 Canal-Pikachun/1.0
+	MaxLagSeconds   int     `json:"max_lag_seconds" binding:"min=0"`                                           // 延迟保护：复制延迟上限（秒），0 表示不限制
+	MaxLagEvents    int     `json:"max_lag_events" binding:"min=0"`                                            // 延迟保护：未投递事件数上限（处理队列加 Webhook 缓冲和投递中的事件），0 表示不限制
+	LagAction       string  `json:"lag_action" binding:"omitempty,oneof=alert pause sample"`                   // 超过限制时的处理方式：alert 只告警（默认）；pause 告警并暂停任务（状态改为 inactive），需要人工重新启用；sample 告警并切换为按 lag_sample 采样，延迟和未投递事件数回落到限制的一半以下后恢复
+	LagSample       float64 `json:"lag_sample" binding:"min=0,max=100"`                                        // sample 方式保留的事件百分比，0 表示默认 10
+	MaxEventAge     int     `json:"max_event_age" binding:"min=0"`                                             // 事件最大存活时间（秒），从事件进入处理队列时算起：投递失败后，超过该时间的事件不再重试，按 expired_action 处理。0 表示不限制
+	ExpiredAction   string  `json:"expired_action" binding:"omitempty,oneof=dlq drop"`                         // 过期事件的处理方式：dlq 在事件日志中记为 failed，进入失败事件列表，可重新投递（默认）；drop 丢弃，事件日志记为 expired。两种方式都计入 Webhook 统计的 expired_events 和 failed_events
+	QualityRules    string  `json:"quality_rules"`                                                             // 数据质量规则（JSON）：{"rules":[...]}，每条规则按 table（库.表）和 column 断言 not_null、min/max 取值范围或 exists 引用存在（库.表.列），违规的事件计数并采样进数据质量报告；空表示不检查
+	QuarantineURL   string  `json:"quarantine_url" binding:"omitempty,max=500,webhook_url" format:"uri"`       // 隔离地址：违反数据质量规则的事件改投到该地址而不是回调地址，事件日志记为 quarantined；空表示违规的事件照常投递，只计数
 }
 
 // ToTask 转换为Task模型
 func (r *CreateTaskRequest) ToTask() *database.Task {
 	return &database.Task{
-		Name:        r.Name,
-		Database:    r.Database,
-		Table:       r.Table,
-		EventTypes:  r.EventTypes,
-		CallbackURL: r.CallbackURL,
-		Status:      "active",
+		Name:          r.Name,
+		Database:      r.Database,
+		Table:         r.Table,
+		EventTypes:    r.EventTypes,
+		CallbackURL:   r.CallbackURL,
+		ExcludeTables: r.ExcludeTables,
+		Status:        "active",
+
+		RequestTimeout:  r.RequestTimeout,
+		DeliveryTimeout: r.DeliveryTimeout,
+		ShutdownTimeout: r.ShutdownTimeout,
+
+		Schedule:     r.Schedule,
+		ScheduleMode: r.ScheduleMode,
+		ScheduleRate: r.ScheduleRate,
+
+		DryRun: r.DryRun,
+
+		PartitionBy:     r.PartitionBy,
+		PartitionColumn: r.PartitionColumn,
+		PartitionCount:  r.PartitionCount,
+
+		NumericStrings: r.NumericStrings,
+		IncludeSchema:  r.IncludeSchema,
+
+		Compression:     r.Compression,
+		CompressMinSize: r.CompressMinSize,
+
+		Priority: r.Priority,
+
+		SamplePercent:  r.SamplePercent,
+		SampleInterval: r.SampleInterval,
+
+		CompactWindow:  r.CompactWindow,
+		DeleteMode:     r.DeleteMode,
+		PayloadMapping: r.PayloadMapping,
+		RowFilter:      r.RowFilter,
+		SourceName:     r.SourceName,
+		Environment:    r.Environment,
+		UserAgent:      r.UserAgent,
+		MaxLagSeconds:  r.MaxLagSeconds,
+		MaxLagEvents:   r.MaxLagEvents,
+		LagAction:      r.LagAction,
+		LagSample:      r.LagSample,
+		MaxEventAge:    r.MaxEventAge,
+		ExpiredAction:  r.ExpiredAction,
+		QualityRules:   r.QualityRules,
+		QuarantineURL:  r.QuarantineURL,
 	}
 }
 
 // UpdateTaskRequest 更新任务请求
 type UpdateTaskRequest struct {
-	Name        *string `json:"name,omitempty"`
-	Database    *string `json:"database,omitempty"`
-	Table       *string `json:"table,omitempty"`
-	EventTypes  *string `json:"event_types,omitempty"`
-	CallbackURL *string `json:"callback_url,omitempty"`
-	Status      *string `json:"status,omitempty"`
+	Name            *string  `json:"name,omitempty" binding:"omitempty,min=1,max=100"`                                     // 任务名称
+	Database        *string  `json:"database,omitempty" binding:"omitempty,identifier" maxLength:"64"`                     // 字母、数字、下划线、$ 和 -，最长 64 个字符
+	Table           *string  `json:"table,omitempty" binding:"omitempty,identifier" maxLength:"64"`                        // 字母、数字、下划线、$ 和 -，最长 64 个字符
+	EventTypes      *string  `json:"event_types,omitempty" binding:"omitempty,event_types" example:"INSERT,UPDATE,DELETE"` // 逗号分隔的事件类型，取值为 INSERT、UPDATE、DELETE，不区分大小写
+	CallbackURL     *string  `json:"callback_url,omitempty" binding:"omitempty,min=1,max=500,webhook_url" format:"uri"`    // http 或 https 地址
+	Status          *string  `json:"status,omitempty" binding:"omitempty,oneof=active inactive"`                           // active 启用、inactive 停用
+	ExcludeTables   *string  `json:"exclude_tables,omitempty" binding:"omitempty,max=500"`                                 // 排除规则，逗号分隔，支持通配符，如 *_tmp,migrations
+	RequestTimeout  *int     `json:"request_timeout,omitempty" binding:"omitempty,min=1,max=3600"`                         // 单次 HTTP 请求超时（秒），0 表示默认 30s
+	DeliveryTimeout *int     `json:"delivery_timeout,omitempty" binding:"omitempty,min=1,max=3600"`                        // 一批事件投递的总超时（秒，含重试），0 表示默认 60s
+	ShutdownTimeout *int     `json:"shutdown_timeout,omitempty" binding:"omitempty,min=1,max=3600"`                        // 停止时刷新缓冲区的超时（秒），0 表示默认 30s
+	Schedule        *string  `json:"schedule,omitempty" binding:"omitempty,max=500"`                                       // 维护窗口，分号分隔，如 "Mon-Fri 09:00-18:00; Sat,Sun 22:00-06:00"（服务器本地时间），传空字符串清除窗口
+	ScheduleMode    *string  `json:"schedule_mode,omitempty" binding:"omitempty,oneof=pause throttle"`                     // 窗口内暂停读取（在事务之间断开 binlog 连接，窗口结束后从断开的位置重新连接）或限速读取，默认 pause
+	ScheduleRate    *int     `json:"schedule_rate,omitempty" binding:"omitempty,min=1,max=1000000"`                        // throttle 模式下每秒最多读取的 binlog 事件数
+	DryRun          *bool    `json:"dry_run,omitempty"`                                                                    // 演练模式：完整运行处理流程，只记录将要投递的内容，不调用 Webhook
+	PartitionBy     *string  `json:"partition_by,omitempty" binding:"omitempty,oneof=none pk table column round_robin"`    // 分区路由策略，为空或 none 时不分区。开启后一批事件按分区键拆成多个请求，请求头 X-Partition-Key 标明分区，关闭时设为 none
+	PartitionColumn *string  `json:"partition_column,omitempty" binding:"omitempty,max=64"`                                // column 策略使用的列名
+	PartitionCount  *int     `json:"partition_count,omitempty" binding:"omitempty,min=0,max=1024"`                         // 分区数，大于 0 时分区键为分区号 0..N-1，否则为原始路由值；round_robin 策略必填，设为 0 改回原始路由值
+	NumericStrings  *bool    `json:"numeric_strings,omitempty" extensions:"x-nullable"`                                    // 64 位整数（BIGINT）和定点小数（DECIMAL）列值以及请求序号编码为 JSON 字符串，避免 JavaScript 消费端丢失精度；不设置时使用全局配置 canal.numeric_strings
+	IncludeSchema   *bool    `json:"include_schema,omitempty" extensions:"x-nullable"`                                     // Webhook 请求体中附带 schema 部分，按 库.表 描述列名、列类型、主键和结构哈希；每张表只在首次发送和结构哈希变化后附带，事件的 schema_hash 字段与之对应
+	Compression     *string  `json:"compression,omitempty" binding:"omitempty,oneof=none gzip zstd auto"`                  // 请求体压缩，设置 Content-Encoding；auto 先用 gzip，接收方在响应头 Accept-Encoding 中声明支持 zstd 后改用 zstd。接收方声明的编码或返回 415 时自动改用双方都支持的编码或不压缩，关闭时设为 none
+	CompressMinSize *int     `json:"compress_min_size,omitempty" binding:"omitempty,min=1,max=16777216"`                   // 只压缩不小于该字节数的请求体，0 表示默认 1024
+	Priority        *int     `json:"priority,omitempty" binding:"omitempty,min=1,max=100"`                                 // 投递调度权重：全局投递并发（canal.max_delivery_concurrency）已满时，各任务按权重比例获得空出的并发，0 表示默认 1
+	SamplePercent   *float64 `json:"sample_percent,omitempty" binding:"omitempty,min=0,max=100"`                           // 事件采样：保留的百分比，按库表和主键值哈希，同一行的变更要么都保留要么都丢弃；0 或 100 表示不按比例采样
+	SampleInterval  *int     `json:"sample_interval,omitempty" binding:"omitempty,min=0,max=86400"`                        // 事件采样：同一行的最小事件间隔（秒），间隔内只保留第一个事件，0 表示不限频
+	CompactWindow   *int     `json:"compact_window,omitempty" binding:"omitempty,min=0,max=3600"`                          // 窗口合并（秒）：同一行在窗口内的多次变更合并为最新状态后投递（后写覆盖），合并后的事件带有 compacted 字段；0 表示不合并，关闭时设为 0
+	DeleteMode      *string  `json:"delete_mode,omitempty" binding:"omitempty,oneof=before tombstone both"`                // DELETE 事件投递方式：before 投递带删除前镜像的事件（默认）；tombstone 投递只带主键（key）、没有行数据的墓碑（tombstone: true）；both 先投递删除前镜像再投递墓碑。没有主键的表总是投递删除前镜像，恢复默认时设为 before
+	PayloadMapping  *string  `json:"payload_mapping,omitempty"`                                                            // 载荷映射规则（JSON）：rename 列重命名（键为列名或表名.列名），flatten 把 before_data/after_data/key 展开为顶层字段（前缀 before_prefix/after_prefix/key_prefix，默认 before_/after_/key_），drop 删除元数据字段。空字符串表示不改写，设为空字符串清除
+	RowFilter       *string  `json:"row_filter,omitempty"`                                                                 // 行过滤条件，如 status = 'paid' AND amount >= 100，只投递匹配的行。列名默认指 after_data（DELETE 为 before_data），可加 before. 或 after. 前缀；空表示不过滤，设为空字符串清除
+	SourceName      *string  `json:"source_name,omitempty" binding:"omitempty,max=100"`                                    // 来源标识，写入载荷的 source 字段和 X-Source-Name 请求头，为空时 source 为 canal-pikachun、不发送请求头，设为空字符串恢复默认
+	Environment     *string  `json:"environment,omitempty" binding:"omitempty,max=50"`                                     // 来源环境（如 production），写入载荷的 environment 字段和 X-Source-Environment 请求头，为空时不发送，设为空字符串清除
+	UserAgent       *string  `json:"user_agent,omitempty" binding:"omitempty,max=200"`                                     // 投递请求的 User-Agent，为空时为 Canal-Pikachun/1.0，设为空字符串恢复默认
+	MaxLagSeconds   *int     `json:"max_lag_seconds,omitempty" binding:"omitempty,min=0"`                                  // 延迟保护：复制延迟上限（秒），0 表示不限制，设为 0 取消限制
+	MaxLagEvents    *int     `json:"max_lag_events,omitempty" binding:"omitempty,min=0"`                                   // 延迟保护：未投递事件数上限（处理队列加 Webhook 缓冲和投递中的事件），0 表示不限制，设为 0 取消限制
+	LagAction       *string  `json:"lag_action,omitempty" binding:"omitempty,oneof=alert pause sample"`                    // 超过限制时的处理方式：alert 只告警（默认）；pause 告警并暂停任务（状态改为 inactive），需要人工重新启用；sample 告警并切换为按 lag_sample 采样，延迟和未投递事件数回落到限制的一半以下后恢复
+	LagSample       *float64 `json:"lag_sample,omitempty" binding:"omitempty,min=0,max=100"`                               // sample 方式保留的事件百分比，0 表示默认 10
+	MaxEventAge     *int     `json:"max_event_age,omitempty" binding:"omitempty,min=0"`                                    // 事件最大存活时间（秒），从事件进入处理队列时算起：投递失败后，超过该时间的事件不再重试，按 expired_action 处理。0 表示不限制，设为 0 取消限制
+	ExpiredAction   *string  `json:"expired_action,omitempty" binding:"omitempty,oneof=dlq drop"`                          // 过期事件的处理方式：dlq 在事件日志中记为 failed，进入失败事件列表，可重新投递（默认）；drop 丢弃，事件日志记为 expired。两种方式都计入 Webhook 统计的 expired_events 和 failed_events
+	QualityRules    *string  `json:"quality_rules,omitempty"`                                                              // 数据质量规则（JSON）：{"rules":[...]}，每条规则按 table（库.表）和 column 断言 not_null、min/max 取值范围或 exists 引用存在（库.表.列），违规的事件计数并采样进数据质量报告；空表示不检查；设为空字符串清除
+	QuarantineURL   *string  `json:"quarantine_url,omitempty" binding:"omitempty,max=500,webhook_url" format:"uri"`        // 隔离地址：违反数据质量规则的事件改投到该地址而不是回调地址，事件日志记为 quarantined；空表示违规的事件照常投递，只计数；设为空字符串清除
 }
 
 // ToTask 转换为Task模型
@@ -68,9 +179,200 @@ func (r *UpdateTaskRequest) ToTask() *database.Task {
 	if r.Status != nil {
 		task.Status = *r.Status
 	}
+	if r.ExcludeTables != nil {
+		task.ExcludeTables = *r.ExcludeTables
+	}
+	if r.RequestTimeout != nil {
+		task.RequestTimeout = *r.RequestTimeout
+	}
+	if r.DeliveryTimeout != nil {
+		task.DeliveryTimeout = *r.DeliveryTimeout
+	}
+	if r.ShutdownTimeout != nil {
+		task.ShutdownTimeout = *r.ShutdownTimeout
+	}
+	if r.Schedule != nil {
+		task.Schedule = *r.Schedule
+	}
+	if r.ScheduleMode != nil {
+		task.ScheduleMode = *r.ScheduleMode
+	}
+	if r.ScheduleRate != nil {
+		task.ScheduleRate = *r.ScheduleRate
+	}
+	if r.DryRun != nil {
+		task.DryRun = *r.DryRun
+	}
+	if r.PartitionBy != nil {
+		task.PartitionBy = *r.PartitionBy
+	}
+	if r.PartitionColumn != nil {
+		task.PartitionColumn = *r.PartitionColumn
+	}
+	if r.PartitionCount != nil {
+		task.PartitionCount = *r.PartitionCount
+	}
+	task.NumericStrings = r.NumericStrings
+	task.IncludeSchema = r.IncludeSchema
+	if r.Compression != nil {
+		task.Compression = *r.Compression
+	}
+	if r.CompressMinSize != nil {
+		task.CompressMinSize = *r.CompressMinSize
+	}
+	if r.Priority != nil {
+		task.Priority = *r.Priority
+	}
+	if r.SamplePercent != nil {
+		task.SamplePercent = *r.SamplePercent
+	}
+	if r.SampleInterval != nil {
+		task.SampleInterval = *r.SampleInterval
+	}
+	if r.CompactWindow != nil {
+		task.CompactWindow = *r.CompactWindow
+	}
+	if r.DeleteMode != nil {
+		task.DeleteMode = *r.DeleteMode
+	}
+	if r.PayloadMapping != nil {
+		task.PayloadMapping = *r.PayloadMapping
+	}
+	if r.RowFilter != nil {
+		task.RowFilter = *r.RowFilter
+	}
+	if r.SourceName != nil {
+		task.SourceName = *r.SourceName
+	}
+	if r.Environment != nil {
+		task.Environment = *r.Environment
+	}
+	if r.UserAgent != nil {
+		task.UserAgent = *r.UserAgent
+	}
+	if r.MaxLagSeconds != nil {
+		task.MaxLagSeconds = *r.MaxLagSeconds
+	}
+	if r.MaxLagEvents != nil {
+		task.MaxLagEvents = *r.MaxLagEvents
+	}
+	if r.LagAction != nil {
+		task.LagAction = *r.LagAction
+	}
+	if r.LagSample != nil {
+		task.LagSample = *r.LagSample
+	}
+	if r.MaxEventAge != nil {
+		task.MaxEventAge = *r.MaxEventAge
+	}
+	if r.ExpiredAction != nil {
+		task.ExpiredAction = *r.ExpiredAction
+	}
+	if r.QualityRules != nil {
+		task.QualityRules = *r.QualityRules
+	}
+	if r.QuarantineURL != nil {
+		task.QuarantineURL = *r.QuarantineURL
+	}
 	return task
 }
 
+// defaultSourceID 唯一的源库，即 canal 配置的 MySQL
+const defaultSourceID = canal.DefaultSourceID
+
+// RotateCredentialsRequest 源库账号轮换请求
+type RotateCredentialsRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password"`
+}
+
+// MaintenanceRequest 维护暂停/恢复请求，source 为空表示全部源库
+type MaintenanceRequest struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// maxBulkTasks 一次批量创建的最大任务数
+const maxBulkTasks = 500
+
+// BulkCreateTasksRequest 批量创建任务请求：tasks 逐个列出任务，或 pattern 加 template 按库表规则展开。
+// 每个任务单独校验，全部通过后在一个事务中创建
+//
+//	@Description	tasks 逐个列出任务，或 pattern 加 template 按库表规则展开，二者选一
+type BulkCreateTasksRequest struct {
+	Tasks    []CreateTaskRequest `json:"tasks" binding:"-"`
+	Pattern  string              `json:"pattern"`              // 库表规则，如 shop.order_*，源库中匹配到的每张表创建一个任务
+	Template *CreateTaskRequest  `json:"template" binding:"-"` // 展开规则时的任务模板，库名和表名由匹配结果填充
+}
+
+// expand 按库表展开模板，任务名为 "模板名-库.表"，模板名为空时为 "库.表"
+func (t CreateTaskRequest) expand(tables []canal.SourceTable) []CreateTaskRequest {
+	items := make([]CreateTaskRequest, 0, len(tables))
+	for _, table := range tables {
+		item := t
+		item.Database, item.Table = table.Schema, table.Table
+		item.Name = table.Schema + "." + table.Table
+		if t.Name != "" {
+			item.Name = t.Name + "-" + item.Name
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// BulkTaskResult 批量创建中单个任务的结果
+type BulkTaskResult struct {
+	Index    int            `json:"index"`
+	Database string         `json:"database"`
+	Table    string         `json:"table"`
+	Task     *database.Task `json:"task,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Fields   []FieldError   `json:"fields,omitempty"` // 请求参数校验失败的字段
+}
+
+// RecoverTaskRequest binlog 被清除后的恢复请求
+type RecoverTaskRequest struct {
+	Action string `json:"action" binding:"required,oneof=earliest latest snapshot"`
+}
+
+// SetPositionRequest 修改任务持久化 binlog 位置的请求
+type SetPositionRequest struct {
+	Name    string `json:"name" binding:"required"`
+	Pos     uint32 `json:"pos" binding:"required,min=4"`
+	GTIDSet string `json:"gtid_set"` // 该位置之前已执行的 GTID 集合，可选
+}
+
+// ResetPositionRequest 把任务位置重置到源库最早或最新位置的请求
+type ResetPositionRequest struct {
+	Action string `json:"action" binding:"required,oneof=earliest latest"`
+}
+
+// BackfillTaskRequest 从本地 binlog 文件回填任务的请求，起止位置可选，文件名为空时从第一个文件开头读到最后一个文件末尾
+type BackfillTaskRequest struct {
+	Files []string       `json:"files" binding:"required,min=1"` // 相对于 canal.backfill_dir 的文件名，按顺序读取
+	Start canal.Position `json:"start"`
+	Stop  canal.Position `json:"stop"`
+}
+
+// RestartTaskRequest 重启任务实例的请求，请求体可选
+type RestartTaskRequest struct {
+	ReloadCredentials bool `json:"reload_credentials"` // 重启前重新读取配置中的源库地址和账号
+}
+
+// SimulateEventRequest 模拟事件请求，行数据为列名到值的映射，库表为空时使用任务监听的库表
+type SimulateEventRequest struct {
+	EventType string                 `json:"event_type" binding:"required,oneof=INSERT UPDATE DELETE"`
+	Schema    string                 `json:"schema"`
+	Table     string                 `json:"table"`
+	Before    map[string]interface{} `json:"before"`
+	After     map[string]interface{} `json:"after"`
+}
+
+// RedeliverEventRequest 重新投递事件请求，URL 为空时使用任务当前的回调地址
+type RedeliverEventRequest struct {
+	URL string `json:"url" binding:"omitempty,url" format:"uri"`
+}
+
 // parseIntDefault 解析整数，失败时返回默认值
 func parseIntDefault(s string, defaultValue int) (int, error) {
 	if i, err := strconv.Atoi(s); err == nil {
@@ -87,6 +389,19 @@ func parseUintDefault(s string, defaultValue uint) (uint, error) {
 	return defaultValue, nil
 }
 
+// parseTimeQuery 从查询参数解析 RFC3339 时间，参数为空时返回 nil
+func parseTimeQuery(c *gin.Context, param string) (*time.Time, error) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时间格式 %s=%s，需为 RFC3339", param, value)
+	}
+	return &t, nil
+}
+
 // parseUintParam 从URL参数解析无符号整数
 func parseUintParam(c *gin.Context, param string) (uint, error) {
 	s := c.Param(param)
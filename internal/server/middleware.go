@@ -0,0 +1,317 @@
+package server
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"pikachun/internal/auth"
+	"pikachun/internal/config"
+	"pikachun/internal/i18n"
+)
+
+// requestIDHeader 请求ID头，客户端传入时沿用，否则生成
+const requestIDHeader = "X-Request-ID"
+
+// requestIDKey 请求ID在 gin.Context 中的键
+const requestIDKey = "request_id"
+
+// requestIDMiddleware 为每个请求分配请求ID并写入响应头
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		// 限制长度，避免日志被超长的客户端请求ID污染
+		if id == "" || len(id) > 128 {
+			id = uuid.NewString()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// requestID 获取当前请求的请求ID
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// languageCookie 保存用户选择的语言，页面和 API 共用
+const languageCookie = "pikachun_lang"
+
+// languageKey 请求语言在 gin.Context 中的键
+const languageKey = "language"
+
+// languageMiddleware 确定请求使用的语言并写入 Content-Language 响应头。
+// 优先级：?lang= 参数（同时写入 Cookie，作为该用户之后请求的语言）、Cookie、Accept-Language、默认语言
+func languageMiddleware(defaultLanguage string) gin.HandlerFunc {
+	if !i18n.Supported(defaultLanguage) {
+		defaultLanguage = i18n.DefaultLanguage
+	}
+	defaultLanguage = i18n.Normalize(defaultLanguage)
+
+	return func(c *gin.Context) {
+		lang := ""
+		if query := c.Query("lang"); query != "" && i18n.Supported(query) {
+			lang = i18n.Normalize(query)
+			http.SetCookie(c.Writer, &http.Cookie{
+				Name:     languageCookie,
+				Value:    lang,
+				Path:     "/",
+				MaxAge:   365 * 24 * 3600,
+				SameSite: http.SameSiteLaxMode,
+			})
+		} else if cookie, err := c.Cookie(languageCookie); err == nil && i18n.Supported(cookie) {
+			lang = i18n.Normalize(cookie)
+		} else {
+			lang = i18n.Match(c.GetHeader("Accept-Language"))
+		}
+		if lang == "" {
+			lang = defaultLanguage
+		}
+
+		c.Set(languageKey, lang)
+		c.Header("Content-Language", lang)
+		c.Next()
+	}
+}
+
+// language 获取当前请求的语言，未经过 languageMiddleware 时为中文
+func language(c *gin.Context) string {
+	if lang := c.GetString(languageKey); lang != "" {
+		return lang
+	}
+	return i18n.DefaultLanguage
+}
+
+// tr 按当前请求的语言翻译消息，args 按 fmt.Sprintf 格式化
+func tr(c *gin.Context, msgid string, args ...interface{}) string {
+	return i18n.T(language(c), msgid, args...)
+}
+
+// API 路由前缀
+const (
+	apiV1Prefix     = "/api/v1"
+	legacyAPIPrefix = "/api" // 未带版本的旧路径，映射到 v1
+)
+
+// apiVersionMiddleware 在响应头中标明 API 版本
+// successor 不为空表示当前路径已弃用，通过 Deprecation 和 Link 头提示客户端迁移到新路径
+func apiVersionMiddleware(version, successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("X-API-Version", version)
+		if successor != "" {
+			path := successor + strings.TrimPrefix(c.Request.URL.Path, legacyAPIPrefix)
+			c.Header("Deprecation", "true")
+			c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", path))
+		}
+		c.Next()
+	}
+}
+
+// loggerMiddleware 以 key=value 格式记录请求日志
+func loggerMiddleware(logger *log.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		if raw := c.Request.URL.RawQuery; raw != "" {
+			path += "?" + raw
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		icon := "🌐"
+		switch {
+		case status >= http.StatusInternalServerError:
+			icon = "❌"
+		case status >= http.StatusBadRequest:
+			icon = "⚠️"
+		}
+
+		line := fmt.Sprintf("%s request_id=%s method=%s path=%q status=%d latency=%s client_ip=%s size=%d",
+			icon, requestID(c), c.Request.Method, path, status, time.Since(start), c.ClientIP(), c.Writer.Size())
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			line += fmt.Sprintf(" errors=%q", strings.TrimSpace(errs))
+		}
+		logger.Print(line)
+	}
+}
+
+// recoveryMiddleware 捕获处理器 panic，将堆栈写入错误日志并返回 500
+// gin 自带的恢复日志不含请求ID，这里丢弃后自行记录
+func recoveryMiddleware(errorLogger *log.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err interface{}) {
+		errorLogger.Printf("💥 Panic recovered request_id=%s method=%s path=%s: %v\n%s",
+			requestID(c), c.Request.Method, c.Request.URL.Path, err, debug.Stack())
+		abortWithError(c, ErrCodeInternal, tr(c, "服务器内部错误"))
+	})
+}
+
+// corsMiddleware 跨域访问控制，未配置允许的来源时不添加任何 CORS 头
+func corsMiddleware(cfg config.CORSConfig) gin.HandlerFunc {
+	allowAll := false
+	allowed := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := ""
+	if d, err := time.ParseDuration(cfg.MaxAge); err == nil && d > 0 {
+		maxAge = strconv.Itoa(int(d.Seconds()))
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || (!allowAll && !allowed[origin]) {
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		// 携带凭证时不能返回 *，回显具体来源
+		if allowAll && !cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Add("Vary", "Origin")
+		}
+		if cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		h.Set("Access-Control-Expose-Headers", requestIDHeader)
+
+		// 预检请求
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", headers)
+			if maxAge != "" {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// adminAuthMiddleware 管理员认证，请求需携带 Authorization: Bearer <令牌>，或以 admin 角色登录
+func adminAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user, ok := currentUser(c); ok && user.Role == auth.RoleAdmin {
+			c.Next()
+			return
+		}
+		if !validAdminToken(c, token) {
+			c.Header("WWW-Authenticate", `Bearer realm="pikachun"`)
+			abortWithError(c, ErrCodeUnauthorized, tr(c, "需要管理员认证"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// bodyLimitMiddleware 限制请求体大小
+func bodyLimitMiddleware(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			abortWithError(c, ErrCodePayloadTooLarge, tr(c, "请求体过大，最大 %d 字节", limit))
+			return
+		}
+		// 未声明长度（分块传输）时由 MaxBytesReader 在读取时截断
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// gzipWriterPool 复用 gzip.Writer
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// gzipResponseWriter 延迟创建 gzip 流，没有响应体（如 204、304）时不输出 gzip 头
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	gz *gzip.Writer
+}
+
+// WriteHeader 压缩后长度未知，去掉处理器设置的 Content-Length
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write 写入压缩数据
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if w.gz == nil {
+		h := w.Header()
+		// 处理器已自行编码时原样输出
+		if h.Get("Content-Encoding") != "" {
+			return w.ResponseWriter.Write(data)
+		}
+		h.Set("Content-Encoding", "gzip")
+		h.Add("Vary", "Accept-Encoding")
+		h.Del("Content-Length")
+		w.gz = gzipWriterPool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	return w.gz.Write(data)
+}
+
+// WriteString 写入压缩字符串
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 刷新压缩缓冲区
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close 结束 gzip 流并归还 Writer
+func (w *gzipResponseWriter) close() {
+	if w.gz == nil {
+		return
+	}
+	w.gz.Close()
+	gzipWriterPool.Put(w.gz)
+	w.gz = nil
+}
+
+// gzipMiddleware 客户端支持时压缩响应
+func gzipMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		writer := &gzipResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer func() {
+			writer.close()
+			c.Writer = writer.ResponseWriter
+		}()
+
+		c.Next()
+	}
+}
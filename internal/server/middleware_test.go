@@ -0,0 +1,211 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"pikachun/internal/config"
+)
+
+// newMiddlewareTestRouter 创建带完整中间件的测试路由
+func newMiddlewareTestRouter(logs *bytes.Buffer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := log.New(logs, "", 0)
+
+	router := gin.New()
+	router.Use(
+		requestIDMiddleware(),
+		loggerMiddleware(logger),
+		recoveryMiddleware(logger),
+		corsMiddleware(config.CORSConfig{
+			AllowedOrigins: []string{"http://ui.example.com"},
+			AllowedMethods: []string{"GET", "POST"},
+			AllowedHeaders: []string{"Content-Type"},
+			MaxAge:         "1h",
+		}),
+		bodyLimitMiddleware(16),
+		gzipMiddleware(),
+	)
+	router.GET("/ok", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": strings.Repeat("pikachun", 10)})
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	router.POST("/echo", func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	return router
+}
+
+// TestRequestIDAndLogging 测试请求ID透传与请求日志
+func TestRequestIDAndLogging(t *testing.T) {
+	var logs bytes.Buffer
+	router := newMiddlewareTestRouter(&logs)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set(requestIDHeader, "req-123")
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get(requestIDHeader); got != "req-123" {
+		t.Errorf("expected request id to be propagated, got %q", got)
+	}
+	if !strings.Contains(logs.String(), "request_id=req-123") || !strings.Contains(logs.String(), "status=200") {
+		t.Errorf("unexpected request log: %s", logs.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if w.Header().Get(requestIDHeader) == "" {
+		t.Errorf("expected generated request id")
+	}
+}
+
+// TestRecoveryMiddleware 测试 panic 被捕获并记录
+func TestRecoveryMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	router := newMiddlewareTestRouter(&logs)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), w.Header().Get(requestIDHeader)) {
+		t.Errorf("expected request id in error response: %s", w.Body.String())
+	}
+	if !strings.Contains(logs.String(), "Panic recovered") || !strings.Contains(logs.String(), "boom") {
+		t.Errorf("panic not reported to error log: %s", logs.String())
+	}
+}
+
+// TestCORSMiddleware 测试跨域预检与来源校验
+func TestCORSMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	router := newMiddlewareTestRouter(&logs)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/ok", nil)
+	req.Header.Set("Origin", "http://ui.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204 for preflight, got %d", w.Code)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "http://ui.example.com" || w.Header().Get("Access-Control-Max-Age") != "3600" {
+		t.Errorf("unexpected preflight headers: %v", w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set("Origin", "http://evil.example.com")
+	router.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("unexpected CORS header for disallowed origin")
+	}
+}
+
+// TestGzipAndBodyLimit 测试响应压缩与请求体大小限制
+func TestGzipAndBodyLimit(t *testing.T) {
+	var logs bytes.Buffer
+	router := newMiddlewareTestRouter(&logs)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip response, headers: %v", w.Header())
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("invalid gzip body: %v", err)
+	}
+	body, _ := io.ReadAll(gz)
+	if !strings.Contains(string(body), "pikachun") {
+		t.Errorf("unexpected decompressed body: %s", body)
+	}
+
+	// 无响应体时不输出 gzip 流
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("small"))
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("expected empty 204, got %d with %d bytes", w.Code, w.Body.Len())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(strings.Repeat("x", 64))))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for oversized body, got %d", w.Code)
+	}
+}
+
+// TestLanguageMiddleware 测试语言的选择顺序和会话 Cookie
+func TestLanguageMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(languageMiddleware("zh"), adminAuthMiddleware("secret"))
+	router.GET("/tasks", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": tr(c, "任务删除成功")})
+	})
+
+	serve := func(target string, setup func(req *http.Request)) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if setup != nil {
+			setup(req)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 默认语言
+	w := serve("/tasks", nil)
+	if w.Header().Get("Content-Language") != "zh" || !strings.Contains(w.Body.String(), "需要管理员认证") {
+		t.Errorf("expected default chinese response, got %s: %s", w.Header().Get("Content-Language"), w.Body.String())
+	}
+
+	// Accept-Language
+	w = serve("/tasks", func(req *http.Request) { req.Header.Set("Accept-Language", "en-US,en;q=0.9") })
+	if !strings.Contains(w.Body.String(), "Admin authentication required") {
+		t.Errorf("expected english error, got %s", w.Body.String())
+	}
+
+	// ?lang= 覆盖 Accept-Language 并写入 Cookie
+	w = serve("/tasks?lang=zh", func(req *http.Request) { req.Header.Set("Accept-Language", "en") })
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != languageCookie || cookies[0].Value != "zh" {
+		t.Fatalf("expected language cookie, got %v", cookies)
+	}
+	if !strings.Contains(w.Body.String(), "需要管理员认证") {
+		t.Errorf("expected chinese error, got %s", w.Body.String())
+	}
+
+	// Cookie 优先于 Accept-Language，不支持的语言被忽略
+	w = serve("/tasks?lang=xx", func(req *http.Request) {
+		req.Header.Set("Accept-Language", "zh")
+		req.AddCookie(&http.Cookie{Name: languageCookie, Value: "en"})
+		req.Header.Set("Authorization", "Bearer secret")
+	})
+	if w.Header().Get("Content-Language") != "en" || !strings.Contains(w.Body.String(), "Task deleted") {
+		t.Errorf("expected english response from cookie, got %s", w.Body.String())
+	}
+}
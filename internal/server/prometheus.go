@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"pikachun/internal/service"
+	"pikachun/internal/slo"
+)
+
+// prometheusContentType Prometheus 文本格式 0.0.4
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// prometheusMetricsHandler 以 Prometheus 文本格式导出各任务的状态、SLO 指标和准入上限的使用情况
+func (h *EnhancedHandlers) prometheusMetricsHandler(c *gin.Context) {
+	report, err := h.enhancedCanalService.SLOReport()
+	if err != nil {
+		c.String(http.StatusInternalServerError, "# failed to compute SLO report: %v\n", err)
+		return
+	}
+	c.Data(http.StatusOK, prometheusContentType, renderPrometheusMetrics(report, h.enhancedCanalService.AdmissionStatus()))
+}
+
+// metricFamily 一个指标及其所有样本
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string // gauge 或 counter
+	samples []string
+}
+
+func (m *metricFamily) add(labels string, value float64) {
+	m.samples = append(m.samples, m.name+"{"+labels+"} "+formatMetricValue(value))
+}
+
+// renderPrometheusMetrics 渲染 SLO 统计结果和准入上限。达标率、剩余错误预算和 burn rate 只在有数据时导出，
+// 告警规则可直接比较阈值，如 pikachun_task_slo_burn_rate{window="1h"} > 14.4；
+// 上限只导出已配置的项，如 pikachun_limit_current / pikachun_limit_max > 0.8
+func renderPrometheusMetrics(report *service.SLOReport, limits []service.AdmissionUsage) []byte {
+	up := &metricFamily{name: "pikachun_task_up", kind: "gauge",
+		help: "Whether the task's canal instance is running (1) or not (0)."}
+	lag := &metricFamily{name: "pikachun_task_lag_seconds", kind: "gauge",
+		help: "Replication lag of the task, end-to-end heartbeat freshness when heartbeats are enabled."}
+	delivered := &metricFamily{name: "pikachun_task_delivered_events_total", kind: "counter",
+		help: "Events delivered to the webhook by the task's current instance."}
+	failed := &metricFamily{name: "pikachun_task_failed_events_total", kind: "counter",
+		help: "Events that failed delivery after all retries in the task's current instance."}
+	target := &metricFamily{name: "pikachun_task_slo_target", kind: "gauge",
+		help: "Configured SLO target ratio."}
+	ratio := &metricFamily{name: "pikachun_task_slo_ratio", kind: "gauge",
+		help: "Good ratio of the SLO over the SLO window."}
+	budget := &metricFamily{name: "pikachun_task_slo_error_budget_remaining", kind: "gauge",
+		help: "Fraction of the error budget left over the SLO window (1 untouched, below 0 overspent)."}
+	burn := &metricFamily{name: "pikachun_task_slo_burn_rate", kind: "gauge",
+		help: "Error rate over the window divided by the allowed error rate (1 exhausts the budget exactly at the end of the SLO window)."}
+	limitMax := &metricFamily{name: "pikachun_limit_max", kind: "gauge",
+		help: "Configured admission limit (canal.limits)."}
+	limitCurrent := &metricFamily{name: "pikachun_limit_current", kind: "gauge",
+		help: "Current usage of the admission limit."}
+	rejected := &metricFamily{name: "pikachun_admission_rejected_total", kind: "counter",
+		help: "Requests and instance starts rejected because the admission limit was reached."}
+
+	for _, task := range report.Tasks {
+		labels := fmt.Sprintf(`task_id="%d",task="%s"`, task.TaskID, escapeLabelValue(task.TaskName))
+
+		running := 0.0
+		if task.Running {
+			running = 1
+		}
+		up.add(labels, running)
+		lag.add(labels, task.LagSeconds)
+		delivered.add(labels, float64(task.Delivered))
+		failed.add(labels, float64(task.Failed))
+
+		for _, o := range task.Objectives {
+			sloLabels := labels + `,slo="` + o.Name + `"`
+			target.add(sloLabels, o.Target)
+			if o.HasData {
+				ratio.add(sloLabels+`,window="`+report.Window+`"`, o.Ratio)
+				budget.add(sloLabels+`,window="`+report.Window+`"`, o.BudgetRemaining)
+			}
+			for _, w := range slo.BurnRateWindows {
+				if rate, ok := o.BurnRates[w.Name]; ok {
+					burn.add(sloLabels+`,window="`+w.Name+`"`, rate)
+				}
+			}
+		}
+	}
+
+	for _, usage := range limits {
+		labels := `limit="` + usage.Limit + `"`
+		if usage.Max > 0 {
+			limitMax.add(labels, float64(usage.Max))
+		}
+		limitCurrent.add(labels, float64(usage.Current))
+		rejected.add(labels, float64(usage.Rejected))
+	}
+
+	var buf bytes.Buffer
+	for _, m := range []*metricFamily{up, lag, delivered, failed, target, ratio, budget, burn, limitMax, limitCurrent, rejected} {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, sample := range m.samples {
+			buf.WriteString(sample)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// labelValueEscaper 转义标签值中的反斜杠、双引号和换行
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
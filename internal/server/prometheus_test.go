@@ -0,0 +1,70 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"pikachun/internal/service"
+	"pikachun/internal/slo"
+)
+
+// TestRenderPrometheusMetrics 测试 SLO 指标的文本格式：标签转义、没有数据的 SLO 不导出达标率和 burn rate，
+// 未配置的准入上限不导出上限值
+func TestRenderPrometheusMetrics(t *testing.T) {
+	report := &service.SLOReport{
+		Window: "28d",
+		Tasks: []service.TaskSLO{
+			{
+				TaskID:     1,
+				TaskName:   `orders "main"`,
+				Running:    true,
+				LagSeconds: 1.5,
+				Delivered:  120,
+				Failed:     3,
+				Objectives: []slo.Objective{
+					{Name: slo.Availability, Target: 0.999, HasData: true, Ratio: 0.9995, BudgetRemaining: 0.5,
+						BurnRates: map[string]float64{"5m": 0, "1h": 2}},
+					{Name: slo.Delivery, Target: 0.999, BurnRates: map[string]float64{}},
+				},
+			},
+		},
+	}
+
+	limits := []service.AdmissionUsage{
+		{Limit: service.LimitTasksPerSource, Max: 50, Current: 12, Rejected: 4},
+		{Limit: service.LimitInstances, Current: 10},
+	}
+
+	out := string(renderPrometheusMetrics(report, limits))
+	for _, want := range []string{
+		"# TYPE pikachun_task_up gauge\n",
+		`pikachun_task_up{task_id="1",task="orders \"main\""} 1` + "\n",
+		`pikachun_task_lag_seconds{task_id="1",task="orders \"main\""} 1.5` + "\n",
+		"# TYPE pikachun_task_failed_events_total counter\n",
+		`pikachun_task_failed_events_total{task_id="1",task="orders \"main\""} 3` + "\n",
+		`pikachun_task_slo_target{task_id="1",task="orders \"main\"",slo="delivery"} 0.999` + "\n",
+		`pikachun_task_slo_ratio{task_id="1",task="orders \"main\"",slo="availability",window="28d"} 0.9995` + "\n",
+		`pikachun_task_slo_error_budget_remaining{task_id="1",task="orders \"main\"",slo="availability",window="28d"} 0.5` + "\n",
+		`pikachun_task_slo_burn_rate{task_id="1",task="orders \"main\"",slo="availability",window="1h"} 2` + "\n",
+		`pikachun_task_slo_burn_rate{task_id="1",task="orders \"main\"",slo="availability",window="5m"} 0` + "\n",
+		`pikachun_limit_max{limit="max_tasks_per_source"} 50` + "\n",
+		`pikachun_limit_current{limit="max_tasks_per_source"} 12` + "\n",
+		"# TYPE pikachun_admission_rejected_total counter\n",
+		`pikachun_admission_rejected_total{limit="max_tasks_per_source"} 4` + "\n",
+		`pikachun_limit_current{limit="max_instances"} 10` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `pikachun_limit_max{limit="max_instances"}`) {
+		t.Errorf("expected no maximum for an unconfigured limit:\n%s", out)
+	}
+	if strings.Contains(out, `slo="delivery",window=`) {
+		t.Errorf("expected no ratio or burn rate for an SLO without data:\n%s", out)
+	}
+	// burn rate 按窗口从短到长排列
+	if strings.Index(out, `window="5m"`) > strings.Index(out, `window="1h"`) {
+		t.Errorf("expected burn rate windows in order:\n%s", out)
+	}
+}
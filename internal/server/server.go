@@ -4,14 +4,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html/template"
+	"io"
+	"log"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"pikachun/internal/auth"
+	"pikachun/internal/canal"
 	"pikachun/internal/config"
 	"pikachun/internal/database"
+	"pikachun/internal/i18n"
 	"pikachun/internal/service"
+	"pikachun/internal/version"
 )
 
 // Server HTTP服务器
@@ -20,8 +32,14 @@ type Server struct {
 	taskService      *service.TaskService
 	canalService     service.CanalServiceInterface
 	enhancedHandlers *EnhancedHandlers
+	auth             *auth.Authenticator // 登录认证，未配置用户时不启用
 	// enhancedCanalService *service.EnhancedCanalService
 	router *gin.Engine
+	webDir string // 页面模板和静态文件所在目录
+
+	mu         sync.Mutex
+	httpServer *http.Server
+	closed     bool // 已调用 Shutdown，之后的 Start 直接返回
 }
 
 // CanalServiceAdapter Canal服务适配器
@@ -64,6 +82,9 @@ func (a *CanalServiceAdapter) GetStatus() map[string]interface{} {
 // New 创建服务器实例
 // New 创建服务器实例
 func New(cfg *config.Config, taskService *service.TaskService, canalService service.CanalServiceInterface) *Server {
+	// 任务请求的校验规则需在第一次校验前注册
+	registerValidations()
+
 	// 创建增强处理器
 	var enhancedHandlers *EnhancedHandlers
 
@@ -84,62 +105,335 @@ func New(cfg *config.Config, taskService *service.TaskService, canalService serv
 		taskService:      taskService,
 		canalService:     canalService,
 		enhancedHandlers: enhancedHandlers,
+		webDir:           "web",
 	}
 }
 
-// Start 启动服务器
+// Start 启动服务器，阻塞直到服务器关闭；通过 Shutdown 正常关闭时返回 nil
 func (s *Server) Start() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	if err := s.setupAuth(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
 	s.setupRouter()
-	addr := s.config.Server.Host + ":" + s.config.Server.Port
-	return s.router.Run(addr)
+	s.httpServer = &http.Server{
+		Addr:    s.config.Server.Host + ":" + s.config.Server.Port,
+		Handler: s.router,
+	}
+	httpServer := s.httpServer
+	s.mu.Unlock()
+
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown 停止接收新连接，等待进行中的请求完成后释放端口
+// ctx 超时后强制关闭剩余连接
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	httpServer := s.httpServer
+	s.mu.Unlock()
+
+	if httpServer == nil {
+		return nil
+	}
+	if err := httpServer.Shutdown(ctx); err != nil {
+		httpServer.Close()
+		return fmt.Errorf("failed to shutdown http server: %v", err)
+	}
+	return nil
+}
+
+// setupAuth 根据配置的用户创建认证器，配置无效时拒绝启动，避免在未认证的情况下开放服务
+func (s *Server) setupAuth() error {
+	ttl, err := time.ParseDuration(s.config.Server.SessionTTL)
+	if err != nil && s.config.Server.SessionTTL != "" {
+		return fmt.Errorf("invalid session_ttl %q: %v", s.config.Server.SessionTTL, err)
+	}
+	authn, err := auth.New(s.config.Server.Users, ttl)
+	if err != nil {
+		return fmt.Errorf("invalid users config: %v", err)
+	}
+	if authn.Enabled() {
+		log.Printf("🔑 Login required for web UI and API (%d users)", len(s.config.Server.Users))
+	}
+	s.auth = authn
+	return nil
 }
 
 // setupRouter 设置路由
 func (s *Server) setupRouter() {
 	// 设置Gin模式
 	gin.SetMode(gin.ReleaseMode)
-	s.router = gin.Default()
+	s.router = gin.New()
+
+	// 集成方提供的消息目录，覆盖或补充内置翻译
+	if dir := s.config.Server.LocalesDir; dir != "" {
+		if err := i18n.LoadDir(dir); err != nil {
+			log.Printf("⚠️ Failed to load locales: %v", err)
+		}
+	}
+
+	// 中间件：请求ID需最先执行，日志和 panic 恢复都依赖它；语言在日志和 panic 恢复之前确定
+	s.router.Use(
+		requestIDMiddleware(),
+		languageMiddleware(s.config.Server.Language),
+		sessionMiddleware(s.auth),
+		loggerMiddleware(log.New(gin.DefaultWriter, "[HTTP] ", log.LstdFlags)),
+		recoveryMiddleware(log.New(gin.DefaultErrorWriter, "[HTTP] ", log.LstdFlags)),
+		corsMiddleware(s.config.Server.CORS),
+	)
+	if s.config.Server.MaxBodySize > 0 {
+		s.router.Use(bodyLimitMiddleware(s.config.Server.MaxBodySize))
+	}
+	if s.config.Server.Gzip {
+		s.router.Use(gzipMiddleware())
+	}
 
 	// 静态文件服务
-	s.router.Static("/static", "./web/static")
-	s.router.LoadHTMLGlob("web/templates/*")
+	s.router.Static("/static", filepath.Join(s.webDir, "static"))
+	s.router.SetFuncMap(template.FuncMap{"t": i18n.T})
+	s.router.LoadHTMLGlob(filepath.Join(s.webDir, "templates", "*"))
 
-	// 首页
+	// 首页和登录
 	s.router.GET("/", s.indexHandler)
+	s.registerSessionRoutes()
+
+	// 就绪检查，维护暂停期间返回 503
+	s.router.GET("/readyz", s.readyzHandler)
+
+	// API 文档
+	registerSwaggerRoutes(s.router)
 
-	// API路由组
-	api := s.router.Group("/api")
+	// 调试接口
+	s.registerDebugRoutes()
+
+	// API路由：/api/v1 为当前版本，/api 为兼容旧客户端的别名，行为与 v1 一致
+	// 配置了用户时需要登录
+	login := requireLogin(s.auth, s.config.Server.AdminToken)
+
+	// Prometheus 指标（任务状态和 SLO），配置了用户时需要登录或管理员令牌
+	if s.enhancedHandlers != nil {
+		s.router.GET("/metrics", login, s.enhancedHandlers.prometheusMetricsHandler)
+	}
+	s.registerAPIRoutes(s.router.Group(apiV1Prefix, apiVersionMiddleware("v1", ""), login))
+	s.registerAPIRoutes(s.router.Group(legacyAPIPrefix, apiVersionMiddleware("v1", apiV1Prefix), login))
+}
+
+// registerAPIRoutes 注册 API 路由
+func (s *Server) registerAPIRoutes(api *gin.RouterGroup) {
+	// 任务管理
+	tasks := api.Group("/tasks")
 	{
-		// 任务管理
-		tasks := api.Group("/tasks")
-		{
-			tasks.GET("", s.getTasksHandler)
-			tasks.POST("", s.createTaskHandler)
-			tasks.GET("/:id", s.getTaskHandler)
-			tasks.PUT("/:id", s.updateTaskHandler)
-			tasks.DELETE("/:id", s.deleteTaskHandler)
+		tasks.GET("", s.getTasksHandler)
+		tasks.POST("", s.createTaskHandler)
+		// 批量创建任务
+		tasks.POST("/bulk", s.bulkCreateTasksHandler)
+		tasks.GET("/:id", s.getTaskHandler)
+		tasks.PUT("/:id", s.updateTaskHandler)
+		tasks.DELETE("/:id", s.deleteTaskHandler)
+		// 已删除的任务在保留期内可以还原
+		tasks.GET("/deleted", s.getDeletedTasksHandler)
+		tasks.POST("/:id/restore", s.restoreTaskHandler)
+
+		// binlog 被清除后的恢复操作
+		if s.enhancedHandlers != nil {
+			tasks.POST("/:id/recover", s.enhancedHandlers.recoverTaskHandler)
+			// 重启实例：重新连接源库并重建表结构缓存
+			tasks.POST("/:id/restart", s.enhancedHandlers.restartTaskHandler)
+			// 注入模拟事件，用于下游的端到端测试
+			tasks.POST("/:id/simulate", s.enhancedHandlers.simulateTaskEventHandler)
+			// 停滞检测触发的 binlog 流重启记录
+			tasks.GET("/:id/restarts", s.enhancedHandlers.taskRestartsHandler)
+			// 事件校验和状态和被跳过的损坏事件
+			tasks.GET("/:id/checksum", s.enhancedHandlers.taskChecksumHandler)
+			// 数据质量报告：违规计数和最近的违规事件
+			tasks.GET("/:id/quality", s.enhancedHandlers.taskQualityHandler)
+			// 以 SSE 实时推送任务实例的日志
+			tasks.GET("/:id/logs/stream", s.enhancedHandlers.streamTaskLogsHandler)
+		}
+		// Webhook 请求序号与 binlog 位置的对应关系，用于消费端恢复
+		tasks.GET("/:id/cursor", s.getDeliveryCursorHandler)
+		// 长轮询拉取事件，供无法接收 Webhook 的消费端使用，位置按消费者保存
+		tasks.GET("/:id/events", s.pullEventsHandler)
+		// 消费端契约：投递前校验事件，违约的事件记为失败
+		tasks.GET("/:id/contract", s.getTaskContractHandler)
+		tasks.PUT("/:id/contract", s.putTaskContractHandler)
+		tasks.DELETE("/:id/contract", s.deleteTaskContractHandler)
+	}
+
+	// 源库中的表及其 binlog 活跃度
+	if s.enhancedHandlers != nil {
+		api.GET("/sources/:id/tables", s.enhancedHandlers.sourceTablesHandler)
+		// 源库预检：复制权限、binlog 配置和 server_id
+		api.GET("/sources/:id/preflight", s.enhancedHandlers.sourcePreflightHandler)
+		// 时间点对应的 binlog 位置，用于从该时间点重放
+		api.GET("/sources/:id/position", s.enhancedHandlers.sourcePositionHandler)
+	}
+
+	// 源库账号轮换，配置了管理员令牌时需要认证
+	if s.enhancedHandlers != nil {
+		credentials := api.Group("/sources")
+		if token := s.config.Server.AdminToken; token != "" || s.auth.Enabled() {
+			credentials.Use(adminAuthMiddleware(token))
 		}
+		credentials.PUT("/:id/credentials", s.enhancedHandlers.rotateCredentialsHandler)
+	}
 
-		// 事件日志
-		api.GET("/logs", s.getEventLogsHandler)
-		api.GET("/logs/:id", s.getEventLogHandler)
+	// binlog 位置管理：查看各任务位置和延迟；修改、重置持久化位置、重放单个 sink 和从本地 binlog 文件回填需要管理员认证
+	if s.enhancedHandlers != nil {
+		api.GET("/positions", s.enhancedHandlers.positionsHandler)
+		positions := api.Group("/tasks")
+		if token := s.config.Server.AdminToken; token != "" || s.auth.Enabled() {
+			positions.Use(adminAuthMiddleware(token))
+		}
+		positions.PUT("/:id/position", s.enhancedHandlers.setTaskPositionHandler)
+		positions.POST("/:id/position/reset", s.enhancedHandlers.resetTaskPositionHandler)
+		positions.POST("/:id/sinks/:sink/replay", s.enhancedHandlers.replaySinkHandler)
+		positions.POST("/:id/backfill", s.enhancedHandlers.backfillTaskHandler)
+	}
 
-		// 系统状态
-		api.GET("/status", s.getStatusHandler)
+	// 维护暂停：协调源库维护时停止所有实例读取 binlog，配置了管理员令牌时需要认证
+	if s.enhancedHandlers != nil {
+		admin := api.Group("/admin")
+		if token := s.config.Server.AdminToken; token != "" || s.auth.Enabled() {
+			admin.Use(adminAuthMiddleware(token))
+		}
+		admin.POST("/pause", s.enhancedHandlers.pauseReadingHandler)
+		admin.POST("/resume", s.enhancedHandlers.resumeReadingHandler)
+	}
 
-		// 增强功能 API
-		api.GET("/metrics", s.getPerformanceMetricsHandler)
+	// 全局监听策略：额外监听的表、读取的事件类型和排除规则，修改即时推送到运行中的实例，需要管理员认证
+	if s.enhancedHandlers != nil {
+		watch := api.Group("/watch")
+		if token := s.config.Server.AdminToken; token != "" || s.auth.Enabled() {
+			watch.Use(adminAuthMiddleware(token))
+		}
+		watch.GET("", s.enhancedHandlers.watchPolicyHandler)
+		watch.PUT("", s.enhancedHandlers.setWatchPolicyHandler)
 	}
+
+	// 事件日志
+	api.GET("/logs", s.getEventLogsHandler)
+	api.GET("/logs/export", s.exportEventLogsHandler)
+	api.GET("/logs/:id", s.getEventLogHandler)
+	api.GET("/logs/:id/deliveries", s.getEventDeliveriesHandler)
+	api.POST("/logs/:id/redeliver", s.redeliverEventHandler)
+
+	// 变更分析：各表的行变更数和变更频率
+	api.GET("/analytics/tables", s.hottestTablesHandler)
+	api.GET("/analytics/tables/:database/:table", s.tableChangeSeriesHandler)
+
+	// 系统状态
+	api.GET("/status", s.getStatusHandler)
+	// 构建信息和启用的功能，用于核对部署的版本
+	api.GET("/version", s.getVersionHandler)
+	// 当前语言的消息目录，?lang= 切换语言
+	api.GET("/i18n", s.getMessagesHandler)
+	// 当前登录用户和最近操作
+	api.GET("/session", s.getSessionHandler)
+
+	// 增强功能 API
+	api.GET("/metrics", s.getPerformanceMetricsHandler)
 }
 
 // indexHandler 首页处理器
 func (s *Server) indexHandler(c *gin.Context) {
+	user, loggedIn := currentUser(c)
+	if s.auth.Enabled() && !loggedIn {
+		c.Redirect(http.StatusSeeOther, "/login")
+		return
+	}
+
+	lang := language(c)
+	canMutate := !loggedIn || user.Role.CanMutate()
 	c.HTML(http.StatusOK, "index.html", gin.H{
-		"title": "Pikachun - 数据库监听服务",
+		"title":     tr(c, "Pikachun - 数据库监听服务"),
+		"lang":      lang,
+		"languages": i18n.Languages(),
+		"i18n": gin.H{
+			"language": lang,
+			"messages": i18n.Messages(lang),
+		},
+		"user":      user,
+		"loggedIn":  loggedIn,
+		"canMutate": canMutate,
+		"session": gin.H{
+			"logged_in":  loggedIn,
+			"can_mutate": canMutate,
+		},
 	})
 }
 
+// readyzHandler 就绪检查：服务未运行或处于维护暂停时返回 503
+func (s *Server) readyzHandler(c *gin.Context) {
+	if s.canalService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "stopped"})
+		return
+	}
+
+	maintenance, _ := s.canalService.GetStatus()["maintenance"].(canal.MaintenanceStatus)
+	if maintenance.Paused {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":      "paused",
+			"maintenance": maintenance,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// preflight 创建任务前检查源库权限和 binlog 配置，未启用预检或请求带 skip_preflight=true 时返回 nil。
+// 源库暂时不可达或权限通过其他方式授予时，可以跳过预检创建任务，实例启动失败时任务进入 pending 后台重试
+func (s *Server) preflight(c *gin.Context, tasks ...*database.Task) *canal.PreflightReport {
+	if s.enhancedHandlers == nil || !s.config.Canal.Preflight {
+		return nil
+	}
+	if c.Query("skip_preflight") == "true" {
+		log.Printf("⚠️ Source preflight skipped by request for %d tasks", len(tasks))
+		return nil
+	}
+	tables := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		tables = append(tables, task.Database+"."+task.Table)
+	}
+	return s.enhancedHandlers.enhancedCanalService.Preflight(tables)
+}
+
+// admit 按 canal.limits 检查能否为 tasks 中的活跃任务启动实例，达到上限时返回 LimitError；
+// 任务数上限由 TaskService 在创建时检查
+func (s *Server) admit(tasks ...*database.Task) error {
+	if s.enhancedHandlers == nil {
+		return nil
+	}
+	n := 0
+	for _, task := range tasks {
+		if task.Status == "active" {
+			n++
+		}
+	}
+	return s.enhancedHandlers.enhancedCanalService.AdmitInstances(n)
+}
+
 // getTasksHandler 获取任务列表
+//
+//	@Summary	获取任务列表
+//	@Tags		tasks
+//	@ID			listTasks
+//	@Produce	json
+//	@Param		page		query		int																			false	"页码，从 1 开始"
+//	@Param		page_size	query		int																			false	"每页条数"
+//	@Success	200			{object}	object{data=object{tasks=[]database.Task,total=int,page=int,page_size=int}}	"任务列表"
+//	@Failure	500			{object}	APIError																	"查询失败"
+//	@Router		/tasks [get]
 func (s *Server) getTasksHandler(c *gin.Context) {
 	page := 1
 	pageSize := 10
@@ -158,9 +452,7 @@ func (s *Server) getTasksHandler(c *gin.Context) {
 
 	tasks, total, err := s.taskService.GetTasks(page, pageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "获取任务列表失败: " + err.Error(),
-		})
+		respondError(c, ErrCodeInternal, tr(c, "获取任务列表失败: %v", err))
 		return
 	}
 
@@ -175,27 +467,78 @@ func (s *Server) getTasksHandler(c *gin.Context) {
 }
 
 // createTaskHandler 创建任务
+//
+//	@Summary	创建任务
+//	@Tags		tasks
+//	@ID			createTask
+//	@Accept		json
+//	@Produce	json
+//	@Param		Idempotency-Key	header		string						false	"幂等键，使用相同幂等键重试的请求返回已创建的任务，不重复创建"												maxlength(100)
+//	@Param		skip_preflight	query		bool						false	"为 true 时跳过源库预检，用于源库暂时不可达或权限无法自动确认（如通过未激活的角色授权）时创建任务；实例启动失败时任务进入 pending 后台重试"	default(false)
+//	@Param		request			body		CreateTaskRequest			true	"请求体"
+//	@Success	200				{object}	object{data=database.Task}	"幂等键已对应任务，返回已创建的任务（响应头 Idempotent-Replayed: true）"
+//	@Success	201				{object}	object{data=database.Task}	"创建成功"
+//	@Success	202				{object}	object{data=database.Task}	"任务已创建但实例启动失败，任务状态为 pending，由后台重试启动（message 中为原因）"
+//	@Failure	400				{object}	APIError					"请求体无法解析、含未知字段（details.fields 中为未知字段）或幂等键过长（invalid_request）"
+//	@Failure	409				{object}	APIError					"幂等键已用于其他库表或回调地址"
+//	@Failure	422				{object}	APIError					"请求参数或任务配置校验失败（validation_failed，details.fields 中为每个字段的错误）、源库预检未通过（preflight_failed，details 中为预检报告）或回调地址检查未通过（sink_check_failed）"
+//	@Failure	500				{object}	APIError					"创建或启动监听失败"
+//	@Failure	429				{object}	APIError{details=service.LimitError}					"达到 canal.limits 的任务数、实例数或复制连接数上限（limit_exceeded）"
+//	@Router		/tasks [post]
 func (s *Server) createTaskHandler(c *gin.Context) {
 	var req CreateTaskRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "请求参数错误: " + err.Error(),
-		})
+	if !bindStrictJSON(c, &req) {
 		return
 	}
 
 	task := req.ToTask()
+
+	// 幂等键：重试的请求返回已创建的任务，不重复创建
+	key := strings.TrimSpace(c.GetHeader(idempotencyKeyHeader))
+	if len(key) > maxIdempotencyKeyLen {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "幂等键不能超过 %d 个字符", maxIdempotencyKeyLen))
+		return
+	}
+	if key != "" {
+		if s.replayCreateTask(c, key, task) {
+			return
+		}
+		task.IdempotencyKey = &key
+	}
+
+	if report := s.preflight(c, task); report != nil && !report.Passed {
+		respondErrorDetails(c, ErrCodePreflightFailed, tr(c, "源库预检未通过: %v", report.Err()), report)
+		return
+	}
+	if err := s.checkSink(task); err != nil {
+		respondError(c, ErrCodeSinkCheckFailed, tr(c, "回调地址检查未通过: %v", err))
+		return
+	}
+	if err := s.admit(task); err != nil {
+		respondTaskError(c, err, "创建任务失败: %v")
+		return
+	}
 	if err := s.taskService.CreateTask(task); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "创建任务失败: " + err.Error(),
-		})
+		// 并发的重试请求已先创建了任务
+		if key != "" && s.replayCreateTask(c, key, task) {
+			return
+		}
+		respondTaskError(c, err, "创建任务失败: %v")
 		return
 	}
 
-	// 启动Canal实例来监听binlog
+	// 启动Canal实例来监听binlog，失败时任务保留为 pending，由后台重试启动
 	if err := s.canalService.CreateTask(task); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "启动Canal监听失败: " + err.Error(),
+		if markErr := s.deferTaskStart(task, err); markErr != nil {
+			// 无法标记为 pending 时回滚，不留下没有实例的 active 任务
+			s.taskService.PurgeTask(task.ID)
+			s.dropTaskLogs(task.ID)
+			respondError(c, ErrCodeInstanceFailed, tr(c, "启动Canal监听失败: %v", err))
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"data":    task,
+			"message": tr(c, "启动Canal监听失败，任务将在后台重试启动: %v", err),
 		})
 		return
 	}
@@ -205,21 +548,234 @@ func (s *Server) createTaskHandler(c *gin.Context) {
 	})
 }
 
+// 创建任务请求的幂等键
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 100
+)
+
+// replayCreateTask 幂等键已对应任务时返回该任务，返回 true 表示已响应。
+// 幂等键已用于其他库表或回调地址时返回 409；任务删除后幂等键随之释放
+func (s *Server) replayCreateTask(c *gin.Context, key string, task *database.Task) bool {
+	existing, err := s.taskService.GetTaskByIdempotencyKey(key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false
+	}
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "获取任务失败: %v", err))
+		return true
+	}
+	if existing.Database != task.Database || existing.Table != task.Table || existing.CallbackURL != task.CallbackURL {
+		respondError(c, ErrCodeConflict, tr(c, "幂等键已用于其他任务"))
+		return true
+	}
+
+	// 上次请求可能在启动实例前中断，实例已在运行时不会重复启动；pending 任务由后台重试启动
+	if existing.Status == "active" {
+		if err := s.canalService.CreateTask(existing); err != nil {
+			if markErr := s.deferTaskStart(existing, err); markErr != nil {
+				respondError(c, ErrCodeInstanceFailed, tr(c, "启动Canal监听失败: %v", err))
+				return true
+			}
+		}
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(http.StatusOK, gin.H{
+		"data": existing,
+	})
+	return true
+}
+
+// sinkCheckTimeout 创建任务前检查 Webhook 端点的超时
+const sinkCheckTimeout = 5 * time.Second
+
+// checkSink 按 canal.sink_check 检查任务的 Webhook 端点是否可达，未开启时返回 nil
+func (s *Server) checkSink(task *database.Task) error {
+	if !s.config.Canal.SinkCheck {
+		return nil
+	}
+	return canal.CheckWebhookEndpoint(task.CallbackURL, sinkCheckTimeout)
+}
+
+// dropTaskLogs 丢弃已删除任务的日志，只有增强服务记录任务日志
+func (s *Server) dropTaskLogs(id uint) {
+	if s.enhancedHandlers != nil {
+		s.enhancedHandlers.enhancedCanalService.DropTaskLogs(id)
+	}
+}
+
+// deferTaskStart 实例启动失败时将已创建的任务标记为 pending，由后台定时重试启动，
+// 而不是留下状态为 active 却没有实例的任务
+func (s *Server) deferTaskStart(task *database.Task, startErr error) error {
+	if err := s.taskService.MarkTaskPending(task.ID, startErr.Error()); err != nil {
+		return err
+	}
+	now := time.Now()
+	task.Status = "pending"
+	task.LastError = startErr.Error()
+	task.LastErrorAt = &now
+	return nil
+}
+
+// bulkCreateTasksHandler 批量创建任务，任一任务校验失败时不创建任何任务，并返回每个任务的校验结果
+//
+//	@Summary		批量创建任务
+//	@Description	每个任务单独校验，全部通过后在一个事务中创建；任一任务无效时不创建任何任务，并返回每个任务的校验结果。
+//	@Tags			tasks
+//	@ID				bulkCreateTasks
+//	@Accept			json
+//	@Produce		json
+//	@Param			skip_preflight	query		bool														false	"为 true 时跳过源库预检，用于源库暂时不可达或权限无法自动确认（如通过未激活的角色授权）时创建任务；实例启动失败时任务进入 pending 后台重试"	default(false)
+//	@Param			request			body		BulkCreateTasksRequest										true	"请求体"
+//	@Success		201				{object}	object{data=object{created=int,results=[]BulkTaskResult}}	"创建成功，单个任务启动监听失败时记录在对应结果的 error 中"
+//	@Failure		400				{object}	APIError													"请求体无法解析或含未知字段（invalid_request）"
+//	@Failure		422				{object}	APIError													"部分任务校验失败（validation_failed，details.results 中为每个任务的结果，参数校验失败的字段在结果的 fields 中）、库表规则无效或源库预检未通过（preflight_failed，details 中为预检报告）"
+//	@Failure		500				{object}	APIError													"查询源库表或创建任务失败"
+//	@Failure		429				{object}	APIError{details=service.LimitError}													"达到 canal.limits 的任务数、实例数或复制连接数上限（limit_exceeded）"
+//	@Router			/tasks/bulk [post]
+func (s *Server) bulkCreateTasksHandler(c *gin.Context) {
+	var req BulkCreateTasksRequest
+	if !bindStrictJSON(c, &req) {
+		return
+	}
+
+	items := req.Tasks
+	if req.Pattern != "" {
+		if req.Template == nil || len(req.Tasks) > 0 {
+			respondError(c, ErrCodeInvalidRequest, tr(c, "pattern 需要与 template 一起使用，且不能同时指定 tasks"))
+			return
+		}
+		if err := canal.ValidateTablePattern(req.Pattern); err != nil {
+			respondError(c, ErrCodeValidationFailed, tr(c, "无效的库表规则: %v", err))
+			return
+		}
+		tables, err := canal.ListSourceTables(s.config.Canal, req.Pattern)
+		if err != nil {
+			respondError(c, ErrCodeInternal, tr(c, "查询源库表失败: %v", err))
+			return
+		}
+		if len(tables) == 0 {
+			respondError(c, ErrCodeValidationFailed, tr(c, "没有匹配的表: %s", req.Pattern))
+			return
+		}
+		items = req.Template.expand(tables)
+	}
+
+	if len(items) == 0 {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "需要指定 tasks 或 pattern"))
+		return
+	}
+	if len(items) > maxBulkTasks {
+		respondError(c, ErrCodeValidationFailed, tr(c, "一次最多创建 %d 个任务，请求中有 %d 个", maxBulkTasks, len(items)))
+		return
+	}
+
+	// 逐个校验请求参数和任务配置
+	results := make([]BulkTaskResult, len(items))
+	tasks := make([]*database.Task, len(items))
+	invalid := false
+	for i := range items {
+		results[i] = BulkTaskResult{Index: i, Database: items[i].Database, Table: items[i].Table}
+		tasks[i] = items[i].ToTask()
+		if fields := validateRequest(c, &items[i]); len(fields) > 0 {
+			results[i].Error = tr(c, "请求参数校验失败: %s", joinFieldErrors(fields))
+			results[i].Fields = fields
+			invalid = true
+		}
+	}
+	if invalid {
+		respondErrorDetails(c, ErrCodeValidationFailed, tr(c, "部分任务校验失败，未创建任何任务"), gin.H{"results": results})
+		return
+	}
+	if report := s.preflight(c, tasks...); report != nil && !report.Passed {
+		respondErrorDetails(c, ErrCodePreflightFailed, tr(c, "源库预检未通过，未创建任何任务: %v", report.Err()), report)
+		return
+	}
+	// 同一回调地址只检查一次
+	sinkErrs := make(map[string]error)
+	for i, task := range tasks {
+		err, checked := sinkErrs[task.CallbackURL]
+		if !checked {
+			err = s.checkSink(task)
+			sinkErrs[task.CallbackURL] = err
+		}
+		if err != nil {
+			results[i].Error = tr(c, "回调地址检查未通过: %v", err)
+			invalid = true
+		}
+	}
+	if invalid {
+		respondErrorDetails(c, ErrCodeValidationFailed, tr(c, "部分任务校验失败，未创建任何任务"), gin.H{"results": results})
+		return
+	}
+
+	if err := s.admit(tasks...); err != nil {
+		respondTaskError(c, err, "未创建任何任务: %v")
+		return
+	}
+
+	errs, err := s.taskService.CreateTasks(tasks)
+	if errs != nil {
+		for i, itemErr := range errs {
+			if itemErr != nil {
+				results[i].Error = tr(c, itemErr.Error())
+			}
+		}
+		respondErrorDetails(c, ErrCodeValidationFailed, tr(c, err.Error()), gin.H{"results": results})
+		return
+	}
+	if err != nil {
+		respondTaskError(c, err, "创建任务失败: %v")
+		return
+	}
+
+	// 任务已全部创建，单个实例启动失败只记录在对应结果中，任务保留为 pending 由后台重试启动
+	created := len(tasks)
+	for i, task := range tasks {
+		results[i].Task = task
+		if err := s.canalService.CreateTask(task); err != nil {
+			if markErr := s.deferTaskStart(task, err); markErr != nil {
+				s.taskService.PurgeTask(task.ID)
+				s.dropTaskLogs(task.ID)
+				results[i].Task = nil
+				created--
+				results[i].Error = tr(c, "启动Canal监听失败: %v", err)
+			} else {
+				results[i].Error = tr(c, "启动Canal监听失败，任务将在后台重试启动: %v", err)
+			}
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": gin.H{
+			"created": created,
+			"results": results,
+		},
+	})
+}
+
 // getTaskHandler 获取单个任务
+//
+//	@Summary	获取任务详情
+//	@Tags		tasks
+//	@ID			getTask
+//	@Produce	json
+//	@Param		id	path		int							true	"任务ID"	minimum(1)
+//	@Success	200	{object}	object{data=database.Task}	"任务详情"
+//	@Failure	400	{object}	APIError					"无效的任务ID"
+//	@Failure	404	{object}	APIError					"任务不存在"
+//	@Router		/tasks/{id} [get]
 func (s *Server) getTaskHandler(c *gin.Context) {
 	id, err := parseUintParam(c, "id")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "无效的任务ID",
-		})
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
 		return
 	}
 
 	task, err := s.taskService.GetTask(id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "任务不存在",
-		})
+		respondError(c, ErrCodeTaskNotFound, tr(c, "任务不存在"))
 		return
 	}
 
@@ -228,42 +784,252 @@ func (s *Server) getTaskHandler(c *gin.Context) {
 	})
 }
 
+// getDeliveryCursorHandler 按请求序号（sequence）或 binlog 位置（position=文件名:偏移）查询投递记录
+//
+//	@Summary		按请求序号或 binlog 位置查询投递记录
+//	@Description	每个 Webhook 请求分配递增的序号（请求头 X-Delivery-Sequence，请求体 sequence），投递成功后记录序号与其中事件的 binlog 位置范围。按 sequence 查询返回该序号的请求，没有记录时返回之前最近的一个；按 position 查询返回包含该位置事件的第一个请求。需要开启 database_storage。
+//	@Tags			tasks
+//	@ID				getDeliveryCursor
+//	@Produce		json
+//	@Param			id			path		int										true	"任务ID"	minimum(1)
+//	@Param			sequence	query		int										false	"请求序号"	minimum(1)
+//	@Param			position	query		string									false	"binlog 位置，格式为 文件名:偏移，如 mysql-bin.000003:1234"
+//	@Success		200			{object}	object{data=database.DeliveryCursor}	"投递记录"
+//	@Failure		400			{object}	APIError								"无效的任务ID或查询参数"
+//	@Failure		404			{object}	APIError								"没有对应的投递记录"
+//	@Failure		500			{object}	APIError								"查询投递记录失败"
+//	@Router			/tasks/{id}/cursor [get]
+func (s *Server) getDeliveryCursorHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	var cursor *database.DeliveryCursor
+	switch {
+	case c.Query("sequence") != "":
+		sequence, parseErr := strconv.ParseUint(c.Query("sequence"), 10, 64)
+		if parseErr != nil {
+			respondError(c, ErrCodeInvalidRequest, tr(c, "无效的请求序号"))
+			return
+		}
+		cursor, err = s.taskService.GetDeliveryCursorBySequence(id, sequence)
+	case c.Query("position") != "":
+		pos, parseErr := canal.ParsePosition(c.Query("position"))
+		if parseErr != nil {
+			respondError(c, ErrCodeInvalidRequest, tr(c, "无效的位置: %v", parseErr))
+			return
+		}
+		cursor, err = s.taskService.GetDeliveryCursorByPosition(id, pos)
+	default:
+		respondError(c, ErrCodeInvalidRequest, tr(c, "需要指定 sequence 或 position 参数"))
+		return
+	}
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, ErrCodeNotFound, tr(c, "没有对应的投递记录"))
+			return
+		}
+
+		respondError(c, ErrCodeInternal, tr(c, "查询投递记录失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": cursor,
+	})
+}
+
+// pullEventsHandler 长轮询拉取任务的事件：cursor 为已处理的最后一条事件的位置，同时确认并保存，
+// 不传时从消费者保存的位置继续；wait 为没有新事件时最长等待的时间，如 30s，最长 60s。
+// 事件来自事件日志，未开启 database_storage 时没有可拉取的事件，返回 409
+//
+//	@Summary		长轮询拉取任务的事件
+//	@Description	供无法接收 Webhook 的消费端（如位于 NAT 之后）拉取事件。返回位置之后的事件日志，没有新事件时等待写入，直到超过 wait 指定的时间后返回空列表。cursor 表示之前的事件已处理，同时确认并保存为该消费者的位置；不传 cursor 时从保存的位置继续，没有保存的位置时从最早保留的事件开始。拉取不改变事件日志的投递状态，只需拉取时可为任务开启 dry_run 不调用 Webhook。事件来自事件日志，需要开启 database_storage，未开启时返回 409。
+//	@Tags			tasks
+//	@ID				pullTaskEvents
+//	@Produce		json
+//	@Param			id			path		int								true	"任务ID"								minimum(1)
+//	@Param			cursor		query		int								false	"已处理的最后一条事件的位置，即上次返回的 next_cursor"	minimum(0)
+//	@Param			wait		query		string							false	"没有新事件时最长等待的时间，如 30s，最长 60s，不传表示立即返回"
+//	@Param			limit		query		int								false	"最多返回的事件数，默认 100，最大 1000"		minimum(1)	maximum(1000)
+//	@Param			consumer	query		string							false	"消费者名称，各消费者的位置分别保存，默认 default"	maxlength(100)
+//	@Success		200			{object}	object{data=service.PullResult}	"拉取到的事件"
+//	@Failure		400			{object}	APIError						"无效的任务ID或查询参数"
+//	@Failure		404			{object}	APIError						"任务不存在"
+//	@Failure		409			{object}	APIError						"未开启 database_storage"
+//	@Failure		500			{object}	APIError						"拉取事件失败"
+//	@Router			/tasks/{id}/events [get]
+func (s *Server) pullEventsHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+	if !s.config.DatabaseStorage.Enabled {
+		respondError(c, ErrCodeConflict, tr(c, "未开启 database_storage，没有可拉取的事件"))
+		return
+	}
+
+	req := service.PullRequest{TaskID: id, Consumer: c.Query("consumer")}
+	if len(req.Consumer) > 100 {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "消费者名称不能超过 100 个字符"))
+		return
+	}
+	if value := c.Query("cursor"); value != "" {
+		cursor, parseErr := strconv.ParseUint(value, 10, 32)
+		if parseErr != nil {
+			respondError(c, ErrCodeInvalidRequest, tr(c, "无效的拉取位置: %v", parseErr))
+			return
+		}
+		position := uint(cursor)
+		req.Cursor = &position
+	}
+	if value := c.Query("wait"); value != "" {
+		wait, parseErr := time.ParseDuration(value)
+		if parseErr != nil || wait < 0 {
+			respondError(c, ErrCodeInvalidRequest, tr(c, "无效的等待时间: %s", value))
+			return
+		}
+		req.Wait = wait
+	}
+	if value := c.Query("limit"); value != "" {
+		req.Limit, _ = parseIntDefault(value, 0)
+	}
+
+	if _, err := s.taskService.GetTask(id); err != nil {
+		respondError(c, ErrCodeTaskNotFound, tr(c, "任务不存在"))
+		return
+	}
+
+	result, err := s.taskService.PullEvents(c.Request.Context(), req)
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			// 客户端已断开
+			return
+		}
+		respondError(c, ErrCodeInternal, tr(c, "拉取事件失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
+
 // updateTaskHandler 更新任务
+//
+//	@Summary		更新任务
+//	@Description	只更新请求中包含的字段，运行中的实例会原地重新配置
+//	@Tags			tasks
+//	@ID				updateTask
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"任务ID"	minimum(1)
+//	@Param			request	body		UpdateTaskRequest		true	"请求体"
+//	@Success		200		{object}	object{message=string}	"更新成功"
+//	@Failure		400		{object}	APIError				"请求体无法解析或含未知字段（invalid_request，details.fields 中为未知字段）"
+//	@Failure		404		{object}	APIError				"任务不存在（task_not_found）"
+//	@Failure		422		{object}	APIError				"更新内容校验失败（validation_failed，details.fields 中为每个字段的错误）"
+//	@Failure		500		{object}	APIError				"更新失败"
+//	@Failure		429		{object}	APIError{details=service.LimitError}				"达到 canal.limits 的任务数、实例数或复制连接数上限（limit_exceeded）"
+//	@Router			/tasks/{id} [put]
 func (s *Server) updateTaskHandler(c *gin.Context) {
 	id, err := parseUintParam(c, "id")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "无效的任务ID",
-		})
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
 		return
 	}
 
 	var req UpdateTaskRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "请求参数错误: " + err.Error(),
-		})
+	if !bindStrictJSON(c, &req) {
 		return
 	}
 
 	updates := req.ToTask()
-	if err := s.taskService.UpdateTask(id, updates); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "更新任务失败: " + err.Error(),
-		})
+	// 停用的任务重新启用时需要启动实例，先检查准入上限
+	if req.Status != nil && *req.Status == "active" {
+		current, err := s.taskService.GetTask(id)
+		if err != nil {
+			respondTaskError(c, err, "更新任务失败: %v")
+			return
+		}
+		if current.Status != "active" {
+			if err := s.admit(current); err != nil {
+				respondTaskError(c, err, "更新任务失败: %v")
+				return
+			}
+		}
+	}
+	if err := s.taskService.UpdateTask(id, updates, req.PartitionCount); err != nil {
+		respondTaskError(c, err, "更新任务失败: %v")
+		return
+	}
+	if req.DryRun != nil {
+		if err := s.taskService.SetTaskDryRun(id, *req.DryRun); err != nil {
+			respondTaskError(c, err, "更新任务失败: %v")
+			return
+		}
+	}
+	if req.Schedule != nil {
+		if err := s.taskService.SetTaskSchedule(id, *req.Schedule); err != nil {
+			respondTaskError(c, err, "更新任务失败: %v")
+			return
+		}
+	}
+	if err := s.taskService.SetTaskSampling(id, req.SamplePercent, req.SampleInterval); err != nil {
+		respondTaskError(c, err, "更新任务失败: %v")
+		return
+	}
+	if req.CompactWindow != nil {
+		if err := s.taskService.SetTaskCompactWindow(id, *req.CompactWindow); err != nil {
+			respondTaskError(c, err, "更新任务失败: %v")
+			return
+		}
+	}
+	if req.PayloadMapping != nil {
+		if err := s.taskService.SetTaskPayloadMapping(id, *req.PayloadMapping); err != nil {
+			respondTaskError(c, err, "更新任务失败: %v")
+			return
+		}
+	}
+	if req.RowFilter != nil {
+		if err := s.taskService.SetTaskRowFilter(id, *req.RowFilter); err != nil {
+			respondTaskError(c, err, "更新任务失败: %v")
+			return
+		}
+	}
+	if err := s.taskService.SetTaskLagLimits(id, req.MaxLagSeconds, req.MaxLagEvents); err != nil {
+		respondTaskError(c, err, "更新任务失败: %v")
+		return
+	}
+	if err := s.taskService.SetTaskSourceIdentity(id, req.SourceName, req.Environment, req.UserAgent); err != nil {
+		respondTaskError(c, err, "更新任务失败: %v")
+		return
+	}
+	if req.MaxEventAge != nil {
+		if err := s.taskService.SetTaskMaxEventAge(id, *req.MaxEventAge); err != nil {
+			respondTaskError(c, err, "更新任务失败: %v")
+			return
+		}
+	}
+	if err := s.taskService.SetTaskQuality(id, req.QualityRules, req.QuarantineURL); err != nil {
+		respondTaskError(c, err, "更新任务失败: %v")
 		return
 	}
 
 	// 日志记录
 	fmt.Printf("Task %d updated, updating associated canal instance if exists", id)
-	if s.canalService.UpdateInstance(id, updates) != nil {
-		// 日错误志记录
-		fmt.Printf("Error updating canal instance for updated task %d", id)
-		// error
-		fmt.Printf("Error updating canal instance for updated task: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "更新Canal任务失败: " + err.Error(),
-		})
+	if err := s.canalService.UpdateInstance(id, updates); err != nil {
+		// 错误日志记录
+		fmt.Printf("Error updating canal instance for updated task %d: %s", id, err)
+		if limitErr, ok := service.AsLimitError(err); ok {
+			respondLimitError(c, limitErr, "更新Canal任务失败: %v")
+			return
+		}
+		respondError(c, ErrCodeInstanceFailed, tr(c, "更新Canal任务失败: %v", err))
 		return
 	}
 
@@ -271,24 +1037,40 @@ func (s *Server) updateTaskHandler(c *gin.Context) {
 	fmt.Printf("Canal instance for task %d updated", id)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "任务更新成功",
+		"message": tr(c, "任务更新成功"),
 	})
 }
 
 // deleteTaskHandler 删除任务
+//
+//	@Summary		删除任务
+//	@Description	停止监听并删除任务。配置了保留天数（task_deletion.retention_days，默认 7）时任务只标记删除，binlog 位置、事件日志和失败事件保留，保留期内可以还原，到期后由后台彻底清除；purge=true 或保留天数为 0 时立即彻底清除
+//	@Tags			tasks
+//	@ID				deleteTask
+//	@Produce		json
+//	@Param			id		path		int													true	"任务ID"					minimum(1)
+//	@Param			purge	query		bool												false	"为 true 时立即彻底清除，不可还原"	default(false)
+//	@Success		200		{object}	object{message=string,data=object{purge_at=string}}	"删除成功"
+//	@Failure		400		{object}	APIError											"无效的任务ID"
+//	@Failure		500		{object}	APIError											"删除失败"
+//	@Router			/tasks/{id} [delete]
 func (s *Server) deleteTaskHandler(c *gin.Context) {
 	id, err := parseUintParam(c, "id")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "无效的任务ID",
-		})
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
 		return
 	}
 
-	if err := s.taskService.DeleteTask(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "删除任务失败: " + err.Error(),
-		})
+	// 保留期内只标记删除，binlog 位置和失败事件保留，可以恢复；purge=true 或未配置保留期时立即清除
+	retentionDays := s.config.TaskDeletion.RetentionDays
+	purge := retentionDays <= 0 || c.Query("purge") == "true"
+	if purge {
+		err = s.taskService.PurgeTask(id)
+	} else {
+		err = s.taskService.DeleteTask(id)
+	}
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "删除任务失败: %v", err))
 		return
 	}
 
@@ -297,83 +1079,363 @@ func (s *Server) deleteTaskHandler(c *gin.Context) {
 	if err := s.canalService.StopInstance(id); err != nil {
 		// 错误日志
 		fmt.Printf("Error stopping canal instance for deleted task %d: %s", id, err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "停止Canal任务失败: " + err.Error(),
-		})
+		respondError(c, ErrCodeInstanceFailed, tr(c, "停止Canal任务失败: %v", err))
 		return
 	}
 	//日志记录
 	fmt.Printf("Canal instance for task %d stopped", id)
+	s.dropTaskLogs(id)
 
+	if purge {
+		c.JSON(http.StatusOK, gin.H{
+			"message": tr(c, "任务删除成功"),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"message": "任务删除成功",
+		"message": tr(c, "任务已删除，%d 天内可以还原", retentionDays),
+		"data": gin.H{
+			"purge_at": time.Now().AddDate(0, 0, retentionDays),
+		},
+	})
+}
+
+// deletedTaskResponse 已删除的任务及其清除时间
+type deletedTaskResponse struct {
+	database.Task
+	PurgeAt time.Time `json:"purge_at" format:"date-time"`
+} // @name DeletedTask
+
+// getDeletedTasksHandler 已删除、尚未清除的任务，保留期内可以还原
+//
+//	@Summary		已删除、尚未清除的任务
+//	@Description	最近删除的在前，保留期内可以还原
+//	@Tags			tasks
+//	@ID				listDeletedTasks
+//	@Produce		json
+//	@Success		200	{object}	object{data=[]deletedTaskResponse}	"已删除的任务"
+//	@Failure		500	{object}	APIError							"查询失败"
+//	@Router			/tasks/deleted [get]
+func (s *Server) getDeletedTasksHandler(c *gin.Context) {
+	tasks, err := s.taskService.GetDeletedTasks()
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "获取已删除的任务失败: %v", err))
+		return
+	}
+
+	result := make([]deletedTaskResponse, 0, len(tasks))
+	for _, task := range tasks {
+		result = append(result, deletedTaskResponse{
+			Task:    task,
+			PurgeAt: task.DeletedAt.Time.AddDate(0, 0, s.config.TaskDeletion.RetentionDays),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
+
+// restoreTaskHandler 还原保留期内的已删除任务，还原后为 inactive，启用后从保存的 binlog 位置继续
+//
+//	@Summary		还原已删除的任务
+//	@Description	还原保留期内的已删除任务，binlog 位置和失败事件都保留。任务还原为 inactive，启用后从保存的位置继续；删除时清除的幂等键不会还原
+//	@Tags			tasks
+//	@ID				restoreTask
+//	@Produce		json
+//	@Param			id	path		int											true	"任务ID"	minimum(1)
+//	@Success		200	{object}	object{message=string,data=database.Task}	"还原成功"
+//	@Failure		400	{object}	APIError									"无效的任务ID"
+//	@Failure		404	{object}	APIError									"任务不存在、未被删除或已被清除"
+//	@Failure		500	{object}	APIError									"还原失败"
+//	@Failure		429	{object}	APIError									"还原后超过任务数上限（limit_exceeded）"
+//	@Router			/tasks/{id}/restore [post]
+func (s *Server) restoreTaskHandler(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的任务ID"))
+		return
+	}
+
+	task, err := s.taskService.RestoreTask(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, ErrCodeTaskNotFound, tr(c, "任务不存在或已被清除"))
+		return
+	}
+	if err != nil {
+		respondTaskError(c, err, "还原任务失败: %v")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "任务已还原，启用后从保存的位置继续"),
+		"data":    task,
 	})
 }
 
 // getEventLogsHandler 获取事件日志
+//
+//	@Summary		查询事件日志
+//	@Description	支持过滤、全文搜索，传入 cursor 时使用游标分页（total 为 -1）
+//	@Tags			logs
+//	@ID				listEventLogs
+//	@Produce		json
+//	@Param			task_id			query		int																								false	"任务ID"
+//	@Param			database		query		string																							false	"数据库名"
+//	@Param			table			query		string																							false	"表名"
+//	@Param			event_type		query		string																							false	"事件类型（INSERT/UPDATE/DELETE）"
+//	@Param			status			query		string																							false	"状态（pending/success/failed/dry_run）"
+//	@Param			content_hash	query		string																							false	"事件的内容哈希，查找内容相同（重复投递）的事件"
+//	@Param			q				query		string																							false	"全文搜索关键词"
+//	@Param			since			query		string																							false	"起始时间（RFC3339）"	format(date-time)
+//	@Param			until			query		string																							false	"结束时间（RFC3339）"	format(date-time)
+//	@Param			cursor			query		int																								false	"游标，取上一页返回的 next_cursor"
+//	@Param			page			query		int																								false	"页码"
+//	@Param			page_size		query		int																								false	"每页条数"
+//	@Success		200				{object}	object{data=object{logs=[]database.EventLog,total=int,page=int,page_size=int,next_cursor=int}}	"事件日志"
+//	@Failure		400				{object}	APIError																						"参数错误"
+//	@Failure		500				{object}	APIError																						"查询失败"
+//	@Router			/logs [get]
 func (s *Server) getEventLogsHandler(c *gin.Context) {
-	page := 1
-	pageSize := 20
-	var taskID uint
+	filter, err := parseEventLogFilter(c)
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	result, err := s.taskService.SearchEventLogs(filter)
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "获取事件日志失败: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"logs":        result.Logs,
+			"total":       result.Total,
+			"page":        filter.Page,
+			"page_size":   filter.PageSize,
+			"next_cursor": result.NextCursor,
+		},
+	})
+}
+
+// exportEventLogsHandler 按查询条件导出事件日志为 CSV 或 Parquet 文件，边查询边输出
+//
+//	@Summary		导出事件日志
+//	@Description	按查询条件导出全部匹配的事件日志（按 ID 升序），以附件形式流式返回，分页参数无效
+//	@Tags			logs
+//	@ID				exportEventLogs
+//	@Produce		text/csv,application/vnd.apache.parquet,application/json
+//	@Param			format			query		string		false	"导出格式（csv/parquet），默认 csv"	Enums(csv,parquet)	default(csv)
+//	@Param			task_id			query		int			false	"任务ID"
+//	@Param			database		query		string		false	"数据库名"
+//	@Param			table			query		string		false	"表名"
+//	@Param			event_type		query		string		false	"事件类型（INSERT/UPDATE/DELETE）"
+//	@Param			status			query		string		false	"状态（pending/success/failed/dry_run）"
+//	@Param			content_hash	query		string		false	"事件的内容哈希，查找内容相同（重复投递）的事件"
+//	@Param			q				query		string		false	"全文搜索关键词"
+//	@Param			since			query		string		false	"起始时间（RFC3339）"	format(date-time)
+//	@Param			until			query		string		false	"结束时间（RFC3339）"	format(date-time)
+//	@Success		200				{string}	string		"导出文件"
+//	@Failure		400				{object}	APIError	"参数错误"
+//	@Failure		500				{object}	APIError	"导出失败"
+//	@Router			/logs/export [get]
+func (s *Server) exportEventLogsHandler(c *gin.Context) {
+	filter, err := parseEventLogFilter(c)
+	if err != nil {
+		respondError(c, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	format := c.DefaultQuery("format", service.ExportFormatCSV)
+	if format != service.ExportFormatCSV && format != service.ExportFormatParquet {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "不支持的导出格式，支持: csv, parquet"))
+		return
+	}
+
+	filename := fmt.Sprintf("event_logs_%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	c.Header("Content-Type", service.ExportContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	count, err := s.taskService.ExportEventLogs(c.Writer, format, filter)
+	if err != nil {
+		// 已开始输出时无法再修改状态码，只能记录错误
+		if !c.Writer.Written() {
+			c.Header("Content-Disposition", "")
+			respondError(c, ErrCodeInternal, tr(c, "导出事件日志失败: %v", err))
+			return
+		}
+		c.Error(fmt.Errorf("export interrupted after %d rows: %v", count, err))
+	}
+}
+
+// parseEventLogFilter 从查询参数解析事件日志查询条件
+func parseEventLogFilter(c *gin.Context) (service.EventLogFilter, error) {
+	filter := service.EventLogFilter{
+		Page:      1,
+		PageSize:  20,
+		Database:  c.Query("database"),
+		Table:     c.Query("table"),
+		EventType: c.Query("event_type"),
+		Status:    c.Query("status"),
+		Hash:      c.Query("content_hash"),
+		Search:    c.Query("q"),
+	}
 
 	if p := c.Query("page"); p != "" {
 		if parsed, err := parseIntDefault(p, 1); err == nil {
-			page = parsed
+			filter.Page = parsed
 		}
 	}
 
 	if ps := c.Query("page_size"); ps != "" {
-		if parsed, err := parseIntDefault(ps, 20); err == nil {
-			pageSize = parsed
+		if parsed, err := parseIntDefault(ps, 20); err == nil && parsed > 0 && parsed <= 1000 {
+			filter.PageSize = parsed
 		}
 	}
 
 	if tid := c.Query("task_id"); tid != "" {
 		if parsed, err := parseUintDefault(tid, 0); err == nil {
-			taskID = parsed
+			filter.TaskID = parsed
 		}
 	}
 
-	logs, total, err := s.taskService.GetEventLogs(taskID, page, pageSize)
+	if cursor := c.Query("cursor"); cursor != "" {
+		if parsed, err := parseUintDefault(cursor, 0); err == nil {
+			filter.Cursor = parsed
+		}
+	}
+
+	var err error
+	if filter.Since, err = parseTimeQuery(c, "since"); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = parseTimeQuery(c, "until"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// getEventDeliveriesHandler 获取事件的投递历史
+//
+//	@Summary	获取事件的投递历史
+//	@Tags		logs
+//	@ID			listEventDeliveries
+//	@Produce	json
+//	@Param		id	path		int																			true	"日志ID"	minimum(1)
+//	@Success	200	{object}	object{data=object{event_id=string,attempts=[]database.DeliveryAttempt}}	"投递历史"
+//	@Failure	400	{object}	APIError																	"无效的日志ID"
+//	@Failure	404	{object}	APIError																	"日志不存在"
+//	@Router		/logs/{id}/deliveries [get]
+func (s *Server) getEventDeliveriesHandler(c *gin.Context) {
+	id, err := parseUintDefault(c.Param("id"), 0)
+	if err != nil || id == 0 {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的日志ID"))
+		return
+	}
+
+	log, err := s.taskService.GetEventLog(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "获取事件日志失败: " + err.Error(),
-		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, ErrCodeNotFound, tr(c, "日志不存在"))
+			return
+		}
+
+		respondError(c, ErrCodeInternal, tr(c, "获取日志失败: %v", err))
+		return
+	}
+
+	attempts, err := s.taskService.GetDeliveryAttempts(log)
+	if err != nil {
+		respondError(c, ErrCodeInternal, tr(c, "获取投递历史失败: %v", err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": gin.H{
-			"logs":      logs,
-			"total":     total,
-			"page":      page,
-			"page_size": pageSize,
+			"event_id": log.EventID,
+			"attempts": attempts,
 		},
 	})
 }
 
+// redeliverEventHandler 重新投递事件
+//
+//	@Summary		重新投递事件
+//	@Description	请求体可省略，URL 为空时投递到任务当前的回调地址
+//	@Tags			logs
+//	@ID				redeliverEvent
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int													true	"日志ID"	minimum(1)
+//	@Param			request	body		RedeliverEventRequest								false	"请求体"
+//	@Success		200		{object}	object{message=string,data=canal.DeliveryAttempt}	"投递成功"
+//	@Failure		400		{object}	APIError											"请求参数错误"
+//	@Failure		404		{object}	APIError											"日志不存在"
+//	@Failure		502		{object}	APIError											"下游返回失败（delivery_failed，details 中为本次投递结果）"
+//	@Router			/logs/{id}/redeliver [post]
+func (s *Server) redeliverEventHandler(c *gin.Context) {
+	id, err := parseUintDefault(c.Param("id"), 0)
+	if err != nil || id == 0 {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的日志ID"))
+		return
+	}
+
+	// 请求体可选
+	var req RedeliverEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err))
+		return
+	}
+
+	attempt, err := s.taskService.RedeliverEvent(c.Request.Context(), id, req.URL)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, ErrCodeNotFound, tr(c, "日志不存在"))
+			return
+		}
+
+		code := ErrCodeInternal
+		if attempt != nil {
+			// 已投递但下游返回失败
+			code = ErrCodeDeliveryFailed
+		}
+		respondErrorDetails(c, code, tr(c, "重新投递失败: %v", err), attempt)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": tr(c, "重新投递成功"),
+		"data":    attempt,
+	})
+}
+
 // getEventLogHandler 获取单个事件日志
+//
+//	@Summary	获取事件日志详情
+//	@Tags		logs
+//	@ID			getEventLog
+//	@Produce	json
+//	@Param		id	path		int								true	"日志ID"	minimum(1)
+//	@Success	200	{object}	object{data=database.EventLog}	"事件日志"
+//	@Failure	400	{object}	APIError						"无效的日志ID"
+//	@Failure	404	{object}	APIError						"日志不存在"
+//	@Router		/logs/{id} [get]
 func (s *Server) getEventLogHandler(c *gin.Context) {
 	id, err := parseUintDefault(c.Param("id"), 0)
 	if err != nil || id == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "无效的日志ID",
-		})
+		respondError(c, ErrCodeInvalidRequest, tr(c, "无效的日志ID"))
 		return
 	}
 
 	log, err := s.taskService.GetEventLog(id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "日志不存在",
-			})
+			respondError(c, ErrCodeNotFound, tr(c, "日志不存在"))
 			return
 		}
 
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "获取日志失败: " + err.Error(),
-		})
+		respondError(c, ErrCodeInternal, tr(c, "获取日志失败: %v", err))
 		return
 	}
 
@@ -382,14 +1444,85 @@ func (s *Server) getEventLogHandler(c *gin.Context) {
 	})
 }
 
+// getVersionHandler 获取构建信息和启用的功能
+//
+//	@Summary		获取构建信息
+//	@Description	返回构建版本、Git 提交、构建时间、Go 版本以及按配置启用的可选功能，用于核对部署的版本。版本信息在构建时通过 -ldflags 注入，未注入时 git_commit 和 build_time 取自 Go 工具链记录的 VCS 信息。
+//	@Tags			status
+//	@ID				getVersion
+//	@Produce		json
+//	@Success		200	{object}	object{data=object{build=version.Info,features=map[string]bool}}	"构建信息"
+//	@Router			/version [get]
+func (s *Server) getVersionHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"build":    version.Get(),
+			"features": featureFlags(s.config),
+		},
+	})
+}
+
+// getMessagesHandler 当前语言的消息目录，键为中文原文，供界面和集成方翻译文字
+//
+//	@Summary		获取消息目录
+//	@Description	返回当前请求语言的消息目录，键为中文原文，值为译文，缺少的键使用原文。语言依次取自 ?lang= 参数（同时写入 pikachun_lang Cookie，作为之后请求的语言）、pikachun_lang Cookie、Accept-Language 请求头和 server.language 配置，API 的错误信息和提示也使用该语言。集成方可以在 server.locales_dir 目录中放置 <语言>.json 覆盖或补充翻译。
+//	@Tags			status
+//	@ID				getMessages
+//	@Produce		json
+//	@Param			lang	query		string																				false	"语言，如 zh、en"
+//	@Success		200		{object}	object{data=object{language=string,languages=[]string,messages=map[string]string}}	"消息目录"
+//	@Header			200		{string}	Content-Language																	"响应使用的语言"
+//	@Router			/i18n [get]
+func (s *Server) getMessagesHandler(c *gin.Context) {
+	lang := language(c)
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"language":  lang,
+			"languages": i18n.Languages(),
+			"messages":  i18n.Messages(lang),
+		},
+	})
+}
+
+// featureFlags 根据配置列出可选功能是否启用
+func featureFlags(cfg *config.Config) map[string]bool {
+	largeValues := cfg.Canal.LargeValues.Policy != "" && cfg.Canal.LargeValues.Policy != "none"
+	return map[string]bool{
+		"admin_auth":       cfg.Server.AdminToken != "",
+		"login":            len(cfg.Server.Users) > 0,
+		"gzip":             cfg.Server.Gzip,
+		"cors":             len(cfg.Server.CORS.AllowedOrigins) > 0,
+		"preflight":        cfg.Canal.Preflight,
+		"gtid":             cfg.Canal.Binlog.GTIDEnabled,
+		"heartbeat":        cfg.Canal.Heartbeat.Enabled,
+		"watchdog":         cfg.Canal.Watchdog.Enabled,
+		"rows_query":       cfg.Canal.RowsQuery.Enabled,
+		"numeric_strings":  cfg.Canal.NumericStrings,
+		"large_values":     largeValues,
+		"read_throttle":    cfg.Canal.Throttle.MaxEventsPerSecond > 0 || cfg.Canal.Throttle.MaxMBPerSecond > 0,
+		"memory_limits":    cfg.Canal.Memory.SoftLimitMB > 0 || cfg.Canal.Memory.HardLimitMB > 0,
+		"log_sampling":     cfg.Log.Sampling.Enabled,
+		"database_storage": cfg.DatabaseStorage.Enabled,
+		"clickhouse":       cfg.ClickHouse.Enabled,
+		"archive":          cfg.Archive.Enabled,
+		"failure_policy":   cfg.FailurePolicy.Enabled,
+	}
+}
+
 // getStatusHandler 获取系统状态
+//
+//	@Summary	获取系统状态
+//	@Tags		status
+//	@ID			getStatus
+//	@Produce	json
+//	@Success	200	{object}	object{data=object{status=string,active_tasks=int,version=string,instance_errors=map[string]object{error_msg=string,error_at=string,alert=string},task_errors=[]object{task_id=int,task_name=string,last_error=string,last_error_at=string},sinks=map[string][]canal.SinkStatus,maintenance=canal.MaintenanceStatus}}	"系统状态"
+//	@Failure	500	{object}	APIError																																																																												"查询失败"
+//	@Router		/status [get]
 func (s *Server) getStatusHandler(c *gin.Context) {
 	// 获取活跃任务数量
 	activeTasks, err := s.taskService.GetActiveTasks()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "获取系统状态失败: " + err.Error(),
-		})
+		respondError(c, ErrCodeInternal, tr(c, "获取系统状态失败: %v", err))
 		return
 	}
 
@@ -399,16 +1532,67 @@ func (s *Server) getStatusHandler(c *gin.Context) {
 		canalStatus = "stopped"
 	}
 
+	// 运行中实例的当前错误、各 sink 的投递状态和维护暂停状态
+	instanceErrors := make(map[string]interface{})
+	sinks := make(map[string][]canal.SinkStatus)
+	var maintenance canal.MaintenanceStatus
+	if s.canalService != nil {
+		stats := s.canalService.GetStatus()
+		maintenance, _ = stats["maintenance"].(canal.MaintenanceStatus)
+		if instances, ok := stats["instances"].(map[string]interface{}); ok {
+			for id, value := range instances {
+				status, ok := value.(canal.InstanceStatus)
+				if !ok {
+					continue
+				}
+				if status.ErrorMsg != "" {
+					instanceErrors[id] = gin.H{
+						"error_msg": status.ErrorMsg,
+						"error_at":  status.ErrorAt,
+						"alert":     status.Alert,
+					}
+				}
+				if len(status.Sinks) > 0 {
+					sinks[id] = status.Sinks
+				}
+			}
+		}
+	}
+
+	// 任务记录中持久化的最近错误
+	taskErrors := make([]gin.H, 0)
+	for _, task := range activeTasks {
+		if task.LastError != "" {
+			taskErrors = append(taskErrors, gin.H{
+				"task_id":       task.ID,
+				"task_name":     task.Name,
+				"last_error":    task.LastError,
+				"last_error_at": task.LastErrorAt,
+			})
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"data": gin.H{
-			"status":       canalStatus,
-			"active_tasks": len(activeTasks),
-			"version":      "1.0.0",
+			"status":          canalStatus,
+			"active_tasks":    len(activeTasks),
+			"version":         version.Get().Version,
+			"instance_errors": instanceErrors,
+			"task_errors":     taskErrors,
+			"sinks":           sinks,
+			"maintenance":     maintenance,
 		},
 	})
 }
 
 // getPerformanceMetricsHandler 获取性能指标
+//
+//	@Summary	获取性能指标
+//	@Tags		status
+//	@ID			getMetrics
+//	@Produce	json
+//	@Success	200	{object}	object{data=object}	"性能指标"
+//	@Router		/metrics [get]
 func (s *Server) getPerformanceMetricsHandler(c *gin.Context) {
 	// 如果有增强的handlers，使用增强的实现
 	if s.enhancedHandlers != nil {
@@ -444,8 +1628,10 @@ func (s *Server) getPerformanceMetricsHandler(c *gin.Context) {
 
 	// 获取内存使用情况
 	memoryUsage := "0 MB"
-	if mem, ok := status["memory_usage"].(string); ok {
-		memoryUsage = mem
+	if mem, ok := status["memory_usage"].(map[string]interface{}); ok {
+		if alloc, ok := mem["alloc_bytes"].(uint64); ok {
+			memoryUsage = fmt.Sprintf("%.1f MB", float64(alloc)/1024/1024)
+		}
 	}
 
 	// 获取连接池状态
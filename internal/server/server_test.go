@@ -0,0 +1,547 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/config"
+	"pikachun/internal/database"
+	"pikachun/internal/service"
+	"pikachun/internal/version"
+)
+
+// newTestServer 按 cfg 创建服务器并注册路由，任务保存在临时数据库中。
+// 页面模板从项目根目录的 web 目录加载，不切换进程的工作目录
+func newTestServer(t *testing.T, cfg *config.Config, canalService service.CanalServiceInterface) (*Server, *service.TaskService) {
+	t.Helper()
+	db, err := database.Init(config.DatabaseConfig{DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	taskService := service.NewTaskService(db)
+	s := New(cfg, taskService, canalService)
+	s.webDir = filepath.Join("..", "..", "web")
+	if err := s.setupAuth(); err != nil {
+		t.Fatalf("setupAuth failed: %v", err)
+	}
+	s.setupRouter()
+	return s, taskService
+}
+
+// TestServerShutdown 测试 Shutdown 后 Start 正常返回
+func TestServerShutdown(t *testing.T) {
+	s, _ := newTestServer(t, &config.Config{Server: config.ServerConfig{Host: "127.0.0.1", Port: "0"}}, nil)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Start()
+	}()
+
+	// 等待服务器开始监听
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.mu.Lock()
+		started := s.httpServer != nil
+		s.mu.Unlock()
+		if started || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("Start returned error after shutdown: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Start did not return after shutdown")
+	}
+
+	// 关闭后再次启动直接返回
+	if err := s.Start(); err != nil {
+		t.Errorf("Start after shutdown returned error: %v", err)
+	}
+}
+
+// fakeCanalService 记录启动的任务，startErr 不为空时启动失败
+type fakeCanalService struct {
+	created  []uint
+	startErr error
+}
+
+func (f *fakeCanalService) Start(ctx context.Context) error                           { return nil }
+func (f *fakeCanalService) Stop() error                                               { return nil }
+func (f *fakeCanalService) StopInstance(instanceID uint) error                        { return nil }
+func (f *fakeCanalService) UpdateInstance(instanceID uint, task *database.Task) error { return nil }
+func (f *fakeCanalService) GetStatus() map[string]interface{}                         { return nil }
+func (f *fakeCanalService) CreateTask(task *database.Task) error {
+	if f.startErr != nil {
+		return f.startErr
+	}
+	f.created = append(f.created, task.ID)
+	return nil
+}
+
+// TestBulkCreateTasks 测试批量创建任务：任一任务无效时不创建任何任务，全部有效时一起创建并启动
+func TestBulkCreateTasks(t *testing.T) {
+	canalService := &fakeCanalService{}
+	s, taskService := newTestServer(t, &config.Config{}, canalService)
+
+	post := func(body string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		s.router.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	task := func(table, eventTypes string) string {
+		return `{"name":"t-` + table + `","database":"shop","table":"` + table +
+			`","event_types":"` + eventTypes + `","callback_url":"http://localhost/hook"}`
+	}
+
+	// 第二个任务的事件类型无效，整批不创建
+	code, resp := post(`{"tasks":[` + task("orders", "INSERT") + `,` + task("users", "UPSERT") + `]}`)
+	if code != http.StatusUnprocessableEntity || resp["code"] != string(ErrCodeValidationFailed) {
+		t.Fatalf("expected 422 validation_failed, got %d: %v", code, resp)
+	}
+	results := resp["details"].(map[string]interface{})["results"].([]interface{})
+	if results[0].(map[string]interface{})["error"] != nil || results[1].(map[string]interface{})["error"] == nil {
+		t.Errorf("expected only the second task to fail validation, got %v", results)
+	}
+	if fields, _ := results[1].(map[string]interface{})["fields"].([]interface{}); len(fields) != 1 || fields[0].(map[string]interface{})["field"] != "event_types" {
+		t.Errorf("expected an event_types field error, got %v", results[1])
+	}
+	if _, total, _ := taskService.GetTasks(1, 10); total != 0 {
+		t.Fatalf("expected no tasks after failed bulk create, got %d", total)
+	}
+
+	// 缺少必填字段同样逐个报告
+	if code, _ := post(`{"tasks":[{"name":"x"}]}`); code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for missing fields, got %d", code)
+	}
+
+	code, resp = post(`{"tasks":[` + task("orders", "INSERT") + `,` + task("users", "INSERT,UPDATE") + `]}`)
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %v", code, resp)
+	}
+	if created := resp["data"].(map[string]interface{})["created"]; created != float64(2) {
+		t.Errorf("expected 2 tasks created, got %v", created)
+	}
+	if _, total, _ := taskService.GetTasks(1, 10); total != 2 || len(canalService.created) != 2 {
+		t.Errorf("expected 2 tasks created and started, got %d tasks, %d started", total, len(canalService.created))
+	}
+
+	if code, _ := post(`{"pattern":"shop","template":{}}`); code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for invalid pattern, got %d", code)
+	}
+}
+
+// TestCreateTaskIdempotencyKey 测试携带幂等键重试创建请求时返回已创建的任务
+func TestCreateTaskIdempotencyKey(t *testing.T) {
+	canalService := &fakeCanalService{}
+	s, taskService := newTestServer(t, &config.Config{}, canalService)
+
+	post := func(key, callback string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		body := `{"name":"orders","database":"shop","table":"orders","event_types":"INSERT","callback_url":"` + callback + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		s.router.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	w, resp := post("req-1", "http://localhost/hook")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %v", w.Code, resp)
+	}
+	id := resp["data"].(map[string]interface{})["id"]
+
+	w, resp = post("req-1", "http://localhost/hook")
+	if w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected replayed 200, got %d: %v", w.Code, resp)
+	}
+	if replayed := resp["data"].(map[string]interface{})["id"]; replayed != id {
+		t.Errorf("expected task %v, got %v", id, replayed)
+	}
+	if _, total, _ := taskService.GetTasks(1, 10); total != 1 {
+		t.Errorf("expected 1 task after retry, got %d", total)
+	}
+
+	if w, _ := post("req-1", "http://localhost/other"); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a key reused with another callback, got %d", w.Code)
+	}
+	if w, _ := post(strings.Repeat("k", 101), "http://localhost/hook"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a long key, got %d", w.Code)
+	}
+
+	// 不带幂等键时照常创建
+	if w, _ := post("", "http://localhost/hook"); w.Code != http.StatusCreated {
+		t.Errorf("expected 201 without key, got %d", w.Code)
+	}
+	if _, total, _ := taskService.GetTasks(1, 10); total != 2 {
+		t.Errorf("expected 2 tasks, got %d", total)
+	}
+
+	if err := taskService.DeleteTask(uint(id.(float64))); err != nil {
+		t.Fatal(err)
+	}
+	// 任务删除后幂等键可以重新使用
+	if w, _ := post("req-1", "http://localhost/hook"); w.Code != http.StatusCreated {
+		t.Errorf("expected 201 after the task was deleted, got %d", w.Code)
+	}
+}
+
+// TestDeleteAndRestoreTask 测试删除的任务在保留期内可以还原，purge=true 时立即彻底清除
+func TestDeleteAndRestoreTask(t *testing.T) {
+	s, taskService := newTestServer(t, &config.Config{TaskDeletion: config.TaskDeletionConfig{RetentionDays: 7}}, &fakeCanalService{})
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	task := &database.Task{Name: "orders", Database: "shop", Table: "orders", EventTypes: "INSERT", CallbackURL: "http://localhost/hook", Status: "active"}
+	if err := taskService.CreateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if err := taskService.CreateEventLog(canal.EventLogEntry{TaskID: task.ID, EventID: "e1", Database: "shop", Table: "orders",
+		EventType: "INSERT", ContentHash: "h1", Data: "{}", Status: "failed", Error: "timeout"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := do(http.MethodDelete, fmt.Sprintf("/api/v1/tasks/%d", task.ID)); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := taskService.GetTask(task.ID); err == nil {
+		t.Error("expected the deleted task to be hidden")
+	}
+	if deleted, _ := taskService.GetDeletedTasks(); len(deleted) != 1 {
+		t.Errorf("expected 1 deleted task, got %d", len(deleted))
+	}
+
+	if w := do(http.MethodPost, fmt.Sprintf("/api/v1/tasks/%d/restore", task.ID)); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	restored, err := taskService.GetTask(task.ID)
+	if err != nil || restored.Status != "inactive" {
+		t.Fatalf("expected the task to be restored as inactive, got %+v, %v", restored, err)
+	}
+	if count, _ := taskService.CountFailedEventLogs(task.ID); count != 1 {
+		t.Errorf("expected the failed event to be kept, got %d", count)
+	}
+	if w := do(http.MethodPost, fmt.Sprintf("/api/v1/tasks/%d/restore", task.ID)); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a task that is not deleted, got %d", w.Code)
+	}
+
+	if w := do(http.MethodDelete, fmt.Sprintf("/api/v1/tasks/%d?purge=true", task.ID)); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if deleted, _ := taskService.GetDeletedTasks(); len(deleted) != 0 {
+		t.Errorf("expected the task to be purged, got %d deleted tasks", len(deleted))
+	}
+	if count, _ := taskService.CountFailedEventLogs(task.ID); count != 0 {
+		t.Errorf("expected the failed events to be purged, got %d", count)
+	}
+}
+
+// TestCreateTaskPendingOnStartFailure 测试回调地址检查，以及实例启动失败时任务保留为 pending
+func TestCreateTaskPendingOnStartFailure(t *testing.T) {
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hook" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer sink.Close()
+
+	canalService := &fakeCanalService{startErr: errors.New("connection refused")}
+	s, taskService := newTestServer(t, &config.Config{Canal: config.CanalConfig{SinkCheck: true}}, canalService)
+
+	post := func(callback string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		body := `{"name":"orders","database":"shop","table":"orders","event_types":"INSERT","callback_url":"` + callback + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		s.router.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	if code, resp := post(sink.URL + "/missing"); code != http.StatusUnprocessableEntity || resp["code"] != string(ErrCodeSinkCheckFailed) {
+		t.Errorf("expected 422 sink_check_failed for an unreachable callback, got %d: %v", code, resp)
+	}
+	if _, total, _ := taskService.GetTasks(1, 10); total != 0 {
+		t.Fatalf("expected no task after a failed sink check, got %d", total)
+	}
+
+	code, resp := post(sink.URL + "/hook")
+	if code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %v", code, resp)
+	}
+	id := uint(resp["data"].(map[string]interface{})["id"].(float64))
+	task, err := taskService.GetTask(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "pending" || task.LastError != "connection refused" {
+		t.Errorf("expected pending task with the start error, got %s: %s", task.Status, task.LastError)
+	}
+
+	if err := taskService.RecordPendingTaskError(id, "timeout"); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ := taskService.GetTask(id); task.Status != "pending" || task.LastError != "timeout" {
+		t.Errorf("expected the retry error recorded, got %s: %s", task.Status, task.LastError)
+	}
+
+	if activated, err := taskService.ActivatePendingTask(id); err != nil || !activated {
+		t.Fatalf("expected pending task to be activated, got %v, %v", activated, err)
+	}
+	if task, _ := taskService.GetTask(id); task.Status != "active" || task.LastError != "" {
+		t.Errorf("expected active task without error, got %s: %s", task.Status, task.LastError)
+	}
+	if activated, _ := taskService.ActivatePendingTask(id); activated {
+		t.Error("an active task must not be activated again")
+	}
+
+	// 重试失败不能覆盖期间被修改的状态
+	if err := taskService.RecordPendingTaskError(id, "timeout"); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ := taskService.GetTask(id); task.Status != "active" || task.LastError != "" {
+		t.Errorf("expected a non-pending task left unchanged, got %s: %s", task.Status, task.LastError)
+	}
+}
+
+// TestVersionHandler 测试构建信息和按配置列出的功能开关
+func TestVersionHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{config: &config.Config{
+		Server: config.ServerConfig{AdminToken: "secret"},
+		Canal:  config.CanalConfig{Preflight: true, LargeValues: config.LargeValueConfig{Policy: "none"}},
+	}}
+	router := gin.New()
+	router.GET("/api/version", s.getVersionHandler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var resp struct {
+		Data struct {
+			Build    version.Info    `json:"build"`
+			Features map[string]bool `json:"features"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.Data.Build.Version != version.Version || resp.Data.Build.GoVersion == "" {
+		t.Errorf("unexpected build info %+v", resp.Data.Build)
+	}
+	features := resp.Data.Features
+	if !features["admin_auth"] || !features["preflight"] || features["large_values"] || features["gzip"] {
+		t.Errorf("unexpected features %v", features)
+	}
+}
+
+// TestLocalizedIndexAndMessages 测试首页按所选语言渲染，之后的请求沿用 Cookie 中的语言
+func TestLocalizedIndexAndMessages(t *testing.T) {
+	s, _ := newTestServer(t, &config.Config{Server: config.ServerConfig{Language: "zh"}}, nil)
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?lang=en", nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, `<html lang="en">`) || !strings.Contains(body, "Create Listener Task") {
+		t.Fatalf("expected english page, got %d: %.300s", w.Code, body)
+	}
+	if strings.Contains(body, "<h3>创建监听任务</h3>") || !strings.Contains(body, `"language":"en"`) {
+		t.Error("expected translated template and injected catalog")
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected language cookie, got %v", cookies)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/i18n", nil)
+	req.AddCookie(cookies[0])
+	s.router.ServeHTTP(w, req)
+	var resp struct {
+		Data struct {
+			Language  string            `json:"language"`
+			Languages []string          `json:"languages"`
+			Messages  map[string]string `json:"messages"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.Data.Language != "en" || resp.Data.Messages["任务不存在"] != "Task not found" || len(resp.Data.Languages) < 2 {
+		t.Errorf("unexpected catalog response %+v", resp.Data.Language)
+	}
+}
+
+// TestUpdateTaskClearsSchedule 测试更新任务时传入空的维护窗口可以清除窗口
+func TestUpdateTaskClearsSchedule(t *testing.T) {
+	s, taskService := newTestServer(t, &config.Config{}, &fakeCanalService{})
+
+	task := &database.Task{Name: "orders", Database: "shop", Table: "orders", EventTypes: "INSERT", CallbackURL: "http://localhost/hook",
+		Status: "active", Schedule: "Mon-Fri 09:00-18:00"}
+	if err := taskService.CreateTask(task); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/tasks/%d", task.ID), strings.NewReader(`{"schedule": ""}`))
+	req.Header.Set("Content-Type", "application/json")
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if updated, err := taskService.GetTask(task.ID); err != nil || updated.Schedule != "" {
+		t.Errorf("expected the schedule cleared, got %+v, %v", updated, err)
+	}
+}
+
+// TestUpdateTaskResetsPartitionCount 测试更新任务时分区数可以设为 0，round_robin 策略仍要求分区数
+func TestUpdateTaskResetsPartitionCount(t *testing.T) {
+	s, taskService := newTestServer(t, &config.Config{}, &fakeCanalService{})
+
+	update := func(id uint, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/tasks/%d", id), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	task := &database.Task{Name: "orders", Database: "shop", Table: "orders", EventTypes: "INSERT", CallbackURL: "http://localhost/hook",
+		Status: "active", PartitionBy: "pk", PartitionCount: 4}
+	if err := taskService.CreateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if w := update(task.ID, `{"partition_count": 0}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if updated, err := taskService.GetTask(task.ID); err != nil || updated.PartitionCount != 0 || updated.PartitionBy != "pk" {
+		t.Errorf("expected the partition count reset, got %+v, %v", updated, err)
+	}
+
+	// 校验失败时不写入任何字段
+	if w := update(task.ID, `{"callback_url": "http://other/hook", "partition_by": "round_robin", "partition_count": 0}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+	if updated, err := taskService.GetTask(task.ID); err != nil || updated.PartitionBy != "pk" || updated.CallbackURL != "http://localhost/hook" {
+		t.Errorf("expected the task unchanged after a rejected update, got %+v, %v", updated, err)
+	}
+
+	// round_robin 策略没有分区数时无效
+	if w := update(task.ID, `{"partition_by": "round_robin", "partition_count": 4}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := update(task.ID, `{"partition_count": 0}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+	if updated, err := taskService.GetTask(task.ID); err != nil || updated.PartitionCount != 4 {
+		t.Errorf("expected the partition count kept, got %+v, %v", updated, err)
+	}
+}
+
+// TestPullEventsRoute 测试拉取接口返回事件和 next_cursor，传入的位置被确认，未开启 database_storage 时返回 409
+func TestPullEventsRoute(t *testing.T) {
+	s, taskService := newTestServer(t, &config.Config{}, &fakeCanalService{})
+	task := &database.Task{Name: "orders", Database: "shop", Table: "orders", EventTypes: "INSERT", CallbackURL: "http://localhost/hook"}
+	if err := taskService.CreateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	entries := make([]canal.EventLogEntry, 0, 3)
+	for i := 1; i <= 3; i++ {
+		entries = append(entries, canal.EventLogEntry{TaskID: task.ID, EventID: fmt.Sprintf("e%d", i), Database: "shop", Table: "orders",
+			EventType: "INSERT", Data: fmt.Sprintf(`{"columns":[{"name":"id","value":%d}]}`, i), Status: "success"})
+	}
+	if err := taskService.CreateEventLogs(entries); err != nil {
+		t.Fatal(err)
+	}
+
+	pull := func(query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/tasks/%d/events?%s", task.ID, query), nil)
+		s.router.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	if code, _ := pull(""); code != http.StatusConflict {
+		t.Errorf("expected 409 without database_storage, got %d", code)
+	}
+
+	// 拉取接口在请求时检查 database_storage
+	s.config.DatabaseStorage.Enabled = true
+	if code, _ := pull("wait=soon"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid wait, got %d", code)
+	}
+
+	code, resp := pull("limit=2")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	data := resp["data"].(map[string]interface{})
+	if events := data["events"].([]interface{}); len(events) != 2 {
+		t.Fatalf("expected 2 events with limit=2, got %v", events)
+	}
+
+	code, resp = pull(fmt.Sprintf("cursor=%v", data["next_cursor"]))
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	events := resp["data"].(map[string]interface{})["events"].([]interface{})
+	if len(events) != 1 || events[0].(map[string]interface{})["event"].(map[string]interface{})["id"] != "e3" {
+		t.Errorf("expected only e3 after acknowledging, got %v", events)
+	}
+}
+
+// TestSourceTablesInvalidPattern 测试无效的表规则返回 400，不查询源库
+func TestSourceTablesInvalidPattern(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/sources/:id/tables", (&EnhancedHandlers{}).sourceTablesHandler)
+
+	for _, query := range []string{"?pattern=shop", "?pattern=shop.[orders", "?pattern=.orders"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/sources/default/tables"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d: %s", query, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"pikachun/internal/auth"
+	"pikachun/internal/i18n"
+)
+
+// sessionCookie 登录会话的 Cookie
+const sessionCookie = "pikachun_session"
+
+// userKey 登录用户在 gin.Context 中的键
+const userKey = "user"
+
+// sessionMiddleware 根据会话 Cookie 识别登录用户，不拒绝请求
+func sessionMiddleware(authn *auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authn.Enabled() {
+			if id, err := c.Cookie(sessionCookie); err == nil {
+				if session, ok := authn.Session(id); ok {
+					c.Set(userKey, session.User)
+				}
+			}
+		}
+		c.Next()
+	}
+}
+
+// currentUser 获取当前请求的登录用户
+func currentUser(c *gin.Context) (auth.User, bool) {
+	value, ok := c.Get(userKey)
+	if !ok {
+		return auth.User{}, false
+	}
+	user, ok := value.(auth.User)
+	return user, ok
+}
+
+// validAdminToken 请求是否携带了正确的管理员令牌，未配置令牌时总是 false
+func validAdminToken(c *gin.Context, token string) bool {
+	provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return token != "" && ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// isReadOnlyMethod 不修改数据的请求方法
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// requireLogin 配置了用户时 API 需要登录，viewer 只能发起只读请求；携带管理员令牌的请求视为管理员。
+// 登录用户的修改操作记录到最近操作中
+func requireLogin(authn *auth.Authenticator, adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authn.Enabled() {
+			c.Next()
+			return
+		}
+
+		user, ok := currentUser(c)
+		if !ok {
+			if validAdminToken(c, adminToken) {
+				c.Next()
+				return
+			}
+			abortWithError(c, ErrCodeUnauthorized, tr(c, "需要登录"))
+			return
+		}
+		if isReadOnlyMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if !user.Role.CanMutate() {
+			abortWithError(c, ErrCodeForbidden, tr(c, "当前角色没有权限执行此操作"))
+			return
+		}
+
+		c.Next()
+		authn.Record(user.Username, c.Request.Method+" "+c.Request.URL.Path, c.Writer.Status())
+	}
+}
+
+// registerSessionRoutes 注册登录页面和登录、退出接口
+func (s *Server) registerSessionRoutes() {
+	s.router.GET("/login", s.loginPageHandler)
+	s.router.POST("/login", s.loginHandler)
+	s.router.POST("/logout", s.logoutHandler)
+}
+
+// loginPageHandler 登录页面，未配置用户时跳转到首页
+func (s *Server) loginPageHandler(c *gin.Context) {
+	if !s.auth.Enabled() {
+		c.Redirect(http.StatusSeeOther, "/")
+		return
+	}
+	if _, ok := currentUser(c); ok {
+		c.Redirect(http.StatusSeeOther, "/")
+		return
+	}
+	s.renderLogin(c, http.StatusOK, "")
+}
+
+// loginHandler 校验登录表单，成功后写入会话 Cookie 并跳转到首页
+func (s *Server) loginHandler(c *gin.Context) {
+	if !s.auth.Enabled() {
+		c.Redirect(http.StatusSeeOther, "/")
+		return
+	}
+
+	session, err := s.auth.Login(c.PostForm("username"), c.PostForm("password"))
+	if err != nil {
+		if !errors.Is(err, auth.ErrInvalidCredentials) {
+			log.Printf("❌ Failed to create session: %v", err)
+		}
+		s.renderLogin(c, http.StatusUnauthorized, tr(c, "用户名或密码错误"))
+		return
+	}
+
+	log.Printf("🔑 User %s logged in (role: %s)", session.User.Username, session.User.Role)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    session.ID,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Redirect(http.StatusSeeOther, "/")
+}
+
+// logoutHandler 删除会话并跳转到登录页面
+func (s *Server) logoutHandler(c *gin.Context) {
+	if id, err := c.Cookie(sessionCookie); err == nil && s.auth.Enabled() {
+		s.auth.Logout(id)
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Redirect(http.StatusSeeOther, "/login")
+}
+
+// renderLogin 渲染登录页面
+func (s *Server) renderLogin(c *gin.Context, status int, message string) {
+	c.HTML(status, "login.html", gin.H{
+		"title":     tr(c, "登录 - Pikachun"),
+		"lang":      language(c),
+		"languages": i18n.Languages(),
+		"error":     message,
+		"username":  c.PostForm("username"),
+	})
+}
+
+// getSessionHandler 当前登录用户、是否可以修改数据和最近操作
+//
+//	@Summary		获取当前登录用户
+//	@Description	返回是否启用了登录、当前用户及角色、是否可以修改数据以及该用户最近的操作（登录、退出和修改请求，最多 50 条，最新的在前）。配置了 server.users 时所有 API 都需要登录：Web 界面通过 /login 登录后使用 pikachun_session Cookie，自动化客户端可以携带 Authorization: Bearer <管理员令牌>。viewer 角色只能发起只读请求，修改请求返回 403。会话保存在内存中，进程重启后需要重新登录。
+//	@Tags			status
+//	@ID				getSession
+//	@Produce		json
+//	@Success		200	{object}	object{data=object{auth_enabled=bool,can_mutate=bool,user=object{username=string,role=string},activities=[]object{time=string,action=string,status=int}}}	"当前会话"
+//	@Failure		401	{object}	APIError																																					"需要登录"
+//	@Router			/session [get]
+func (s *Server) getSessionHandler(c *gin.Context) {
+	data := gin.H{
+		"auth_enabled": s.auth.Enabled(),
+		"can_mutate":   true,
+	}
+	if user, ok := currentUser(c); ok {
+		data["user"] = user
+		data["can_mutate"] = user.Role.CanMutate()
+		data["activities"] = s.auth.Activities(user.Username)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
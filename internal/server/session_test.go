@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"pikachun/internal/config"
+)
+
+// TestLoginSessionsAndRoles 测试登录、viewer 角色只读、管理员令牌和最近操作
+func TestLoginSessionsAndRoles(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestServer(t, &config.Config{Server: config.ServerConfig{
+		AdminToken: "token",
+		Users: []config.UserConfig{
+			{Username: "alice", PasswordHash: string(hash), Role: "operator"},
+			{Username: "bob", PasswordHash: string(hash), Role: "viewer"},
+		},
+	}}, nil)
+
+	serve := func(method, target string, cookie *http.Cookie, body url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body.Encode()))
+		if body != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+	login := func(username, password string) *httptest.ResponseRecorder {
+		return serve(http.MethodPost, "/login", nil, url.Values{"username": {username}, "password": {password}})
+	}
+	sessionCookieOf := func(w *httptest.ResponseRecorder) *http.Cookie {
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == sessionCookie {
+				return cookie
+			}
+		}
+		t.Fatalf("expected session cookie, got %v", w.Result().Cookies())
+		return nil
+	}
+
+	// 未登录
+	if w := serve(http.MethodGet, "/", nil, nil); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login" {
+		t.Errorf("expected redirect to login, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/api/v1/session", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without session, got %d", w.Code)
+	}
+	if w := login("bob", "wrong"); w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "用户名或密码错误") {
+		t.Errorf("expected login failure, got %d", w.Code)
+	}
+
+	// viewer 看不到修改操作，修改请求被拒绝
+	viewer := sessionCookieOf(login("bob", "secret"))
+	w := serve(http.MethodGet, "/", viewer, nil)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "showCreateTaskModal()") || !strings.Contains(w.Body.String(), "bob (viewer)") {
+		t.Errorf("expected read-only page for viewer, got %d", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/api/v1/tasks/1", viewer, nil); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for viewer, got %d", w.Code)
+	}
+
+	// operator 的修改请求通过认证并记录到最近操作
+	operator := sessionCookieOf(login("alice", "secret"))
+	if w := serve(http.MethodDelete, "/api/v1/tasks/abc", operator, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("expected request to reach the handler, got %d", w.Code)
+	}
+	w = serve(http.MethodGet, "/api/v1/session", operator, nil)
+	var resp struct {
+		Data struct {
+			CanMutate  bool `json:"can_mutate"`
+			Activities []struct {
+				Action string `json:"action"`
+				Status int    `json:"status"`
+			} `json:"activities"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if !resp.Data.CanMutate || len(resp.Data.Activities) != 2 ||
+		resp.Data.Activities[0].Action != "DELETE /api/v1/tasks/abc" || resp.Data.Activities[1].Action != "login" {
+		t.Errorf("unexpected session %+v", resp.Data)
+	}
+
+	// 管理员令牌不需要登录
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/i18n", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected admin token to be accepted, got %d", w.Code)
+	}
+
+	// 退出后会话失效
+	if w := serve(http.MethodPost, "/logout", operator, nil); w.Code != http.StatusSeeOther {
+		t.Errorf("expected redirect after logout, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/api/v1/session", operator, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 after logout, got %d", w.Code)
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// REST API 的总体描述，接口描述写在各处理器的注释中，修改后运行 go generate 重新生成 openapi.json
+//
+//	@title				Pikachun API
+//	@version			1.0.0
+//	@description		MySQL binlog 监听服务的任务管理、事件日志与状态接口
+//	@BasePath			/api/v1
+//
+//	@tag.name			tasks
+//	@tag.description	监听任务管理
+//	@tag.name			logs
+//	@tag.description	事件日志与投递历史
+//	@tag.name			sources
+//	@tag.description	源库信息
+//	@tag.name			status
+//	@tag.description	系统状态与指标
+//	@tag.name			admin
+//	@tag.description	维护操作
+//	@tag.name			analytics
+//	@tag.description	变更分析：按 binlog 事件统计各表的行变更数
+
+//go:generate go run ./openapigen
+
+// openAPISpec 由处理器注释生成的 REST API OpenAPI 3 描述
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// swaggerUIPage Swagger UI 页面，静态资源随程序内嵌，不依赖外部 CDN
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <title>Pikachun API</title>
+    <link rel="stylesheet" href="/swagger/ui/swagger-ui.css">
+</head>
+<body>
+    <div id="swagger-ui"></div>
+    <script src="/swagger/ui/swagger-ui-bundle.js"></script>
+    <script>
+        window.ui = SwaggerUIBundle({
+            url: '/swagger/openapi.json',
+            dom_id: '#swagger-ui'
+        });
+    </script>
+</body>
+</html>`
+
+// registerSwaggerRoutes 注册 OpenAPI 描述和 Swagger UI 路由
+func registerSwaggerRoutes(router *gin.Engine) {
+	router.GET("/swagger", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+	})
+	router.GET("/swagger/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", openAPISpec)
+	})
+	router.StaticFS("/swagger/ui", http.FS(swaggerFiles.FS))
+}
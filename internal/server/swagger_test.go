@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"pikachun/internal/config"
+)
+
+// TestOpenAPISpecCoversRoutes 测试所有 API 路由都在 OpenAPI 描述中
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("invalid OpenAPI spec: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("unexpected OpenAPI version %q", spec.OpenAPI)
+	}
+
+	s, _ := newTestServer(t, &config.Config{}, nil)
+
+	param := regexp.MustCompile(`:(\w+)`)
+	for _, route := range s.router.Routes() {
+		if !strings.HasPrefix(route.Path, apiV1Prefix+"/") {
+			continue
+		}
+		path := param.ReplaceAllString(strings.TrimPrefix(route.Path, apiV1Prefix), "{$1}")
+		operations, ok := spec.Paths[path]
+		if !ok {
+			t.Errorf("route %s %s missing from OpenAPI spec", route.Method, path)
+			continue
+		}
+		if _, ok := operations[strings.ToLower(route.Method)]; !ok {
+			t.Errorf("method %s missing for %s in OpenAPI spec", route.Method, path)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/swagger", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "/swagger/openapi.json") {
+		t.Errorf("unexpected swagger page response: %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "https://") {
+		t.Error("expected the swagger page to load no external assets")
+	}
+
+	// Swagger UI 的静态资源随程序内嵌
+	for _, asset := range []string{"/swagger/ui/swagger-ui.css", "/swagger/ui/swagger-ui-bundle.js"} {
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, asset, nil))
+		if w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Errorf("expected embedded asset %s, got %d", asset, w.Code)
+		}
+	}
+}
+
+// TestLegacyAPIRoutes 测试未版本化的旧路径与 v1 路由一致并标记为弃用
+func TestLegacyAPIRoutes(t *testing.T) {
+	s, _ := newTestServer(t, &config.Config{}, nil)
+
+	v1Routes := make(map[string]bool)
+	for _, route := range s.router.Routes() {
+		if strings.HasPrefix(route.Path, apiV1Prefix+"/") {
+			v1Routes[route.Method+" "+strings.TrimPrefix(route.Path, apiV1Prefix)] = true
+		}
+	}
+	for _, route := range s.router.Routes() {
+		if strings.HasPrefix(route.Path, legacyAPIPrefix+"/") && !strings.HasPrefix(route.Path, apiV1Prefix+"/") {
+			key := route.Method + " " + strings.TrimPrefix(route.Path, legacyAPIPrefix)
+			if !v1Routes[key] {
+				t.Errorf("legacy route %s has no v1 counterpart", key)
+			}
+			delete(v1Routes, key)
+		}
+	}
+	for key := range v1Routes {
+		t.Errorf("v1 route %s has no legacy alias", key)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/tasks/abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected legacy route to reach the v1 handler, got %d", w.Code)
+	}
+	if w.Header().Get("Deprecation") != "true" || w.Header().Get("Link") != `</api/v1/tasks/abc>; rel="successor-version"` {
+		t.Errorf("unexpected deprecation headers: %v", w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/abc", nil))
+	if w.Header().Get("X-API-Version") != "v1" || w.Header().Get("Deprecation") != "" {
+		t.Errorf("unexpected v1 headers: %v", w.Header())
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError 请求中一个字段的校验错误，field 为 JSON 字段名
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// identifierPattern 库名和表名：字母、数字、下划线、$ 和 -，最长 64 个字符（MySQL 标识符的长度上限）。
+// 不能包含 .，订阅按 库.表 匹配
+var identifierPattern = regexp.MustCompile(`^[\p{L}\p{N}_$-]{1,64}$`)
+
+// taskEventTypes 任务支持的事件类型
+var taskEventTypes = map[string]bool{"INSERT": true, "UPDATE": true, "DELETE": true}
+
+var registerValidationsOnce sync.Once
+
+// registerValidations 向 gin 的校验器注册任务请求使用的校验规则，校验错误中的字段名使用 JSON 字段名
+func registerValidations() {
+	registerValidationsOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+		v.RegisterValidation("identifier", func(fl validator.FieldLevel) bool {
+			return identifierPattern.MatchString(fl.Field().String())
+		})
+		v.RegisterValidation("event_types", func(fl validator.FieldLevel) bool {
+			for _, eventType := range strings.Split(fl.Field().String(), ",") {
+				if !taskEventTypes[strings.ToUpper(strings.TrimSpace(eventType))] {
+					return false
+				}
+			}
+			return true
+		})
+		// 空字符串表示不设置，必填的地址由 required 检查
+		v.RegisterValidation("webhook_url", func(fl validator.FieldLevel) bool {
+			value := fl.Field().String()
+			if value == "" {
+				return true
+			}
+			parsed, err := url.Parse(value)
+			return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+		})
+	})
+}
+
+// bindStrictJSON 解析请求体并逐字段校验，返回 false 表示已响应错误。
+// 未知字段（多为拼写错误）和无法解析的请求体返回 400，字段校验失败返回 422，details.fields 中列出每个字段的错误
+func bindStrictJSON(c *gin.Context, obj interface{}) bool {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		var details interface{}
+		if field, ok := unknownField(err); ok {
+			details = gin.H{"fields": []FieldError{{Field: field, Message: tr(c, "未知字段")}}}
+		}
+		respondErrorDetails(c, ErrCodeInvalidRequest, tr(c, "请求参数错误: %v", err), details)
+		return false
+	}
+	if fields := validateRequest(c, obj); len(fields) > 0 {
+		respondErrorDetails(c, ErrCodeValidationFailed, tr(c, "请求参数校验失败: %s", joinFieldErrors(fields)), gin.H{"fields": fields})
+		return false
+	}
+	return true
+}
+
+// unknownField 从 JSON 解析错误中取出未知字段名
+func unknownField(err error) (string, bool) {
+	const prefix = "json: unknown field "
+	message := err.Error()
+	if !strings.HasPrefix(message, prefix) {
+		return "", false
+	}
+	field, unquoteErr := strconv.Unquote(strings.TrimPrefix(message, prefix))
+	return field, unquoteErr == nil
+}
+
+// validateRequest 按 binding 标签校验请求，返回每个字段的错误，全部通过时返回 nil
+func validateRequest(c *gin.Context, obj interface{}) []FieldError {
+	registerValidations()
+	err := binding.Validator.ValidateStruct(obj)
+	if err == nil {
+		return nil
+	}
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return []FieldError{{Message: err.Error()}}
+	}
+	fields := make([]FieldError, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		fields = append(fields, FieldError{Field: fieldErr.Field(), Message: fieldErrorMessage(c, fieldErr)})
+	}
+	return fields
+}
+
+// fieldErrorMessage 按校验规则生成字段错误信息
+func fieldErrorMessage(c *gin.Context, fieldErr validator.FieldError) string {
+	isString := fieldErr.Kind() == reflect.String
+	switch fieldErr.Tag() {
+	case "required":
+		return tr(c, "不能为空")
+	case "min":
+		if isString {
+			return tr(c, "长度不能少于 %s 个字符", fieldErr.Param())
+		}
+		return tr(c, "不能小于 %s", fieldErr.Param())
+	case "max":
+		if isString {
+			return tr(c, "长度不能超过 %s 个字符", fieldErr.Param())
+		}
+		return tr(c, "不能大于 %s", fieldErr.Param())
+	case "oneof":
+		return tr(c, "取值必须为 %s 之一", strings.ReplaceAll(fieldErr.Param(), " ", ", "))
+	case "identifier":
+		return tr(c, "只能包含字母、数字、下划线、$ 和 -，最长 64 个字符")
+	case "event_types":
+		return tr(c, "无效的事件类型，支持: INSERT, UPDATE, DELETE")
+	case "webhook_url":
+		return tr(c, "必须是 http 或 https 地址")
+	default:
+		return tr(c, "无效的值")
+	}
+}
+
+// joinFieldErrors 将字段错误合并为一行，用于错误信息
+func joinFieldErrors(fields []FieldError) string {
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field.Field == "" {
+			messages = append(messages, field.Message)
+			continue
+		}
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+	return strings.Join(messages, "; ")
+}
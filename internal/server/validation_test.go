@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestBindStrictJSON 测试未知字段返回 400，字段校验失败返回 422 并按 JSON 字段名列出每个字段的错误
+func TestBindStrictJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registerValidations()
+
+	router := gin.New()
+	router.POST("/create", func(c *gin.Context) {
+		var req CreateTaskRequest
+		if bindStrictJSON(c, &req) {
+			c.Status(http.StatusNoContent)
+		}
+	})
+	router.POST("/update", func(c *gin.Context) {
+		var req UpdateTaskRequest
+		if bindStrictJSON(c, &req) {
+			c.Status(http.StatusNoContent)
+		}
+	})
+
+	valid := `{"name":"orders","database":"shop","table":"order_items","event_types":"INSERT, update","callback_url":"https://example.com/hook"}`
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		fields []string
+	}{
+		{"valid", "/create", valid, http.StatusNoContent, nil},
+		{"unknown field", "/create", strings.Replace(valid, `"table"`, `"tabel"`, 1), http.StatusBadRequest, []string{"tabel"}},
+		{"malformed", "/create", `{"name":`, http.StatusBadRequest, nil},
+		{"missing", "/create", `{"name":"orders"}`, http.StatusUnprocessableEntity, []string{"database", "table", "event_types", "callback_url"}},
+		{"invalid values", "/create", `{"name":"orders","database":"shop","table":"shop.orders","event_types":"INSERT,TRUNCATE","callback_url":"ftp://example.com","partition_count":2048}`,
+			http.StatusUnprocessableEntity, []string{"table", "event_types", "callback_url", "partition_count"}},
+		{"update valid", "/update", `{"table":"orders","status":"inactive"}`, http.StatusNoContent, nil},
+		{"update invalid", "/update", `{"status":"paused","request_timeout":0,"quarantine_url":"example.com"}`,
+			http.StatusUnprocessableEntity, []string{"status", "request_timeout", "quarantine_url"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusNoContent {
+				return
+			}
+
+			var resp struct {
+				Code    ErrorCode `json:"code"`
+				Details struct {
+					Fields []FieldError `json:"fields"`
+				} `json:"details"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var fields []string
+			for _, field := range resp.Details.Fields {
+				if field.Message == "" {
+					t.Errorf("field %s has no message", field.Field)
+				}
+				fields = append(fields, field.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("expected fields %v, got %v", tt.fields, fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"sync"
+
+	"pikachun/internal/canal"
+)
+
+// admissionControl 实例准入控制。通过检查、尚未登记到 instances 的实例预占名额，
+// 并发创建的任务不会一起越过上限
+type admissionControl struct {
+	mu       sync.Mutex
+	starting int              // 正在启动的实例数
+	rejected map[string]int64 // 各上限拒绝的次数
+}
+
+// AdmissionUsage 一项准入上限的使用情况
+type AdmissionUsage struct {
+	Limit    string `json:"limit"`    // canal.limits 的配置项
+	Max      int    `json:"max"`      // 配置的上限，0 表示不限制
+	Current  int    `json:"current"`  // 当前的数量
+	Rejected int64  `json:"rejected"` // 因该上限被拒绝的次数
+}
+
+// admissionCounts 当前的实例数和复制连接数，调用方需持有 admission.mu。
+// 每个实例占用一个复制连接，尚未连接或正在重连的实例也计入
+func (s *EnhancedCanalService) admissionCounts() (instances, connections int) {
+	s.instances.Range(func(key, value interface{}) bool {
+		instances++
+		return true
+	})
+	instances += s.admission.starting
+	connections = canal.ReplicationConnections()
+	if connections < instances {
+		connections = instances
+	}
+	return instances, connections
+}
+
+// checkAdmission 再启动 n 个实例后超过实例数或复制连接数上限时返回 LimitError，并记录拒绝次数。
+// 调用方需持有 admission.mu
+func (s *EnhancedCanalService) checkAdmission(n int) error {
+	limits := s.config.Canal.Limits
+	instances, connections := s.admissionCounts()
+	err := checkLimit(LimitInstances, limits.MaxInstances, instances, n)
+	if err == nil {
+		err = checkLimit(LimitReplicationConnections, limits.MaxReplicationConnections, connections, n)
+	}
+	if limitErr, ok := AsLimitError(err); ok {
+		if s.admission.rejected == nil {
+			s.admission.rejected = make(map[string]int64)
+		}
+		s.admission.rejected[limitErr.Limit]++
+	}
+	return err
+}
+
+// AdmitInstances 检查能否再启动 n 个实例，用于创建任务前提前拒绝，不预占名额
+func (s *EnhancedCanalService) AdmitInstances(n int) error {
+	if n <= 0 {
+		return nil
+	}
+	s.admission.mu.Lock()
+	defer s.admission.mu.Unlock()
+	return s.checkAdmission(n)
+}
+
+// reserveInstance 为即将启动的实例预占名额，返回的函数在实例登记或启动失败后释放名额
+func (s *EnhancedCanalService) reserveInstance() (func(), error) {
+	s.admission.mu.Lock()
+	defer s.admission.mu.Unlock()
+	if err := s.checkAdmission(1); err != nil {
+		return nil, err
+	}
+	s.admission.starting++
+	return func() {
+		s.admission.mu.Lock()
+		s.admission.starting--
+		s.admission.mu.Unlock()
+	}, nil
+}
+
+// AdmissionStatus 各准入上限的使用情况
+func (s *EnhancedCanalService) AdmissionStatus() []AdmissionUsage {
+	limits := s.config.Canal.Limits
+	tasks, err := s.taskService.CountTasks()
+	if err != nil {
+		s.logger.Printf("⚠️ Failed to count tasks for admission status: %v", err)
+	}
+
+	s.admission.mu.Lock()
+	defer s.admission.mu.Unlock()
+	instances, connections := s.admissionCounts()
+	return []AdmissionUsage{
+		{Limit: LimitTasksPerSource, Max: limits.MaxTasksPerSource, Current: int(tasks), Rejected: s.taskService.TaskLimitRejections()},
+		{Limit: LimitInstances, Max: limits.MaxInstances, Current: instances, Rejected: s.admission.rejected[LimitInstances]},
+		{Limit: LimitReplicationConnections, Max: limits.MaxReplicationConnections, Current: connections, Rejected: s.admission.rejected[LimitReplicationConnections]},
+	}
+}
@@ -0,0 +1,199 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/database"
+	"pikachun/internal/notify"
+)
+
+// fireAlert 发送告警，同一告警在限流间隔内只发送一次
+func (s *EnhancedCanalService) fireAlert(alert notify.Alert) {
+	if !s.notifier.Enabled() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sent, err := s.alerter.Fire(ctx, alert)
+	if err != nil {
+		s.logger.Printf("❌ Failed to send %s alert for %s: %v", alert.Type, alert.Key, err)
+		return
+	}
+	if !sent {
+		s.logger.Printf("🔕 Alert %s for %s suppressed by rate limit", alert.Type, alert.Key)
+	}
+}
+
+// checkAlerts 检查同步延迟和失败事件增长
+func (s *EnhancedCanalService) checkAlerts() {
+	if !s.notifier.Enabled() {
+		return
+	}
+
+	cfg := s.config.Alerting
+
+	var lagThreshold time.Duration
+	if cfg.LagThreshold != "" {
+		threshold, err := time.ParseDuration(cfg.LagThreshold)
+		if err != nil {
+			s.logger.Printf("⚠️ Invalid alerting lag_threshold %q", cfg.LagThreshold)
+		} else {
+			lagThreshold = threshold
+		}
+	}
+
+	s.instances.Range(func(key, value interface{}) bool {
+		instanceID := key.(string)
+		var taskID uint
+		if _, err := fmt.Sscanf(instanceID, "task-%d", &taskID); err != nil {
+			return true
+		}
+
+		status := value.(canal.CanalInstance).GetStatus()
+		if lagThreshold > 0 {
+			s.checkLag(instanceID, status, lagThreshold)
+		}
+		if status.PositionDrift != nil {
+			s.alertPositionDrift(instanceID, status.PositionDrift)
+		}
+		if cfg.DLQGrowthThreshold > 0 {
+			s.checkFailedEventGrowth(taskID, int64(cfg.DLQGrowthThreshold))
+		}
+		return true
+	})
+}
+
+// checkLag 复制延迟或心跳新鲜度超过阈值时告警
+func (s *EnhancedCanalService) checkLag(instanceID string, status canal.InstanceStatus, threshold time.Duration) {
+	// 维护窗口内暂停读取，延迟增长是预期行为
+	if status.Paused {
+		return
+	}
+
+	lag := s.effectiveLag(instanceID, status)
+	if lag <= threshold {
+		return
+	}
+
+	s.fireAlert(notify.Alert{
+		Type:  notify.AlertLag,
+		Key:   instanceID,
+		Level: notify.LevelWarning,
+		Data: map[string]interface{}{
+			"instance_id": instanceID,
+			"lag":         lag.Round(time.Second).String(),
+			"threshold":   threshold.String(),
+		},
+	})
+}
+
+// effectiveLag 实例的复制延迟，开启心跳时以端到端新鲜度为准，更能反映下游实际延迟
+func (s *EnhancedCanalService) effectiveLag(instanceID string, status canal.InstanceStatus) time.Duration {
+	lag := time.Duration(status.Lag * float64(time.Second))
+	if value, ok := s.heartbeats.Load(instanceID); ok {
+		stats := value.(*canal.HeartbeatHandler).GetStats()
+		if freshness, ok := stats["freshness_seconds"].(float64); ok {
+			if d := time.Duration(freshness * float64(time.Second)); d > lag {
+				lag = d
+			}
+		}
+	}
+	return lag
+}
+
+// alertPositionDrift 持久化位置漂移告警，无法自动修正时级别为 critical
+func (s *EnhancedCanalService) alertPositionDrift(instanceID string, drift *canal.PositionDrift) {
+	level := notify.LevelWarning
+	resolution := "reconciled to " + formatPosition(drift.Reconciled)
+	if drift.Reconciled == nil {
+		level = notify.LevelCritical
+		resolution = "manual recovery required"
+	}
+
+	s.fireAlert(notify.Alert{
+		Type:  notify.AlertPositionDrift,
+		Key:   instanceID,
+		Level: level,
+		Data: map[string]interface{}{
+			"instance_id": instanceID,
+			"issues":      strings.Join(drift.Issues, ", "),
+			"persisted":   formatPosition(&drift.Persisted),
+			"resolution":  resolution,
+		},
+	})
+}
+
+// formatPosition 以 file:pos 格式显示 binlog 位置
+func formatPosition(pos *canal.Position) string {
+	return fmt.Sprintf("%s:%d", pos.Name, pos.Pos)
+}
+
+// checkFailedEventGrowth 两次检查之间失败事件增长超过阈值时告警
+func (s *EnhancedCanalService) checkFailedEventGrowth(taskID uint, threshold int64) {
+	total, err := s.taskService.CountFailedEventLogs(taskID)
+	if err != nil {
+		s.logger.Printf("❌ Failed to count failed events for task %d: %v", taskID, err)
+		return
+	}
+
+	previous, loaded := s.failedCount.Swap(taskID, total)
+	if !loaded {
+		return
+	}
+
+	growth := total - previous.(int64)
+	if growth < threshold {
+		return
+	}
+
+	s.fireAlert(notify.Alert{
+		Type:  notify.AlertDLQGrowth,
+		Key:   fmt.Sprintf("task-%d", taskID),
+		Level: notify.LevelWarning,
+		Data: map[string]interface{}{
+			"task_id": taskID,
+			"growth":  growth,
+			"total":   total,
+		},
+	})
+}
+
+// recordChecksumSkip 持久化跳过损坏 binlog 事件的审计记录并告警，跳过的事件中的数据没有投递，每个事件都告警
+func (s *EnhancedCanalService) recordChecksumSkip(taskID uint, skipped canal.SkippedEvent) {
+	record := &database.SkippedBinlogEvent{
+		TaskID:    taskID,
+		Filename:  skipped.Position.Name,
+		Position:  skipped.Position.Pos,
+		EndPos:    skipped.EndPos,
+		EventType: skipped.EventType,
+		Error:     skipped.Error,
+		CreatedAt: skipped.At,
+	}
+	if err := s.taskService.RecordSkippedBinlogEvent(record); err != nil {
+		s.logger.Printf("❌ Failed to record skipped binlog event for task %d: %v", taskID, err)
+	}
+
+	position := formatPosition(&skipped.Position)
+	// 在 binlog 读取协程中调用，告警在后台发送
+	go s.fireAlert(notify.Alert{
+		Type:  notify.AlertChecksumSkip,
+		Key:   fmt.Sprintf("task-%d@%s", taskID, position),
+		Level: notify.LevelCritical,
+		Data: map[string]interface{}{
+			"task_id":    taskID,
+			"position":   position,
+			"end_pos":    skipped.EndPos,
+			"event_type": skipped.EventType,
+			"error":      skipped.Error,
+		},
+	})
+}
@@ -0,0 +1,84 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pikachun/internal/canal"
+)
+
+// BackfillEnabled 是否配置了回填目录 canal.backfill_dir
+func (s *EnhancedCanalService) BackfillEnabled() bool {
+	return s.config.Canal.BackfillDir != ""
+}
+
+// BackfillTask 从 canal.backfill_dir 下的 binlog 文件回填任务，事件送入任务运行中实例的事件接收器，
+// 经过与在线读取相同的过滤和处理器。files 为相对于回填目录的文件名，按顺序读取
+func (s *EnhancedCanalService) BackfillTask(taskID uint, files []string, start, stop canal.Position) error {
+	paths, err := s.backfillPaths(files)
+	if err != nil {
+		return err
+	}
+
+	instanceID := fmt.Sprintf("task-%d", taskID)
+	instanceValue, ok := s.instances.Load(instanceID)
+	if !ok {
+		return fmt.Errorf("instance %s not found", instanceID)
+	}
+	instance, ok := instanceValue.(*canal.MySQLCanalInstance)
+	if !ok {
+		return fmt.Errorf("instance %s does not support backfill", instanceID)
+	}
+
+	task, err := s.taskService.GetTask(taskID)
+	if err != nil {
+		return fmt.Errorf("failed to load task %d: %v", taskID, err)
+	}
+
+	s.logger.Printf("📂 Backfilling task %d from %d binlog files", taskID, len(paths))
+	return instance.Backfill(canal.BackfillRequest{
+		Files:         paths,
+		Schema:        task.Database,
+		Table:         task.Table,
+		ExcludeTables: canal.SplitList(task.ExcludeTables),
+		Start:         start,
+		Stop:          stop,
+	})
+}
+
+// backfillPaths 把相对于回填目录的文件名转换为路径，拒绝目录之外的文件
+func (s *EnhancedCanalService) backfillPaths(files []string) ([]string, error) {
+	dir := s.config.Canal.BackfillDir
+	if dir == "" {
+		return nil, fmt.Errorf("canal.backfill_dir is not configured")
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no binlog files to read")
+	}
+
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid canal.backfill_dir: %v", err)
+	}
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		path := filepath.Join(root, file)
+		if filepath.IsAbs(file) || !strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return nil, fmt.Errorf("binlog file %s is outside of canal.backfill_dir", file)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("binlog file %s: %v", file, err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("binlog file %s is a directory", file)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
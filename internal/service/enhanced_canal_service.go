@@ -8,14 +8,21 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"runtime"
+	"sort"
 	"sync"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"pikachun/internal/canal"
 	"pikachun/internal/config"
 	"pikachun/internal/database"
+	"pikachun/internal/logstream"
+	"pikachun/internal/notify"
+	"pikachun/internal/objectstore"
+	"pikachun/internal/slo"
 )
 
 // EnhancedCanalService 增强的Canal服务
@@ -29,6 +36,76 @@ type EnhancedCanalService struct {
 	instances   sync.Map // map[string]canal.CanalInstance
 	metaManager canal.MetaManager
 
+	// 任务生命周期锁，见 lockTask
+	taskLocks sync.Map // map[uint]*sync.Mutex
+
+	// 任务与实例的核对结果，见 reconcileInstances
+	reconcile reconcileStats
+
+	// 实例数和复制连接数的准入控制，见 reserveInstance
+	admission admissionControl
+
+	// 端到端心跳
+	heartbeatWriter *HeartbeatWriter
+	heartbeats      sync.Map // map[string]*canal.HeartbeatHandler
+
+	// 已持久化的实例错误时间，避免重复写库
+	persistedErrors sync.Map // map[string]time.Time
+
+	// 持续失败自动停用
+	webhooks         sync.Map // map[string]*canal.WebhookHandler
+	connFailingSince sync.Map // map[string]time.Time
+	notifier         *notify.MultiNotifier
+
+	// 全局 binlog 读取限速，所有实例共享
+	readThrottle *canal.ReadThrottle
+
+	// 维护暂停开关，所有实例共享
+	maintenance *canal.MaintenanceSwitch
+
+	// 热点路径日志采样，所有实例和处理器共享，未启用时为 nil
+	logSampler *canal.LogSampler
+
+	// 源库的地址和账号，见 sourceConfig
+	source *sourceConnection
+
+	// 数据质量规则中引用存在断言的源库查询，所有任务共享连接和缓存
+	qualityLookup *canal.SourceLookup
+
+	// Webhook 连接池，投递到同一端点的任务共享连接
+	webhookTransports *canal.WebhookTransports
+
+	// 全局投递并发调度，未限制时为 nil
+	deliveryScheduler *canal.DeliveryScheduler
+
+	// 归档使用的对象存储，未启用归档时为 nil
+	archiveStore objectstore.Store
+
+	// 各任务实例和处理器的日志，供界面实时查看
+	taskLogs *logstream.Hub
+
+	// 最近一次采样的进程内存统计，ReadMemStats 会短暂停止所有协程，因此定时采样而不是每次查询时读取
+	memMu        sync.RWMutex
+	memStats     runtime.MemStats
+	memSampledAt time.Time
+
+	// 告警
+	alerter     *notify.Alerter
+	failedCount sync.Map // map[uint]int64 上次检查时的失败事件数
+
+	// 任务 SLO 统计，只在健康检查协程中采样
+	slo          *slo.Tracker
+	sloSettings  sloSettings
+	sloSampledAt time.Time
+	sloBaselines map[uint]deliveryBaseline
+
+	// 各表的行变更统计，由健康检查定时持久化
+	tableChanges *canal.TableChangeCounter
+
+	// 全局监听策略，见 SetWatchPolicy
+	watchMu     sync.RWMutex
+	watchPolicy canal.WatchPolicy
+
 	// 连接池和性能优化
 	connectionPool *ConnectionPool
 	startTime      time.Time
@@ -50,6 +127,19 @@ type ConnectionPool struct {
 func NewEnhancedCanalService(cfg *config.Config, db *gorm.DB, taskService *TaskService) (*EnhancedCanalService, error) {
 	logger := log.New(os.Stdout, "[EnhancedCanal] ", log.LstdFlags|log.Lshortfile)
 
+	// 到源库的隧道，需在建立任何源库连接之前配置
+	if err := canal.ConfigureSourceTunnel(cfg.Canal.Tunnel); err != nil {
+		return nil, fmt.Errorf("invalid canal.tunnel config: %v", err)
+	}
+	if cfg.Canal.Tunnel.Type != "" {
+		logger.Printf("🔧 Connecting to source %s:%d through %s tunnel %s", cfg.Canal.Host, cfg.Canal.Port, cfg.Canal.Tunnel.Type, cfg.Canal.Tunnel.Address)
+	}
+
+	// 确保服务使用的表都已存在，调用方可能只迁移了部分表
+	if err := database.Migrate(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
+	}
+
 	// 创建元数据管理器
 	metaManager, err := canal.NewDBMetaManager(db, logger)
 	if err != nil {
@@ -61,7 +151,47 @@ func NewEnhancedCanalService(cfg *config.Config, db *gorm.DB, taskService *TaskS
 		maxSize: 10,
 	}
 
-	return &EnhancedCanalService{
+	// 创建告警器
+	rateLimit := notify.DefaultRateLimit
+	if cfg.Alerting.RateLimit != "" {
+		if rateLimit, err = time.ParseDuration(cfg.Alerting.RateLimit); err != nil {
+			return nil, fmt.Errorf("invalid alerting rate_limit: %v", err)
+		}
+	}
+	notifier := notify.New(cfg.Notify, logger)
+	alerter, err := notify.NewAlerter(notifier, rateLimit, cfg.Alerting.Templates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create alerter: %v", err)
+	}
+
+	sloSettings, err := parseSLOConfig(cfg.Alerting.SLO)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建 Webhook 连接池
+	webhookTransports, err := canal.NewWebhookTransports(cfg.Canal.WebhookTransport)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建归档使用的对象存储
+	var archiveStore objectstore.Store
+	if cfg.Archive.Enabled {
+		if err := canal.ValidateArchiveConfig(cfg.Archive); err != nil {
+			return nil, err
+		}
+		if archiveStore, err = objectstore.New(cfg.ObjectStore); err != nil {
+			return nil, fmt.Errorf("failed to create archive object store: %v", err)
+		}
+	}
+
+	logSampler, err := canal.NewLogSampler(cfg.Log.Sampling)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log sampling config: %v", err)
+	}
+
+	service := &EnhancedCanalService{
 		config:         cfg,
 		db:             db,
 		logger:         logger,
@@ -69,8 +199,34 @@ func NewEnhancedCanalService(cfg *config.Config, db *gorm.DB, taskService *TaskS
 		metaManager:    metaManager,
 		connectionPool: pool,
 		taskService:    taskService,
+		notifier:       notifier,
+		alerter:        alerter,
+		readThrottle:   canal.NewReadThrottle(cfg.Canal.Throttle),
+		logSampler:     logSampler,
+		archiveStore:   archiveStore,
 		startTime:      time.Now(),
-	}, nil
+
+		webhookTransports: webhookTransports,
+		deliveryScheduler: canal.NewDeliveryScheduler(cfg.Canal.MaxDeliveryConcurrency),
+		maintenance:       canal.NewMaintenanceSwitch(),
+		source:            newSourceConnection(cfg.Canal),
+
+		slo:          slo.NewTracker(sloSettings.window),
+		sloSettings:  sloSettings,
+		sloBaselines: make(map[uint]deliveryBaseline),
+
+		tableChanges: canal.NewTableChangeCounter(),
+		taskLogs:     logstream.NewHub(logstream.DefaultTailSize),
+	}
+	service.qualityLookup = canal.NewSourceLookup(service.sourceConfig)
+	service.loadSLOBuckets()
+	if err := service.loadWatchPolicy(); err != nil {
+		return nil, err
+	}
+	if err := service.loadMaintenancePauses(); err != nil {
+		return nil, err
+	}
+	return service, nil
 }
 
 // Start 启动增强的Canal服务
@@ -98,77 +254,115 @@ func (s *EnhancedCanalService) Start(ctx context.Context) error {
 	s.wg.Add(1)
 	go s.manageConnectionPool()
 
+	// 启动内存采样协程
+	s.sampleMemory()
+	s.wg.Add(1)
+	go s.memorySampler()
+
+	// 启动心跳写入协程
+	if s.config.Canal.Heartbeat.Enabled {
+		writer, err := NewHeartbeatWriter(s.config, s.logger)
+		if err != nil {
+			s.logger.Printf("❌ Failed to create heartbeat writer: %v", err)
+		} else {
+			s.heartbeatWriter = writer
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				writer.Run(s.ctx)
+			}()
+		}
+	}
+
 	s.logger.Println("Enhanced Canal service started")
 	return nil
 }
 
-// Update 某个实例
+// UpdateInstance 更新某个实例
+// 实例运行中且任务仍为活跃状态时原地重新配置，保留 binlog 位置和缓冲中的事件
 func (s *EnhancedCanalService) UpdateInstance(instanceID uint, task *database.Task) error {
-	// 先停止
-	// 日志
-	s.logger.Printf("Updating: Stop instance %s", instanceID)
-	if err := s.StopInstance(instanceID); err != nil {
-		s.logger.Printf("Updating: Stop Failed to stop instance %s: %v", instanceID, err)
+	defer s.lockTask(instanceID)()
+	logger := s.taskLogger(instanceID)
+
+	// 请求中只包含变更字段，以数据库中更新后的完整任务为准
+	current, err := s.taskService.GetTask(instanceID)
+	if err != nil {
+		logger.Printf("Updating: Failed to load task %d: %v", instanceID, err)
+		return fmt.Errorf("failed to load task %d: %v", instanceID, err)
+	}
+
+	instanceValue, exists := s.instances.Load(fmt.Sprintf("task-%d", instanceID))
+
+	// 任务已停用：停止实例
+	if current.Status != "active" {
+		logger.Printf("Updating: Task %d is %s, stopping instance", instanceID, current.Status)
+		return s.stopInstance(instanceID)
+	}
+
+	// 实例不存在：直接创建
+	if !exists {
+		logger.Printf("Updating: Instance for task %d not found, creating", instanceID)
+		return s.createTask(current)
+	}
+
+	instance, ok := instanceValue.(canal.CanalInstance)
+	if !ok {
+		return fmt.Errorf("invalid instance type for task %d", instanceID)
+	}
+
+	logger.Printf("Updating: Reconfiguring instance for task %d in place", instanceID)
+	if err := instance.UpdateInstance(instanceID, current); err != nil {
+		logger.Printf("Updating: Failed to reconfigure instance for task %d: %v", instanceID, err)
 		return err
 	}
-	// 再启动
-	// 日志
-	s.logger.Printf("Updating: Start instance %s", instanceID)
-	// 活跃状态才创建
-	if task.Status == "active" {
-		task.ID = instanceID // 重新添加id
-		if err := s.CreateTask(task); err != nil {
-			s.logger.Printf("Updating: Satrt Failed to stop instance %s: %v", instanceID, err)
-			return err
-		}
+	if err := s.applyTaskContract(instanceID); err != nil {
+		logger.Printf("Updating: Failed to apply consumer contract for task %d: %v", instanceID, err)
+		return err
 	}
-	// 日志
-	s.logger.Printf("Updating: Successfully updated instance %s", instanceID)
 
+	logger.Printf("Updating: Successfully updated instance for task %d", instanceID)
 	return nil
 }
 
 // Stop 某个实例
 func (s *EnhancedCanalService) StopInstance(instanceID uint) error {
+	defer s.lockTask(instanceID)()
+	return s.stopInstance(instanceID)
+}
+
+// stopInstance 停止实例，调用方需持有任务锁
+// 实例投递完缓冲的事件、保存 binlog 位置后摘除任务的处理器，不依赖任务记录，已删除的任务也能停止
+func (s *EnhancedCanalService) stopInstance(instanceID uint) error {
+	logger := s.taskLogger(instanceID)
 
 	if !s.running {
 		// 直接返回
-		s.logger.Printf("Enhanced Canal service not running")
+		logger.Printf("Enhanced Canal service not running")
 		return nil
 	}
 
-	instanceValue, ok := s.instances.Load(fmt.Sprintf("task-%d", instanceID))
+	key := fmt.Sprintf("task-%d", instanceID)
+	instanceValue, ok := s.instances.Load(key)
 	if !ok {
 		// 直接返回
-		s.logger.Printf("Instance %s not found", instanceID)
+		logger.Printf("Instance %s not found", key)
 		return nil
 	}
 
-	// 停止订阅
-	s.logger.Printf("Stopping instance %s", instanceID)
-
-	// 获取任务信息以用于取消订阅
-	oldTask, err := s.taskService.GetTask(instanceID)
-	if err == nil {
-		// 取消订阅处理器
-		s.logger.Printf("Unsubscribing handlers for task %d", instanceID)
-		if instance, ok := instanceValue.(canal.CanalInstance); ok {
-			handlerName1 := fmt.Sprintf("webhook-%d", instanceID)
-			handlerName2 := fmt.Sprintf("db-%d", instanceID)
-			if err := instance.Unsubscribe(oldTask.Database, oldTask.Table, handlerName1); err != nil {
-				s.logger.Printf("Failed to unsubscribe webhook handler for task %d: %v", instanceID, err)
-			}
-			if err := instance.Unsubscribe(oldTask.Database, oldTask.Table, handlerName2); err != nil {
-				s.logger.Printf("Failed to unsubscribe database handler for task %d: %v", instanceID, err)
-			}
-
+	logger.Printf("Stopping instance %s", key)
+	if instance, ok := instanceValue.(canal.CanalInstance); ok {
+		if err := instance.StopInstance(instanceID); err != nil {
+			// 即使停止失败，也继续删除实例以避免实例泄露
+			logger.Printf("Failed to stop instance %s: %v", key, err)
 		}
 	}
 
 	// 日志记录
-	s.logger.Printf("Instance %s stopped", instanceID)
+	logger.Printf("Instance %s stopped", key)
 	// 删除实例
-	s.instances.Delete(fmt.Sprintf("task-%d", instanceID))
+	s.instances.Delete(key)
+	s.heartbeats.Delete(key)
+	s.webhooks.Delete(key)
 
 	return nil
 }
@@ -200,73 +394,285 @@ func (s *EnhancedCanalService) Stop() error {
 		s.wg.Wait()
 	}
 
+	// 实例停止时已刷新缓冲区，释放 Webhook 空闲连接
+	s.webhookTransports.Close()
+	s.qualityLookup.Close()
+
+	s.persistTableChanges(time.Now())
+
 	s.logger.Println("Enhanced Canal service stopped")
 	return nil
 }
 
 // CreateTask 创建监听任务（增强版）
+// 以实例 ID 幂等：任务的实例已在运行时直接返回，不会重复启动
 func (s *EnhancedCanalService) CreateTask(task *database.Task) error {
-	// 打印日志
-	s.logger.Printf("🔧 one log: Creating task %d: %s.%s -> %s", task.ID, task.Database, task.Table, task.CallbackURL)
-	// s.mu.Lock()
-	// defer s.mu.Unlock()
+	defer s.lockTask(task.ID)()
+	return s.createTask(task)
+}
 
-	s.logger.Printf("🔧 two log: Creating task %d: %s.%s -> %s", task.ID, task.Database, task.Table, task.CallbackURL)
+// createTask 创建并启动任务的实例，调用方需持有任务锁
+func (s *EnhancedCanalService) createTask(task *database.Task) error {
+	// 实例和处理器使用任务的日志记录器
+	logger := s.taskLogger(task.ID)
+	logger.Printf("🔧 Creating task %d: %s.%s -> %s", task.ID, task.Database, task.Table, task.CallbackURL)
 
 	instanceID := fmt.Sprintf("task-%d", task.ID)
+	if _, exists := s.instances.Load(instanceID); exists {
+		logger.Printf("⏭️ Instance %s is already running, skipping creation", instanceID)
+		return nil
+	}
+
+	// 准入控制：达到实例数或复制连接数上限时不启动，任务由调用方标记为 pending 后台重试
+	release, err := s.reserveInstance()
+	if err != nil {
+		logger.Printf("🚫 Task %d not started: %v", task.ID, err)
+		return err
+	}
+	defer release()
 
 	// 创建基于真实 MySQL binlog 的 Canal 实例
-	s.logger.Printf("🔧 Creating MySQL canal instance for task %d (database: %s, table: %s)", task.ID, task.Database, task.Table)
+	logger.Printf("🔧 Creating MySQL canal instance for task %d (database: %s, table: %s)", task.ID, task.Database, task.Table)
 
 	var instance canal.CanalInstance
-	var err error
-	instance, err = canal.NewMySQLCanalInstance(instanceID, s.config, s.logger, s.metaManager)
+	mysqlInstance, err := canal.NewMySQLCanalInstance(instanceID, s.instanceConfig(), logger, s.metaManager)
 	if err != nil {
-		s.logger.Printf("❌ Failed to create mysql canal instance for task %d: %v", task.ID, err)
+		logger.Printf("❌ Failed to create mysql canal instance for task %d: %v", task.ID, err)
 		return fmt.Errorf("failed to create mysql canal instance for task %d: %v", task.ID, err)
 	}
-	s.logger.Printf("✅ Canal instance created for task %d", task.ID)
+	// 全局监听策略和任务级排除规则
+	mysqlInstance.SetWatchPolicy(s.WatchPolicy())
+	mysqlInstance.SetChangeCounter(s.tableChanges, task.ID)
+	mysqlInstance.SetChecksumAuditor(func(skipped canal.SkippedEvent) { s.recordChecksumSkip(task.ID, skipped) })
+	mysqlInstance.SetExcludeTables(canal.SplitList(task.ExcludeTables))
+	// 读取限速和维护窗口
+	mysqlInstance.SetReadThrottle(s.readThrottle)
+	mysqlInstance.SetMaintenance(s.maintenance)
+	mysqlInstance.SetLogSampler(s.logSampler)
+	mysqlInstance.SetQualityLookup(s.qualityLookup)
+	mysqlInstance.SetPriority(task.Priority)
+	if err := mysqlInstance.SetSchedule(task); err != nil {
+		logger.Printf("❌ Failed to apply schedule for task %d: %v", task.ID, err)
+		return err
+	}
+	if err := mysqlInstance.SetSampling(task); err != nil {
+		logger.Printf("❌ Failed to apply sampling for task %d: %v", task.ID, err)
+		return err
+	}
+	if err := mysqlInstance.SetLagGuard(task); err != nil {
+		logger.Printf("❌ Failed to apply lag guard for task %d: %v", task.ID, err)
+		return err
+	}
+	// 任务级事件类型：同一张表上的任务可以接收不同类型的事件
+	if err := mysqlInstance.SetEventTypes(task); err != nil {
+		logger.Printf("❌ Failed to apply event types for task %d: %v", task.ID, err)
+		return err
+	}
+	// 任务级行过滤：同一张表上的任务可以只接收各自关心的行
+	if err := mysqlInstance.SetRowFilter(task); err != nil {
+		logger.Printf("❌ Failed to apply row filter for task %d: %v", task.ID, err)
+		return err
+	}
+	instance = mysqlInstance
+	logger.Printf("✅ Canal instance created for task %d", task.ID)
+
+	// 订阅或启动失败时取消已完成的订阅并关闭处理器（如归档处理器的轮转协程），
+	// 失败的任务由后台反复重试，每次失败都不能留下处理器
+	var subscribed []func()
+	started := false
+	defer func() {
+		if started {
+			return
+		}
+		for i := len(subscribed) - 1; i >= 0; i-- {
+			subscribed[i]()
+		}
+		s.webhooks.Delete(instanceID)
+		s.heartbeats.Delete(instanceID)
+	}()
+	subscribe := func(schema, table string, handler canal.EventHandler) error {
+		if err := instance.Subscribe(schema, table, handler); err != nil {
+			handler.Close()
+			return err
+		}
+		subscribed = append(subscribed, func() { instance.Unsubscribe(schema, table, handler.GetName()) })
+		return nil
+	}
 
 	// 创建Webhook处理器
-	s.logger.Printf("🔧 Creating webhook handler for task %d (callback URL: %s)", task.ID, task.CallbackURL)
+	logger.Printf("🔧 Creating webhook handler for task %d (callback URL: %s)", task.ID, task.CallbackURL)
 	webhookHandler := canal.NewWebhookHandler(
 		fmt.Sprintf("webhook-%d", task.ID),
 		task.CallbackURL,
-		s.logger,
+		logger,
 	)
-	s.logger.Printf("✅ Webhook handler created for task %d", task.ID)
+	if s.config.DatabaseStorage.Enabled {
+		webhookHandler.SetDeliveryRecorder(task.ID, s.taskService)
+		// 请求序号接着已记录的最大序号递增
+		if last, err := s.taskService.LastDeliverySequence(task.ID); err != nil {
+			logger.Printf("⚠️ Failed to load last delivery sequence for task %d, cursors disabled: %v", task.ID, err)
+		} else {
+			webhookHandler.SetCursorRecorder(s.taskService, last)
+		}
+	}
+	timeouts := canal.TaskDeliveryTimeouts(task)
+	if err := webhookHandler.SetTimeouts(timeouts); err != nil {
+		logger.Printf("❌ Invalid delivery timeouts for task %d: %v", task.ID, err)
+		return fmt.Errorf("invalid delivery timeouts for task %d: %v", task.ID, err)
+	}
+	mysqlInstance.SetHandlerTimeout(timeouts.Delivery)
+	// 演练模式：只记录将要投递的内容，不调用 Webhook
+	webhookHandler.SetDryRun(task.DryRun)
+	// 分区路由：按分区拆分请求并设置 X-Partition-Key
+	router, err := canal.NewPartitionRouter(task.PartitionBy, task.PartitionColumn, task.PartitionCount)
+	if err != nil {
+		logger.Printf("❌ Invalid partition routing for task %d: %v", task.ID, err)
+		return fmt.Errorf("invalid partition routing for task %d: %v", task.ID, err)
+	}
+	webhookHandler.SetPartitionRouter(router)
+	// 数值安全编码：64 位整数和定点小数编码为字符串
+	webhookHandler.SetNumericStrings(canal.TaskNumericStrings(task, s.config.Canal.NumericStrings))
+	// 表结构元数据：结构变化后才重新发送
+	webhookHandler.SetIncludeSchema(canal.TaskIncludeSchema(task))
+	// 请求体压缩
+	compressor, err := canal.NewPayloadCompressor(task.Compression, task.CompressMinSize)
+	if err != nil {
+		logger.Printf("❌ Invalid compression for task %d: %v", task.ID, err)
+		return fmt.Errorf("invalid compression for task %d: %v", task.ID, err)
+	}
+	webhookHandler.SetCompressor(compressor)
+	// 窗口合并：同一行在窗口内的变更合并后投递
+	compactor, err := canal.NewEventCompactor(task.CompactWindow)
+	if err != nil {
+		logger.Printf("❌ Invalid compact window for task %d: %v", task.ID, err)
+		return fmt.Errorf("invalid compact window for task %d: %v", task.ID, err)
+	}
+	webhookHandler.SetCompactor(compactor)
+	// DELETE 事件投递方式：删除前镜像或墓碑
+	if err := webhookHandler.SetDeleteMode(task.DeleteMode); err != nil {
+		logger.Printf("❌ Invalid delete mode for task %d: %v", task.ID, err)
+		return fmt.Errorf("invalid delete mode for task %d: %v", task.ID, err)
+	}
+	// 载荷映射：序列化时重命名列、展开行数据、删除元数据
+	mapping, err := canal.ParsePayloadMapping(task.PayloadMapping)
+	if err != nil {
+		logger.Printf("❌ Invalid payload mapping for task %d: %v", task.ID, err)
+		return fmt.Errorf("invalid payload mapping for task %d: %v", task.ID, err)
+	}
+	webhookHandler.SetPayloadMapping(mapping)
+	// 来源标识：载荷的 source、environment 字段和请求头
+	identity, err := canal.TaskSourceIdentity(task)
+	if err != nil {
+		logger.Printf("❌ Invalid source identity for task %d: %v", task.ID, err)
+		return fmt.Errorf("invalid source identity for task %d: %v", task.ID, err)
+	}
+	webhookHandler.SetSourceIdentity(identity)
+	// 事件最大存活时间：投递失败后过期的事件不再重试
+	expiry, err := canal.NewEventExpiry(task)
+	if err != nil {
+		logger.Printf("❌ Invalid event expiry for task %d: %v", task.ID, err)
+		return fmt.Errorf("invalid event expiry for task %d: %v", task.ID, err)
+	}
+	webhookHandler.SetEventExpiry(expiry)
+	webhookHandler.SetTransports(s.webhookTransports)
+	// 数据质量规则：违规的事件计数并采样，设置了隔离地址时改投到隔离地址
+	quality, err := canal.ParseQualityRules(task.QualityRules)
+	if err != nil {
+		logger.Printf("❌ Invalid quality rules for task %d: %v", task.ID, err)
+		return fmt.Errorf("invalid quality rules for task %d: %v", task.ID, err)
+	}
+	quality.SetLookup(s.qualityLookup)
+	webhookHandler.SetQualityRules(quality)
+	webhookHandler.SetQuarantineURL(task.QuarantineURL)
+	// 全局投递并发按优先级加权分配
+	webhookHandler.SetScheduler(s.deliveryScheduler)
+	webhookHandler.SetPriority(task.Priority)
+	webhookHandler.SetLogSampler(s.logSampler)
+	logger.Printf("✅ Webhook handler created for task %d", task.ID)
 
 	// 创建数据库处理器
-	s.logger.Printf("🔧 Creating database handler for task %d", task.ID)
+	logger.Printf("🔧 Creating database handler for task %d", task.ID)
 	dbHandler := canal.NewDatabaseHandler(
 		fmt.Sprintf("db-%d", task.ID),
 		task.ID,
-		s.logger,
+		logger,
 		s.taskService,
-		s.config.DatabaseStorage.Enabled,
+		s.config.DatabaseStorage,
 	)
-	s.logger.Printf("✅ Database handler created for task %d", task.ID)
+	dbHandler.SetDryRun(task.DryRun)
+	dbHandler.SetLogSampler(s.logSampler)
+	// 消费端契约：违约的事件不投递，在事件日志中记为 failed
+	contract, err := s.taskService.LoadConsumerContract(task.ID)
+	if err != nil {
+		logger.Printf("❌ Failed to load consumer contract for task %d: %v", task.ID, err)
+		return fmt.Errorf("failed to load consumer contract for task %d: %v", task.ID, err)
+	}
+	webhookHandler.SetContract(contract)
+	dbHandler.SetContract(webhookHandler)
+	dbHandler.SetQualityRules(quality, task.QuarantineURL != "")
+	logger.Printf("✅ Database handler created for task %d", task.ID)
 
 	// 订阅事件
-	s.logger.Printf("🔧 Subscribing webhook handler for task %d to %s.%s", task.ID, task.Database, task.Table)
-	if err := instance.Subscribe(task.Database, task.Table, webhookHandler); err != nil {
-		s.logger.Printf("❌ Failed to subscribe webhook handler for task %d: %v", task.ID, err)
+	logger.Printf("🔧 Subscribing webhook handler for task %d to %s.%s", task.ID, task.Database, task.Table)
+	if err := subscribe(task.Database, task.Table, webhookHandler); err != nil {
+		logger.Printf("❌ Failed to subscribe webhook handler for task %d: %v", task.ID, err)
 		return fmt.Errorf("failed to subscribe webhook handler for task %d: %v", task.ID, err)
 	}
-	s.logger.Printf("✅ Webhook handler subscribed for task %d", task.ID)
+	logger.Printf("✅ Webhook handler subscribed for task %d", task.ID)
+	s.webhooks.Store(instanceID, webhookHandler)
 
-	s.logger.Printf("🔧 Subscribing database handler for task %d to %s.%s", task.ID, task.Database, task.Table)
-	if err := instance.Subscribe(task.Database, task.Table, dbHandler); err != nil {
-		s.logger.Printf("❌ Failed to subscribe database handler for task %d: %v", task.ID, err)
+	logger.Printf("🔧 Subscribing database handler for task %d to %s.%s", task.ID, task.Database, task.Table)
+	if err := subscribe(task.Database, task.Table, dbHandler); err != nil {
+		logger.Printf("❌ Failed to subscribe database handler for task %d: %v", task.ID, err)
 		return fmt.Errorf("failed to subscribe database handler for task %d: %v", task.ID, err)
 	}
-	s.logger.Printf("✅ Database handler subscribed for task %d", task.ID)
+	logger.Printf("✅ Database handler subscribed for task %d", task.ID)
+
+	// 同步到 ClickHouse 分析库
+	if s.config.ClickHouse.Enabled {
+		clickHouseHandler := canal.NewClickHouseHandler(
+			fmt.Sprintf("clickhouse-%d", task.ID),
+			s.config.ClickHouse,
+			s.metaManager,
+			logger,
+		)
+		if err := subscribe(task.Database, task.Table, clickHouseHandler); err != nil {
+			logger.Printf("❌ Failed to subscribe ClickHouse handler for task %d: %v", task.ID, err)
+			return fmt.Errorf("failed to subscribe ClickHouse handler for task %d: %v", task.ID, err)
+		}
+		logger.Printf("✅ ClickHouse handler subscribed for task %d", task.ID)
+	}
+
+	// 归档到对象存储
+	if s.archiveStore != nil {
+		archiveHandler := canal.NewArchiveHandler(
+			fmt.Sprintf("archive-%d", task.ID),
+			s.config.Archive,
+			s.archiveStore,
+			logger,
+		)
+		if err := subscribe(task.Database, task.Table, archiveHandler); err != nil {
+			logger.Printf("❌ Failed to subscribe archive handler for task %d: %v", task.ID, err)
+			return fmt.Errorf("failed to subscribe archive handler for task %d: %v", task.ID, err)
+		}
+		logger.Printf("✅ Archive handler subscribed for task %d", task.ID)
+	}
+
+	// 订阅心跳表，用于计算端到端新鲜度
+	if hb := s.config.Canal.Heartbeat; hb.Enabled {
+		heartbeatHandler := canal.NewHeartbeatHandler(fmt.Sprintf("heartbeat-%d", task.ID), logger)
+		if err := subscribe(hb.Database, hb.Table, heartbeatHandler); err != nil {
+			logger.Printf("⚠️ Failed to subscribe heartbeat handler for task %d: %v", task.ID, err)
+		} else {
+			s.heartbeats.Store(instanceID, heartbeatHandler)
+		}
+	}
 
 	// 启动实例
-	s.logger.Printf("🚀 Starting Canal instance for task %d: %s.%s -> %s", task.ID, task.Database, task.Table, task.CallbackURL)
-	s.logger.Printf("🔧 About to call instance.Start for task %d", task.ID)
+	logger.Printf("🚀 Starting Canal instance for task %d: %s.%s -> %s", task.ID, task.Database, task.Table, task.CallbackURL)
+	logger.Printf("🔧 About to call instance.Start for task %d", task.ID)
 
-	s.logger.Printf("🔧 Calling instance.Start for task %d", task.ID)
+	logger.Printf("🔧 Calling instance.Start for task %d", task.ID)
 	// 检查 s.ctx 是否已初始化，如果没有则使用一个临时的 context
 	ctx := s.ctx
 	if ctx == nil {
@@ -274,136 +680,440 @@ func (s *EnhancedCanalService) CreateTask(task *database.Task) error {
 	}
 
 	if err := instance.Start(ctx); err != nil {
-		s.logger.Printf("❌ Failed to start mysql canal instance for task %d: %v", task.ID, err)
+		logger.Printf("❌ Failed to start mysql canal instance for task %d: %v", task.ID, err)
+		s.persistInstanceError(task.ID, instance.GetStatus())
 		return fmt.Errorf("failed to start mysql canal instance for task %d: %v", task.ID, err)
 	}
-	s.logger.Printf("✅ instance.Start completed for task %d", task.ID)
+	logger.Printf("✅ instance.Start completed for task %d", task.ID)
+	started = true
 
 	s.instances.Store(instanceID, instance)
-	s.logger.Printf("✅ Canal instance started successfully for task %d", task.ID)
-	s.logger.Printf("🔧 Created and started canal instance for task %d", task.ID)
+	logger.Printf("✅ Canal instance started successfully for task %d", task.ID)
+	logger.Printf("🔧 Created and started canal instance for task %d", task.ID)
 
 	return nil
 }
 
-// UpdateTask 更新监听任务
-func (s *EnhancedCanalService) UpdateTask(taskID uint, task *database.Task) error {
+// DeleteTask 删除监听任务
+func (s *EnhancedCanalService) DeleteTask(taskID uint) error {
+	defer s.lockTask(taskID)()
+	return s.deleteTask(taskID)
+}
 
+// deleteTask 停止并移除任务的实例，调用方需持有任务锁
+func (s *EnhancedCanalService) deleteTask(taskID uint) error {
 	instanceID := fmt.Sprintf("task-%d", taskID)
+	logger := s.taskLogger(taskID)
 
-	// 先停止现有的实例（如果存在）
+	// 先尝试获取实例并停止它，任务记录此时可能已被删除
 	if instanceValue, exists := s.instances.Load(instanceID); exists {
 		if instance, ok := instanceValue.(canal.CanalInstance); ok {
-			// 获取任务信息以用于取消订阅
-			oldTask, err := s.taskService.GetTask(taskID)
-			if err == nil {
-				// 取消订阅处理器
-				s.logger.Printf("Unsubscribing handlers for task %d", taskID)
-				handlerName1 := fmt.Sprintf("webhook-%d", taskID)
-				handlerName2 := fmt.Sprintf("db-%d", taskID)
-				if err := instance.Unsubscribe(oldTask.Database, oldTask.Table, handlerName1); err != nil {
-					s.logger.Printf("Failed to unsubscribe webhook handler for task %d: %v", taskID, err)
-				}
-				if err := instance.Unsubscribe(oldTask.Database, oldTask.Table, handlerName2); err != nil {
-					s.logger.Printf("Failed to unsubscribe database handler for task %d: %v", taskID, err)
-				}
-			} else {
-				s.logger.Printf("Failed to get old task info for task %d: %v", taskID, err)
-			}
-
-			// 移除监听
-			s.logger.Printf("Removing canal instance for task %d", taskID)
-
-			if err := instance.Stop(); err != nil {
-				s.logger.Printf("Failed to stop instance %s: %v", instanceID, err)
+			logger.Printf("Stopping canal instance %s for task %d", instanceID, taskID)
+			if err := instance.StopInstance(taskID); err != nil {
+				logger.Printf("Failed to stop instance %s: %v", instanceID, err)
 				// 即使停止失败，也继续删除实例以避免实例泄露
 			} else {
-				s.logger.Printf("Successfully stopped canal instance for task %d", taskID)
+				logger.Printf("Successfully stopped canal instance for task %d", taskID)
 			}
 		}
 	}
 
 	// 确保从sync.Map中删除实例
 	s.instances.Delete(instanceID)
-	s.logger.Printf("Deleted canal instance for task %d", taskID)
+	s.heartbeats.Delete(instanceID)
+	s.webhooks.Delete(instanceID)
+	s.failedCount.Delete(taskID)
+	logger.Printf("Deleted canal instance for task %d", taskID)
 
-	// 如果任务状态是活跃的，重新创建实例
-	if task.Status == "active" {
-		// 创建新的Canal实例
-		instance, err := canal.NewMySQLCanalInstance(instanceID, s.config, s.logger, s.metaManager)
-		if err != nil {
-			s.logger.Printf("Failed to create mysql canal instance for task %d: %v", taskID, err)
-			return fmt.Errorf("创建Canal实例失败: %v", err)
-		}
+	return nil
+}
+
+// RecoverTask 从 binlog 被清除的告警状态中恢复任务
+func (s *EnhancedCanalService) RecoverTask(taskID uint, action canal.RecoveryAction) error {
+	defer s.lockTask(taskID)()
+
+	instanceID := fmt.Sprintf("task-%d", taskID)
+
+	instanceValue, ok := s.instances.Load(instanceID)
+	if !ok {
+		return fmt.Errorf("instance %s not found", instanceID)
+	}
+
+	instance, ok := instanceValue.(*canal.MySQLCanalInstance)
+	if !ok {
+		return fmt.Errorf("instance %s does not support recovery", instanceID)
+	}
+
+	s.logger.Printf("🔧 Recovering task %d with action %s", taskID, action)
+	if err := instance.Recover(action); err != nil {
+		s.logger.Printf("❌ Failed to recover task %d: %v", taskID, err)
+		return err
+	}
+
+	if action == canal.RecoverWithSnapshot {
+		s.logger.Printf("📸 Task %d snapshot recovery started in the background", taskID)
+		return nil
+	}
+	s.logger.Printf("✅ Task %d recovered with action %s", taskID, action)
+	return nil
+}
+
+// ApplyTaskContract 重新加载任务的消费端契约并应用到运行中的实例，实例不存在时不做处理
+func (s *EnhancedCanalService) ApplyTaskContract(taskID uint) error {
+	defer s.lockTask(taskID)()
+	return s.applyTaskContract(taskID)
+}
+
+// applyTaskContract 应用消费端契约，调用方需持有任务锁
+func (s *EnhancedCanalService) applyTaskContract(taskID uint) error {
+	instanceValue, ok := s.instances.Load(fmt.Sprintf("task-%d", taskID))
+	if !ok {
+		return nil
+	}
+	instance, ok := instanceValue.(*canal.MySQLCanalInstance)
+	if !ok {
+		return nil
+	}
+
+	contract, err := s.taskService.LoadConsumerContract(taskID)
+	if err != nil {
+		return fmt.Errorf("failed to load consumer contract for task %d: %v", taskID, err)
+	}
+	instance.SetContract(taskID, contract)
+	s.logger.Printf("📜 Consumer contract for task %d applied (enabled: %t)", taskID, contract != nil)
+	return nil
+}
+
+// TaskRestarts 任务实例由停滞检测触发的 binlog 流重启记录
+func (s *EnhancedCanalService) TaskRestarts(taskID uint) ([]canal.StreamRestart, error) {
+	instanceID := fmt.Sprintf("task-%d", taskID)
+
+	instanceValue, ok := s.instances.Load(instanceID)
+	if !ok {
+		return nil, fmt.Errorf("instance %s not found", instanceID)
+	}
+
+	instance, ok := instanceValue.(*canal.MySQLCanalInstance)
+	if !ok {
+		return nil, fmt.Errorf("instance %s does not support stall detection", instanceID)
+	}
+	return instance.StreamRestarts(), nil
+}
+
+// taskLogger 任务的日志记录器：日志行带有任务、实例和源库标签，并记入任务的日志
+func (s *EnhancedCanalService) taskLogger(taskID uint) *log.Logger {
+	return s.taskLogs.Logger(s.logger, logstream.Labels{
+		TaskID:     taskID,
+		InstanceID: fmt.Sprintf("task-%d", taskID),
+		Source:     canal.DefaultSourceID,
+	})
+}
+
+// SubscribeTaskLogs 订阅任务实例和处理器的日志，返回最近的日志和之后的新行，调用 cancel 取消订阅
+func (s *EnhancedCanalService) SubscribeTaskLogs(taskID uint) (recent []logstream.Entry, entries <-chan logstream.Entry, cancel func(), err error) {
+	if _, err := s.taskService.GetTask(taskID); err != nil {
+		return nil, nil, nil, err
+	}
+	recent, entries, cancel = s.taskLogs.Subscribe(taskID)
+	return recent, entries, cancel, nil
+}
+
+// DropTaskLogs 丢弃已删除任务的日志，订阅者的日志流随之结束。
+// 需在停止实例之后调用，否则实例停止时的日志会重新创建任务的日志
+func (s *EnhancedCanalService) DropTaskLogs(taskID uint) {
+	s.taskLogs.Remove(taskID)
+}
+
+// TaskChecksum 任务实例的事件校验和设置和校验失败的审计记录
+func (s *EnhancedCanalService) TaskChecksum(taskID uint) (canal.ChecksumStatus, error) {
+	instanceID := fmt.Sprintf("task-%d", taskID)
+
+	instanceValue, ok := s.instances.Load(instanceID)
+	if !ok {
+		return canal.ChecksumStatus{}, fmt.Errorf("instance %s not found", instanceID)
+	}
+
+	instance, ok := instanceValue.(*canal.MySQLCanalInstance)
+	if !ok {
+		return canal.ChecksumStatus{}, fmt.Errorf("instance %s does not support binlog checksum", instanceID)
+	}
+	status := instance.ChecksumStatus()
 
-		// 启动实例 检查 s.ctx 是否已初始化，如果没有则使用一个临时的 context
-		ctx := s.ctx
-		if ctx == nil {
-			ctx = context.Background()
+	// 跳过记录以持久化的为准，包括实例重启之前跳过的事件；读取失败或保存失败时使用实例内存中的记录
+	records, total, err := s.taskService.SkippedBinlogEvents(taskID, canal.MaxSkippedEvents)
+	if err != nil {
+		s.logger.Printf("⚠️ Failed to load skipped binlog events for task %d: %v", taskID, err)
+		return status, nil
+	}
+	if total >= int64(status.SkippedTotal) {
+		status.SkippedTotal = int(total)
+		status.Skipped = make([]canal.SkippedEvent, 0, len(records))
+		for _, record := range records {
+			status.Skipped = append(status.Skipped, canal.SkippedEvent{
+				At:        record.CreatedAt,
+				Position:  canal.Position{Name: record.Filename, Pos: record.Position},
+				EndPos:    record.EndPos,
+				EventType: record.EventType,
+				Error:     record.Error,
+			})
 		}
+	}
+	return status, nil
+}
+
+// QualityReport 任务的数据质量报告，实例未运行时返回错误
+func (s *EnhancedCanalService) QualityReport(taskID uint) (canal.QualityReport, error) {
+	instanceID := fmt.Sprintf("task-%d", taskID)
+	value, ok := s.webhooks.Load(instanceID)
+	if !ok {
+		return canal.QualityReport{}, fmt.Errorf("instance %s not found", instanceID)
+	}
+	return value.(*canal.WebhookHandler).QualityReport(), nil
+}
 
-		if err := instance.Start(ctx); err != nil {
-			s.logger.Printf("Failed to start canal instance for task %d: %v", taskID, err)
-			return fmt.Errorf("启动Canal实例失败: %v", err)
+// RestartTask 停止并重新创建任务的 Canal 实例：重新连接源库、重建表结构缓存，从保存的 binlog 位置继续读取。
+// reloadCredentials 为 true 时先重新读取配置中的源库连接信息，用于轮换账号密码后生效
+func (s *EnhancedCanalService) RestartTask(taskID uint, reloadCredentials bool) error {
+	defer s.lockTask(taskID)()
+
+	task, err := s.taskService.GetTask(taskID)
+	if err != nil {
+		return fmt.Errorf("failed to load task %d: %v", taskID, err)
+	}
+	if task.Status != "active" {
+		return fmt.Errorf("task %d is %s, only active tasks can be restarted", taskID, task.Status)
+	}
+	if reloadCredentials {
+		if err := s.reloadSourceConnection(); err != nil {
+			return err
 		}
+	}
 
-		// 存储实例
-		s.instances.Store(instanceID, instance)
-		s.logger.Printf("Task %d updated and instance restarted successfully", taskID)
-	} else {
-		s.logger.Printf("Task %d updated successfully, status is inactive, not starting instance", taskID)
+	instanceID := fmt.Sprintf("task-%d", taskID)
+	s.logger.Printf("🔄 Restarting canal instance for task %d", taskID)
+	if value, ok := s.instances.Load(instanceID); ok {
+		// 停止时刷新缓冲中的事件，新实例从保存的位置继续
+		if err := value.(canal.CanalInstance).Stop(); err != nil {
+			s.logger.Printf("⚠️ Failed to stop instance %s: %v", instanceID, err)
+		}
 	}
+	s.instances.Delete(instanceID)
+	s.heartbeats.Delete(instanceID)
+	s.webhooks.Delete(instanceID)
 
+	if err := s.createTask(task); err != nil {
+		return fmt.Errorf("failed to restart task %d: %v", taskID, err)
+	}
+	s.logger.Printf("✅ Canal instance for task %d restarted", taskID)
 	return nil
 }
 
-// DeleteTask 删除监听任务
-func (s *EnhancedCanalService) DeleteTask(taskID uint) error {
+// Preflight 检查源库能否读取 tables（库.表）的 binlog，见 canal.RunPreflight
+func (s *EnhancedCanalService) Preflight(tables []string) *canal.PreflightReport {
+	cfg := s.sourceConfig()
 
-	instanceID := fmt.Sprintf("task-%d", taskID)
+	// 本服务的实例以同一个 server_id 连接源库，它们不算冲突
+	ownInstances := false
+	s.instances.Range(func(key, value interface{}) bool {
+		if instance, ok := value.(canal.CanalInstance); ok && instance.GetStatus().Running {
+			ownInstances = true
+			return false
+		}
+		return true
+	})
+	return canal.RunPreflight(cfg, tables, ownInstances)
+}
 
-	// 先尝试获取实例并停止它
-	s.logger.Printf("Stopping canal instance for task %d", taskID)
-	if instanceValue, exists := s.instances.Load(instanceID); exists {
-		s.logger.Printf("Instance %s found for task %d", instanceID, taskID)
-		if instance, ok := instanceValue.(canal.CanalInstance); ok {
-			// 获取任务信息以用于取消订阅
-			task, err := s.taskService.GetTask(taskID)
-			// 停止任务的Canal实例
-			s.logger.Printf("Stopping canal instance %s for task %d", instanceID, taskID)
-			if err := instance.Stop(); err != nil {
-				s.logger.Printf("Failed to stop instance %s: %v", instanceID, err)
+// LocatePosition 查找源库上时间点 at 对应的 binlog 位置，用于从该时间点重放，见 canal.LocatePosition
+func (s *EnhancedCanalService) LocatePosition(ctx context.Context, at time.Time) (*canal.LocatedPosition, error) {
+	cfg := s.sourceConfig()
+	return canal.LocatePosition(ctx, cfg, at)
+}
+
+// RotateSourceCredentials 校验新账号的复制权限后切换源库账号：运行中的实例断开 binlog 连接，
+// 用新账号从已处理的位置重连。返回切换的实例数
+func (s *EnhancedCanalService) RotateSourceCredentials(creds canal.SourceCredentials) (int, error) {
+	cfg := s.sourceConfig()
+	cfg.Username, cfg.Password = creds.Username, creds.Password
+	if err := canal.ValidateReplicationAccount(cfg); err != nil {
+		return 0, err
+	}
+
+	s.setSourceCredentials(creds.Username, creds.Password)
+
+	rotated := 0
+	s.instances.Range(func(key, value interface{}) bool {
+		if instance, ok := value.(*canal.MySQLCanalInstance); ok {
+			instance.SetCredentials(creds)
+			rotated++
+		}
+		return true
+	})
+	if s.heartbeatWriter != nil {
+		if err := s.heartbeatWriter.SetCredentials(creds.Username, creds.Password); err != nil {
+			s.logger.Printf("⚠️ Failed to rotate heartbeat writer credentials: %v", err)
+		}
+	}
+
+	s.logger.Printf("🔑 Source credentials rotated to user %s, %d instances reconnecting", creds.Username, rotated)
+	return rotated, nil
+}
+
+// PauseReading 人工维护暂停，source 为空时暂停全部源库。暂停持久化到数据库，重启后仍然生效；
+// 立即保存各实例已投递完成的位置，实例在事务之间断开 binlog 连接，恢复后从断开的位置继续
+func (s *EnhancedCanalService) PauseReading(source, reason string) (canal.MaintenanceStatus, error) {
+	since := time.Now()
+	if current := s.maintenance.Status(); source == "" && current.Global != nil {
+		since = current.Global.Since
+	} else if source != "" && current.Sources[source] != nil {
+		since = current.Sources[source].Since
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason"}),
+	}).Create(&database.MaintenancePause{Source: source, Reason: reason, Since: since}).Error
+	if err != nil {
+		return s.maintenance.Status(), fmt.Errorf("failed to persist maintenance pause: %v", err)
+	}
+
+	s.maintenance.Restore(source, canal.MaintenancePause{Reason: reason, Since: since})
+	if source == "" {
+		s.logger.Printf("⏸️ Global maintenance pause requested: %s", reason)
+	} else {
+		s.logger.Printf("⏸️ Maintenance pause requested for source %s: %s", source, reason)
+	}
+
+	// 目前所有实例都读取默认源库
+	if source == "" || source == canal.DefaultSourceID {
+		s.instances.Range(func(key, value interface{}) bool {
+			instance, ok := value.(*canal.MySQLCanalInstance)
+			if !ok {
+				return true
 			}
-			if err == nil {
-				// 取消订阅处理器
-				s.logger.Printf("Unsubscribing handlers for task %d", taskID)
-				handlerName1 := fmt.Sprintf("webhook-%d", taskID)
-				handlerName2 := fmt.Sprintf("db-%d", taskID)
-				if err := instance.Unsubscribe(task.Database, task.Table, handlerName1); err != nil {
-					s.logger.Printf("Failed to unsubscribe webhook handler for task %d: %v", taskID, err)
-				}
-				if err := instance.Unsubscribe(task.Database, task.Table, handlerName2); err != nil {
-					s.logger.Printf("Failed to unsubscribe database handler for task %d: %v", taskID, err)
-				}
-			} else {
-				s.logger.Printf("Failed to get task info for task %d: %v", taskID, err)
+			if pos, err := instance.SavePosition(); err != nil {
+				s.logger.Printf("❌ Failed to save binlog position of %v for maintenance pause: %v", key, err)
+			} else if pos.Name != "" {
+				s.logger.Printf("💾 Saved binlog position %s:%d of %v for maintenance pause", pos.Name, pos.Pos, key)
 			}
+			return true
+		})
+	}
+	return s.maintenance.Status(), nil
+}
 
-			s.logger.Printf("Stopping canal instance for task %d", taskID)
-			if err := instance.Stop(); err != nil {
-				s.logger.Printf("Failed to stop instance %s: %v", instanceID, err)
-				// 即使停止失败，也继续删除实例以避免实例泄露
-			} else {
-				s.logger.Printf("Successfully stopped canal instance for task %d", taskID)
+// ResumeReading 解除人工维护暂停，source 为空时解除全局暂停。返回之前是否处于暂停
+func (s *EnhancedCanalService) ResumeReading(source string) (bool, canal.MaintenanceStatus, error) {
+	if err := s.db.Where("source = ?", source).Delete(&database.MaintenancePause{}).Error; err != nil {
+		return false, s.maintenance.Status(), fmt.Errorf("failed to delete maintenance pause: %v", err)
+	}
+	resumed := s.maintenance.Resume(source)
+	if resumed {
+		if source == "" {
+			s.logger.Printf("▶️ Global maintenance pause lifted")
+		} else {
+			s.logger.Printf("▶️ Maintenance pause lifted for source %s", source)
+		}
+	}
+	return resumed, s.maintenance.Status(), nil
+}
+
+// loadMaintenancePauses 恢复重启前的人工维护暂停
+func (s *EnhancedCanalService) loadMaintenancePauses() error {
+	var pauses []database.MaintenancePause
+	if err := s.db.Find(&pauses).Error; err != nil {
+		return fmt.Errorf("failed to load maintenance pauses: %v", err)
+	}
+	for _, pause := range pauses {
+		s.maintenance.Restore(pause.Source, canal.MaintenancePause{Reason: pause.Reason, Since: pause.Since})
+		s.logger.Printf("⏸️ Restored maintenance pause (source %q, since %s): %s", pause.Source, pause.Since.Format(time.RFC3339), pause.Reason)
+	}
+	return nil
+}
+
+// SourceTables 源库中匹配规则的表，附带行数、运行中实例观察到的 binlog 活跃度和是否已被任务监听，按活跃度排序
+func (s *EnhancedCanalService) SourceTables(pattern string) ([]canal.SourceTableInfo, error) {
+	tables, err := canal.ListSourceTables(s.sourceConfig(), pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// 各实例都读取同一个源库的完整 binlog，取观察到事件最多的一份
+	activity := make(map[string]canal.TableActivity)
+	s.instances.Range(func(key, value interface{}) bool {
+		instance, ok := value.(*canal.MySQLCanalInstance)
+		if !ok {
+			return true
+		}
+		for _, a := range instance.TableActivity() {
+			k := a.Schema + "." + a.Table
+			if cur, ok := activity[k]; !ok || a.Total > cur.Total {
+				activity[k] = a
 			}
 		}
+		return true
+	})
+
+	subscribed := make(map[string]bool)
+	tasks, err := s.taskService.GetActiveTasks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %v", err)
+	}
+	for _, task := range tasks {
+		subscribed[task.Database+"."+task.Table] = true
 	}
 
-	// 确保从sync.Map中删除实例
-	s.instances.Delete(instanceID)
-	s.logger.Printf("Deleted canal instance for task %d", taskID)
+	infos := make([]canal.SourceTableInfo, 0, len(tables))
+	for _, t := range tables {
+		k := t.Schema + "." + t.Table
+		info := canal.SourceTableInfo{SourceTable: t, Subscribed: subscribed[k]}
+		if a, ok := activity[k]; ok {
+			info.Activity = &a
+		}
+		infos = append(infos, info)
+	}
 
-	return nil
+	sort.SliceStable(infos, func(i, j int) bool {
+		return activityRate(infos[i].Activity) > activityRate(infos[j].Activity)
+	})
+	return infos, nil
+}
+
+// activityRate 近期每分钟事件数，没有观察到时为 0
+func activityRate(a *canal.TableActivity) float64 {
+	if a == nil {
+		return 0
+	}
+	return a.RecentPerMinute
+}
+
+// SimulateEvent 向任务的实例注入模拟事件，未指定库表时使用任务监听的库表
+func (s *EnhancedCanalService) SimulateEvent(taskID uint, sim canal.SimulatedEvent) (*canal.Event, error) {
+	instanceID := fmt.Sprintf("task-%d", taskID)
+
+	instanceValue, ok := s.instances.Load(instanceID)
+	if !ok {
+		return nil, fmt.Errorf("instance %s not found", instanceID)
+	}
+
+	instance, ok := instanceValue.(*canal.MySQLCanalInstance)
+	if !ok {
+		return nil, fmt.Errorf("instance %s does not support simulation", instanceID)
+	}
+
+	if sim.Schema == "" || sim.Table == "" {
+		task, err := s.taskService.GetTask(taskID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load task %d: %v", taskID, err)
+		}
+		if sim.Schema == "" {
+			sim.Schema = task.Database
+		}
+		if sim.Table == "" {
+			sim.Table = task.Table
+		}
+	}
+
+	return instance.Simulate(sim)
 }
 
 // GetStatus 获取服务状态
@@ -427,7 +1137,48 @@ func (s *EnhancedCanalService) GetStatus() map[string]interface{} {
 		"instances":       instanceStatuses,
 		"connection_pool": s.getConnectionPoolStatus(),
 		"memory_usage":    s.getMemoryUsage(),
+		"heartbeat":       s.getHeartbeatStatus(),
+		"alerting":        s.alerter.GetStats(),
+		// 各 Webhook 端点的连接复用情况
+		"webhook_endpoints": s.webhookTransports.Stats(),
+		// 各任务占用和等待的投递并发
+		"delivery_scheduler": s.getDeliverySchedulerStatus(),
+		// 人工维护暂停
+		"maintenance": s.maintenance.Status(),
+		// 热点路径日志采样输出和丢弃的条数
+		"log_sampling": s.logSampler.Stats(),
+		// 任务与实例的核对
+		"reconcile": s.reconcile.snapshot(),
+		// 准入控制上限的使用情况
+		"limits": s.AdmissionStatus(),
+	}
+}
+
+// getDeliverySchedulerStatus 获取投递调度状态
+func (s *EnhancedCanalService) getDeliverySchedulerStatus() map[string]interface{} {
+	if s.deliveryScheduler == nil {
+		return map[string]interface{}{"max_concurrency": 0}
 	}
+	return s.deliveryScheduler.Stats()
+}
+
+// getHeartbeatStatus 获取心跳状态（写入端与各实例的接收端）
+func (s *EnhancedCanalService) getHeartbeatStatus() map[string]interface{} {
+	status := map[string]interface{}{
+		"enabled": s.config.Canal.Heartbeat.Enabled,
+	}
+	if s.heartbeatWriter != nil {
+		status["writer"] = s.heartbeatWriter.GetStats()
+	}
+
+	receivers := make(map[string]interface{})
+	s.heartbeats.Range(func(key, value interface{}) bool {
+		receivers[key.(string)] = value.(*canal.HeartbeatHandler).GetStats()
+		return true
+	})
+	status["receivers"] = receivers
+
+	return status
 }
 
 // monitor 监控协程
@@ -457,12 +1208,65 @@ func (s *EnhancedCanalService) performHealthCheck() {
 
 	s.logger.Printf("Health check: %d active instances", instanceCount)
 
+	// 持久化实例的最近错误
+	s.instances.Range(func(key, value interface{}) bool {
+		var taskID uint
+		if _, err := fmt.Sscanf(key.(string), "task-%d", &taskID); err != nil {
+			return true
+		}
+		s.persistInstanceError(taskID, value.(canal.CanalInstance).GetStatus())
+		return true
+	})
+
+	// 重试启动 pending 任务，核对数据库中的任务与运行中的实例
+	s.activatePendingTasks()
+	s.reconcileInstances()
+
+	// 持续失败的任务自动停用
+	s.applyFailurePolicy()
+
+	// 任务级延迟保护
+	s.applyLagGuards()
+
+	// 延迟与失败事件告警
+	s.checkAlerts()
+
+	// SLO 采样
+	s.recordSLO()
+
+	// 表变更统计
+	s.persistTableChanges(time.Now())
+
+	// 清除超过保留天数的已删除任务
+	s.purgeDeletedTasks(time.Now())
+
+	// 清理超过保留时长的请求序号记录
+	s.pruneDeliveryCursors(time.Now())
+
 	// 检查连接池状态
 	poolStatus := s.getConnectionPoolStatus()
 	s.logger.Printf("Connection pool: %d/%d connections available",
 		poolStatus["available"], poolStatus["max_size"])
 }
 
+// persistInstanceError 将实例的最近错误写入任务记录
+func (s *EnhancedCanalService) persistInstanceError(taskID uint, status canal.InstanceStatus) {
+	if status.ErrorMsg == "" || status.ErrorAt.IsZero() {
+		return
+	}
+
+	key := fmt.Sprintf("task-%d", taskID)
+	if last, ok := s.persistedErrors.Load(key); ok && last.(time.Time).Equal(status.ErrorAt) {
+		return
+	}
+
+	if err := s.taskService.RecordTaskError(taskID, status.ErrorMsg, status.ErrorAt); err != nil {
+		s.logger.Printf("❌ Failed to persist error for task %d: %v", taskID, err)
+		return
+	}
+	s.persistedErrors.Store(key, status.ErrorAt)
+}
+
 // manageConnectionPool 管理连接池
 func (s *EnhancedCanalService) manageConnectionPool() {
 	defer s.wg.Done()
@@ -499,18 +1303,94 @@ func (s *EnhancedCanalService) getConnectionPoolStatus() map[string]interface{}
 	}
 }
 
-// getMemoryUsage 获取内存使用情况
+// memorySampler 按配置的间隔采样进程内存统计
+func (s *EnhancedCanalService) memorySampler() {
+	defer s.wg.Done()
+
+	interval, err := time.ParseDuration(s.config.Canal.Memory.SampleInterval)
+	if err != nil || interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.sampleMemory()
+		}
+	}
+}
+
+// sampleMemory 读取一次进程内存统计
+func (s *EnhancedCanalService) sampleMemory() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	s.memMu.Lock()
+	s.memStats = stats
+	s.memSampledAt = time.Now()
+	s.memMu.Unlock()
+}
+
+// getMemoryUsage 获取内存使用情况：最近一次采样的进程内存统计，以及各实例缓冲事件占用的字节数
 func (s *EnhancedCanalService) getMemoryUsage() map[string]interface{} {
-	// 获取真实的内存使用情况
-	instanceCount := 0
+	s.memMu.RLock()
+	stats, sampledAt := s.memStats, s.memSampledAt
+	s.memMu.RUnlock()
+
+	instances := make(map[string]interface{})
+	var buffered int64
 	s.instances.Range(func(key, value interface{}) bool {
-		instanceCount++
+		status := value.(canal.CanalInstance).GetStatus()
+		instances[key.(string)] = map[string]interface{}{
+			"buffered_bytes": status.BufferedBytes,
+			"memory_paused":  status.MemoryPaused,
+		}
+		buffered += status.BufferedBytes
 		return true
 	})
 
+	limits := canal.NewMemoryLimits(s.config.Canal.Memory)
 	return map[string]interface{}{
-		"instances": instanceCount,
-		"status":    "managed_by_vitess",
+		"alloc_bytes":      stats.Alloc,
+		"heap_inuse_bytes": stats.HeapInuse,
+		"sys_bytes":        stats.Sys,
+		"num_gc":           stats.NumGC,
+		"goroutines":       runtime.NumGoroutine(),
+		"sampled_at":       sampledAt,
+		"buffered_bytes":   buffered,
+		"soft_limit_bytes": limits.Soft,
+		"hard_limit_bytes": limits.Hard,
+		"instance_count":   len(instances),
+		"instances":        instances,
+	}
+}
+
+// DebugInstances 各实例的内部状态：事件通道和处理器队列深度、按 pprof 标签统计的协程数
+func (s *EnhancedCanalService) DebugInstances() map[string]interface{} {
+	goroutines, err := canal.GoroutinesByInstance()
+	if err != nil {
+		s.logger.Printf("⚠️ Failed to count goroutines by instance: %v", err)
+	}
+
+	instances := make(map[string]interface{})
+	s.instances.Range(func(key, value interface{}) bool {
+		instanceID := key.(string)
+		info := map[string]interface{}{}
+		if debuggable, ok := value.(interface{ DebugInfo() map[string]interface{} }); ok {
+			info = debuggable.DebugInfo()
+		}
+		info["goroutines"] = goroutines[instanceID]
+		instances[instanceID] = info
+		return true
+	})
+
+	return map[string]interface{}{
+		"goroutines": runtime.NumGoroutine(),
+		"instances":  instances,
 	}
 }
 
@@ -594,6 +1474,22 @@ func (s *EnhancedCanalService) GetPerformanceMetrics() map[string]interface{} {
 			}
 			if status.ErrorMsg != "" {
 				statusMap["error_msg"] = status.ErrorMsg
+				statusMap["error_at"] = status.ErrorAt
+			}
+			if status.Alert != "" {
+				statusMap["alert"] = status.Alert
+			}
+			if sampling, ok := stats["sampling"].(map[string]interface{}); ok && sampling["enabled"] == true {
+				statusMap["sampling"] = sampling
+			}
+			if pending, ok := stats["pending_events"]; ok {
+				statusMap["pending_events"] = pending
+			}
+			if queue, ok := stats["event_queue"]; ok {
+				statusMap["event_queue"] = queue
+			}
+			if breach, ok := stats["lag_breach"]; ok {
+				statusMap["lag_breach"] = breach
 			}
 			instances[key.(string)] = statusMap
 		}
@@ -638,8 +1534,8 @@ func (s *EnhancedCanalService) GetPerformanceMetrics() map[string]interface{} {
 func (s *EnhancedCanalService) loadExistingTasks() error {
 	var tasks []database.Task
 
-	// 查询所有活跃的任务
-	if err := s.db.Where("status = ?", "active").Find(&tasks).Error; err != nil {
+	// 查询所有活跃的任务，优先级高的任务先启动，先连接源库追赶 binlog
+	if err := s.db.Where("status = ?", "active").Order("priority DESC, id ASC").Find(&tasks).Error; err != nil {
 		s.logger.Printf("❌ Failed to query active tasks: %v", err)
 		// 即使查询失败，也不影响服务启动，只是不加载任何任务
 		return nil
@@ -655,6 +1551,10 @@ func (s *EnhancedCanalService) loadExistingTasks() error {
 		if err := s.CreateTask(&task); err != nil {
 			// 记录详细错误信息，但不中断其他任务的加载
 			s.logger.Printf("❌ Failed to load task %d (%s.%s -> %s): %v", task.ID, task.Database, task.Table, task.CallbackURL, err)
+			// 标记为 pending，由健康检查在后台重试启动
+			if err := s.taskService.MarkTaskPending(task.ID, err.Error()); err != nil {
+				s.logger.Printf("❌ Failed to mark task %d as pending: %v", task.ID, err)
+			}
 			s.logger.Printf("⚠️  Continuing to load other tasks...")
 			// 不返回错误，继续加载其他任务
 			continue
@@ -0,0 +1,144 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/config"
+	databaseCom "pikachun/internal/database"
+)
+
+// newTestService 创建连接不可达源库的服务和一个 active 任务。
+// 有元数据管理器时实例启动不直连源库，binlog 读取协程在后台重连
+func newTestService(t *testing.T, cfg *config.Config) (*EnhancedCanalService, *databaseCom.Task) {
+	t.Helper()
+	db, err := databaseCom.Init(config.DatabaseConfig{DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	cfg.Canal = config.CanalConfig{Host: "127.0.0.1", Port: 1, Username: "root", ServerID: 12345}
+	s, err := NewEnhancedCanalService(cfg, db, NewTaskService(db))
+	if err != nil {
+		t.Fatalf("NewEnhancedCanalService failed: %v", err)
+	}
+
+	task := &databaseCom.Task{Name: "t", Database: "shop", Table: "orders", EventTypes: "INSERT", CallbackURL: "http://localhost", Status: "active"}
+	if err := db.Create(task).Error; err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	return s, task
+}
+
+// runningGoroutines 调用栈中包含 fn 的协程数
+func runningGoroutines(fn string) int {
+	buf := make([]byte, 1<<20)
+	return strings.Count(string(buf[:runtime.Stack(buf, true)]), fn)
+}
+
+// TestCreateTaskCleansUpOnStartFailure 测试实例启动失败时关闭已订阅的处理器（包括归档处理器的轮转协程），不留下 Webhook 处理器记录
+func TestCreateTaskCleansUpOnStartFailure(t *testing.T) {
+	s, task := newTestService(t, &config.Config{
+		Archive:     config.ArchiveConfig{Enabled: true, Format: "ndjson"},
+		ObjectStore: config.ObjectStoreConfig{Type: "local", LocalDir: t.TempDir()},
+	})
+	// 没有元数据管理器时 binlog 读取器启动前直连源库，实例启动失败
+	s.metaManager = nil
+	// 后台重试每次失败都不能泄露处理器
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := s.createTask(task); err == nil {
+			t.Fatal("expected createTask to fail without a reachable source")
+		}
+	}
+
+	instanceID := fmt.Sprintf("task-%d", task.ID)
+	for name, m := range map[string]interface{ Load(any) (any, bool) }{"instances": &s.instances, "webhooks": &s.webhooks, "heartbeats": &s.heartbeats} {
+		if _, ok := m.Load(instanceID); ok {
+			t.Errorf("expected no %s entry for %s", name, instanceID)
+		}
+	}
+	if n := runningGoroutines("(*ArchiveHandler).rotateLoop"); n > 0 {
+		t.Errorf("expected the archive handler closed, got %d running rotate loops", n)
+	}
+}
+
+// TestTaskLifecycleSerialized 测试同一任务并发的创建、删除和启动时加载串行执行：
+// 不会启动两个实例而泄露其中一个，最后删除后没有仍在读取 binlog 的实例
+func TestTaskLifecycleSerialized(t *testing.T) {
+	s, task := newTestService(t, &config.Config{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if err := s.CreateTask(task); err != nil {
+				t.Errorf("CreateTask failed: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := s.DeleteTask(task.ID); err != nil {
+				t.Errorf("DeleteTask failed: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			s.loadExistingTasks()
+		}()
+	}
+	wg.Wait()
+
+	if updated, err := s.taskService.GetTask(task.ID); err != nil || updated.Status != "active" {
+		t.Fatalf("expected the task to stay active, got %+v %v", updated, err)
+	}
+	if err := s.DeleteTask(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := runningGoroutines("(*MySQLBinlogSlave).monitor"); n > 0 {
+		t.Errorf("expected every started instance stopped, got %d running binlog slaves", n)
+	}
+	if _, ok := s.instances.Load(fmt.Sprintf("task-%d", task.ID)); ok {
+		t.Error("expected no instance after the final delete")
+	}
+}
+
+// TestRestartTask 测试重启停止原实例并创建新实例，只有 active 任务可以重启
+func TestRestartTask(t *testing.T) {
+	s, task := newTestService(t, &config.Config{})
+	t.Cleanup(func() { s.DeleteTask(task.ID) })
+	instanceID := fmt.Sprintf("task-%d", task.ID)
+
+	if err := s.CreateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := s.instances.Load(instanceID)
+
+	if err := s.RestartTask(task.ID, false); err != nil {
+		t.Fatalf("RestartTask failed: %v", err)
+	}
+	after, ok := s.instances.Load(instanceID)
+	if !ok || after == before {
+		t.Fatalf("expected a new instance after restart, got %v", after)
+	}
+	if before.(*canal.MySQLCanalInstance).IsRunning() || !after.(*canal.MySQLCanalInstance).IsRunning() {
+		t.Error("expected the old instance stopped and the new one running")
+	}
+
+	if err := s.db.Model(task).Update("status", "inactive").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RestartTask(task.ID, false); err == nil {
+		t.Error("expected restarting an inactive task to fail")
+	}
+	if current, _ := s.instances.Load(instanceID); current != after {
+		t.Error("expected the instance untouched when the restart is rejected")
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ValidationError 任务配置未通过校验，与数据库等内部错误区分，接口据此返回 422
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// IsValidationError 判断错误是否为校验错误
+func IsValidationError(err error) bool {
+	var validationErr *ValidationError
+	return errors.As(err, &validationErr)
+}
+
+// invalid 将校验错误包装为 ValidationError，nil 原样返回
+func invalid(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ValidationError{Err: err}
+}
+
+// 准入控制的上限，与 canal.limits 的配置项对应
+const (
+	LimitTasksPerSource         = "max_tasks_per_source"
+	LimitInstances              = "max_instances"
+	LimitReplicationConnections = "max_replication_connections"
+)
+
+// LimitError 达到准入控制的上限，接口据此返回 429
+type LimitError struct {
+	Limit   string `json:"limit" enums:"max_tasks_per_source,max_instances,max_replication_connections"` // 上限的配置项，如 max_instances
+	Max     int    `json:"max"`                                                                          // 配置的上限
+	Current int    `json:"current"`                                                                      // 当前的数量
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("已达到 canal.limits.%s 上限 %d（当前 %d）", e.Limit, e.Max, e.Current)
+}
+
+// AsLimitError 取出错误中的 LimitError
+func AsLimitError(err error) (*LimitError, bool) {
+	var limitErr *LimitError
+	ok := errors.As(err, &limitErr)
+	return limitErr, ok
+}
+
+// checkLimit 数量增加 n 后超过上限时返回 LimitError，上限不大于 0 表示不限制
+func checkLimit(limit string, max, current, n int) error {
+	if max <= 0 || current+n <= max {
+		return nil
+	}
+	return &LimitError{Limit: limit, Max: max, Current: current}
+}
@@ -0,0 +1,124 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/notify"
+)
+
+// deliveryFailureTracker 记录连续投递失败的起始时间，即任务的 WebhookHandler
+type deliveryFailureTracker interface {
+	FailingSince() time.Time
+}
+
+// failingSince 计算任务持续失败的起始时间（连接错误与投递失败取较早者）
+func (s *EnhancedCanalService) failingSince(instanceID string, status canal.InstanceStatus) time.Time {
+	var since time.Time
+
+	// 连接错误：从首次观察到错误开始计时，错误消失后重置
+	if status.ErrorMsg != "" {
+		first, _ := s.connFailingSince.LoadOrStore(instanceID, status.ErrorAt)
+		since = first.(time.Time)
+	} else {
+		s.connFailingSince.Delete(instanceID)
+	}
+
+	// 投递失败
+	if value, ok := s.webhooks.Load(instanceID); ok {
+		if deliverySince := value.(deliveryFailureTracker).FailingSince(); !deliverySince.IsZero() {
+			if since.IsZero() || deliverySince.Before(since) {
+				since = deliverySince
+			}
+		}
+	}
+
+	return since
+}
+
+// applyFailurePolicy 将持续失败超过阈值的任务标记为 failed、停止实例并通知运维
+func (s *EnhancedCanalService) applyFailurePolicy() {
+	policy := s.config.FailurePolicy
+	if !policy.Enabled {
+		return
+	}
+
+	maxDuration, err := time.ParseDuration(policy.MaxDuration)
+	if err != nil || maxDuration <= 0 {
+		s.logger.Printf("⚠️ Invalid failure policy max_duration %q", policy.MaxDuration)
+		return
+	}
+
+	type failedTask struct {
+		taskID uint
+		since  time.Time
+		reason string
+	}
+	var failed []failedTask
+
+	s.instances.Range(func(key, value interface{}) bool {
+		instanceID := key.(string)
+		var taskID uint
+		if _, err := fmt.Sscanf(instanceID, "task-%d", &taskID); err != nil {
+			return true
+		}
+
+		status := value.(canal.CanalInstance).GetStatus()
+		since := s.failingSince(instanceID, status)
+		if since.IsZero() || time.Since(since) < maxDuration {
+			return true
+		}
+
+		reason := status.ErrorMsg
+		if reason == "" {
+			reason = "webhook delivery failing"
+		}
+		failed = append(failed, failedTask{taskID: taskID, since: since, reason: reason})
+		return true
+	})
+
+	for _, f := range failed {
+		s.disableFailedTask(f.taskID, f.since, f.reason)
+	}
+}
+
+// disableFailedTask 停用持续失败的任务
+func (s *EnhancedCanalService) disableFailedTask(taskID uint, since time.Time, reason string) {
+	s.logger.Printf("🚨 Task %d has been failing since %s, disabling: %s",
+		taskID, since.Format(time.RFC3339), reason)
+
+	if err := s.taskService.MarkTaskFailed(taskID, reason); err != nil {
+		s.logger.Printf("❌ Failed to mark task %d as failed: %v", taskID, err)
+		return
+	}
+
+	if err := s.DeleteTask(taskID); err != nil {
+		s.logger.Printf("❌ Failed to stop instance for failed task %d: %v", taskID, err)
+	}
+	s.connFailingSince.Delete(fmt.Sprintf("task-%d", taskID))
+
+	if !s.notifier.Enabled() {
+		return
+	}
+
+	data := map[string]interface{}{
+		"task_id":       taskID,
+		"failing_since": since.Format(time.RFC3339),
+		"reason":        reason,
+	}
+	if task, err := s.taskService.GetTask(taskID); err == nil {
+		data["task_name"] = task.Name
+		data["table"] = fmt.Sprintf("%s.%s", task.Database, task.Table)
+	}
+
+	s.fireAlert(notify.Alert{
+		Type:  notify.AlertTaskFailed,
+		Key:   fmt.Sprintf("task-%d", taskID),
+		Level: notify.LevelCritical,
+		Data:  data,
+	})
+}
@@ -0,0 +1,184 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/config"
+	databaseCom "pikachun/internal/database"
+)
+
+// failingInstance 返回固定状态的实例，记录是否被停止
+type failingInstance struct {
+	canal.CanalInstance
+	status  canal.InstanceStatus
+	stopped bool
+}
+
+func (i *failingInstance) GetStatus() canal.InstanceStatus {
+	return i.status
+}
+
+func (i *failingInstance) StopInstance(instanceID uint) error {
+	i.stopped = true
+	return nil
+}
+
+// failingWebhook 连续投递失败的起始时间固定的处理器
+type failingWebhook struct {
+	since time.Time
+}
+
+func (w failingWebhook) FailingSince() time.Time {
+	return w.since
+}
+
+// newFailurePolicyService 创建启用失败策略的服务，通知发往返回的通道
+func newFailurePolicyService(t *testing.T, policy config.FailurePolicyConfig) (*EnhancedCanalService, <-chan map[string]interface{}) {
+	alerts := make(chan map[string]interface{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload struct {
+			Message map[string]interface{} `json:"message"`
+		}
+		if err := json.Unmarshal(body, &payload); err == nil {
+			alerts <- payload.Message
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	db, err := databaseCom.Init(config.DatabaseConfig{DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	cfg := &config.Config{
+		Canal:         config.CanalConfig{Host: "127.0.0.1", Port: 3307, Username: "root", ServerID: 12345},
+		FailurePolicy: policy,
+		Notify:        config.NotifyConfig{WebhookURL: server.URL},
+	}
+	s, err := NewEnhancedCanalService(cfg, db, NewTaskService(db))
+	if err != nil {
+		t.Fatalf("NewEnhancedCanalService failed: %v", err)
+	}
+	return s, alerts
+}
+
+// addFailingTask 创建活跃的任务并注册返回 status 的实例，webhookSince 不为零时注册投递失败的处理器
+func addFailingTask(t *testing.T, s *EnhancedCanalService, status canal.InstanceStatus, webhookSince time.Time) (uint, *failingInstance) {
+	task := databaseCom.Task{Name: "t", Database: "shop", Table: "orders", EventTypes: "INSERT", CallbackURL: "http://localhost", Status: "active"}
+	if err := s.db.Create(&task).Error; err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	instance := &failingInstance{status: status}
+	instanceID := fmt.Sprintf("task-%d", task.ID)
+	s.instances.Store(instanceID, instance)
+	if !webhookSince.IsZero() {
+		s.webhooks.Store(instanceID, failingWebhook{since: webhookSince})
+	}
+	return task.ID, instance
+}
+
+// TestFailingSince 测试连接错误从首次观察到时计时、错误消失后重置，与投递失败取较早者
+func TestFailingSince(t *testing.T) {
+	s, _ := newFailurePolicyService(t, config.FailurePolicyConfig{})
+	first := time.Now().Add(-time.Minute)
+
+	if since := s.failingSince("task-1", canal.InstanceStatus{}); !since.IsZero() {
+		t.Errorf("expected no failure, got %v", since)
+	}
+	if since := s.failingSince("task-1", canal.InstanceStatus{ErrorMsg: "connection refused", ErrorAt: first}); !since.Equal(first) {
+		t.Errorf("expected failing since the first error, got %v", since)
+	}
+	// 之后的错误不重新计时
+	if since := s.failingSince("task-1", canal.InstanceStatus{ErrorMsg: "connection refused", ErrorAt: time.Now()}); !since.Equal(first) {
+		t.Errorf("expected the first error time kept, got %v", since)
+	}
+	if since := s.failingSince("task-1", canal.InstanceStatus{}); !since.IsZero() {
+		t.Errorf("expected reset after the error cleared, got %v", since)
+	}
+
+	delivery := time.Now().Add(-time.Hour)
+	s.webhooks.Store("task-1", failingWebhook{since: delivery})
+	if since := s.failingSince("task-1", canal.InstanceStatus{ErrorMsg: "connection refused", ErrorAt: first}); !since.Equal(delivery) {
+		t.Errorf("expected the earlier delivery failure, got %v", since)
+	}
+	s.webhooks.Store("task-1", failingWebhook{})
+	if since := s.failingSince("task-1", canal.InstanceStatus{ErrorMsg: "connection refused", ErrorAt: time.Now()}); !since.Equal(first) {
+		t.Errorf("expected the connection failure without delivery failures, got %v", since)
+	}
+}
+
+// TestApplyFailurePolicy 测试持续失败超过阈值的任务被标记为 failed、停止实例并通知，未超过阈值的任务不受影响
+func TestApplyFailurePolicy(t *testing.T) {
+	s, alerts := newFailurePolicyService(t, config.FailurePolicyConfig{Enabled: true, MaxDuration: "1m"})
+
+	connID, connInstance := addFailingTask(t, s, canal.InstanceStatus{ErrorMsg: "connection refused", ErrorAt: time.Now().Add(-2 * time.Minute)}, time.Time{})
+	deliveryID, _ := addFailingTask(t, s, canal.InstanceStatus{}, time.Now().Add(-5*time.Minute))
+	recentID, recentInstance := addFailingTask(t, s, canal.InstanceStatus{ErrorMsg: "connection refused", ErrorAt: time.Now().Add(-10 * time.Second)}, time.Time{})
+	healthyID, _ := addFailingTask(t, s, canal.InstanceStatus{}, time.Time{})
+
+	s.applyFailurePolicy()
+
+	reasons := map[uint]string{connID: "connection refused", deliveryID: "webhook delivery failing"}
+	for id, reason := range reasons {
+		task, err := s.taskService.GetTask(id)
+		if err != nil {
+			t.Fatalf("GetTask failed: %v", err)
+		}
+		if task.Status != "failed" || task.LastError != reason {
+			t.Errorf("task %d: expected failed with %q, got %s %q", id, reason, task.Status, task.LastError)
+		}
+		if _, ok := s.instances.Load(fmt.Sprintf("task-%d", id)); ok {
+			t.Errorf("task %d: expected the instance removed", id)
+		}
+	}
+	if !connInstance.stopped || recentInstance.stopped {
+		t.Errorf("expected only the failed instance stopped, got %v %v", connInstance.stopped, recentInstance.stopped)
+	}
+	for _, id := range []uint{recentID, healthyID} {
+		if task, _ := s.taskService.GetTask(id); task.Status != "active" {
+			t.Errorf("task %d: expected still active, got %s", id, task.Status)
+		}
+	}
+
+	received := 0
+	timeout := time.After(5 * time.Second)
+	for received < 2 {
+		select {
+		case alert := <-alerts:
+			received++
+			if alert["level"] != "critical" {
+				t.Errorf("expected a critical alert, got %v", alert)
+			}
+		case <-timeout:
+			t.Fatalf("expected 2 alerts, got %d", received)
+		}
+	}
+}
+
+// TestApplyFailurePolicyDisabled 测试未启用或阈值无效时不停用任务
+func TestApplyFailurePolicyDisabled(t *testing.T) {
+	for _, policy := range []config.FailurePolicyConfig{
+		{Enabled: false, MaxDuration: "1m"},
+		{Enabled: true, MaxDuration: "soon"},
+		{Enabled: true, MaxDuration: "0s"},
+	} {
+		s, _ := newFailurePolicyService(t, policy)
+		id, instance := addFailingTask(t, s, canal.InstanceStatus{ErrorMsg: "connection refused", ErrorAt: time.Now().Add(-time.Hour)}, time.Time{})
+
+		s.applyFailurePolicy()
+		if task, _ := s.taskService.GetTask(id); task.Status != "active" || instance.stopped {
+			t.Errorf("policy %+v: expected task %d untouched, got %s", policy, id, task.Status)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/config"
+)
+
+// HeartbeatWriter 心跳写入器
+// 定期向源库的心跳表写入当前时间戳，配合 canal.HeartbeatHandler 计算端到端新鲜度
+type HeartbeatWriter struct {
+	canalCfg config.CanalConfig
+	interval time.Duration
+	logger   *log.Logger
+
+	mu         sync.RWMutex
+	db         *sql.DB
+	lastWrite  time.Time
+	lastError  string
+	writeCount int64
+}
+
+// NewHeartbeatWriter 创建心跳写入器
+func NewHeartbeatWriter(cfg *config.Config, logger *log.Logger) (*HeartbeatWriter, error) {
+	hb := cfg.Canal.Heartbeat
+	if hb.Database == "" || hb.Table == "" {
+		return nil, fmt.Errorf("heartbeat database and table must be configured")
+	}
+
+	interval, err := time.ParseDuration(hb.Interval)
+	if err != nil || interval <= 0 {
+		return nil, fmt.Errorf("invalid heartbeat interval %q", hb.Interval)
+	}
+
+	db, err := openHeartbeatDB(cfg.Canal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HeartbeatWriter{
+		canalCfg: cfg.Canal,
+		interval: interval,
+		logger:   logger,
+		db:       db,
+	}, nil
+}
+
+// openHeartbeatDB 打开写入心跳的源库连接
+func openHeartbeatDB(cfg config.CanalConfig) (*sql.DB, error) {
+	dsn := canal.SourceDSN(cfg.Username, cfg.Password, cfg.Host, cfg.Port, "charset="+cfg.Charset)
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open heartbeat connection: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// SetCredentials 切换源库账号，用新账号重新打开连接
+func (w *HeartbeatWriter) SetCredentials(username, password string) error {
+	cfg := w.canalCfg
+	cfg.Username, cfg.Password = username, password
+	db, err := openHeartbeatDB(cfg)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	old := w.db
+	w.db = db
+	w.mu.Unlock()
+	return old.Close()
+}
+
+// conn 当前的源库连接
+func (w *HeartbeatWriter) conn() *sql.DB {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.db
+}
+
+// Run 运行心跳写入循环，直到上下文取消
+func (w *HeartbeatWriter) Run(ctx context.Context) {
+	defer func() { w.conn().Close() }()
+
+	// 源库启动时不可达或心跳表被删除后写入失败，下次写入前重新建表
+	ready := w.prepareTable(ctx)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !ready {
+				if ready = w.prepareTable(ctx); !ready {
+					continue
+				}
+			}
+			if err := w.write(ctx); err != nil {
+				ready = false
+				w.recordError(err)
+				w.logger.Printf("❌ Failed to write heartbeat: %v", err)
+			}
+		}
+	}
+}
+
+// prepareTable 创建心跳表，失败时记录错误并返回 false
+func (w *HeartbeatWriter) prepareTable(ctx context.Context) bool {
+	if err := w.ensureTable(ctx); err != nil {
+		w.recordError(err)
+		w.logger.Printf("❌ Failed to prepare heartbeat table: %v", err)
+		return false
+	}
+	return true
+}
+
+// ensureTable 创建心跳表
+func (w *HeartbeatWriter) ensureTable(ctx context.Context) error {
+	hb := w.canalCfg.Heartbeat
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`.`%s` (id INT PRIMARY KEY, ts BIGINT NOT NULL)", hb.Database, hb.Table)
+	_, err := w.conn().ExecContext(ctx, query)
+	return err
+}
+
+// write 写入一次心跳
+func (w *HeartbeatWriter) write(ctx context.Context) error {
+	hb := w.canalCfg.Heartbeat
+	now := time.Now()
+	query := fmt.Sprintf("REPLACE INTO `%s`.`%s` (id, ts) VALUES (?, ?)", hb.Database, hb.Table)
+	if _, err := w.conn().ExecContext(ctx, query, w.canalCfg.ServerID, now.UnixNano()); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.lastWrite = now
+	w.lastError = ""
+	w.writeCount++
+	w.mu.Unlock()
+	return nil
+}
+
+// recordError 记录最近一次错误
+func (w *HeartbeatWriter) recordError(err error) {
+	w.mu.Lock()
+	w.lastError = err.Error()
+	w.mu.Unlock()
+}
+
+// GetStats 获取心跳写入统计
+func (w *HeartbeatWriter) GetStats() map[string]interface{} {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return map[string]interface{}{
+		"table":       fmt.Sprintf("%s.%s", w.canalCfg.Heartbeat.Database, w.canalCfg.Heartbeat.Table),
+		"interval":    w.interval.String(),
+		"last_write":  w.lastWrite,
+		"last_error":  w.lastError,
+		"write_count": w.writeCount,
+	}
+}
@@ -0,0 +1,160 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pikachun/internal/config"
+)
+
+// fakeHeartbeatSource 模拟源库的心跳表：建表前写入失败，可以模拟源库不可达和心跳表被删除
+type fakeHeartbeatSource struct {
+	mu          sync.Mutex
+	unreachable bool
+	table       bool
+	creates     int
+	rows        map[int64]int64
+}
+
+func (f *fakeHeartbeatSource) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeHeartbeatConn{source: f}, nil
+}
+
+func (f *fakeHeartbeatSource) Driver() driver.Driver {
+	return nil
+}
+
+// set 修改源库状态
+func (f *fakeHeartbeatSource) set(unreachable, table bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unreachable, f.table = unreachable, table
+}
+
+// state 建表次数和写入的心跳行数
+func (f *fakeHeartbeatSource) state() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.creates, len(f.rows)
+}
+
+// fakeHeartbeatConn 只支持 Exec 的连接
+type fakeHeartbeatConn struct {
+	source *fakeHeartbeatSource
+}
+
+func (c *fakeHeartbeatConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c *fakeHeartbeatConn) Close() error {
+	return nil
+}
+
+func (c *fakeHeartbeatConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+func (c *fakeHeartbeatConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	f := c.source
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.unreachable {
+		return nil, errors.New("connection refused")
+	}
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE"):
+		f.creates++
+		f.table = true
+	case strings.HasPrefix(query, "REPLACE INTO"):
+		if !f.table {
+			return nil, errors.New("table heartbeat.pikachun doesn't exist")
+		}
+		if f.rows == nil {
+			f.rows = make(map[int64]int64)
+		}
+		f.rows[args[0].Value.(int64)] = args[1].Value.(int64)
+	default:
+		return nil, errors.New("unexpected query " + query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// newFakeHeartbeatWriter 创建写入 source 的心跳写入器
+func newFakeHeartbeatWriter(source *fakeHeartbeatSource) *HeartbeatWriter {
+	return &HeartbeatWriter{
+		canalCfg: config.CanalConfig{ServerID: 1001, Heartbeat: config.HeartbeatConfig{Database: "heartbeat", Table: "pikachun"}},
+		interval: 10 * time.Millisecond,
+		logger:   log.New(io.Discard, "", 0),
+		db:       sql.OpenDB(source),
+	}
+}
+
+// waitHeartbeat 等待 cond 成立
+func waitHeartbeat(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestHeartbeatWriterRetriesTable 测试启动时源库不可达、运行中心跳表被删除后都会重新建表并恢复写入
+func TestHeartbeatWriterRetriesTable(t *testing.T) {
+	source := &fakeHeartbeatSource{unreachable: true}
+	w := newFakeHeartbeatWriter(source)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitHeartbeat(t, func() bool { return w.GetStats()["last_error"] != "" }, "expected the unreachable source recorded")
+	source.set(false, false)
+	waitHeartbeat(t, func() bool { _, rows := source.state(); return rows == 1 }, "expected heartbeats written once the source is reachable")
+	if stats := w.GetStats(); stats["last_error"] != "" || stats["write_count"].(int64) == 0 {
+		t.Errorf("expected the error cleared after writing, got %v", stats)
+	}
+
+	// 心跳表被删除
+	creates, _ := source.state()
+	source.set(false, false)
+	waitHeartbeat(t, func() bool { n, _ := source.state(); return n > creates }, "expected the table recreated after a failed write")
+	written := w.GetStats()["write_count"].(int64)
+	waitHeartbeat(t, func() bool { return w.GetStats()["write_count"].(int64) > written }, "expected heartbeats written after recreating the table")
+}
+
+// TestNewHeartbeatWriterValidatesConfig 测试心跳表和间隔必须有效
+func TestNewHeartbeatWriterValidatesConfig(t *testing.T) {
+	for _, hb := range []config.HeartbeatConfig{
+		{Table: "pikachun", Interval: "1s"},
+		{Database: "heartbeat", Interval: "1s"},
+		{Database: "heartbeat", Table: "pikachun", Interval: "soon"},
+		{Database: "heartbeat", Table: "pikachun", Interval: "0s"},
+	} {
+		if _, err := NewHeartbeatWriter(&config.Config{Canal: config.CanalConfig{Heartbeat: hb}}, log.New(io.Discard, "", 0)); err == nil {
+			t.Errorf("expected %+v to be rejected", hb)
+		}
+	}
+}
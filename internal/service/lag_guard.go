@@ -0,0 +1,79 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/notify"
+)
+
+// lagChecker 支持任务级延迟保护的实例
+type lagChecker interface {
+	CheckLag() *canal.LagBreach
+}
+
+// applyLagGuards 检查各任务的延迟保护：超过限制时告警，pause 方式同时暂停任务，
+// sample 方式的采样切换由实例在检查时完成
+func (s *EnhancedCanalService) applyLagGuards() {
+	type pausedTask struct {
+		taskID uint
+		reason string
+	}
+	var paused []pausedTask
+
+	s.instances.Range(func(key, value interface{}) bool {
+		instanceID := key.(string)
+		var taskID uint
+		if _, err := fmt.Sscanf(instanceID, "task-%d", &taskID); err != nil {
+			return true
+		}
+		checker, ok := value.(lagChecker)
+		if !ok {
+			return true
+		}
+
+		breach := checker.CheckLag()
+		if breach == nil {
+			return true
+		}
+
+		s.fireAlert(notify.Alert{
+			Type:  notify.AlertTaskLag,
+			Key:   instanceID,
+			Level: notify.LevelWarning,
+			Data: map[string]interface{}{
+				"task_id":        taskID,
+				"reason":         breach.Reason,
+				"action":         breach.Action,
+				"since":          breach.Since.Format(time.RFC3339),
+				"lag_seconds":    breach.LagSeconds,
+				"pending_events": breach.PendingEvents,
+			},
+		})
+		if breach.Action == canal.LagActionPause {
+			paused = append(paused, pausedTask{taskID: taskID, reason: breach.Reason})
+		}
+		return true
+	})
+
+	for _, p := range paused {
+		s.pauseLaggingTask(p.taskID, p.reason)
+	}
+}
+
+// pauseLaggingTask 暂停超过延迟限制的任务，读取位置已保存，重新启用任务后从该位置继续
+func (s *EnhancedCanalService) pauseLaggingTask(taskID uint, reason string) {
+	s.logger.Printf("⏸️ Task %d exceeded its lag limits, pausing: %s", taskID, reason)
+
+	if err := s.taskService.MarkTaskPaused(taskID, "lag guard: "+reason); err != nil {
+		s.logger.Printf("❌ Failed to mark task %d as paused: %v", taskID, err)
+		return
+	}
+	if err := s.DeleteTask(taskID); err != nil {
+		s.logger.Printf("❌ Failed to stop instance for lagging task %d: %v", taskID, err)
+	}
+}
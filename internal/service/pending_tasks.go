@@ -0,0 +1,58 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"pikachun/internal/database"
+)
+
+// activatePendingTasks 重试启动创建时未能启动实例的 pending 任务，启动成功后标记为 active
+func (s *EnhancedCanalService) activatePendingTasks() {
+	var tasks []database.Task
+	if err := s.db.Where("status = ?", "pending").Order("priority DESC, id ASC").Find(&tasks).Error; err != nil {
+		s.logger.Printf("❌ Failed to query pending tasks: %v", err)
+		return
+	}
+
+	for i := range tasks {
+		s.activatePendingTask(tasks[i].ID)
+	}
+}
+
+// activatePendingTask 在任务锁内重新读取任务，仍为 pending 时启动实例
+func (s *EnhancedCanalService) activatePendingTask(taskID uint) {
+	defer s.lockTask(taskID)()
+
+	task, err := s.taskService.GetTask(taskID)
+	if err != nil || task.Status != "pending" {
+		return
+	}
+	if err := s.createTask(task); err != nil {
+		s.logger.Printf("⏳ Task %d is still pending: %v", taskID, err)
+		if err := s.taskService.RecordPendingTaskError(taskID, err.Error()); err != nil {
+			s.logger.Printf("❌ Failed to record activation error for task %d: %v", taskID, err)
+		}
+		return
+	}
+
+	activated, err := s.taskService.ActivatePendingTask(taskID)
+	if err != nil {
+		s.logger.Printf("❌ Failed to mark task %d as active: %v", taskID, err)
+		return
+	}
+	if activated {
+		s.logger.Printf("✅ Pending task %d activated", taskID)
+		return
+	}
+
+	// 启动期间任务被修改：已改为 active 时保留实例，停用或删除时停止实例
+	current, err := s.taskService.GetTask(taskID)
+	if err == nil && current.Status == "active" {
+		return
+	}
+	s.logger.Printf("⚠️ Task %d was changed while activating, stopping instance", taskID)
+	if err := s.deleteTask(taskID); err != nil {
+		s.logger.Printf("❌ Failed to stop instance for task %d: %v", taskID, err)
+	}
+}
@@ -0,0 +1,78 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"pikachun/internal/config"
+)
+
+// TestLoadExistingTasksMarksPending 测试启动时加载失败的任务改为 pending，由后台重试启动
+func TestLoadExistingTasksMarksPending(t *testing.T) {
+	s, task := newTestService(t, &config.Config{})
+	// 没有元数据管理器时实例启动前直连源库，源库不可达，启动失败
+	s.metaManager = nil
+
+	if err := s.loadExistingTasks(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := s.taskService.GetTask(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Status != "pending" || loaded.LastError == "" {
+		t.Errorf("expected the task marked pending with the start error, got %s %q", loaded.Status, loaded.LastError)
+	}
+}
+
+// TestActivatePendingTask 测试 pending 任务启动失败时保留为 pending 并更新错误，启动成功后改为 active 并清除错误
+func TestActivatePendingTask(t *testing.T) {
+	s, task := newTestService(t, &config.Config{})
+	if err := s.taskService.MarkTaskPending(task.ID, "connection refused"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.DeleteTask(task.ID) })
+
+	metaManager := s.metaManager
+	s.metaManager = nil
+	s.activatePendingTasks()
+	pending, err := s.taskService.GetTask(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Status != "pending" || pending.LastError == "connection refused" || pending.LastError == "" {
+		t.Errorf("expected the task still pending with the new start error, got %s %q", pending.Status, pending.LastError)
+	}
+
+	s.metaManager = metaManager
+	s.activatePendingTasks()
+	active, err := s.taskService.GetTask(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active.Status != "active" || active.LastError != "" || active.LastErrorAt != nil {
+		t.Errorf("expected the task activated with the error cleared, got %s %q", active.Status, active.LastError)
+	}
+	if _, ok := s.instances.Load(fmt.Sprintf("task-%d", task.ID)); !ok {
+		t.Error("expected an instance for the activated task")
+	}
+}
+
+// TestActivatePendingTaskSkipsChangedTasks 测试已不是 pending 的任务不启动实例
+func TestActivatePendingTaskSkipsChangedTasks(t *testing.T) {
+	s, task := newTestService(t, &config.Config{})
+	if err := s.db.Model(task).Update("status", "inactive").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	s.activatePendingTask(task.ID)
+	if _, ok := s.instances.Load(fmt.Sprintf("task-%d", task.ID)); ok {
+		t.Error("expected no instance for the inactive task")
+	}
+	if current, _ := s.taskService.GetTask(task.ID); current.Status != "inactive" {
+		t.Errorf("expected the task to stay inactive, got %s", current.Status)
+	}
+}
@@ -0,0 +1,217 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/database"
+)
+
+// TaskPosition 任务实例的 binlog 位置和落后源库的程度
+type TaskPosition struct {
+	TaskID     uint                      `json:"task_id"`
+	TaskName   string                    `json:"task_name"`
+	TaskStatus string                    `json:"task_status"`
+	Running    bool                      `json:"running"`
+	Alert      string                    `json:"alert,omitempty"`
+	Current    *canal.Position           `json:"current,omitempty"` // 实例当前读取的位置，没有运行中的实例时为空
+	Saved      canal.Position            `json:"saved"`             // 持久化的位置，实例重启后从这里继续
+	Sinks      map[string]canal.Position `json:"sinks,omitempty"`   // 各 sink 持久化的投递进度，重启后跳过不晚于它的事件
+	LagSeconds float64                   `json:"lag_seconds"`       // 最近事件的复制延迟
+	Lag        *canal.PositionLag        `json:"lag,omitempty"`     // 读取位置（没有实例时为持久化位置）落后源库的字节数，无法计算时为空
+	LagError   string                    `json:"lag_error,omitempty"`
+}
+
+// PositionsOverview 源库状态和各任务的 binlog 位置
+type PositionsOverview struct {
+	Source      *canal.SourceStatus `json:"source,omitempty"`
+	SourceError string              `json:"source_error,omitempty"` // 查询源库状态失败的原因，此时不计算字节延迟
+	Tasks       []TaskPosition      `json:"tasks"`
+}
+
+// Positions 查询源库状态和所有任务的当前、持久化位置，计算各任务落后源库的程度
+func (s *EnhancedCanalService) Positions() (*PositionsOverview, error) {
+	var tasks []database.Task
+	if err := s.db.Order("id").Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %v", err)
+	}
+
+	cfg := s.sourceConfig()
+
+	overview := &PositionsOverview{Tasks: make([]TaskPosition, 0, len(tasks))}
+	source, err := canal.QuerySourceStatus(cfg)
+	if err != nil {
+		overview.SourceError = err.Error()
+	} else {
+		overview.Source = source
+	}
+
+	for _, task := range tasks {
+		instanceID := fmt.Sprintf("task-%d", task.ID)
+		tp := TaskPosition{TaskID: task.ID, TaskName: task.Name, TaskStatus: task.Status}
+
+		saved, err := s.metaManager.LoadPosition(instanceID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load saved position of task %d: %v", task.ID, err)
+		}
+		tp.Saved = saved
+		if store, ok := s.metaManager.(canal.SinkOffsetStore); ok {
+			if tp.Sinks, err = store.LoadSinkOffsets(instanceID); err != nil {
+				return nil, fmt.Errorf("failed to load sink offsets of task %d: %v", task.ID, err)
+			}
+		}
+
+		reference := saved
+		if value, ok := s.instances.Load(instanceID); ok {
+			status := value.(canal.CanalInstance).GetStatus()
+			tp.Running = status.Running
+			tp.Alert = status.Alert
+			tp.LagSeconds = status.Lag
+			if status.Position.Name != "" {
+				current := status.Position
+				tp.Current = &current
+				reference = current
+			}
+		}
+
+		if source != nil && reference.Name != "" {
+			if lag, err := source.Lag(reference); err != nil {
+				tp.LagError = err.Error()
+			} else {
+				tp.Lag = &lag
+			}
+		}
+		overview.Tasks = append(overview.Tasks, tp)
+	}
+	return overview, nil
+}
+
+// SetTaskPosition 修改任务持久化的 binlog 位置。位置需在源库上可读取；
+// 运行中的实例先停止（缓冲中的事件投递完），保存新位置后重新创建，从新位置开始读取
+func (s *EnhancedCanalService) SetTaskPosition(taskID uint, pos canal.Position) (canal.Position, error) {
+	cfg := s.sourceConfig()
+
+	source, err := canal.QuerySourceStatus(cfg)
+	if err != nil {
+		return canal.Position{}, fmt.Errorf("failed to verify position on source: %v", err)
+	}
+	if err := source.Validate(pos); err != nil {
+		return canal.Position{}, err
+	}
+
+	pos.GTID = ""
+	return pos, s.repositionTask(taskID, pos)
+}
+
+// ResetTaskPosition 把任务的持久化位置重置到源库最早可读取（earliest）或最新（latest）的位置
+func (s *EnhancedCanalService) ResetTaskPosition(taskID uint, action canal.RecoveryAction) (canal.Position, error) {
+	cfg := s.sourceConfig()
+
+	source, err := canal.QuerySourceStatus(cfg)
+	if err != nil {
+		return canal.Position{}, fmt.Errorf("failed to query source status: %v", err)
+	}
+
+	var pos canal.Position
+	switch action {
+	case canal.RecoverFromEarliest:
+		// 最早文件之前已执行的 GTID 无从得知，从该位置重新累计
+		pos = source.Earliest()
+		if pos.Name == "" {
+			return canal.Position{}, fmt.Errorf("no binary logs available on source")
+		}
+	case canal.RecoverFromLatest:
+		pos = source.Latest()
+	default:
+		return canal.Position{}, fmt.Errorf("unknown reset action: %s", action)
+	}
+	return pos, s.repositionTask(taskID, pos)
+}
+
+// ReplaySink 只向任务的一个 sink 重放 pos 之后的事件，其他 sink 跳过已处理过的事件，见 canal.RewindSink。
+// sink 为处理器名称（如 webhook-1）或其前缀（如 webhook），位置需在源库上可读取
+func (s *EnhancedCanalService) ReplaySink(taskID uint, sink string, pos canal.Position) (canal.Position, error) {
+	name := sink
+	if !strings.HasSuffix(name, fmt.Sprintf("-%d", taskID)) {
+		name = fmt.Sprintf("%s-%d", sink, taskID)
+	}
+	sinks := canal.TaskHandlerNames(taskID)
+	if !slices.Contains(sinks, name) {
+		return canal.Position{}, fmt.Errorf("unknown sink %q, expected one of %s", sink, strings.Join(sinks, ", "))
+	}
+
+	cfg := s.sourceConfig()
+
+	source, err := canal.QuerySourceStatus(cfg)
+	if err != nil {
+		return canal.Position{}, fmt.Errorf("failed to verify position on source: %v", err)
+	}
+	if err := source.Validate(pos); err != nil {
+		return canal.Position{}, err
+	}
+
+	pos.GTID = ""
+	err = s.restartTask(taskID, func(instanceID string) error {
+		if err := canal.RewindSink(s.metaManager, instanceID, name, pos, sinks); err != nil {
+			return fmt.Errorf("failed to rewind sink %s of task %d: %v", name, taskID, err)
+		}
+		s.logger.Printf("⏪ Sink %s of task %d will replay from %s:%d", name, taskID, pos.Name, pos.Pos)
+		return nil
+	})
+	return pos, err
+}
+
+// repositionTask 停止任务的实例，保存新位置后重新创建实例。未启用的任务只保存位置，启用后从新位置开始。
+// 各 sink 的进度一并清除，都从新位置开始
+func (s *EnhancedCanalService) repositionTask(taskID uint, pos canal.Position) error {
+	return s.restartTask(taskID, func(instanceID string) error {
+		if store, ok := s.metaManager.(canal.SinkOffsetStore); ok {
+			if err := store.DeleteSinkOffsets(instanceID); err != nil {
+				return fmt.Errorf("failed to clear sink offsets of task %d: %v", taskID, err)
+			}
+		}
+		if err := s.metaManager.SavePosition(instanceID, pos); err != nil {
+			return fmt.Errorf("failed to save position of task %d: %v", taskID, err)
+		}
+		s.logger.Printf("📍 Binlog position of task %d set to %s:%d", taskID, pos.Name, pos.Pos)
+		return nil
+	})
+}
+
+// restartTask 停止任务的实例，执行 save 修改持久化的位置后重新创建实例。未启用的任务只执行 save
+func (s *EnhancedCanalService) restartTask(taskID uint, save func(instanceID string) error) error {
+	defer s.lockTask(taskID)()
+
+	task, err := s.taskService.GetTask(taskID)
+	if err != nil {
+		return fmt.Errorf("failed to load task %d: %v", taskID, err)
+	}
+
+	instanceID := fmt.Sprintf("task-%d", taskID)
+	if value, ok := s.instances.Load(instanceID); ok {
+		// 停止时会保存投递完成的位置，必须在写入新位置之前完成
+		if err := value.(canal.CanalInstance).Stop(); err != nil {
+			s.logger.Printf("⚠️ Failed to stop instance %s: %v", instanceID, err)
+		}
+	}
+	s.instances.Delete(instanceID)
+	s.heartbeats.Delete(instanceID)
+	s.webhooks.Delete(instanceID)
+
+	if err := save(instanceID); err != nil {
+		return err
+	}
+
+	if task.Status != "active" {
+		return nil
+	}
+	if err := s.createTask(task); err != nil {
+		return fmt.Errorf("position saved but failed to restart task %d: %v", taskID, err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"pikachun/internal/canal"
+	databaseCom "pikachun/internal/database"
+)
+
+// 拉取接口的默认值和上限
+const (
+	DefaultPullConsumer = "default"
+	DefaultPullLimit    = 100
+	MaxPullLimit        = 1000
+	MaxPullWait         = 60 * time.Second
+)
+
+// eventLogSignal 事件日志写入通知：每次写入关闭当前通道并换一个新的，所有等待者随之醒来。
+// 不区分任务，醒来的请求重新查询自己的任务，没有新事件时继续等待
+type eventLogSignal struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+// wait 返回下一次写入时关闭的通道，需在查询之前获取，避免查询与等待之间的写入被错过
+func (s *eventLogSignal) wait() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ch == nil {
+		s.ch = make(chan struct{})
+	}
+	return s.ch
+}
+
+// notify 唤醒所有等待者
+func (s *eventLogSignal) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ch != nil {
+		close(s.ch)
+		s.ch = nil
+	}
+}
+
+// PullRequest 拉取事件的参数
+type PullRequest struct {
+	TaskID   uint
+	Consumer string        // 消费者名称，各消费者的位置分别保存，空表示 default
+	Cursor   *uint         // 已处理的最后一条事件的位置，同时确认并保存；为空时从保存的位置继续
+	Limit    int           // 最多返回的事件数，0 表示默认 100
+	Wait     time.Duration // 没有新事件时最长等待的时间，0 表示立即返回
+}
+
+// PulledEvent 拉取到的事件，cursor 为事件日志的ID
+type PulledEvent struct {
+	Cursor uint         `json:"cursor"`
+	Status string       `json:"status"` // 事件日志的投递状态，拉取不会改变它
+	Event  *canal.Event `json:"event"`
+}
+
+// PullResult 一次拉取的结果，下次拉取时把 next_cursor 作为 cursor 传入即确认本次的事件
+type PullResult struct {
+	Consumer   string        `json:"consumer"`
+	Events     []PulledEvent `json:"events"`
+	NextCursor uint          `json:"next_cursor"`
+}
+
+// PullEvents 长轮询拉取任务位置之后的事件日志，供无法接收 Webhook 的消费端（如位于 NAT 之后）使用。
+// 传入的位置表示之前的事件已处理，保存为消费者的位置；没有新事件时等待写入，直到超时或请求取消
+func (s *TaskService) PullEvents(ctx context.Context, req PullRequest) (*PullResult, error) {
+	if req.Consumer == "" {
+		req.Consumer = DefaultPullConsumer
+	}
+	if req.Limit <= 0 || req.Limit > MaxPullLimit {
+		req.Limit = DefaultPullLimit
+	}
+	if req.Wait > MaxPullWait {
+		req.Wait = MaxPullWait
+	}
+
+	var cursor uint
+	if req.Cursor != nil {
+		cursor = *req.Cursor
+		if err := s.savePullCursor(req.TaskID, req.Consumer, cursor); err != nil {
+			return nil, err
+		}
+	} else {
+		saved, err := s.loadPullCursor(req.TaskID, req.Consumer)
+		if err != nil {
+			return nil, err
+		}
+		cursor = saved
+	}
+
+	timer := time.NewTimer(req.Wait)
+	defer timer.Stop()
+	for {
+		written := s.eventLogsWritten.wait()
+		var logs []databaseCom.EventLog
+		err := s.db.Where("task_id = ? AND id > ?", req.TaskID, cursor).
+			Order("id ASC").Limit(req.Limit).Find(&logs).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(logs) > 0 {
+			return pullResult(req.Consumer, cursor, logs), nil
+		}
+
+		select {
+		case <-written:
+		case <-timer.C:
+			return pullResult(req.Consumer, cursor, nil), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// pullResult 把事件日志转换为拉取结果，无法解析的事件日志原样跳过，避免阻塞之后的事件
+func pullResult(consumer string, cursor uint, logs []databaseCom.EventLog) *PullResult {
+	result := &PullResult{
+		Consumer:   consumer,
+		Events:     make([]PulledEvent, 0, len(logs)),
+		NextCursor: cursor,
+	}
+	for i := range logs {
+		result.NextCursor = logs[i].ID
+		event, err := eventFromLog(&logs[i])
+		if err != nil {
+			continue
+		}
+		result.Events = append(result.Events, PulledEvent{Cursor: logs[i].ID, Status: logs[i].Status, Event: event})
+	}
+	return result
+}
+
+// loadPullCursor 读取消费者保存的位置，没有记录时返回 0，即从最早保留的事件开始
+func (s *TaskService) loadPullCursor(taskID uint, consumer string) (uint, error) {
+	var cursors []databaseCom.PullCursor
+	if err := s.db.Where("task_id = ? AND consumer = ?", taskID, consumer).Limit(1).Find(&cursors).Error; err != nil {
+		return 0, err
+	}
+	if len(cursors) == 0 {
+		return 0, nil
+	}
+	return cursors[0].Cursor, nil
+}
+
+// savePullCursor 保存消费者的位置
+func (s *TaskService) savePullCursor(taskID uint, consumer string, cursor uint) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "task_id"}, {Name: "consumer"}},
+		DoUpdates: clause.AssignmentColumns([]string{"cursor", "updated_at"}),
+	}).Create(&databaseCom.PullCursor{TaskID: taskID, Consumer: consumer, Cursor: cursor}).Error
+}
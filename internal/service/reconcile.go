@@ -0,0 +1,191 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/database"
+)
+
+// reconcileStats 任务与实例核对的累计结果
+type reconcileStats struct {
+	mu        sync.Mutex
+	lastRunAt time.Time
+	started   int64 // 为没有实例的 active 任务启动的实例数
+	stopped   int64 // 停止的孤儿实例数（任务已删除或不再是 active）
+	restarted int64 // 重启的已停止实例数
+
+	// 上次核对时已停止的实例，连续两次核对都已停止才重启，避免与断线重连冲突
+	stalled map[uint]bool
+}
+
+// snapshot 返回核对结果
+func (r *reconcileStats) snapshot() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return map[string]interface{}{
+		"last_run_at": r.lastRunAt,
+		"started":     r.started,
+		"stopped":     r.stopped,
+		"restarted":   r.restarted,
+	}
+}
+
+// add 累加一次核对的结果
+func (r *reconcileStats) add(started, stopped, restarted int64, stalled map[uint]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastRunAt = time.Now()
+	r.started += started
+	r.stopped += stopped
+	r.restarted += restarted
+	r.stalled = stalled
+}
+
+// wasStalled 上次核对时实例是否已停止
+func (r *reconcileStats) wasStalled(taskID uint) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stalled[taskID]
+}
+
+// reconcileInstances 核对数据库中的任务与运行中的实例，使服务在崩溃或部分失败后自行恢复：
+// 为没有实例的 active 任务启动实例（失败时改为 pending），停止任务已删除或不再是 active 的孤儿实例，
+// 重启连续两次核对都已停止且没有告警的实例。pending 任务由 activatePendingTasks 处理
+func (s *EnhancedCanalService) reconcileInstances() {
+	var tasks []database.Task
+	if err := s.db.Select("id", "status").Find(&tasks).Error; err != nil {
+		s.logger.Printf("❌ Failed to query tasks for reconciliation: %v", err)
+		return
+	}
+	statuses := make(map[uint]string, len(tasks))
+	for _, task := range tasks {
+		statuses[task.ID] = task.Status
+	}
+
+	var started, stopped, restarted int64
+	stalled := make(map[uint]bool)
+
+	// 孤儿实例和已停止的实例
+	instances := make(map[uint]canal.CanalInstance)
+	s.instances.Range(func(key, value interface{}) bool {
+		var taskID uint
+		if _, err := fmt.Sscanf(key.(string), "task-%d", &taskID); err != nil {
+			return true
+		}
+		if instance, ok := value.(canal.CanalInstance); ok {
+			instances[taskID] = instance
+		}
+		return true
+	})
+	for taskID, instance := range instances {
+		status, exists := statuses[taskID]
+		if !exists || (status != "active" && status != "pending") {
+			if s.stopOrphanInstance(taskID) {
+				stopped++
+			}
+			continue
+		}
+		if status != "active" {
+			continue
+		}
+		if st := instance.GetStatus(); st.Running || st.Alert != "" {
+			continue
+		}
+		if !s.reconcile.wasStalled(taskID) {
+			stalled[taskID] = true
+			continue
+		}
+		if s.restartStoppedInstance(taskID) {
+			restarted++
+		}
+	}
+
+	// 没有实例的 active 任务
+	for taskID, status := range statuses {
+		if status != "active" {
+			continue
+		}
+		if _, exists := instances[taskID]; exists {
+			continue
+		}
+		if s.startMissingInstance(taskID) {
+			started++
+		}
+	}
+
+	s.reconcile.add(started, stopped, restarted, stalled)
+	if started+stopped+restarted > 0 {
+		s.logger.Printf("♻️ Reconciled tasks and instances: %d started, %d stopped, %d restarted", started, stopped, restarted)
+	}
+}
+
+// stopOrphanInstance 在任务锁内确认任务已删除或不再是 active 后停止实例
+func (s *EnhancedCanalService) stopOrphanInstance(taskID uint) bool {
+	defer s.lockTask(taskID)()
+
+	task, err := s.taskService.GetTask(taskID)
+	if err == nil && (task.Status == "active" || task.Status == "pending") {
+		return false
+	}
+	if _, exists := s.instances.Load(fmt.Sprintf("task-%d", taskID)); !exists {
+		return false
+	}
+	s.logger.Printf("♻️ Instance for task %d has no active task, stopping it", taskID)
+	if err := s.deleteTask(taskID); err != nil {
+		s.logger.Printf("❌ Failed to stop orphan instance for task %d: %v", taskID, err)
+		return false
+	}
+	return true
+}
+
+// restartStoppedInstance 在任务锁内重新创建已停止的实例，从保存的 binlog 位置继续
+func (s *EnhancedCanalService) restartStoppedInstance(taskID uint) bool {
+	defer s.lockTask(taskID)()
+
+	task, err := s.taskService.GetTask(taskID)
+	if err != nil || task.Status != "active" {
+		return false
+	}
+	s.logger.Printf("♻️ Instance for task %d has stopped, restarting it", taskID)
+	if err := s.deleteTask(taskID); err != nil {
+		s.logger.Printf("❌ Failed to remove stopped instance for task %d: %v", taskID, err)
+		return false
+	}
+	if err := s.createTask(task); err != nil {
+		s.logger.Printf("⏳ Failed to restart instance for task %d, marking as pending: %v", taskID, err)
+		if err := s.taskService.MarkTaskPending(taskID, err.Error()); err != nil {
+			s.logger.Printf("❌ Failed to mark task %d as pending: %v", taskID, err)
+		}
+		return false
+	}
+	return true
+}
+
+// startMissingInstance 在任务锁内确认任务仍为 active 且没有实例后启动实例，失败时改为 pending
+func (s *EnhancedCanalService) startMissingInstance(taskID uint) bool {
+	defer s.lockTask(taskID)()
+
+	task, err := s.taskService.GetTask(taskID)
+	if err != nil || task.Status != "active" {
+		return false
+	}
+	if _, exists := s.instances.Load(fmt.Sprintf("task-%d", taskID)); exists {
+		return false
+	}
+
+	s.logger.Printf("♻️ Active task %d has no running instance, starting it", taskID)
+	if err := s.createTask(task); err != nil {
+		s.logger.Printf("⏳ Failed to start instance for task %d, marking as pending: %v", taskID, err)
+		if err := s.taskService.MarkTaskPending(taskID, err.Error()); err != nil {
+			s.logger.Printf("❌ Failed to mark task %d as pending: %v", taskID, err)
+		}
+		return false
+	}
+	return true
+}
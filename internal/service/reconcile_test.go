@@ -0,0 +1,134 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/config"
+	databaseCom "pikachun/internal/database"
+)
+
+// addReconcileTask 创建指定状态的任务，status 不为空时注册返回 status 的实例
+func addReconcileTask(t *testing.T, s *EnhancedCanalService, taskStatus string, status *canal.InstanceStatus) (uint, *failingInstance) {
+	t.Helper()
+	task := databaseCom.Task{Name: "t", Database: "shop", Table: "orders", EventTypes: "INSERT", CallbackURL: "http://localhost", Status: taskStatus}
+	if err := s.db.Create(&task).Error; err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if status == nil {
+		return task.ID, nil
+	}
+	instance := &failingInstance{status: *status}
+	s.instances.Store(fmt.Sprintf("task-%d", task.ID), instance)
+	return task.ID, instance
+}
+
+// reconcileCounts 核对的累计结果：启动、停止和重启的实例数
+func reconcileCounts(s *EnhancedCanalService) (int64, int64, int64) {
+	stats := s.reconcile.snapshot()
+	return stats["started"].(int64), stats["stopped"].(int64), stats["restarted"].(int64)
+}
+
+// TestReconcileStopsOrphanInstances 测试任务已删除或已停用时停止实例，pending 任务的实例保留
+func TestReconcileStopsOrphanInstances(t *testing.T) {
+	s, _ := newTestService(t, &config.Config{})
+
+	inactiveID, inactive := addReconcileTask(t, s, "inactive", &canal.InstanceStatus{Running: true})
+	deletedID, deleted := addReconcileTask(t, s, "active", &canal.InstanceStatus{Running: true})
+	if err := s.taskService.DeleteTask(deletedID); err != nil {
+		t.Fatal(err)
+	}
+	pendingID, pending := addReconcileTask(t, s, "pending", &canal.InstanceStatus{Running: true})
+
+	s.reconcileInstances()
+
+	for id, instance := range map[uint]*failingInstance{inactiveID: inactive, deletedID: deleted} {
+		if !instance.stopped {
+			t.Errorf("task %d: expected the orphan instance stopped", id)
+		}
+		if _, ok := s.instances.Load(fmt.Sprintf("task-%d", id)); ok {
+			t.Errorf("task %d: expected the orphan instance removed", id)
+		}
+	}
+	if _, ok := s.instances.Load(fmt.Sprintf("task-%d", pendingID)); !ok || pending.stopped {
+		t.Errorf("expected the pending task's instance kept")
+	}
+	if _, stopped, _ := reconcileCounts(s); stopped != 2 {
+		t.Errorf("expected 2 stopped instances, got %d", stopped)
+	}
+}
+
+// TestReconcileRestartsStalledInstances 测试已停止的实例连续两次核对都未恢复才重启，有告警的实例不重启
+func TestReconcileRestartsStalledInstances(t *testing.T) {
+	s, task := newTestService(t, &config.Config{})
+	s.instances.Store(fmt.Sprintf("task-%d", task.ID), &failingInstance{})
+	stalled, _ := s.instances.Load(fmt.Sprintf("task-%d", task.ID))
+	alertID, alerted := addReconcileTask(t, s, "active", &canal.InstanceStatus{Alert: canal.AlertBinlogPurged})
+	t.Cleanup(func() { s.DeleteTask(task.ID) })
+
+	// 第一次核对只记录已停止，可能正在断线重连
+	s.reconcileInstances()
+	if stalled.(*failingInstance).stopped {
+		t.Fatal("expected no restart on the first strike")
+	}
+	if _, _, restarted := reconcileCounts(s); restarted != 0 {
+		t.Fatalf("expected no restarts after the first run, got %d", restarted)
+	}
+
+	s.reconcileInstances()
+	if !stalled.(*failingInstance).stopped {
+		t.Error("expected the stalled instance stopped before restarting")
+	}
+	value, ok := s.instances.Load(fmt.Sprintf("task-%d", task.ID))
+	if !ok {
+		t.Fatal("expected a new instance for the stalled task")
+	}
+	if _, ok := value.(*canal.MySQLCanalInstance); !ok {
+		t.Errorf("expected the stalled instance replaced, got %T", value)
+	}
+	if _, _, restarted := reconcileCounts(s); restarted != 1 {
+		t.Errorf("expected 1 restart, got %d", restarted)
+	}
+
+	// 需要人工处理的告警不自动重启
+	if alerted.stopped {
+		t.Errorf("task %d: expected the alerted instance left alone", alertID)
+	}
+	if value, _ := s.instances.Load(fmt.Sprintf("task-%d", alertID)); value != alerted {
+		t.Errorf("task %d: expected the alerted instance kept", alertID)
+	}
+}
+
+// TestReconcileStartsMissingInstances 测试为没有实例的 active 任务启动实例，启动失败时改为 pending
+func TestReconcileStartsMissingInstances(t *testing.T) {
+	s, task := newTestService(t, &config.Config{})
+	t.Cleanup(func() { s.DeleteTask(task.ID) })
+
+	s.reconcileInstances()
+	if _, ok := s.instances.Load(fmt.Sprintf("task-%d", task.ID)); !ok {
+		t.Fatal("expected an instance started for the active task")
+	}
+	if started, _, _ := reconcileCounts(s); started != 1 {
+		t.Errorf("expected 1 started instance, got %d", started)
+	}
+
+	// 没有元数据管理器时实例启动前直连源库，源库不可达，启动失败
+	s.metaManager = nil
+	failingID, _ := addReconcileTask(t, s, "active", nil)
+	s.reconcileInstances()
+
+	failed, err := s.taskService.GetTask(failingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed.Status != "pending" || failed.LastError == "" {
+		t.Errorf("expected the task marked pending with the start error, got %s %q", failed.Status, failed.LastError)
+	}
+	if _, ok := s.instances.Load(fmt.Sprintf("task-%d", failingID)); ok {
+		t.Error("expected no instance for the task that failed to start")
+	}
+}
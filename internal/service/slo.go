@@ -0,0 +1,241 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/config"
+	"pikachun/internal/database"
+	"pikachun/internal/slo"
+)
+
+// sloSettings 解析后的 SLO 配置
+type sloSettings struct {
+	window    time.Duration
+	freshness time.Duration
+	targets   slo.Targets
+}
+
+// 未配置时的 SLO 默认值，与 config.yaml 的默认值相同
+const (
+	defaultSLOWindow    = 28 * 24 * time.Hour
+	defaultSLOFreshness = 5 * time.Minute
+)
+
+var defaultSLOTargets = slo.Targets{slo.Availability: 0.999, slo.Freshness: 0.99, slo.Delivery: 0.999}
+
+// parseSLOConfig 解析并校验 SLO 配置，未配置的项使用默认值
+func parseSLOConfig(cfg config.SLOConfig) (sloSettings, error) {
+	window := defaultSLOWindow
+	if cfg.Window != "" {
+		d, err := time.ParseDuration(cfg.Window)
+		if err != nil || d < slo.BucketWidth {
+			return sloSettings{}, fmt.Errorf("invalid alerting.slo.window %q: must be a duration of at least %s", cfg.Window, slo.BucketWidth)
+		}
+		window = d
+	}
+	freshness := defaultSLOFreshness
+	if cfg.FreshnessThreshold != "" {
+		d, err := time.ParseDuration(cfg.FreshnessThreshold)
+		if err != nil || d <= 0 {
+			return sloSettings{}, fmt.Errorf("invalid alerting.slo.freshness_threshold %q", cfg.FreshnessThreshold)
+		}
+		freshness = d
+	}
+
+	targets := slo.Targets{
+		slo.Availability: cfg.AvailabilityTarget,
+		slo.Freshness:    cfg.FreshnessTarget,
+		slo.Delivery:     cfg.DeliveryTarget,
+	}
+	for _, name := range slo.Names {
+		if targets[name] == 0 {
+			targets[name] = defaultSLOTargets[name]
+		}
+		if !slo.ValidTarget(targets[name]) {
+			return sloSettings{}, fmt.Errorf("invalid alerting.slo.%s_target %v: must be between 0 and 1 (exclusive)", name, targets[name])
+		}
+	}
+	return sloSettings{window: window, freshness: freshness, targets: targets}, nil
+}
+
+// deliveryBaseline 上次采样时 Webhook 处理器的累计投递数，处理器随实例重建后从零开始
+type deliveryBaseline struct {
+	handler   *canal.WebhookHandler
+	delivered int64
+	failed    int64
+}
+
+// TaskSLO 任务的 SLO 统计结果和当前状态
+type TaskSLO struct {
+	TaskID     uint            `json:"task_id"`
+	TaskName   string          `json:"task_name"`
+	TaskStatus string          `json:"task_status"`
+	Running    bool            `json:"running"`
+	LagSeconds float64         `json:"lag_seconds"`
+	Delivered  int64           `json:"delivered"` // 当前实例累计投递成功的事件数，实例重建后从零开始
+	Failed     int64           `json:"failed"`    // 当前实例累计重试全部失败的事件数
+	Objectives []slo.Objective `json:"objectives"`
+}
+
+// SLOReport 所有任务的 SLO 统计结果
+type SLOReport struct {
+	Window      string    `json:"window"` // 统计窗口，如 28d
+	GeneratedAt time.Time `json:"generated_at"`
+	Tasks       []TaskSLO `json:"tasks"`
+}
+
+// loadSLOBuckets 载入保留时长内持久化的统计桶
+func (s *EnhancedCanalService) loadSLOBuckets() {
+	var rows []database.SLOBucket
+	since := time.Now().Add(-s.slo.Retention())
+	if err := s.db.Where("start >= ?", since).Order("task_id, start").Find(&rows).Error; err != nil {
+		s.logger.Printf("⚠️ Failed to load SLO buckets: %v", err)
+		return
+	}
+
+	buckets := make(map[uint][]slo.Bucket)
+	for _, row := range rows {
+		buckets[row.TaskID] = append(buckets[row.TaskID], slo.Bucket{
+			Start:     row.Start,
+			Observed:  row.Observed,
+			Up:        row.Up,
+			Fresh:     row.Fresh,
+			Delivered: row.Delivered,
+			Failed:    row.Failed,
+		})
+	}
+	for taskID, list := range buckets {
+		s.slo.Load(taskID, list)
+	}
+	s.logger.Printf("📊 Loaded %d SLO buckets for %d tasks", len(rows), len(buckets))
+}
+
+// recordSLO 采样各任务的可用性、延迟和投递结果并持久化变化的统计桶，由健康检查定时调用。
+// 启用和等待启动的任务计入统计，自动停用（failed）的任务计为不可用，手动停用的任务和维护暂停期间不计入
+func (s *EnhancedCanalService) recordSLO() {
+	var tasks []database.Task
+	if err := s.db.Find(&tasks).Error; err != nil {
+		s.logger.Printf("❌ Failed to load tasks for SLO: %v", err)
+		return
+	}
+
+	now := time.Now()
+	var elapsed time.Duration
+	if !s.sloSampledAt.IsZero() {
+		elapsed = now.Sub(s.sloSampledAt)
+	}
+	s.sloSampledAt = now
+
+	known := make(map[uint]bool, len(tasks))
+	for _, task := range tasks {
+		known[task.ID] = true
+		instanceID := fmt.Sprintf("task-%d", task.ID)
+
+		sample := slo.Sample{Elapsed: elapsed, Observed: task.Status != "inactive"}
+		if value, ok := s.instances.Load(instanceID); ok {
+			status := value.(canal.CanalInstance).GetStatus()
+			if status.Paused || status.MaintenancePaused {
+				sample.Observed = false
+			}
+			sample.Up = status.Running && status.Alert == ""
+			sample.Fresh = sample.Up && s.effectiveLag(instanceID, status) <= s.sloSettings.freshness
+		}
+		sample.Delivered, sample.Failed = s.deliveryDelta(task.ID, instanceID)
+		s.slo.Record(task.ID, now, sample)
+	}
+
+	// 已删除任务的统计数据不再保留
+	for _, taskID := range s.slo.Tasks() {
+		if !known[taskID] {
+			s.slo.Forget(taskID)
+			delete(s.sloBaselines, taskID)
+		}
+	}
+
+	s.persistSLOBuckets(now)
+}
+
+// deliveryDelta 距上次采样任务新增的投递成功和失败事件数
+func (s *EnhancedCanalService) deliveryDelta(taskID uint, instanceID string) (delivered, failed int64) {
+	value, ok := s.webhooks.Load(instanceID)
+	if !ok {
+		delete(s.sloBaselines, taskID)
+		return 0, 0
+	}
+
+	handler := value.(*canal.WebhookHandler)
+	current := deliveryBaseline{handler: handler}
+	current.delivered, current.failed = handler.DeliveryCounts()
+
+	previous := s.sloBaselines[taskID]
+	s.sloBaselines[taskID] = current
+	if previous.handler != handler {
+		previous = deliveryBaseline{}
+	}
+	return current.delivered - previous.delivered, current.failed - previous.failed
+}
+
+// persistSLOBuckets 写入变化的统计桶，删除超出保留时长的桶
+func (s *EnhancedCanalService) persistSLOBuckets(now time.Time) {
+	var rows []database.SLOBucket
+	for taskID, buckets := range s.slo.Flush() {
+		for _, b := range buckets {
+			rows = append(rows, database.SLOBucket{
+				TaskID:    taskID,
+				Start:     b.Start,
+				Observed:  b.Observed,
+				Up:        b.Up,
+				Fresh:     b.Fresh,
+				Delivered: b.Delivered,
+				Failed:    b.Failed,
+			})
+		}
+	}
+
+	if len(rows) > 0 {
+		err := s.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "task_id"}, {Name: "start"}},
+			DoUpdates: clause.AssignmentColumns([]string{"observed", "up", "fresh", "delivered", "failed"}),
+		}).Create(&rows).Error
+		if err != nil {
+			s.logger.Printf("❌ Failed to persist SLO buckets: %v", err)
+		}
+	}
+
+	if err := s.db.Where("start < ?", now.Add(-s.slo.Retention())).Delete(&database.SLOBucket{}).Error; err != nil {
+		s.logger.Printf("❌ Failed to prune SLO buckets: %v", err)
+	}
+}
+
+// SLOReport 计算所有任务的 SLO 统计结果，用于导出 Prometheus 指标
+func (s *EnhancedCanalService) SLOReport() (*SLOReport, error) {
+	var tasks []database.Task
+	if err := s.db.Order("id").Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %v", err)
+	}
+
+	now := time.Now()
+	report := &SLOReport{Window: slo.FormatWindow(s.slo.Window()), GeneratedAt: now, Tasks: make([]TaskSLO, 0, len(tasks))}
+	for _, task := range tasks {
+		instanceID := fmt.Sprintf("task-%d", task.ID)
+		ts := TaskSLO{TaskID: task.ID, TaskName: task.Name, TaskStatus: task.Status}
+		if value, ok := s.instances.Load(instanceID); ok {
+			status := value.(canal.CanalInstance).GetStatus()
+			ts.Running = status.Running
+			ts.LagSeconds = s.effectiveLag(instanceID, status).Seconds()
+		}
+		if value, ok := s.webhooks.Load(instanceID); ok {
+			ts.Delivered, ts.Failed = value.(*canal.WebhookHandler).DeliveryCounts()
+		}
+		ts.Objectives = s.slo.Report(task.ID, now, s.sloSettings.targets)
+		report.Tasks = append(report.Tasks, ts)
+	}
+	return report, nil
+}
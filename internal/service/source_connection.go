@@ -0,0 +1,86 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"fmt"
+	"sync"
+
+	"pikachun/internal/config"
+)
+
+// sourceConnection 源库的地址和账号，可在运行时通过 API 轮换账号或重新读取配置文件。
+// s.config.Canal 中的连接信息启动后不再修改，读取当前的连接信息使用 sourceConfig
+type sourceConnection struct {
+	mu       sync.RWMutex
+	host     string
+	port     int
+	username string
+	password string
+
+	// 最近一次从配置文件读取的账号，重新读取时配置文件中的账号没有变化则保留 API 轮换的账号
+	fileUsername string
+	filePassword string
+}
+
+func newSourceConnection(cfg config.CanalConfig) *sourceConnection {
+	return &sourceConnection{
+		host:         cfg.Host,
+		port:         cfg.Port,
+		username:     cfg.Username,
+		password:     cfg.Password,
+		fileUsername: cfg.Username,
+		filePassword: cfg.Password,
+	}
+}
+
+// sourceConfig 返回源库配置的副本，连接信息为当前的地址和账号
+func (s *EnhancedCanalService) sourceConfig() config.CanalConfig {
+	cfg := s.config.Canal
+	s.source.mu.RLock()
+	cfg.Host, cfg.Port = s.source.host, s.source.port
+	cfg.Username, cfg.Password = s.source.username, s.source.password
+	s.source.mu.RUnlock()
+	return cfg
+}
+
+// instanceConfig 返回创建实例使用的配置副本，源库连接信息为当前的地址和账号
+func (s *EnhancedCanalService) instanceConfig() *config.Config {
+	cfg := *s.config
+	cfg.Canal = s.sourceConfig()
+	return &cfg
+}
+
+// setSourceCredentials 切换之后创建的连接使用的源库账号
+func (s *EnhancedCanalService) setSourceCredentials(username, password string) {
+	s.source.mu.Lock()
+	s.source.username, s.source.password = username, password
+	s.source.mu.Unlock()
+}
+
+// reloadSourceConnection 重新读取配置文件和环境变量中的源库地址和账号，之后创建的实例使用新的连接信息。
+// 配置中的账号与上次读取时相同时保留通过 API 轮换的账号，不会把轮换撤销
+func (s *EnhancedCanalService) reloadSourceConnection() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to reload config: %v", err)
+	}
+
+	s.source.mu.Lock()
+	s.source.host, s.source.port = cfg.Canal.Host, cfg.Canal.Port
+	fileChanged := cfg.Canal.Username != s.source.fileUsername || cfg.Canal.Password != s.source.filePassword
+	if fileChanged {
+		s.source.username, s.source.password = cfg.Canal.Username, cfg.Canal.Password
+		s.source.fileUsername, s.source.filePassword = cfg.Canal.Username, cfg.Canal.Password
+	}
+	username := s.source.username
+	s.source.mu.Unlock()
+
+	if !fileChanged && username != cfg.Canal.Username {
+		s.logger.Printf("🔑 Reloaded source address %s:%d, keeping credentials of user %s rotated through the API", cfg.Canal.Host, cfg.Canal.Port, username)
+		return nil
+	}
+	s.logger.Printf("🔑 Reloaded source connection settings: %s@%s:%d", username, cfg.Canal.Host, cfg.Canal.Port)
+	return nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"pikachun/internal/canal"
+	databaseCom "pikachun/internal/database"
+)
+
+// TableChangeRetention 表变更统计的保留时长，也是分析接口的最大时间窗口
+const TableChangeRetention = 7 * 24 * time.Hour
+
+// TableChangeSummary 一张表在时间窗口内的行变更数和变更频率
+type TableChangeSummary struct {
+	Database      string  `json:"database"`
+	Table         string  `json:"table"`
+	Inserts       int64   `json:"inserts"`
+	Updates       int64   `json:"updates"`
+	Deletes       int64   `json:"deletes"`
+	Total         int64   `json:"total"`
+	PerMinute     float64 `json:"per_minute"`      // 窗口内平均每分钟的变更数
+	PeakPerMinute float64 `json:"peak_per_minute"` // 变更最多的统计桶内每分钟的变更数
+}
+
+// TableChangePoint 一个统计桶内的行变更数
+type TableChangePoint struct {
+	Start   time.Time `json:"start" format:"date-time"`
+	Inserts int64     `json:"inserts"`
+	Updates int64     `json:"updates"`
+	Deletes int64     `json:"deletes"`
+}
+
+func (p TableChangePoint) total() int64 {
+	return p.Inserts + p.Updates + p.Deletes
+}
+
+// tableBucket 一张表的一个统计桶
+type tableBucket struct {
+	database string
+	table    string
+	start    time.Time
+}
+
+// loadTableChanges 读取时间窗口内的统计桶，taskID 为 0 表示所有任务，database 和 table 为空表示所有表。
+// 每个任务各自读取 binlog，同一张表被多个任务监听时各任务的计数相同，取变更最多的一个，避免重复计算
+func (s *TaskService) loadTableChanges(taskID uint, database, table string, since time.Time) (map[tableBucket]TableChangePoint, error) {
+	query := s.db.Model(&databaseCom.TableChangeStat{}).Where("start >= ?", since.Truncate(canal.TableChangeBucketWidth))
+	if taskID > 0 {
+		query = query.Where("task_id = ?", taskID)
+	}
+	if database != "" {
+		query = query.Where("`database` = ? AND `table` = ?", database, table)
+	}
+	var rows []databaseCom.TableChangeStat
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[tableBucket]TableChangePoint)
+	for _, row := range rows {
+		key := tableBucket{database: row.Database, table: row.Table, start: row.Start}
+		point := TableChangePoint{Start: row.Start, Inserts: row.Inserts, Updates: row.Updates, Deletes: row.Deletes}
+		if existing, ok := buckets[key]; !ok || point.total() > existing.total() {
+			buckets[key] = point
+		}
+	}
+	return buckets, nil
+}
+
+// HottestTables 时间窗口内变更最多的表，按变更数从多到少排列，最多返回 limit 张
+func (s *TaskService) HottestTables(taskID uint, window time.Duration, limit int) ([]TableChangeSummary, error) {
+	buckets, err := s.loadTableChanges(taskID, "", "", time.Now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+
+	bucketMinutes := canal.TableChangeBucketWidth.Minutes()
+	summaries := make(map[[2]string]*TableChangeSummary)
+	for key, point := range buckets {
+		summary := summaries[[2]string{key.database, key.table}]
+		if summary == nil {
+			summary = &TableChangeSummary{Database: key.database, Table: key.table}
+			summaries[[2]string{key.database, key.table}] = summary
+		}
+		summary.Inserts += point.Inserts
+		summary.Updates += point.Updates
+		summary.Deletes += point.Deletes
+		if peak := float64(point.total()) / bucketMinutes; peak > summary.PeakPerMinute {
+			summary.PeakPerMinute = peak
+		}
+	}
+
+	result := make([]TableChangeSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		summary.Total = summary.Inserts + summary.Updates + summary.Deletes
+		summary.PerMinute = float64(summary.Total) / window.Minutes()
+		result = append(result, *summary)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		if result[i].Database != result[j].Database {
+			return result[i].Database < result[j].Database
+		}
+		return result[i].Table < result[j].Table
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// TableChangeSeries 一张表在时间窗口内各统计桶的变更数，按时间顺序，没有变更的桶不返回
+func (s *TaskService) TableChangeSeries(taskID uint, database, table string, window time.Duration) ([]TableChangePoint, error) {
+	buckets, err := s.loadTableChanges(taskID, database, table, time.Now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+	points := make([]TableChangePoint, 0, len(buckets))
+	for _, point := range buckets {
+		points = append(points, point)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Start.Before(points[j].Start)
+	})
+	return points, nil
+}
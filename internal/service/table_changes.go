@@ -0,0 +1,47 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"pikachun/internal/database"
+)
+
+// persistTableChanges 把各表行变更数的增量累加到统计桶，删除超出保留时长的桶
+func (s *EnhancedCanalService) persistTableChanges(now time.Time) {
+	deltas := s.tableChanges.Flush()
+	if len(deltas) > 0 {
+		rows := make([]database.TableChangeStat, 0, len(deltas))
+		for key, counts := range deltas {
+			rows = append(rows, database.TableChangeStat{
+				TaskID:   key.TaskID,
+				Database: key.Schema,
+				Table:    key.Table,
+				Start:    key.Start,
+				Inserts:  counts.Inserts,
+				Updates:  counts.Updates,
+				Deletes:  counts.Deletes,
+			})
+		}
+		err := s.db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "task_id"}, {Name: "database"}, {Name: "table"}, {Name: "start"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"inserts": gorm.Expr("inserts + excluded.inserts"),
+				"updates": gorm.Expr("updates + excluded.updates"),
+				"deletes": gorm.Expr("deletes + excluded.deletes"),
+			}),
+		}).CreateInBatches(rows, 500).Error
+		if err != nil {
+			s.logger.Printf("❌ Failed to persist table change stats: %v", err)
+		}
+	}
+
+	if err := s.db.Where("start < ?", now.Add(-TableChangeRetention)).Delete(&database.TableChangeStat{}).Error; err != nil {
+		s.logger.Printf("❌ Failed to prune table change stats: %v", err)
+	}
+}
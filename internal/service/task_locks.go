@@ -0,0 +1,16 @@
+//go:build !test
+// +build !test
+
+package service
+
+import "sync"
+
+// lockTask 获取任务的生命周期锁，返回解锁函数。
+// 同一任务的创建、更新、停止、删除和重启串行执行，避免并发的 API 调用与启动时加载任务重复启动实例；
+// 不同任务互不阻塞。锁在任务删除后保留，避免删除与再次创建之间拿到不同的锁
+func (s *EnhancedCanalService) lockTask(taskID uint) func() {
+	value, _ := s.taskLocks.LoadOrStore(taskID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
@@ -0,0 +1,77 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/database"
+)
+
+// purgeDeletedTasks 彻底清除超过保留天数的已删除任务，并删除已清除任务的 binlog 位置
+func (s *EnhancedCanalService) purgeDeletedTasks(now time.Time) {
+	retention := time.Duration(s.config.TaskDeletion.RetentionDays) * 24 * time.Hour
+	ids, err := s.taskService.DeletedTasksBefore(now.Add(-retention))
+	if err != nil {
+		s.logger.Printf("❌ Failed to query deleted tasks: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if err := s.taskService.PurgeTask(id); err != nil {
+			s.logger.Printf("❌ Failed to purge deleted task %d: %v", id, err)
+			continue
+		}
+		s.DropTaskLogs(id)
+		s.logger.Printf("🗑️ Deleted task %d purged after the %d-day retention", id, s.config.TaskDeletion.RetentionDays)
+	}
+
+	// 任务记录已不存在（包括删除时立即清除的任务）的 binlog 位置
+	metaManager, ok := s.metaManager.(*canal.DBMetaManager)
+	if !ok {
+		return
+	}
+	var existing []uint
+	if err := s.db.Unscoped().Model(&database.Task{}).Pluck("id", &existing).Error; err != nil {
+		s.logger.Printf("❌ Failed to query tasks for position cleanup: %v", err)
+		return
+	}
+	known := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		known[fmt.Sprintf("task-%d", id)] = true
+	}
+	for instanceID := range metaManager.GetAllPositions() {
+		var taskID uint
+		if _, err := fmt.Sscanf(instanceID, "task-%d", &taskID); err != nil || known[instanceID] {
+			continue
+		}
+		if err := metaManager.DeletePosition(instanceID); err != nil {
+			s.logger.Printf("❌ Failed to delete binlog position of purged task %d: %v", taskID, err)
+		}
+	}
+}
+
+// pruneDeliveryCursors 删除超过保留时长的请求序号记录，已预留的序号上限保留，序号继续递增
+func (s *EnhancedCanalService) pruneDeliveryCursors(now time.Time) {
+	if !s.config.DatabaseStorage.Enabled || s.config.DatabaseStorage.CursorRetention == "" {
+		return
+	}
+	retention, err := time.ParseDuration(s.config.DatabaseStorage.CursorRetention)
+	if err != nil {
+		s.logger.Printf("⚠️ Invalid database_storage.cursor_retention %q: %v", s.config.DatabaseStorage.CursorRetention, err)
+		return
+	}
+	if retention <= 0 {
+		return
+	}
+	pruned, err := s.taskService.PruneDeliveryCursors(now.Add(-retention))
+	if err != nil {
+		s.logger.Printf("❌ Failed to prune delivery cursors: %v", err)
+		return
+	}
+	if pruned > 0 {
+		s.logger.Printf("🧹 Pruned %d delivery cursors older than %v", pruned, retention)
+	}
+}
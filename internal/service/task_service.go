@@ -1,26 +1,153 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"pikachun/internal/canal"
 	databaseCom "pikachun/internal/database"
 )
 
 // TaskService 任务服务
 type TaskService struct {
-	db *gorm.DB
+	db         *gorm.DB
+	ftsEnabled bool // 事件日志全文索引是否可用
+
+	numericStrings bool // 全局的数值安全编码，重新投递时任务未设置则使用
+
+	maxTasksPerSource int          // 任务数上限，见 SetMaxTasksPerSource
+	taskLimitRejected atomic.Int64 // 因任务数上限被拒绝的请求数
+
+	eventLogsWritten eventLogSignal // 写入事件日志时唤醒等待拉取的请求
 }
 
 // NewTaskService 创建任务服务实例
 func NewTaskService(db *gorm.DB) *TaskService {
-	return &TaskService{db: db}
+	return &TaskService{
+		db:         db,
+		ftsEnabled: db.Migrator().HasTable(databaseCom.EventLogFTSTable),
+	}
+}
+
+// SetNumericStrings 设置全局的数值安全编码，见 canal.TaskNumericStrings
+func (s *TaskService) SetNumericStrings(enabled bool) {
+	s.numericStrings = enabled
+}
+
+// SetMaxTasksPerSource 设置每个源库的任务数上限（canal.limits.max_tasks_per_source），0 表示不限制
+func (s *TaskService) SetMaxTasksPerSource(max int) {
+	s.maxTasksPerSource = max
+}
+
+// CountTasks 未删除的任务数
+func (s *TaskService) CountTasks() (int64, error) {
+	var count int64
+	err := s.db.Model(&databaseCom.Task{}).Count(&count).Error
+	return count, err
+}
+
+// TaskLimitRejections 因任务数上限被拒绝的请求数
+func (s *TaskService) TaskLimitRejections() int64 {
+	return s.taskLimitRejected.Load()
+}
+
+// checkTaskLimit 再增加 n 个任务后超过任务数上限时返回 LimitError。
+// 目前只有 canal 配置的一个源库，所有未删除的任务都属于该源库
+func (s *TaskService) checkTaskLimit(tx *gorm.DB, n int) error {
+	if s.maxTasksPerSource <= 0 {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&databaseCom.Task{}).Count(&count).Error; err != nil {
+		return err
+	}
+	err := checkLimit(LimitTasksPerSource, s.maxTasksPerSource, int(count), n)
+	if err != nil {
+		s.taskLimitRejected.Add(1)
+	}
+	return err
+}
+
+// EventLogFilter 事件日志查询条件
+type EventLogFilter struct {
+	TaskID    uint
+	Database  string
+	Table     string
+	EventType string
+	Status    string
+	Hash      string // 内容哈希，查找内容相同的事件
+	Since     *time.Time
+	Until     *time.Time
+	Search    string // 在事件数据中搜索
+
+	// 分页：Cursor 大于 0 时使用游标分页（返回 ID 小于 Cursor 的记录），否则按页码分页
+	Page     int
+	PageSize int
+	Cursor   uint
+}
+
+// EventLogPage 事件日志查询结果
+type EventLogPage struct {
+	Logs       []databaseCom.EventLog `json:"logs"`
+	Total      int64                  `json:"total"` // 游标分页时不统计总数，为 -1
+	NextCursor uint                   `json:"next_cursor"`
 }
 
 // CreateTask 创建任务
 func (s *TaskService) CreateTask(task *databaseCom.Task) error {
+	if err := s.validateTask(task); err != nil {
+		return invalid(err)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkTaskLimit(tx, 1); err != nil {
+			return err
+		}
+		return tx.Create(task).Error
+	})
+}
+
+// CreateTasks 在一个事务中批量创建任务，先逐个校验，任一任务无效时不创建任何任务。
+// 返回与 tasks 一一对应的校验错误，校验全部通过时为 nil
+func (s *TaskService) CreateTasks(tasks []*databaseCom.Task) ([]error, error) {
+	var errs []error
+	for i, task := range tasks {
+		if err := s.validateTask(task); err != nil {
+			if errs == nil {
+				errs = make([]error, len(tasks))
+			}
+			errs[i] = err
+		}
+	}
+	if errs != nil {
+		return errs, invalid(errors.New("部分任务校验失败，未创建任何任务"))
+	}
+
+	return nil, s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkTaskLimit(tx, len(tasks)); err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if err := tx.Create(task).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// validateTask 校验新任务的配置
+func (s *TaskService) validateTask(task *databaseCom.Task) error {
 	// 验证事件类型
 	if !s.validateEventTypes(task.EventTypes) {
 		return errors.New("无效的事件类型，支持: INSERT, UPDATE, DELETE")
@@ -31,7 +158,106 @@ func (s *TaskService) CreateTask(task *databaseCom.Task) error {
 		return errors.New("回调URL不能为空")
 	}
 
-	return s.db.Create(task).Error
+	// 验证投递超时
+	if err := validateDeliveryTimeouts(task); err != nil {
+		return err
+	}
+
+	// 验证维护窗口
+	if err := validateSchedule(task); err != nil {
+		return err
+	}
+
+	// 验证分区路由
+	if err := validatePartition(task); err != nil {
+		return err
+	}
+
+	// 验证请求体压缩
+	if err := validateCompression(task); err != nil {
+		return err
+	}
+
+	// 验证投递调度权重
+	if task.Priority < 0 {
+		return errors.New("优先级不能为负数")
+	}
+
+	// 验证事件采样
+	if err := validateSampling(task); err != nil {
+		return err
+	}
+
+	// 验证窗口合并
+	if err := validateCompactWindow(task); err != nil {
+		return err
+	}
+
+	// 验证 DELETE 事件投递方式
+	if _, err := canal.ParseDeleteMode(task.DeleteMode); err != nil {
+		return fmt.Errorf("无效的删除事件投递方式: %v", err)
+	}
+
+	// 验证载荷映射规则
+	if _, err := canal.ParsePayloadMapping(task.PayloadMapping); err != nil {
+		return fmt.Errorf("无效的载荷映射规则: %v", err)
+	}
+
+	// 验证行过滤条件
+	if _, err := canal.ParseRowFilter(task.RowFilter); err != nil {
+		return fmt.Errorf("无效的行过滤条件: %v", err)
+	}
+
+	// 验证来源标识
+	if _, err := canal.TaskSourceIdentity(task); err != nil {
+		return fmt.Errorf("无效的来源标识: %v", err)
+	}
+
+	// 验证延迟保护
+	if _, err := canal.NewLagGuard(task); err != nil {
+		return fmt.Errorf("无效的延迟保护配置: %v", err)
+	}
+
+	// 验证事件最大存活时间
+	if _, err := canal.NewEventExpiry(task); err != nil {
+		return fmt.Errorf("无效的事件存活时间配置: %v", err)
+	}
+
+	// 验证数据质量规则和隔离地址
+	if err := validateQuality(task); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateEventLogs 批量创建事件日志
+func (s *TaskService) CreateEventLogs(entries []canal.EventLogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	logs := make([]databaseCom.EventLog, 0, len(entries))
+	for _, entry := range entries {
+		logs = append(logs, databaseCom.EventLog{
+			TaskID:      entry.TaskID,
+			EventID:     entry.EventID,
+			Database:    entry.Database,
+			Table:       entry.Table,
+			EventType:   entry.EventType,
+			ContentHash: entry.ContentHash,
+			Data:        entry.Data,
+			BeforeData:  entry.BeforeData,
+			Status:      entry.Status,
+			Error:       entry.Error,
+		})
+	}
+
+	if err := s.db.CreateInBatches(logs, 500).Error; err != nil {
+		return err
+	}
+	s.eventLogsWritten.notify()
+	return nil
 }
 
 // GetTasks 获取任务列表
@@ -54,18 +280,8 @@ func (s *TaskService) GetTasks(page, pageSize int) ([]databaseCom.Task, int64, e
 }
 
 // CreateEventLog 创建事件日志
-func (s *TaskService) CreateEventLog(taskID uint, database, table, eventType, data, status, errorMsg string) error {
-	eventLog := &databaseCom.EventLog{
-		TaskID:    taskID,
-		Database:  database,
-		Table:     table,
-		EventType: eventType,
-		Data:      data,
-		Status:    status,
-		Error:     errorMsg,
-	}
-
-	return s.db.Create(eventLog).Error
+func (s *TaskService) CreateEventLog(entry canal.EventLogEntry) error {
+	return s.CreateEventLogs([]canal.EventLogEntry{entry})
 }
 
 // GetTask 根据ID获取任务
@@ -77,24 +293,446 @@ func (s *TaskService) GetTask(id uint) (*databaseCom.Task, error) {
 	return &task, nil
 }
 
-// UpdateTask 更新任务
-func (s *TaskService) UpdateTask(id uint, updates *databaseCom.Task) error {
+// GetTaskByIdempotencyKey 根据创建请求的幂等键获取任务
+func (s *TaskService) GetTaskByIdempotencyKey(key string) (*databaseCom.Task, error) {
+	var task databaseCom.Task
+	if err := s.db.Where("idempotency_key = ?", key).First(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// UpdateTask 更新任务，partitionCount 不为 nil 时同时更新分区数（按结构体更新会忽略 0，改回原始路由值需显式传入）。
+// 全部内容校验通过后在一个事务中写入；任务不存在时返回 gorm.ErrRecordNotFound，更新内容未通过校验时返回 ValidationError
+func (s *TaskService) UpdateTask(id uint, updates *databaseCom.Task, partitionCount *int) error {
+	current, err := s.GetTask(id)
+	if err != nil {
+		return err
+	}
+	if err := s.validateUpdate(current, updates, partitionCount); err != nil {
+		return invalid(err)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&databaseCom.Task{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return err
+		}
+		if partitionCount == nil {
+			return nil
+		}
+		return tx.Model(&databaseCom.Task{}).Where("id = ?", id).Update("partition_count", *partitionCount).Error
+	})
+}
+
+// validateUpdate 校验更新内容，updates 中只包含变更的字段，partitionCount 为显式传入的分区数
+func (s *TaskService) validateUpdate(current, updates *databaseCom.Task, partitionCount *int) error {
 	// 验证事件类型
 	if updates.EventTypes != "" && !s.validateEventTypes(updates.EventTypes) {
 		return errors.New("无效的事件类型，支持: INSERT, UPDATE, DELETE")
 	}
 
+	// 投递超时和维护窗口需与未修改的项一起校验
+	timeoutsChanged := updates.RequestTimeout != 0 || updates.DeliveryTimeout != 0 || updates.ShutdownTimeout != 0
+	scheduleChanged := updates.Schedule != "" || updates.ScheduleMode != "" || updates.ScheduleRate != 0
+	partitionChanged := updates.PartitionBy != "" || updates.PartitionColumn != "" || partitionCount != nil
+	if timeoutsChanged || scheduleChanged || partitionChanged {
+		merged := *current
+		if updates.RequestTimeout != 0 {
+			merged.RequestTimeout = updates.RequestTimeout
+		}
+		if updates.DeliveryTimeout != 0 {
+			merged.DeliveryTimeout = updates.DeliveryTimeout
+		}
+		if updates.ShutdownTimeout != 0 {
+			merged.ShutdownTimeout = updates.ShutdownTimeout
+		}
+		if updates.Schedule != "" {
+			merged.Schedule = updates.Schedule
+		}
+		if updates.ScheduleMode != "" {
+			merged.ScheduleMode = updates.ScheduleMode
+		}
+		if updates.ScheduleRate != 0 {
+			merged.ScheduleRate = updates.ScheduleRate
+		}
+		if updates.PartitionBy != "" {
+			merged.PartitionBy = updates.PartitionBy
+		}
+		if updates.PartitionColumn != "" {
+			merged.PartitionColumn = updates.PartitionColumn
+		}
+		if partitionCount != nil {
+			merged.PartitionCount = *partitionCount
+		}
+		if err := validateDeliveryTimeouts(&merged); err != nil {
+			return err
+		}
+		if err := validateSchedule(&merged); err != nil {
+			return err
+		}
+		if err := validatePartition(&merged); err != nil {
+			return err
+		}
+	}
+	if err := validateCompression(updates); err != nil {
+		return err
+	}
+	if updates.Priority < 0 {
+		return errors.New("优先级不能为负数")
+	}
+	if err := validateSampling(updates); err != nil {
+		return err
+	}
+	if err := validateCompactWindow(updates); err != nil {
+		return err
+	}
+	if _, err := canal.ParseDeleteMode(updates.DeleteMode); err != nil {
+		return fmt.Errorf("无效的删除事件投递方式: %v", err)
+	}
+	if _, err := canal.ParsePayloadMapping(updates.PayloadMapping); err != nil {
+		return fmt.Errorf("无效的载荷映射规则: %v", err)
+	}
+	if _, err := canal.ParseRowFilter(updates.RowFilter); err != nil {
+		return fmt.Errorf("无效的行过滤条件: %v", err)
+	}
+	if _, err := canal.TaskSourceIdentity(updates); err != nil {
+		return fmt.Errorf("无效的来源标识: %v", err)
+	}
+	if _, err := canal.NewLagGuard(updates); err != nil {
+		return fmt.Errorf("无效的延迟保护配置: %v", err)
+	}
+	if _, err := canal.NewEventExpiry(updates); err != nil {
+		return fmt.Errorf("无效的事件存活时间配置: %v", err)
+	}
+	return validateQuality(updates)
+}
+
+// SetTaskDryRun 开启或关闭任务的演练模式
+// UpdateTask 按结构体更新会忽略 false，关闭演练模式需单独更新
+func (s *TaskService) SetTaskDryRun(id uint, dryRun bool) error {
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Update("dry_run", dryRun).Error
+}
+
+// SetTaskSchedule 更新任务的维护窗口，非空的窗口已在 UpdateTask 中与模式和速率一起校验
+// UpdateTask 按结构体更新会忽略空字符串，清除维护窗口需单独更新
+func (s *TaskService) SetTaskSchedule(id uint, schedule string) error {
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Update("schedule", schedule).Error
+}
+
+// SetTaskSampling 更新任务的事件采样配置，nil 表示不修改
+// UpdateTask 按结构体更新会忽略 0，关闭采样需单独更新
+func (s *TaskService) SetTaskSampling(id uint, percent *float64, interval *int) error {
+	updates := map[string]interface{}{}
+	if percent != nil {
+		updates["sample_percent"] = *percent
+	}
+	if interval != nil {
+		updates["sample_interval"] = *interval
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// SetTaskCompactWindow 更新任务的合并窗口
+// UpdateTask 按结构体更新会忽略 0，关闭窗口合并需单独更新
+func (s *TaskService) SetTaskCompactWindow(id uint, window int) error {
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Update("compact_window", window).Error
+}
+
+// SetTaskPayloadMapping 更新任务的载荷映射规则
+// UpdateTask 按结构体更新会忽略空字符串，清除映射规则需单独更新
+func (s *TaskService) SetTaskPayloadMapping(id uint, mapping string) error {
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Update("payload_mapping", mapping).Error
+}
+
+// SetTaskRowFilter 更新任务的行过滤条件
+// UpdateTask 按结构体更新会忽略空字符串，清除过滤条件需单独更新
+func (s *TaskService) SetTaskRowFilter(id uint, filter string) error {
+	if _, err := canal.ParseRowFilter(filter); err != nil {
+		return invalid(fmt.Errorf("无效的行过滤条件: %v", err))
+	}
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Update("row_filter", filter).Error
+}
+
+// SetTaskSourceIdentity 更新任务的来源标识，nil 表示不修改
+// UpdateTask 按结构体更新会忽略空字符串，恢复默认值需单独更新
+func (s *TaskService) SetTaskSourceIdentity(id uint, name, environment, userAgent *string) error {
+	updates := map[string]interface{}{}
+	if name != nil {
+		updates["source_name"] = *name
+	}
+	if environment != nil {
+		updates["environment"] = *environment
+	}
+	if userAgent != nil {
+		updates["user_agent"] = *userAgent
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// SetTaskLagLimits 更新任务的延迟保护上限，nil 表示不修改
+// UpdateTask 按结构体更新会忽略 0，取消限制需单独更新
+func (s *TaskService) SetTaskLagLimits(id uint, maxSeconds, maxEvents *int) error {
+	updates := map[string]interface{}{}
+	if maxSeconds != nil {
+		updates["max_lag_seconds"] = *maxSeconds
+	}
+	if maxEvents != nil {
+		updates["max_lag_events"] = *maxEvents
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// SetTaskMaxEventAge 更新任务的事件最大存活时间
+// UpdateTask 按结构体更新会忽略 0，取消限制需单独更新
+func (s *TaskService) SetTaskMaxEventAge(id uint, seconds int) error {
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Update("max_event_age", seconds).Error
+}
+
+// SetTaskQuality 更新任务的数据质量规则和隔离地址，nil 表示不修改
+// UpdateTask 按结构体更新会忽略空字符串，清除规则或隔离地址需单独更新
+func (s *TaskService) SetTaskQuality(id uint, rules, quarantineURL *string) error {
+	updates := map[string]interface{}{}
+	if rules != nil {
+		updates["quality_rules"] = *rules
+	}
+	if quarantineURL != nil {
+		updates["quarantine_url"] = *quarantineURL
+	}
+	if len(updates) == 0 {
+		return nil
+	}
 	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Updates(updates).Error
 }
 
-// DeleteTask 删除任务
+// GetTaskContract 获取任务的消费端契约，未登记时返回 gorm.ErrRecordNotFound
+func (s *TaskService) GetTaskContract(taskID uint) (*databaseCom.TaskContract, error) {
+	var contract databaseCom.TaskContract
+	if err := s.db.Where("task_id = ?", taskID).First(&contract).Error; err != nil {
+		return nil, err
+	}
+	return &contract, nil
+}
+
+// SaveTaskContract 登记或替换任务的消费端契约，版本号加一
+func (s *TaskService) SaveTaskContract(taskID uint, definition string) (*databaseCom.TaskContract, error) {
+	parsed, err := canal.ParseConsumerContract(definition)
+	if err != nil {
+		return nil, invalid(fmt.Errorf("无效的消费端契约: %v", err))
+	}
+	if parsed == nil {
+		return nil, invalid(errors.New("契约不能为空"))
+	}
+	if _, err := s.GetTask(taskID); err != nil {
+		return nil, err
+	}
+
+	var contract databaseCom.TaskContract
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ?", taskID).First(&contract).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			contract = databaseCom.TaskContract{TaskID: taskID}
+		}
+		contract.Version++
+		contract.Definition = definition
+		return tx.Save(&contract).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &contract, nil
+}
+
+// DeleteTaskContract 删除任务的消费端契约
+func (s *TaskService) DeleteTaskContract(taskID uint) error {
+	return s.db.Where("task_id = ?", taskID).Delete(&databaseCom.TaskContract{}).Error
+}
+
+// LoadConsumerContract 加载任务的消费端契约，未登记时返回 nil
+func (s *TaskService) LoadConsumerContract(taskID uint) (*canal.ConsumerContract, error) {
+	contract, err := s.GetTaskContract(taskID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return canal.ParseConsumerContract(contract.Definition)
+}
+
+// RecordTaskError 记录任务最近一次错误
+func (s *TaskService) RecordTaskError(id uint, errMsg string, at time.Time) error {
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_error":    errMsg,
+		"last_error_at": at,
+	}).Error
+}
+
+// MarkTaskFailed 将任务标记为失败并记录原因
+func (s *TaskService) MarkTaskFailed(id uint, reason string) error {
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":        "failed",
+		"last_error":    reason,
+		"last_error_at": time.Now(),
+	}).Error
+}
+
+// MarkTaskPaused 将任务标记为 inactive 并记录原因，用于延迟保护自动暂停
+func (s *TaskService) MarkTaskPaused(id uint, reason string) error {
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":        "inactive",
+		"last_error":    reason,
+		"last_error_at": time.Now(),
+	}).Error
+}
+
+// MarkTaskPending 将任务标记为 pending 并记录启动失败的原因，由后台定时重试启动
+func (s *TaskService) MarkTaskPending(id uint, reason string) error {
+	return s.db.Model(&databaseCom.Task{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":        "pending",
+		"last_error":    reason,
+		"last_error_at": time.Now(),
+	}).Error
+}
+
+// RecordPendingTaskError 记录 pending 任务再次启动失败的原因，任务已被改为其他状态时不修改
+func (s *TaskService) RecordPendingTaskError(id uint, reason string) error {
+	return s.db.Model(&databaseCom.Task{}).Where("id = ? AND status = ?", id, "pending").Updates(map[string]interface{}{
+		"last_error":    reason,
+		"last_error_at": time.Now(),
+	}).Error
+}
+
+// ActivatePendingTask 实例启动成功后将 pending 任务标记为 active，任务已被改为其他状态时不修改
+func (s *TaskService) ActivatePendingTask(id uint) (bool, error) {
+	result := s.db.Model(&databaseCom.Task{}).Where("id = ? AND status = ?", id, "pending").Updates(map[string]interface{}{
+		"status":        "active",
+		"last_error":    "",
+		"last_error_at": nil,
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountFailedEventLogs 统计任务投递失败的事件数
+func (s *TaskService) CountFailedEventLogs(taskID uint) (int64, error) {
+	var count int64
+	err := s.db.Model(&databaseCom.EventLog{}).Where("task_id = ? AND status = ?", taskID, "failed").Count(&count).Error
+	return count, err
+}
+
+// RecordSkippedBinlogEvent 保存跳过的损坏 binlog 事件的审计记录
+func (s *TaskService) RecordSkippedBinlogEvent(record *databaseCom.SkippedBinlogEvent) error {
+	return s.db.Create(record).Error
+}
+
+// SkippedBinlogEvents 任务最近跳过的 limit 个损坏 binlog 事件（按时间先后排列）和跳过的总数，实例重启后仍然保留
+func (s *TaskService) SkippedBinlogEvents(taskID uint, limit int) ([]databaseCom.SkippedBinlogEvent, int64, error) {
+	var total int64
+	if err := s.db.Model(&databaseCom.SkippedBinlogEvent{}).Where("task_id = ?", taskID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var records []databaseCom.SkippedBinlogEvent
+	if err := s.db.Where("task_id = ?", taskID).Order("id DESC").Limit(limit).Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, total, nil
+}
+
+// DeleteTask 删除任务：只标记删除时间，binlog 位置、事件日志和失败事件保留到 PurgeTask 清除，期间可以用 RestoreTask 恢复。
+// 幂等键同时清除，删除后可以用同一个键创建新任务
 func (s *TaskService) DeleteTask(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&databaseCom.Task{}).Where("id = ?", id).Update("idempotency_key", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&databaseCom.Task{}, id).Error
+	})
+}
+
+// RestoreTask 恢复已删除、尚未清除的任务，任务恢复为 inactive，启用后从保存的 binlog 位置继续。
+// 任务不存在或未被删除时返回 gorm.ErrRecordNotFound
+func (s *TaskService) RestoreTask(id uint) (*databaseCom.Task, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 还原的任务重新计入任务数上限
+		if err := s.checkTaskLimit(tx, 1); err != nil {
+			return err
+		}
+		result := tx.Unscoped().Model(&databaseCom.Task{}).
+			Where("id = ? AND deleted_at IS NOT NULL", id).
+			Updates(map[string]interface{}{"deleted_at": nil, "status": "inactive"})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetTask(id)
+}
+
+// GetDeletedTasks 获取已删除、尚未清除的任务，最近删除的在前
+func (s *TaskService) GetDeletedTasks() ([]databaseCom.Task, error) {
+	var tasks []databaseCom.Task
+	err := s.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&tasks).Error
+	return tasks, err
+}
+
+// DeletedTasksBefore 删除时间早于 cutoff 的任务ID，用于到期清除
+func (s *TaskService) DeletedTasksBefore(cutoff time.Time) ([]uint, error) {
+	var ids []uint
+	err := s.db.Unscoped().Model(&databaseCom.Task{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// PurgeTask 彻底清除任务及其事件日志、投递历史等关联数据，不可恢复。
+// binlog 位置由 Canal 服务的清除任务删除，见 EnhancedCanalService.purgeDeletedTasks
+func (s *TaskService) PurgeTask(id uint) error {
 	// 物理删除任务，包括关联的事件日志
 	err := s.db.Transaction(func(tx *gorm.DB) error {
 		// 先删除关联的事件日志
 		if err := tx.Where("task_id = ?", id).Delete(&databaseCom.EventLog{}).Error; err != nil {
 			return err
 		}
+		if err := tx.Where("task_id = ?", id).Delete(&databaseCom.DeliveryAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ?", id).Delete(&databaseCom.DeliveryCursor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ?", id).Delete(&databaseCom.DeliverySequence{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ?", id).Delete(&databaseCom.TaskContract{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ?", id).Delete(&databaseCom.SLOBucket{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ?", id).Delete(&databaseCom.PullCursor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ?", id).Delete(&databaseCom.SkippedBinlogEvent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ?", id).Delete(&databaseCom.TableChangeStat{}).Error; err != nil {
+			return err
+		}
 		// 再物理删除任务
 		if err := tx.Unscoped().Delete(&databaseCom.Task{}, id).Error; err != nil {
 			return err
@@ -142,6 +780,346 @@ func (s *TaskService) GetEventLogs(taskID uint, page, pageSize int) ([]databaseC
 	return logs, total, nil
 }
 
+// SearchEventLogs 按条件查询事件日志，支持数据全文搜索和游标分页
+func (s *TaskService) SearchEventLogs(filter EventLogFilter) (*EventLogPage, error) {
+	if filter.PageSize <= 0 {
+		filter.PageSize = 20
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+
+	query := s.eventLogQuery(filter)
+
+	result := &EventLogPage{Total: -1}
+	if filter.Cursor > 0 {
+		query = query.Where("id < ?", filter.Cursor)
+	} else {
+		if err := query.Count(&result.Total).Error; err != nil {
+			return nil, err
+		}
+		query = query.Offset((filter.Page - 1) * filter.PageSize)
+	}
+
+	if err := query.Preload("Task").Order("id DESC").Limit(filter.PageSize).Find(&result.Logs).Error; err != nil {
+		return nil, err
+	}
+
+	if len(result.Logs) == filter.PageSize {
+		result.NextCursor = result.Logs[len(result.Logs)-1].ID
+	}
+	return result, nil
+}
+
+// eventLogQuery 根据查询条件构建事件日志查询，不含分页和排序
+func (s *TaskService) eventLogQuery(filter EventLogFilter) *gorm.DB {
+	query := s.db.Model(&databaseCom.EventLog{})
+	if filter.TaskID > 0 {
+		query = query.Where("task_id = ?", filter.TaskID)
+	}
+	if filter.Database != "" {
+		query = query.Where("`database` = ?", filter.Database)
+	}
+	if filter.Table != "" {
+		query = query.Where("`table` = ?", filter.Table)
+	}
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", strings.ToUpper(filter.EventType))
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Hash != "" {
+		query = query.Where("content_hash = ?", strings.ToLower(filter.Hash))
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+	if filter.Search != "" {
+		if s.ftsEnabled {
+			// 作为短语匹配，避免用户输入被解析为 FTS 语法
+			phrase := `"` + strings.ReplaceAll(filter.Search, `"`, `""`) + `"`
+			query = query.Where("id IN (SELECT rowid FROM event_logs_fts WHERE event_logs_fts MATCH ?)", phrase)
+		} else {
+			query = query.Where("data LIKE ? ESCAPE '\\'", "%"+escapeLike(filter.Search)+"%")
+		}
+	}
+
+	return query
+}
+
+// escapeLike 转义 LIKE 通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// RecordDeliveryAttempt 记录一次投递尝试，批次中的每个事件各记录一条
+func (s *TaskService) RecordDeliveryAttempt(attempt canal.DeliveryAttempt) error {
+	if len(attempt.EventIDs) == 0 {
+		return nil
+	}
+
+	// 逐事件确认时，被拒绝的事件记录各自的原因，已确认的事件没有错误
+	var nacked map[string]string
+	if len(attempt.Nacked) > 0 {
+		nacked = make(map[string]string, len(attempt.Nacked))
+		for _, nack := range attempt.Nacked {
+			nacked[nack.EventID] = "nacked by receiver: " + nack.Error
+		}
+	}
+
+	records := make([]databaseCom.DeliveryAttempt, 0, len(attempt.EventIDs))
+	for _, eventID := range attempt.EventIDs {
+		errMsg := attempt.Error
+		if nacked != nil {
+			errMsg = nacked[eventID]
+		}
+		records = append(records, databaseCom.DeliveryAttempt{
+			TaskID:       attempt.TaskID,
+			EventID:      eventID,
+			Attempt:      attempt.Attempt,
+			URL:          attempt.URL,
+			StatusCode:   attempt.StatusCode,
+			LatencyMs:    attempt.Latency.Milliseconds(),
+			ResponseBody: attempt.ResponseBody,
+			Error:        errMsg,
+			DryRun:       attempt.DryRun,
+			Payload:      attempt.Payload,
+			PartitionKey: attempt.PartitionKey,
+		})
+	}
+	return s.db.Create(&records).Error
+}
+
+// RecordFailedEvents 把重试后仍被接收方拒绝的事件在事件日志中记为 failed，进入失败事件列表。
+// 事件日志中没有该事件（未启用数据库存储）时插入一条，失败的事件仍可重新投递
+func (s *TaskService) RecordFailedEvents(entries []canal.EventLogEntry) error {
+	var missing []canal.EventLogEntry
+	for _, entry := range entries {
+		result := s.db.Model(&databaseCom.EventLog{}).
+			Where("task_id = ? AND event_id = ?", entry.TaskID, entry.EventID).
+			Updates(map[string]interface{}{"status": entry.Status, "error": entry.Error})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			missing = append(missing, entry)
+		}
+	}
+	return s.CreateEventLogs(missing)
+}
+
+// RecordExpiredEvents 记录超过最大存活时间、不再重试的事件：dlq 方式记为 failed 进入失败事件列表，
+// drop 方式记为 expired，不会出现在失败事件列表中
+func (s *TaskService) RecordExpiredEvents(taskID uint, eventIDs []string, action, reason string) error {
+	status := "failed"
+	if action == canal.ExpiredActionDrop {
+		status = "expired"
+	}
+	return s.db.Model(&databaseCom.EventLog{}).
+		Where("task_id = ? AND event_id IN ?", taskID, eventIDs).
+		Updates(map[string]interface{}{"status": status, "error": reason}).Error
+}
+
+// RecordDeliveryCursor 记录投递成功的请求序号与 binlog 位置的对应关系
+func (s *TaskService) RecordDeliveryCursor(cursor canal.DeliveryCursor) error {
+	return s.db.Create(&databaseCom.DeliveryCursor{
+		TaskID:       cursor.TaskID,
+		Sequence:     cursor.Sequence,
+		PartitionKey: cursor.PartitionKey,
+		FirstFile:    cursor.First.Name,
+		FirstPos:     cursor.First.Pos,
+		LastFile:     cursor.Last.Name,
+		LastPos:      cursor.Last.Pos,
+		EventCount:   cursor.EventCount,
+	}).Error
+}
+
+// ReserveDeliverySequence 持久化任务已预留的请求序号上限，只增不减
+func (s *TaskService) ReserveDeliverySequence(taskID uint, sequence uint64) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "task_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"reserved":   gorm.Expr("CASE WHEN excluded.reserved > reserved THEN excluded.reserved ELSE reserved END"),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+	}).Create(&databaseCom.DeliverySequence{TaskID: taskID, Reserved: sequence}).Error
+}
+
+// LastDeliverySequence 任务已分配的最大请求序号：预留的上限和已记录的最大序号中较大的一个，没有记录时返回 0
+func (s *TaskService) LastDeliverySequence(taskID uint) (uint64, error) {
+	var recorded, reserved uint64
+	err := s.db.Model(&databaseCom.DeliveryCursor{}).Where("task_id = ?", taskID).
+		Select("COALESCE(MAX(sequence), 0)").Scan(&recorded).Error
+	if err != nil {
+		return 0, err
+	}
+	err = s.db.Model(&databaseCom.DeliverySequence{}).Where("task_id = ?", taskID).
+		Select("COALESCE(MAX(reserved), 0)").Scan(&reserved).Error
+	if err != nil {
+		return 0, err
+	}
+	if reserved > recorded {
+		return reserved, nil
+	}
+	return recorded, nil
+}
+
+// PruneDeliveryCursors 删除早于 before 的请求序号记录，返回删除的行数
+func (s *TaskService) PruneDeliveryCursors(before time.Time) (int64, error) {
+	result := s.db.Where("created_at < ?", before).Delete(&databaseCom.DeliveryCursor{})
+	return result.RowsAffected, result.Error
+}
+
+// GetDeliveryCursorBySequence 查询序号对应的请求，该序号没有记录（如投递失败）时返回之前最近的一个
+func (s *TaskService) GetDeliveryCursorBySequence(taskID uint, sequence uint64) (*databaseCom.DeliveryCursor, error) {
+	var cursor databaseCom.DeliveryCursor
+	err := s.db.Where("task_id = ? AND sequence <= ?", taskID, sequence).
+		Order("sequence DESC").First(&cursor).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// GetDeliveryCursorByPosition 查询包含该位置事件的第一个请求，即最后一个事件不早于该位置的序号最小的请求。
+// 文件名按字符串比较，要求 binlog 文件名序号位数相同（MySQL 默认补齐 6 位）
+func (s *TaskService) GetDeliveryCursorByPosition(taskID uint, pos canal.Position) (*databaseCom.DeliveryCursor, error) {
+	var cursor databaseCom.DeliveryCursor
+	err := s.db.Where("task_id = ? AND (last_file > ? OR (last_file = ? AND last_pos >= ?))", taskID, pos.Name, pos.Name, pos.Pos).
+		Order("sequence ASC").First(&cursor).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// GetDeliveryAttempts 获取事件日志对应事件的投递历史，按时间顺序
+func (s *TaskService) GetDeliveryAttempts(log *databaseCom.EventLog) ([]databaseCom.DeliveryAttempt, error) {
+	var attempts []databaseCom.DeliveryAttempt
+	if log.EventID == "" {
+		return attempts, nil
+	}
+	err := s.db.Where("task_id = ? AND event_id = ?", log.TaskID, log.EventID).
+		Order("id ASC").Find(&attempts).Error
+	return attempts, err
+}
+
+// RedeliverEvent 将事件日志中保存的事件重新投递，callbackURL 为空时使用任务当前的回调地址
+// 投递成功后失败状态的日志会被标记为 success
+func (s *TaskService) RedeliverEvent(ctx context.Context, id uint, callbackURL string) (*canal.DeliveryAttempt, error) {
+	eventLog, err := s.GetEventLog(id)
+	if err != nil {
+		return nil, err
+	}
+	if callbackURL == "" {
+		callbackURL = eventLog.Task.CallbackURL
+	}
+	if callbackURL == "" {
+		return nil, fmt.Errorf("no callback URL for task %d", eventLog.TaskID)
+	}
+
+	event, err := eventFromLog(eventLog)
+	if err != nil {
+		return nil, err
+	}
+
+	// 违约的事件需要先修正契约或消费端，按当前契约校验后再投递
+	contract, err := s.LoadConsumerContract(eventLog.TaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := log.New(os.Stdout, "[Redeliver] ", log.LstdFlags|log.Lshortfile)
+	handler := canal.NewWebhookHandler(fmt.Sprintf("redeliver-%d", id), callbackURL, logger)
+	handler.SetDeliveryRecorder(eventLog.TaskID, s)
+	handler.SetNumericStrings(canal.TaskNumericStrings(&eventLog.Task, s.numericStrings))
+	handler.SetContract(contract)
+	if violations := handler.CheckContract(event); len(violations) > 0 {
+		return nil, errors.New(canal.ContractViolationError(violations))
+	}
+
+	// 投递次数接着已有的投递历史计数
+	var previous int64
+	if err := s.db.Model(&databaseCom.DeliveryAttempt{}).
+		Where("task_id = ? AND event_id = ?", eventLog.TaskID, event.ID).Count(&previous).Error; err != nil {
+		return nil, err
+	}
+
+	// 沿用首次投递的分区键，重投的事件发往同一个分片
+	var first databaseCom.DeliveryAttempt
+	if previous > 0 {
+		if err := s.db.Where("task_id = ? AND event_id = ?", eventLog.TaskID, event.ID).
+			Order("id ASC").First(&first).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	attempt, err := handler.DeliverPartition(ctx, first.PartitionKey, []*canal.Event{event}, int(previous)+1)
+	if err != nil {
+		return &attempt, err
+	}
+
+	if eventLog.Status == "failed" {
+		if err := s.db.Model(eventLog).Updates(map[string]interface{}{"status": "success", "error": ""}).Error; err != nil {
+			return &attempt, err
+		}
+	}
+	return &attempt, nil
+}
+
+// eventFromLog 根据事件日志还原事件
+func eventFromLog(eventLog *databaseCom.EventLog) (*canal.Event, error) {
+	event := &canal.Event{
+		ID:        eventLog.EventID,
+		Schema:    eventLog.Database,
+		Table:     eventLog.Table,
+		EventType: canal.EventType(eventLog.EventType),
+		Timestamp: eventLog.CreatedAt,
+		// 沿用首次投递时的内容哈希，消费端据此识别重新投递的事件；日志中没有主键列名，无法按还原的数据重新计算
+		ContentHash: eventLog.ContentHash,
+	}
+	if event.ID == "" {
+		event.ID = fmt.Sprintf("event-log-%d", eventLog.ID)
+	}
+
+	if eventLog.Data != "" {
+		rowData, err := decodeLogRowData(eventLog.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event data: %v", err)
+		}
+		if event.EventType == canal.EventTypeDelete {
+			event.BeforeData = rowData
+		} else {
+			event.AfterData = rowData
+		}
+	}
+	// UPDATE 事件修改前的数据，早期的日志没有保存时只还原修改后的数据
+	if eventLog.BeforeData != "" && event.EventType != canal.EventTypeDelete {
+		rowData, err := decodeLogRowData(eventLog.BeforeData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event before data: %v", err)
+		}
+		event.BeforeData = rowData
+	}
+
+	return event, nil
+}
+
+// decodeLogRowData 解析事件日志中的行数据，数值保留为 json.Number，BIGINT 等大整数重新投递时不会因转为 float64 丢失精度
+func decodeLogRowData(data string) (*canal.RowData, error) {
+	var rowData canal.RowData
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&rowData); err != nil {
+		return nil, err
+	}
+	return &rowData, nil
+}
+
 // GetEventLog 获取单个事件日志
 func (s *TaskService) GetEventLog(id uint) (*databaseCom.EventLog, error) {
 	var log databaseCom.EventLog
@@ -168,3 +1146,68 @@ func (s *TaskService) validateEventTypes(eventTypes string) bool {
 	}
 	return true
 }
+
+// validateDeliveryTimeouts 验证任务的投递超时配置
+func validateDeliveryTimeouts(task *databaseCom.Task) error {
+	if task.RequestTimeout < 0 || task.DeliveryTimeout < 0 || task.ShutdownTimeout < 0 {
+		return errors.New("投递超时不能为负数")
+	}
+	if err := canal.TaskDeliveryTimeouts(task).Validate(); err != nil {
+		return fmt.Errorf("无效的投递超时配置: %v", err)
+	}
+	return nil
+}
+
+// validateSchedule 验证任务的维护窗口配置
+func validateSchedule(task *databaseCom.Task) error {
+	if _, err := canal.ParseDeliverySchedule(task.Schedule, task.ScheduleMode, task.ScheduleRate); err != nil {
+		return fmt.Errorf("无效的维护窗口配置: %v", err)
+	}
+	return nil
+}
+
+// validateCompression 验证任务的请求体压缩配置
+func validateCompression(task *databaseCom.Task) error {
+	if _, err := canal.NewPayloadCompressor(task.Compression, task.CompressMinSize); err != nil {
+		return fmt.Errorf("无效的压缩配置: %v", err)
+	}
+	return nil
+}
+
+// validateSampling 验证任务的事件采样配置
+func validateSampling(task *databaseCom.Task) error {
+	if _, err := canal.NewEventSampler(task.SamplePercent, task.SampleInterval); err != nil {
+		return fmt.Errorf("无效的采样配置: %v", err)
+	}
+	return nil
+}
+
+// validateCompactWindow 验证任务的窗口合并配置
+func validateCompactWindow(task *databaseCom.Task) error {
+	if _, err := canal.NewEventCompactor(task.CompactWindow); err != nil {
+		return fmt.Errorf("无效的合并窗口: %v", err)
+	}
+	return nil
+}
+
+// validateQuality 验证任务的数据质量规则和隔离地址
+func validateQuality(task *databaseCom.Task) error {
+	if _, err := canal.ParseQualityRules(task.QualityRules); err != nil {
+		return fmt.Errorf("无效的数据质量规则: %v", err)
+	}
+	if task.QuarantineURL != "" {
+		parsed, err := url.Parse(task.QuarantineURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("无效的隔离地址: %s", task.QuarantineURL)
+		}
+	}
+	return nil
+}
+
+// validatePartition 验证任务的分区路由配置
+func validatePartition(task *databaseCom.Task) error {
+	if _, err := canal.NewPartitionRouter(task.PartitionBy, task.PartitionColumn, task.PartitionCount); err != nil {
+		return fmt.Errorf("无效的分区路由配置: %v", err)
+	}
+	return nil
+}
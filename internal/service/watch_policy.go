@@ -0,0 +1,106 @@
+//go:build !test
+// +build !test
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/database"
+)
+
+// loadWatchPolicy 加载持久化的全局监听策略。首次启动时由配置文件的 canal.watch 生成并保存，
+// 之后 canal.watch 不再生效，通过 API 修改
+func (s *EnhancedCanalService) loadWatchPolicy() error {
+	fromConfig, err := canal.WatchPolicyFromConfig(s.config.Canal.Watch).Normalize()
+	if err != nil {
+		return fmt.Errorf("invalid canal.watch config: %v", err)
+	}
+
+	var row database.WatchPolicy
+	err = s.db.First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := s.saveWatchPolicy(fromConfig); err != nil {
+			return err
+		}
+		s.watchPolicy = fromConfig
+		s.logger.Printf("🔧 Global watch policy initialized from canal.watch (deprecated, use PUT /api/v1/watch from now on)")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load watch policy: %v", err)
+	}
+
+	s.watchPolicy = canal.WatchPolicy{
+		Tables:        splitPolicyList(row.Tables),
+		EventTypes:    splitPolicyList(row.EventTypes),
+		ExcludeTables: splitPolicyList(row.ExcludeTables),
+	}
+	if !reflect.DeepEqual(fromConfig, s.watchPolicy) {
+		s.logger.Printf("⚠️ canal.watch in the config file differs from the stored global watch policy and is ignored; canal.watch is deprecated, use /api/v1/watch")
+	}
+	return nil
+}
+
+// splitPolicyList 拆分逗号分隔的列表，空字符串返回空列表
+func splitPolicyList(value string) []string {
+	items := canal.SplitList(value)
+	if items == nil {
+		items = []string{}
+	}
+	return items
+}
+
+// saveWatchPolicy 保存全局监听策略
+func (s *EnhancedCanalService) saveWatchPolicy(policy canal.WatchPolicy) error {
+	row := database.WatchPolicy{
+		ID:            1,
+		Tables:        strings.Join(policy.Tables, ","),
+		EventTypes:    strings.Join(policy.EventTypes, ","),
+		ExcludeTables: strings.Join(policy.ExcludeTables, ","),
+	}
+	if err := s.db.Save(&row).Error; err != nil {
+		return fmt.Errorf("failed to save watch policy: %v", err)
+	}
+	return nil
+}
+
+// WatchPolicy 当前的全局监听策略
+func (s *EnhancedCanalService) WatchPolicy() canal.WatchPolicy {
+	s.watchMu.RLock()
+	defer s.watchMu.RUnlock()
+	return s.watchPolicy
+}
+
+// SetWatchPolicy 校验并保存全局监听策略，推送到所有运行中的实例，无需重启即时生效
+func (s *EnhancedCanalService) SetWatchPolicy(policy canal.WatchPolicy) (canal.WatchPolicy, error) {
+	policy, err := policy.Normalize()
+	if err != nil {
+		return canal.WatchPolicy{}, err
+	}
+
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	if err := s.saveWatchPolicy(policy); err != nil {
+		return canal.WatchPolicy{}, err
+	}
+	s.watchPolicy = policy
+
+	applied := 0
+	s.instances.Range(func(key, value interface{}) bool {
+		if instance, ok := value.(*canal.MySQLCanalInstance); ok {
+			instance.SetWatchPolicy(policy)
+			applied++
+		}
+		return true
+	})
+	s.logger.Printf("🔧 Global watch policy updated and applied to %d instances", applied)
+	return policy, nil
+}
@@ -0,0 +1,285 @@
+// Package slo 按任务统计 SLO：可用性、延迟达标率和投递成功率，
+// 计算统计窗口内的达标率、剩余错误预算和多个时间窗口的预算消耗速率（burn rate）
+package slo
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SLO 名称
+const (
+	Availability = "availability" // 实例运行且没有告警的时间占比
+	Freshness    = "freshness"    // 复制延迟不超过阈值的时间占比
+	Delivery     = "delivery"     // 投递成功的事件占比
+)
+
+// Names 所有 SLO 名称，按导出顺序
+var Names = []string{Availability, Freshness, Delivery}
+
+// BucketWidth 统计桶的宽度，也是最短的 burn rate 窗口
+const BucketWidth = 5 * time.Minute
+
+// BurnRateWindow 计算 burn rate 的时间窗口
+type BurnRateWindow struct {
+	Name     string
+	Duration time.Duration
+}
+
+// BurnRateWindows 多窗口 burn rate 告警常用的窗口：
+// 1h 与 5m 同时超过 14.4、6h 与 30m 同时超过 6 时告警（紧急），3d 与 6h 同时超过 1 时提醒
+var BurnRateWindows = []BurnRateWindow{
+	{"5m", 5 * time.Minute},
+	{"30m", 30 * time.Minute},
+	{"1h", time.Hour},
+	{"6h", 6 * time.Hour},
+	{"3d", 72 * time.Hour},
+}
+
+// FormatWindow 以 Prometheus 习惯的单位显示窗口时长，如 28d、6h、30m
+func FormatWindow(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d >= time.Minute && d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
+
+// Targets 各 SLO 的目标达标率，如 0.999
+type Targets map[string]float64
+
+// Sample 一次采样：距上次采样的时长内任务的状态，以及期间投递成功和失败的事件数
+type Sample struct {
+	Elapsed   time.Duration
+	Observed  bool // 计入可用性和延迟；维护窗口等计划内暂停期间为 false
+	Up        bool
+	Fresh     bool
+	Delivered int64
+	Failed    int64
+}
+
+// Bucket 一个任务在一个统计桶内的累计量
+type Bucket struct {
+	Start     time.Time
+	Observed  float64 // 计入统计的秒数
+	Up        float64 // 其中实例运行且没有告警的秒数
+	Fresh     float64 // 其中延迟不超过阈值的秒数
+	Delivered int64
+	Failed    int64
+}
+
+// counts 桶内某个 SLO 的达标量和总量
+func (b *Bucket) counts(name string) (good, total float64) {
+	switch name {
+	case Availability:
+		return b.Up, b.Observed
+	case Freshness:
+		return b.Fresh, b.Observed
+	case Delivery:
+		return float64(b.Delivered), float64(b.Delivered + b.Failed)
+	}
+	return 0, 0
+}
+
+// Objective 一个 SLO 的统计结果
+type Objective struct {
+	Name   string  `json:"name"`
+	Target float64 `json:"target"`
+	// 统计窗口内的达标率和剩余错误预算（1 为未消耗，小于 0 为超支），HasData 为 false 时没有数据
+	HasData         bool    `json:"has_data"`
+	Ratio           float64 `json:"ratio"`
+	BudgetRemaining float64 `json:"budget_remaining"`
+	// 各窗口的 burn rate：窗口内的错误率除以允许的错误率，1 表示恰好在统计窗口结束时耗尽预算；没有数据的窗口不出现
+	BurnRates map[string]float64 `json:"burn_rates"`
+}
+
+// Tracker 按任务保存统计桶，只保留统计窗口（至少为最长的 burn rate 窗口）内的数据
+type Tracker struct {
+	mu     sync.Mutex
+	window time.Duration
+	tasks  map[uint]*series
+}
+
+// series 一个任务的统计桶，按开始时间排序
+type series struct {
+	buckets []*Bucket
+	dirty   map[*Bucket]bool // 上次 Flush 之后变化的桶
+}
+
+// NewTracker 创建统计器，window 为计算达标率和错误预算的窗口
+func NewTracker(window time.Duration) *Tracker {
+	return &Tracker{window: window, tasks: make(map[uint]*series)}
+}
+
+// Window 统计窗口
+func (t *Tracker) Window() time.Duration {
+	return t.window
+}
+
+// Retention 保留数据的时长：统计窗口和最长的 burn rate 窗口中较长的一个
+func (t *Tracker) Retention() time.Duration {
+	longest := BurnRateWindows[len(BurnRateWindows)-1].Duration
+	if t.window > longest {
+		return t.window
+	}
+	return longest
+}
+
+// Load 载入持久化的统计桶，用于进程重启后继续统计
+func (t *Tracker) Load(taskID uint, buckets []Bucket) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.seriesLocked(taskID)
+	for i := range buckets {
+		b := buckets[i]
+		b.Start = b.Start.Truncate(BucketWidth)
+		*s.bucketLocked(b.Start) = b
+	}
+}
+
+// Record 记录任务在 at 时刻的一次采样
+func (t *Tracker) Record(taskID uint, at time.Time, sample Sample) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.seriesLocked(taskID)
+	b := s.bucketLocked(at.Truncate(BucketWidth))
+	if sample.Observed && sample.Elapsed > 0 {
+		seconds := sample.Elapsed.Seconds()
+		b.Observed += seconds
+		if sample.Up {
+			b.Up += seconds
+		}
+		if sample.Fresh {
+			b.Fresh += seconds
+		}
+	}
+	b.Delivered += sample.Delivered
+	b.Failed += sample.Failed
+	s.dirty[b] = true
+
+	// 丢弃超出保留时长的桶
+	cutoff := at.Add(-t.Retention())
+	drop := 0
+	for drop < len(s.buckets) && s.buckets[drop].Start.Before(cutoff) {
+		delete(s.dirty, s.buckets[drop])
+		drop++
+	}
+	s.buckets = s.buckets[drop:]
+}
+
+// Forget 删除任务的统计数据
+func (t *Tracker) Forget(taskID uint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.tasks, taskID)
+}
+
+// Tasks 有统计数据的任务
+func (t *Tracker) Tasks() []uint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ids := make([]uint, 0, len(t.tasks))
+	for taskID := range t.tasks {
+		ids = append(ids, taskID)
+	}
+	return ids
+}
+
+// Flush 返回上次调用之后变化的桶，用于持久化
+func (t *Tracker) Flush() map[uint][]Bucket {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	changed := make(map[uint][]Bucket)
+	for taskID, s := range t.tasks {
+		for b := range s.dirty {
+			changed[taskID] = append(changed[taskID], *b)
+		}
+		s.dirty = make(map[*Bucket]bool)
+	}
+	return changed
+}
+
+// Report 计算任务在 now 时刻各 SLO 的统计结果，顺序与 Names 一致
+func (t *Tracker) Report(taskID uint, now time.Time, targets Targets) []Objective {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var buckets []*Bucket
+	if s, ok := t.tasks[taskID]; ok {
+		buckets = s.buckets
+	}
+
+	objectives := make([]Objective, 0, len(Names))
+	for _, name := range Names {
+		target := targets[name]
+		o := Objective{Name: name, Target: target, BurnRates: make(map[string]float64)}
+
+		if good, total := sum(buckets, name, now.Add(-t.window)); total > 0 {
+			o.HasData = true
+			o.Ratio = good / total
+			o.BudgetRemaining = 1 - burnRate(o.Ratio, target)
+		}
+		for _, w := range BurnRateWindows {
+			// 最近的桶尚未结束，窗口按桶对齐，包含最近的 w/BucketWidth 个桶
+			from := now.Truncate(BucketWidth).Add(-w.Duration + BucketWidth)
+			if good, total := sum(buckets, name, from); total > 0 {
+				o.BurnRates[w.Name] = burnRate(good/total, target)
+			}
+		}
+		objectives = append(objectives, o)
+	}
+	return objectives
+}
+
+// sum 汇总开始时间不早于 from 的桶
+func sum(buckets []*Bucket, name string, from time.Time) (good, total float64) {
+	i := sort.Search(len(buckets), func(i int) bool { return !buckets[i].Start.Before(from) })
+	for _, b := range buckets[i:] {
+		g, t := b.counts(name)
+		good += g
+		total += t
+	}
+	return good, total
+}
+
+// burnRate 错误率与允许的错误率之比，target 需在 0 和 1 之间，见 ValidTarget
+func burnRate(ratio, target float64) float64 {
+	return (1 - ratio) / (1 - target)
+}
+
+// ValidTarget 目标达标率需大于 0 且小于 1，为 1 时没有错误预算
+func ValidTarget(target float64) bool {
+	return target > 0 && target < 1
+}
+
+func (t *Tracker) seriesLocked(taskID uint) *series {
+	s, ok := t.tasks[taskID]
+	if !ok {
+		s = &series{dirty: make(map[*Bucket]bool)}
+		t.tasks[taskID] = s
+	}
+	return s
+}
+
+// bucketLocked 开始时间为 start 的桶，不存在时按顺序插入
+func (s *series) bucketLocked(start time.Time) *Bucket {
+	i := sort.Search(len(s.buckets), func(i int) bool { return !s.buckets[i].Start.Before(start) })
+	if i < len(s.buckets) && s.buckets[i].Start.Equal(start) {
+		return s.buckets[i]
+	}
+	b := &Bucket{Start: start}
+	s.buckets = append(s.buckets, nil)
+	copy(s.buckets[i+1:], s.buckets[i:])
+	s.buckets[i] = b
+	return b
+}
@@ -0,0 +1,111 @@
+package slo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var testTargets = Targets{Availability: 0.99, Freshness: 0.9, Delivery: 0.999}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func objective(objectives []Objective, name string) Objective {
+	for _, o := range objectives {
+		if o.Name == name {
+			return o
+		}
+	}
+	return Objective{}
+}
+
+// TestTrackerRatiosAndBudget 测试统计窗口内的达标率和剩余错误预算
+func TestTrackerRatiosAndBudget(t *testing.T) {
+	tracker := NewTracker(24 * time.Hour)
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	// 10 小时内每 30 秒采样一次，最后 6 分钟（12 次）实例停止
+	var now time.Time
+	for i := 0; i < 1200; i++ {
+		now = start.Add(time.Duration(i) * 30 * time.Second)
+		up := i < 1188
+		tracker.Record(1, now, Sample{Elapsed: 30 * time.Second, Observed: true, Up: up, Fresh: up, Delivered: 10})
+	}
+	tracker.Record(1, now, Sample{Failed: 12})
+
+	report := tracker.Report(1, now, testTargets)
+	availability := objective(report, Availability)
+	if !availability.HasData || !approx(availability.Ratio, 0.99) {
+		t.Fatalf("unexpected availability %+v", availability)
+	}
+	// 错误率 1% 恰好等于允许的错误率，预算耗尽
+	if !approx(availability.BudgetRemaining, 0) {
+		t.Errorf("expected exhausted budget, got %v", availability.BudgetRemaining)
+	}
+
+	delivery := objective(report, Delivery)
+	if !approx(delivery.Ratio, 12000.0/12012.0) {
+		t.Errorf("unexpected delivery ratio %v", delivery.Ratio)
+	}
+
+	// 最近 5 分钟的桶全部不可用：burn rate 为 1/0.01
+	if got := availability.BurnRates["5m"]; !approx(got, 100) {
+		t.Errorf("unexpected 5m burn rate %v", got)
+	}
+	// 最近 1 小时 12 个桶中 6 分钟不可用
+	if got := availability.BurnRates["1h"]; !approx(got, 0.1/0.01) {
+		t.Errorf("unexpected 1h burn rate %v", got)
+	}
+	// 延迟目标 0.9，10 小时内错误率 1%
+	if got := objective(report, Freshness).BudgetRemaining; !approx(got, 0.9) {
+		t.Errorf("unexpected freshness budget %v", got)
+	}
+}
+
+// TestTrackerUnobservedAndEmpty 测试计划内暂停不计入可用性，没有数据时不报告达标率和 burn rate
+func TestTrackerUnobservedAndEmpty(t *testing.T) {
+	tracker := NewTracker(24 * time.Hour)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker.Record(1, now, Sample{Elapsed: time.Minute, Observed: false, Up: false})
+
+	report := tracker.Report(1, now, testTargets)
+	for _, o := range report {
+		if o.HasData || len(o.BurnRates) != 0 {
+			t.Errorf("%s: expected no data, got %+v", o.Name, o)
+		}
+	}
+	if report := tracker.Report(2, now, testTargets); len(report) != len(Names) || report[0].HasData {
+		t.Errorf("unexpected report for an unknown task %+v", report)
+	}
+}
+
+// TestTrackerRetentionAndFlush 测试丢弃过期的桶，以及 Flush 只返回变化的桶，Load 后继续累计
+func TestTrackerRetentionAndFlush(t *testing.T) {
+	tracker := NewTracker(time.Hour)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker.Record(1, now, Sample{Elapsed: time.Minute, Observed: true, Up: true})
+
+	changed := tracker.Flush()
+	if len(changed[1]) != 1 || changed[1][0].Up != 60 {
+		t.Fatalf("unexpected flushed buckets %+v", changed)
+	}
+	if changed := tracker.Flush(); len(changed) != 0 {
+		t.Errorf("expected nothing to flush, got %+v", changed)
+	}
+
+	// 保留时长为最长的 burn rate 窗口（3d），之后旧桶被丢弃
+	later := now.Add(73 * time.Hour)
+	tracker.Record(1, later, Sample{Elapsed: time.Minute, Observed: true, Up: false})
+	if report := tracker.Report(1, later, testTargets); objective(report, Availability).Ratio != 0 {
+		t.Errorf("expected old buckets to be dropped, got %+v", objective(report, Availability))
+	}
+
+	restored := NewTracker(time.Hour)
+	restored.Load(1, []Bucket{{Start: now.Add(2 * time.Minute), Observed: 60, Up: 60}})
+	restored.Record(1, now.Add(time.Minute), Sample{Elapsed: time.Minute, Observed: true, Up: false})
+	if got := objective(restored.Report(1, now.Add(time.Minute), testTargets), Availability).Ratio; !approx(got, 0.5) {
+		t.Errorf("expected loaded bucket to be merged, ratio %v", got)
+	}
+}
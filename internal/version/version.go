@@ -0,0 +1,59 @@
+// Package version 构建信息，发布构建时通过 -ldflags 注入，例如：
+//
+//	go build -ldflags "-X pikachun/internal/version.Version=v1.2.0 \
+//	  -X pikachun/internal/version.GitCommit=$(git rev-parse HEAD) \
+//	  -X pikachun/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" .
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// 由 -ldflags -X 注入，未注入时 GitCommit 和 BuildTime 取自 Go 工具链记录的 VCS 信息
+var (
+	Version   = "dev"
+	GitCommit = ""
+	BuildTime = ""
+)
+
+// Info 构建信息
+type Info struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+	Modified  bool   `json:"modified,omitempty"` // 构建时工作区有未提交的修改
+} // @name BuildInfo
+
+// Get 获取当前二进制的构建信息
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.GitCommit == "" {
+				info.GitCommit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
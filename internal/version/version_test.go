@@ -0,0 +1,21 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+)
+
+// TestGetUsesInjectedValues 测试 -ldflags 注入的值优先于 VCS 信息
+func TestGetUsesInjectedValues(t *testing.T) {
+	oldVersion, oldCommit, oldTime := Version, GitCommit, BuildTime
+	defer func() { Version, GitCommit, BuildTime = oldVersion, oldCommit, oldTime }()
+
+	Version, GitCommit, BuildTime = "v1.2.3", "abc123", "2025-01-02T03:04:05Z"
+	info := Get()
+	if info.Version != "v1.2.3" || info.GitCommit != "abc123" || info.BuildTime != "2025-01-02T03:04:05Z" {
+		t.Errorf("unexpected build info %+v", info)
+	}
+	if info.GoVersion != runtime.Version() || info.Platform != runtime.GOOS+"/"+runtime.GOARCH {
+		t.Errorf("unexpected runtime info %+v", info)
+	}
+}
@@ -9,12 +9,19 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	gormlogger "gorm.io/gorm/logger"
+
+	"pikachun/internal/auth"
 	"pikachun/internal/config"
 	"pikachun/internal/database"
 	"pikachun/internal/server"
@@ -24,6 +31,21 @@ import (
 func main() {
 	// 设置日志格式
 	log.SetFlags(log.LstdFlags | log.Lshortfile | log.Lmicroseconds)
+
+	// 子命令
+	if len(os.Args) > 1 && os.Args[1] == "export-logs" {
+		if err := runExportLogs(os.Args[2:]); err != nil {
+			log.Fatalf("❌ Export failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		if err := runHashPassword(os.Stdin); err != nil {
+			log.Fatalf("❌ Failed to hash password: %v", err)
+		}
+		return
+	}
+
 	log.Println("🔧 Starting Pikachun Enhanced with Canal Architecture...")
 
 	// 加载配置
@@ -36,7 +58,7 @@ func main() {
 
 	// 初始化数据库
 	log.Println("🔧 Initializing database...")
-	db, err := database.Init(cfg.Database.DSN)
+	db, err := database.Init(cfg.Database)
 	if err != nil {
 		log.Fatalf("❌ Failed to initialize database: %v", err)
 	}
@@ -49,6 +71,8 @@ func main() {
 	// 初始化任务服务
 	log.Println("🔧 Initializing task service...")
 	taskService := service.NewTaskService(db)
+	taskService.SetNumericStrings(cfg.Canal.NumericStrings)
+	taskService.SetMaxTasksPerSource(cfg.Canal.Limits.MaxTasksPerSource)
 	log.Printf("✅ Task service initialized successfully")
 
 	// 初始化增强的Canal服务
@@ -93,6 +117,14 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
 
+	// 先停止Web服务器，等待进行中的API请求完成，避免请求操作已停止的Canal服务
+	log.Println("🔧 Stopping Web server...")
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("❌ Error stopping Web server: %v", err)
+	} else {
+		log.Println("✅ Web server stopped")
+	}
+
 	// 停止Canal服务
 	log.Println("🔧 Stopping Canal service...")
 	if err := enhancedCanalService.Stop(); err != nil {
@@ -143,6 +175,11 @@ func (s *EnhancedServer) Start() error {
 	return s.server.Start()
 }
 
+// Shutdown 优雅关闭增强的服务器
+func (s *EnhancedServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
 // CanalServiceAdapter Canal服务适配器
 type CanalServiceAdapter struct {
 	enhanced *service.EnhancedCanalService
@@ -184,3 +221,100 @@ func (a *CanalServiceAdapter) StopInstance(instanceID uint) error {
 func (a *CanalServiceAdapter) UpdateInstance(instanceID uint, task *database.Task) error {
 	return a.enhanced.UpdateInstance(instanceID, task)
 }
+
+// runExportLogs 导出事件日志子命令：pikachun export-logs -format parquet -output logs.parquet -since 2024-05-01T00:00:00Z
+// 直接读取配置中的 SQLite 数据库，不启动服务；输出到标准输出时日志写到标准错误
+func runExportLogs(args []string) error {
+	fs := flag.NewFlagSet("export-logs", flag.ContinueOnError)
+	format := fs.String("format", service.ExportFormatCSV, "导出格式: csv 或 parquet")
+	output := fs.String("output", "-", "输出文件，- 表示标准输出")
+	taskID := fs.Uint("task-id", 0, "任务ID")
+	databaseName := fs.String("database", "", "数据库名")
+	table := fs.String("table", "", "表名")
+	eventType := fs.String("event-type", "", "事件类型 (INSERT/UPDATE/DELETE)")
+	status := fs.String("status", "", "状态 (pending/success/failed)")
+	search := fs.String("q", "", "在事件数据中搜索")
+	since := fs.String("since", "", "起始时间 (RFC3339)")
+	until := fs.String("until", "", "结束时间 (RFC3339)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filter := service.EventLogFilter{
+		TaskID:    *taskID,
+		Database:  *databaseName,
+		Table:     *table,
+		EventType: *eventType,
+		Status:    *status,
+		Search:    *search,
+	}
+	for _, item := range []struct {
+		value  string
+		target **time.Time
+	}{{*since, &filter.Since}, {*until, &filter.Until}} {
+		if item.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, item.value)
+		if err != nil {
+			return fmt.Errorf("invalid time %q, expected RFC3339", item.value)
+		}
+		*item.target = &t
+	}
+
+	// 数据库日志默认输出到标准输出，改到标准错误以免混入导出数据
+	gormlogger.Default = gormlogger.New(log.New(os.Stderr, "\r\n", log.LstdFlags), gormlogger.Config{
+		SlowThreshold: 200 * time.Millisecond,
+		LogLevel:      gormlogger.Warn,
+	})
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+	db, err := database.Init(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %v", err)
+	}
+
+	var out io.Writer = os.Stdout
+	var file *os.File
+	if *output != "-" {
+		if file, err = os.Create(*output); err != nil {
+			return fmt.Errorf("failed to create output file: %v", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	count, err := service.NewTaskService(db).ExportEventLogs(out, *format, filter)
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to close output file: %v", err)
+		}
+	}
+	log.Printf("✅ Exported %d event logs", count)
+	return nil
+}
+
+// runHashPassword 生成 server.users 中的 password_hash：echo -n 'secret' | pikachun hash-password
+// 从标准输入读取密码，避免出现在命令行历史中
+func runHashPassword(input io.Reader) error {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return err
+	}
+	password := strings.TrimRight(string(data), "\r\n")
+	if password == "" {
+		return fmt.Errorf("empty password")
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+	return nil
+}
@@ -0,0 +1,211 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"pikachun/internal/canal"
+	"pikachun/test/integration/harness"
+)
+
+// eventTimeout 等待 Webhook 事件的时间，需大于 WebhookHandler 的批处理超时
+const eventTimeout = 30 * time.Second
+
+func newLogger(t *testing.T) *log.Logger {
+	return log.New(os.Stdout, "["+t.Name()+"] ", log.LstdFlags|log.Lshortfile)
+}
+
+// assertColumn 断言行数据中某列的值
+func assertColumn(t *testing.T, data *canal.RowData, name string, want interface{}) {
+	t.Helper()
+	got, ok := harness.ColumnValue(data, name)
+	if !ok {
+		t.Errorf("column %s not found in %+v", name, data)
+		return
+	}
+	// JSON 解码后数字为 float64，按字符串比较
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("column %s: expected %v, got %v", name, want, got)
+	}
+}
+
+// TestBinlogToWebhook 对真实 MySQL 执行增删改，断言 Webhook 收到的事件内容
+func TestBinlogToWebhook(t *testing.T) {
+	mysql := harness.StartMySQL(t)
+	recorder := harness.NewWebhookRecorder(t)
+	logger := newLogger(t)
+
+	mysql.CreateTable(t, "users", "id INT PRIMARY KEY, name VARCHAR(64), email VARCHAR(128)")
+
+	instance, err := canal.NewMySQLCanalInstance("it-webhook", mysql.CanalConfig(1001), logger, nil)
+	if err != nil {
+		t.Fatalf("Failed to create instance: %v", err)
+	}
+	handler := canal.NewWebhookHandler("webhook-it", recorder.URL, logger)
+	defer handler.Close()
+	if err := instance.Subscribe(mysql.Database, "users", handler); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	if err := instance.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start instance: %v", err)
+	}
+	defer instance.Stop()
+
+	mysql.Exec(t, "INSERT INTO "+mysql.Database+".users (id, name, email) VALUES (1, 'alice', 'alice@example.com')")
+	mysql.Exec(t, "UPDATE "+mysql.Database+".users SET name = 'alice2' WHERE id = 1")
+	mysql.Exec(t, "DELETE FROM "+mysql.Database+".users WHERE id = 1")
+
+	events := recorder.WaitForEvents(t, 3, eventTimeout)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %+v", len(events), events)
+	}
+
+	for _, event := range events {
+		if event.Schema != mysql.Database || event.Table != "users" {
+			t.Errorf("unexpected table %s.%s", event.Schema, event.Table)
+		}
+		if event.ID == "" || event.Position.Name == "" {
+			t.Errorf("expected event id and position, got %+v", event)
+		}
+	}
+
+	insert, update, del := events[0], events[1], events[2]
+
+	if insert.EventType != canal.EventTypeInsert {
+		t.Fatalf("expected INSERT, got %s", insert.EventType)
+	}
+	if insert.BeforeData != nil {
+		t.Errorf("expected no before data for INSERT")
+	}
+	assertColumn(t, insert.AfterData, "id", 1)
+	assertColumn(t, insert.AfterData, "name", "alice")
+	assertColumn(t, insert.AfterData, "email", "alice@example.com")
+
+	if update.EventType != canal.EventTypeUpdate {
+		t.Fatalf("expected UPDATE, got %s", update.EventType)
+	}
+	assertColumn(t, update.BeforeData, "name", "alice")
+	assertColumn(t, update.AfterData, "name", "alice2")
+	assertColumn(t, update.AfterData, "email", "alice@example.com")
+
+	if del.EventType != canal.EventTypeDelete {
+		t.Fatalf("expected DELETE, got %s", del.EventType)
+	}
+	if del.AfterData != nil {
+		t.Errorf("expected no after data for DELETE")
+	}
+	assertColumn(t, del.BeforeData, "id", 1)
+	assertColumn(t, del.BeforeData, "name", "alice2")
+}
+
+// TestUnwatchedTableNotDelivered 未订阅的表的变更不会投递
+func TestUnwatchedTableNotDelivered(t *testing.T) {
+	mysql := harness.StartMySQL(t)
+	recorder := harness.NewWebhookRecorder(t)
+	logger := newLogger(t)
+
+	mysql.CreateTable(t, "users", "id INT PRIMARY KEY, name VARCHAR(64)")
+	mysql.CreateTable(t, "orders", "id INT PRIMARY KEY, amount INT")
+
+	instance, err := canal.NewMySQLCanalInstance("it-filter", mysql.CanalConfig(1002), logger, nil)
+	if err != nil {
+		t.Fatalf("Failed to create instance: %v", err)
+	}
+	handler := canal.NewWebhookHandler("webhook-it", recorder.URL, logger)
+	defer handler.Close()
+	if err := instance.Subscribe(mysql.Database, "users", handler); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	if err := instance.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start instance: %v", err)
+	}
+	defer instance.Stop()
+
+	mysql.Exec(t, "INSERT INTO "+mysql.Database+".orders (id, amount) VALUES (1, 100)")
+	mysql.Exec(t, "INSERT INTO "+mysql.Database+".users (id, name) VALUES (1, 'bob')")
+
+	// users 的事件在 orders 之后写入 binlog，收到它时 orders 的事件必然已被处理
+	events := recorder.WaitForEvents(t, 1, eventTimeout)
+	for _, event := range events {
+		if event.Table != "users" {
+			t.Errorf("unexpected event for %s.%s", event.Schema, event.Table)
+		}
+	}
+}
+
+// TestStartFailsWithBadCredentials 密码错误时启动失败并记录错误信息
+func TestStartFailsWithBadCredentials(t *testing.T) {
+	mysql := harness.StartMySQL(t)
+	logger := newLogger(t)
+
+	cfg := mysql.CanalConfig(1003)
+	cfg.Canal.Password = "wrong-password"
+
+	instance, err := canal.NewMySQLCanalInstance("it-bad-auth", cfg, logger, nil)
+	if err != nil {
+		t.Fatalf("Failed to create instance: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := instance.Start(ctx); err == nil {
+		instance.Stop()
+		t.Fatal("Expected Start to fail with wrong password")
+	}
+
+	status := instance.GetStatus()
+	if status.Running {
+		t.Error("Expected instance not to be running")
+	}
+	if status.ErrorMsg == "" {
+		t.Error("Expected error message in status")
+	}
+
+	if err := instance.Stop(); err != nil {
+		t.Errorf("Stop after failed start returned error: %v", err)
+	}
+}
+
+// TestBinlogSlaveStartStop binlog slave 连接真实 MySQL 启动、获取位置并停止
+func TestBinlogSlaveStartStop(t *testing.T) {
+	mysql := harness.StartMySQL(t)
+	logger := newLogger(t)
+
+	cfg := mysql.CanalConfig(1004)
+	slave, err := canal.NewMySQLBinlogSlave(canal.MySQLConfig{
+		Host:     cfg.Canal.Host,
+		Port:     cfg.Canal.Port,
+		Username: cfg.Canal.Username,
+		Password: cfg.Canal.Password,
+		ServerID: cfg.Canal.ServerID,
+	}, canal.NewDefaultEventSink(logger), logger)
+	if err != nil {
+		t.Fatalf("Failed to create binlog slave: %v", err)
+	}
+
+	if err := slave.Start(); err != nil {
+		t.Fatalf("Failed to start binlog slave: %v", err)
+	}
+	if !slave.IsRunning() {
+		t.Error("Expected binlog slave to be running")
+	}
+	if pos := slave.GetBinlogPosition(); pos.Name == "" || pos.Pos == 0 {
+		t.Errorf("Expected current binlog position, got %+v", pos)
+	}
+
+	if err := slave.Stop(); err != nil {
+		t.Fatalf("Failed to stop binlog slave: %v", err)
+	}
+	if slave.IsRunning() {
+		t.Error("Expected binlog slave to be stopped")
+	}
+}
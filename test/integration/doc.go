@@ -0,0 +1,6 @@
+// Package integration 基于真实 MySQL 的端到端测试，需要 docker 或已有的 MySQL（见 harness.MySQLAddrEnv）
+//
+// 测试带有 integration 构建标签，默认的 go test ./... 不会运行：
+//
+//	go test -tags integration ./test/integration/... -v
+package integration
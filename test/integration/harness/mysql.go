@@ -0,0 +1,213 @@
+// Package harness 集成测试辅助：启动开启 binlog 的真实 MySQL、执行 SQL、收集 Webhook 请求
+package harness
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"pikachun/internal/config"
+)
+
+// 环境变量
+const (
+	// MySQLAddrEnv 指定已有的 MySQL（host:port）时不再启动容器，服务器需开启 ROW 格式 binlog
+	MySQLAddrEnv = "PIKACHUN_TEST_MYSQL_ADDR"
+	// MySQLPasswordEnv 已有 MySQL 的 root 密码，默认与 docker-compose.yml 一致
+	MySQLPasswordEnv = "PIKACHUN_TEST_MYSQL_PASSWORD"
+)
+
+// 与 test/integration/docker-compose.yml 保持一致
+const (
+	defaultPassword = "pikachun123"
+	defaultDatabase = "pikachun_it"
+	readyTimeout    = 2 * time.Minute
+)
+
+// MySQL 测试用 MySQL 服务器
+type MySQL struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Database string
+	DB       *sql.DB
+}
+
+// StartMySQL 启动 MySQL 并等待就绪，测试结束时自动销毁
+// 设置了 MySQLAddrEnv 时直接使用已有服务器；否则通过 docker compose 启动，docker 不可用时跳过测试
+func StartMySQL(t testing.TB) *MySQL {
+	t.Helper()
+
+	m := &MySQL{User: "root", Password: defaultPassword, Database: defaultDatabase}
+	if password := os.Getenv(MySQLPasswordEnv); password != "" {
+		m.Password = password
+	}
+
+	addr := os.Getenv(MySQLAddrEnv)
+	if addr == "" {
+		addr = startCompose(t)
+	}
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("invalid mysql address %q: %v", addr, err)
+	}
+	m.Host = host
+	if m.Port, err = strconv.Atoi(portStr); err != nil {
+		t.Fatalf("invalid mysql port %q: %v", portStr, err)
+	}
+
+	if err := m.waitReady(); err != nil {
+		t.Fatalf("mysql at %s not ready: %v", addr, err)
+	}
+	t.Cleanup(func() { m.DB.Close() })
+
+	t.Logf("✅ MySQL ready at %s", addr)
+	return m
+}
+
+// startCompose 启动 docker-compose.yml 中的 MySQL，返回映射到宿主机的地址
+func startCompose(t testing.TB) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skipf("docker not found, set %s to use an existing MySQL", MySQLAddrEnv)
+	}
+	if err := exec.Command("docker", "compose", "version").Run(); err != nil {
+		t.Skipf("docker compose not available: %v", err)
+	}
+
+	// 每个测试进程使用独立的项目名，避免并行运行时互相干扰
+	project := fmt.Sprintf("pikachun-it-%d", os.Getpid())
+	compose := func(args ...string) (string, error) {
+		args = append([]string{"compose", "-p", project, "-f", composeFile()}, args...)
+		cmd := exec.Command("docker", args...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("docker %s: %v: %s", strings.Join(args, " "), err, stderr.String())
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	t.Logf("🐳 Starting MySQL with docker compose (project %s)", project)
+	if _, err := compose("up", "-d"); err != nil {
+		t.Fatalf("failed to start mysql: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := compose("down", "-v"); err != nil {
+			t.Logf("⚠️ Failed to stop mysql: %v", err)
+		}
+	})
+
+	// 输出形如 0.0.0.0:49153
+	addr, err := compose("port", "mysql", "3306")
+	if err != nil {
+		t.Fatalf("failed to resolve mysql port: %v", err)
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("unexpected docker compose port output %q: %v", addr, err)
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// composeFile docker-compose.yml 的绝对路径，与测试的工作目录无关
+func composeFile() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "docker-compose.yml")
+}
+
+// waitReady 等待 MySQL 接受连接并确认 binlog 已开启
+// 容器初始化期间会先启动一个不监听 TCP 的临时实例，因此需要轮询
+func (m *MySQL) waitReady() error {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s)/?parseTime=true&multiStatements=true",
+		m.User, m.Password, net.JoinHostPort(m.Host, strconv.Itoa(m.Port)))
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open connection: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+	for {
+		err = db.PingContext(ctx)
+		if err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return fmt.Errorf("timed out waiting for mysql: %v", err)
+		case <-time.After(time.Second):
+		}
+	}
+
+	var name, value string
+	if err := db.QueryRow("SHOW VARIABLES LIKE 'binlog_format'").Scan(&name, &value); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to query binlog_format: %v", err)
+	}
+	if value != "ROW" {
+		db.Close()
+		return fmt.Errorf("binlog_format is %s, ROW required", value)
+	}
+
+	if _, err := db.Exec("CREATE DATABASE IF NOT EXISTS " + m.Database); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create database %s: %v", m.Database, err)
+	}
+
+	m.DB = db
+	return nil
+}
+
+// Exec 执行 SQL，失败时终止测试
+func (m *MySQL) Exec(t testing.TB, query string, args ...interface{}) sql.Result {
+	t.Helper()
+	result, err := m.DB.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("failed to exec %q: %v", query, err)
+	}
+	return result
+}
+
+// CreateTable 在测试库中重建表，测试结束时删除
+func (m *MySQL) CreateTable(t testing.TB, table, columns string) {
+	t.Helper()
+	m.Exec(t, fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", m.Database, table))
+	m.Exec(t, fmt.Sprintf("CREATE TABLE %s.%s (%s)", m.Database, table, columns))
+	t.Cleanup(func() {
+		m.DB.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", m.Database, table))
+	})
+}
+
+// CanalConfig 连接该服务器的 Canal 配置，serverID 需与其他 slave 不同
+func (m *MySQL) CanalConfig(serverID uint32) *config.Config {
+	return &config.Config{
+		Canal: config.CanalConfig{
+			Host:     m.Host,
+			Port:     m.Port,
+			Username: m.User,
+			Password: m.Password,
+			ServerID: serverID,
+			Watch: config.WatchConfig{
+				EventTypes: []string{"INSERT", "UPDATE", "DELETE"},
+			},
+		},
+	}
+}
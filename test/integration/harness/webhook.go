@@ -0,0 +1,106 @@
+package harness
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"pikachun/internal/canal"
+)
+
+// WebhookPayload Webhook 请求体，与 canal.WebhookHandler 发送的格式一致
+type WebhookPayload struct {
+	Events    []canal.Event `json:"events"`
+	Timestamp int64         `json:"timestamp"`
+	Source    string        `json:"source"`
+}
+
+// WebhookRecorder 本地 Webhook 接收器，记录收到的所有请求
+type WebhookRecorder struct {
+	URL string
+
+	server   *httptest.Server
+	mu       sync.Mutex
+	payloads []WebhookPayload
+	notify   chan struct{}
+}
+
+// NewWebhookRecorder 启动 Webhook 接收器，测试结束时自动关闭
+func NewWebhookRecorder(t testing.TB) *WebhookRecorder {
+	t.Helper()
+
+	r := &WebhookRecorder{notify: make(chan struct{}, 1)}
+	r.server = httptest.NewServer(http.HandlerFunc(r.handle))
+	r.URL = r.server.URL
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// handle 解析并记录请求体，无法解析时返回 400
+func (r *WebhookRecorder) handle(w http.ResponseWriter, req *http.Request) {
+	var payload WebhookPayload
+	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	r.payloads = append(r.payloads, payload)
+	r.mu.Unlock()
+
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Payloads 已收到的请求体
+func (r *WebhookRecorder) Payloads() []WebhookPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]WebhookPayload(nil), r.payloads...)
+}
+
+// Events 按收到的顺序展开所有请求中的事件
+func (r *WebhookRecorder) Events() []canal.Event {
+	var events []canal.Event
+	for _, payload := range r.Payloads() {
+		events = append(events, payload.Events...)
+	}
+	return events
+}
+
+// WaitForEvents 等待至少收到 n 个事件，超时则终止测试
+func (r *WebhookRecorder) WaitForEvents(t testing.TB, n int, timeout time.Duration) []canal.Event {
+	t.Helper()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		if events := r.Events(); len(events) >= n {
+			return events
+		}
+		select {
+		case <-r.notify:
+		case <-deadline.C:
+			t.Fatalf("timed out waiting for %d webhook events, got %d", n, len(r.Events()))
+		}
+	}
+}
+
+// ColumnValue 按列名查找行数据中的值
+func ColumnValue(data *canal.RowData, name string) (interface{}, bool) {
+	if data == nil {
+		return nil, false
+	}
+	for _, col := range data.Columns {
+		if col.Name == name {
+			return col.Value, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/database"
+	"pikachun/internal/service"
+)
+
+// TestDeliverySequenceReservation 测试预留的序号上限在重启后继续递增，清理和清除任务时删除序号记录
+func TestDeliverySequenceReservation(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	task := database.Task{Name: "t", Database: "testdb", Table: "users", EventTypes: "INSERT", CallbackURL: "http://localhost"}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	taskService := service.NewTaskService(db)
+
+	// 只有投递成功的序号有记录，重启后从预留的上限之后分配，失败批次的序号不会重复使用
+	if err := taskService.ReserveDeliverySequence(task.ID, 1000); err != nil {
+		t.Fatalf("ReserveDeliverySequence failed: %v", err)
+	}
+	if err := taskService.RecordDeliveryCursor(canal.DeliveryCursor{TaskID: task.ID, Sequence: 3, EventCount: 1}); err != nil {
+		t.Fatalf("RecordDeliveryCursor failed: %v", err)
+	}
+	// 上限只增不减
+	if err := taskService.ReserveDeliverySequence(task.ID, 500); err != nil {
+		t.Fatalf("ReserveDeliverySequence failed: %v", err)
+	}
+	if last, err := taskService.LastDeliverySequence(task.ID); err != nil || last != 1000 {
+		t.Fatalf("expected last sequence 1000, got %d, %v", last, err)
+	}
+
+	// 清理过期记录不影响序号递增
+	pruned, err := taskService.PruneDeliveryCursors(time.Now().Add(time.Minute))
+	if err != nil || pruned != 1 {
+		t.Fatalf("expected 1 pruned cursor, got %d, %v", pruned, err)
+	}
+	if last, err := taskService.LastDeliverySequence(task.ID); err != nil || last != 1000 {
+		t.Fatalf("expected last sequence 1000 after pruning, got %d, %v", last, err)
+	}
+
+	// 清除任务时一并删除序号记录
+	if err := taskService.RecordDeliveryCursor(canal.DeliveryCursor{TaskID: task.ID, Sequence: 1001, EventCount: 1}); err != nil {
+		t.Fatalf("RecordDeliveryCursor failed: %v", err)
+	}
+	if err := taskService.PurgeTask(task.ID); err != nil {
+		t.Fatalf("PurgeTask failed: %v", err)
+	}
+	var cursors, sequences int64
+	db.Model(&database.DeliveryCursor{}).Count(&cursors)
+	db.Model(&database.DeliverySequence{}).Count(&sequences)
+	if cursors != 0 || sequences != 0 {
+		t.Errorf("expected cursors and sequences purged, got %d cursors and %d sequences", cursors, sequences)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/config"
+	"pikachun/internal/database"
+	"pikachun/internal/service"
+)
+
+// seedEventLogs 写入测试事件日志
+func seedEventLogs(t *testing.T, db *gorm.DB) {
+	task := database.Task{Name: "t", Database: "testdb", Table: "users", EventTypes: "INSERT", CallbackURL: "http://localhost"}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	now := time.Now()
+	logs := []database.EventLog{
+		{TaskID: task.ID, Database: "testdb", Table: "users", EventType: "INSERT", ContentHash: "hash-alice", Status: "success", Data: `{"name":"alice"}`, CreatedAt: now.Add(-3 * time.Hour)},
+		{TaskID: task.ID, Database: "testdb", Table: "users", EventType: "UPDATE", Status: "failed", Data: `{"name":"bob"}`, CreatedAt: now.Add(-2 * time.Hour)},
+		{TaskID: task.ID, Database: "testdb", Table: "orders", EventType: "INSERT", Status: "success", Data: `{"item":"100%_cotton"}`, CreatedAt: now.Add(-time.Hour)},
+		{TaskID: task.ID, Database: "otherdb", Table: "users", EventType: "DELETE", Status: "success", Data: `{"name":"alice"}`, CreatedAt: now},
+	}
+	for i := range logs {
+		if err := db.Create(&logs[i]).Error; err != nil {
+			t.Fatalf("Failed to create event log: %v", err)
+		}
+	}
+}
+
+// checkEventLogSearch 校验过滤与分页结果
+func checkEventLogSearch(t *testing.T, taskService *service.TaskService) {
+	since := time.Now().Add(-150 * time.Minute)
+	cases := []struct {
+		name   string
+		filter service.EventLogFilter
+		want   int
+	}{
+		{"all", service.EventLogFilter{}, 4},
+		{"database", service.EventLogFilter{Database: "testdb"}, 3},
+		{"table", service.EventLogFilter{Database: "testdb", Table: "users"}, 2},
+		{"event type", service.EventLogFilter{EventType: "insert"}, 2},
+		{"status", service.EventLogFilter{Status: "failed"}, 1},
+		{"time range", service.EventLogFilter{Since: &since}, 3},
+		{"search", service.EventLogFilter{Search: "alice"}, 2},
+		{"search with wildcard chars", service.EventLogFilter{Search: "100%_cotton"}, 1},
+		{"search no match", service.EventLogFilter{Search: "carol"}, 0},
+	}
+
+	for _, c := range cases {
+		result, err := taskService.SearchEventLogs(c.filter)
+		if err != nil {
+			t.Fatalf("%s: SearchEventLogs failed: %v", c.name, err)
+		}
+		if len(result.Logs) != c.want || result.Total != int64(c.want) {
+			t.Errorf("%s: got %d logs (total %d), want %d", c.name, len(result.Logs), result.Total, c.want)
+		}
+	}
+
+	// 游标分页按 ID 倒序遍历全部记录
+	var seen []uint
+	filter := service.EventLogFilter{PageSize: 3}
+	for {
+		result, err := taskService.SearchEventLogs(filter)
+		if err != nil {
+			t.Fatalf("cursor: SearchEventLogs failed: %v", err)
+		}
+		for _, log := range result.Logs {
+			seen = append(seen, log.ID)
+		}
+		if result.NextCursor == 0 {
+			break
+		}
+		filter.Cursor = result.NextCursor
+	}
+	if len(seen) != 4 {
+		t.Fatalf("cursor pagination returned %d logs, want 4", len(seen))
+	}
+	for i := 1; i < len(seen); i++ {
+		if seen[i] >= seen[i-1] {
+			t.Errorf("cursor pagination not in descending order: %v", seen)
+		}
+	}
+}
+
+// TestSearchEventLogsLike 测试未启用全文索引时的 LIKE 搜索
+func TestSearchEventLogsLike(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.Task{}, &database.EventLog{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	seedEventLogs(t, db)
+	checkEventLogSearch(t, service.NewTaskService(db))
+}
+
+// TestSearchEventLogsFTS 测试全文索引搜索
+func TestSearchEventLogsFTS(t *testing.T) {
+	db, err := database.Init(config.DatabaseConfig{DSN: filepath.Join(t.TempDir(), "fts.db")})
+	if err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	if !db.Migrator().HasTable(database.EventLogFTSTable) {
+		t.Skip("SQLite FTS5 not available")
+	}
+
+	seedEventLogs(t, db)
+	checkEventLogSearch(t, service.NewTaskService(db))
+}
+
+// TestExportEventLogs 测试按条件导出事件日志
+func TestExportEventLogs(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.Task{}, &database.EventLog{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	seedEventLogs(t, db)
+	taskService := service.NewTaskService(db)
+
+	var buf bytes.Buffer
+	count, err := taskService.ExportEventLogs(&buf, service.ExportFormatCSV, service.EventLogFilter{Database: "testdb"})
+	if err != nil {
+		t.Fatalf("ExportEventLogs failed: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid csv: %v", err)
+	}
+	if count != 3 || len(records) != 4 {
+		t.Fatalf("expected 3 rows plus header, got count %d, %d records", count, len(records))
+	}
+	if records[0][0] != "id" || records[1][3] != "testdb" || records[1][8] != `{"name":"alice"}` {
+		t.Errorf("unexpected csv content: %v", records)
+	}
+	// content_hash 追加在最后一列，之前的列位置不变
+	if last := len(records[0]) - 1; records[0][last] != "content_hash" || records[1][last] != "hash-alice" {
+		t.Errorf("expected content_hash as the last column, got %v", records[:2])
+	}
+	// 按 ID 升序导出
+	if records[1][0] >= records[2][0] {
+		t.Errorf("export not in ascending order: %v", records)
+	}
+
+	buf.Reset()
+	if count, err = taskService.ExportEventLogs(&buf, service.ExportFormatParquet, service.EventLogFilter{}); err != nil || count != 4 {
+		t.Fatalf("parquet export failed: count %d, err %v", count, err)
+	}
+	if data := buf.Bytes(); string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Errorf("invalid parquet file")
+	}
+
+	if _, err := taskService.ExportEventLogs(&buf, "xlsx", service.EventLogFilter{}); err == nil {
+		t.Errorf("expected error for unsupported format")
+	}
+
+	// 单条写入的事件日志同样带有内容哈希
+	entry := canal.EventLogEntry{TaskID: 1, Database: "newdb", Table: "users", EventType: "INSERT", ContentHash: "hash-new", Status: "success"}
+	if err := taskService.CreateEventLog(entry); err != nil {
+		t.Fatalf("CreateEventLog failed: %v", err)
+	}
+	buf.Reset()
+	if _, err := taskService.ExportEventLogs(&buf, service.ExportFormatCSV, service.EventLogFilter{Database: "newdb"}); err != nil {
+		t.Fatalf("ExportEventLogs failed: %v", err)
+	}
+	if records, err = csv.NewReader(&buf).ReadAll(); err != nil || len(records) != 2 || records[1][len(records[1])-1] != "hash-new" {
+		t.Errorf("expected content hash of the created log exported, got %v, %v", records, err)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/database"
+	"pikachun/internal/service"
+)
+
+// TestRecordFailedEvents 测试被拒绝的事件已有事件日志时更新为 failed，没有时插入一条 failed 记录
+func TestRecordFailedEvents(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	task := database.Task{Name: "t", Database: "shop", Table: "orders", EventTypes: "INSERT", CallbackURL: "http://localhost"}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	taskService := service.NewTaskService(db)
+
+	if err := taskService.CreateEventLogs([]canal.EventLogEntry{{TaskID: task.ID, EventID: "e1", Database: "shop", Table: "orders",
+		EventType: "INSERT", Data: `{"id":1}`, Status: "success"}}); err != nil {
+		t.Fatalf("CreateEventLogs failed: %v", err)
+	}
+	err = taskService.RecordFailedEvents([]canal.EventLogEntry{
+		{TaskID: task.ID, EventID: "e1", Database: "shop", Table: "orders", EventType: "INSERT", Data: `{"id":1}`,
+			Status: "failed", Error: "nacked by receiver: busy"},
+		// 未启用数据库存储时事件日志中没有该事件
+		{TaskID: task.ID, EventID: "e2", Database: "shop", Table: "orders", EventType: "UPDATE", Data: `{"id":2}`,
+			Status: "failed", Error: "nacked by receiver: locked"},
+	})
+	if err != nil {
+		t.Fatalf("RecordFailedEvents failed: %v", err)
+	}
+
+	var logs []database.EventLog
+	if err := db.Where("task_id = ?", task.ID).Order("event_id").Find(&logs).Error; err != nil {
+		t.Fatalf("Failed to load event logs: %v", err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 event logs, got %d", len(logs))
+	}
+	if logs[0].EventID != "e1" || logs[0].Status != "failed" || logs[0].Error != "nacked by receiver: busy" {
+		t.Errorf("expected e1 updated to failed, got %+v", logs[0])
+	}
+	if logs[1].EventID != "e2" || logs[1].Status != "failed" || logs[1].EventType != "UPDATE" || logs[1].Data != `{"id":2}` {
+		t.Errorf("expected e2 inserted as failed, got %+v", logs[1])
+	}
+	if count, err := taskService.CountFailedEventLogs(task.ID); err != nil || count != 2 {
+		t.Errorf("expected 2 failed events, got %d, %v", count, err)
+	}
+}
+
+// TestSkippedBinlogEvents 测试跳过的损坏事件的审计记录按时间先后返回最近的记录和总数，清除任务时删除
+func TestSkippedBinlogEvents(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	task := database.Task{Name: "t", Database: "shop", Table: "orders", EventTypes: "INSERT", CallbackURL: "http://localhost"}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	taskService := service.NewTaskService(db)
+
+	for _, pos := range []uint32{100, 200, 300} {
+		record := &database.SkippedBinlogEvent{TaskID: task.ID, Filename: "mysql-bin.000003", Position: pos, EndPos: pos + 50,
+			EventType: "Write_rows", Error: "checksum mismatch"}
+		if err := taskService.RecordSkippedBinlogEvent(record); err != nil {
+			t.Fatalf("RecordSkippedBinlogEvent failed: %v", err)
+		}
+	}
+
+	records, total, err := taskService.SkippedBinlogEvents(task.ID, 2)
+	if err != nil {
+		t.Fatalf("SkippedBinlogEvents failed: %v", err)
+	}
+	if total != 3 || len(records) != 2 || records[0].Position != 200 || records[1].Position != 300 {
+		t.Errorf("expected the latest 2 of 3 records in order, got %d %+v", total, records)
+	}
+
+	if err := taskService.PurgeTask(task.ID); err != nil {
+		t.Fatalf("PurgeTask failed: %v", err)
+	}
+	if _, total, _ := taskService.SkippedBinlogEvents(task.ID, 2); total != 0 {
+		t.Errorf("expected the records removed with the task, got %d", total)
+	}
+}
+
+// TestRedeliverUpdateEvent 测试重新投递 UPDATE 事件时还原修改前后的两份数据
+func TestRedeliverUpdateEvent(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer server.Close()
+
+	task := database.Task{Name: "t", Database: "shop", Table: "orders", EventTypes: "UPDATE", CallbackURL: server.URL}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	taskService := service.NewTaskService(db)
+	if err := taskService.CreateEventLogs([]canal.EventLogEntry{{TaskID: task.ID, EventID: "u1", Database: "shop", Table: "orders",
+		EventType: "UPDATE", Data: `{"columns":[{"name":"status","value":"paid"}]}`,
+		BeforeData: `{"columns":[{"name":"status","value":"pending"}]}`, Status: "failed"}}); err != nil {
+		t.Fatalf("CreateEventLogs failed: %v", err)
+	}
+	var eventLog database.EventLog
+	if err := db.Where("event_id = ?", "u1").First(&eventLog).Error; err != nil {
+		t.Fatalf("Failed to load event log: %v", err)
+	}
+
+	if _, err := taskService.RedeliverEvent(context.Background(), eventLog.ID, ""); err != nil {
+		t.Fatalf("RedeliverEvent failed: %v", err)
+	}
+	body := <-bodies
+	if !strings.Contains(body, `"before_data"`) || !strings.Contains(body, "pending") || !strings.Contains(body, "paid") {
+		t.Errorf("expected both row images redelivered, got %s", body)
+	}
+}
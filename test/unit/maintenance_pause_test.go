@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/config"
+	"pikachun/internal/service"
+)
+
+// TestMaintenancePausePersisted 测试人工维护暂停在重启后恢复，解除后不再恢复
+func TestMaintenancePausePersisted(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to setup test database: %v", err)
+	}
+	cfg := &config.Config{Canal: config.CanalConfig{Host: "127.0.0.1", Port: 3307, Username: "root", ServerID: 12345}}
+	newService := func() *service.EnhancedCanalService {
+		svc, err := service.NewEnhancedCanalService(cfg, db, service.NewTaskService(db))
+		if err != nil {
+			t.Fatalf("Failed to create EnhancedCanalService: %v", err)
+		}
+		return svc
+	}
+	maintenance := func(svc *service.EnhancedCanalService) canal.MaintenanceStatus {
+		status, _ := svc.GetStatus()["maintenance"].(canal.MaintenanceStatus)
+		return status
+	}
+
+	svc := newService()
+	if _, err := svc.PauseReading("", "upgrade"); err != nil {
+		t.Fatalf("PauseReading failed: %v", err)
+	}
+	since := maintenance(svc).Global.Since
+	// 重复暂停保留开始时间
+	if _, err := svc.PauseReading("", "still upgrading"); err != nil {
+		t.Fatalf("PauseReading failed: %v", err)
+	}
+	if _, err := svc.PauseReading(canal.DefaultSourceID, "failover"); err != nil {
+		t.Fatalf("PauseReading failed: %v", err)
+	}
+
+	status := maintenance(newService())
+	if status.Global == nil || status.Global.Reason != "still upgrading" || !status.Global.Since.Equal(since) {
+		t.Fatalf("expected global pause restored, got %+v", status.Global)
+	}
+	if pause := status.Sources[canal.DefaultSourceID]; pause == nil || pause.Reason != "failover" {
+		t.Fatalf("expected source pause restored, got %+v", pause)
+	}
+
+	if resumed, _, err := svc.ResumeReading(""); err != nil || !resumed {
+		t.Fatalf("ResumeReading failed: %v, %v", resumed, err)
+	}
+	status = maintenance(newService())
+	if status.Global != nil || status.Sources[canal.DefaultSourceID] == nil {
+		t.Errorf("expected only the source pause restored, got %+v", status)
+	}
+}
@@ -64,44 +64,6 @@ func TestMySQLBinlogSlave(t *testing.T) {
 	t.Logf("MySQLBinlogSlave String(): %s", str)
 }
 
-// TestMySQLBinlogSlaveStartStop 测试 MySQLBinlogSlave 的启动和停止
-func TestMySQLBinlogSlaveStartStop(t *testing.T) {
-	// 创建测试日志器
-	logger := log.New(os.Stdout, "[TestMySQLBinlogSlaveStartStop] ", log.LstdFlags|log.Lshortfile)
-
-	// 创建事件接收器
-	eventSink := canal.NewDefaultEventSink(logger)
-
-	// 创建 MySQL 配置
-	mysqlConfig := canal.MySQLConfig{
-		Host:     "localhost",
-		Port:     3307,
-		Username: "test",
-		Password: "test",
-		ServerID: 12345,
-	}
-
-	// 创建 MySQLBinlogSlave
-	binlogSlave, err := canal.NewMySQLBinlogSlave(mysqlConfig, eventSink, logger)
-	if err != nil {
-		t.Fatalf("Failed to create MySQLBinlogSlave: %v", err)
-	}
-
-	// 启动应该失败，因为我们没有真实的 MySQL 连接
-	err = binlogSlave.Start()
-	if err == nil {
-		t.Error("Expected Start() to fail without real MySQL connection")
-	} else {
-		t.Logf("Start() failed as expected: %v", err)
-	}
-
-	// 停止应该成功，即使没有启动
-	err = binlogSlave.Stop()
-	if err != nil {
-		t.Errorf("Stop() failed: %v", err)
-	}
-}
-
 // TestMySQLBinlogSlaveWatchTables 测试监听表的添加和移除
 func TestMySQLBinlogSlaveWatchTables(t *testing.T) {
 	// 创建测试日志器
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pikachun/internal/canal"
+	"pikachun/internal/config"
+	"pikachun/internal/database"
+	"pikachun/internal/service"
+)
+
+// setupPullTest 创建使用文件数据库的任务服务，长轮询期间的写入来自其他连接
+func setupPullTest(t *testing.T) (*service.TaskService, uint) {
+	db, err := database.Init(config.DatabaseConfig{DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	task := database.Task{Name: "t", Database: "shop", Table: "orders", EventTypes: "INSERT", CallbackURL: "http://localhost"}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	return service.NewTaskService(db), task.ID
+}
+
+// writePullEvents 写入 ID 为 from 到 to 的事件日志
+func writePullEvents(t *testing.T, taskService *service.TaskService, taskID uint, from, to int) {
+	entries := make([]canal.EventLogEntry, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		entries = append(entries, canal.EventLogEntry{TaskID: taskID, EventID: fmt.Sprintf("e%d", i), Database: "shop", Table: "orders",
+			EventType: "INSERT", Data: fmt.Sprintf(`{"columns":[{"name":"id","value":%d}]}`, i), Status: "success"})
+	}
+	if err := taskService.CreateEventLogs(entries); err != nil {
+		t.Fatalf("CreateEventLogs failed: %v", err)
+	}
+}
+
+// TestPullEventsCursor 测试传入的位置被确认并保存，不传位置时从保存的位置继续，limit 限制返回的事件数
+func TestPullEventsCursor(t *testing.T) {
+	taskService, taskID := setupPullTest(t)
+	writePullEvents(t, taskService, taskID, 1, 5)
+	ctx := context.Background()
+
+	result, err := taskService.PullEvents(ctx, service.PullRequest{TaskID: taskID, Limit: 2})
+	if err != nil {
+		t.Fatalf("PullEvents failed: %v", err)
+	}
+	if len(result.Events) != 2 || result.Events[0].Event.ID != "e1" || result.NextCursor != result.Events[1].Cursor {
+		t.Fatalf("expected the first 2 events, got %+v", result)
+	}
+
+	// 没有确认之前，再次拉取返回同样的事件
+	again, err := taskService.PullEvents(ctx, service.PullRequest{TaskID: taskID, Limit: 2})
+	if err != nil || len(again.Events) != 2 || again.Events[0].Event.ID != "e1" {
+		t.Fatalf("expected the same events before acknowledging, got %+v, %v", again, err)
+	}
+
+	cursor := result.NextCursor
+	result, err = taskService.PullEvents(ctx, service.PullRequest{TaskID: taskID, Cursor: &cursor, Limit: 2})
+	if err != nil || len(result.Events) != 2 || result.Events[0].Event.ID != "e3" {
+		t.Fatalf("expected e3 and e4 after acknowledging, got %+v, %v", result, err)
+	}
+
+	// 不传位置时从保存的位置（e2 之后）继续
+	resumed, err := taskService.PullEvents(ctx, service.PullRequest{TaskID: taskID})
+	if err != nil || len(resumed.Events) != 3 || resumed.Events[0].Event.ID != "e3" {
+		t.Fatalf("expected to resume from the saved cursor, got %+v, %v", resumed, err)
+	}
+
+	// 各消费者的位置分别保存
+	other, err := taskService.PullEvents(ctx, service.PullRequest{TaskID: taskID, Consumer: "other", Limit: 1})
+	if err != nil || len(other.Events) != 1 || other.Events[0].Event.ID != "e1" {
+		t.Fatalf("expected consumer other to start from the beginning, got %+v, %v", other, err)
+	}
+
+	// limit 超过上限时使用默认值
+	all, err := taskService.PullEvents(ctx, service.PullRequest{TaskID: taskID, Consumer: "all", Limit: service.MaxPullLimit + 1})
+	if err != nil || len(all.Events) != 5 {
+		t.Fatalf("expected all 5 events with the default limit, got %+v, %v", all, err)
+	}
+}
+
+// TestPullEventsWait 测试没有新事件时等待，写入事件日志后醒来返回，超时返回空列表
+func TestPullEventsWait(t *testing.T) {
+	taskService, taskID := setupPullTest(t)
+	ctx := context.Background()
+
+	start := time.Now()
+	result, err := taskService.PullEvents(ctx, service.PullRequest{TaskID: taskID, Wait: 50 * time.Millisecond})
+	if err != nil || len(result.Events) != 0 || result.NextCursor != 0 {
+		t.Fatalf("expected an empty result after the wait, got %+v, %v", result, err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected to wait 50ms, returned after %v", elapsed)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if err := taskService.CreateEventLogs([]canal.EventLogEntry{{TaskID: taskID, EventID: "e1", Database: "shop", Table: "orders",
+			EventType: "INSERT", Data: `{"columns":[{"name":"id","value":1}]}`, Status: "success"}}); err != nil {
+			t.Errorf("CreateEventLogs failed: %v", err)
+		}
+	}()
+	start = time.Now()
+	result, err = taskService.PullEvents(ctx, service.PullRequest{TaskID: taskID, Wait: 10 * time.Second})
+	if err != nil || len(result.Events) != 1 || result.Events[0].Event.ID != "e1" {
+		t.Fatalf("expected to wake up with the new event, got %+v, %v", result, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected to wake up on write, returned after %v", elapsed)
+	}
+
+	// 请求取消时返回
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	cursor := result.NextCursor
+	if _, err := taskService.PullEvents(cancelled, service.PullRequest{TaskID: taskID, Cursor: &cursor, Wait: 10 * time.Second}); err == nil {
+		t.Error("expected an error when the request is cancelled")
+	}
+}
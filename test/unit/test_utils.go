@@ -34,3 +34,11 @@ func (h *MockEventHandler) Handle(ctx context.Context, event *canal.Event) error
 func (h *MockEventHandler) GetName() string {
 	return h.name
 }
+
+func (h *MockEventHandler) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (h *MockEventHandler) Close() error {
+	return nil
+}
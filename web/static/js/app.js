@@ -2,6 +2,34 @@
 let currentPage = 1;
 let currentTab = 'tasks';
 
+// 界面语言和消息目录由首页注入，缺少译文时使用中文原文
+const i18n = window.PIKACHUN_I18N || { language: 'zh', messages: {} };
+
+// 当前用户的权限，viewer 角色不显示修改任务的操作
+const session = window.PIKACHUN_SESSION || { logged_in: false, can_mutate: true };
+
+// t 翻译界面文字，{0}、{1} 依次替换为参数
+function t(text, ...args) {
+    let result = i18n.messages[text] || text;
+    args.forEach((arg, i) => {
+        result = result.split(`{${i}}`).join(arg);
+    });
+    return result;
+}
+
+// escapeHTML 转义要插入 HTML 的文本，错误信息等来自外部的内容不能按 HTML 解析
+function escapeHTML(text) {
+    const entities = { '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' };
+    return (text == null ? '' : String(text)).replace(/[&<>"']/g, c => entities[c]);
+}
+
+// switchLanguage 切换界面语言，服务端将选择保存在 Cookie 中，API 错误信息也使用该语言
+function switchLanguage(language) {
+    const url = new URL(window.location.href);
+    url.searchParams.set('lang', language);
+    window.location.href = url.toString();
+}
+
 // 页面加载完成后初始化
 document.addEventListener('DOMContentLoaded', function() {
     initializeTabs();
@@ -43,9 +71,12 @@ function initializeTabs() {
                 case 'status':
                     loadSystemStatus();
                     break;
-                // case 'binlog':
-                //     loadBinlogInfo();
-                //     break;
+                case 'binlog':
+                    loadPositions();
+                    break;
+                case 'analytics':
+                    loadTableAnalytics();
+                    break;
                 case 'metrics':
                     loadMetrics();
                     break;
@@ -57,17 +88,70 @@ function initializeTabs() {
 // 加载任务列表
 async function loadTasks(page = 1) {
     try {
-        const response = await fetch(`/api/tasks?page=${page}&page_size=10`);
+        const response = await fetch(`/api/v1/tasks?page=${page}&page_size=10`);
         const result = await response.json();
         
         if (response.ok) {
             renderTasksTable(result.data.tasks);
             renderPagination('tasksPagination', result.data.page, Math.ceil(result.data.total / result.data.page_size), loadTasks);
+            loadDeletedTasks();
         } else {
-            showError('加载任务列表失败: ' + result.error);
+            showError(t('加载任务列表失败: ') + result.error);
         }
     } catch (error) {
-        showError('网络错误: ' + error.message);
+        showError(t('网络错误: ') + error.message);
+    }
+}
+
+// 加载已删除、尚未清除的任务，没有时隐藏面板
+async function loadDeletedTasks() {
+    try {
+        const response = await fetch('/api/v1/tasks/deleted');
+        const result = await response.json();
+        if (!response.ok) {
+            return;
+        }
+
+        const tasks = result.data || [];
+        document.getElementById('deletedTasksPanel').style.display = tasks.length > 0 ? '' : 'none';
+        const tbody = document.querySelector('#deletedTasksTable tbody');
+        tbody.innerHTML = '';
+        tasks.forEach(task => {
+            const row = document.createElement('tr');
+            row.innerHTML = `
+                <td>${task.id}</td>
+                <td>${task.name}</td>
+                <td>${task.database}</td>
+                <td>${task.table}</td>
+                <td>${formatDateTime(task.deleted_at)}</td>
+                <td>${formatDateTime(task.purge_at)}</td>
+                <td>
+                    ${session.can_mutate ? `<button class="btn btn-small btn-secondary" onclick="restoreTask(${task.id})">${t('还原')}</button>` : '-'}
+                </td>
+            `;
+            tbody.appendChild(row);
+        });
+    } catch (error) {
+        console.error('Failed to load deleted tasks:', error);
+    }
+}
+
+// 还原已删除的任务，还原后为停用状态
+async function restoreTask(id) {
+    try {
+        const response = await fetch(`/api/v1/tasks/${id}/restore`, {
+            method: 'POST'
+        });
+        const result = await response.json();
+
+        if (response.ok) {
+            loadTasks();
+            showSuccess(result.message);
+        } else {
+            showError(t('还原任务失败: ') + result.error);
+        }
+    } catch (error) {
+        showError(t('网络错误: ') + error.message);
     }
 }
 
@@ -77,7 +161,7 @@ function renderTasksTable(tasks) {
     tbody.innerHTML = '';
     
     if (!tasks || tasks.length === 0) {
-        tbody.innerHTML = '<tr><td colspan="8" style="text-align: center; color: #666;">暂无数据</td></tr>';
+        tbody.innerHTML = `<tr><td colspan="8" style="text-align: center; color: #666;">${t('暂无数据')}</td></tr>`;
         return;
     }
     
@@ -90,10 +174,24 @@ function renderTasksTable(tasks) {
             <td>${task.table}</td>
             <td>${task.event_types}</td>
             <td><span class="url-text" title="${task.callback_url}">${truncateUrl(task.callback_url)}</span></td>
-            <td><span class="status-badge status-${task.status}">${getStatusText(task.status)}</span></td>
             <td>
-                <button class="btn btn-small btn-secondary" onclick="editTask(${task.id})">编辑</button>
-                <button class="btn btn-small btn-danger" onclick="deleteTask(${task.id})">删除</button>
+                <span class="status-badge status-${task.status}" ${task.status === 'pending' ? `title="${t('实例启动失败，后台重试中')}: ${escapeHTML(task.last_error)}"` : ''}>${getStatusText(task.status)}</span>
+                ${task.dry_run ? `<span class="status-badge status-dry_run" title="${t('不调用回调地址，只记录将要投递的内容')}">${t('演练')}</span>` : ''}
+                ${task.priority > 1 ? `<span class="status-badge status-priority" title="${t('调度权重，读取限速和投递并发已满时按权重分配')}">P${task.priority}</span>` : ''}
+                ${task.compact_window > 0 ? `<span class="status-badge status-compact" title="${t('同一行在窗口内的变更合并后投递')}">${t('合并 {0}s', task.compact_window)}</span>` : ''}
+                ${task.max_lag_seconds > 0 || task.max_lag_events > 0 ? `<span class="status-badge status-lag-guard" title="${t('超过延迟限制时的处理方式')}: ${task.lag_action || 'alert'}">${t('延迟保护')}</span>` : ''}
+                ${task.payload_mapping ? `<span class="status-badge status-mapping" title="${t('按映射规则改写载荷')}">${t('映射')}</span>` : ''}
+                ${task.row_filter ? `<span class="status-badge status-row-filter" title="${t('只投递满足行过滤条件的事件')}">${t('行过滤')}</span>` : ''}
+                ${task.sample_percent > 0 || task.sample_interval > 0 ? `<span class="status-badge status-sampling" title="${t('只投递采样的事件')}">${t('采样')}</span>` : ''}
+                ${task.last_error ? `<span class="error-text" title="${escapeHTML(task.last_error)} (${formatDateTime(task.last_error_at)})">⚠️</span>` : ''}
+            </td>
+            <td>
+                <button class="btn btn-small btn-secondary" onclick="showTaskDetail(${task.id})">${t('详情')}</button>
+                ${session.can_mutate ? `
+                <button class="btn btn-small btn-secondary" onclick="editTask(${task.id})">${t('编辑')}</button>
+                ${task.status === 'active' ? `<button class="btn btn-small btn-secondary" onclick="restartTask(${task.id})" title="${t('重新连接源库并重建表结构缓存')}">${t('重启')}</button>` : ''}
+                <button class="btn btn-small btn-danger" onclick="deleteTask(${task.id})">${t('删除')}</button>
+                ` : ''}
             </td>
         `;
         tbody.appendChild(row);
@@ -103,8 +201,17 @@ function renderTasksTable(tasks) {
 // 加载事件日志
 async function loadEventLogs(page = 1) {
     try {
-        const taskId = document.getElementById('taskFilter').value;
-        const url = `/api/logs?page=${page}&page_size=20${taskId ? '&task_id=' + taskId : ''}`;
+        const params = new URLSearchParams({ page: page, page_size: 20 });
+        const filters = {
+            task_id: document.getElementById('taskFilter').value,
+            event_type: document.getElementById('eventTypeFilter').value,
+            status: document.getElementById('statusFilter').value,
+            q: document.getElementById('logSearch').value.trim()
+        };
+        Object.entries(filters).forEach(([key, value]) => {
+            if (value) params.append(key, value);
+        });
+        const url = `/api/v1/logs?${params.toString()}`;
         
         const response = await fetch(url);
         const result = await response.json();
@@ -113,10 +220,10 @@ async function loadEventLogs(page = 1) {
             renderLogsTable(result.data.logs);
             renderPagination('logsPagination', result.data.page, Math.ceil(result.data.total / result.data.page_size), loadEventLogs);
         } else {
-            showError('加载事件日志失败: ' + result.error);
+            showError(t('加载事件日志失败: ') + result.error);
         }
     } catch (error) {
-        showError('网络错误: ' + error.message);
+        showError(t('网络错误: ') + error.message);
     }
 }
 
@@ -126,7 +233,7 @@ function renderLogsTable(logs) {
     tbody.innerHTML = '';
     
     if (!logs || logs.length === 0) {
-        tbody.innerHTML = '<tr><td colspan="8" style="text-align: center; color: #666;">暂无数据</td></tr>';
+        tbody.innerHTML = `<tr><td colspan="8" style="text-align: center; color: #666;">${t('暂无数据')}</td></tr>`;
         return;
     }
     
@@ -142,7 +249,8 @@ function renderLogsTable(logs) {
             <td><span class="status-badge status-${log.status}">${getStatusText(log.status)}</span></td>
             <td>${formatDateTime(log.created_at)}</td>
             <td>
-                <button class="btn btn-small btn-secondary" onclick="viewLogDetail(${log.id})">详情</button>
+                <button class="btn btn-small btn-secondary" onclick="viewLogDetail(${log.id})">${t('详情')}</button>
+                ${session.can_mutate ? `<button class="btn btn-small btn-secondary" onclick="redeliverEvent(${log.id})">${t('重新投递')}</button>` : ''}
             </td>
         `;
         tbody.appendChild(row);
@@ -152,13 +260,18 @@ function renderLogsTable(logs) {
 // 加载系统状态
 async function loadSystemStatus() {
     try {
-        const response = await fetch('/api/status');
+        const response = await fetch('/api/v1/status');
         const result = await response.json();
         
         if (response.ok) {
             document.getElementById('activeTasksCount').textContent = result.data.active_tasks;
-            document.getElementById('systemStatus').textContent = result.data.status === 'running' ? '运行中' : '停止';
+            document.getElementById('systemStatus').textContent = result.data.status === 'running' ? t('运行中') : t('停止');
             document.getElementById('systemVersion').textContent = result.data.version;
+            renderErrorsTable(result.data.instance_errors, result.data.task_errors);
+            renderMaintenanceBanner(result.data.maintenance);
+            if (session.logged_in) {
+                loadActivities();
+            }
             
             // 更新状态指示器
             const statusDot = document.querySelector('.status-dot');
@@ -168,22 +281,129 @@ async function loadSystemStatus() {
                 statusDot.classList.remove('active');
             }
         } else {
-            showError('加载系统状态失败: ' + result.error);
+            showError(t('加载系统状态失败: ') + result.error);
+        }
+    } catch (error) {
+        showError(t('网络错误: ') + error.message);
+    }
+}
+
+// 渲染维护暂停横幅
+function renderMaintenanceBanner(maintenance) {
+    const banner = document.getElementById('maintenanceBanner');
+    if (!maintenance || !maintenance.paused) {
+        banner.style.display = 'none';
+        return;
+    }
+
+    // 全局暂停优先显示，解除后再显示按源库的暂停
+    const source = maintenance.global ? '' : Object.keys(maintenance.sources)[0];
+    const pause = maintenance.global || maintenance.sources[source];
+    const scope = source || t('全部源库');
+    let text = t('⏸️ 维护暂停中（{0}），自 {1} 起停止读取 binlog', scope, formatDateTime(pause.since));
+    if (pause.reason) {
+        text += `：${pause.reason}`;
+    }
+    document.getElementById('maintenanceText').textContent = text;
+    banner.dataset.source = source;
+    banner.style.display = 'flex';
+}
+
+// 解除维护暂停
+async function resumeReading() {
+    if (!confirm(t('确定要恢复读取 binlog 吗？'))) {
+        return;
+    }
+
+    try {
+        const source = document.getElementById('maintenanceBanner').dataset.source;
+        const response = await fetch('/api/v1/admin/resume', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify(source ? { source } : {})
+        });
+        const result = await response.json();
+
+        if (response.ok) {
+            loadSystemStatus();
+            showSuccess(result.message);
+        } else {
+            showError(t('恢复读取失败: ') + result.error);
         }
     } catch (error) {
-        showError('网络错误: ' + error.message);
+        showError(t('网络错误: ') + error.message);
+    }
+}
+
+// 渲染最近错误表格
+function renderErrorsTable(instanceErrors, taskErrors) {
+    const tbody = document.getElementById('errorsTableBody');
+    tbody.innerHTML = '';
+    
+    const rows = [];
+    // 运行中实例的当前错误
+    for (const [id, err] of Object.entries(instanceErrors || {})) {
+        rows.push({ source: id, message: err.error_msg, time: err.error_at });
+    }
+    // 任务记录中持久化的错误
+    (taskErrors || []).forEach(err => {
+        rows.push({ source: `${err.task_name} (#${err.task_id})`, message: err.last_error, time: err.last_error_at });
+    });
+    
+    if (rows.length === 0) {
+        tbody.innerHTML = `<tr><td colspan="3" style="text-align: center; color: #666;">${t('暂无错误')}</td></tr>`;
+        return;
+    }
+    
+    rows.forEach(item => {
+        const row = document.createElement('tr');
+        row.innerHTML = `
+            <td>${escapeHTML(item.source)}</td>
+            <td class="error-text">${escapeHTML(item.message)}</td>
+            <td>${item.time ? formatDateTime(item.time) : '-'}</td>
+        `;
+        tbody.appendChild(row);
+    });
+}
+
+// 加载当前用户的最近操作
+async function loadActivities() {
+    try {
+        const response = await fetch('/api/v1/session');
+        const result = await response.json();
+        if (!response.ok) {
+            return;
+        }
+
+        const tbody = document.getElementById('activitiesTableBody');
+        const activities = result.data.activities || [];
+        if (activities.length === 0) {
+            tbody.innerHTML = `<tr><td colspan="3" style="text-align: center; color: #666;">${t('暂无数据')}</td></tr>`;
+            return;
+        }
+        tbody.innerHTML = activities.map(a => `
+            <tr>
+                <td>${formatDateTime(a.time)}</td>
+                <td>${a.action}</td>
+                <td>${a.status || '-'}</td>
+            </tr>
+        `).join('');
+    } catch (error) {
+        console.error('获取最近操作失败:', error);
     }
 }
 
 // 加载任务列表用于过滤器
 async function loadTasksForFilter() {
     try {
-        const response = await fetch('/api/tasks?page=1&page_size=100');
+        const response = await fetch('/api/v1/tasks?page=1&page_size=100');
         const result = await response.json();
         
         if (response.ok) {
             const select = document.getElementById('taskFilter');
-            select.innerHTML = '<option value="">所有任务</option>';
+            select.innerHTML = `<option value="">${t('所有任务')}</option>`;
             
             result.data.tasks.forEach(task => {
                 const option = document.createElement('option');
@@ -215,7 +435,7 @@ async function createTask() {
     
     // 获取选中的事件类型
     const eventTypes = [];
-    document.querySelectorAll('input[type="checkbox"]:checked').forEach(cb => {
+    document.querySelectorAll('.checkbox-group input[type="checkbox"]:checked').forEach(cb => {
         eventTypes.push(cb.value);
     });
     
@@ -224,11 +444,37 @@ async function createTask() {
         database: formData.get('database'),
         table: formData.get('table'),
         event_types: eventTypes.join(','),
-        callback_url: formData.get('callback_url')
+        callback_url: formData.get('callback_url'),
+        exclude_tables: formData.get('exclude_tables') || '',
+        request_timeout: parseInt(formData.get('request_timeout')) || 0,
+        delivery_timeout: parseInt(formData.get('delivery_timeout')) || 0,
+        shutdown_timeout: parseInt(formData.get('shutdown_timeout')) || 0,
+        schedule: formData.get('schedule') || '',
+        schedule_mode: formData.get('schedule_mode') || 'pause',
+        schedule_rate: parseInt(formData.get('schedule_rate')) || 0,
+        priority: parseInt(formData.get('priority')) || 0,
+        sample_percent: parseFloat(formData.get('sample_percent')) || 0,
+        sample_interval: parseInt(formData.get('sample_interval')) || 0,
+        compact_window: parseInt(formData.get('compact_window')) || 0,
+        delete_mode: formData.get('delete_mode') || 'before',
+        payload_mapping: formData.get('payload_mapping') || '',
+        row_filter: (formData.get('row_filter') || '').trim(),
+        source_name: (formData.get('source_name') || '').trim(),
+        environment: (formData.get('environment') || '').trim(),
+        user_agent: (formData.get('user_agent') || '').trim(),
+        max_lag_seconds: parseInt(formData.get('max_lag_seconds')) || 0,
+        max_lag_events: parseInt(formData.get('max_lag_events')) || 0,
+        lag_action: formData.get('lag_action') || 'alert',
+        lag_sample: parseFloat(formData.get('lag_sample')) || 0,
+        max_event_age: parseInt(formData.get('max_event_age')) || 0,
+        expired_action: formData.get('expired_action') || 'dlq',
+        quality_rules: (formData.get('quality_rules') || '').trim(),
+        quarantine_url: (formData.get('quarantine_url') || '').trim(),
+        dry_run: document.getElementById('taskDryRun').checked
     };
     
     try {
-        const response = await fetch('/api/tasks', {
+        const response = await fetch('/api/v1/tasks', {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json'
@@ -238,26 +484,55 @@ async function createTask() {
         
         const result = await response.json();
         
-        if (response.ok) {
+        if (response.status === 202) {
+            // 任务已创建，实例由后台重试启动
             hideCreateTaskModal();
             loadTasks();
-            showSuccess('任务创建成功');
+            showInfo(result.message);
+        } else if (response.ok) {
+            hideCreateTaskModal();
+            loadTasks();
+            showSuccess(t('任务创建成功'));
         } else {
-            showError('创建任务失败: ' + result.error);
+            showError(t('创建任务失败: ') + result.error);
         }
     } catch (error) {
-        showError('网络错误: ' + error.message);
+        showError(t('网络错误: ') + error.message);
+    }
+}
+
+// 重启任务实例
+async function restartTask(id) {
+    if (!confirm(t('确定要重启这个任务的实例吗？将重新连接源库并从保存的位置继续读取。'))) {
+        return;
+    }
+
+    try {
+        const response = await fetch(`/api/v1/tasks/${id}/restart`, {
+            method: 'POST'
+        });
+
+        const result = await response.json();
+
+        if (response.ok) {
+            loadTasks();
+            showSuccess(t('任务实例已重启'));
+        } else {
+            showError(t('重启任务失败: ') + result.error);
+        }
+    } catch (error) {
+        showError(t('网络错误: ') + error.message);
     }
 }
 
 // 删除任务
 async function deleteTask(id) {
-    if (!confirm('确定要删除这个任务吗？')) {
+    if (!confirm(t('确定要删除这个任务吗？'))) {
         return;
     }
     
     try {
-        const response = await fetch(`/api/tasks/${id}`, {
+        const response = await fetch(`/api/v1/tasks/${id}`, {
             method: 'DELETE'
         });
         
@@ -265,12 +540,12 @@ async function deleteTask(id) {
         
         if (response.ok) {
             loadTasks();
-            showSuccess('任务删除成功');
+            showSuccess(result.message);
         } else {
-            showError('删除任务失败: ' + result.error);
+            showError(t('删除任务失败: ') + result.error);
         }
     } catch (error) {
-        showError('网络错误: ' + error.message);
+        showError(t('网络错误: ') + error.message);
     }
 }
 
@@ -279,21 +554,129 @@ async function editTask(id) {
     console.log('editTask called with id:', id);
     try {
         // 获取任务详细信息
-        const response = await fetch(`/api/tasks/${id}`);
+        const response = await fetch(`/api/v1/tasks/${id}`);
         const result = await response.json();
         
         if (!response.ok) {
-            showError('获取任务信息失败: ' + result.error);
+            showError(t('获取任务信息失败: ') + result.error);
             return;
         }
         
         // 显示编辑表单
         showEditTaskForm(result.data);
     } catch (error) {
-        showError('网络错误: ' + error.message);
+        showError(t('网络错误: ') + error.message);
+    }
+}
+
+// 显示任务详情
+async function showTaskDetail(id) {
+    try {
+        const [taskResponse, statusResponse] = await Promise.all([
+            fetch(`/api/v1/tasks/${id}`),
+            fetch('/api/v1/status')
+        ]);
+        const taskResult = await taskResponse.json();
+        const statusResult = await statusResponse.json();
+
+        if (!taskResponse.ok) {
+            showError(t('获取任务信息失败: ') + taskResult.error);
+            return;
+        }
+
+        const task = taskResult.data;
+        document.getElementById('taskDetailName').textContent = `${task.name} (#${task.id})`;
+        document.getElementById('taskDetailStatus').textContent = getStatusText(task.status);
+        const sinks = statusResponse.ok ? (statusResult.data.sinks || {})[`task-${id}`] : null;
+        renderTaskSinksTable(sinks);
+        startTaskLogStream(id);
+
+        document.getElementById('taskDetailModal').style.display = 'block';
+    } catch (error) {
+        showError(t('网络错误: ') + error.message);
     }
 }
 
+// 渲染任务各 sink 的投递状态
+function renderTaskSinksTable(sinks) {
+    const tbody = document.querySelector('#taskSinksTable tbody');
+    tbody.innerHTML = '';
+
+    if (!sinks || sinks.length === 0) {
+        tbody.innerHTML = `<tr><td colspan="7" style="text-align: center; color: #666;">${t('任务未运行，暂无投递状态')}</td></tr>`;
+        return;
+    }
+
+    sinks.forEach(sink => {
+        const row = document.createElement('tr');
+        row.innerHTML = `
+            <td>${sink.name}</td>
+            <td>${sink.delivered}</td>
+            <td>${sink.pending}</td>
+            <td>${sink.failed}</td>
+            <td>${sink.dlq}</td>
+            <td class="error-text" title="${escapeHTML(sink.last_error)}">${sink.last_error ? `${escapeHTML(truncateUrl(sink.last_error, 40))} (${formatDateTime(sink.last_error_at)})` : '-'}</td>
+            <td>${sink.last_success_at ? formatDateTime(sink.last_success_at) : '-'}</td>
+        `;
+        tbody.appendChild(row);
+    });
+}
+
+// 任务详情中打开的日志流
+let taskLogStream = null;
+
+// 任务日志面板最多显示的行数
+const maxTaskLogLines = 500;
+
+// 订阅任务的实时日志，先收到最近的日志，之后逐行追加
+function startTaskLogStream(id) {
+    stopTaskLogStream();
+    const element = document.getElementById('taskDetailLogs');
+    const state = document.getElementById('taskLogsState');
+    element.textContent = '';
+    state.textContent = t('连接中...');
+
+    const stream = new EventSource(`/api/v1/tasks/${id}/logs/stream`);
+    taskLogStream = stream;
+    stream.onopen = () => {
+        state.textContent = t('已连接');
+    };
+    stream.addEventListener('log', event => {
+        const entry = JSON.parse(event.data);
+        // 已滚动到底部时跟随新行
+        const follow = element.scrollTop + element.clientHeight >= element.scrollHeight - 5;
+        const lines = element.textContent ? element.textContent.split('\n') : [];
+        lines.push(entry.message);
+        element.textContent = lines.slice(-maxTaskLogLines).join('\n');
+        if (follow) {
+            element.scrollTop = element.scrollHeight;
+        }
+    });
+    // 断开后浏览器自动重连，重连时重新收到最近的日志；请求被拒绝时不再重连
+    stream.onerror = () => {
+        if (stream.readyState === EventSource.CLOSED) {
+            state.textContent = t('无法获取日志');
+            return;
+        }
+        state.textContent = t('连接断开，正在重连...');
+        element.textContent = '';
+    };
+}
+
+// 关闭任务的日志流
+function stopTaskLogStream() {
+    if (taskLogStream) {
+        taskLogStream.close();
+        taskLogStream = null;
+    }
+}
+
+// 隐藏任务详情模态框
+function hideTaskDetailModal() {
+    stopTaskLogStream();
+    document.getElementById('taskDetailModal').style.display = 'none';
+}
+
 // 渲染分页
 function renderPagination(containerId, currentPage, totalPages, loadFunction) {
     const container = document.getElementById(containerId);
@@ -303,7 +686,7 @@ function renderPagination(containerId, currentPage, totalPages, loadFunction) {
     
     // 上一页按钮
     const prevBtn = document.createElement('button');
-    prevBtn.textContent = '上一页';
+    prevBtn.textContent = t('上一页');
     prevBtn.disabled = currentPage === 1;
     prevBtn.onclick = () => loadFunction(currentPage - 1);
     container.appendChild(prevBtn);
@@ -322,7 +705,7 @@ function renderPagination(containerId, currentPage, totalPages, loadFunction) {
     
     // 下一页按钮
     const nextBtn = document.createElement('button');
-    nextBtn.textContent = '下一页';
+    nextBtn.textContent = t('下一页');
     nextBtn.disabled = currentPage === totalPages;
     nextBtn.onclick = () => loadFunction(currentPage + 1);
     container.appendChild(nextBtn);
@@ -335,18 +718,19 @@ function truncateUrl(url, maxLength = 30) {
 
 function getStatusText(status) {
     const statusMap = {
-        'active': '活跃',
-        'inactive': '停用',
-        'pending': '等待中',
-        'success': '成功',
-        'failed': '失败'
+        'active': t('活跃'),
+        'inactive': t('停用'),
+        'pending': t('等待中'),
+        'success': t('成功'),
+        'failed': t('失败'),
+        'dry_run': t('演练')
     };
     return statusMap[status] || status;
 }
 
 function formatDateTime(dateString) {
     const date = new Date(dateString);
-    return date.toLocaleString('zh-CN');
+    return date.toLocaleString(i18n.language);
 }
 
 function showSuccess(message) {
@@ -403,6 +787,12 @@ window.onclick = function(event) {
     if (event.target === modal) {
         hideCreateTaskModal();
     }
+    if (event.target === document.getElementById('editPositionModal')) {
+        hideEditPositionModal();
+    }
+    if (event.target === document.getElementById('taskDetailModal')) {
+        hideTaskDetailModal();
+    }
 }
 
 // 添加CSS动画
@@ -420,27 +810,228 @@ style.textContent = `
 `;
 document.head.appendChild(style);
 
-// Binlog 监控功能
-function loadBinlogInfo() {
-    fetch('/api/binlog/info')
-        .then(response => response.json())
-        .then(data => {
-            if (data.data) {
-                document.getElementById('binlogStatus').textContent = data.data.log_bin || '-';
-                document.getElementById('binlogFormat').textContent = data.data.binlog_format || '-';
-                document.getElementById('currentFile').textContent = data.data.current_file || '-';
-                document.getElementById('currentPos').textContent = data.data.current_pos || '-';
+// Binlog 位置管理：源库最新位置、各任务的当前和保存的位置及延迟
+async function loadPositions() {
+    try {
+        const response = await fetch('/api/v1/positions');
+        const result = await response.json();
+
+        if (response.ok) {
+            renderSourceStatus(result.data.source, result.data.source_error);
+            renderPositionsTable(result.data.tasks);
+        } else {
+            showError(t('获取binlog位置失败: ') + result.error);
+        }
+    } catch (error) {
+        showError(t('网络错误: ') + error.message);
+    }
+}
+
+// 渲染源库状态，查询失败时显示原因
+function renderSourceStatus(source, sourceError) {
+    const errorEl = document.getElementById('sourceError');
+    if (!source) {
+        document.getElementById('sourcePosition').textContent = '-';
+        document.getElementById('sourceBinaryLogs').textContent = '-';
+        document.getElementById('sourceGTIDSet').textContent = '';
+        errorEl.textContent = t('查询源库状态失败: ') + (sourceError || '');
+        errorEl.style.display = 'block';
+        return;
+    }
+
+    errorEl.style.display = 'none';
+    document.getElementById('sourcePosition').textContent = formatPosition(source);
+    const logs = source.binary_logs || [];
+    document.getElementById('sourceBinaryLogs').textContent = logs.length
+        ? `${logs.length} (${logs[0].name} ~ ${logs[logs.length - 1].name})`
+        : '0';
+    document.getElementById('sourceGTIDSet').textContent = source.executed_gtid_set ? `GTID: ${source.executed_gtid_set}` : '';
+}
+
+// 渲染各任务的位置
+function renderPositionsTable(tasks) {
+    const tbody = document.getElementById('positionsTableBody');
+    tbody.innerHTML = '';
+
+    tasks.forEach(task => {
+        let runningText = task.running ? t('运行中') : t('已停止');
+        if (task.alert) {
+            runningText += ` <span class="status-badge status-failed">⚠️ ${task.alert}</span>`;
+        }
+
+        let lagText = `${task.lag_seconds.toFixed(1)}s`;
+        if (task.lag) {
+            lagText += ` / ${formatBytes(task.lag.bytes)}`;
+            if (task.lag.files > 0) {
+                lagText += ` (${t('{0} 个文件', task.lag.files)})`;
             }
-        })
-        .catch(error => {
-            console.error('获取binlog信息失败:', error);
-            showNotification('获取binlog信息失败', 'error');
+        } else if (task.lag_error) {
+            lagText += ` <span class="error-text" title="${task.lag_error}">⚠️</span>`;
+        }
+
+        const row = document.createElement('tr');
+        row.innerHTML = `
+            <td>${task.task_id} - ${task.task_name} <span class="status-badge status-${task.task_status}">${getStatusText(task.task_status)}</span></td>
+            <td>${runningText}</td>
+            <td class="url-text">${task.current ? formatPosition(task.current) : '-'}</td>
+            <td class="url-text" title="${task.saved.gtid_set || ''}">${task.saved.name ? formatPosition(task.saved) : '-'}</td>
+            <td>${lagText}</td>
+            ${session.can_mutate ? `
+            <td>
+                <button class="btn btn-small btn-secondary" onclick='showEditPositionModal(${task.task_id}, ${JSON.stringify(task.saved)})'>${t('修改')}</button>
+                <button class="btn btn-small btn-secondary" onclick="resetPosition(${task.task_id}, 'earliest')">${t('重置到最早位置')}</button>
+                <button class="btn btn-small btn-secondary" onclick="resetPosition(${task.task_id}, 'latest')">${t('重置到最新位置')}</button>
+            </td>
+            ` : ''}
+        `;
+        tbody.appendChild(row);
+    });
+}
+
+// 变更分析：时间窗口内变更最多的表
+async function loadTableAnalytics() {
+    const range = document.getElementById('analyticsWindow').value;
+    try {
+        const response = await fetch(`/api/v1/analytics/tables?window=${range}&limit=20`);
+        const result = await response.json();
+
+        if (response.ok) {
+            renderAnalyticsTable(result.data.tables);
+        } else {
+            showError(t('获取变更统计失败: ') + result.error);
+        }
+    } catch (error) {
+        showError(t('网络错误: ') + error.message);
+    }
+}
+
+// 渲染各表的变更数和变更频率
+function renderAnalyticsTable(tables) {
+    const tbody = document.getElementById('analyticsTableBody');
+    tbody.innerHTML = '';
+
+    if (tables.length === 0) {
+        tbody.innerHTML = `<tr><td colspan="7" style="text-align: center; color: #666;">${t('暂无数据')}</td></tr>`;
+        return;
+    }
+
+    tables.forEach(table => {
+        const row = document.createElement('tr');
+        row.innerHTML = `
+            <td>${table.database}.${table.table}</td>
+            <td>${table.inserts}</td>
+            <td>${table.updates}</td>
+            <td>${table.deletes}</td>
+            <td>${table.total}</td>
+            <td>${table.per_minute.toFixed(2)}</td>
+            <td>${table.peak_per_minute.toFixed(2)}</td>
+        `;
+        tbody.appendChild(row);
+    });
+}
+
+// 格式化 binlog 位置，兼容源库状态（file/pos）和任务位置（name/pos）
+function formatPosition(pos) {
+    return `${pos.name || pos.file}:${pos.pos}`;
+}
+
+function formatBytes(bytes) {
+    const units = ['B', 'KB', 'MB', 'GB'];
+    let value = bytes;
+    let i = 0;
+    while (value >= 1024 && i < units.length - 1) {
+        value /= 1024;
+        i++;
+    }
+    return i === 0 ? `${value} B` : `${value.toFixed(1)} ${units[i]}`;
+}
+
+// 显示修改位置模态框，默认填入保存的位置
+function showEditPositionModal(taskId, saved) {
+    document.getElementById('editPositionForm').reset();
+    document.getElementById('positionTaskId').value = taskId;
+    document.getElementById('positionName').value = saved.name || '';
+    document.getElementById('positionPos').value = saved.pos || 4;
+    document.getElementById('positionGTIDSet').value = saved.gtid_set || '';
+    document.getElementById('editPositionModal').style.display = 'block';
+}
+
+// 隐藏修改位置模态框
+function hideEditPositionModal() {
+    document.getElementById('editPositionModal').style.display = 'none';
+}
+
+// 保存修改的位置
+async function savePosition() {
+    const taskId = document.getElementById('positionTaskId').value;
+    const data = {
+        name: document.getElementById('positionName').value.trim(),
+        pos: parseInt(document.getElementById('positionPos').value, 10),
+        gtid_set: document.getElementById('positionGTIDSet').value.trim()
+    };
+    if (!data.name || !(data.pos >= 4)) {
+        showError(t('请填写binlog文件和不小于 4 的偏移'));
+        return;
+    }
+    if (!confirm(t('任务 {0} 的实例将停止并投递完缓冲中的事件，然后从 {1} 重新开始读取，可能跳过或重复投递部分事件。确定继续吗？', taskId, `${data.name}:${data.pos}`))) {
+        return;
+    }
+
+    try {
+        const response = await fetch(`/api/v1/tasks/${taskId}/position`, {
+            method: 'PUT',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify(data)
+        });
+        const result = await response.json();
+
+        if (response.ok) {
+            hideEditPositionModal();
+            loadPositions();
+            showSuccess(result.message);
+        } else {
+            showError(t('修改binlog位置失败: ') + result.error);
+        }
+    } catch (error) {
+        showError(t('网络错误: ') + error.message);
+    }
+}
+
+// 把任务位置重置到源库最早或最新的位置
+async function resetPosition(taskId, action) {
+    const message = action === 'earliest'
+        ? t('任务 {0} 将从源库最早可读取的位置重新开始，之前的变更会再次投递。确定继续吗？', taskId)
+        : t('任务 {0} 将跳到源库最新位置，尚未读取的变更不会投递。确定继续吗？', taskId);
+    if (!confirm(message)) {
+        return;
+    }
+
+    try {
+        const response = await fetch(`/api/v1/tasks/${taskId}/position/reset`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ action: action })
         });
+        const result = await response.json();
+
+        if (response.ok) {
+            loadPositions();
+            showSuccess(result.message);
+        } else {
+            showError(t('重置binlog位置失败: ') + result.error);
+        }
+    } catch (error) {
+        showError(t('网络错误: ') + error.message);
+    }
 }
 
 // 性能指标监控功能
 function loadMetrics() {
-    fetch('/api/metrics')
+    fetch('/api/v1/metrics')
         .then(response => response.json())
         .then(data => {
             if (data.data) {
@@ -466,7 +1057,7 @@ function loadMetrics() {
                     if (canalStatus.connection_pool) {
                         const pool = canalStatus.connection_pool;
                         document.getElementById('connectionPoolStatus').textContent =
-                            `${pool.available}/${pool.max_size} (由${pool.managed_by}管理)`;
+                            t('{0}/{1} (由{2}管理)', pool.available, pool.max_size, pool.managed_by);
                     }
                     
                     // 实例数量
@@ -478,12 +1069,12 @@ function loadMetrics() {
                     if (canalStatus.memory_usage) {
                         const memory = canalStatus.memory_usage;
                         document.getElementById('memoryUsage').textContent =
-                            `${memory.instances}个实例 (${memory.status})`;
+                            t('{0}个实例 ({1})', memory.instances, memory.status);
                     }
                     
                     // 运行状态
                     document.getElementById('runningStatus').textContent =
-                        canalStatus.running ? '运行中' : '已停止';
+                        canalStatus.running ? t('运行中') : t('已停止');
                         
                     // 更新实例详情表
                     console.log('准备更新实例详情表:', canalStatus.instances);
@@ -497,7 +1088,7 @@ function loadMetrics() {
         })
         .catch(error => {
             console.error('获取性能指标失败:', error);
-            showNotification('获取性能指标失败', 'error');
+            showNotification(t('获取性能指标失败'), 'error');
         });
 }
 
@@ -530,7 +1121,7 @@ function updateInstancesTable(instances) {
                 // 尝试解析日期
                 const date = new Date(instance.last_event);
                 if (!isNaN(date.getTime())) {
-                    lastEventText = date.toLocaleString('zh-CN');
+                    lastEventText = date.toLocaleString(i18n.language);
                 }
             } catch (e) {
                 console.error('日期解析错误:', e);
@@ -538,24 +1129,106 @@ function updateInstancesTable(instances) {
             }
         }
         
+        // binlog 被清除时显示告警和恢复操作
+        let runningText = instance.running ? t('运行中') : t('已停止');
+        const recovery = instance.recovery;
+        if (instance.alert === 'binlog_purged' && recovery && recovery.state === 'running') {
+            // 快照在后台进行时显示进度
+            runningText = `<span class="status-badge status-pending">📸 ${t('快照中')} ${recovery.tables_done}/${recovery.tables} ${escapeHTML(recovery.table)} (${recovery.rows})</span>`;
+        } else if (instance.alert === 'binlog_purged') {
+            const taskId = id.replace('task-', '');
+            const failed = recovery && recovery.state === 'failed' ? ` <span class="error-text" title="${escapeHTML(recovery.error)}">${t('上次恢复失败')}</span>` : '';
+            runningText = `${failed}
+                <span class="status-badge status-failed" title="${escapeHTML(instance.error_msg)}">⚠️ ${t('Binlog已被清除')}</span>
+                <div class="recover-actions" style="${session.can_mutate ? '' : 'display: none;'}">
+                    <button class="btn btn-small btn-secondary" onclick="recoverTask(${taskId}, 'earliest')">${t('跳到最早位置')}</button>
+                    <button class="btn btn-small btn-secondary" onclick="recoverTask(${taskId}, 'latest')">${t('跳到最新位置')}</button>
+                    <button class="btn btn-small btn-primary" onclick="recoverTask(${taskId}, 'snapshot')">${t('快照重新同步')}</button>
+                </div>
+            `;
+        }
+        
+        // 显示最近错误及时间
+        if (instance.error_msg && instance.alert !== 'binlog_purged') {
+            runningText += ` <span class="error-text" title="${escapeHTML(instance.error_msg)}">⚠️ ${formatDateTime(instance.error_at)}</span>`;
+        }
+        
         row.innerHTML = `
             <td>${id}</td>
-            <td>${instance.running ? '运行中' : '已停止'}</td>
+            <td>${runningText}</td>
             <td>${positionText}</td>
             <td>${lastEventText}</td>
         `;
         tableBody.appendChild(row);
     }
 }
+// 重新投递事件
+async function redeliverEvent(id) {
+    const url = prompt('重新投递到 (留空使用任务当前的回调地址):', '');
+    if (url === null) {
+        return;
+    }
+
+    try {
+        const response = await fetch(`/api/v1/logs/${id}/redeliver`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify(url.trim() ? { url: url.trim() } : {})
+        });
+
+        const result = await response.json();
+
+        if (response.ok) {
+            loadEventLogs();
+            showSuccess(t('重新投递成功'));
+        } else {
+            showError(t('重新投递失败: ') + result.error);
+        }
+    } catch (error) {
+        showError(t('网络错误: ') + error.message);
+    }
+}
+
+// 恢复 binlog 被清除的任务
+async function recoverTask(id, action) {
+    if (!confirm(t('恢复操作可能会丢失或重复部分数据，确定继续吗？'))) {
+        return;
+    }
+    
+    try {
+        const response = await fetch(`/api/v1/tasks/${id}/recover`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ action: action })
+        });
+        
+        const result = await response.json();
+        
+        if (response.ok) {
+            loadMetrics();
+            showSuccess(result.message || t('任务恢复成功'));
+        } else {
+            showError(t('还原任务失败: ') + result.error);
+        }
+    } catch (error) {
+        showError(t('网络错误: ') + error.message);
+    }
+}
+
 // 自动刷新监控数据
 function startMonitoring() {
     // 每30秒自动刷新一次监控数据
     setInterval(() => {
         const activeTab = document.querySelector('.tab-btn.active').dataset.tab;
-        // if (activeTab === 'binlog') {
-        //     loadBinlogInfo();
-        // } else
-        if (activeTab === 'metrics') {
+        if (activeTab === 'binlog') {
+            loadPositions();
+        } else if (activeTab === 'analytics') {
+            loadTableAnalytics();
+        } else if (activeTab === 'metrics') {
             loadMetrics();
         }
     }, 30000);
@@ -574,37 +1247,124 @@ function showEditTaskForm(task) {
     modal.innerHTML = `
         <div class="modal-content">
             <span class="close">&times;</span>
-            <h2>编辑任务</h2>
+            <h2>${t('编辑任务')}</h2>
             <form id="editTaskForm">
                 <input type="hidden" id="editTaskId" value="${task.id}">
                 <div class="form-group">
-                    <label for="editTaskName">任务名称:</label>
+                    <label for="editTaskName">${t('任务名称:')}</label>
                     <input type="text" id="editTaskName" value="${task.name}" required>
                 </div>
                 <div class="form-group">
-                    <label for="editTaskDatabase">数据库:</label>
+                    <label for="editTaskDatabase">${t('数据库:')}</label>
                     <input type="text" id="editTaskDatabase" value="${task.database}" required>
                 </div>
                 <div class="form-group">
-                    <label for="editTaskTable">表名:</label>
+                    <label for="editTaskTable">${t('表名:')}</label>
                     <input type="text" id="editTaskTable" value="${task.table}" required>
                 </div>
                 <div class="form-group">
-                    <label for="editTaskEventTypes">事件类型:</label>
+                    <label for="editTaskEventTypes">${t('事件类型:')}</label>
                     <input type="text" id="editTaskEventTypes" value="${task.event_types}" required>
                 </div>
                 <div class="form-group">
-                    <label for="editTaskCallbackURL">回调URL:</label>
+                    <label for="editTaskCallbackURL">${t('回调URL:')}</label>
                     <input type="text" id="editTaskCallbackURL" value="${task.callback_url}" required>
                 </div>
                 <div class="form-group">
-                    <label for="editTaskStatus">状态:</label>
+                    <label for="editTaskExcludeTables">${t('排除表:')}</label>
+                    <input type="text" id="editTaskExcludeTables" value="${task.exclude_tables || ''}" placeholder="*_tmp,migrations">
+                </div>
+                <div class="form-group">
+                    <label>${t('投递超时（秒，留空为默认值）:')}</label>
+                    <input type="number" id="editTaskRequestTimeout" min="1" value="${task.request_timeout || ''}" placeholder="${t('单次请求 30')}">
+                    <input type="number" id="editTaskDeliveryTimeout" min="1" value="${task.delivery_timeout || ''}" placeholder="${t('总超时 60')}">
+                    <input type="number" id="editTaskShutdownTimeout" min="1" value="${task.shutdown_timeout || ''}" placeholder="${t('停止刷新 30')}">
+                </div>
+                <div class="form-group">
+                    <label for="editTaskSchedule">${t('维护窗口:')}</label>
+                    <input type="text" id="editTaskSchedule" value="${task.schedule || ''}" placeholder="Mon-Fri 09:00-18:00; Sat,Sun 22:00-06:00">
+                    <select id="editTaskScheduleMode">
+                        <option value="pause" ${task.schedule_mode !== 'throttle' ? 'selected' : ''}>${t('窗口内暂停')}</option>
+                        <option value="throttle" ${task.schedule_mode === 'throttle' ? 'selected' : ''}>${t('窗口内限速')}</option>
+                    </select>
+                    <input type="number" id="editTaskScheduleRate" min="1" value="${task.schedule_rate || ''}" placeholder="${t('限速（事件/秒）')}">
+                </div>
+                <div class="form-group">
+                    <label for="editTaskPriority">${t('优先级（1-100，留空为 1）:')}</label>
+                    <input type="number" id="editTaskPriority" min="1" max="100" value="${task.priority || ''}" placeholder="1">
+                </div>
+                <div class="form-group">
+                    <label for="editTaskSamplePercent">${t('事件采样（百分比和同一行最小间隔秒数，留空或 0 为不采样）:')}</label>
+                    <input type="number" id="editTaskSamplePercent" min="0" max="100" step="0.01" value="${task.sample_percent || ''}" placeholder="${t('保留的百分比')}">
+                    <input type="number" id="editTaskSampleInterval" min="0" value="${task.sample_interval || ''}" placeholder="${t('同一行最小间隔（秒）')}">
+                </div>
+                <div class="form-group">
+                    <label for="editTaskCompactWindow">${t('合并窗口（秒，留空或 0 为不合并）:')}</label>
+                    <input type="number" id="editTaskCompactWindow" min="0" max="3600" value="${task.compact_window || ''}" placeholder="0">
+                </div>
+                <div class="form-group">
+                    <label for="editTaskDeleteMode">${t('删除事件投递方式:')}</label>
+                    <select id="editTaskDeleteMode">
+                        <option value="before" ${!task.delete_mode || task.delete_mode === 'before' ? 'selected' : ''}>${t('删除前镜像（默认）')}</option>
+                        <option value="tombstone" ${task.delete_mode === 'tombstone' ? 'selected' : ''}>${t('墓碑（只带主键）')}</option>
+                        <option value="both" ${task.delete_mode === 'both' ? 'selected' : ''}>${t('删除前镜像和墓碑')}</option>
+                    </select>
+                </div>
+                <div class="form-group">
+                    <label for="editTaskMaxLagSeconds">${t('延迟保护（最大延迟秒数和未投递事件数，留空或 0 为不限制）:')}</label>
+                    <input type="number" id="editTaskMaxLagSeconds" min="0" value="${task.max_lag_seconds || ''}" placeholder="${t('最大复制延迟（秒）')}">
+                    <input type="number" id="editTaskMaxLagEvents" min="0" value="${task.max_lag_events || ''}" placeholder="${t('最大未投递事件数')}">
+                    <select id="editTaskLagAction">
+                        <option value="alert" ${!task.lag_action || task.lag_action === 'alert' ? 'selected' : ''}>${t('超过时告警')}</option>
+                        <option value="pause" ${task.lag_action === 'pause' ? 'selected' : ''}>${t('超过时暂停任务')}</option>
+                        <option value="sample" ${task.lag_action === 'sample' ? 'selected' : ''}>${t('超过时切换为采样')}</option>
+                    </select>
+                    <input type="number" id="editTaskLagSample" min="0" max="99.99" step="0.01" value="${task.lag_sample || ''}" placeholder="${t('采样保留的百分比，默认 10')}">
+                </div>
+                <div class="form-group">
+                    <label for="editTaskMaxEventAge">${t('事件最大存活时间（秒，投递失败后超过该时间的事件不再重试，留空或 0 为不限制）:')}</label>
+                    <input type="number" id="editTaskMaxEventAge" min="0" value="${task.max_event_age || ''}">
+                    <select id="editTaskExpiredAction">
+                        <option value="dlq" ${!task.expired_action || task.expired_action === 'dlq' ? 'selected' : ''}>${t('过期事件进入失败事件列表')}</option>
+                        <option value="drop" ${task.expired_action === 'drop' ? 'selected' : ''}>${t('过期事件直接丢弃')}</option>
+                    </select>
+                </div>
+                <div class="form-group">
+                    <label for="editTaskQualityRules">${t('数据质量规则（JSON，留空为不检查）:')}</label>
+                    <textarea id="editTaskQualityRules" rows="3">${task.quality_rules || ''}</textarea>
+                    <input type="url" id="editTaskQuarantineURL" value="${task.quarantine_url || ''}" placeholder="${t('隔离地址：违规的事件改投到该地址，留空则照常投递')}">
+                </div>
+                <div class="form-group">
+                    <label for="editTaskPayloadMapping">${t('载荷映射（JSON，留空为不改写）:')}</label>
+                    <textarea id="editTaskPayloadMapping" rows="3">${task.payload_mapping || ''}</textarea>
+                </div>
+                <div class="form-group">
+                    <label for="editTaskRowFilter">${t('行过滤条件（留空为不过滤）:')}</label>
+                    <textarea id="editTaskRowFilter" rows="2">${task.row_filter || ''}</textarea>
+                </div>
+                <div class="form-group">
+                    <label for="editTaskSourceName">${t('来源标识（留空为 canal-pikachun）:')}</label>
+                    <input type="text" id="editTaskSourceName" maxlength="100" value="${task.source_name || ''}">
+                </div>
+                <div class="form-group">
+                    <label for="editTaskEnvironment">${t('环境（留空为不发送）:')}</label>
+                    <input type="text" id="editTaskEnvironment" maxlength="50" value="${task.environment || ''}">
+                </div>
+                <div class="form-group">
+                    <label for="editTaskUserAgent">${t('User-Agent（留空为 Canal-Pikachun/1.0）:')}</label>
+                    <input type="text" id="editTaskUserAgent" maxlength="200" value="${task.user_agent || ''}">
+                </div>
+                <div class="form-group">
+                    <label><input type="checkbox" id="editTaskDryRun" ${task.dry_run ? 'checked' : ''}> ${t('演练模式（不调用回调地址）')}</label>
+                </div>
+                <div class="form-group">
+                    <label for="editTaskStatus">${t('状态:')}</label>
                     <select id="editTaskStatus">
-                        <option value="active" ${task.status === 'active' ? 'selected' : ''}>活跃</option>
-                        <option value="inactive" ${task.status === 'inactive' ? 'selected' : ''}>非活跃</option>
+                        <option value="active" ${task.status === 'active' ? 'selected' : ''}>${t('活跃')}</option>
+                        <option value="inactive" ${task.status === 'inactive' ? 'selected' : ''}>${t('非活跃')}</option>
                     </select>
                 </div>
-                <button type="submit">保存</button>
+                <button type="submit">${t('保存')}</button>
             </form>
         </div>
     `;
@@ -640,11 +1400,43 @@ function showEditTaskForm(task) {
             table: document.getElementById('editTaskTable').value,
             event_types: document.getElementById('editTaskEventTypes').value,
             callback_url: document.getElementById('editTaskCallbackURL').value,
-            status: document.getElementById('editTaskStatus').value
+            exclude_tables: document.getElementById('editTaskExcludeTables').value,
+            dry_run: document.getElementById('editTaskDryRun').checked,
+            status: document.getElementById('editTaskStatus').value,
+            // 采样总是提交，留空表示关闭
+            sample_percent: parseFloat(document.getElementById('editTaskSamplePercent').value) || 0,
+            sample_interval: parseInt(document.getElementById('editTaskSampleInterval').value) || 0,
+            compact_window: parseInt(document.getElementById('editTaskCompactWindow').value) || 0,
+            delete_mode: document.getElementById('editTaskDeleteMode').value,
+            payload_mapping: document.getElementById('editTaskPayloadMapping').value.trim(),
+            row_filter: document.getElementById('editTaskRowFilter').value.trim(),
+            source_name: document.getElementById('editTaskSourceName').value.trim(),
+            environment: document.getElementById('editTaskEnvironment').value.trim(),
+            user_agent: document.getElementById('editTaskUserAgent').value.trim(),
+            max_lag_seconds: parseInt(document.getElementById('editTaskMaxLagSeconds').value) || 0,
+            max_lag_events: parseInt(document.getElementById('editTaskMaxLagEvents').value) || 0,
+            lag_action: document.getElementById('editTaskLagAction').value,
+            lag_sample: parseFloat(document.getElementById('editTaskLagSample').value) || 0,
+            max_event_age: parseInt(document.getElementById('editTaskMaxEventAge').value) || 0,
+            expired_action: document.getElementById('editTaskExpiredAction').value,
+            quality_rules: document.getElementById('editTaskQualityRules').value.trim(),
+            quarantine_url: document.getElementById('editTaskQuarantineURL').value.trim()
         };
+        const schedule = document.getElementById('editTaskSchedule').value;
+        if (schedule) {
+            taskData.schedule = schedule;
+            taskData.schedule_mode = document.getElementById('editTaskScheduleMode').value;
+        }
+        // 超时和限速只提交填写了的项
+        [['request_timeout', 'editTaskRequestTimeout'], ['delivery_timeout', 'editTaskDeliveryTimeout'], ['shutdown_timeout', 'editTaskShutdownTimeout'], ['schedule_rate', 'editTaskScheduleRate'], ['priority', 'editTaskPriority']].forEach(([key, id]) => {
+            const value = parseInt(document.getElementById(id).value);
+            if (value > 0) {
+                taskData[key] = value;
+            }
+        });
         
         try {
-            const response = await fetch(`/api/tasks/${taskId}`, {
+            const response = await fetch(`/api/v1/tasks/${taskId}`, {
                 method: 'PUT',
                 headers: {
                     'Content-Type': 'application/json'
@@ -655,14 +1447,14 @@ function showEditTaskForm(task) {
             const result = await response.json();
             
             if (response.ok) {
-                showSuccess('任务更新成功');
+                showSuccess(t('任务更新成功'));
                 document.body.removeChild(modal);
                 loadTasks(); // 重新加载任务列表
             } else {
-                showError('更新任务失败: ' + result.error);
+                showError(t('更新任务失败: ') + result.error);
             }
         } catch (error) {
-            showError('网络错误: ' + error.message);
+            showError(t('网络错误: ') + error.message);
         }
     };
 }
@@ -675,18 +1467,18 @@ function showLogDetailModal(log) {
     modal.innerHTML = `
         <div class="modal-content">
             <span class="close">&times;</span>
-            <h2>日志详情</h2>
+            <h2>${t('日志详情')}</h2>
             <div class="log-detail">
                 <p><strong>ID:</strong> ${log.id}</p>
-                <p><strong>任务ID:</strong> ${log.task_id}</p>
-                <p><strong>数据库:</strong> ${log.database}</p>
-                <p><strong>表名:</strong> ${log.table}</p>
-                <p><strong>事件类型:</strong> ${log.event_type}</p>
-                <p><strong>状态:</strong> ${log.status}</p>
-                <p><strong>创建时间:</strong> ${new Date(log.created_at).toLocaleString()}</p>
-                <p><strong>错误信息:</strong> ${log.error || '无'}</p>
+                <p><strong>${t('任务ID:')}</strong> ${log.task_id}</p>
+                <p><strong>${t('数据库:')}</strong> ${log.database}</p>
+                <p><strong>${t('表名:')}</strong> ${log.table}</p>
+                <p><strong>${t('事件类型:')}</strong> ${log.event_type}</p>
+                <p><strong>${t('状态:')}</strong> ${log.status}</p>
+                <p><strong>${t('创建时间:')}</strong> ${new Date(log.created_at).toLocaleString()}</p>
+                <p><strong>${t('错误信息:')}</strong> ${log.error || t('无')}</p>
                 <div class="form-group">
-                    <label for="logData">数据:</label>
+                    <label for="logData">${t('数据:')}</label>
                     <textarea id="logData" readonly>${log.data}</textarea>
                 </div>
             </div>
@@ -712,18 +1504,18 @@ function showLogDetailModal(log) {
 
 // 查看日志详情
 function viewLogDetail(id) {
-    fetch('/api/logs/' + id)
+    fetch('/api/v1/logs/' + id)
         .then(response => response.json())
         .then(data => {
             if (data.data) {
                 showLogDetailModal(data.data);
             } else {
-                showNotification('获取日志详情失败', 'error');
+                showNotification(t('获取日志详情失败'), 'error');
             }
         })
         .catch(error => {
             console.error('获取日志详情失败:', error);
-            showNotification('获取日志详情失败: ' + error.message, 'error');
+            showNotification(t('获取日志详情失败: ') + error.message, 'error');
         });
 }
 
@@ -741,7 +1533,7 @@ function showLogDetailModal(log) {
     statusElement.textContent = log.status;
     statusElement.className = 'status-tag status-' + log.status;
     
-    document.getElementById('logDetailCreatedAt').textContent = new Date(log.created_at).toLocaleString('zh-CN');
+    document.getElementById('logDetailCreatedAt').textContent = new Date(log.created_at).toLocaleString(i18n.language);
     document.getElementById('logDetailData').textContent = log.data;
     
     // 处理错误信息显示
@@ -754,10 +1546,37 @@ function showLogDetailModal(log) {
         errorGroup.style.display = 'none';
     }
     
+    loadLogDeliveries(log.id);
+
     // 显示模态框
     document.getElementById('logDetailModal').style.display = 'block';
 }
 
+// 加载事件的投递历史
+function loadLogDeliveries(id) {
+    const group = document.getElementById('logDetailDeliveriesGroup');
+    const element = document.getElementById('logDetailDeliveries');
+    group.style.display = 'none';
+
+    fetch('/api/v1/logs/' + id + '/deliveries')
+        .then(response => response.json())
+        .then(result => {
+            const attempts = (result.data && result.data.attempts) || [];
+            if (attempts.length === 0) return;
+
+            element.textContent = attempts.map(a => {
+                const time = new Date(a.created_at).toLocaleString(i18n.language);
+                const status = a.status_code ? 'HTTP ' + a.status_code : t('无响应');
+                let line = `#${a.attempt} ${time} ${status} ${a.latency_ms}ms ${a.url}`;
+                if (a.error) line += '\n    ' + t('错误: ') + a.error;
+                if (a.response_body) line += '\n    ' + t('响应: ') + a.response_body;
+                return line;
+            }).join('\n');
+            group.style.display = 'block';
+        })
+        .catch(error => console.error('获取投递历史失败:', error));
+}
+
 // 隐藏日志详情模态框
 function hideLogDetailModal() {
     document.getElementById('logDetailModal').style.display = 'none';